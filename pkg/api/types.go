@@ -0,0 +1,91 @@
+// SPDX-License-Identifier:Apache-2.0
+
+// Package api is the result model of a comparison for Go programs consuming the results of kube-compare. Unlike the
+// structs the output is printed from, the types of this package only change in backward compatible ways within an
+// APIVersion: fields may be added, never renamed or removed.
+package api
+
+// APIVersion is the version of the result model, it's bumped on backward incompatible changes
+const APIVersion = "kube-compare.openshift.io/v1"
+
+// ComparisonResultKind is the kind of a ComparisonResult
+const ComparisonResultKind = "ComparisonResult"
+
+// Severity is how severe a deviation from the reference is
+type Severity string
+
+const (
+	// SeverityCritical deviations break the reference, e.g. a required CR is missing
+	SeverityCritical Severity = "critical"
+	// SeverityWarning deviations should be reviewed, e.g. a CR differs from its template
+	SeverityWarning Severity = "warning"
+	// SeverityInfo deviations are only reported, e.g. a CR isn't matched by any template
+	SeverityInfo Severity = "info"
+)
+
+// ComparisonResult is the result of comparing a cluster, or local CRs, to a reference
+type ComparisonResult struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	// MetadataHash identifies the content of the reference the cluster was compared to
+	MetadataHash string `json:"metadataHash"`
+	// Interrupted is the reason the comparison stopped before all the CRs were compared, the result then only covers
+	// the CRs compared until then and Missing is empty
+	Interrupted string        `json:"interrupted,omitempty"`
+	Summary     ResultSummary `json:"summary"`
+	// Resources are the results of the cluster CRs matched by a template, sorted by name
+	Resources []ResourceResult `json:"resources"`
+	// Missing are the templates of the reference that no cluster CR matched while they're required
+	Missing []MissingEntry `json:"missing"`
+	// Unmatched are the names of the cluster CRs that no template matched
+	Unmatched []string `json:"unmatched"`
+}
+
+// ResultSummary counts the results of the comparison
+type ResultSummary struct {
+	TotalResources     int `json:"totalResources"`
+	ResourcesWithDiffs int `json:"resourcesWithDiffs"`
+	PatchedResources   int `json:"patchedResources"`
+	Missing            int `json:"missing"`
+	Unmatched          int `json:"unmatched"`
+}
+
+// ResourceResult is the result of comparing a cluster CR to the template it matched
+type ResourceResult struct {
+	// Name identifies the cluster CR by its apiVersion, kind, namespace and name, e.g. v1_ConfigMap_example_cm
+	Name     string `json:"name"`
+	Template string `json:"template"`
+	// Part and Component are the reference component the template belongs to, empty for v1 references
+	Part      string `json:"part,omitempty"`
+	Component string `json:"component,omitempty"`
+	// Diff is the diff of the CR against the template, empty if the CR matches the template
+	Diff string `json:"diff,omitempty"`
+	// Severity is set when the CR differs from the template
+	Severity Severity `json:"severity,omitempty"`
+	// Patched is the user override the template was patched with, if any, and PatchReasons the reasons given for it
+	Patched      string   `json:"patched,omitempty"`
+	PatchReasons []string `json:"patchReasons,omitempty"`
+	Description  string   `json:"description,omitempty"`
+	// DocURL, Owner and Remediation are the annotations of the template: a link to its documentation, who to contact
+	// about it and how to fix a CR differing from it
+	DocURL      string   `json:"docURL,omitempty"`
+	Owner       string   `json:"owner,omitempty"`
+	Remediation string   `json:"remediation,omitempty"`
+	Warnings    []string `json:"warnings,omitempty"`
+}
+
+// HasDiff checks if the CR differs from its template
+func (r ResourceResult) HasDiff() bool {
+	return r.Diff != ""
+}
+
+// MissingEntry is a template of a component that no cluster CR matched
+type MissingEntry struct {
+	Part      string `json:"part"`
+	Component string `json:"component"`
+	Template  string `json:"template"`
+	// Reason tells why the template is reported, e.g. that a required component is missing
+	Reason      string   `json:"reason"`
+	Description string   `json:"description,omitempty"`
+	Severity    Severity `json:"severity"`
+}
@@ -0,0 +1,129 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// CorrelateBy correlates a template with the cluster CRs stamped with its id, e.g. by a GitOps pipeline, regardless of
+// their names
+type CorrelateBy struct {
+	// Annotation is the key of the annotation of the cluster CRs holding the id of their template
+	Annotation string `json:"annotation"`
+	// ID is the id of the template in the annotation, defaults to the path of the template
+	ID string `json:"id,omitempty"`
+}
+
+func (c *CorrelateBy) validate() error {
+	if c.Annotation == "" {
+		return errors.New("correlateBy must set annotation")
+	}
+	if errs := validation.IsQualifiedName(c.Annotation); len(errs) > 0 {
+		return fmt.Errorf("correlateBy annotation %q isn't a valid annotation key: %s", c.Annotation, strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// annotationID returns the id of the template in the annotation it is correlated by
+func annotationID(temp ReferenceTemplate, c *CorrelateBy) string {
+	if c.ID != "" {
+		return c.ID
+	}
+	return temp.GetIdentifier()
+}
+
+// AnnotationCorrelator Matches templates declaring correlateBy in their config to the cluster CRs of their kind whose
+// annotation holds the id of the template.
+type AnnotationCorrelator struct {
+	// keys are the annotation keys declared by the templates, sorted to try them in a stable order
+	keys []string
+	// templates are the templates by annotation key and id
+	templates map[string]map[string]ReferenceTemplate
+}
+
+// NewAnnotationCorrelator indexes the templates declaring correlateBy, two templates with the same annotation and id
+// would make the correlation ambiguous and are an error
+func NewAnnotationCorrelator(templates []ReferenceTemplate) (*AnnotationCorrelator, error) {
+	c := AnnotationCorrelator{templates: make(map[string]map[string]ReferenceTemplate)}
+	var errs []error
+	for _, temp := range templates {
+		correlateBy := temp.GetConfig().GetCorrelateBy()
+		if correlateBy == nil {
+			continue
+		}
+		ids, ok := c.templates[correlateBy.Annotation]
+		if !ok {
+			ids = make(map[string]ReferenceTemplate)
+			c.templates[correlateBy.Annotation] = ids
+			c.keys = append(c.keys, correlateBy.Annotation)
+		}
+		id := annotationID(temp, correlateBy)
+		if other, ok := ids[id]; ok {
+			errs = append(errs, fmt.Errorf("templates %s and %s are both correlated by %s=%s",
+				other.GetIdentifier(), temp.GetIdentifier(), correlateBy.Annotation, id))
+			continue
+		}
+		ids[id] = temp
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	sort.Strings(c.keys)
+	return &c, nil
+}
+
+// isEmpty returns whether no template is correlated by annotation
+func (c AnnotationCorrelator) isEmpty() bool {
+	return len(c.keys) == 0
+}
+
+func (c AnnotationCorrelator) Match(object *unstructured.Unstructured) ([]ReferenceTemplate, error) {
+	annotations := object.GetAnnotations()
+	for _, key := range c.keys {
+		id, ok := annotations[key]
+		if !ok {
+			continue
+		}
+		temp, ok := c.templates[key][id]
+		if !ok {
+			continue
+		}
+		// The annotation may be copied to CRs of other kinds, e.g. the pods of a deployment
+		if temp.GetMetadata().GroupVersionKind().GroupKind() == object.GroupVersionKind().GroupKind() {
+			return []ReferenceTemplate{temp}, nil
+		}
+	}
+	return []ReferenceTemplate{}, UnknownMatch{Resource: object}
+}
+
+// withoutCorrelationAnnotation returns the cluster CR without the annotation correlating it to the template, the
+// annotation is stamped on the CR rather than part of the expected configuration, unless the template sets it
+func withoutCorrelationAnnotation(temp ReferenceTemplate, rendered, clusterCR *unstructured.Unstructured) *unstructured.Unstructured {
+	correlateBy := temp.GetConfig().GetCorrelateBy()
+	if correlateBy == nil {
+		return clusterCR
+	}
+	if _, ok := clusterCR.GetAnnotations()[correlateBy.Annotation]; !ok {
+		return clusterCR
+	}
+	if _, ok := rendered.GetAnnotations()[correlateBy.Annotation]; ok {
+		return clusterCR
+	}
+	// The cluster CR is shared by the templates it's compared to
+	cr := clusterCR.DeepCopy()
+	annotations := cr.GetAnnotations()
+	delete(annotations, correlateBy.Annotation)
+	if len(annotations) == 0 {
+		unstructured.RemoveNestedField(cr.Object, "metadata", "annotations")
+	} else {
+		cr.SetAnnotations(annotations)
+	}
+	return cr
+}
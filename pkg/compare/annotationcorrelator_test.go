@@ -0,0 +1,71 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestAnnotationCorrelator(t *testing.T) {
+	const key = "policy.example.com/template-id"
+	newTemplate := func(path, kind string, correlateBy *CorrelateBy) ReferenceTemplate {
+		metadata := &unstructured.Unstructured{Object: map[string]any{}}
+		metadata.SetAPIVersion("v1")
+		metadata.SetKind(kind)
+		return ReferenceTemplateV2{
+			Config:              ReferenceTemplateConfigV2{CorrelateBy: correlateBy},
+			ReferenceTemplateV1: ReferenceTemplateV1{Path: path, metadata: metadata},
+		}
+	}
+	templates := []ReferenceTemplate{
+		newTemplate("small.yaml", "ConfigMap", &CorrelateBy{Annotation: key, ID: "small"}),
+		newTemplate("large.yaml", "ConfigMap", &CorrelateBy{Annotation: key}),
+		newTemplate("other.yaml", "ConfigMap", nil),
+	}
+	correlator, err := NewAnnotationCorrelator(templates)
+	require.NoError(t, err)
+
+	newCR := func(kind, id string) *unstructured.Unstructured {
+		cr := &unstructured.Unstructured{Object: map[string]any{}}
+		cr.SetAPIVersion("v1")
+		cr.SetKind(kind)
+		cr.SetName("cr")
+		if id != "" {
+			cr.SetAnnotations(map[string]string{key: id})
+		}
+		return cr
+	}
+	cases := []struct {
+		name     string
+		cr       *unstructured.Unstructured
+		expected string
+	}{
+		{name: "declared id", cr: newCR("ConfigMap", "small"), expected: "small.yaml"},
+		{name: "path as id", cr: newCR("ConfigMap", "large.yaml"), expected: "large.yaml"},
+		{name: "unknown id", cr: newCR("ConfigMap", "medium")},
+		{name: "not annotated", cr: newCR("ConfigMap", "")},
+		{name: "other kind", cr: newCR("Secret", "small")},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			matches, err := correlator.Match(c.cr)
+			if c.expected == "" {
+				require.ErrorAs(t, err, &UnknownMatch{})
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, matches, 1)
+			require.Equal(t, c.expected, matches[0].GetIdentifier())
+		})
+	}
+
+	t.Run("annotation removed from the compared CR", func(t *testing.T) {
+		cr := newCR("ConfigMap", "small")
+		stripped := withoutCorrelationAnnotation(templates[0], &unstructured.Unstructured{Object: map[string]any{}}, cr)
+		require.Empty(t, stripped.GetAnnotations())
+		require.Equal(t, map[string]string{key: "small"}, cr.GetAnnotations())
+	})
+}
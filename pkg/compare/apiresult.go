@@ -0,0 +1,87 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"cmp"
+	"slices"
+	"sort"
+
+	"github.com/openshift/kube-compare/pkg/api"
+)
+
+// NewComparisonResult converts the summary and the diffs of a comparison to the result model of the api package
+func NewComparisonResult(sum *Summary, diffs []DiffSum) *api.ComparisonResult {
+	result := &api.ComparisonResult{
+		APIVersion:   api.APIVersion,
+		Kind:         api.ComparisonResultKind,
+		MetadataHash: sum.MetadataHash,
+		Interrupted:  sum.Interrupted,
+		Summary: api.ResultSummary{
+			TotalResources:     sum.TotalCRs,
+			ResourcesWithDiffs: sum.NumDiffCRs,
+			PatchedResources:   sum.PatchedCRs,
+			Missing:            sum.NumMissing,
+			Unmatched:          len(sum.UnmatchedCRS),
+		},
+		Resources: make([]api.ResourceResult, 0, len(diffs)),
+		Missing:   []api.MissingEntry{},
+		Unmatched: slices.Clone(sum.UnmatchedCRS),
+	}
+	if result.Unmatched == nil {
+		result.Unmatched = []string{}
+	}
+	for _, d := range diffs {
+		r := api.ResourceResult{
+			Name:         d.CRName,
+			Template:     d.CorrelatedTemplate,
+			Part:         d.Part,
+			Component:    d.Component,
+			Diff:         d.DiffOutput,
+			Patched:      d.Patched,
+			PatchReasons: d.OverrideReasons,
+			Description:  d.Description,
+			DocURL:       d.DocURL,
+			Owner:        d.Owner,
+			Remediation:  d.Remediation,
+			Warnings:     d.Warnings,
+		}
+		if d.HasDiff() {
+			r.Severity = cmp.Or(d.Severity, api.SeverityWarning)
+		}
+		result.Resources = append(result.Resources, r)
+	}
+	sort.SliceStable(result.Resources, func(i, j int) bool { return result.Resources[i].Name < result.Resources[j].Name })
+
+	for part, components := range sum.ValidationIssues {
+		for component, issue := range components {
+			for _, temp := range issue.CRs {
+				result.Missing = append(result.Missing, api.MissingEntry{
+					Part:        part,
+					Component:   component,
+					Template:    temp,
+					Reason:      issue.Msg,
+					Description: issue.CRMetadata[temp].Description,
+					Severity:    api.SeverityCritical,
+				})
+			}
+		}
+	}
+	sort.Slice(result.Missing, func(i, j int) bool {
+		a, b := result.Missing[i], result.Missing[j]
+		if a.Part != b.Part {
+			return a.Part < b.Part
+		}
+		if a.Component != b.Component {
+			return a.Component < b.Component
+		}
+		return a.Template < b.Template
+	})
+	return result
+}
+
+// ComparisonResult returns the result in the model of the api package, which is stable across releases unlike Summary
+// and DiffSum
+func (r *Result) ComparisonResult() *api.ComparisonResult {
+	return NewComparisonResult(r.Summary, r.Diffs)
+}
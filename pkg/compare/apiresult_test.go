@@ -0,0 +1,52 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"testing"
+
+	"github.com/openshift/kube-compare/pkg/api"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewComparisonResult(t *testing.T) {
+	sum := &Summary{
+		ValidationIssues: map[string]map[string]ValidationIssue{
+			"ExamplePart": {"Required": {
+				Msg:        "Missing CRs",
+				CRs:        []string{"ns.yaml", "cm.yaml"},
+				CRMetadata: map[string]CRMetadata{"cm.yaml": {Description: "The config of the example"}},
+			}},
+		},
+		NumMissing:   2,
+		UnmatchedCRS: []string{"v1_Secret_example_extra"},
+		NumDiffCRs:   2,
+		TotalCRs:     3,
+		MetadataHash: "hash",
+	}
+	diffs := []DiffSum{
+		{CRName: "v1_Service_example_svc", CorrelatedTemplate: "svc.yaml", Part: "ExamplePart", Component: "Services"},
+		{CRName: "v1_ConfigMap_example_cm", CorrelatedTemplate: "cm.yaml", DiffOutput: "-key: value\n+key: other",
+			TemplateAnnotations: TemplateAnnotations{DocURL: "https://example.com/cm", Owner: "team", Remediation: "Reset the key"}},
+		{CRName: "v1_ConfigMap_example_labels", CorrelatedTemplate: "labels.yaml", DiffOutput: "-team: a\n+team: b", Severity: api.SeverityInfo},
+	}
+
+	require.Equal(t, &api.ComparisonResult{
+		APIVersion:   api.APIVersion,
+		Kind:         api.ComparisonResultKind,
+		MetadataHash: "hash",
+		Summary:      api.ResultSummary{TotalResources: 3, ResourcesWithDiffs: 2, Missing: 2, Unmatched: 1},
+		Resources: []api.ResourceResult{
+			{Name: "v1_ConfigMap_example_cm", Template: "cm.yaml", Diff: "-key: value\n+key: other", Severity: api.SeverityWarning,
+				DocURL: "https://example.com/cm", Owner: "team", Remediation: "Reset the key"},
+			{Name: "v1_ConfigMap_example_labels", Template: "labels.yaml", Diff: "-team: a\n+team: b", Severity: api.SeverityInfo},
+			{Name: "v1_Service_example_svc", Template: "svc.yaml", Part: "ExamplePart", Component: "Services"},
+		},
+		Missing: []api.MissingEntry{
+			{Part: "ExamplePart", Component: "Required", Template: "cm.yaml", Reason: "Missing CRs",
+				Description: "The config of the example", Severity: api.SeverityCritical},
+			{Part: "ExamplePart", Component: "Required", Template: "ns.yaml", Reason: "Missing CRs", Severity: api.SeverityCritical},
+		},
+		Unmatched: []string{"v1_Secret_example_extra"},
+	}, NewComparisonResult(sum, diffs))
+}
@@ -0,0 +1,145 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"k8s.io/cli-runtime/pkg/resource"
+)
+
+// isArchive checks if the local CR file is an archive of CR files, e.g. a compressed must-gather
+func isArchive(name string) bool {
+	return strings.HasSuffix(name, ".zip") || strings.HasSuffix(name, ".tgz") || strings.HasSuffix(name, ".tar.gz")
+}
+
+// openArchive returns the file system of the archive. Zip archives are read on demand, the files of tar.gz archives
+// can only be read in order so the CR files are read in memory once, the other files (e.g. the logs of a must-gather)
+// are skipped.
+func openArchive(name string) (fs.FS, error) {
+	if strings.HasSuffix(name, ".zip") {
+		// The archive is kept open so the CR files are read from it every time the CRs are visited
+		r, err := zip.OpenReader(name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open archive %s: %w", name, err)
+		}
+		return r, nil
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive %s: %w", name, err)
+	}
+	defer f.Close()
+	gr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive %s: %w", name, err)
+	}
+	afs := bundleFS{}
+	tr := tar.NewReader(gr)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read archive %s: %w", name, err)
+		}
+		p := path.Clean(header.Name)
+		if header.Typeflag != tar.TypeReg || !fs.ValidPath(p) || !slices.Contains(resource.FileExtensions, path.Ext(p)) {
+			continue
+		}
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s from archive %s: %w", p, name, err)
+		}
+		afs[p] = content
+	}
+	return afs, nil
+}
+
+// archiveCRs finds the CR files of the archive, like in a directory only the CR files at the root of the archive are
+// found unless recursive is set. The CR files are streamed to the builder from the archive.
+func archiveCRs(name string, recursive bool) ([]streamedCR, error) {
+	afs, err := openArchive(name)
+	if err != nil {
+		return nil, err
+	}
+	var result []streamedCR
+	err = fs.WalkDir(afs, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if p != "." && !recursive {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if slices.Contains(resource.FileExtensions, path.Ext(p)) {
+			result = append(result, streamedCR{
+				name: filepath.Join(name, filepath.FromSlash(p)),
+				open: func() (io.ReadCloser, error) { return afs.Open(p) },
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the CR files of archive %s: %w", name, err)
+	}
+	return result, nil
+}
+
+// readArchives returns the CR files of the archives among the local CR files, together with the rest of the files to
+// pass to the builder
+func readArchives(filenames []string, recursive bool) ([]string, []streamedCR, error) {
+	var plain []string
+	var archived []streamedCR
+	for _, f := range filenames {
+		if f == stdinFilename || isURL(f) || !isArchive(f) {
+			plain = append(plain, f)
+			continue
+		}
+		crs, err := archiveCRs(f, recursive)
+		if err != nil {
+			return nil, nil, err
+		}
+		archived = append(archived, crs...)
+	}
+	return plain, archived, nil
+}
+
+// lazyReader opens the file on the first read and closes it once it was fully read, so the CR files of an archive
+// aren't all open at once
+type lazyReader struct {
+	open func() (io.ReadCloser, error)
+	r    io.ReadCloser
+	err  error
+}
+
+func (l *lazyReader) Read(p []byte) (int, error) {
+	if l.err != nil {
+		return 0, l.err
+	}
+	if l.r == nil {
+		if l.r, l.err = l.open(); l.err != nil {
+			return 0, l.err
+		}
+	}
+	n, err := l.r.Read(p)
+	if err != nil {
+		l.r.Close()
+		l.err = err
+	}
+	return n, err
+}
@@ -0,0 +1,361 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+	kcmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"k8s.io/kubectl/pkg/util/i18n"
+	"k8s.io/kubectl/pkg/util/templates"
+)
+
+var (
+	bundleLong = templates.LongDesc(`
+		Package a reference configuration into a single tarball.
+
+		The bundle command loads the reference and writes the reference config, the templates and the template
+		function files it uses into a gzipped tarball. The reference config is stored as metadata.yaml at the root of
+		the tarball and the rest of the files keep their path relative to it, files that aren't used by the reference
+		aren't included. The tarball is reproducible: bundling the same reference content always produces the same
+		bytes, so it can be signed and its checksum published.
+
+		A bundle (a file with a .tgz or .tar.gz extension, either local or served over http) can be passed to -r
+		instead of a reference config.
+	`)
+
+	bundleExample = templates.Examples(`
+		# Bundle a reference configuration:
+		kubectl cluster-compare bundle -r ./reference/metadata.yaml -o ref.tgz
+
+		# Compare a cluster to a bundled reference:
+		kubectl cluster-compare -r ref.tgz
+	`)
+)
+
+// BundleReferenceFileName is the name of the reference config in a bundle
+const BundleReferenceFileName = "metadata.yaml"
+
+// isBundle checks if the reference is a bundle written by the bundle command
+func isBundle(refConfig string) bool {
+	return strings.HasSuffix(refConfig, ".tgz") || strings.HasSuffix(refConfig, ".tar.gz")
+}
+
+// ReferenceFileName returns the name of the reference config in the file system returned by GetRefFS
+func ReferenceFileName(refConfig string) string {
+	if isBundle(refConfig) {
+		return BundleReferenceFileName
+	}
+	return filepath.Base(refConfig)
+}
+
+// bundleFile is a file of the reference in a bundle
+type bundleFile struct {
+	name    string
+	content []byte
+}
+
+// writeBundle writes the files into a gzipped tarball, the files are written in order with fixed metadata so the
+// same files always result in the same tarball
+func writeBundle(w io.Writer, files []bundleFile) error {
+	sort.Slice(files, func(i, j int) bool { return files[i].name < files[j].name })
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+	for _, f := range files {
+		header := &tar.Header{
+			Name:    f.name,
+			Mode:    0o644,
+			Size:    int64(len(f.content)),
+			ModTime: time.Unix(0, 0),
+			Format:  tar.FormatPAX,
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return fmt.Errorf("failed to write %s to bundle: %w", f.name, err)
+		}
+		if _, err := tw.Write(f.content); err != nil {
+			return fmt.Errorf("failed to write %s to bundle: %w", f.name, err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to write bundle: %w", err)
+	}
+	if err := gw.Close(); err != nil {
+		return fmt.Errorf("failed to write bundle: %w", err)
+	}
+	return nil
+}
+
+// loadBundle reads a bundle from a local path or a URL into an in memory file system
+func loadBundle(refConfig string, client *referenceHTTPClient) (fs.FS, error) {
+	content, err := readReference(refConfig, client)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open bundle: %w", err)
+	}
+	return readBundle(bytes.NewReader(content))
+}
+
+func readBundle(r io.Reader) (fs.FS, error) {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bundle: %w", err)
+	}
+	bfs := bundleFS{}
+	tr := tar.NewReader(gr)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read bundle: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		name := path.Clean(header.Name)
+		if !fs.ValidPath(name) {
+			return nil, fmt.Errorf("bundle contains a file with an invalid path: %s", header.Name)
+		}
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s from bundle: %w", name, err)
+		}
+		bfs[name] = content
+	}
+	if _, ok := bfs[BundleReferenceFileName]; !ok {
+		return nil, fmt.Errorf("bundle doesn't contain a %s reference config", BundleReferenceFileName)
+	}
+	return bfs, nil
+}
+
+// bundleFS is the in memory file system of a bundle, mapping the path of every file to its content
+type bundleFS map[string][]byte
+
+func (b bundleFS) Open(name string) (fs.File, error) {
+	info, err := b.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return &bundleDir{info: info, entries: b.entries(name)}, nil
+	}
+	return lockedFile{Reader: bytes.NewReader(b[name]), info: info}, nil
+}
+
+func (b bundleFS) Stat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+	if content, ok := b[name]; ok {
+		return bundleFileInfo{name: path.Base(name), size: int64(len(content))}, nil
+	}
+	if name == "." || len(b.entries(name)) > 0 {
+		return bundleFileInfo{name: path.Base(name), dir: true}, nil
+	}
+	return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+}
+
+// ReadDir lists the files and directories directly within the directory
+func (b bundleFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	entries := b.entries(name)
+	if len(entries) == 0 && name != "." {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+	return entries, nil
+}
+
+func (b bundleFS) entries(dir string) []fs.DirEntry {
+	prefix := dir + "/"
+	if dir == "." {
+		prefix = ""
+	}
+	seen := make(map[string]bool)
+	var entries []fs.DirEntry
+	for name, content := range b {
+		rest, ok := strings.CutPrefix(name, prefix)
+		if !ok {
+			continue
+		}
+		child, _, isDir := strings.Cut(rest, "/")
+		if seen[child] {
+			continue
+		}
+		seen[child] = true
+		entries = append(entries, fs.FileInfoToDirEntry(bundleFileInfo{name: child, size: int64(len(content)), dir: isDir}))
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries
+}
+
+// bundleDir is an opened directory of a bundle
+type bundleDir struct {
+	info    fs.FileInfo
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (d *bundleDir) Stat() (fs.FileInfo, error) {
+	return d.info, nil
+}
+
+func (d *bundleDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.Name(), Err: errors.New("is a directory")}
+}
+
+func (d *bundleDir) Close() error {
+	return nil
+}
+
+// ReadDir returns the next n entries of the directory, or all the remaining ones if n <= 0
+func (d *bundleDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	remaining := d.entries[d.offset:]
+	if n <= 0 {
+		d.offset = len(d.entries)
+		return remaining, nil
+	}
+	if len(remaining) == 0 {
+		return nil, io.EOF
+	}
+	n = min(n, len(remaining))
+	d.offset += n
+	return remaining[:n], nil
+}
+
+type bundleFileInfo struct {
+	name string
+	size int64
+	dir  bool
+}
+
+func (f bundleFileInfo) Name() string {
+	return f.name
+}
+
+func (f bundleFileInfo) Size() int64 {
+	if f.dir {
+		return 0
+	}
+	return f.size
+}
+
+func (f bundleFileInfo) Mode() fs.FileMode {
+	if f.dir {
+		return fs.ModeDir | 0o555
+	}
+	return 0o444
+}
+
+func (f bundleFileInfo) ModTime() time.Time {
+	return time.Unix(0, 0)
+}
+
+func (f bundleFileInfo) IsDir() bool {
+	return f.dir
+}
+
+func (f bundleFileInfo) Sys() any {
+	return nil
+}
+
+type BundleOptions struct {
+	referenceConfig string
+	outputPath      string
+
+	genericiooptions.IOStreams
+}
+
+func NewBundleCmd(streams genericiooptions.IOStreams) *cobra.Command {
+	options := &BundleOptions{IOStreams: streams}
+
+	cmd := &cobra.Command{
+		Use:                   "bundle -r <Reference File> -o <Bundle File>",
+		DisableFlagsInUseLine: true,
+		Short:                 i18n.T("Package a reference configuration into a single tarball."),
+		Long:                  bundleLong,
+		Example:               exampleForBinary(bundleExample),
+		Run: func(cmd *cobra.Command, args []string) {
+			kcmdutil.CheckDiffErr(options.Complete(cmd, args))
+			kcmdutil.CheckDiffErr(options.Run())
+		},
+	}
+	cmd.SetFlagErrorFunc(func(command *cobra.Command, err error) error {
+		kcmdutil.CheckDiffErr(kcmdutil.UsageErrorf(cmd, err.Error()))
+		return nil
+	})
+	cmd.Flags().StringVarP(&options.referenceConfig, "reference", "r", "", "Path to reference config file.")
+	cmd.Flags().StringVarP(&options.outputPath, "output", "o", "", "Path of the bundle to write, must have a .tgz or .tar.gz extension.")
+	return cmd
+}
+
+func (o *BundleOptions) Complete(cmd *cobra.Command, args []string) error {
+	if len(args) != 0 {
+		return kcmdutil.UsageErrorf(cmd, "Unexpected args: %v", args)
+	}
+	if o.referenceConfig == "" {
+		return kcmdutil.UsageErrorf(cmd, noRefFileWasPassed)
+	}
+	if _, err := os.Stat(o.referenceConfig); os.IsNotExist(err) && !isURL(o.referenceConfig) {
+		return errors.New(refFileNotExistsError)
+	}
+	if !isBundle(o.outputPath) {
+		return kcmdutil.UsageErrorf(cmd, "--output must be a path with a .tgz or .tar.gz extension")
+	}
+	return nil
+}
+
+// Run bundles the files used by the reference
+func (o *BundleOptions) Run() error {
+	content, numFiles, err := bundleReference(o.referenceConfig)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(o.outputPath, content, 0o644); err != nil { // nolint:gosec
+		return fmt.Errorf("failed to write bundle: %w", err)
+	}
+	if _, err := fmt.Fprintf(o.Out, "Bundled %d files of the reference in %s\n", numFiles, o.outputPath); err != nil {
+		return fmt.Errorf("error occurred when writing output: %w", err)
+	}
+	return nil
+}
+
+// bundleReference returns the bundle of the files used by the reference and the number of files in it
+func bundleReference(refConfig string) ([]byte, int, error) {
+	cfs, err := GetRefFS(refConfig)
+	if err != nil {
+		return nil, 0, err
+	}
+	loaded, err := recordReferenceFiles(cfs, ReferenceFileName(refConfig))
+	if err != nil {
+		return nil, 0, err
+	}
+	files := make([]bundleFile, 0, len(loaded))
+	for _, f := range loaded {
+		name := f.Path
+		if name == ReferenceFileName(refConfig) {
+			name = BundleReferenceFileName
+		} else if name == BundleReferenceFileName {
+			return nil, 0, fmt.Errorf("the reference uses a file named %s, it would conflict with the reference config in the bundle", name)
+		}
+		files = append(files, bundleFile{name: name, content: f.content})
+	}
+
+	var buf bytes.Buffer
+	if err := writeBundle(&buf, files); err != nil {
+		return nil, 0, err
+	}
+	return buf.Bytes(), len(files), nil
+}
@@ -0,0 +1,58 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"bytes"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBundleRoundTrip(t *testing.T) {
+	files := []bundleFile{
+		{name: "metadata.yaml", content: []byte(`apiVersion: v2
+parts:
+  - name: Part
+    components:
+      - name: Component
+        allOf:
+          - path: templates/cm.yaml
+templateFunctionFiles:
+  - functions/*.tmpl
+`)},
+		{name: "templates/cm.yaml", content: []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: {{ template "name" }}
+`)},
+		{name: "functions/name.tmpl", content: []byte(`{{- define "name" }}example{{ end -}}`)},
+	}
+	var first, second bytes.Buffer
+	require.NoError(t, writeBundle(&first, files))
+	require.NoError(t, writeBundle(&second, files))
+	require.Equal(t, first.Bytes(), second.Bytes(), "bundling the same files should be reproducible")
+
+	bfs, err := readBundle(&first)
+	require.NoError(t, err)
+	require.NoError(t, fstest.TestFS(bfs, "metadata.yaml", "templates/cm.yaml", "functions/name.tmpl"))
+
+	ref, err := GetReference(bfs, BundleReferenceFileName)
+	require.NoError(t, err)
+	temps, err := ParseTemplates(ref, bfs)
+	require.NoError(t, err)
+	require.Len(t, temps, 1)
+	require.Equal(t, "example", temps[0].GetMetadata().GetName())
+
+	_, err = fs.ReadFile(bfs, "missing.yaml")
+	require.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+func TestReadBundleWithoutReferenceConfig(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, writeBundle(&buf, []bundleFile{{name: "cm.yaml", content: []byte("kind: ConfigMap")}}))
+	_, err := readBundle(&buf)
+	require.ErrorContains(t, err, "bundle doesn't contain a metadata.yaml reference config")
+}
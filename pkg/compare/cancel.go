@@ -0,0 +1,81 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"k8s.io/utils/exec"
+)
+
+// withCancellation returns a context that is cancelled when the timeout expires (if it's set) or when the process is
+// interrupted. Only the first interrupt is handled, a second one kills the process as usual.
+func withCancellation(parent context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx, cancel := context.WithCancelCause(parent)
+	stopTimeout := func() bool { return false }
+	if timeout > 0 {
+		stopTimeout = time.AfterFunc(timeout, func() {
+			cancel(fmt.Errorf("timed out after %s", timeout))
+		}).Stop
+	}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case s := <-signals:
+			signal.Stop(signals)
+			cancel(fmt.Errorf("received %s", s))
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, func() {
+		signal.Stop(signals)
+		stopTimeout()
+		cancel(context.Canceled)
+	}
+}
+
+// interruptedError is returned when the comparison is cancelled before all the cluster CRs were compared, a partial
+// summary of the CRs compared until then is still reported
+type interruptedError struct {
+	cause error
+}
+
+func (e interruptedError) Error() string {
+	return fmt.Sprintf("comparison interrupted before all the cluster CRs were compared: %s", e.cause)
+}
+
+func (e interruptedError) Unwrap() error {
+	return e.cause
+}
+
+// diffLimitReached is the cause of stopping the comparison once the number of CRs with diffs set by --max-diffs (or
+// --fail-fast) was found
+type diffLimitReached struct {
+	maxDiffs int
+}
+
+func (e diffLimitReached) Error() string {
+	if e.maxDiffs == 1 {
+		return "stopped at the first CR with diffs"
+	}
+	return fmt.Sprintf("stopped after %d CRs with diffs", e.maxDiffs)
+}
+
+// contextExec runs the commands of an exec.Interface with a context, so the commands are killed when it's done
+type contextExec struct {
+	exec.Interface
+	ctx context.Context
+}
+
+func (e contextExec) Command(cmd string, args ...string) exec.Cmd {
+	return e.Interface.CommandContext(e.ctx, cmd, args...)
+}
@@ -0,0 +1,44 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/utils/exec"
+)
+
+func TestWithCancellation(t *testing.T) {
+	t.Run("timeout", func(t *testing.T) {
+		ctx, cancel := withCancellation(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		<-ctx.Done()
+		require.EqualError(t, context.Cause(ctx), "timed out after 10ms")
+	})
+	t.Run("no timeout", func(t *testing.T) {
+		ctx, cancel := withCancellation(nil, 0) //nolint:staticcheck
+		require.NoError(t, ctx.Err())
+		cancel()
+		require.ErrorIs(t, ctx.Err(), context.Canceled)
+	})
+	t.Run("cancelled parent", func(t *testing.T) {
+		parent, cancelParent := context.WithCancelCause(context.Background())
+		cancelParent(errors.New("stop"))
+		ctx, cancel := withCancellation(parent, time.Hour)
+		defer cancel()
+		require.EqualError(t, context.Cause(ctx), "stop")
+	})
+}
+
+func TestContextExecKillsCommand(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := contextExec{Interface: exec.New(), ctx: ctx}.Command("sleep", "10").Run()
+	require.Error(t, err)
+	require.Less(t, time.Since(start), 5*time.Second)
+}
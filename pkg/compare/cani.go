@@ -0,0 +1,276 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+	kcmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"k8s.io/kubectl/pkg/util/i18n"
+	"k8s.io/kubectl/pkg/util/templates"
+	"k8s.io/utils/exec"
+	"sigs.k8s.io/yaml"
+)
+
+var (
+	canILong = templates.LongDesc(`
+		Check that the current identity has the permissions needed to compare the cluster to a reference.
+
+		The can-i command asks the API server, with self subject access reviews, whether the current identity can list
+		and get the resources of every kind in the reference in all the namespaces, and reports the exact verbs and
+		resources that are missing instead of failing in the middle of a comparison with forbidden errors. Kinds that
+		the cluster doesn't serve are reported separately, they're skipped by the comparison.
+
+		Exit status: 0 All the permissions are granted. 1 Permissions are missing. >1 The check couldn't run.
+	`)
+
+	canIExample = templates.Examples(`
+		# Check the permissions needed to compare the cluster to a reference:
+		kubectl cluster-compare can-i -r ./reference/metadata.yaml
+
+		# Print the checked permissions as json:
+		kubectl cluster-compare can-i -r ./reference/metadata.yaml -o json
+	`)
+)
+
+const MissingPermissionsMsg = "the current identity is missing permissions required by the reference"
+
+// canIVerbs are the verbs checked for every kind of the reference: resources are listed in live mode and fetched by
+// name by lookups
+var canIVerbs = []string{"list", "get"}
+
+// Permission is a verb on a resource checked by the can-i command, in all the namespaces
+type Permission struct {
+	Verb     string `json:"verb"`
+	Group    string `json:"group,omitempty"`
+	Resource string `json:"resource"`
+	// Kinds are the kinds of the reference served by the resource
+	Kinds   []string `json:"kinds"`
+	Allowed bool     `json:"allowed"`
+	Reason  string   `json:"reason,omitempty"`
+}
+
+func (p Permission) String() string {
+	resource := p.Resource
+	if p.Group != "" {
+		resource += "." + p.Group
+	}
+	s := fmt.Sprintf("%s %s (%s)", p.Verb, resource, strings.Join(p.Kinds, ", "))
+	if p.Reason != "" {
+		s += ": " + p.Reason
+	}
+	return s
+}
+
+// CanIResult is the output of the can-i command in json and yaml formats
+type CanIResult struct {
+	Permissions   []Permission `json:"permissions"`
+	NumMissing    int          `json:"numMissing"`
+	UnservedKinds []string     `json:"unservedKinds,omitempty"`
+}
+
+// accessReviewer tells whether the current identity is allowed the verb on the resource and the reason given by the
+// authorizer
+type accessReviewer func(ctx context.Context, attributes authorizationv1.ResourceAttributes) (bool, string, error)
+
+type CanIOptions struct {
+	referenceConfig string
+	OutputFormat    string
+
+	mapper meta.RESTMapper
+	review accessReviewer
+	genericiooptions.IOStreams
+}
+
+func NewCanICmd(f kcmdutil.Factory, streams genericiooptions.IOStreams) *cobra.Command {
+	options := &CanIOptions{IOStreams: streams}
+
+	cmd := &cobra.Command{
+		Use:                   "can-i -r <Reference File>",
+		DisableFlagsInUseLine: true,
+		Short:                 i18n.T("Check the permissions needed to compare the cluster to a reference."),
+		Long:                  canILong,
+		Example:               exampleForBinary(canIExample),
+		Run: func(cmd *cobra.Command, args []string) {
+			kcmdutil.CheckDiffErr(options.Complete(f, cmd, args))
+			if err := options.Run(cmd.Context()); err != nil {
+				if exitErr := diffError(err); exitErr != nil {
+					kcmdutil.CheckErr(kcmdutil.ErrExit)
+				}
+				kcmdutil.CheckDiffErr(err)
+			}
+		},
+	}
+	cmd.SetFlagErrorFunc(func(command *cobra.Command, err error) error {
+		kcmdutil.CheckDiffErr(kcmdutil.UsageErrorf(cmd, err.Error()))
+		return nil
+	})
+	cmd.Flags().StringVarP(&options.referenceConfig, "reference", "r", "", "Path to reference config file.")
+	cmd.Flags().StringVarP(&options.OutputFormat, "output", "o", "", fmt.Sprintf(`Output format. One of: (%s, %s)`, Json, Yaml))
+	return cmd
+}
+
+func (o *CanIOptions) Complete(f kcmdutil.Factory, cmd *cobra.Command, args []string) error {
+	if len(args) != 0 {
+		return kcmdutil.UsageErrorf(cmd, "Unexpected args: %v", args)
+	}
+	if o.referenceConfig == "" {
+		return kcmdutil.UsageErrorf(cmd, noRefFileWasPassed)
+	}
+	if _, err := os.Stat(o.referenceConfig); os.IsNotExist(err) && !isURL(o.referenceConfig) {
+		return errors.New(refFileNotExistsError)
+	}
+	if o.OutputFormat != "" && o.OutputFormat != Json && o.OutputFormat != Yaml {
+		return kcmdutil.UsageErrorf(cmd, "Invalid output format %q, must be one of: %s, %s", o.OutputFormat, Json, Yaml)
+	}
+	mapper, err := f.ToRESTMapper()
+	if err != nil {
+		return fmt.Errorf("failed to create REST mapper: %w", err)
+	}
+	o.mapper = mapper
+	client, err := f.KubernetesClientSet()
+	if err != nil {
+		return fmt.Errorf("failed to create kubernetes client: %w", err)
+	}
+	reviews := client.AuthorizationV1().SelfSubjectAccessReviews()
+	o.review = func(ctx context.Context, attributes authorizationv1.ResourceAttributes) (bool, string, error) {
+		review, err := reviews.Create(ctx, &authorizationv1.SelfSubjectAccessReview{
+			Spec: authorizationv1.SelfSubjectAccessReviewSpec{ResourceAttributes: &attributes},
+		}, metav1.CreateOptions{})
+		if err != nil {
+			return false, "", fmt.Errorf("failed to review access to %s %s: %w", attributes.Verb, attributes.Resource, err)
+		}
+		return review.Status.Allowed, review.Status.Reason, nil
+	}
+	return nil
+}
+
+// Run checks the permissions required by the reference and prints them, in case permissions are missing an exit
+// error with code 1 is returned.
+func (o *CanIOptions) Run(ctx context.Context) error {
+	cfs, err := GetRefFS(o.referenceConfig)
+	if err != nil {
+		return err
+	}
+	fsys := newSopsFS(cfs)
+	ref, err := GetReference(fsys, ReferenceFileName(o.referenceConfig))
+	if err != nil {
+		return err
+	}
+	temps, err := ParseTemplates(ref, fsys)
+	if err != nil {
+		return err
+	}
+	result, err := checkPermissions(ctx, requiredKinds(ref, temps), o.mapper, o.review)
+	if err != nil {
+		return err
+	}
+	if err := o.print(result); err != nil {
+		return fmt.Errorf("error occurred when writing output: %w", err)
+	}
+	if result.NumMissing > 0 {
+		return exec.CodeExitError{Err: errors.New(MissingPermissionsMsg), Code: 1}
+	}
+	return nil
+}
+
+// requiredKinds returns the kinds that are fetched from the cluster when comparing it to the reference: the kinds of
+// the templates, and the ClusterServiceVersions when the reference declares operator versions
+func requiredKinds(ref Reference, temps []ReferenceTemplate) []schema.GroupKind {
+	kinds := make(map[schema.GroupKind]bool)
+	for _, temp := range temps {
+		kinds[temp.GetMetadata().GroupVersionKind().GroupKind()] = true
+	}
+	if len(ref.GetOperatorVersions()) > 0 {
+		kinds[schema.GroupKind{Group: csvGroup, Kind: csvKind}] = true
+	}
+	result := make([]schema.GroupKind, 0, len(kinds))
+	for gk := range kinds {
+		result = append(result, gk)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].String() < result[j].String() })
+	return result
+}
+
+// checkPermissions reviews the verbs needed on the resources of the kinds, in all the namespaces. Kinds served by the
+// same resource are only reviewed once.
+func checkPermissions(ctx context.Context, kinds []schema.GroupKind, mapper meta.RESTMapper, review accessReviewer) (CanIResult, error) {
+	result := CanIResult{Permissions: []Permission{}}
+	var resources []schema.GroupResource
+	kindsOf := make(map[schema.GroupResource][]string)
+	for _, gk := range kinds {
+		mapping, err := mapper.RESTMapping(gk)
+		if meta.IsNoMatchError(err) {
+			result.UnservedKinds = append(result.UnservedKinds, gk.String())
+			continue
+		}
+		if err != nil {
+			return result, fmt.Errorf("failed to find the resource of %s: %w", gk, err)
+		}
+		gr := mapping.Resource.GroupResource()
+		if _, ok := kindsOf[gr]; !ok {
+			resources = append(resources, gr)
+		}
+		kindsOf[gr] = append(kindsOf[gr], gk.Kind)
+	}
+	for _, gr := range resources {
+		for _, verb := range canIVerbs {
+			allowed, reason, err := review(ctx, authorizationv1.ResourceAttributes{Verb: verb, Group: gr.Group, Resource: gr.Resource})
+			if err != nil {
+				return result, err
+			}
+			if !allowed {
+				result.NumMissing++
+			}
+			result.Permissions = append(result.Permissions, Permission{
+				Verb: verb, Group: gr.Group, Resource: gr.Resource, Kinds: kindsOf[gr], Allowed: allowed, Reason: reason,
+			})
+		}
+	}
+	return result, nil
+}
+
+func (o *CanIOptions) print(result CanIResult) error {
+	var content []byte
+	var err error
+	switch o.OutputFormat {
+	case Json:
+		content, err = json.Marshal(result)
+		content = append(content, '\n')
+	case Yaml:
+		content, err = yaml.Marshal(result)
+	default:
+		var sb strings.Builder
+		if result.NumMissing > 0 {
+			fmt.Fprintf(&sb, "Missing permissions in all the namespaces: %d\n", result.NumMissing)
+			for _, p := range result.Permissions {
+				if !p.Allowed {
+					sb.WriteString("- " + p.String() + "\n")
+				}
+			}
+		} else {
+			fmt.Fprintf(&sb, "All the %d permissions required by the reference are granted\n", len(result.Permissions))
+		}
+		if len(result.UnservedKinds) > 0 {
+			fmt.Fprintf(&sb, "Kinds not served by the cluster, they won't be compared: %s\n", strings.Join(result.UnservedKinds, ", "))
+		}
+		content = []byte(sb.String())
+	}
+	if err != nil {
+		return err // nolint:wrapcheck
+	}
+	_, err = o.Out.Write(content)
+	return err // nolint:wrapcheck
+}
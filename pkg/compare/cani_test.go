@@ -0,0 +1,81 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"bytes"
+	"context"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+	"k8s.io/utils/exec"
+)
+
+func TestCanI(t *testing.T) {
+	mapper := meta.NewDefaultRESTMapper([]schema.GroupVersion{{Version: "v1"}, {Group: "apps", Version: "v1"}})
+	mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "Namespace"}, meta.RESTScopeRoot)
+	mapper.Add(schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}, meta.RESTScopeNamespace)
+	var reviewed []authorizationv1.ResourceAttributes
+	review := func(ctx context.Context, attributes authorizationv1.ResourceAttributes) (bool, string, error) {
+		reviewed = append(reviewed, attributes)
+		if attributes.Resource == "namespaces" && attributes.Verb == "list" {
+			return false, "no RBAC policy matched", nil
+		}
+		return true, "", nil
+	}
+
+	tests := []struct {
+		name      string
+		reference string
+		expected  string
+		reviewed  int
+		missing   bool
+	}{
+		{
+			name:      "granted",
+			reference: path.Join(TestDirs, "SomeDiffs", TestRefDirName, defaultReferenceFilename),
+			expected:  "All the 2 permissions required by the reference are granted\n",
+			reviewed:  2,
+		},
+		{
+			name:      "missing and unserved",
+			reference: path.Join(TestDirs, "OperatorVersionDrift", TestRefDirName, defaultReferenceFilename),
+			expected: `Missing permissions in all the namespaces: 1
+- list namespaces (Namespace): no RBAC policy matched
+Kinds not served by the cluster, they won't be compared: ClusterServiceVersion.operators.coreos.com
+`,
+			reviewed: 2,
+			missing:  true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reviewed = nil
+			out := &bytes.Buffer{}
+			o := &CanIOptions{
+				referenceConfig: test.reference,
+				mapper:          mapper,
+				review:          review,
+				IOStreams:       genericiooptions.IOStreams{Out: out},
+			}
+			err := o.Run(context.Background())
+			if test.missing {
+				var exitErr exec.CodeExitError
+				require.ErrorAs(t, err, &exitErr)
+				require.Equal(t, 1, exitErr.Code)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, test.expected, out.String())
+			require.Len(t, reviewed, test.reviewed)
+			for _, attributes := range reviewed {
+				require.Empty(t, attributes.Namespace, "permissions are checked in all the namespaces")
+			}
+		})
+	}
+}
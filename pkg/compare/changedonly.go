@@ -0,0 +1,279 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/klog/v2"
+)
+
+// runCacheVersion is part of the key of the run cache, it has to be changed whenever the cached format or the way CRs
+// are diffed changes so that results recorded by older versions of the tool won't be reused.
+const runCacheVersion = "v2"
+
+// runCacheEntry is the result of comparing a cluster CR, recorded along with the version of the CR it was computed for
+type runCacheEntry struct {
+	ResourceVersion string  `json:"resourceVersion"`
+	Generation      int64   `json:"generation,omitempty"`
+	Template        string  `json:"template"`
+	HasDiff         bool    `json:"hasDiff"`
+	Diff            DiffSum `json:"diff"`
+}
+
+type runCacheFile struct {
+	Key     string                   `json:"key"`
+	Entries map[string]runCacheEntry `json:"entries"`
+}
+
+// runCache records the results of the CRs compared in a run (with --run-cache) so that the next runs with
+// --changed-only can reuse the results of the CRs whose resourceVersion and generation didn't change instead of
+// rendering and diffing them again. The results of the templates looking up other cluster objects are never reused,
+// as the objects may have changed while the CR didn't. A nil cache doesn't record or reuse anything.
+type runCache struct {
+	path        string
+	key         string
+	changedOnly bool
+	previous    map[string]runCacheEntry
+	templates   map[string]ReferenceTemplate
+	// lookups are the templates calling lookupCR when the lookups are enabled
+	lookups map[string]bool
+	// recorded are the results recorded by the previous run with recordedKey, they're only reused once the key of this
+	// run is known
+	recordedKey string
+	recorded    map[string]runCacheEntry
+
+	// lock guards current and reused, the CRs are visited concurrently
+	lock    sync.Mutex
+	current map[string]runCacheEntry
+	reused  int
+}
+
+// loadRunCache reads the results recorded by a previous run, the results are ignored when the file doesn't exist or
+// when they were recorded with a different reference or options (a different key, see useFacts)
+func loadRunCache(path, key string, changedOnly, lookups bool, templates []ReferenceTemplate) (*runCache, error) {
+	c := &runCache{
+		path:        path,
+		key:         key,
+		changedOnly: changedOnly,
+		previous:    make(map[string]runCacheEntry),
+		templates:   make(map[string]ReferenceTemplate),
+		lookups:     make(map[string]bool),
+		current:     make(map[string]runCacheEntry),
+	}
+	var usingLookups []string
+	if lookups {
+		usingLookups = templatesUsingLookups(templates)
+	}
+	for _, temp := range templates {
+		c.templates[temp.GetIdentifier()] = temp
+		if slices.Contains(usingLookups, temp.GetPath()) {
+			c.lookups[temp.GetIdentifier()] = true
+		}
+	}
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read run cache: %w", err)
+	}
+	var file runCacheFile
+	if err := json.Unmarshal(content, &file); err != nil {
+		return nil, fmt.Errorf("run cache %s isn't in correct format: %w", path, err)
+	}
+	c.recordedKey, c.recorded = file.Key, file.Entries
+	return c, nil
+}
+
+// useFacts completes the key of the run with the facts of the cluster the templates were rendered with, nil when the
+// templates don't depend on them: the output of templates using .ClusterFacts and the templates whose conditions hold
+// change with the cluster (e.g. after an upgrade) while the CRs don't. The recorded results are then reused if they
+// were recorded with the same key.
+func (c *runCache) useFacts(facts *ClusterFacts) error {
+	if c == nil {
+		return nil
+	}
+	if facts != nil {
+		content, err := json.Marshal(struct {
+			Key   string
+			Facts *ClusterFacts
+		}{c.key, facts})
+		if err != nil {
+			return fmt.Errorf("failed to compute the key of the run cache: %w", err)
+		}
+		c.key = fmt.Sprintf("%x", sha256.Sum256(content))
+	}
+	if c.recordedKey == "" {
+		return nil
+	}
+	if c.recordedKey != c.key {
+		if c.changedOnly {
+			klog.Warningf("Run cache %s was recorded with a different reference, options or cluster facts, all the CRs will be compared", c.path)
+		}
+		return nil
+	}
+	if c.recorded != nil {
+		c.previous = c.recorded
+	}
+	return nil
+}
+
+// crVersion identifies the version of a cluster CR, it's taken before the CR is compared since the fields populated
+// by the API server are removed from it while diffing
+type crVersion struct {
+	name            string
+	resourceVersion string
+	generation      int64
+}
+
+func versionOf(cr *unstructured.Unstructured) crVersion {
+	return crVersion{name: apiKindNamespaceName(cr), resourceVersion: cr.GetResourceVersion(), generation: cr.GetGeneration()}
+}
+
+// runCacheKey identifies the reference and the options that affect the result of comparing a CR, results recorded
+// with another key can't be reused
+func runCacheKey(o *Options) (string, error) {
+	content, err := json.Marshal(struct {
+		Version           string
+		MetadataHash      string
+		UserConfig        UserConfig
+		UserOverrides     []*UserOverride
+		ReferencePatches  []*referencePatch
+		DiffEngine        string
+		DiffStyle         string
+		DiffContext       int
+		NoDiffHeader      bool
+		ShowManagedFields bool
+		IncludeKinds      []string
+		ExcludeKinds      []string
+		Components        []string
+		SkipComponents    []string
+		FieldManagers     []string
+		IgnoreManagers    []string
+		OnlyPaths         []string
+		IgnorePaths       []string
+		ServerSideDryRun  bool
+		IgnoreAPIDefaults bool
+		EnableLookups     bool
+		ValidateSchemas   bool
+		Schemas           string
+	}{
+		Version:           runCacheVersion,
+		MetadataHash:      o.metadataHash,
+		UserConfig:        o.userConfig,
+		UserOverrides:     o.userOverrides,
+		ReferencePatches:  o.referencePatches,
+		DiffEngine:        o.diffEngine,
+		DiffStyle:         o.diffStyle,
+		DiffContext:       o.diffContext,
+		NoDiffHeader:      o.noDiffHeader,
+		ShowManagedFields: o.ShowManagedFields,
+		IncludeKinds:      o.kinds.include,
+		ExcludeKinds:      o.kinds.exclude,
+		Components:        o.components.include,
+		SkipComponents:    o.components.exclude,
+		FieldManagers:     o.fieldOwners.only,
+		IgnoreManagers:    o.fieldOwners.ignore,
+		OnlyPaths:         o.paths.only,
+		IgnorePaths:       o.paths.ignore,
+		ServerSideDryRun:  o.serverSideDryRun,
+		IgnoreAPIDefaults: o.ignoreAPIDefaults,
+		EnableLookups:     o.enableLookups,
+		ValidateSchemas:   o.validateSchemas,
+		Schemas:           o.schemasPath,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to compute the key of the run cache: %w", err)
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(content)), nil
+}
+
+// unchanged returns the recorded result of a cluster CR and the template it was correlated to if --changed-only is
+// set and the CR didn't change since it was recorded. CRs without a resourceVersion (e.g. local files that were never
+// applied) are always compared.
+func (c *runCache) unchanged(v crVersion) (runCacheEntry, ReferenceTemplate, bool) {
+	if c == nil || !c.changedOnly || v.resourceVersion == "" {
+		return runCacheEntry{}, nil, false
+	}
+	entry, ok := c.previous[v.name]
+	if !ok || entry.ResourceVersion != v.resourceVersion || entry.Generation != v.generation {
+		return runCacheEntry{}, nil, false
+	}
+	temp, ok := c.templates[entry.Template]
+	if !ok || c.lookups[entry.Template] {
+		return runCacheEntry{}, nil, false
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.current[v.name] = entry
+	c.reused++
+	return entry, temp, true
+}
+
+// record records the result of comparing a cluster CR
+func (c *runCache) record(v crVersion, temp ReferenceTemplate, hasDiff bool, diff DiffSum) {
+	if c == nil || v.resourceVersion == "" {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.current[v.name] = runCacheEntry{
+		ResourceVersion: v.resourceVersion,
+		Generation:      v.generation,
+		Template:        temp.GetIdentifier(),
+		HasDiff:         hasDiff,
+		Diff:            diff,
+	}
+}
+
+// numReused returns the number of CRs whose recorded result was reused
+func (c *runCache) numReused() int {
+	if c == nil {
+		return 0
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.reused
+}
+
+// write replaces the cache file with the results of the CRs compared in this run, CRs that weren't seen in this run
+// are dropped. The file is replaced atomically so an interrupted write won't leave a truncated cache behind.
+func (c *runCache) write() error {
+	if c == nil {
+		return nil
+	}
+	c.lock.Lock()
+	content, err := json.Marshal(runCacheFile{Key: c.key, Entries: c.current})
+	c.lock.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to marshal run cache: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o755); err != nil { // nolint:gosec
+		return fmt.Errorf("failed to create run cache directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), filepath.Base(c.path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write run cache: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write run cache: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write run cache: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), c.path); err != nil {
+		return fmt.Errorf("failed to write run cache: %w", err)
+	}
+	return nil
+}
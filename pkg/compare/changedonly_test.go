@@ -0,0 +1,131 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"context"
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	cmdtesting "k8s.io/kubectl/pkg/cmd/testing"
+	"sigs.k8s.io/yaml"
+)
+
+// writeVersionedCRs copies the CRs of the SomeDiffs test to the directory with the given resourceVersions and replicas
+func writeVersionedCRs(t *testing.T, dir string, resourceVersions map[string]string, replicas int64) {
+	resourcesDir := path.Join(TestDirs, "SomeDiffs", ResourceDirName)
+	entries, err := os.ReadDir(resourcesDir)
+	require.NoError(t, err)
+	for _, entry := range entries {
+		content, err := os.ReadFile(path.Join(resourcesDir, entry.Name()))
+		require.NoError(t, err)
+		obj := &unstructured.Unstructured{}
+		require.NoError(t, yaml.Unmarshal(content, &obj.Object))
+		obj.SetResourceVersion(resourceVersions[entry.Name()])
+		require.NoError(t, unstructured.SetNestedField(obj.Object, replicas, "spec", "replicas"))
+		content, err = yaml.Marshal(obj.Object)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filepath.Join(dir, entry.Name()), content, 0o600))
+	}
+}
+
+func diffOutputs(diffs []DiffSum) map[string]string {
+	outputs := make(map[string]string)
+	for _, d := range diffs {
+		outputs[d.CRName] = d.DiffOutput
+	}
+	return outputs
+}
+
+func TestChangedOnly(t *testing.T) {
+	tf := cmdtesting.NewTestFactory()
+	defer tf.Cleanup()
+	crsDir := t.TempDir()
+	req := CompareRequest{
+		Reference:   path.Join(TestDirs, "SomeDiffs", TestRefDirName, defaultReferenceFilename),
+		Factory:     tf,
+		Filenames:   []string{crsDir},
+		DiffEngine:  DiffEngineInternal,
+		RunCache:    filepath.Join(t.TempDir(), "cache", "run.json"),
+		ChangedOnly: true,
+	}
+	versions := map[string]string{"d2.yaml": "1", "deploymentDashboard.yaml": "1"}
+
+	writeVersionedCRs(t, crsDir, versions, 1)
+	first, err := Compare(context.Background(), req)
+	require.NoError(t, err)
+	require.Equal(t, 0, first.Summary.UnchangedCRs, "nothing is recorded before the first run")
+	require.FileExists(t, req.RunCache)
+
+	// The CRs are changed without changing their resourceVersion, their recorded results are expected to be reused
+	writeVersionedCRs(t, crsDir, versions, 5)
+	second, err := Compare(context.Background(), req)
+	require.NoError(t, err)
+	require.Equal(t, 2, second.Summary.UnchangedCRs)
+	require.Equal(t, first.Summary.NumDiffCRs, second.Summary.NumDiffCRs)
+	require.Equal(t, first.Summary.TemplateStats, second.Summary.TemplateStats)
+	require.Equal(t, diffOutputs(first.Diffs), diffOutputs(second.Diffs))
+	require.Empty(t, second.Summary.ValidationIssues, "reused CRs are still matched to their templates")
+
+	versions["d2.yaml"] = "2"
+	writeVersionedCRs(t, crsDir, versions, 5)
+	third, err := Compare(context.Background(), req)
+	require.NoError(t, err)
+	require.Equal(t, 1, third.Summary.UnchangedCRs, "CRs with a new resourceVersion are compared again")
+	require.Contains(t, diffOutputs(third.Diffs)["apps/v1_Deployment_kubernetes-dashboard_dashboard-metrics-scraper"], "replicas")
+
+	// Results recorded with other options aren't reused
+	req.IgnorePaths = []string{".spec.replicas"}
+	fourth, err := Compare(context.Background(), req)
+	require.NoError(t, err)
+	require.Equal(t, 0, fourth.Summary.UnchangedCRs)
+
+	key, err := runCacheKey(&Options{})
+	require.NoError(t, err)
+	withoutDefaults, err := runCacheKey(&Options{ignoreAPIDefaults: true})
+	require.NoError(t, err)
+	require.NotEqual(t, key, withoutDefaults, "--ignore-api-defaults changes the diffs")
+
+	_, err = Compare(context.Background(), CompareRequest{Reference: req.Reference, Factory: tf, Filenames: req.Filenames, ChangedOnly: true})
+	require.EqualError(t, err, "--changed-only requires --run-cache")
+}
+
+func TestRunCacheReuse(t *testing.T) {
+	refDir := path.Join(TestDirs, "LookupCR", TestRefDirName)
+	ref, err := GetReference(os.DirFS(refDir), defaultReferenceFilename)
+	require.NoError(t, err)
+	templates, err := ParseTemplates(ref, os.DirFS(refDir))
+	require.NoError(t, err)
+	cachePath := filepath.Join(t.TempDir(), "run.json")
+	v := crVersion{name: "v1_ConfigMap_example_app", resourceVersion: "1"}
+
+	record := func(t *testing.T, facts *ClusterFacts) {
+		c, err := loadRunCache(cachePath, "key", false, false, templates)
+		require.NoError(t, err)
+		require.NoError(t, c.useFacts(facts))
+		c.record(v, templates[0], false, DiffSum{})
+		require.NoError(t, c.write())
+	}
+	reused := func(t *testing.T, lookups bool, facts *ClusterFacts) bool {
+		c, err := loadRunCache(cachePath, "key", true, lookups, templates)
+		require.NoError(t, err)
+		require.NoError(t, c.useFacts(facts))
+		_, _, ok := c.unchanged(v)
+		return ok
+	}
+
+	record(t, nil)
+	require.True(t, reused(t, false, nil))
+	require.False(t, reused(t, true, nil), "the objects looked up by the template may have changed")
+
+	facts := &ClusterFacts{KubernetesVersion: "v1.30.2", CRDs: map[string]bool{"widgets.example.com": true}}
+	require.False(t, reused(t, false, facts), "results recorded without the facts of the cluster shouldn't be reused")
+	record(t, facts)
+	require.True(t, reused(t, false, &ClusterFacts{KubernetesVersion: "v1.30.2", CRDs: map[string]bool{"widgets.example.com": true}}))
+	require.False(t, reused(t, false, &ClusterFacts{KubernetesVersion: "v1.31.0", CRDs: map[string]bool{"widgets.example.com": true}}),
+		"results recorded before an upgrade shouldn't be reused")
+}
@@ -0,0 +1,257 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"sync"
+	"text/template/parse"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/samber/lo"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/cli-runtime/pkg/resource"
+	kcmdutil "k8s.io/kubectl/pkg/cmd/util"
+)
+
+// clusterFactsKey is the key of the cluster facts in the data templates are rendered with, next to the fields of the
+// cluster CR
+const clusterFactsKey = "ClusterFacts"
+
+var (
+	clusterVersionGVR = schema.GroupVersionResource{Group: "config.openshift.io", Version: "v1", Resource: "clusterversions"}
+	infrastructureGVR = schema.GroupVersionResource{Group: "config.openshift.io", Version: "v1", Resource: "infrastructures"}
+	crdGVR            = schema.GroupVersionResource{Group: "apiextensions.k8s.io", Version: "v1", Resource: "customresourcedefinitions"}
+	nodeGVR           = schema.GroupVersionResource{Version: "v1", Resource: "nodes"}
+)
+
+const (
+	clusterVersionName = "version"
+	infrastructureName = "cluster"
+)
+
+// ClusterFacts are the facts discovered about the compared cluster. Template conditions are evaluated against them and
+// templates can branch on them with .ClusterFacts.
+type ClusterFacts struct {
+	// KubernetesVersion is the version of the API server, the lowest kubelet version of the nodes when comparing local
+	// files
+	KubernetesVersion string `json:"KubernetesVersion,omitempty"`
+	// OpenShiftVersion is the desired version of the ClusterVersion, empty when the cluster isn't an OpenShift cluster
+	OpenShiftVersion string `json:"OpenShiftVersion,omitempty"`
+	// Platform is the platform type of the Infrastructure, empty when it's unknown
+	Platform string `json:"Platform,omitempty"`
+	// NodeCount is the number of nodes of the cluster
+	NodeCount int `json:"NodeCount"`
+	// CRDs are the names of the installed CustomResourceDefinitions
+	CRDs map[string]bool `json:"CRDs,omitempty"`
+}
+
+func newClusterFacts() *ClusterFacts {
+	return &ClusterFacts{CRDs: make(map[string]bool)}
+}
+
+// add records the facts held by the object: the installed CRDs, the nodes, the version of OpenShift in the
+// ClusterVersion and the platform in the Infrastructure. Other objects are ignored.
+func (f *ClusterFacts) add(obj *unstructured.Unstructured) {
+	gk := obj.GroupVersionKind().GroupKind()
+	switch {
+	case gk.Group == crdGVR.Group && gk.Kind == "CustomResourceDefinition":
+		f.CRDs[obj.GetName()] = true
+	case gk.Group == "" && gk.Kind == "Node":
+		f.NodeCount++
+		kubelet, _, _ := unstructured.NestedString(obj.Object, "status", "nodeInfo", "kubeletVersion")
+		if v, err := semver.NewVersion(kubelet); err == nil && f.kubeletIsOlder(v) {
+			f.KubernetesVersion = kubelet
+		}
+	case gk.Group == clusterVersionGVR.Group && gk.Kind == "ClusterVersion" && obj.GetName() == clusterVersionName:
+		f.OpenShiftVersion, _, _ = unstructured.NestedString(obj.Object, "status", "desired", "version")
+	case gk.Group == infrastructureGVR.Group && gk.Kind == "Infrastructure" && obj.GetName() == infrastructureName:
+		platform, _, _ := unstructured.NestedString(obj.Object, "status", "platformStatus", "type")
+		if platform == "" {
+			platform, _, _ = unstructured.NestedString(obj.Object, "status", "platform")
+		}
+		f.Platform = platform
+	}
+}
+
+// kubeletIsOlder checks if the kubelet version is older than the Kubernetes version recorded so far
+func (f *ClusterFacts) kubeletIsOlder(kubelet *semver.Version) bool {
+	current, err := semver.NewVersion(f.KubernetesVersion)
+	return err != nil || kubelet.LessThan(current)
+}
+
+// templateValue returns the facts as passed to the templates. The value only holds JSON types so the data templates
+// are rendered with can be deep copied, the CRDs are a sorted list of names.
+func (f *ClusterFacts) templateValue() map[string]any {
+	names := lo.Keys(f.CRDs)
+	sort.Strings(names)
+	crds := make([]any, 0, len(names))
+	for _, name := range names {
+		crds = append(crds, name)
+	}
+	return map[string]any{
+		"KubernetesVersion": f.KubernetesVersion,
+		"OpenShiftVersion":  f.OpenShiftVersion,
+		"Platform":          f.Platform,
+		"NodeCount":         int64(f.NodeCount),
+		"CRDs":              crds,
+	}
+}
+
+// withClusterFacts returns the data to render a template with for the cluster CR: the fields of the CR and the
+// cluster facts, if they were discovered. The CR itself isn't modified.
+func withClusterFacts(clusterCR map[string]any, facts *ClusterFacts) map[string]any {
+	if facts == nil {
+		return clusterCR
+	}
+	params := make(map[string]any, len(clusterCR)+1)
+	for k, v := range clusterCR {
+		params[k] = v
+	}
+	params[clusterFactsKey] = facts.templateValue()
+	return params
+}
+
+// templatesUsingClusterFacts returns the paths of the templates referring to .ClusterFacts
+func templatesUsingClusterFacts(temps []ReferenceTemplate) []string {
+	var paths []string
+	for _, temp := range temps {
+		tree := temp.GetTemplateTree()
+		if tree == nil {
+			continue
+		}
+		uses := false
+		walkTemplateNodes(tree.Root, func(n parse.Node) {
+			switch n := n.(type) {
+			case *parse.FieldNode:
+				uses = uses || slices.Contains(n.Ident, clusterFactsKey)
+			case *parse.VariableNode:
+				uses = uses || slices.Contains(n.Ident, clusterFactsKey)
+			}
+		})
+		if uses {
+			paths = append(paths, temp.GetPath())
+		}
+	}
+	return paths
+}
+
+// liveClusterFacts discovers the facts of the cluster: the version of the API server, the nodes, the CRDs, the
+// ClusterVersion and the Infrastructure. Kinds the cluster doesn't serve and objects that don't exist are left out.
+func liveClusterFacts(ctx context.Context, f kcmdutil.Factory) (*ClusterFacts, error) {
+	facts := newClusterFacts()
+	discovery, err := f.ToDiscoveryClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create discovery client: %w", err)
+	}
+	version, err := discovery.ServerVersion()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the version of the cluster: %w", err)
+	}
+	client, err := f.DynamicClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dynamic client: %w", err)
+	}
+	ignored := func(err error) bool { return apierrors.IsNotFound(err) || meta.IsNoMatchError(err) }
+	for _, gvr := range []schema.GroupVersionResource{nodeGVR, crdGVR} {
+		list, err := client.Resource(gvr).List(ctx, metav1.ListOptions{})
+		if ignored(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s for the cluster facts: %w", gvr.GroupResource(), err)
+		}
+		for i := range list.Items {
+			facts.add(&list.Items[i])
+		}
+	}
+	for gvr, name := range map[schema.GroupVersionResource]string{clusterVersionGVR: clusterVersionName, infrastructureGVR: infrastructureName} {
+		obj, err := client.Resource(gvr).Get(ctx, name, metav1.GetOptions{})
+		if ignored(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get %s %s for the cluster facts: %w", gvr.GroupResource(), name, err)
+		}
+		facts.add(obj)
+	}
+	// The version of the API server is more accurate than the versions of the kubelets
+	facts.KubernetesVersion = version.GitVersion
+	return facts, nil
+}
+
+// localClusterFacts gathers the facts from the local CRs, e.g. the nodes, ClusterVersion, Infrastructure and CRDs of a
+// must-gather
+func (o *Options) localClusterFacts() (*ClusterFacts, error) {
+	r, err := o.newResult(o.types, "", "")
+	if err != nil {
+		return nil, err
+	}
+	facts := newClusterFacts()
+	var mu sync.Mutex
+	err = r.Visit(func(info *resource.Info, _ error) error { // ignoring previous errors, like when comparing
+		obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(info.Object)
+		if err != nil {
+			return fmt.Errorf("failed to convert %s: %w", info.Name, err)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		facts.add(&unstructured.Unstructured{Object: obj})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to gather the cluster facts from the local CRs: %w", err)
+	}
+	return facts, nil
+}
+
+// needsClusterFacts checks if the templates have conditions or refer to .ClusterFacts
+func needsClusterFacts(templates []ReferenceTemplate) bool {
+	return hasConditions(templates) || len(templatesUsingClusterFacts(templates)) > 0
+}
+
+// bufferStdin reads the CRs passed on stdin so they can be read again: the local CRs are read once for the cluster
+// facts before they're compared
+func (o *Options) bufferStdin() error {
+	if !slices.Contains(o.CRs.Filenames, stdinFilename) {
+		return nil
+	}
+	content, err := io.ReadAll(o.IOStreams.In)
+	if err != nil {
+		return fmt.Errorf("failed to read the CRs from stdin: %w", err)
+	}
+	o.CRs.Filenames = slices.DeleteFunc(o.CRs.Filenames, func(f string) bool { return f == stdinFilename })
+	o.streamedCRs = append(o.streamedCRs, streamedCR{name: "STDIN", content: content}.flattened())
+	return nil
+}
+
+// discoverClusterFacts discovers the facts of the compared cluster when the templates need them, templates whose
+// conditions don't hold for the cluster are then left out of the comparison. Replayed comparisons use the facts of the
+// recording.
+func (o *Options) discoverClusterFacts(ctx context.Context) error {
+	if !needsClusterFacts(o.templates) {
+		return nil
+	}
+	var err error
+	switch {
+	case o.replay != nil && o.replay.ClusterFacts != nil:
+		o.clusterFacts = o.replay.ClusterFacts
+	case o.local:
+		o.clusterFacts, err = o.localClusterFacts()
+	default:
+		o.clusterFacts, err = liveClusterFacts(ctx, o.factory)
+	}
+	if err != nil {
+		return err
+	}
+	return o.applyConditions()
+}
@@ -0,0 +1,194 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"io"
+	"os"
+	"strings"
+	"unicode"
+)
+
+const (
+	ColorAuto   = "auto"
+	ColorAlways = "always"
+	ColorNever  = "never"
+)
+
+var ColorModes = []string{ColorAuto, ColorAlways, ColorNever}
+
+const (
+	ansiReset     = "\x1b[0m"
+	ansiBold      = "\x1b[1m"
+	ansiRed       = "\x1b[31m"
+	ansiGreen     = "\x1b[32m"
+	ansiYellow    = "\x1b[33m"
+	ansiCyan      = "\x1b[36m"
+	ansiHighlight = "\x1b[7m"
+	ansiEscape    = "\x1b["
+)
+
+// useColor checks if the diffs written to out should be colored in the requested mode. In auto mode they are colored
+// only when out is a terminal and NO_COLOR isn't set.
+func useColor(mode string, out io.Writer) bool {
+	switch mode {
+	case ColorAlways:
+		return true
+	case ColorNever:
+		return false
+	}
+	_, noColor := os.LookupEnv("NO_COLOR")
+	return !noColor && isTerminal(out)
+}
+
+// colorizeDiff colors the lines of a unified diff or of the output of the internal diff engine: removed lines in red,
+// added lines in green, changed fields in yellow and hunk headers in cyan. When a block of removed lines is directly
+// followed by added lines, the tokens that differ between each pair of lines are highlighted. Lines that are already
+// colored (e.g. by KUBECTL_EXTERNAL_DIFF=colordiff) are left as is.
+func colorizeDiff(diff string) string {
+	if diff == "" {
+		return diff
+	}
+	lines := strings.Split(diff, "\n")
+	colored := make([]string, len(lines))
+	inHeader := true
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if strings.Contains(line, ansiEscape) {
+			colored[i] = line
+			continue
+		}
+		if inHeader && (strings.HasPrefix(line, "diff ") || strings.HasPrefix(line, "--- ") || strings.HasPrefix(line, "+++ ")) {
+			colored[i] = ansiBold + line + ansiReset
+			continue
+		}
+		inHeader = false
+		switch {
+		case strings.HasPrefix(line, "@@"):
+			colored[i] = ansiCyan + line + ansiReset
+		case strings.HasPrefix(line, "~"):
+			colored[i] = ansiYellow + line + ansiReset
+		case strings.HasPrefix(line, "-"):
+			removed := i
+			for i+1 < len(lines) && strings.HasPrefix(lines[i+1], "-") && !strings.Contains(lines[i+1], ansiEscape) {
+				i++
+			}
+			added := i + 1
+			for i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+") && !strings.Contains(lines[i+1], ansiEscape) {
+				i++
+			}
+			colorizeChangedLines(lines[removed:added], lines[added:i+1], colored[removed:i+1])
+		case strings.HasPrefix(line, "+"):
+			colored[i] = ansiGreen + line + ansiReset
+		default:
+			colored[i] = line
+		}
+	}
+	return strings.Join(colored, "\n")
+}
+
+// colorizeChangedLines colors a block of removed lines followed by a block of added lines into colored, the i-th
+// removed line is paired with the i-th added line to highlight the tokens that changed between them
+func colorizeChangedLines(removed, added, colored []string) {
+	for i, line := range removed {
+		colored[i] = ansiRed + line + ansiReset
+	}
+	for i, line := range added {
+		colored[len(removed)+i] = ansiGreen + line + ansiReset
+	}
+	for i := 0; i < min(len(removed), len(added)); i++ {
+		from, to := highlightChangedTokens(removed[i][1:], added[i][1:])
+		colored[i] = ansiRed + "-" + from + ansiReset
+		colored[len(removed)+i] = ansiGreen + "+" + to + ansiReset
+	}
+}
+
+// highlightChangedTokens highlights the tokens of both lines that aren't part of their longest common subsequence of
+// tokens
+func highlightChangedTokens(from, to string) (string, string) {
+	fromTokens, toTokens := tokenizeLine(from), tokenizeLine(to)
+	// lcs[i][j] is the length of the longest common subsequence of fromTokens[i:] and toTokens[j:]
+	lcs := make([][]int, len(fromTokens)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(toTokens)+1)
+	}
+	for i := len(fromTokens) - 1; i >= 0; i-- {
+		for j := len(toTokens) - 1; j >= 0; j-- {
+			if fromTokens[i] == toTokens[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	fromLine, toLine := &highlightWriter{color: ansiRed}, &highlightWriter{color: ansiGreen}
+	i, j := 0, 0
+	for i < len(fromTokens) || j < len(toTokens) {
+		switch {
+		case i < len(fromTokens) && j < len(toTokens) && fromTokens[i] == toTokens[j]:
+			fromLine.write(fromTokens[i], false)
+			toLine.write(toTokens[j], false)
+			i++
+			j++
+		case j == len(toTokens) || (i < len(fromTokens) && lcs[i+1][j] >= lcs[i][j+1]):
+			fromLine.write(fromTokens[i], true)
+			i++
+		default:
+			toLine.write(toTokens[j], true)
+			j++
+		}
+	}
+	return fromLine.String(), toLine.String()
+}
+
+// highlightWriter writes the tokens of a line colored with color, consecutive highlighted tokens are highlighted as a
+// single run
+type highlightWriter struct {
+	sb          strings.Builder
+	color       string
+	highlighted bool
+}
+
+func (w *highlightWriter) write(token string, highlight bool) {
+	if highlight != w.highlighted {
+		if highlight {
+			w.sb.WriteString(ansiHighlight)
+		} else {
+			w.sb.WriteString(ansiReset + w.color)
+		}
+		w.highlighted = highlight
+	}
+	w.sb.WriteString(token)
+}
+
+// String returns the written tokens, the caller resets the attributes at the end of the line
+func (w *highlightWriter) String() string {
+	return w.sb.String()
+}
+
+// tokenizeLine splits the line into runs of letters and digits, runs of spaces and single other characters
+func tokenizeLine(line string) []string {
+	var tokens []string
+	runes := []rune(line)
+	for start := 0; start < len(runes); {
+		end := start + 1
+		switch {
+		case isWordRune(runes[start]):
+			for end < len(runes) && isWordRune(runes[end]) {
+				end++
+			}
+		case unicode.IsSpace(runes[start]):
+			for end < len(runes) && unicode.IsSpace(runes[end]) {
+				end++
+			}
+		}
+		tokens = append(tokens, string(runes[start:end]))
+		start = end
+	}
+	return tokens
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -0,0 +1,34 @@
+package compare
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestColorizeDiff(t *testing.T) {
+	diff := "diff -u -N a b\n--- a\n+++ b\n@@ -1,3 +1,3 @@\n data:\n-  theme: dark\n+  theme: light-blue\n-  removed: x\n \\ No newline at end of file\n"
+	expected := ansiBold + "diff -u -N a b" + ansiReset + "\n" +
+		ansiBold + "--- a" + ansiReset + "\n" +
+		ansiBold + "+++ b" + ansiReset + "\n" +
+		ansiCyan + "@@ -1,3 +1,3 @@" + ansiReset + "\n" +
+		" data:\n" +
+		ansiRed + "-  theme: " + ansiHighlight + "dark" + ansiReset + "\n" +
+		ansiGreen + "+  theme: " + ansiHighlight + "light-blue" + ansiReset + "\n" +
+		ansiRed + "-  removed: x" + ansiReset + "\n" +
+		" \\ No newline at end of file\n"
+	assert.Equal(t, expected, colorizeDiff(diff))
+}
+
+func TestColorizeDiffKeepsColoredLines(t *testing.T) {
+	diff := "\x1b[31m-a\x1b[0m\n\x1b[32m+b\x1b[0m"
+	assert.Equal(t, diff, colorizeDiff(diff))
+}
+
+func TestUseColor(t *testing.T) {
+	out := &bytes.Buffer{}
+	assert.True(t, useColor(ColorAlways, out))
+	assert.False(t, useColor(ColorNever, out))
+	assert.False(t, useColor(ColorAuto, out))
+}
@@ -4,19 +4,26 @@ package compare
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
 	"slices"
 	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	jsonpatch "github.com/evanphx/json-patch"
 	"github.com/gosimple/slug"
+	"github.com/openshift/kube-compare/pkg/api"
+	"github.com/samber/lo"
 	"github.com/spf13/cobra"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -72,6 +79,8 @@ var (
 
 		Note: KUBECTL_EXTERNAL_DIFF, if used, is expected to follow that convention.
 
+		With --diff-engine=internal no external program is run, the changed fields are listed instead.
+
 		Experimental: This command is under active development and may change without notice.
 	`)
 
@@ -87,6 +96,12 @@ var (
 
 		# Run a known valid reference configuration with a must-gather output:
 		kubectl cluster-compare -r ./reference/metadata.yaml -f "must-gather*/*/cluster-scoped-resources","must-gather*/*/namespaces" -R
+
+		# Run a known valid reference configuration with a compressed must-gather, without extracting it:
+		kubectl cluster-compare -r ./reference/metadata.yaml -f must-gather.tar.gz -R
+
+		# Compare a known valid reference configuration with CRs read from stdin:
+		kubectl get configmaps -n my-namespace -o yaml | kubectl cluster-compare -r ./reference/metadata.yaml -f -
 	`)
 )
 
@@ -101,6 +116,7 @@ const (
 	DiffsFoundMsg           = "there are differences between the cluster CRs and the reference CRs"
 	noTemplateForGeneration = "Requested user override generation but no entires for which template to generate overrides for"
 	noReason                = "Reason required when generating overrides"
+	stdinFilename           = "-"
 )
 
 const (
@@ -109,26 +125,134 @@ const (
 	PatchYaml string = "generate-patches"
 )
 
-var OutputFormats = []string{Json, Yaml, PatchYaml}
+var OutputFormats = []string{Json, Yaml, Jsonl, PatchYaml}
 
 type Options struct {
 	CRs                resource.FilenameOptions
 	referenceConfig    string
+	referenceConfigs   []string
+	references         []*Options
 	diffConfigFileName string
 	diffAll            bool
 	verboseOutput      bool
+	showMatchedOnly    bool
+	explain            bool
+	stream             bool
+	dryRun             bool
 	ShowManagedFields  bool
 	OutputFormat       string
-
-	builder        *resource.Builder
-	correlator     *MultiCorrelator[ReferenceTemplate]
-	metricsTracker *MetricsTracker
-	templates      []ReferenceTemplate
-	local          bool
-	types          []string
-	ref            Reference
-	userConfig     UserConfig
-	Concurrency    int
+	outputFile         string
+	Progress           string
+	diffEngine         string
+	// diffProgramMissing is set when the external diff program isn't installed, the diffs are then computed internally
+	diffProgramMissing bool
+	diffStyle          string
+	diffContext        int
+	noDiffHeader       bool
+	groupBy            string
+	color              string
+
+	newBuilder func() *resource.Builder
+	// factory of the compared cluster, the cluster facts are discovered with it
+	factory             kcmdutil.Factory
+	correlator          *MultiCorrelator[ReferenceTemplate]
+	correlatorFactories []CorrelatorFactory
+	metricsTracker      *MetricsTracker
+	templates           []ReferenceTemplate
+	local               bool
+	types               []string
+	ref                 Reference
+	metadataHash        string
+	differenceWeights   differenceWeights
+	fieldSeverities     fieldSeverities
+	userConfig          UserConfig
+	Concurrency         int
+
+	snapshotDir       string
+	streamedCRs       []streamedCR
+	exportUnmatched   string
+	generatePatches   string
+	generateConfig    string
+	extractValues     string
+	valueExtractor    *valueExtractor
+	validateSchemas   bool
+	schemasPath       string
+	schemas           *schemaSet
+	patchFormat       string
+	remediations      *remediations
+	compareToSnapshot string
+	recordDir         string
+	recorder          *recorder
+	replayDir         string
+	replay            *Recording
+	metricsFile       string
+	countResources    resourceCounter
+
+	contextNames       []string
+	allContexts        bool
+	parallelContexts   int
+	contexts           []clusterContext
+	templateCache      bool
+	noCache            bool
+	cacheDir           string
+	referenceCache     referenceCache
+	referenceLock      string
+	verifySignature    string
+	insecureSkipVerify bool
+	referenceHTTP      referenceHTTPOptions
+	referenceClient    *referenceHTTPClient
+	chart              chartOptions
+
+	operatorVersions *operatorVersionTracker
+
+	kinds             kindFilter
+	components        componentFilter
+	fieldOwners       fieldOwnerFilter
+	paths             pathFilter
+	fieldSelectors    fieldSelectors
+	serverSideDryRun  bool
+	ignoreAPIDefaults bool
+	inputFormat       string
+	normalizer        *serverSideNormalizer
+	enableLookups     bool
+	lookupQPS         float64
+	qps               float32
+	burst             int
+	chunkSize         int64
+	fetchConcurrency  int
+	maxMemoryValue    string
+	maxMemory         int64
+	maxDiffs          int
+	failFast          bool
+	lookups           *clusterLookup
+	namespaceScope    namespaceScope
+	annotateDrift     bool
+	removeAnnotations bool
+	annotator         *driftAnnotator
+	emitEvents        bool
+	emitter           *driftEventEmitter
+	review            bool
+	reviewer          *reviewer
+	publisher         resultsPublisher
+	streamer          *diffStreamer
+	outputCloser      io.Closer
+	excludedTemplates map[string]bool
+	clusterFacts      *ClusterFacts
+	notApplicable     []NotApplicableTemplate
+	unavailableKinds  *unavailableKinds
+	retries           int
+	retryInterval     time.Duration
+	timeout           time.Duration
+	templateTimeout   time.Duration
+	onTemplateError   string
+	exitPolicy        exitPolicy
+	renderFailures    *renderFailures
+	ambiguousMatches  *ambiguousMatches
+	explanations      *explanations
+	snapshot          *Snapshot
+	runCachePath      string
+	changedOnly       bool
+	runCache          *runCache
 
 	userOverridesPath               string
 	userOverridesCorrelator         Correlator[*UserOverride]
@@ -136,26 +260,35 @@ type Options struct {
 	newUserOverrides                []*UserOverride
 	templatesToGenerateOverridesFor []string
 	overrideReason                  string
+	referencePatchPaths             []string
+	referencePatches                []*referencePatch
 
 	diff *diff.DiffProgram
 	genericiooptions.IOStreams
 }
 
 func NewCmd(f kcmdutil.Factory, streams genericiooptions.IOStreams) *cobra.Command {
-	options := NewOptions(streams)
-	example := compareExample
-	if strings.HasPrefix(filepath.Base(os.Args[0]), "oc-") {
-		example = strings.ReplaceAll(compareExample, "kubectl", "oc")
-	} else if !strings.HasPrefix(filepath.Base(os.Args[0]), "kubectl-") {
-		example = strings.ReplaceAll(compareExample, "kubectl ", "")
-	}
+	return NewCmdWithOptions(f, NewOptions(streams))
+}
 
+// NewCmdWithOptions creates the command with options set up by a program embedding it, e.g. with custom correlators
+// added with WithCorrelators
+func NewCmdWithOptions(f kcmdutil.Factory, options *Options) *cobra.Command {
+	streams := options.IOStreams
 	cmd := &cobra.Command{
 		Use:                   "cluster-compare -r <Reference File>",
 		DisableFlagsInUseLine: true,
 		Short:                 i18n.T("Compare a reference configuration and a set of cluster configuration CRs."),
 		Long:                  compareLong,
-		Example:               example,
+		Example:               exampleForBinary(compareExample),
+		// Without a validator cobra reports the args of a command with subcommands as unknown commands, they're
+		// reported as usage errors with the same exit code as the other usage errors instead
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 0 {
+				kcmdutil.CheckDiffErr(kcmdutil.UsageErrorf(cmd, "Unexpected args: %v", args))
+			}
+			return nil
+		},
 		Run: func(cmd *cobra.Command, args []string) {
 			kcmdutil.CheckDiffErr(options.Complete(f, cmd, args))
 			// `kubectl cluster-compare` propagates the error code from
@@ -165,7 +298,13 @@ func NewCmd(f kcmdutil.Factory, streams genericiooptions.IOStreams) *cobra.Comma
 			// error code 1, which simply means that changes
 			// were found. We also don't want kubectl to
 			// return 1 if there was a problem.
-			if err := options.Run(); err != nil {
+			ctx, cancel := withCancellation(cmd.Context(), options.timeout)
+			defer cancel()
+			if err := options.Run(ctx); err != nil {
+				if code, ok := failuresExitCode(err); ok {
+					// The summary already reports the failures, only the exit code of the exit policy is returned
+					kcmdutil.CheckErr(exec.CodeExitError{Err: errors.New(""), Code: code})
+				}
 				if exitErr := diffError(err); exitErr != nil {
 					kcmdutil.CheckErr(kcmdutil.ErrExit)
 				}
@@ -181,23 +320,246 @@ func NewCmd(f kcmdutil.Factory, streams genericiooptions.IOStreams) *cobra.Comma
 		kcmdutil.CheckDiffErr(kcmdutil.UsageErrorf(cmd, err.Error()))
 		return nil
 	})
-	cmd.Flags().IntVar(&options.Concurrency, "concurrency", 4,
+	cmd.Flags().IntVar(&options.Concurrency, "concurrency", options.Concurrency,
 		"Number of objects to process in parallel when diffing against the live version. Larger number = faster,"+
 			" but more memory, I/O and CPU over that shorter period of time.")
 	kcmdutil.AddFilenameOptionFlags(cmd, &options.CRs, "contains the configuration to diff")
 	cmd.Flags().StringVarP(&options.diffConfigFileName, "diff-config", "c", "", "Path to the user config file")
-	cmd.Flags().StringVarP(&options.referenceConfig, "reference", "r", "", "Path to reference config file.")
+	cmd.Flags().StringArrayVarP(&options.referenceConfigs, "reference", "r", []string{},
+		"Path to reference config file. Can be repeated to compare the cluster to multiple references side by side")
 	cmd.Flags().BoolVar(&options.ShowManagedFields, "show-managed-fields", options.ShowManagedFields, "If true, include managed fields in the diff.")
 	cmd.Flags().BoolVarP(&options.diffAll, "all-resources", "A", options.diffAll,
 		"If present, In live mode will try to match all resources that are from the types mentioned in the reference. "+
 			"In local mode will try to match all resources passed to the command")
 	cmd.Flags().BoolVarP(&options.verboseOutput, "verbose", "v", options.verboseOutput, "Increases the verbosity of the tool")
+	cmd.Flags().BoolVar(&options.dryRun, "dry-run", false,
+		"Only print how many cluster resources of each kind would be fetched and how many templates would be compared, without fetching or diffing the resources")
+	cmd.Flags().BoolVar(&options.showMatchedOnly, "show-matched-only", false,
+		"Instead of the differences, list the cluster CRs that match their reference template without any differences, grouped by component")
+	cmd.Flags().BoolVar(&options.explain, "explain", false,
+		"Report on stderr, for every cluster CR, how each correlator matched it or why it didn't, and how the templates it was "+
+			"matched to were scored to choose the one it's compared to")
+	cmd.Flags().BoolVar(&options.stream, "stream", false,
+		fmt.Sprintf("Write the diff of every CR as soon as it is compared instead of once all the CRs were compared, followed by the summary. "+
+			"Diffs aren't grouped by component. -o %s always streams a JSON object per line", Jsonl))
+	cmd.Flags().StringVar(&options.diffEngine, "diff-engine", options.diffEngine,
+		fmt.Sprintf("Engine used to diff the cluster CRs against the reference. One of: (%s). external runs diff or KUBECTL_EXTERNAL_DIFF, "+
+			"internal lists the changed fields without running an external program", strings.Join(DiffEngines, ", ")))
+	cmd.Flags().StringVar(&options.diffStyle, "diff-style", options.diffStyle,
+		fmt.Sprintf("Style of the diffs of the external diff engine. One of: (%s). side-by-side renders the reference and the cluster CR "+
+			"in two aligned columns without running an external program", strings.Join(DiffStyles, ", ")))
+	cmd.Flags().IntVar(&options.diffContext, "diff-context", options.diffContext,
+		fmt.Sprintf("Number of unchanged lines shown around the changes of the diffs. By default (%d) unified diffs show %d lines "+
+			"as diff -u does, and side-by-side diffs the whole CR. It's passed to diff, not to the program of KUBECTL_EXTERNAL_DIFF",
+			defaultDiffContext, unifiedDiffContext))
+	cmd.Flags().BoolVar(&options.noDiffHeader, "no-diff-header", false,
+		"Leave out the header of the diffs naming the compared files, the diffs start with their first hunk")
+	cmd.Flags().StringVar(&options.groupBy, "group-by", options.groupBy,
+		fmt.Sprintf("Group the diffs of the text output by the reference component of their template, or by the namespace or the kind of the CRs. "+
+			"One of: (%s). Grouping by namespace also lists the CRs compared, with diffs and unmatched in every namespace after the summary",
+			strings.Join(GroupByModes, ", ")))
+	cmd.Flags().StringVar(&options.color, "color", options.color,
+		fmt.Sprintf("Color the diffs in the text output. One of: (%s). auto colors them only when stdout is a terminal and NO_COLOR isn't set", strings.Join(ColorModes, ", ")))
+	cmd.Flags().StringVar(&options.Progress, "progress", options.Progress,
+		fmt.Sprintf("Report the progress of the run to stderr. One of: (%s). auto reports progress only when stderr is a terminal", strings.Join(ProgressModes, ", ")))
+
+	cmd.Flags().StringVar(&options.snapshotDir, "snapshot-dir", "",
+		"Path to an empty directory where the normalized cluster CRs of this run will be written, for use with --compare-to-snapshot in later runs")
+	cmd.Flags().StringVar(&options.compareToSnapshot, "compare-to-snapshot", "",
+		"Path to a directory written by --snapshot-dir in a previous run. In addition to the reference, cluster CRs will be diffed against their version in the snapshot")
+	cmd.Flags().StringVar(&options.recordDir, "record", "",
+		"Path to an empty directory where the cluster CRs, the reference files, the cluster facts and the objects looked up by the templates "+
+			"of this run will be recorded, so the comparison can be replayed without the cluster with --replay")
+	cmd.Flags().StringVar(&options.replayDir, "replay", "",
+		"Path to a directory written by --record in a previous run. The comparison is replayed offline with the recorded reference and cluster CRs")
+	cmd.Flags().StringVar(&options.exportUnmatched, "export-unmatched", "",
+		"Path to an empty directory where the cluster CRs that weren't matched to any template will be written without the fields "+
+			"populated by the API server, as a starting point for new templates. Use with --all-resources to export all the unmatched CRs")
+	cmd.Flags().StringVar(&options.generatePatches, "generate-patches", "",
+		"Path to an empty directory where a patch bringing every cluster CR with diffs in line with its template will be written")
+	cmd.Flags().StringVar(&options.generateConfig, "generate-config", "",
+		"Path of a diff config file to write with correlation pairs for the cluster CRs that weren't matched to any template "+
+			"and the ones matched equally well by several templates, to pin their correlation with --diff-config")
+	cmd.Flags().StringVar(&options.extractValues, "extract-values", "",
+		"Path of a YAML file to write the values the matched cluster CRs have for the templated fields of their templates to, "+
+			"an inventory of the site-specific values of the cluster")
+	cmd.Flags().StringVar(&options.patchFormat, "patch-format", options.patchFormat,
+		fmt.Sprintf("Format of the patches written by --generate-patches. One of: (%s). %s writes json merge patches and a script "+
+			"applying them with kubectl patch, %s writes the whole CRs with the patches applied", strings.Join(PatchFormats, ", "), PatchFormatMerge, PatchFormatManifest))
+	cmd.Flags().StringVar(&options.metricsFile, "metrics-file", "",
+		"Path of a file to write the summary of the run to as Prometheus gauges in the text format, e.g. for the node-exporter textfile collector")
+	cmd.Flags().StringSliceVar(&options.contextNames, "contexts", []string{},
+		"Compare the clusters of these kubeconfig contexts instead of the current one, the output of every cluster is followed by a roll-up of all of them")
+	cmd.Flags().BoolVar(&options.allContexts, "all-contexts", false, "Compare the clusters of all the contexts in the kubeconfig")
+	cmd.Flags().IntVar(&options.parallelContexts, "parallel-contexts", options.parallelContexts,
+		"Number of clusters compared concurrently when using --contexts or --all-contexts")
+	cmd.Flags().StringSliceVar(&options.kinds.include, "include-kind", []string{},
+		"Only compare resources of this kind, can be repeated. Templates of other kinds are ignored and won't be reported missing")
+	cmd.Flags().StringSliceVar(&options.kinds.exclude, "exclude-kind", []string{},
+		"Don't compare resources of this kind, can be repeated. Templates of this kind are ignored and won't be reported missing")
+	cmd.Flags().StringArrayVar(&options.fieldSelectors.flags, "field-selector", []string{},
+		"List the resources of a kind only with this field selector, in the form of <kind>:<selector> (e.g. Secret:metadata.name=pull-secret). "+
+			"Can be repeated, the kind is listed once for every selector. Overrides the fieldSelector of the templates of the kind. Live mode only")
+	cmd.Flags().StringSliceVar(&options.namespaceScope.names, "namespaces", []string{},
+		"List the resources of namespaced kinds in these namespaces instead of across the cluster, for roles bound to some namespaces only. "+
+			"Can be repeated. Cluster scoped kinds are still listed across the cluster. Live mode only")
+	cmd.Flags().StringVar(&options.namespaceScope.selector, "namespace-selector", "",
+		"Like --namespaces, for the namespaces matching this label selector (e.g. team=payments), listing the namespaces requires a role allowing it")
+	cmd.Flags().StringSliceVar(&options.components.include, "components", []string{},
+		"Only compare the templates of these components of the reference, can be repeated. Templates of other components are ignored and won't be reported missing")
+	cmd.Flags().StringSliceVar(&options.components.exclude, "skip-components", []string{},
+		"Don't compare the templates of these components of the reference, can be repeated. Their templates are ignored and won't be reported missing")
+	cmd.Flags().StringSliceVar(&options.fieldOwners.only, "field-manager", []string{},
+		"Only compare the fields of cluster CRs owned by this field manager according to their managed fields, can be repeated")
+	cmd.Flags().StringSliceVar(&options.fieldOwners.ignore, "ignore-field-manager", []string{},
+		"Don't compare the fields of cluster CRs owned only by this field manager according to their managed fields (e.g. an operator's controller), can be repeated")
+	cmd.Flags().StringArrayVar(&options.paths.only, "only-path", []string{},
+		"Only compare the fields of the CRs selected by this JSONPath (e.g. .spec or {.spec.template.spec.containers[*].image}), "+
+			"can be repeated. The apiVersion, kind, name and namespace of the CRs are always kept")
+	cmd.Flags().StringArrayVar(&options.paths.ignore, "ignore-path", []string{},
+		"Don't compare the fields of the CRs selected by this JSONPath (e.g. .status or {.metadata.annotations['example.com/key']}), can be repeated")
+	cmd.Flags().StringVar(&options.inputFormat, "input-format", options.inputFormat,
+		fmt.Sprintf("Format of the local files passed with -f. One of: (%s). inventory reads the CRs of inventory exports: "+
+			"API list dumps (e.g. kubectl get --raw), ArgoCD managed resources and ACM ManagedClusterViews", strings.Join(InputFormats, ", ")))
+	cmd.Flags().BoolVar(&options.serverSideDryRun, "server-side-dry-run", false,
+		"Send the injected templates through a server-side dry run so the defaulting and mutating admission of the cluster "+
+			"are applied to them before diffing, avoiding diffs on fields set by the API server. Live mode only")
+	cmd.Flags().BoolVar(&options.ignoreAPIDefaults, "ignore-api-defaults", false,
+		"Don't report the fields of the cluster CRs of built-in kinds holding the default value the API server sets when "+
+			"the template doesn't set them (e.g. imagePullPolicy: IfNotPresent or dnsPolicy: ClusterFirst)")
+	cmd.Flags().BoolVar(&options.validateSchemas, "validate-schemas", false,
+		"Validate the rendered templates against the OpenAPI schemas of their kinds served by the cluster, or read from --schemas, "+
+			"fields the schemas don't declare and values of the wrong type are reported as template warnings")
+	cmd.Flags().StringVar(&options.schemasPath, "schemas", "",
+		"Path to a schema bundle to validate the rendered templates against instead of the schemas of the cluster: a file or a directory "+
+			"of CRDs and OpenAPI v3 documents as served under /openapi/v3. Implies --validate-schemas")
+	cmd.Flags().BoolVar(&options.enableLookups, "enable-lookups", false,
+		"Let the templates fetch other cluster objects with the lookupCR template function, without it lookupCR returns "+
+			"empty objects. Live mode only")
+	cmd.Flags().Float64Var(&options.lookupQPS, "lookup-qps", options.lookupQPS,
+		"Maximum number of requests per second sent to the API server by lookupCR, every object is fetched once per run")
+	cmd.Flags().Float32Var(&options.qps, "qps", 0,
+		"Maximum number of requests per second sent to the API server, the client-go default (5) is used if not set. Live mode only")
+	cmd.Flags().IntVar(&options.burst, "burst", 0,
+		"Maximum burst of requests sent to the API server above --qps, the client-go default (10) is used if not set. Live mode only")
+	cmd.Flags().Int64Var(&options.chunkSize, "chunk-size", options.chunkSize,
+		"Number of objects requested per page when listing the resources of the cluster, 0 lists them in a single request. Live mode only")
+	cmd.Flags().IntVar(&options.fetchConcurrency, "fetch-concurrency", options.fetchConcurrency,
+		"Number of resource types listed from the cluster at the same time, each with its own paged requests. Live mode only")
+	cmd.Flags().StringVar(&options.maxMemoryValue, "max-memory", "",
+		"Memory the run may use, e.g. 2Gi. The garbage collector keeps the memory under it and the comparison is interrupted "+
+			"with a partial summary if it can't. Use with --stream on large clusters so the diffs aren't kept in memory")
+	cmd.Flags().BoolVar(&options.annotateDrift, "annotate-drift", false,
+		fmt.Sprintf("Annotate the cluster CRs with diffs with %s=true and %s, the hash of their diff, and remove the "+
+			"annotations from the CRs compared without diffs. Requires permission to patch the CRs. Live mode only", DriftAnnotation, DriftHashAnnotation))
+	cmd.Flags().BoolVar(&options.removeAnnotations, "remove-annotations", false,
+		"Remove the annotations written by --annotate-drift from all the cluster CRs of the compared kinds. Live mode only")
+	cmd.Flags().BoolVar(&options.review, "review", false,
+		"Review the CRs with diffs one at a time once the comparison is over, instead of printing all the diffs. Reading the commands "+
+			"line by line from stdin, the diff of a CR can be shown, acknowledged by adding its patch to the overrides file of -p (default "+
+			defaultReviewOverridesFile+") or its CR correlated to another template in the diff config of -c (default "+defaultReviewDiffConfigFile+")")
+	cmd.Flags().BoolVar(&options.emitEvents, "emit-events", false,
+		fmt.Sprintf("Create a Warning event with the %s reason for each cluster CR with diffs, summarizing its drift, for the "+
+			"alerting based on events to pick it up. Requires permission to create events. Live mode only", DriftEventReason))
+	cmd.Flags().StringVar(&options.publisher.target, "publish-results", "",
+		fmt.Sprintf("Publish the summary of the run to the cluster, timestamped, for dashboards and policies to consume it. One of: (%s). "+
+			"%s writes a config map, %s a %s CR whose CRD must be installed. Requires permission to create and patch them. Live mode only",
+			strings.Join(PublishTargets, ", "), PublishConfigMap, PublishReport, ReportGVK.Kind))
+	cmd.Flags().StringVar(&options.publisher.namespace, "publish-namespace", "",
+		"Namespace of the config map or report written by --publish-results, required with it")
+	cmd.Flags().StringVar(&options.publisher.name, "publish-name", defaultPublishName,
+		"Name of the config map or report written by --publish-results")
+	cmd.Flags().BoolVar(&options.publisher.fullOutput, "publish-full-output", false,
+		"Also publish the diffs of the CRs with --publish-results, not only the summary. The results are limited to 1MiB")
+	cmd.Flags().IntVar(&options.retries, "retries", options.retries,
+		"Number of times listing a resource type from the cluster is retried after a transient error (e.g. 429 or 503 responses), "+
+			"types that still can't be listed are reported and skipped")
+	cmd.Flags().DurationVar(&options.retryInterval, "retry-interval", options.retryInterval,
+		"Time to wait before the first retry of listing a resource type, doubled on every following retry")
+	cmd.Flags().DurationVar(&options.timeout, "timeout", 0,
+		"Maximum time for the comparison (e.g. 5m), the CRs compared until it expires are reported in a partial summary. "+
+			"Zero means no timeout")
+	cmd.Flags().DurationVar(&options.templateTimeout, "template-timeout", options.templateTimeout,
+		"Maximum time to render a template for a cluster CR, templates can override it with renderTimeout in their config. "+
+			"Zero means no timeout")
+	cmd.Flags().IntVar(&options.maxDiffs, "max-diffs", 0,
+		"Stop the comparison once this number of CRs with diffs were found, the CRs compared until then are reported in a "+
+			"partial summary. Zero means no limit")
+	cmd.Flags().BoolVar(&options.failFast, "fail-fast", false,
+		"Stop the comparison at the first CR with diffs, same as --max-diffs=1")
+	cmd.Flags().StringVar(&options.onTemplateError, "on-template-error", options.onTemplateError,
+		fmt.Sprintf("What to do when a template fails to render for a cluster CR (it returns an error, panics or times out). One of: (%s). "+
+			"skip reports the failures in the summary and goes on with the comparison", strings.Join(TemplateErrorPolicies, ", ")))
+	cmd.Flags().BoolVar(&options.exitPolicy.failOnMissing, "fail-on-missing", options.exitPolicy.failOnMissing,
+		"Fail when required CRs of the reference are missing from the cluster (and on the other validation issues of the reference)")
+	cmd.Flags().BoolVar(&options.exitPolicy.failOnUnmatched, "fail-on-unmatched", false,
+		"Fail when cluster CRs aren't matched by any template of the reference")
+	cmd.Flags().StringVar(&options.exitPolicy.failOnSeverity, "fail-on-severity", options.exitPolicy.failOnSeverity,
+		fmt.Sprintf("Lowest severity of the diffs of CRs that fails the command, the severities of the fields are set by the fieldSeverities "+
+			"of the reference and the diffs of other fields are warnings. One of: (%s)", severityNames()))
+	cmd.Flags().IntVar(&options.exitPolicy.diffsCode, "exit-code-diffs", options.exitPolicy.diffsCode,
+		"Exit code when CRs differ from the reference (or operator versions drift, kinds can't be fetched or templates fail to render)")
+	cmd.Flags().IntVar(&options.exitPolicy.missingCode, "exit-code-missing", options.exitPolicy.missingCode,
+		"Exit code when required CRs are missing, used when no CR differs from the reference")
+	cmd.Flags().IntVar(&options.exitPolicy.unmatchedCode, "exit-code-unmatched", options.exitPolicy.unmatchedCode,
+		"Exit code when cluster CRs are unmatched with --fail-on-unmatched, used when no CR differs and none is missing")
+	cmd.Flags().StringArrayVar(&options.chart.valuesFiles, "values", []string{},
+		"Values file used to render a chart reference passed as -r chart:<chart directory>, can be repeated, the last file wins")
+	cmd.Flags().StringVar(&options.chart.releaseName, "release-name", "",
+		"Release name used to render a chart reference, defaults to the name of the chart")
+	cmd.Flags().StringVar(&options.chart.releaseNamespace, "release-namespace", "",
+		"Release namespace used to render a chart reference, defaults to default")
+	cmd.Flags().StringVar(&options.referenceLock, "reference-lock", "",
+		fmt.Sprintf("Path to a lock file written by update-lock, loading reference files that don't match it fails. "+
+			"Defaults to %s next to a local reference config if it exists", DefaultLockFileName))
+	cmd.Flags().StringVar(&options.verifySignature, "verify-signature", "",
+		"Checksum in the form of sha256:<hex digest> the file passed to -r (a bundle or a reference config) is verified against before it's used. "+
+			"A reference config served over http also needs --reference-lock to verify its templates")
+	cmd.Flags().BoolVar(&options.insecureSkipVerify, "insecure-skip-verify", false,
+		"Load a reference served over http without verifying it and without warning about it")
+	cmd.Flags().DurationVar(&options.referenceHTTP.timeout, "reference-timeout", options.referenceHTTP.timeout,
+		"Maximum time of a request fetching a file of a reference served over http, zero means no timeout")
+	cmd.Flags().IntVar(&options.referenceHTTP.retries, "reference-retries", options.referenceHTTP.retries,
+		"Number of times a request fetching a file of a reference served over http is retried after a connection error "+
+			"or a transient status (408, 429 and 5xx)")
+	cmd.Flags().DurationVar(&options.referenceHTTP.retryInterval, "reference-retry-interval", options.referenceHTTP.retryInterval,
+		"Time to wait before the first retry of fetching a file of a reference, doubled on every following retry")
+	cmd.Flags().StringArrayVar(&options.referenceHTTP.headers, "reference-header", []string{},
+		fmt.Sprintf("Header sent with the requests fetching a reference served over http in the form of Name: value, can be repeated. "+
+			"A bearer token can be passed in the %s environment variable instead, it doesn't show in the command line", ReferenceTokenEnv))
+	cmd.Flags().StringVar(&options.referenceHTTP.proxy, "reference-proxy", "",
+		"URL of the proxy used to fetch a reference served over http, defaults to the proxy set by the HTTPS_PROXY, HTTP_PROXY and NO_PROXY environment variables")
+	cmd.Flags().StringVar(&options.referenceHTTP.caFile, "reference-ca-file", "",
+		"Path to a PEM bundle of the certificate authorities trusted to serve a reference over https, instead of the ones of the system")
+	cmd.Flags().StringVar(&options.referenceHTTP.certFile, "reference-client-cert", "",
+		"Path to a PEM client certificate presented to the server of a reference served over https, requires --reference-client-key")
+	cmd.Flags().StringVar(&options.referenceHTTP.keyFile, "reference-client-key", "",
+		"Path to the PEM key of --reference-client-cert")
+	cmd.Flags().BoolVar(&options.templateCache, "template-cache", false,
+		"Cache the results of parsing and validating the reference templates in the user cache directory, "+
+			"repeated runs against the same reference will skip parsing and validating it. References served over http "+
+			"are cached by default")
+	cmd.Flags().BoolVar(&options.noCache, "no-cache", false,
+		"Don't cache the references served over http and their parsed templates, they are fetched and parsed on every run")
+	cmd.Flags().StringVar(&options.cacheDir, "cache-dir", "",
+		"Directory where the references served over http and the parsed templates are cached, defaults to kube-compare "+
+			"under the user cache directory")
+	cmd.Flags().StringVar(&options.runCachePath, "run-cache", "",
+		"Path of a file where the resourceVersion, generation and result of every compared cluster CR are recorded, "+
+			"for use with --changed-only in later runs")
+	cmd.Flags().BoolVar(&options.changedOnly, "changed-only", false,
+		"Only render and diff the cluster CRs whose resourceVersion or generation changed since they were recorded in "+
+			"--run-cache, the recorded results of the other CRs are reused. Requires --run-cache")
 
 	cmd.Flags().StringVarP(&options.userOverridesPath, "overrides", "p", "", "Path to user overrides")
 	cmd.Flags().StringSliceVar(&options.templatesToGenerateOverridesFor, "generate-override-for", []string{}, "Path for template file you wish to generate a override for")
 	cmd.Flags().StringVar(&options.overrideReason, "override-reason", "", "Reason for generating the override")
+	cmd.Flags().StringArrayVar(&options.referencePatchPaths, "reference-patch", []string{},
+		"Path of a file of strategic merge or JSON6902 patches, in the format of kustomize patches, applied to the "+
+			"rendered templates they target before they are diffed. Can be repeated, the patches are applied in order")
 
 	cmd.Flags().StringVarP(&options.OutputFormat, "output", "o", "", fmt.Sprintf(`Output format. One of: (%s)`, strings.Join(OutputFormats, ", ")))
+	cmd.Flags().StringVar(&options.outputFile, "output-file", "", "Write the output to the file at this path instead of stdout")
 	kcmdutil.CheckErr(cmd.RegisterFlagCompletionFunc(
 		"output",
 		func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
@@ -211,9 +573,31 @@ func NewCmd(f kcmdutil.Factory, streams genericiooptions.IOStreams) *cobra.Comma
 		},
 	))
 
+	cmd.AddCommand(NewLintCmd(streams))
+	cmd.AddCommand(NewTestCmd(streams))
+	cmd.AddCommand(NewUpdateLockCmd(streams))
+	cmd.AddCommand(NewBundleCmd(streams))
+	cmd.AddCommand(NewGenerateCmd(f, streams))
+	cmd.AddCommand(NewDiscoverFieldsCmd(f, streams))
+	cmd.AddCommand(NewJobCmd(streams))
+	cmd.AddCommand(NewCanICmd(f, streams))
+	cmd.AddCommand(NewReportDiffCmd(streams))
+	cmd.AddCommand(NewControllerCmd(f, streams))
+
 	return cmd
 }
 
+// exampleForBinary adjusts the command name used in the examples to the name of the binary that is being run
+func exampleForBinary(example string) string {
+	if strings.HasPrefix(filepath.Base(os.Args[0]), "oc-") {
+		return strings.ReplaceAll(example, "kubectl", "oc")
+	} else if !strings.HasPrefix(filepath.Base(os.Args[0]), "kubectl-") {
+		return strings.ReplaceAll(example, "kubectl ", "")
+	}
+	return example
+}
+
+// NewOptions creates the options with the defaults of the command line flags
 func NewOptions(ioStreams genericiooptions.IOStreams) *Options {
 	return &Options{
 		IOStreams: ioStreams,
@@ -221,9 +605,41 @@ func NewOptions(ioStreams genericiooptions.IOStreams) *Options {
 			Exec:      exec.New(),
 			IOStreams: ioStreams,
 		},
+		Concurrency:      4,
+		diffEngine:       DiffEngineExternal,
+		diffStyle:        DiffStyleUnified,
+		diffContext:      defaultDiffContext,
+		groupBy:          GroupByComponent,
+		color:            ColorAuto,
+		Progress:         ProgressAuto,
+		parallelContexts: 1,
+		inputFormat:      InputFormatManifests,
+		patchFormat:      PatchFormatMerge,
+		lookupQPS:        5,
+		chunkSize:        defaultChunkSize,
+		fetchConcurrency: defaultFetchConcurrency,
+		retries:          3,
+		retryInterval:    time.Second,
+		referenceHTTP:    defaultReferenceHTTPOptions(),
+		templateTimeout:  30 * time.Second,
+		onTemplateError:  TemplateErrorFail,
+		exitPolicy: exitPolicy{
+			failOnMissing:  true,
+			failOnSeverity: string(api.SeverityInfo),
+			diffsCode:      1,
+			missingCode:    1,
+			unmatchedCode:  1,
+		},
 	}
 }
 
+// WithCorrelators adds custom correlators to the correlation chain. They're tried after the manual correlation of the
+// diff config and before the correlation by groups of fields, in the order they were added.
+func (o *Options) WithCorrelators(factories ...CorrelatorFactory) *Options {
+	o.correlatorFactories = append(o.correlatorFactories, factories...)
+	return o
+}
+
 // DiffError returns the ExitError if the status code is less than 1,
 // nil otherwise.
 func diffError(err error) exec.ExitError {
@@ -234,61 +650,355 @@ func diffError(err error) exec.ExitError {
 	return nil
 }
 
+// GetRefFS returns the file system of the reference, references served over http are fetched with the default
+// settings of the --reference-* flags
 func GetRefFS(refConfig string) (fs.FS, error) {
+	client, err := defaultReferenceClient()
+	if err != nil {
+		return nil, err
+	}
+	return getRefFS(refConfig, client, nil)
+}
+
+// getRefFS returns the file system of the reference fetched with the client, including is the chain of references
+// including it
+func getRefFS(refConfig string, client *referenceHTTPClient, including []string) (fs.FS, error) {
+	if isBundle(refConfig) {
+		return loadBundle(refConfig, client)
+	}
 	referenceDir := filepath.Dir(refConfig)
 	if isURL(refConfig) {
 		// filepath.Dir removes one / from http://
 		referenceDir = strings.Replace(referenceDir, "/", "//", 1)
-		return HTTPFS{baseURL: referenceDir, httpGet: httpgetImpl}, nil
+		return withIncludes(HTTPFS{baseURL: referenceDir, client: client}, refConfig, client, including)
 	}
 	rootPath, err := filepath.Abs(referenceDir)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get absolute path: %w", err)
 	}
-	return os.DirFS(rootPath), nil
+	return withIncludes(os.DirFS(rootPath), filepath.Join(rootPath, filepath.Base(refConfig)), client, including)
 }
+
+// Complete checks the args of the command and sets up the comparison from the flags, errors caused by invalid flags
+// are turned into usage errors of the command
 func (o *Options) Complete(f kcmdutil.Factory, cmd *cobra.Command, args []string) error {
+	if len(args) != 0 {
+		return kcmdutil.UsageErrorf(cmd, "Unexpected args: %v", args)
+	}
+	err := o.setup(f)
+	var usageErr usageError
+	if errors.As(err, &usageErr) {
+		return kcmdutil.UsageErrorf(cmd, "%s", err.Error())
+	}
+	return err
+}
+
+// usageError is an error caused by invalid options, the command reports it along with the hint to its help
+type usageError struct {
+	msg string
+}
+
+func (e usageError) Error() string {
+	return e.msg
+}
+
+func usageErrorf(format string, args ...any) error {
+	return usageError{msg: fmt.Sprintf(format, args...)}
+}
+
+// referenceFS returns the file system of the reference and the name of its reference config. A chart reference is
+// rendered, other references are verified against the signature and the lock before encrypted files are decrypted.
+func (o *Options) referenceFS() (fs.FS, string, error) {
+	if isChartReference(o.referenceConfig) {
+		cfs, err := chartReferenceFS(o.referenceConfig, o.chart)
+		return cfs, generatedReferenceFileName, err
+	}
+	if _, err := os.Stat(o.referenceConfig); os.IsNotExist(err) && !isURL(o.referenceConfig) {
+		return nil, "", fmt.Errorf(refFileNotExistsError)
+	}
+
+	if o.verifySignature != "" && o.insecureSkipVerify {
+		return nil, "", usageErrorf("--verify-signature and --insecure-skip-verify can't be used together")
+	}
+	if isURL(o.referenceConfig) && o.verifySignature == "" && !o.insecureSkipVerify {
+		klog.Warningf(unverifiedRemoteReference, o.referenceConfig)
+	}
+	// The templates of a reference served over http would be loaded unverified although the run is meant to be verified
+	if isURL(o.referenceConfig) && o.verifySignature != "" && !isBundle(o.referenceConfig) && o.referenceLock == "" {
+		return nil, "", usageErrorf(unverifiedRemoteTemplates, o.referenceConfig)
+	}
+	var lock *ReferenceLock
+	if lockPath := referenceLockPath(o.referenceConfig, o.referenceLock); lockPath != "" {
+		var err error
+		if lock, err = LoadReferenceLock(lockPath); err != nil {
+			return nil, "", err
+		}
+	}
+	// A reference pinned by its checksum or a lock is the same reference in every run, it's fetched once
+	cfs, record := o.referenceCache.load(referenceCacheKey(o.referenceConfig, o.verifySignature, lock))
+	if cfs == nil {
+		fetched, err := getVerifiedRefFS(o.referenceConfig, o.verifySignature, o.referenceClient)
+		if err != nil {
+			return nil, "", err
+		}
+		cfs = record(fetched)
+	}
+	if lock != nil {
+		cfs = newLockedFS(cfs, lock)
+	}
+	// The lock and the signature cover the files as stored, encrypted files are decrypted only once verified
+	return newSopsFS(cfs), ReferenceFileName(o.referenceConfig), nil
+}
+
+// setup validates the options and prepares the comparison: loads the reference, parses its templates and sets up the
+// correlators and the clients of the cluster. It's shared by the command and Compare.
+func (o *Options) setup(f kcmdutil.Factory) error {
 	var err error
-	o.builder = f.NewBuilder()
+	if o.qps < 0 || o.burst < 0 {
+		return usageErrorf("--qps and --burst can't be negative")
+	}
+	f = o.throttled(f)
+	o.newBuilder = f.NewBuilder
+	o.factory = f
+
+	if !slices.Contains(ProgressModes, o.Progress) {
+		return usageErrorf("Invalid progress mode %q, must be one of: %s", o.Progress, strings.Join(ProgressModes, ", "))
+	}
+
+	if !slices.Contains(ColorModes, o.color) {
+		return usageErrorf("Invalid color mode %q, must be one of: %s", o.color, strings.Join(ColorModes, ", "))
+	}
+
+	if _, ok := getRenderer(o.OutputFormat); !ok {
+		return usageErrorf("Invalid output format %q, must be one of: %s", o.OutputFormat, strings.Join(OutputFormats, ", "))
+	}
+
+	if !slices.Contains(DiffEngines, o.diffEngine) {
+		return usageErrorf("Invalid diff engine %q, must be one of: %s", o.diffEngine, strings.Join(DiffEngines, ", "))
+	}
+	if !slices.Contains(DiffStyles, o.diffStyle) {
+		return usageErrorf("Invalid diff style %q, must be one of: %s", o.diffStyle, strings.Join(DiffStyles, ", "))
+	}
+	if o.diffStyle == DiffStyleSideBySide && o.diffEngine == DiffEngineInternal {
+		return usageErrorf("--diff-style %s can't be used with --diff-engine %s", DiffStyleSideBySide, DiffEngineInternal)
+	}
+	if o.diffContext < defaultDiffContext {
+		return usageErrorf("--diff-context can't be negative")
+	}
+	if o.diffContext != defaultDiffContext && o.diffEngine == DiffEngineInternal {
+		return usageErrorf("--diff-context can't be used with --diff-engine %s, it lists the changed fields without context", DiffEngineInternal)
+	}
+	if !slices.Contains(GroupByModes, o.groupBy) {
+		return usageErrorf("Invalid --group-by %q, must be one of: %s", o.groupBy, strings.Join(GroupByModes, ", "))
+	}
+	if o.diffEngine == DiffEngineExternal && o.diffStyle == DiffStyleUnified && !externalDiffAvailable() {
+		klog.Warning("diff wasn't found in the PATH, the diffs are computed internally")
+		o.diffProgramMissing = true
+	}
+	if err := o.paths.process(); err != nil {
+		return usageErrorf("%s", err)
+	}
+	if err := o.fieldSelectors.process(); err != nil {
+		return usageErrorf("%s", err)
+	}
+	if !slices.Contains(TemplateErrorPolicies, o.onTemplateError) {
+		return usageErrorf("Invalid template error policy %q, must be one of: %s", o.onTemplateError, strings.Join(TemplateErrorPolicies, ", "))
+	}
+	if err := o.exitPolicy.validate(); err != nil {
+		return usageErrorf("%s", err)
+	}
+	if !slices.Contains(PatchFormats, o.patchFormat) {
+		return usageErrorf("Invalid patch format %q, must be one of: %s", o.patchFormat, strings.Join(PatchFormats, ", "))
+	}
+	if !slices.Contains(InputFormats, o.inputFormat) {
+		return usageErrorf("Invalid input format %q, must be one of: %s", o.inputFormat, strings.Join(InputFormats, ", "))
+	}
+	if len(o.fieldOwners.only) > 0 && len(o.fieldOwners.ignore) > 0 {
+		return usageErrorf("--field-manager and --ignore-field-manager can't be used together")
+	}
+
+	if o.retries < 0 || o.retryInterval < 0 {
+		return usageErrorf("--retries and --retry-interval can't be negative")
+	}
+	if o.referenceClient, err = o.referenceHTTP.newClient(); err != nil {
+		return err
+	}
+	if o.timeout < 0 {
+		return usageErrorf("--timeout can't be negative")
+	}
+	if o.lookupQPS <= 0 {
+		return usageErrorf("--lookup-qps must be positive")
+	}
+	if o.chunkSize < 0 {
+		return usageErrorf("--chunk-size can't be negative")
+	}
+	if o.fetchConcurrency < 1 {
+		return usageErrorf("--fetch-concurrency must be positive")
+	}
+	if o.maxMemoryValue != "" {
+		var err error
+		if o.maxMemory, err = parseMemoryLimit(o.maxMemoryValue); err != nil {
+			return usageErrorf("%s", err)
+		}
+	}
+	if o.maxDiffs < 0 {
+		return usageErrorf("--max-diffs can't be negative")
+	}
+	if o.failFast {
+		if o.maxDiffs > 1 {
+			return usageErrorf("--fail-fast and --max-diffs can't be used together")
+		}
+		o.maxDiffs = 1
+	}
+	if o.maxDiffs > 0 && len(o.referenceConfigs) > 1 {
+		return usageErrorf("--max-diffs and --fail-fast can't be used with multiple references")
+	}
+
+	if o.OutputFormat == Jsonl {
+		o.stream = true
+	}
+	if o.replayDir != "" {
+		if err := o.setupReplay(); err != nil {
+			return err
+		}
+	}
+	if o.recordDir != "" && (len(o.contextNames) > 0 || o.allContexts || o.dryRun || len(o.referenceConfigs) > 1) {
+		return usageErrorf("--record can't be used with --contexts, --all-contexts, --dry-run or multiple references")
+	}
+	if err := o.validateContextFlags(); err != nil {
+		return err
+	}
+	if err := o.validateReferenceFlags(); err != nil {
+		return err
+	}
+	if o.annotateDrift && o.removeAnnotations {
+		return usageErrorf("--annotate-drift and --remove-annotations can't be used together")
+	}
+	if o.annotateDrift || o.removeAnnotations {
+		o.annotator = newDriftAnnotator(o.removeAnnotations)
+	}
+	if o.publisher.isSet() {
+		if !slices.Contains(PublishTargets, o.publisher.target) {
+			return usageErrorf("Invalid --publish-results %q, must be one of: %s", o.publisher.target, strings.Join(PublishTargets, ", "))
+		}
+		if o.publisher.namespace == "" || o.publisher.name == "" {
+			return usageErrorf("--publish-results requires --publish-namespace and --publish-name")
+		}
+		if len(o.contextNames) > 0 || o.allContexts || o.dryRun {
+			return usageErrorf("--publish-results can't be used with --contexts, --all-contexts or --dry-run")
+		}
+		o.publisher.reference = o.referenceConfig
+		o.publisher.now = time.Now
+	} else if o.publisher.fullOutput {
+		return usageErrorf("--publish-full-output requires --publish-results")
+	}
+
+	if o.changedOnly && o.runCachePath == "" {
+		return usageErrorf("--changed-only requires --run-cache")
+	}
+	if o.runCachePath != "" && (o.OutputFormat == PatchYaml || len(o.contextNames) > 0 || o.allContexts || o.dryRun ||
+		o.snapshotDir != "" || o.compareToSnapshot != "" || o.annotator != nil || o.generatePatches != "" || o.generateConfig != "") {
+		return usageErrorf("--run-cache can't be used with --contexts, --all-contexts, --dry-run, snapshots, --annotate-drift, --remove-annotations, --generate-patches, --generate-config or -o %s", PatchYaml)
+	}
+
+	if o.dryRun && (o.OutputFormat == PatchYaml || len(o.contextNames) > 0 || o.allContexts || o.snapshotDir != "" ||
+		o.compareToSnapshot != "" || o.metricsFile != "" || o.showMatchedOnly || o.exportUnmatched != "" || o.annotator != nil ||
+		o.emitEvents || o.generatePatches != "" || o.generateConfig != "" || o.extractValues != "") {
+		return usageErrorf("--dry-run can't be used with --contexts, --all-contexts, snapshots, --metrics-file, --show-matched-only, --export-unmatched, --generate-patches, --generate-config, --extract-values, --annotate-drift, --remove-annotations, --emit-events or -o %s", PatchYaml)
+	}
+
+	if o.showMatchedOnly && (o.OutputFormat == PatchYaml || len(o.contextNames) > 0 || o.allContexts) {
+		return usageErrorf("--show-matched-only can't be used with --contexts, --all-contexts or -o %s", PatchYaml)
+	}
+
+	if o.explain && (len(o.contextNames) > 0 || o.allContexts || o.dryRun || len(o.referenceConfigs) > 1) {
+		return usageErrorf("--explain can't be used with --contexts, --all-contexts, --dry-run or multiple references")
+	}
+
+	if o.stream && (o.OutputFormat == Json || o.OutputFormat == Yaml || o.OutputFormat == PatchYaml || len(o.contextNames) > 0 ||
+		o.allContexts || o.showMatchedOnly || o.dryRun) {
+		return usageErrorf("--stream and -o %s can't be used with --contexts, --all-contexts, --show-matched-only, --dry-run or -o %s, %s or %s",
+			Jsonl, Json, Yaml, PatchYaml)
+	}
+	if o.stream && o.groupBy != GroupByComponent {
+		return usageErrorf("--group-by can't be used with --stream, streamed diffs aren't grouped")
+	}
+	if o.review && (o.OutputFormat != "" || o.stream || len(o.contextNames) > 0 || o.allContexts || o.showMatchedOnly ||
+		o.dryRun || o.runCachePath != "") {
+		return usageErrorf("--review can't be used with --contexts, --all-contexts, --show-matched-only, --dry-run, --run-cache, --stream or -o")
+	}
 
 	if o.OutputFormat == PatchYaml {
 		if len(o.templatesToGenerateOverridesFor) == 0 {
-			return kcmdutil.UsageErrorf(cmd, noTemplateForGeneration)
+			return usageErrorf(noTemplateForGeneration)
 		}
 
 		if o.overrideReason == "" {
-			return kcmdutil.UsageErrorf(cmd, noReason)
+			return usageErrorf(noReason)
 		}
 	}
 
-	if o.referenceConfig == "" {
-		return kcmdutil.UsageErrorf(cmd, noRefFileWasPassed)
+	if err := o.setupCache(); err != nil {
+		return err
 	}
-	if _, err := os.Stat(o.referenceConfig); os.IsNotExist(err) && !isURL(o.referenceConfig) {
-		return fmt.Errorf(refFileNotExistsError)
+	if err := o.validateChartFlags(); err != nil {
+		return err
 	}
-
-	cfs, err := GetRefFS(o.referenceConfig)
+	if len(o.referenceConfigs) > 1 {
+		return o.setReferences(f)
+	}
+	if len(o.referenceConfigs) == 1 {
+		o.referenceConfig = o.referenceConfigs[0]
+	}
+	if o.referenceConfig == "" {
+		return usageErrorf(noRefFileWasPassed)
+	}
+	cfs, referenceFileName, err := o.referenceFS()
 	if err != nil {
 		return err
 	}
-
-	referenceFileName := filepath.Base(o.referenceConfig)
 	o.ref, err = GetReference(cfs, referenceFileName)
 	if err != nil {
 		return err
 	}
-
 	if o.diffConfigFileName != "" {
 		o.userConfig, err = parseDiffConfig(o.diffConfigFileName)
 		if err != nil {
 			return err
 		}
 	}
-	o.templates, err = ParseTemplates(o.ref, cfs)
-	if err != nil {
-		return err
+	if o.referenceCache.enabled() && (o.templateCache || isURL(o.referenceConfig)) {
+		o.templates, err = ParseTemplatesWithCache(o.ref, cfs, referenceFileName, templateCacheDir(o.referenceCache.dir))
+		if err != nil {
+			return err
+		}
+	} else {
+		o.templates, err = ParseTemplates(o.ref, cfs)
+		if err != nil {
+			return err
+		}
+	}
+	o.referenceCache.store()
+	// The metadata hash identifies the whole reference, including the components and kinds filtered out
+	o.metadataHash = getMetadataHash(o.ref, o.templates)
+	o.differenceWeights = newDifferenceWeights(o.ref.GetMatchTieBreakers(), o.userConfig)
+	o.fieldSeverities = o.ref.GetFieldSeverities()
+	if o.components.isSet() {
+		if o.ref, err = o.components.filterReference(o.ref); err != nil {
+			return err
+		}
+		o.templates = o.ref.GetTemplates()
+	}
+	if paths := templatesUsingLookups(o.templates); len(paths) > 0 && !o.enableLookups && (o.replay == nil || !o.replay.EnableLookups) {
+		klog.Warningf("Templates %s call lookupCR, it returns empty objects unless --enable-lookups is set", strings.Join(paths, ", "))
+	}
+	if o.kinds.isSet() {
+		o.templates, o.excludedTemplates = o.kinds.filterTemplates(o.templates)
+		if len(o.templates) == 0 {
+			return errors.New(noTemplatesAfterKindFilter)
+		}
 	}
+	o.templates = orderByDependencies(o.templates)
 
 	if o.userOverridesPath != "" {
 		o.userOverrides, err = LoadUserOverrides(o.userOverridesPath)
@@ -297,6 +1007,72 @@ func (o *Options) Complete(f kcmdutil.Factory, cmd *cobra.Command, args []string
 		}
 		o.newUserOverrides = append(o.newUserOverrides, o.userOverrides...)
 	}
+	if len(o.referencePatchPaths) > 0 {
+		if o.referencePatches, err = loadReferencePatches(o.referencePatchPaths); err != nil {
+			return err
+		}
+		if unused := unusedReferencePatches(o.referencePatches, o.templates); len(unused) > 0 {
+			klog.Warningf("Reference patches of %s target no template of the reference", strings.Join(unused, ", "))
+		}
+	}
+
+	if o.compareToSnapshot != "" {
+		o.snapshot, err = LoadSnapshot(o.compareToSnapshot)
+		if err != nil {
+			return err
+		}
+	}
+	if o.snapshotDir != "" {
+		if err := prepareSnapshotDir(o.snapshotDir); err != nil {
+			return err
+		}
+	}
+	if o.exportUnmatched != "" {
+		if err := prepareExportDir(o.exportUnmatched); err != nil {
+			return err
+		}
+	}
+	if o.extractValues != "" {
+		if o.valueExtractor, err = newValueExtractor(cfs, o.templates); err != nil {
+			return err
+		}
+	}
+	if o.schemasPath != "" {
+		o.validateSchemas = true
+		if o.schemas, err = loadSchemaBundle(o.schemasPath); err != nil {
+			return err
+		}
+	}
+	if o.review {
+		// The review is held on the terminal even when the output is written to a file
+		o.reviewer = newReviewer(o)
+	}
+	if o.outputFile != "" {
+		file, err := os.Create(o.outputFile)
+		if err != nil {
+			return fmt.Errorf("failed to create output file: %w", err)
+		}
+		o.Out = file
+		o.outputCloser = file
+	}
+	if o.stream {
+		o.streamer = &diffStreamer{out: o.Out, format: o.OutputFormat, showEmptyDiffs: o.verboseOutput, color: useColor(o.color, o.Out)}
+	}
+	if o.generatePatches != "" {
+		if err := prepareExportDir(o.generatePatches); err != nil {
+			return err
+		}
+		o.remediations = &remediations{}
+	}
+	if o.runCachePath != "" {
+		key, err := runCacheKey(o)
+		if err != nil {
+			return err
+		}
+		if o.runCache, err = loadRunCache(o.runCachePath, key, o.changedOnly, o.enableLookups, o.templates); err != nil {
+			return err
+		}
+	}
 
 	err = o.setupCorrelators()
 	if err != nil {
@@ -308,18 +1084,128 @@ func (o *Options) Complete(f kcmdutil.Factory, cmd *cobra.Command, args []string
 		return err
 	}
 
-	if len(args) != 0 {
-		return kcmdutil.UsageErrorf(cmd, "Unexpected args: %v", args)
-	}
 	err = o.CRs.RequireFilenameOrKustomize()
 
 	if err == nil {
 		o.local = true
 		o.types = []string{}
+	}
+	if len(o.contextNames) > 0 || o.allContexts {
+		return o.setContexts(f)
+	}
+	if o.local {
+		if stdin := slices.Index(o.CRs.Filenames, stdinFilename); stdin >= 0 && slices.Contains(o.CRs.Filenames[stdin+1:], stdinFilename) {
+			return usageErrorf("-f - can only be used once")
+		}
+		if o.review && slices.Contains(o.CRs.Filenames, stdinFilename) {
+			return usageErrorf("--review can't be used with -f -, the commands of the review are read from stdin")
+		}
+		if o.dryRun {
+			return usageErrorf("--dry-run can't be used with local files")
+		}
+		if o.serverSideDryRun {
+			return usageErrorf("--server-side-dry-run can't be used with local files")
+		}
+		if o.enableLookups && o.replay == nil {
+			return usageErrorf("--enable-lookups can't be used with local files")
+		}
+		if o.recordDir != "" {
+			return usageErrorf("--record can't be used with local files")
+		}
+		if o.validateSchemas && o.schemas == nil {
+			return usageErrorf("--validate-schemas requires --schemas with local files")
+		}
+		if o.replay != nil {
+			o.lookups = replayLookup(o.replay)
+		}
+		if len(o.fieldSelectors.flags) > 0 {
+			return usageErrorf("--field-selector can't be used with local files")
+		}
+		if o.namespaceScope.isSet() {
+			return usageErrorf("--namespaces and --namespace-selector can't be used with local files")
+		}
+		if o.annotator != nil {
+			return usageErrorf("--annotate-drift and --remove-annotations can't be used with local files")
+		}
+		if o.emitEvents {
+			return usageErrorf("--emit-events can't be used with local files")
+		}
+		if o.publisher.isSet() {
+			return usageErrorf("--publish-results can't be used with local files")
+		}
+		if o.inputFormat == InputFormatInventory {
+			if o.CRs.Kustomize != "" {
+				return usageErrorf("--input-format %s can't be used with -k", InputFormatInventory)
+			}
+			if slices.ContainsFunc(o.CRs.Filenames, isArchive) {
+				return usageErrorf("--input-format %s can't be used with archives", InputFormatInventory)
+			}
+			o.streamedCRs, err = readInventoryCRs(o.CRs.Filenames, o.CRs.Recursive, o.IOStreams.In)
+			o.CRs.Filenames = nil
+			return err
+		}
+		var archived []streamedCR
+		o.CRs.Filenames, archived, err = readArchives(o.CRs.Filenames, o.CRs.Recursive)
+		if err != nil {
+			return err
+		}
+		o.CRs.Filenames, o.streamedCRs, err = decryptLocalCRs(o.CRs.Filenames, o.CRs.Recursive)
+		if err != nil {
+			return err
+		}
+		var flattened []streamedCR
+		o.CRs.Filenames, flattened, err = flattenLocalLists(o.CRs.Filenames, o.CRs.Recursive)
+		if err != nil {
+			return err
+		}
+		o.streamedCRs = append(o.streamedCRs, archived...)
+		for i := range o.streamedCRs {
+			o.streamedCRs[i] = o.streamedCRs[i].flattened()
+		}
+		o.streamedCRs = append(o.streamedCRs, flattened...)
+		if needsClusterFacts(o.templates) {
+			return o.bufferStdin()
+		}
 		return nil
 	}
+	if o.inputFormat == InputFormatInventory {
+		return usageErrorf("--input-format %s can only be used with local files", InputFormatInventory)
+	}
+	if o.dryRun {
+		if o.namespaceScope.isSet() {
+			return usageErrorf("--dry-run can't be used with --namespaces and --namespace-selector")
+		}
+		o.countResources = newResourceCounter(f)
+	}
+	if o.serverSideDryRun {
+		o.normalizer = newServerSideNormalizer(f)
+	}
+	if o.enableLookups {
+		o.lookups = newClusterLookup(f, o.lookupQPS)
+	}
+	if o.emitEvents {
+		if o.emitter, err = newDriftEventEmitter(f); err != nil {
+			return err
+		}
+	}
+	if o.validateSchemas && o.schemas == nil {
+		if o.schemas, err = clusterSchemas(f, o.templates); err != nil {
+			return err
+		}
+	}
+	if o.recordDir != "" {
+		if o.recorder, err = newRecorder(o.recordDir); err != nil {
+			return err
+		}
+		if err := o.recorder.recordReference(o.ref, cfs, referenceFileName); err != nil {
+			return err
+		}
+	}
 
-	return o.setLiveSearchTypes(f)
+	if err := o.setLiveSearchTypes(f); err != nil {
+		return err
+	}
+	return o.namespaceScope.resolve(f)
 }
 
 // These fields are used by the GroupCorrelator who attempts to match templates based on the following priority order:
@@ -344,29 +1230,70 @@ var defaultFieldGroups = [][][]string{
 // This function configures the following base correlators:
 //  1. ExactMatchCorrelator - Matches CRs based on pairs specifying, for each cluster CR, its matching template.
 //     The pairs are read from the diff config and provided to the correlator.
-//  2. GroupCorrelator - Matches CRs based on groups of fields that are similar in cluster resources and templates.
+//  2. PatternCorrelator - Matches CRs based on the glob patterns of the manual correlation pairs of the diff config.
+//  3. AnnotationCorrelator - Matches CRs annotated with the id of a template declaring correlateBy in its config.
+//  4. The correlators created by the factories added with WithCorrelators, in the order they were added.
+//  5. GroupCorrelator - Matches CRs based on groups of fields that are similar in cluster resources and templates.
 //
 // The base correlators are combined using a MultiCorrelator, which attempts to match a template for each base correlator
 // in the specified sequence.
 func (o *Options) setupCorrelators() error {
 	var correlators []Correlator[ReferenceTemplate]
-	if len(o.userConfig.CorrelationSettings.ManualCorrelation.CorrelationPairs) > 0 {
-		manualCorrelator, err := NewExactMatchCorrelator(o.userConfig.CorrelationSettings.ManualCorrelation.CorrelationPairs, o.templates)
+	exactPairs, patternPairs := splitCorrelationPairs(o.userConfig.CorrelationSettings.ManualCorrelation.CorrelationPairs)
+	if len(exactPairs) > 0 {
+		manualCorrelator, err := NewExactMatchCorrelator(exactPairs, o.templates)
 		if err != nil {
 			return err
 		}
 		correlators = append(correlators, manualCorrelator)
 	}
+	if len(patternPairs) > 0 {
+		patternCorrelator, err := NewPatternCorrelator(patternPairs, o.templates)
+		if err != nil {
+			return err
+		}
+		correlators = append(correlators, patternCorrelator)
+	}
+	annotationCorrelator, err := NewAnnotationCorrelator(o.templates)
+	if err != nil {
+		return err
+	}
+	if !annotationCorrelator.isEmpty() {
+		correlators = append(correlators, annotationCorrelator)
+	}
+	for i, factory := range o.correlatorFactories {
+		correlator, err := factory(o.templates)
+		if err != nil {
+			return fmt.Errorf("failed to create custom correlator %d: %w", i+1, err)
+		}
+		correlators = append(correlators, correlator)
+	}
 
-	groupCorrelator, err := NewGroupCorrelator(defaultFieldGroups, o.templates)
+	fieldGroups := o.ref.GetCorrelationFieldGroups()
+	if fieldGroups == nil {
+		fieldGroups = defaultFieldGroups
+	}
+	groupCorrelator, err := NewGroupCorrelator(fieldGroups, o.templates)
 	if err != nil {
 		return err
 	}
+	if err := groupCorrelator.ValidateTemplates(); err != nil {
+		klog.Warning(err)
+	}
 
 	correlators = append(correlators, groupCorrelator)
 
 	o.correlator = NewMultiCorrelator(correlators)
 	o.metricsTracker = NewMetricsTracker()
+	o.unavailableKinds = &unavailableKinds{}
+	o.renderFailures = &renderFailures{}
+	o.ambiguousMatches = &ambiguousMatches{}
+	if o.explain {
+		o.explanations = newExplanations()
+	}
+	if o.kinds.includes(csvKind) {
+		o.operatorVersions = newOperatorVersionTracker(o.ref.GetOperatorVersions())
+	}
 	return nil
 }
 
@@ -391,6 +1318,9 @@ func (o *Options) setupOverrideCorrelators() error {
 	if err != nil {
 		return err
 	}
+	if err := groupCorrelator.ValidateTemplates(); err != nil {
+		klog.Warning(err)
+	}
 	correlators = append(correlators, groupCorrelator)
 	o.userOverridesCorrelator = NewMultiCorrelator(correlators)
 
@@ -402,9 +1332,16 @@ func (o *Options) setupOverrideCorrelators() error {
 // types supported by the live cluster in order to not raise errors by the visitor. In a case the reference includes types that
 // are not supported by the user a warning will be created.
 func (o *Options) setLiveSearchTypes(f kcmdutil.Factory) error {
-	kindSet := make(map[string][]ReferenceTemplate)
+	typeSet := make(map[schema.GroupVersionKind][]ReferenceTemplate)
 	for _, t := range o.templates {
-		kindSet[t.GetMetadata().GetKind()] = append(kindSet[t.GetMetadata().GetKind()], t)
+		gvk := t.GetMetadata().GroupVersionKind()
+		typeSet[gvk] = append(typeSet[gvk], t)
+	}
+	if o.operatorVersions != nil {
+		// CSVs are required for checking the installed operator versions even if no template is of their kind
+		if !lo.ContainsBy(lo.Keys(typeSet), func(gvk schema.GroupVersionKind) bool { return gvk.Kind == csvKind }) {
+			typeSet[schema.GroupVersionKind{Group: csvGroup, Kind: csvKind}] = nil
+		}
 	}
 
 	c, err := f.ToDiscoveryClient()
@@ -416,10 +1353,11 @@ func (o *Options) setLiveSearchTypes(f kcmdutil.Factory) error {
 		return err
 	}
 	var notSupportedTypes []string
-	o.types, notSupportedTypes = findAllRequestedSupportedTypes(SupportedTypes, kindSet)
+	o.types, notSupportedTypes = findAllRequestedSupportedTypes(SupportedTypes, typeSet)
 	if len(o.types) == 0 {
 		return errors.New(emptyTypes)
 	}
+	o.types = orderTypesByDependencies(o.types, o.templates)
 	if len(notSupportedTypes) > 0 {
 		sort.Strings(notSupportedTypes)
 		klog.Warningf("Reference Contains Templates With Types (kind) Not Supported By Cluster: %s", strings.Join(notSupportedTypes, ", "))
@@ -437,58 +1375,74 @@ func getSupportedResourceTypes(client discovery.CachedDiscoveryInterface) (map[s
 		return resources, fmt.Errorf("failed to get clusters resource types: %w", err)
 	}
 	for _, list := range lists {
-		if len(list.APIResources) != 0 {
-			for _, res := range list.APIResources {
-				gv := schema.GroupVersion{Group: res.Group, Version: res.Version}
-				if !slices.Contains(resources[res.Kind], gv) {
-					resources[res.Kind] = append(resources[res.Kind], gv)
-				}
+		// Resources only specify their group and version when they differ from the ones of the list
+		listGV, _ := schema.ParseGroupVersion(list.GroupVersion)
+		for _, res := range list.APIResources {
+			gv := schema.GroupVersion{Group: res.Group, Version: res.Version}
+			if gv.Empty() {
+				gv = listGV
+			}
+			if !slices.Contains(resources[res.Kind], gv) {
+				resources[res.Kind] = append(resources[res.Kind], gv)
 			}
 		}
 	}
 	return resources, nil
 }
 
-func getExpectedGroups(templates []ReferenceTemplate) []schema.GroupVersion {
-	groups := make([]schema.GroupVersion, 0)
-	for _, t := range templates {
-		gvk := t.GetMetadata().GroupVersionKind()
-		gv := schema.GroupVersion{Group: gvk.Group, Version: gvk.Version}
-		if gvk.Group != "" && !slices.Contains(groups, gv) {
-			groups = append(groups, gv)
-		}
+// servedGroupVersions returns the group versions serving the kind of the requested type that should be listed: the
+// requested group version, or the other versions of its group if it isn't served. A kind served without a group is
+// listed as well in case it isn't served by the requested group, as the cluster may expose it without its group.
+// Group versions of other groups aren't listed, they serve unrelated kinds with the same name, unless the group of the
+// requested type isn't known (e.g. its apiVersion is templated). The second return value is true if the requested group
+// version isn't served as is.
+func servedGroupVersions(gvk schema.GroupVersionKind, served []schema.GroupVersion) ([]schema.GroupVersion, bool) {
+	if gvk.GroupVersion().Empty() {
+		return served, false
+	}
+	if gvk.Version != "" && slices.Contains(served, gvk.GroupVersion()) {
+		return []schema.GroupVersion{gvk.GroupVersion()}, false
+	}
+	sameGroup := lo.Filter(served, func(gv schema.GroupVersion, _ int) bool { return gv.Group == gvk.Group })
+	if len(sameGroup) > 0 {
+		return sameGroup, gvk.Version != ""
 	}
-	return groups
+	return lo.Filter(served, func(gv schema.GroupVersion, _ int) bool { return gv.Group == "" }), true
 }
 
-// findAllRequestedSupportedTypes divides the requested types in to two groups: supported types and unsupported types based on if they are specified as supported.
-// The list of supported types will include the types in the form of {kind}.{group}.
-func findAllRequestedSupportedTypes(supportedTypesWithGroups map[string][]schema.GroupVersion, requestedTypes map[string][]ReferenceTemplate) ([]string, []string) {
+// findAllRequestedSupportedTypes divides the requested types in to two groups: supported types and unsupported types
+// based on if the cluster serves their kind in their group. The list of supported types will include the types in the
+// form of {kind}.{version}.{group}.
+func findAllRequestedSupportedTypes(supportedTypesWithGroups map[string][]schema.GroupVersion, requestedTypes map[schema.GroupVersionKind][]ReferenceTemplate) ([]string, []string) {
 	var typesIncludingGroup []string
 	var notSupportedTypes []string
 	var badAPI []string
-	for kind, templates := range requestedTypes {
-		if _, ok := supportedTypesWithGroups[kind]; ok {
-			expectedGroups := getExpectedGroups(templates)
-			for _, gv := range supportedTypesWithGroups[kind] {
-				index := slices.Index(expectedGroups, gv)
-				if index > -1 {
-					expectedGroups = slices.Delete(expectedGroups, index, index+1)
-				}
-				var supported string
-				if gv.Group == "" {
-					supported = kind
-				} else {
-					supported = strings.Join([]string{kind, gv.Version, gv.Group}, ".")
-				}
-
-				typesIncludingGroup = append(typesIncludingGroup, supported)
+	for gvk := range requestedTypes {
+		served, ok := supportedTypesWithGroups[gvk.Kind]
+		if !ok {
+			if !slices.Contains(notSupportedTypes, gvk.Kind) {
+				notSupportedTypes = append(notSupportedTypes, gvk.Kind)
 			}
-			for _, gv := range expectedGroups {
-				badAPI = append(badAPI, strings.Join([]string{kind, gv.Group + "/" + gv.Version}, "."))
+			continue
+		}
+		gvs, mismatch := servedGroupVersions(gvk, served)
+		if len(gvs) == 0 {
+			notSupportedTypes = append(notSupportedTypes, strings.Join([]string{gvk.Kind, gvk.GroupVersion().String()}, "."))
+			continue
+		}
+		if mismatch && gvk.Group != "" {
+			badAPI = append(badAPI, strings.Join([]string{gvk.Kind, gvk.Group + "/" + gvk.Version}, "."))
+		}
+		for _, gv := range gvs {
+			var supported string
+			if gv.Group == "" {
+				supported = gvk.Kind
+			} else {
+				supported = strings.Join([]string{gvk.Kind, gv.Version, gv.Group}, ".")
+			}
+			if !slices.Contains(typesIncludingGroup, supported) {
+				typesIncludingGroup = append(typesIncludingGroup, supported)
 			}
-		} else {
-			notSupportedTypes = append(notSupportedTypes, kind)
 		}
 	}
 	if len(badAPI) > 0 {
@@ -497,6 +1451,8 @@ func findAllRequestedSupportedTypes(supportedTypesWithGroups map[string][]schema
 			"There may be an issue with the API resources exposed by the cluster. Found kind but missing group/version for %s ",
 			strings.Join(badAPI, ", "))
 	}
+	// Types are sorted so they're listed from the cluster in a consistent order
+	slices.Sort(typesIncludingGroup)
 	return typesIncludingGroup, notSupportedTypes
 }
 
@@ -524,29 +1480,20 @@ func countLeaf(d any) int {
 	return count
 }
 
-func countLeaves(uo *UserOverride) (int, error) {
+// countLeaves returns the number of differing fields of the merge patch and their score weighted by the weights
+func countLeaves(uo *UserOverride, weights differenceWeights) (int, int, error) {
 	var data map[string]any
 	err := json.Unmarshal([]byte(uo.Patch), &data)
 	if err != nil {
-		return 0, fmt.Errorf("failed to unmarshal internal diff: %w", err)
-	}
-	return countLeaf(data), nil
-}
-
-func findBestMatch(matches []*diffResult) *diffResult {
-	var bestLeafMatch *diffResult
-	for _, match := range matches {
-		if bestLeafMatch == nil || match.leafCount < bestLeafMatch.leafCount {
-			bestLeafMatch = match
-		}
+		return 0, 0, fmt.Errorf("failed to unmarshal internal diff: %w", err)
 	}
-	return bestLeafMatch
-
+	return countLeaf(data), weights.score(data, nil), nil
 }
 
-func getBestMatchByLines(templates []ReferenceTemplate, cr *unstructured.Unstructured, userOverrides []*UserOverride, o *Options) (*diffResult, error) {
+func getBestMatchByLines(ctx context.Context, templates []ReferenceTemplate, cr *unstructured.Unstructured, userOverrides []*UserOverride, o *Options) (*diffResult, error) {
 	matches := make([]*diffResult, 0)
 	errs := make([]error, 0)
+	failed := make(map[string]error)
 
 	for _, temp := range templates {
 		templateOverrides := make([]*UserOverride, 0)
@@ -556,14 +1503,24 @@ func getBestMatchByLines(templates []ReferenceTemplate, cr *unstructured.Unstruc
 			}
 		}
 
-		diffResult, err := diffAgainstTemplate(temp, cr, templateOverrides, o)
+		diffResult, err := diffAgainstTemplate(ctx, temp, cr, templateOverrides, o)
 		if err != nil {
+			failed[temp.GetIdentifier()] = err
+			if o.onTemplateError == TemplateErrorSkip && errors.As(err, &TemplateRenderError{}) {
+				o.renderFailures.add(temp, cr, err)
+				continue
+			}
 			errs = append(errs, err)
 			continue
 		}
 		matches = append(matches, diffResult)
 	}
-	return findBestMatch(matches), errors.Join(errs...)
+	bestMatch, tied := findBestMatch(matches, cr, o.ref.GetMatchTieBreakers())
+	if len(tied) > 0 {
+		o.ambiguousMatches.add(cr, tied)
+	}
+	o.explanations.ranked(cr, matches, failed, bestMatch, o.ref.GetMatchTieBreakers())
+	return bestMatch, errors.Join(errs...)
 
 }
 
@@ -572,8 +1529,15 @@ type diffResult struct {
 	exitError exec.ExitError
 
 	userOverride *UserOverride
+	remediation  []byte
 	temp         ReferenceTemplate
+	rendered     *unstructured.Unstructured
 	leafCount    int
+	// differenceScore is the number of differing fields weighted by the difference weights
+	differenceScore int
+	// severity is the highest severity of the differing fields
+	severity api.Severity
+	warnings []string
 }
 
 func (d diffResult) IsDiff() bool {
@@ -591,105 +1555,312 @@ func (d diffResult) DiffOutput() *bytes.Buffer {
 	return d.output
 }
 
-func diffAgainstTemplate(temp ReferenceTemplate, clusterCR *unstructured.Unstructured, userOverrides []*UserOverride, o *Options) (*diffResult, error) {
+func diffAgainstTemplate(ctx context.Context, temp ReferenceTemplate, clusterCR *unstructured.Unstructured, userOverrides []*UserOverride, o *Options) (*diffResult, error) {
 	res := &diffResult{
 		temp: temp,
 	}
 
-	localRef, err := temp.Exec(clusterCR.Object)
+	localRef, warnings, err := renderTemplate(ctx, temp, withClusterFacts(clusterCR.Object, o.clusterFacts), renderTimeout(temp, o.templateTimeout), o.lookups.lookupFunc(ctx))
 	if err != nil {
-		return res, err //nolint: wrapcheck
+		return res, err
+	}
+	res.warnings = warnings
+	if localRef, err = applyReferencePatches(o.referencePatches, temp, localRef); err != nil {
+		return res, err
+	}
+	res.warnings = append(res.warnings, o.schemas.violations(localRef, true)...)
+	if o.normalizer != nil {
+		localRef = o.normalizer.normalize(temp, localRef)
+	}
+	res.rendered = localRef
+	userFieldsToOmit, userJSONPathsToOmit := o.userConfig.fieldsToOmitFor(clusterCR)
+	ownership, err := o.fieldOwners.ownership(clusterCR)
+	if err != nil {
+		return res, err
 	}
 	obj := InfoObject{
 		injectedObjFromTemplate: localRef,
-		clusterObj:              clusterCR,
-		FieldsToOmit:            temp.GetFieldsToOmit(o.ref.GetFieldsToOmit()),
+		clusterObj:              quantityFieldsFor(o.ref, temp).normalize(localRef, withoutCorrelationAnnotation(temp, localRef, clusterCR)),
+		FieldsToOmit:            slices.Concat(temp.GetFieldsToOmit(o.ref.GetFieldsToOmit()), userFieldsToOmit),
+		jsonPathsToOmit:         slices.Concat(userJSONPathsToOmit, o.paths.ignorePaths),
+		onlyPaths:               o.paths.onlyPaths,
 		allowMerge:              temp.GetConfig().GetAllowMerge(),
 		userOverrides:           userOverrides,
 		templateFieldConf:       temp.GetConfig().GetInlineDiffFuncs(),
+		ownership:               ownership,
+		unorderedLists:          unorderedListsFor(o.ref, temp),
+		defaults:                defaultsFor(o.ref, temp, clusterCR, o.ignoreAPIDefaults),
+		subset:                  temp.GetConfig().GetComparisonMode() == ComparisonModeSubset,
 	}
 
-	differ, err := diff.NewDiffer("MERGED", "LIVE")
-	diffOutput := new(bytes.Buffer)
+	res.output, res.exitError, err = runDiffer(ctx, obj, "MERGED", "LIVE", o)
+	if err != nil {
+		return res, err
+	}
 
-	res.output = diffOutput
+	// Some extra metadata for deciding if its a good diff
+	uo, err := CreateMergePatch(temp, &obj, o.overrideReason)
+	// if user override is ok we can count the leaves in the patches
 	if err != nil {
-		return res, fmt.Errorf("failed to create diff instance: %w", err)
+		return res, err
+	}
+	res.userOverride = uo
+
+	count, score, err := countLeaves(uo, o.differenceWeights)
+	if err != nil {
+		return res, err
+	}
+	res.leafCount = count
+	res.differenceScore = score
+	if count > 0 {
+		if res.severity, err = diffSeverity(uo, o.fieldSeverities); err != nil {
+			return res, err
+		}
+	}
+	if count > 0 && o.remediations != nil {
+		if res.remediation, err = remediationPatch(&obj); err != nil {
+			return res, err
+		}
+	}
+
+	return res, nil
+}
+
+// runDiffer runs the diff program between the merged and live versions of the object. The returned exit error
+// is set in case the diff program exited with code 1 (differences were found). The diff program is killed when the
+// context is done.
+func runDiffer(ctx context.Context, obj diff.Object, from, to string, o *Options) (*bytes.Buffer, exec.ExitError, error) {
+	output, exitErr, err := runDiffEngine(ctx, obj, from, to, o)
+	if o.noDiffHeader && output != nil {
+		output = stripDiffHeader(output)
+	}
+	return output, exitErr, err
+}
+
+func runDiffEngine(ctx context.Context, obj diff.Object, from, to string, o *Options) (*bytes.Buffer, exec.ExitError, error) {
+	if o.diffEngine == DiffEngineInternal {
+		return runInternalDiffer(obj, from, to, o)
+	}
+	if o.diffStyle == DiffStyleSideBySide {
+		return runSideBySideDiffer(obj, from, to, o)
+	}
+	if o.diffProgramMissing {
+		return runUnifiedDiffer(obj, from, to, o)
+	}
+	diffOutput := new(bytes.Buffer)
+	differ, err := diff.NewDiffer(from, to)
+	if err != nil {
+		return diffOutput, nil, fmt.Errorf("failed to create diff instance: %w", err)
 	}
 	defer differ.TearDown()
 
 	err = differ.Diff(obj, diff.Printer{}, o.ShowManagedFields)
 	if err != nil {
-		return res, fmt.Errorf("error occurered during diff: %w", err)
+		return diffOutput, nil, fmt.Errorf("error occurered during diff: %w", err)
 	}
-	err = differ.Run(&diff.DiffProgram{Exec: exec.New(), IOStreams: genericiooptions.IOStreams{In: o.IOStreams.In, Out: diffOutput, ErrOut: o.IOStreams.ErrOut}})
+	var diffExec exec.Interface = contextExec{Interface: exec.New(), ctx: ctx}
+	if o.diffContext != defaultDiffContext {
+		diffExec = contextDiffExec{Interface: diffExec, context: o.diffContext}
+	}
+	err = differ.Run(&diff.DiffProgram{Exec: diffExec, IOStreams: genericiooptions.IOStreams{In: o.IOStreams.In, Out: diffOutput, ErrOut: o.IOStreams.ErrOut}})
 
 	// If the diff tool runs without issues and detects differences at this level of the code, we would like to report that there are no issues
 	var exitErr exec.ExitError
 	if ok := errors.As(err, &exitErr); ok && exitErr.ExitStatus() <= 1 {
-		res.exitError = exitErr
+		return diffOutput, exitErr, nil
 	} else if err != nil {
-		return res, fmt.Errorf("diff exited with non-zero code: %w", err)
+		return diffOutput, nil, fmt.Errorf("diff exited with non-zero code: %w", err)
 	}
+	return diffOutput, nil, nil
+}
 
-	// Some extra metadata for deciding if its a good diff
-	uo, err := CreateMergePatch(temp, &obj, o.overrideReason)
-	// if user override is ok we can count the leaves in the patches
-	if err != nil {
-		return res, err
+// Run uses the factory to parse file arguments (in case of local mode) or gather all cluster resources matching
+// templates types. For each Resource it finds the matching Resource template and
+// injects, compares, and runs against differ. When the context is done before all the resources were compared, the
+// output is printed with a partial summary and an interruptedError is returned.
+func (o *Options) Run(ctx context.Context) (err error) {
+	if o.outputCloser != nil {
+		defer func() {
+			if closeErr := o.outputCloser.Close(); closeErr != nil && err == nil {
+				err = fmt.Errorf("failed to close output file: %w", closeErr)
+			}
+		}()
+	}
+	if o.dryRun {
+		return o.estimate().Print(o.OutputFormat, o.Out)
+	}
+	if len(o.references) > 0 {
+		return o.runReferences(ctx)
+	}
+	if len(o.contexts) > 0 {
+		return o.runContexts(ctx)
+	}
+	sum, diffs, err := o.compare(ctx)
+	var interrupted interruptedError
+	if err != nil && !errors.As(err, &interrupted) {
+		return err
+	}
+	if o.recorder != nil {
+		if err := o.recorder.finish(o.clusterFacts, o.lookups); err != nil {
+			return err
+		}
 	}
-	res.userOverride = uo
 
-	count, err := countLeaves(uo)
+	switch {
+	case o.showMatchedOnly:
+		err = newComplianceOutput(o.ref, sum, diffs).Print(o.OutputFormat, o.Out)
+	case o.streamer != nil:
+		err = o.streamer.finish(sum)
+	case o.reviewer != nil:
+		sortDiffs(diffs, o.groupBy)
+		if err = o.reviewer.review(diffs); err == nil {
+			// The diffs were reviewed, only the summary is printed
+			_, err = Output{Summary: sum, Diffs: &[]DiffSum{}, color: useColor(o.color, o.Out), groupBy: o.groupBy}.Print(o.OutputFormat, o.Out, false)
+		}
+	default:
+		_, err = Output{Summary: sum, Diffs: &diffs, patches: o.newUserOverrides, color: useColor(o.color, o.Out), groupBy: o.groupBy}.Print(o.OutputFormat, o.Out, o.verboseOutput)
+	}
 	if err != nil {
-		return res, err
+		return err
+	}
+	// Explanations are written to stderr to keep the output parseable
+	if err := o.explanations.write(o.ErrOut); err != nil {
+		return err
+	}
+	if o.exportUnmatched != "" {
+		if err := exportUnmatched(o.exportUnmatched, o.metricsTracker.clone().UnMatchedCRs); err != nil {
+			return err
+		}
+	}
+	if o.generatePatches != "" {
+		if err := writeRemediations(o.generatePatches, o.patchFormat, o.remediations); err != nil {
+			return err
+		}
+	}
+	if o.generateConfig != "" {
+		if err := writeConfigSkeleton(o.generateConfig, configSkeleton{
+			userConfig: o.userConfig,
+			templates:  o.templates,
+			unmatched:  o.metricsTracker.clone().UnMatchedCRs,
+			ambiguous:  o.ambiguousMatches.summarize(),
+		}); err != nil {
+			return err
+		}
+	}
+	if o.valueExtractor != nil {
+		if err := o.valueExtractor.write(o.extractValues); err != nil {
+			return err
+		}
+	}
+	if interrupted.cause != nil {
+		// Metrics of a partial comparison would look like CRs went missing
+		return interrupted
+	}
+	if o.metricsFile != "" && sum.Interrupted == "" {
+		if err := writeMetricsFile(o.metricsFile, contextSummary{summary: sum}); err != nil {
+			return err
+		}
+	}
+	if o.publisher.isSet() && sum.Interrupted == "" {
+		if err := o.publisher.publish(o.factory, sum, diffs); err != nil {
+			return err
+		}
 	}
-	res.leafCount = count
 
-	return res, nil
+	// We will return an exit code in case there are failures according to the exit policy: differences found in
+	// specific CRs, missing CRs or unmatched CRs. As long as we're not generating a set of user overrides.
+	if o.OutputFormat != PatchYaml {
+		return o.exitPolicy.exitError(sum)
+	}
+	return nil
 }
 
-// Run uses the factory to parse file arguments (in case of local mode) or gather all cluster resources matching
-// templates types. For each Resource it finds the matching Resource template and
-// injects, compares, and runs against differ.
-func (o *Options) Run() error {
-	diffs := make([]DiffSum, 0)
+// compare compares the cluster CRs to the reference and returns the summary and the diffs of the CRs. When the
+// context is done before all the CRs were compared, a partial summary of the CRs compared until then is returned
+// along with an interruptedError.
+func (o *Options) compare(ctx context.Context) (*Summary, []DiffSum, error) {
+	diffs := newDiffCollector(o.streamer)
+	if len(o.fieldSeverities) > 0 {
+		diffs.severities = &DiffSeveritiesSummary{}
+	}
 	numDiffCRs := 0
 	numPatched := 0
+	// guards the diffs, their counts and the new user overrides, resources are visited concurrently
+	var mu sync.Mutex
+	components := templateComponents(o.ref)
+	// The comparison is stopped before all the CRs were compared once --max-diffs CRs with diffs were found, or when
+	// the memory used outgrows --max-memory
+	ctx, stop := context.WithCancelCause(ctx)
+	defer stop(nil)
+	defer watchMemory(ctx, o.maxMemory, stop)()
+	// called with mu held, once the diff of the CR is recorded
+	stopAtMaxDiffs := func() {
+		if o.maxDiffs > 0 && numDiffCRs >= o.maxDiffs {
+			stop(diffLimitReached{maxDiffs: o.maxDiffs})
+		}
+	}
 
-	r := o.builder.
-		Unstructured().
-		VisitorConcurrency(o.Concurrency).
-		AllNamespaces(true).
-		LocalParam(o.local).
-		FilenameParam(false, &o.CRs).
-		ResourceTypes(o.types...).
-		SelectAllParam(!o.local).
-		ContinueOnError().
-		Flatten().
-		Do()
-	if err := r.Err(); err != nil {
-		return fmt.Errorf("failed to collect resources: %w", err)
+	if err := o.discoverClusterFacts(ctx); err != nil {
+		return nil, nil, err
+	}
+	if err := o.runCache.useFacts(o.clusterFacts); err != nil {
+		return nil, nil, err
+	}
+	results, err := o.newResults()
+	if err != nil {
+		return nil, nil, err
 	}
-	r.IgnoreErrors(func(err error) bool {
-		if strings.Contains(err.Error(), "Object 'Kind' is missing") {
-			klog.Warningf(skipInvalidResources, extractPath(err.Error(), 3), "'Kind' is missing")
-			return true
-		}
-		if strings.Contains(err.Error(), "error parsing") {
-			klog.Warningf(skipInvalidResources, extractPath(err.Error(), 2), err.Error()[strings.LastIndex(err.Error(), ":"):])
-			return true
-		}
-		return containOnly(err, []error{UnknownMatch{}, MergeError{}, InlineDiffError{}})
-	})
 
-	err := r.Visit(func(info *resource.Info, _ error) error { // ignoring previous errors
+	progress := newProgressReporter(o.Progress, o.ErrOut, o.metricsTracker, len(o.types))
+	progress.Start()
+	visit := func(info *resource.Info, _ error) error { // ignoring previous errors
+		if ctx.Err() != nil {
+			return context.Cause(ctx)
+		}
 		clusterCRMapping, _ := runtime.DefaultUnstructuredConverter.ToUnstructured(info.Object)
 		clusterCR := &unstructured.Unstructured{Object: clusterCRMapping}
+		if err := o.recorder.recordResource(clusterCR); err != nil {
+			return err
+		}
+		if !o.kinds.includes(clusterCR.GetKind()) {
+			return nil
+		}
+		progress.addProcessed(info)
+		o.operatorVersions.add(clusterCR)
+		driftAnnotations := stripDriftAnnotations(clusterCR)
+		o.annotator.visited(info, clusterCR, driftAnnotations)
+
+		version := versionOf(clusterCR)
+		if cached, temp, ok := o.runCache.unchanged(version); ok {
+			// The CR didn't change since its result was recorded, the result is reused without rendering and diffing
+			o.explanations.correlated(clusterCR, []string{"result reused from --run-cache"})
+			o.metricsTracker.addMatch(temp)
+			o.valueExtractor.record(temp, clusterCR)
+			if cached.HasDiff {
+				progress.addDiff()
+				o.metricsTracker.addDiff(temp, cached.Diff.DiffOutput)
+				o.emitter.drifted(ctx, clusterCR, temp.GetPath(), cached.Diff.DiffOutput)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if cached.HasDiff {
+				numDiffCRs += 1
+			}
+			if cached.Diff.WasPatched() {
+				numPatched += 1
+			}
+			cached.Diff.namespace, cached.Diff.kind = clusterCR.GetNamespace(), clusterCR.GetKind()
+			diffs.add(cached.Diff)
+			stopAtMaxDiffs()
+			return nil
+		}
 
 		temps, err := o.correlator.Match(clusterCR)
+		if o.explanations != nil {
+			o.explanations.correlated(clusterCR, o.correlator.explain(clusterCR))
+		}
 		if err != nil && (!containOnly(err, []error{UnknownMatch{}}) || o.diffAll) {
-			o.metricsTracker.addUNMatch(clusterCR)
+			o.metricsTracker.addUNMatch(o.unmatchedCR(clusterCR))
 		}
 		if err != nil {
 			return err
@@ -700,26 +1871,57 @@ func (o *Options) Run() error {
 			return err //nolint: wrapcheck
 		}
 
-		bestMatch, err := getBestMatchByLines(temps, clusterCR, userOverrides, o)
+		bestMatch, err := getBestMatchByLines(ctx, temps, clusterCR, userOverrides, o)
 
 		if err != nil {
-			o.metricsTracker.addUNMatch(clusterCR)
+			o.metricsTracker.addUNMatch(o.unmatchedCR(clusterCR))
 			return err
 		}
+		if bestMatch == nil {
+			// All the templates failed to render, the failures are reported in the summary
+			return nil
+		}
 
 		o.metricsTracker.addMatch(bestMatch.temp)
+		o.valueExtractor.record(bestMatch.temp, clusterCR)
+		o.annotator.compared(info, clusterCR, driftAnnotations, bestMatch.DiffOutput().String())
 
-		if bestMatch.IsDiff() {
+		hasDiff := bestMatch.IsDiff()
+		if hasDiff {
+			mu.Lock()
 			numDiffCRs += 1
+			mu.Unlock()
+			progress.addDiff()
+			o.metricsTracker.addDiff(bestMatch.temp, bestMatch.DiffOutput().String())
+			o.remediations.add(clusterCR, bestMatch.remediation)
+			o.emitter.drifted(ctx, clusterCR, bestMatch.temp.GetPath(), bestMatch.DiffOutput().String())
+			o.reviewer.compared(clusterCR, bestMatch.userOverride)
 		}
 
 		if bestMatch.userOverride != nil && slices.Contains(o.templatesToGenerateOverridesFor, bestMatch.temp.GetPath()) {
+			mu.Lock()
 			o.newUserOverrides = append(o.newUserOverrides, bestMatch.userOverride)
+			mu.Unlock()
+		}
+
+		if o.snapshotDir != "" {
+			if err := writeSnapshotObject(o.snapshotDir, clusterCR); err != nil {
+				return err
+			}
+		}
+		snapshotDiff := ""
+		if o.snapshot != nil {
+			snapshotDiff, err = o.snapshot.Diff(ctx, clusterCR, o)
+			if err != nil {
+				return err
+			}
 		}
 
 		patched := ""
 
 		reasons := make([]string, 0)
+		mu.Lock()
+		defer mu.Unlock()
 		if len(userOverrides) > 0 {
 			patched = o.userOverridesPath
 			for _, uo := range userOverrides {
@@ -730,34 +1932,251 @@ func (o *Options) Run() error {
 			numPatched += 1
 		}
 
-		diffs = append(diffs, DiffSum{
-			DiffOutput:         bestMatch.DiffOutput().String(),
-			CorrelatedTemplate: bestMatch.temp.GetIdentifier(),
-			CRName:             apiKindNamespaceName(clusterCR),
-			Patched:            patched,
-			OverrideReasons:    reasons,
-			Description:        bestMatch.temp.GetDescription(),
-		})
+		component := components[bestMatch.temp.GetPath()]
+		diffSum := DiffSum{
+			DiffOutput:          bestMatch.DiffOutput().String(),
+			CorrelatedTemplate:  bestMatch.temp.GetIdentifier(),
+			Part:                component.part,
+			Component:           component.component,
+			CRName:              apiKindNamespaceName(clusterCR),
+			Patched:             patched,
+			OverrideReasons:     reasons,
+			Description:         bestMatch.temp.GetDescription(),
+			TemplateAnnotations: bestMatch.temp.GetConfig().GetAnnotations(),
+			SnapshotDiffOutput:  snapshotDiff,
+			Warnings:            bestMatch.warnings,
+			Severity:            bestMatch.severity,
+			namespace:           clusterCR.GetNamespace(),
+			kind:                clusterCR.GetKind(),
+		}
+		diffs.add(diffSum)
+		o.runCache.record(version, bestMatch.temp, hasDiff, diffSum)
+		stopAtMaxDiffs()
 		return err
-	})
+	}
+	// The resources are visited in the background so a stuck request or diff doesn't delay the cancellation
+	done := make(chan error, 1)
+	go func() {
+		done <- o.visitResults(ctx, results, visit)
+	}()
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+	}
+	progress.Stop()
+	if ctx.Err() != nil {
+		mu.Lock()
+		defer mu.Unlock()
+		cause := context.Cause(ctx)
+		sum := o.partialSummary(cause, numDiffCRs, numPatched)
+		sum.Warnings = sortWarnings(slices.Clone(diffs.warnings))
+		sum.Components = diffs.components.stats()
+		sum.Namespaces = diffs.namespaces.stats(o.metricsTracker.clone().UnMatchedCRs)
+		sum.DiffSeverities = diffs.severitiesSummary()
+		found := slices.Clone(diffs.diffs)
+		if !o.local {
+			found = orderByTypes(found, o.types)
+		}
+		if errors.As(cause, &diffLimitReached{}) {
+			// Stopping at the limit isn't a failure of the comparison, the diffs found fail the command as usual
+			return sum, found, nil
+		}
+		return sum, found, interruptedError{cause: cause}
+	}
 	if err != nil {
-		return fmt.Errorf("error occurred while trying to process resources: %w", err)
+		return nil, nil, fmt.Errorf("error occurred while trying to process resources: %w", err)
 	}
 
-	sum := newSummary(o.ref, o.metricsTracker, numDiffCRs, o.templates, numPatched)
+	sum := newSummary(o.ref, o.metricsTracker, numDiffCRs, o.metadataHash, numPatched)
+	sum.filterValidationIssues(o.excludedTemplates)
+	sum.NotApplicable = o.notApplicable
+	sum.filterValidationIssues(notApplicableTemplates(o.notApplicable))
+	var unavailableTemplates map[string]bool
+	sum.UnavailableKinds, unavailableTemplates = o.unavailableKinds.summarize(o.templates)
+	sum.filterValidationIssues(unavailableTemplates)
+	sum.RenderFailures = o.renderFailures.summarize()
+	sum.filterValidationIssues(renderFailedTemplates(sum.RenderFailures))
+	sum.AmbiguousMatches = o.ambiguousMatches.summarize()
+	sum.CountMismatches = countMismatches(o.templates, o.metricsTracker.MatchedTemplatesNames, unavailableTemplates,
+		renderFailedTemplates(sum.RenderFailures))
+	if o.snapshot != nil {
+		sum.Snapshot = o.snapshot.Summarize(diffs.numChanged)
+	}
+	sum.OperatorVersions = o.operatorVersions.Summarize()
+	sum.Warnings = sortWarnings(diffs.warnings)
+	sum.Components = diffs.components.stats()
+	sum.Namespaces = diffs.namespaces.stats(o.metricsTracker.UnMatchedCRs)
+	sum.DiffSeverities = diffs.severitiesSummary()
+	sum.DriftAnnotations = o.annotator.summarize()
+	sum.DriftEvents = o.emitter.summarize()
+	sum.UnchangedCRs = o.runCache.numReused()
+	// The cache is only replaced after a complete run, a partial one would drop the CRs that weren't compared yet
+	if err := o.runCache.write(); err != nil {
+		return nil, nil, err
+	}
+	if !o.local {
+		return sum, orderByTypes(diffs.diffs, o.types), nil
+	}
+	return sum, diffs.diffs, nil
+}
 
-	_, err = Output{Summary: sum, Diffs: &diffs, patches: o.newUserOverrides}.Print(o.OutputFormat, o.Out, o.verboseOutput)
-	if err != nil {
-		return err
+// partialSummary returns the summary of the CRs compared before the comparison was interrupted. Missing CRs and
+// validation issues aren't reported since the CRs that weren't compared yet can't be told apart from missing ones,
+// for the same reason the snapshot and operator versions are left out.
+func (o *Options) partialSummary(cause error, numDiffCRs, numPatched int) *Summary {
+	// CRs that were being compared when the comparison was interrupted may still be recorded in the background
+	sum := newSummary(o.ref, o.metricsTracker.clone(), numDiffCRs, o.metadataHash, numPatched)
+	sum.ValidationIssues = make(map[string]map[string]ValidationIssue)
+	sum.NumMissing = 0
+	sum.NotApplicable = o.notApplicable
+	sum.UnavailableKinds, _ = o.unavailableKinds.summarize(o.templates)
+	sum.RenderFailures = o.renderFailures.summarize()
+	sum.AmbiguousMatches = o.ambiguousMatches.summarize()
+	sum.DriftAnnotations = o.annotator.summarize()
+	sum.DriftEvents = o.emitter.summarize()
+	sum.UnchangedCRs = o.runCache.numReused()
+	sum.Interrupted = cause.Error()
+	return sum
+}
+
+// newResult creates a result for visiting the resources of the given types (or the local files in local mode) that
+// match the field selector, in the namespace or across the cluster if it's empty. Errors of resources that should be
+// skipped without failing the run are ignored.
+func (o *Options) newResult(types []string, fieldSelector, namespace string) (*resource.Result, error) {
+	// Resources passed on stdin are read from the input stream of the command instead of the builder reading os.Stdin
+	crs := o.CRs
+	crs.Filenames = slices.DeleteFunc(slices.Clone(o.CRs.Filenames), func(f string) bool { return f == stdinFilename })
+	b := o.newBuilder().
+		Unstructured().
+		VisitorConcurrency(o.Concurrency).
+		NamespaceParam(namespace).
+		AllNamespaces(namespace == "").
+		LocalParam(o.local).
+		FilenameParam(false, &crs)
+	if len(crs.Filenames) != len(o.CRs.Filenames) {
+		stdin := streamedCR{name: "STDIN", open: func() (io.ReadCloser, error) { return io.NopCloser(o.IOStreams.In), nil }}
+		b = b.Stream(stdin.flattened().reader(), "STDIN")
+	}
+	for _, cr := range o.streamedCRs {
+		b = b.Stream(cr.reader(), cr.name)
+	}
+	r := b.ResourceTypes(types...).
+		RequestChunksOf(o.chunkSize).
+		SelectAllParam(!o.local && fieldSelector == "").
+		FieldSelectorParam(fieldSelector).
+		ContinueOnError().
+		Flatten().
+		Do()
+	if err := r.Err(); err != nil {
+		return nil, fmt.Errorf("failed to collect resources: %w", err)
 	}
+	r.IgnoreErrors(func(err error) bool {
+		if strings.Contains(err.Error(), "Object 'Kind' is missing") {
+			klog.Warningf(skipInvalidResources, extractPath(err.Error(), 3), "'Kind' is missing")
+			return true
+		}
+		if strings.Contains(err.Error(), "error parsing") {
+			klog.Warningf(skipInvalidResources, extractPath(err.Error(), 2), err.Error()[strings.LastIndex(err.Error(), ":"):])
+			return true
+		}
+		return containOnly(err, []error{UnknownMatch{}, MergeError{}, InlineDiffError{}})
+	})
+	return r, nil
+}
+
+// typeResult is the result for visiting the resources of a type, the type is empty in local mode where a single
+// result is used for all the resources
+type typeResult struct {
+	resourceType  string
+	fieldSelector string
+	namespace     string
+	result        *resource.Result
+}
 
-	// We will return exit code 1 in case there are differences between the reference CRs and cluster CRs.
-	// The differences can be differences found in specific CRs or any validation issues.
-	// As long as we're not generating a set of user overrides.
-	if (numDiffCRs != 0 || len(sum.ValidationIssues) != 0) && o.OutputFormat != PatchYaml {
-		return exec.CodeExitError{Err: errors.New(DiffsFoundMsg), Code: 1}
+// newResults creates the results for visiting all the resources that should be compared. In live mode a result is
+// created for each type so types that can't be listed can be retried, or recorded and skipped without failing the run.
+// Types with field selectors get a result for every selector.
+func (o *Options) newResults() ([]typeResult, error) {
+	if o.local {
+		r, err := o.newResult(o.types, "", "")
+		if err != nil {
+			return nil, err
+		}
+		return []typeResult{{result: r}}, nil
+	}
+	results := make([]typeResult, 0, len(o.types))
+	for _, t := range o.types {
+		namespaces, err := o.namespaceScope.namespacesOf(o.factory, t)
+		if err != nil {
+			return nil, err
+		}
+		for _, selector := range o.fieldSelectors.forType(t, o.templates, o.diffAll) {
+			for _, namespace := range namespaces {
+				r, err := o.newResult([]string{t}, selector, namespace)
+				if err != nil {
+					return nil, err
+				}
+				results = append(results, typeResult{resourceType: t, fieldSelector: selector, namespace: namespace, result: r})
+			}
+		}
+	}
+	return results, nil
+}
+
+// visitWithRetries visits the resources of a type. Listing the type is retried with an exponential backoff when it
+// fails with a transient error before any of its resources were visited, so no resource is compared twice. Types
+// that still can't be listed are recorded as unavailable and skipped. Waiting before a retry ends when the context is
+// done.
+func (o *Options) visitWithRetries(ctx context.Context, r typeResult, fn resource.VisitorFunc) error {
+	if r.resourceType == "" {
+		return r.result.Visit(fn)
+	}
+	result := r.result
+	for attempt := 1; ; attempt++ {
+		visited := false
+		var retryErr error
+		result.IgnoreErrors(func(err error) bool {
+			if o.namespaceScope.isSet() && o.unavailableKinds.addForbidden(r.resourceType, err) {
+				return true
+			}
+			if unavailableReason(err) == "" {
+				return false
+			}
+			if !visited && attempt <= o.retries {
+				retryErr = err
+				return true
+			}
+			return o.unavailableKinds.add(r.resourceType, err)
+		})
+		err := result.Visit(func(info *resource.Info, err error) error {
+			visited = true
+			return fn(info, err)
+		})
+		if retryErr == nil {
+			return err
+		}
+		delay := o.retryDelay(attempt, retryErr)
+		klog.Warningf("Failed to list %s (attempt %d of %d), retrying in %s: %s",
+			r.resourceType, attempt, o.retries+1, delay, retryErr)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		if result, err = o.newResult([]string{r.resourceType}, r.fieldSelector, r.namespace); err != nil {
+			return err
+		}
 	}
-	return nil
+}
+
+// retryDelay returns the time to wait before retrying after the given attempt, the delay suggested by the server is
+// used if it's longer
+func (o *Options) retryDelay(attempt int, err error) time.Duration {
+	delay := o.retryInterval << (attempt - 1)
+	if seconds, ok := apierrors.SuggestsClientDelay(err); ok && time.Duration(seconds)*time.Second > delay {
+		delay = time.Duration(seconds) * time.Second
+	}
+	return delay
 }
 
 // InfoObject matches the diff.Object interface, it contains the objects that shall be compared.
@@ -765,15 +2184,46 @@ type InfoObject struct {
 	injectedObjFromTemplate *unstructured.Unstructured
 	clusterObj              *unstructured.Unstructured
 	FieldsToOmit            []*ManifestPathV1
+	jsonPathsToOmit         [][]jsonPathSegment
 	allowMerge              bool
 	userOverrides           []*UserOverride
 	templateFieldConf       map[string]inlineDiffType
+	ownership               *fieldOwnership
+	unorderedLists          [][]jsonPathSegment
+	onlyPaths               [][]jsonPathSegment
+	// defaults are the default values of the fields of the cluster object not compared when the template doesn't set
+	// them, they're compared when the template is merged with the cluster object
+	defaults []fieldDefault
+	// subset only keeps the fields of the cluster object set by the template
+	subset bool
 }
 
 // Live Returns the cluster version of the object
 func (obj InfoObject) Live() runtime.Object {
 	omitFields(obj.clusterObj.Object, obj.FieldsToOmit)
-	return obj.clusterObj
+	omitJSONPathFields(obj.clusterObj.Object, obj.jsonPathsToOmit)
+	omitsDefaults := len(obj.defaults) > 0 && !obj.allowMerge
+	if obj.ownership == nil && len(obj.unorderedLists) == 0 && len(obj.onlyPaths) == 0 && !obj.subset && !omitsDefaults {
+		return obj.clusterObj
+	}
+	// The cluster object is shared by the templates it's compared to, its managed fields, unowned fields, fields out
+	// of --only-path, fields the template doesn't set, fields holding their default value and the order of its lists
+	// are still needed to render and merge them
+	live := obj.clusterObj.DeepCopy()
+	if omitsDefaults {
+		omitDefaults(live.Object, obj.injectedObjFromTemplate.Object, obj.defaults)
+	}
+	if obj.subset {
+		if pruned, ok := pruneToShape(live.Object, obj.injectedObjFromTemplate.Object).(map[string]any); ok {
+			live.Object = pruned
+		}
+	}
+	obj.ownership.apply(live.Object)
+	if len(obj.onlyPaths) > 0 {
+		live.Object = keepJSONPathFields(live.Object, obj.onlyPaths)
+	}
+	sortUnorderedLists(live.Object, obj.unorderedLists)
+	return live
 }
 
 type MergeError struct {
@@ -807,6 +2257,12 @@ func (obj InfoObject) Merged() (runtime.Object, error) {
 		return obj.injectedObjFromTemplate, &InlineDiffError{obj: &obj, err: err}
 	}
 	omitFields(obj.injectedObjFromTemplate.Object, obj.FieldsToOmit)
+	omitJSONPathFields(obj.injectedObjFromTemplate.Object, obj.jsonPathsToOmit)
+	obj.ownership.apply(obj.injectedObjFromTemplate.Object)
+	if len(obj.onlyPaths) > 0 {
+		obj.injectedObjFromTemplate = &unstructured.Unstructured{Object: keepJSONPathFields(obj.injectedObjFromTemplate.Object, obj.onlyPaths)}
+	}
+	sortUnorderedLists(obj.injectedObjFromTemplate.Object, obj.unorderedLists)
 	return obj.injectedObjFromTemplate, err
 }
 
@@ -862,6 +2318,10 @@ func (obj InfoObject) runInlineDiffFuncs() error {
 func findFieldPaths(object map[string]any, fields []*ManifestPathV1) [][]string {
 	result := make([][]string, 0)
 	for _, f := range fields {
+		if f.segments != nil {
+			// Paths in the JSONPath syntax are omitted by omitJSONPathFields
+			continue
+		}
 		if !f.IsPrefix {
 			result = append(result, f.parts)
 		} else {
@@ -885,6 +2345,13 @@ func findFieldPaths(object map[string]any, fields []*ManifestPathV1) [][]string
 }
 
 func omitFields(object map[string]any, fields []*ManifestPathV1) {
+	var jsonPaths [][]jsonPathSegment
+	for _, f := range fields {
+		if f.segments != nil {
+			jsonPaths = append(jsonPaths, f.segments)
+		}
+	}
+	omitJSONPathFields(object, jsonPaths)
 	fieldPaths := findFieldPaths(object, fields)
 
 	for _, field := range fieldPaths {
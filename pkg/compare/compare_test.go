@@ -4,6 +4,8 @@ package compare
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
@@ -17,16 +19,29 @@ import (
 	"runtime/debug"
 	"slices"
 	"strings"
+	"sync"
 	"testing"
 
+	"github.com/openshift/kube-compare/pkg/api"
 	"github.com/openshift/kube-compare/pkg/testutils"
 	"github.com/samber/lo"
 	"github.com/spf13/cobra"
 	"github.com/stretchr/testify/require"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/apimachinery/pkg/version"
 	"k8s.io/cli-runtime/pkg/genericiooptions"
 	"k8s.io/cli-runtime/pkg/resource"
+	"k8s.io/client-go/discovery"
+	fakedynamic "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/openapi"
+	"k8s.io/client-go/openapi/openapitest"
 	"k8s.io/client-go/rest/fake"
 	"k8s.io/klog/v2"
 	cmdtesting "k8s.io/kubectl/pkg/cmd/testing"
@@ -45,6 +60,7 @@ const ResourceDirName = "resources"
 
 var userConfigFileName = "userconfig.yaml"
 var defaultConcurrency = "4"
+var defaultRetryInterval = "1ms"
 
 type checkType string
 
@@ -130,6 +146,9 @@ type CRSource string
 const (
 	Local CRSource = "local"
 	Live  CRSource = "live"
+	Stdin CRSource = "stdin"
+	// Replay records the comparison of the live resources, then replays it with --replay
+	Replay CRSource = "replay"
 )
 
 type RefType string
@@ -191,6 +210,60 @@ type Test struct {
 	userOverridePath   string
 	templToGenPatchFor []string
 	overrideGenReason  string
+
+	compareToSnapshot   string
+	includeKinds        []string
+	excludeKinds        []string
+	components          []string
+	skipComponents      []string
+	fieldManagers       []string
+	ignoreFieldManagers []string
+	listErrors          map[string]listError
+	retries             string
+	fetchConcurrency    string
+	verifySignature     string
+	contexts            []string
+	showMatchedOnly     bool
+	diffEngine          string
+	diffStyle           string
+	diffContext         string
+	noDiffHeader        bool
+	color               string
+	dryRun              bool
+	cancelled           bool
+	sopsBinary          string
+	serverSideDryRun    bool
+	correlators         []CorrelatorFactory
+	extraReferences     []string
+	inputFormat         string
+	onTemplateError     string
+	onlyPaths           []string
+	ignorePaths         []string
+	exitPolicyFlags     map[string]string
+	diffLimitFlags      map[string]string
+	stream              bool
+	archive             string
+	enableLookups       bool
+	driftAnnotations    string
+	emitEvents          bool
+	fieldSelectors      []string
+	referencePatches    []string
+	outputToFile        bool
+	namespaces          []string
+	namespaceSelector   string
+	ignoreAPIDefaults   bool
+	noDiffProgram       bool
+	groupBy             string
+	validateSchemas     bool
+	schemas             string
+	reviewInput         string
+}
+
+// listError is an error returned when listing a kind in live mode, the error is returned for the first times
+// requests or for all the requests when times is 0
+type listError struct {
+	err   *apierrors.StatusError
+	times int
 }
 
 func (test *Test) getTestDir() string {
@@ -216,6 +289,52 @@ func (test Test) Clone() Test {
 		referenceFileName:     test.referenceFileName,
 		badAPIResources:       test.badAPIResources,
 		envVar:                maps.Clone(test.envVar),
+		compareToSnapshot:     test.compareToSnapshot,
+		includeKinds:          slices.Clone(test.includeKinds),
+		excludeKinds:          slices.Clone(test.excludeKinds),
+		components:            slices.Clone(test.components),
+		skipComponents:        slices.Clone(test.skipComponents),
+		fieldManagers:         slices.Clone(test.fieldManagers),
+		ignoreFieldManagers:   slices.Clone(test.ignoreFieldManagers),
+		listErrors:            maps.Clone(test.listErrors),
+		retries:               test.retries,
+		fetchConcurrency:      test.fetchConcurrency,
+		verifySignature:       test.verifySignature,
+		contexts:              slices.Clone(test.contexts),
+		showMatchedOnly:       test.showMatchedOnly,
+		diffEngine:            test.diffEngine,
+		diffStyle:             test.diffStyle,
+		diffContext:           test.diffContext,
+		noDiffHeader:          test.noDiffHeader,
+		color:                 test.color,
+		dryRun:                test.dryRun,
+		cancelled:             test.cancelled,
+		sopsBinary:            test.sopsBinary,
+		serverSideDryRun:      test.serverSideDryRun,
+		correlators:           slices.Clone(test.correlators),
+		extraReferences:       slices.Clone(test.extraReferences),
+		inputFormat:           test.inputFormat,
+		onTemplateError:       test.onTemplateError,
+		onlyPaths:             slices.Clone(test.onlyPaths),
+		ignorePaths:           slices.Clone(test.ignorePaths),
+		exitPolicyFlags:       maps.Clone(test.exitPolicyFlags),
+		diffLimitFlags:        maps.Clone(test.diffLimitFlags),
+		stream:                test.stream,
+		archive:               test.archive,
+		enableLookups:         test.enableLookups,
+		driftAnnotations:      test.driftAnnotations,
+		emitEvents:            test.emitEvents,
+		fieldSelectors:        slices.Clone(test.fieldSelectors),
+		referencePatches:      slices.Clone(test.referencePatches),
+		outputToFile:          test.outputToFile,
+		namespaces:            slices.Clone(test.namespaces),
+		namespaceSelector:     test.namespaceSelector,
+		ignoreAPIDefaults:     test.ignoreAPIDefaults,
+		noDiffProgram:         test.noDiffProgram,
+		groupBy:               test.groupBy,
+		validateSchemas:       test.validateSchemas,
+		schemas:               test.schemas,
+		reviewInput:           test.reviewInput,
 	}
 }
 
@@ -297,6 +416,311 @@ func (test Test) withBadAPIResources() Test {
 	return newTest
 }
 
+func (test Test) withCompareToSnapshot(snapshotDir string) Test {
+	newTest := test.Clone()
+	newTest.compareToSnapshot = snapshotDir
+	return newTest
+}
+
+func (test Test) withIncludeKinds(kinds ...string) Test {
+	newTest := test.Clone()
+	newTest.includeKinds = append(newTest.includeKinds, kinds...)
+	return newTest
+}
+
+func (test Test) withExcludeKinds(kinds ...string) Test {
+	newTest := test.Clone()
+	newTest.excludeKinds = append(newTest.excludeKinds, kinds...)
+	return newTest
+}
+
+func (test Test) withComponents(components ...string) Test {
+	newTest := test.Clone()
+	newTest.components = append(newTest.components, components...)
+	return newTest
+}
+
+func (test Test) withSkipComponents(components ...string) Test {
+	newTest := test.Clone()
+	newTest.skipComponents = append(newTest.skipComponents, components...)
+	return newTest
+}
+
+func (test Test) withFieldManagers(managers ...string) Test {
+	newTest := test.Clone()
+	newTest.fieldManagers = append(newTest.fieldManagers, managers...)
+	return newTest
+}
+
+func (test Test) withIgnoreFieldManagers(managers ...string) Test {
+	newTest := test.Clone()
+	newTest.ignoreFieldManagers = append(newTest.ignoreFieldManagers, managers...)
+	return newTest
+}
+
+// withListError makes listing the kind in live mode fail with the error
+func (test Test) withListError(kind string, err *apierrors.StatusError) Test {
+	return test.withTransientListError(kind, err, 0)
+}
+
+// withTransientListError makes listing the kind in live mode fail with the error for the first times requests
+func (test Test) withTransientListError(kind string, err *apierrors.StatusError, times int) Test {
+	newTest := test.Clone()
+	if newTest.listErrors == nil {
+		newTest.listErrors = make(map[string]listError)
+	}
+	newTest.listErrors[kind] = listError{err: err, times: times}
+	return newTest
+}
+
+func (test Test) withVerifySignature(signature string) Test {
+	newTest := test.Clone()
+	newTest.verifySignature = signature
+	return newTest
+}
+
+func (test Test) withRetries(retries string) Test {
+	newTest := test.Clone()
+	newTest.retries = retries
+	return newTest
+}
+
+func (test Test) withFetchConcurrency(concurrency string) Test {
+	newTest := test.Clone()
+	newTest.fetchConcurrency = concurrency
+	return newTest
+}
+
+// withContexts compares the live cluster once per context, all the contexts except "missing" resolve to the same
+// test cluster
+func (test Test) withContexts(contexts ...string) Test {
+	newTest := test.Clone()
+	newTest.contexts = append(newTest.contexts, contexts...)
+	return newTest
+}
+
+func (test Test) withShowMatchedOnly() Test {
+	newTest := test.Clone()
+	newTest.showMatchedOnly = true
+	return newTest
+}
+
+func (test Test) withDiffEngine(engine string) Test {
+	newTest := test.Clone()
+	newTest.diffEngine = engine
+	return newTest
+}
+
+func (test Test) withDiffStyle(style string) Test {
+	newTest := test.Clone()
+	newTest.diffStyle = style
+	return newTest
+}
+
+func (test Test) withDiffContext(lines string) Test {
+	newTest := test.Clone()
+	newTest.diffContext = lines
+	return newTest
+}
+
+func (test Test) withoutDiffHeader() Test {
+	newTest := test.Clone()
+	newTest.noDiffHeader = true
+	return newTest
+}
+
+func (test Test) withColor(mode string) Test {
+	newTest := test.Clone()
+	newTest.color = mode
+	return newTest
+}
+
+func (test Test) withDryRun() Test {
+	newTest := test.Clone()
+	newTest.dryRun = true
+	return newTest
+}
+
+// withExtraReferences compares to the reference config files of the test reference directory in addition to the
+// test reference, only supported with local references
+func (test Test) withExtraReferences(referenceFileNames ...string) Test {
+	newTest := test.Clone()
+	newTest.extraReferences = referenceFileNames
+	return newTest
+}
+
+func (test Test) withSopsBinary(binary string) Test {
+	newTest := test.Clone()
+	newTest.sopsBinary = binary
+	return newTest
+}
+
+func (test Test) withServerSideDryRun() Test {
+	newTest := test.Clone()
+	newTest.serverSideDryRun = true
+	return newTest
+}
+
+func (test Test) withInputFormat(format string) Test {
+	newTest := test.Clone()
+	newTest.inputFormat = format
+	return newTest
+}
+
+func (test Test) withOnTemplateError(policy string) Test {
+	newTest := test.Clone()
+	newTest.onTemplateError = policy
+	return newTest
+}
+
+func (test Test) withLookups() Test {
+	newTest := test.Clone()
+	newTest.enableLookups = true
+	return newTest
+}
+
+// withDriftAnnotations sets the flag writing the drift annotations, annotate-drift or remove-annotations
+func (test Test) withDriftAnnotations(flag string) Test {
+	newTest := test.Clone()
+	newTest.driftAnnotations = flag
+	return newTest
+}
+
+func (test Test) withEmitEvents() Test {
+	newTest := test.Clone()
+	newTest.emitEvents = true
+	return newTest
+}
+
+func (test Test) withExitPolicy(flags map[string]string) Test {
+	newTest := test.Clone()
+	newTest.exitPolicyFlags = flags
+	return newTest
+}
+
+// withDiffLimit sets --max-diffs or --fail-fast, the CRs are then compared one at a time so the CRs compared before
+// stopping are always the same
+func (test Test) withDiffLimit(flags map[string]string) Test {
+	newTest := test.Clone()
+	newTest.diffLimitFlags = flags
+	return newTest
+}
+
+// withStream sets --stream, the CRs are then compared one at a time so the diffs are always streamed in the same order
+func (test Test) withStream() Test {
+	newTest := test.Clone()
+	newTest.stream = true
+	return newTest
+}
+
+// withArchive passes the archive of the test dir to -f instead of the resources dir in local mode
+func (test Test) withArchive(name string) Test {
+	newTest := test.Clone()
+	newTest.archive = name
+	return newTest
+}
+
+func (test Test) withOnlyPaths(paths ...string) Test {
+	newTest := test.Clone()
+	newTest.onlyPaths = paths
+	return newTest
+}
+
+func (test Test) withIgnorePaths(paths ...string) Test {
+	newTest := test.Clone()
+	newTest.ignorePaths = paths
+	return newTest
+}
+
+func (test Test) withFieldSelectors(selectors ...string) Test {
+	newTest := test.Clone()
+	newTest.fieldSelectors = selectors
+	return newTest
+}
+
+func (test Test) withReferencePatches(paths ...string) Test {
+	newTest := test.Clone()
+	newTest.referencePatches = paths
+	return newTest
+}
+
+func (test Test) withNamespaces(namespaces ...string) Test {
+	newTest := test.Clone()
+	newTest.namespaces = namespaces
+	return newTest
+}
+
+func (test Test) withNamespaceSelector(selector string) Test {
+	newTest := test.Clone()
+	newTest.namespaceSelector = selector
+	return newTest
+}
+
+func (test Test) withGroupBy(groupBy string) Test {
+	newTest := test.Clone()
+	newTest.groupBy = groupBy
+	return newTest
+}
+
+// withValidateSchemas validates the rendered templates against the OpenAPI schemas served by the test cluster, the
+// schemas of the core, apps and batch group versions
+func (test Test) withValidateSchemas() Test {
+	newTest := test.Clone()
+	newTest.validateSchemas = true
+	return newTest
+}
+
+func (test Test) withSchemas(schemas string) Test {
+	newTest := test.Clone()
+	newTest.schemas = schemas
+	return newTest
+}
+
+// withReview reviews the CRs with diffs with --review, the commands of the review are read from the input
+func (test Test) withReview(input string) Test {
+	newTest := test.Clone()
+	newTest.reviewInput = input
+	return newTest
+}
+
+func (test Test) withIgnoreAPIDefaults() Test {
+	newTest := test.Clone()
+	newTest.ignoreAPIDefaults = true
+	return newTest
+}
+
+// withoutDiffProgram runs the test as if diff wasn't installed
+func (test Test) withoutDiffProgram() Test {
+	newTest := test.Clone()
+	newTest.noDiffProgram = true
+	return newTest
+}
+
+// withOutputToFile writes the output with --output-file, the content of the file is checked after the stdout output
+func (test Test) withOutputToFile() Test {
+	newTest := test.Clone()
+	newTest.outputToFile = true
+	return newTest
+}
+
+func (test Test) withCorrelators(factories ...CorrelatorFactory) Test {
+	newTest := test.Clone()
+	newTest.correlators = append(newTest.correlators, factories...)
+	return newTest
+}
+
+func (test Test) withCancelledContext() Test {
+	newTest := test.Clone()
+	newTest.cancelled = true
+	return newTest
+}
+
+func (test Test) withSubTestWithChecks(subName string) Test {
+	squashed := strings.ReplaceAll(subName, " ", "_")
+	return test.withSubTestSuffix(subName).
+		withChecks(test.checks.withPrefixedSuffix("_" + squashed + "_"))
+}
+
 func (test Test) withSubTestWithMetadata(subName string) Test {
 	squashed := strings.ReplaceAll(subName, " ", "_")
 	return test.withSubTestSuffix(subName).
@@ -361,6 +785,9 @@ func TestCompareRun(t *testing.T) {
 			withModes([]Mode{{Live, LocalRef}}),
 		defaultTest("Two Templates With Same apiVersion Kind Name Namespace"),
 		defaultTest("Two Templates With Same Kind Namespace"),
+		defaultTest("Two Templates With Same Kind Namespace").
+			withSubTestWithChecks("Custom Correlator").
+			withCorrelators(templatePathCorrelator("apps.v1.DaemonSet.kube-system.kindnet2.yaml")),
 		defaultTest("User Config Doesnt Exist").
 			withUserConfig(userConfigFileName).
 			withChecks(Checks{Out: defaultCheckOut,
@@ -389,6 +816,27 @@ func TestCompareRun(t *testing.T) {
 		defaultTest("Custom Fields To Omit Ref Entry Not Found"),
 		defaultTest("When Using Diff All Flag - All Unmatched Resources Appear In Summary").
 			diffAll(),
+		defaultTest("User Config Fields To Omit").
+			withModes([]Mode{{Live, LocalRef}, {Local, LocalRef}}).
+			withUserConfig(userConfigFileName),
+		defaultTest("User Config Fields To Omit Isnt Valid").
+			withUserConfig(userConfigFileName),
+		defaultTest("Multiple Contexts").
+			withModes([]Mode{{Live, LocalRef}}).
+			withContexts("cluster-a", "cluster-b"),
+		defaultTest("Multiple Contexts").
+			withModes([]Mode{{Live, LocalRef}}).
+			withSubTestWithChecks("JSON").
+			withOutputFormat(Json).
+			withContexts("cluster-a", "cluster-b"),
+		defaultTest("Multiple Contexts").
+			withModes([]Mode{{Live, LocalRef}}).
+			withSubTestWithChecks("Missing Context").
+			withContexts("cluster-a", "missing"),
+		defaultTest("Multiple Contexts").
+			withModes([]Mode{{Local, LocalRef}}).
+			withSubTestWithChecks("Local Files").
+			withContexts("cluster-a"),
 		defaultTest("Manual Correlation Matches Are Prioritized Over Group Correlation").
 			withModes([]Mode{{Live, LocalRef}, {Local, LocalRef}}).
 			withUserConfig(userConfigFileName),
@@ -403,8 +851,232 @@ func TestCompareRun(t *testing.T) {
 		defaultTest("Ref Template In Sub Dir Works With Manual Correlation").
 			withModes([]Mode{{Live, LocalRef}, {Local, LocalRef}, {Local, URL}}).
 			withUserConfig(userConfigFileName),
+		defaultTest("Template Partials").
+			withModes([]Mode{{Live, LocalRef}, {Local, LocalRef}}),
+		defaultTest("Template Partials").
+			withSubTestWithMetadata("undefined"),
+		defaultTest("Template Partials").
+			withSubTestWithMetadata("recursive"),
 		defaultTest("Ref With Template Functions Renders As Expected").
 			withModes([]Mode{{Live, LocalRef}, {Local, LocalRef}, {Local, URL}}),
+		defaultTest("Show Matched Only").
+			withModes([]Mode{{Live, LocalRef}, {Local, LocalRef}}).
+			withShowMatchedOnly(),
+		defaultTest("Show Matched Only").
+			withSubTestWithChecks("JSON").
+			withOutputFormat(Json).
+			withShowMatchedOnly(),
+		defaultTest("Show Matched Only").
+			withSubTestWithChecks("Diffs").
+			withVerboseOutput(),
+		defaultTest("Internal Diff Engine").
+			withModes([]Mode{{Live, LocalRef}, {Local, LocalRef}}).
+			withDiffEngine(DiffEngineInternal),
+		defaultTest("Internal Diff Engine").
+			withSubTestWithChecks("Invalid").
+			withDiffEngine("colordiff"),
+		defaultTest("Side By Side Diff").
+			withModes([]Mode{{Live, LocalRef}, {Local, LocalRef}}).
+			withDiffStyle(DiffStyleSideBySide),
+		defaultTest("Side By Side Diff").
+			withSubTestWithChecks("Invalid").
+			withDiffStyle("columns"),
+		defaultTest("Side By Side Diff").
+			withSubTestWithChecks("Internal Diff Engine").
+			withDiffStyle(DiffStyleSideBySide).
+			withDiffEngine(DiffEngineInternal),
+		defaultTest("Diff Context").
+			withModes([]Mode{{Live, LocalRef}, {Local, LocalRef}}).
+			withDiffContext("1"),
+		defaultTest("Diff Context").
+			withSubTestWithChecks("No Header").
+			withDiffContext("0").
+			withoutDiffHeader(),
+		defaultTest("Diff Context").
+			withSubTestWithChecks("No Diff Program").
+			withDiffContext("1").
+			withoutDiffProgram(),
+		defaultTest("Diff Context").
+			withSubTestWithChecks("Side By Side").
+			withDiffStyle(DiffStyleSideBySide).
+			withDiffContext("1"),
+		defaultTest("Diff Context").
+			withSubTestWithChecks("Internal Diff Engine").
+			withDiffEngine(DiffEngineInternal).
+			withDiffContext("1"),
+		defaultTest("Diff Context").
+			withSubTestWithChecks("Negative").
+			withDiffContext("-2"),
+		defaultTest("Colored Output").
+			withColor(ColorAlways),
+		defaultTest("Colored Output").
+			withSubTestWithChecks("Internal Diff Engine").
+			withColor(ColorAlways).
+			withDiffEngine(DiffEngineInternal),
+		defaultTest("Colored Output").
+			withSubTestWithChecks("Auto").
+			withColor(ColorAuto),
+		defaultTest("Correlation Field Groups").
+			withModes([]Mode{{Live, LocalRef}, {Local, LocalRef}}),
+		defaultTest("Correlation Field Groups").
+			withSubTestWithMetadata("invalid"),
+		defaultTest("Manual Correlation Patterns").
+			withModes([]Mode{{Live, LocalRef}, {Local, LocalRef}}).
+			withUserConfig(userConfigFileName).
+			withVerboseOutput(),
+		defaultTest("Dry Run").
+			withModes([]Mode{{Live, LocalRef}, {Local, LocalRef}}).
+			withDryRun(),
+		defaultTest("Dry Run").
+			withSubTestWithChecks("JSON").
+			withModes([]Mode{{Live, LocalRef}}).
+			withOutputFormat(Json).
+			withDryRun(),
+		defaultTest("Stdin Resources").
+			withModes([]Mode{{Stdin, LocalRef}, {Local, LocalRef}}),
+		defaultTest("List Resources").
+			withModes([]Mode{{Stdin, LocalRef}, {Local, LocalRef}}),
+		defaultTest("Multiple References").
+			withModes([]Mode{{Live, LocalRef}, {Local, LocalRef}}).
+			withExtraReferences("next/metadata.yaml"),
+		defaultTest("Multiple References").
+			withSubTestWithChecks("JSON").
+			withModes([]Mode{{Live, LocalRef}}).
+			withOutputFormat(Json).
+			withExtraReferences("next/metadata.yaml"),
+		defaultTest("Multiple References").
+			withSubTestWithChecks("Contexts").
+			withModes([]Mode{{Live, LocalRef}}).
+			withContexts("cluster-a").
+			withExtraReferences("next/metadata.yaml"),
+		defaultTest("Sops Encrypted").
+			withModes([]Mode{{Local, LocalRef}}).
+			// The fake sops script decrypts the values stored in clear text in the encrypted test files
+			withSopsBinary(path.Join(TestDirs, "SopsEncrypted", "sops")),
+		defaultTest("Sops Encrypted").
+			withSubTestWithChecks("Sops Not Installed").
+			withModes([]Mode{{Local, LocalRef}}).
+			withSopsBinary("sops-not-installed"),
+		defaultTest("Unordered Lists").
+			withModes([]Mode{{Live, LocalRef}, {Local, LocalRef}}),
+		defaultTest("API Defaults").
+			withSubTestWithChecks("No Diff Program").
+			withModes([]Mode{{Local, LocalRef}}).
+			withoutDiffProgram(),
+		defaultTest("API Defaults").
+			withModes([]Mode{{Live, LocalRef}, {Local, LocalRef}}),
+		defaultTest("API Defaults").
+			withSubTestWithChecks("Ignore API Defaults").
+			withModes([]Mode{{Live, LocalRef}, {Local, LocalRef}}).
+			withIgnoreAPIDefaults(),
+		defaultTest("API Defaults").
+			withSubTestWithMetadata("invalid").
+			withModes([]Mode{{Local, LocalRef}}),
+		defaultTest("Normalize Quantities").
+			withModes([]Mode{{Live, LocalRef}, {Local, LocalRef}}),
+		defaultTest("Comparison Mode").
+			withModes([]Mode{{Live, LocalRef}, {Local, LocalRef}}),
+		defaultTest("Comparison Mode").
+			withSubTestWithMetadata("invalid").
+			withModes([]Mode{{Local, LocalRef}}),
+		defaultTest("Inventory Input").
+			withInputFormat(InputFormatInventory),
+		defaultTest("Path Filters").
+			withModes([]Mode{{Live, LocalRef}, {Local, LocalRef}}),
+		defaultTest("Path Filters").
+			withSubTestWithChecks("Only Spec").
+			withModes([]Mode{{Live, LocalRef}, {Local, LocalRef}}).
+			withOnlyPaths("spec.*"),
+		defaultTest("Path Filters").
+			withSubTestWithChecks("Only Images").
+			withOnlyPaths("{.spec.template.spec.containers[*].image}").
+			withDiffEngine(DiffEngineInternal),
+		defaultTest("Path Filters").
+			withSubTestWithChecks("Ignore Replicas And Labels").
+			withIgnorePaths(".spec.replicas", "{.metadata.labels['example.com/team']}"),
+		defaultTest("Path Filters").
+			withSubTestWithChecks("Invalid Path").
+			withOnlyPaths(".spec[?(@.x)]"),
+		defaultTest("Exit Codes").
+			withModes([]Mode{{Live, LocalRef}, {Local, LocalRef}}),
+		defaultTest("Exit Codes").
+			withSubTestWithChecks("Missing Code").
+			withModes([]Mode{{Local, LocalRef}}).
+			withExitPolicy(map[string]string{"exit-code-missing": "4"}),
+		defaultTest("Exit Codes").
+			withSubTestWithChecks("No Fail On Missing").
+			withModes([]Mode{{Local, LocalRef}}).
+			withExitPolicy(map[string]string{"fail-on-missing": "false"}),
+		defaultTest("Exit Codes").
+			withSubTestWithChecks("Fail On Unmatched").
+			withModes([]Mode{{Local, LocalRef}}).
+			diffAll().
+			withExitPolicy(map[string]string{"fail-on-missing": "false", "fail-on-unmatched": "true", "exit-code-unmatched": "5"}),
+		defaultTest("Exit Codes").
+			withSubTestWithChecks("Invalid Code").
+			withModes([]Mode{{Local, LocalRef}}).
+			withExitPolicy(map[string]string{"exit-code-diffs": "2"}),
+		defaultTest("Lookup CR").
+			withModes([]Mode{{Live, LocalRef}, {Replay, LocalRef}}).
+			withLookups(),
+		defaultTest("Lookup CR").
+			withSubTestWithChecks("Disabled").
+			withModes([]Mode{{Live, LocalRef}}),
+		defaultTest("Lookup CR").
+			withSubTestWithChecks("Local").
+			withModes([]Mode{{Local, LocalRef}}).
+			withLookups(),
+		defaultTest("Drift Annotations").
+			withModes([]Mode{{Live, LocalRef}}).
+			withDriftAnnotations("annotate-drift"),
+		defaultTest("Drift Annotations").
+			withSubTestWithChecks("Remove").
+			withModes([]Mode{{Live, LocalRef}}).
+			withDriftAnnotations("remove-annotations"),
+		defaultTest("Drift Annotations").
+			withSubTestWithChecks("Local").
+			withModes([]Mode{{Local, LocalRef}}).
+			withDriftAnnotations("annotate-drift"),
+		defaultTest("Drift Events").
+			withModes([]Mode{{Live, LocalRef}}).
+			withEmitEvents(),
+		defaultTest("Drift Events").
+			withSubTestWithChecks("Local").
+			withModes([]Mode{{Local, LocalRef}}).
+			withEmitEvents(),
+		defaultTest("Template Warnings").
+			withModes([]Mode{{Live, LocalRef}, {Local, LocalRef}}),
+		defaultTest("Template Warnings").
+			withSubTestWithChecks("Json").
+			withOutputFormat(Json),
+		defaultTest("Template Render Failures").
+			withModes([]Mode{{Live, LocalRef}, {Local, LocalRef}}),
+		defaultTest("Template Render Failures").
+			withSubTestWithChecks("Skip").
+			withModes([]Mode{{Live, LocalRef}, {Local, LocalRef}}).
+			withOnTemplateError(TemplateErrorSkip),
+		defaultTest("Template Render Failures").
+			withSubTestWithChecks("Skip Json").
+			withOnTemplateError(TemplateErrorSkip).
+			withOutputFormat(Json),
+		defaultTest("Unordered Lists").
+			withSubTestWithChecks("Internal Diff Engine").
+			withModes([]Mode{{Local, LocalRef}}).
+			withDiffEngine(DiffEngineInternal),
+		defaultTest("Server Side Dry Run").
+			withModes([]Mode{{Live, LocalRef}, {Local, LocalRef}}).
+			withServerSideDryRun(),
+		defaultTest("Server Side Dry Run").
+			withSubTestWithChecks("Not Normalized").
+			withModes([]Mode{{Live, LocalRef}}),
+		defaultTest("Cancelled Comparison").
+			withModes([]Mode{{Live, LocalRef}, {Local, LocalRef}}).
+			withCancelledContext(),
+		defaultTest("Cancelled Comparison").
+			withSubTestWithChecks("JSON").
+			withModes([]Mode{{Live, LocalRef}}).
+			withOutputFormat(Json).
+			withCancelledContext(),
 		defaultTest("YAML Output").
 			withOutputFormat(Yaml).
 			withChecks(Checks{Err: defaultCheckErr,
@@ -412,6 +1084,15 @@ func TestCompareRun(t *testing.T) {
 			}),
 		defaultTest("JSON Output").
 			withOutputFormat(Json),
+		defaultTest("JSON Output").
+			withSubTestWithChecks("Output File").
+			withModes([]Mode{{Local, LocalRef}}).
+			withOutputFormat(Json).
+			withOutputToFile(),
+		defaultTest("JSON Output").
+			withSubTestWithChecks("Invalid Format").
+			withModes([]Mode{{Local, LocalRef}}).
+			withOutputFormat("html"),
 		defaultTest("Check Ignore Unspecified Fields Config"),
 		defaultTest("Check Merging Does Not Overwrite Template Config"),
 		defaultTest("NoDiffs"),
@@ -466,6 +1147,7 @@ func TestCompareRun(t *testing.T) {
 			withModes([]Mode{{Live, LocalRef}, {Local, LocalRef}, {Local, URL}, {Live, URL}}),
 
 		defaultTest("Reference V2 Too Many Keys In Component Group"),
+		defaultTest("Reference V2 Invalid Operator Versions"),
 		defaultTest("Reference V2 Only One").
 			withSubTestSuffix("All Of").
 			withMetadataFile("metadata-all-of.yaml").
@@ -590,6 +1272,124 @@ func TestCompareRun(t *testing.T) {
 				),
 			}),
 		defaultTest("Reference V2 Diff in Custom Omitted Fields Isnt Shown Prefix"),
+		defaultTest("Reference V2 Diff in Custom Omitted Fields Isnt Shown JSONPath"),
+		defaultTest("Count Constraints").withSubTestWithMetadata("mismatch"),
+		defaultTest("Count Constraints").withSubTestWithMetadata("invalid"),
+		defaultTest("Field Selectors").
+			withModes([]Mode{{Live, LocalRef}}),
+		defaultTest("Cluster Facts").
+			withModes([]Mode{{Live, LocalRef}, {Local, LocalRef}}),
+		defaultTest("Template Conditions").
+			withModes([]Mode{{Live, LocalRef}, {Local, LocalRef}, {Stdin, LocalRef}, {Replay, LocalRef}}),
+		defaultTest("Template Conditions").withSubTestWithMetadata("invalid"),
+		defaultTest("Template Dependencies").
+			withModes([]Mode{{Live, LocalRef}, {Local, LocalRef}}),
+		defaultTest("Template Dependencies").withSubTestWithMetadata("invalid"),
+		defaultTest("Template Dependencies").withSubTestWithMetadata("cycle"),
+		defaultTest("Group By").
+			withModes([]Mode{{Live, LocalRef}, {Local, LocalRef}}).
+			diffAll(),
+		defaultTest("Group By").
+			withSubTestWithChecks("Namespace").
+			withModes([]Mode{{Local, LocalRef}}).
+			diffAll().
+			withGroupBy(GroupByNamespace),
+		defaultTest("Group By").
+			withSubTestWithChecks("Kind").
+			withModes([]Mode{{Local, LocalRef}}).
+			withGroupBy(GroupByKind),
+		defaultTest("Group By").
+			withSubTestWithChecks("Namespace JSON").
+			withModes([]Mode{{Local, LocalRef}}).
+			diffAll().
+			withGroupBy(GroupByNamespace).
+			withOutputFormat(Json),
+		defaultTest("Group By").
+			withSubTestWithChecks("Invalid").
+			withModes([]Mode{{Local, LocalRef}}).
+			withGroupBy("team"),
+		defaultTest("Schema Validation").
+			withModes([]Mode{{Live, LocalRef}}).
+			withExcludeKinds("Widget").
+			withValidateSchemas(),
+		defaultTest("Schema Validation").
+			withSubTestWithChecks("Bundle").
+			withModes([]Mode{{Local, LocalRef}}).
+			withSchemas("schemas"),
+		defaultTest("Schema Validation").
+			withSubTestWithChecks("Missing Bundle").
+			withModes([]Mode{{Local, LocalRef}}).
+			withValidateSchemas(),
+		defaultTest("Schema Validation").
+			withSubTestWithChecks("Invalid Bundle").
+			withModes([]Mode{{Local, LocalRef}}).
+			withSchemas("resources"),
+		defaultTest("Template Patterns").
+			withModes([]Mode{{Live, LocalRef}, {Local, LocalRef}}).
+			diffAll(),
+		defaultTest("Template Patterns").withSubTestWithMetadata("duplicate"),
+		defaultTest("Template Patterns").withSubTestWithMetadata("no match"),
+		defaultTest("Reference Includes").
+			withModes([]Mode{{Live, LocalRef}, {Local, LocalRef}}).
+			diffAll(),
+		defaultTest("Reference Includes").withSubTestWithMetadata("invalid"),
+		defaultTest("Reference Includes").withSubTestWithMetadata("cycle"),
+		defaultTest("Correlate By Annotation"),
+		defaultTest("Correlate By Annotation").withSubTestWithMetadata("invalid"),
+		defaultTest("Correlate By Annotation").withSubTestWithMetadata("duplicate"),
+		defaultTest("Reference Patches").
+			withSubTestWithChecks("Without Patches").
+			withModes([]Mode{{Local, LocalRef}}),
+		defaultTest("Reference Patches").
+			withSubTestWithChecks("Patched").
+			withModes([]Mode{{Local, LocalRef}}).
+			withReferencePatches("patches.yaml"),
+		defaultTest("Reference Patches").
+			withSubTestWithChecks("Patch Path").
+			withModes([]Mode{{Local, LocalRef}}).
+			withReferencePatches("entry.yaml"),
+		defaultTest("Reference Patches").
+			withSubTestWithChecks("Invalid").
+			withModes([]Mode{{Local, LocalRef}}).
+			withReferencePatches("invalid.yaml"),
+		defaultTest("Namespace Scope").
+			withSubTestWithChecks("Names").
+			withModes([]Mode{{Live, LocalRef}}).
+			withNamespaces("team-a", "team-b").
+			withListError("ConfigMap", apierrors.NewForbidden(schema.GroupResource{Resource: "configmaps"}, "",
+				errors.New("cluster-wide list forbidden"))),
+		defaultTest("Namespace Scope").
+			withSubTestWithChecks("Selector").
+			withModes([]Mode{{Live, LocalRef}}).
+			withNamespaceSelector("team=payments").
+			withListError("ConfigMap", apierrors.NewForbidden(schema.GroupResource{Resource: "configmaps"}, "",
+				errors.New("cluster-wide list forbidden"))),
+		defaultTest("Namespace Scope").
+			withSubTestWithChecks("Forbidden Namespace").
+			withModes([]Mode{{Live, LocalRef}}).
+			withNamespaces("team-a", "team-c").
+			withListError("team-c/ConfigMap", apierrors.NewForbidden(schema.GroupResource{Resource: "configmaps"}, "",
+				errors.New(`User "dev" cannot list resource "configmaps" in API group "" in the namespace "team-c"`))),
+		defaultTest("Namespace Scope").
+			withSubTestWithChecks("Local").
+			withModes([]Mode{{Local, LocalRef}}).
+			withNamespaces("team-a"),
+		defaultTest("Field Selectors").
+			withSubTestWithChecks("Flag").
+			withModes([]Mode{{Live, LocalRef}}).
+			withFieldSelectors("secret:metadata.namespace=certs", "Secret:metadata.name=tls"),
+		defaultTest("Field Selectors").
+			withSubTestWithChecks("All Resources").
+			withModes([]Mode{{Live, LocalRef}}).
+			diffAll(),
+		defaultTest("Field Selectors").
+			withSubTestWithChecks("Local").
+			withModes([]Mode{{Local, LocalRef}}).
+			withFieldSelectors("Secret:metadata.name=tls"),
+		defaultTest("Field Selectors").
+			withSubTestWithChecks("Invalid").
+			withModes([]Mode{{Live, LocalRef}}).
+			withFieldSelectors("metadata.name=tls"),
 
 		defaultTest("Description").withSubTestWithMetadata("shown for diff"),
 		defaultTest("Description").withSubTestWithMetadata("shown for missing file"),
@@ -600,6 +1400,9 @@ func TestCompareRun(t *testing.T) {
 		defaultTest("Description").withSubTestWithMetadata("precidence p t shown for diff"),
 		defaultTest("Description").withSubTestWithMetadata("precidence p c t shown for diff"),
 		defaultTest("Description").withSubTestWithMetadata("precidence c t shown for diff"),
+		defaultTest("Description").withSubTestWithMetadata("annotations shown for diff"),
+		defaultTest("Description").withSubTestWithMetadata("annotations hidden for match"),
+		defaultTest("Description").withSubTestWithMetadata("annotations invalid doc url"),
 		defaultTest("Description V1").withSubTestWithMetadata("shown for diff"),
 		defaultTest("Description V1").withSubTestWithMetadata("shown for missing file"),
 		defaultTest("Description V1").withSubTestWithMetadata("hidden for match"),
@@ -614,6 +1417,176 @@ func TestCompareRun(t *testing.T) {
 			withEnvVar("KUBECTL_EXTERNAL_DIFF", "diff -y -W 150").
 			withChecks(defaultChecks.withPrefixedSuffix("with_diff_y")),
 		defaultTest("Machine Configs Catch All"),
+		defaultTest("Compare To Snapshot").
+			withCompareToSnapshot("snapshot"),
+		defaultTest("Operator Version Drift"),
+		defaultTest("Reference Lock Mismatch"),
+		defaultTest("Reference Bundle").
+			withMetadataFile("ref.tgz").
+			withModes([]Mode{{Local, LocalRef}, {Local, URL}}),
+		defaultTest("Reference Bundle").
+			withSubTestWithChecks("Verified").
+			withMetadataFile("ref.tgz").
+			withModes([]Mode{{Local, URL}}).
+			withVerifySignature("sha256:AFE61F5D1B308863BAFB45FFD0E6C4FCF85368FD544A17BF599FBCC1465DEE8E"),
+		defaultTest("Reference Bundle").
+			withSubTestWithChecks("Checksum Mismatch").
+			withMetadataFile("ref.tgz").
+			withModes([]Mode{{Local, LocalRef}}).
+			withVerifySignature("sha256:0000000000000000000000000000000000000000000000000000000000000000"),
+		defaultTest("Reference Bundle").
+			withSubTestWithChecks("Unsupported Signature").
+			withMetadataFile("ref.tgz").
+			withModes([]Mode{{Local, LocalRef}}).
+			withVerifySignature("cosign.pub"),
+		defaultTest("Unavailable Kinds").
+			withModes([]Mode{{Live, LocalRef}}).
+			withRetries("0").
+			withListError("CronJob", apierrors.NewInternalError(errors.New(
+				`conversion webhook for batch/v1, Kind=CronJob failed: Post "https://webhook.example.svc:443/convert": no endpoints available for service "webhook"`))).
+			withListError("HorizontalPodAutoscaler", apierrors.NewServiceUnavailable("the server is currently unable to handle the request")),
+		defaultTest("List Retries").
+			withSubTestWithChecks("Recovered").
+			withModes([]Mode{{Live, LocalRef}}).
+			withTransientListError("CronJob", apierrors.NewTooManyRequests("too many requests, please try again later", 0), 2).
+			withTransientListError("HorizontalPodAutoscaler", apierrors.NewServiceUnavailable("the server is currently unable to handle the request"), 1),
+		defaultTest("List Retries").
+			withSubTestWithChecks("Retries Exhausted").
+			withModes([]Mode{{Live, LocalRef}}).
+			withRetries("1").
+			withTransientListError("CronJob", apierrors.NewTooManyRequests("too many requests, please try again later", 0), 2).
+			withListError("HorizontalPodAutoscaler", apierrors.NewTimeoutError("the server was unable to return a response in the time allotted", 0)),
+		defaultTest("List Retries").
+			withSubTestWithChecks("Invalid Fetch Concurrency").
+			withModes([]Mode{{Live, LocalRef}}).
+			withFetchConcurrency("0"),
+		defaultTest("Match Tie Breakers").
+			withModes([]Mode{{Live, LocalRef}, {Local, LocalRef}}),
+		defaultTest("Match Tie Breakers").
+			withSubTestWithMetadata("invalid"),
+		defaultTest("Match Difference Weights").
+			withModes([]Mode{{Live, LocalRef}, {Local, LocalRef}}),
+		defaultTest("Match Difference Weights").
+			withSubTestWithMetadata("unweighted"),
+		defaultTest("Match Difference Weights").
+			withSubTestWithMetadata("invalid"),
+		defaultTest("Match Difference Weights").
+			withSubTestWithChecks("User Config").
+			withMetadataFile("metadata_unweighted.yaml").
+			withUserConfig("userconfig.yaml"),
+		defaultTest("Field Severities").
+			withModes([]Mode{{Live, LocalRef}, {Local, LocalRef}}),
+		defaultTest("Field Severities").
+			withSubTestWithChecks("JSON").
+			withModes([]Mode{{Local, LocalRef}}).
+			withOutputFormat(Json),
+		defaultTest("Field Severities").
+			withSubTestWithChecks("Fail On Critical").
+			withModes([]Mode{{Local, LocalRef}}).
+			withExitPolicy(map[string]string{"fail-on-severity": string(api.SeverityCritical)}),
+		defaultTest("Field Severities").
+			withSubTestWithChecks("No Critical").
+			withModes([]Mode{{Local, LocalRef}}).
+			withMetadataFile("metadata_no_critical.yaml").
+			withExitPolicy(map[string]string{"fail-on-severity": string(api.SeverityCritical)}),
+		defaultTest("Field Severities").
+			withSubTestWithChecks("Invalid Severity").
+			withModes([]Mode{{Local, LocalRef}}).
+			withMetadataFile("metadata_invalid.yaml"),
+		defaultTest("Field Severities").
+			withSubTestWithChecks("Invalid Flag").
+			withModes([]Mode{{Local, LocalRef}}).
+			withExitPolicy(map[string]string{"fail-on-severity": "severe"}),
+		defaultTest("Field Severities").
+			withSubTestWithChecks("Review").
+			withModes([]Mode{{Live, LocalRef}, {Local, LocalRef}, {Stdin, LocalRef}}).
+			withReview("l\nd\nx\nn\np\nq\n"),
+		defaultTest("Archive Input").
+			withSubTestWithChecks("Tar").
+			withModes([]Mode{{Local, LocalRef}}).
+			withArchive("must-gather.tar.gz"),
+		defaultTest("Archive Input").
+			withSubTestWithChecks("Zip").
+			withModes([]Mode{{Local, LocalRef}}).
+			withArchive("must-gather.zip"),
+		defaultTest("Archive Input").
+			withSubTestWithChecks("Inventory").
+			withModes([]Mode{{Local, LocalRef}}).
+			withArchive("must-gather.zip").
+			withInputFormat(InputFormatInventory),
+		defaultTest("Max Diffs").
+			withSubTestWithChecks("Fail Fast").
+			withModes([]Mode{{Live, LocalRef}, {Local, LocalRef}}).
+			withDiffLimit(map[string]string{"fail-fast": "true"}),
+		defaultTest("Max Diffs").
+			withSubTestWithChecks("Two").
+			withModes([]Mode{{Live, LocalRef}, {Local, LocalRef}}).
+			withDiffLimit(map[string]string{"max-diffs": "2"}),
+		defaultTest("Max Diffs").
+			withSubTestWithChecks("Not Reached").
+			withDiffLimit(map[string]string{"max-diffs": "5"}),
+		defaultTest("Max Diffs").
+			withSubTestWithChecks("Conflicting Flags").
+			withDiffLimit(map[string]string{"fail-fast": "true", "max-diffs": "3"}),
+		defaultTest("Streaming Output").
+			withModes([]Mode{{Live, LocalRef}, {Local, LocalRef}}).
+			withStream(),
+		defaultTest("Streaming Output").
+			withSubTestWithChecks("JSONL").
+			withModes([]Mode{{Live, LocalRef}, {Local, LocalRef}}).
+			withOutputFormat(Jsonl).
+			withStream(),
+		defaultTest("Streaming Output").
+			withSubTestWithChecks("Conflicting Flags").
+			withOutputFormat(Json).
+			withStream(),
+		defaultTest("Kind Filters").
+			withSubTestWithChecks("No Filters"),
+		defaultTest("Kind Filters").
+			withSubTestWithChecks("Include ConfigMap").
+			withIncludeKinds("ConfigMap").
+			withModes([]Mode{{Live, LocalRef}, {Local, LocalRef}}),
+		defaultTest("Kind Filters").
+			withSubTestWithChecks("Exclude Secret And Service").
+			withExcludeKinds("secret", "service"),
+		defaultTest("Kind Filters").
+			withSubTestWithChecks("Exclude All").
+			withIncludeKinds("ConfigMap").
+			withExcludeKinds("ConfigMap"),
+		defaultTest("Component Filters").
+			withSubTestWithChecks("No Filters"),
+		defaultTest("Component Filters").
+			withSubTestWithChecks("Include Services").
+			withComponents("Services").
+			withModes([]Mode{{Live, LocalRef}, {Local, LocalRef}}),
+		defaultTest("Component Filters").
+			withSubTestWithChecks("Skip Volumes").
+			withSkipComponents("Volumes"),
+		defaultTest("Component Filters").
+			withSubTestWithChecks("Unknown").
+			withComponents("Services", "Compute"),
+		defaultTest("Component Filters").
+			withSubTestWithChecks("Skip All").
+			withSkipComponents("Settings", "Services", "Volumes"),
+		defaultTest("Component Filters").
+			withSubTestWithMetadata("v1").
+			withSkipComponents("Volumes"),
+		defaultTest("Field Managers").
+			withSubTestWithChecks("No Filter"),
+		defaultTest("Field Managers").
+			withSubTestWithChecks("Ignore Operator").
+			withModes([]Mode{{Live, LocalRef}, {Local, LocalRef}}).
+			withIgnoreFieldManagers("config-operator"),
+		defaultTest("Field Managers").
+			withSubTestWithChecks("Only Operator").
+			withFieldManagers("config-operator"),
+		defaultTest("Field Managers").
+			withSubTestWithChecks("Only Kubectl").
+			withFieldManagers("kubectl-client-side-apply"),
+		defaultTest("Field Managers").
+			withSubTestWithChecks("Both").
+			withFieldManagers("kubectl-client-side-apply").
+			withIgnoreFieldManagers("config-operator"),
 	}
 
 	tf := cmdtesting.NewTestFactory()
@@ -632,6 +1605,11 @@ func TestCompareRun(t *testing.T) {
 				IOStream, _, out, _ := genericiooptions.NewTestIOStreams()
 				klog.SetOutputBySeverity("INFO", out)
 				cmd := getCommand(t, &test, i, tf, &IOStream) // nolint:gosec
+				outputFile := ""
+				if test.outputToFile {
+					outputFile = filepath.Join(t.TempDir(), "output")
+					require.NoError(t, cmd.Flags().Set("output-file", outputFile))
+				}
 
 				hasCheckedError := false
 				cmdutil.BehaviorOnFatal(func(str string, code int) {
@@ -649,7 +1627,13 @@ func TestCompareRun(t *testing.T) {
 					if !hasCheckedError && test.checks.Err.hasErrorFile(test, mode) {
 						t.Fatalf("Unchecked error file %s", test.checks.Err.getPath(test, mode))
 					}
-					test.checks.Out.check(t, test, mode, testutils.RemoveInconsistentInfo(t, out.String()))
+					output := out.String()
+					if outputFile != "" {
+						content, err := os.ReadFile(outputFile)
+						require.NoError(t, err)
+						output += "--- output file ---\n" + string(content)
+					}
+					test.checks.Out.check(t, test, mode, testutils.RemoveInconsistentInfo(t, output))
 				}()
 				cmd.Run(cmd, []string{})
 			})
@@ -658,10 +1642,133 @@ func TestCompareRun(t *testing.T) {
 
 }
 
+func TestUnexpectedArgs(t *testing.T) {
+	tf := cmdtesting.NewTestFactory()
+	defer tf.Cleanup()
+	cmd := NewCmd(tf, genericiooptions.NewTestIOStreamsDiscard())
+	cmd.SetArgs([]string{"-r", "metadata.yaml", "extra"})
+
+	const fatal = "fatal"
+	var message string
+	var code int
+	cmdutil.BehaviorOnFatal(func(msg string, c int) {
+		message, code = msg, c
+		panic(fatal)
+	})
+	defer cmdutil.DefaultBehaviorOnFatal()
+	require.PanicsWithValue(t, fatal, func() { _ = cmd.Execute() })
+	require.Contains(t, message, "Unexpected args: [extra]")
+	require.Equal(t, 2, code)
+}
+
+func TestFindAllRequestedSupportedTypes(t *testing.T) {
+	apps := schema.GroupVersion{Group: "apps", Version: "v1"}
+	appsBeta := schema.GroupVersion{Group: "apps", Version: "v1beta1"}
+	custom := schema.GroupVersion{Group: "example.com", Version: "v1"}
+	core := schema.GroupVersion{Version: "v1"}
+	tests := []struct {
+		name         string
+		supported    map[string][]schema.GroupVersion
+		requested    []schema.GroupVersionKind
+		types        []string
+		notSupported []string
+	}{
+		{
+			name:      "kinds of other groups aren't listed",
+			supported: map[string][]schema.GroupVersion{"Deployment": {apps, custom}, "ConfigMap": {core, custom}},
+			requested: []schema.GroupVersionKind{apps.WithKind("Deployment"), core.WithKind("ConfigMap")},
+			types:     []string{"ConfigMap", "Deployment.v1.apps"},
+		},
+		{
+			name:      "only the requested version is listed",
+			supported: map[string][]schema.GroupVersion{"Deployment": {apps, appsBeta}},
+			requested: []schema.GroupVersionKind{appsBeta.WithKind("Deployment")},
+			types:     []string{"Deployment.v1beta1.apps"},
+		},
+		{
+			name:      "other versions of the group are listed if the version isn't served",
+			supported: map[string][]schema.GroupVersion{"Deployment": {apps, custom}},
+			requested: []schema.GroupVersionKind{appsBeta.WithKind("Deployment")},
+			types:     []string{"Deployment.v1.apps"},
+		},
+		{
+			name:         "kind only served by other groups isn't supported",
+			supported:    map[string][]schema.GroupVersion{"Deployment": {custom}},
+			requested:    []schema.GroupVersionKind{apps.WithKind("Deployment"), apps.WithKind("StatefulSet")},
+			notSupported: []string{"Deployment.apps/v1", "StatefulSet"},
+		},
+		{
+			name:      "kind served without a group is listed",
+			supported: map[string][]schema.GroupVersion{"Deployment": {core, custom}},
+			requested: []schema.GroupVersionKind{apps.WithKind("Deployment")},
+			types:     []string{"Deployment"},
+		},
+		{
+			name:      "all groups are listed if the requested group isn't known",
+			supported: map[string][]schema.GroupVersion{"Deployment": {apps, custom}},
+			requested: []schema.GroupVersionKind{{Kind: "Deployment"}},
+			types:     []string{"Deployment.v1.apps", "Deployment.v1.example.com"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			requested := make(map[schema.GroupVersionKind][]ReferenceTemplate)
+			for _, gvk := range test.requested {
+				requested[gvk] = nil
+			}
+			types, notSupported := findAllRequestedSupportedTypes(test.supported, requested)
+			slices.Sort(notSupported)
+			require.Equal(t, test.types, types)
+			require.Equal(t, test.notSupported, notSupported)
+		})
+	}
+}
+
+// runRecording runs the comparison recording the live resources, the diffs found don't fail it
+func runRecording(t *testing.T, cmd *cobra.Command) {
+	const recorded = "recorded"
+	cmdutil.BehaviorOnFatal(func(msg string, code int) {
+		require.Equal(t, 1, code, "recording the comparison failed: %s", msg)
+		panic(recorded)
+	})
+	defer func() {
+		if r := recover(); r != nil && r != recorded {
+			panic(r)
+		}
+	}()
+	cmd.Run(cmd, []string{})
+}
+
+// writeResourcesToStdin writes the resources of the dir to the input stream as a single multi-document YAML
+func writeResourcesToStdin(t *testing.T, in *bytes.Buffer, dir string) {
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	for _, entry := range entries {
+		content, err := os.ReadFile(path.Join(dir, entry.Name()))
+		require.NoError(t, err)
+		in.WriteString("---\n")
+		in.Write(content)
+	}
+}
+
 func getCommand(t *testing.T, test *Test, modeIndex int, tf *cmdtesting.TestFactory, streams *genericiooptions.IOStreams) *cobra.Command {
 	mode := test.mode[modeIndex]
-	cmd := NewCmd(tf, *streams)
+	cmd := NewCmdWithOptions(tf, NewOptions(*streams).WithCorrelators(test.correlators...))
 	require.NoError(t, cmd.Flags().Set("concurrency", defaultConcurrency))
+	require.NoError(t, cmd.Flags().Set("retry-interval", defaultRetryInterval))
+	require.NoError(t, cmd.Flags().Set("reference-retry-interval", defaultRetryInterval))
+	if test.verifySignature != "" {
+		require.NoError(t, cmd.Flags().Set("verify-signature", test.verifySignature))
+	}
+	if test.retries != "" {
+		require.NoError(t, cmd.Flags().Set("retries", test.retries))
+	}
+	// The fake REST client of the test factory isn't safe for concurrent use, types are listed one at a time
+	fetchConcurrency := "1"
+	if test.fetchConcurrency != "" {
+		fetchConcurrency = test.fetchConcurrency
+	}
+	require.NoError(t, cmd.Flags().Set("fetch-concurrency", fetchConcurrency))
 	if test.shouldDiffAll {
 		require.NoError(t, cmd.Flags().Set("all-resources", "true"))
 	}
@@ -677,12 +1784,29 @@ func getCommand(t *testing.T, test *Test, modeIndex int, tf *cmdtesting.TestFact
 	resourcesDir := path.Join(test.getTestDir(), ResourceDirName)
 	switch mode.crSource {
 	case Local:
+		if test.archive != "" {
+			resourcesDir = path.Join(test.getTestDir(), test.archive)
+		}
 		require.NoError(t, cmd.Flags().Set("filename", resourcesDir))
 		require.NoError(t, cmd.Flags().Set("recursive", "true"))
+	case Stdin:
+		in, ok := streams.In.(*bytes.Buffer)
+		require.True(t, ok)
+		writeResourcesToStdin(t, in, resourcesDir)
+		require.NoError(t, cmd.Flags().Set("filename", "-"))
+	case Replay:
+		recordTest := test.withModes([]Mode{{Live, mode.refSource}})
+		recordStreams, _, _, _ := genericiooptions.NewTestIOStreams()
+		recordCmd := getCommand(t, &recordTest, 0, tf, &recordStreams)
+		recording := filepath.Join(t.TempDir(), "recording")
+		require.NoError(t, recordCmd.Flags().Set("record", recording))
+		runRecording(t, recordCmd)
+		require.NoError(t, cmd.Flags().Set("replay", recording))
 	case Live:
 		discoveryResources, resources := getResources(t, *test, resourcesDir)
 		updateTestDiscoveryClient(tf, discoveryResources)
-		setClient(t, resources, tf)
+		setClient(t, resources, test.listErrors, tf)
+		setFactsClient(resources, tf)
 	}
 	switch mode.refSource {
 	case URL:
@@ -693,14 +1817,23 @@ func getCommand(t *testing.T, test *Test, modeIndex int, tf *cmdtesting.TestFact
 			require.NoError(t, err)
 		}))
 		require.NoError(t, cmd.Flags().Set("reference", svr.URL+"/"+test.referenceFileName))
+		if test.verifySignature == "" {
+			require.NoError(t, cmd.Flags().Set("insecure-skip-verify", "true"))
+		}
+		// References served over http are cached by default, every test gets its own cache
+		require.NoError(t, cmd.Flags().Set("cache-dir", t.TempDir()))
 		t.Cleanup(func() {
 			svr.Close()
 		})
 
 	case LocalRef:
-		if !test.leaveTemplateDirEmpty {
+		// The reference of the recording is compared when replaying
+		if !test.leaveTemplateDirEmpty && mode.crSource != Replay {
 			require.NoError(t, cmd.Flags().Set("reference", path.Join(test.getTestDir(), TestRefDirName, test.referenceFileName)))
 		}
+		for _, reference := range test.extraReferences {
+			require.NoError(t, cmd.Flags().Set("reference", path.Join(test.getTestDir(), TestRefDirName, reference)))
+		}
 	}
 
 	if test.userOverridePath != "" {
@@ -717,40 +1850,333 @@ func getCommand(t *testing.T, test *Test, modeIndex int, tf *cmdtesting.TestFact
 		require.NoError(t, cmd.Flags().Set("override-reason", test.overrideGenReason))
 	}
 
+	for _, kind := range test.includeKinds {
+		require.NoError(t, cmd.Flags().Set("include-kind", kind))
+	}
+	for _, kind := range test.excludeKinds {
+		require.NoError(t, cmd.Flags().Set("exclude-kind", kind))
+	}
+	for _, component := range test.components {
+		require.NoError(t, cmd.Flags().Set("components", component))
+	}
+	for _, component := range test.skipComponents {
+		require.NoError(t, cmd.Flags().Set("skip-components", component))
+	}
+	for _, manager := range test.fieldManagers {
+		require.NoError(t, cmd.Flags().Set("field-manager", manager))
+	}
+	for _, manager := range test.ignoreFieldManagers {
+		require.NoError(t, cmd.Flags().Set("ignore-field-manager", manager))
+	}
+
+	if test.dryRun {
+		require.NoError(t, cmd.Flags().Set("dry-run", "true"))
+	}
+	if test.color != "" {
+		require.NoError(t, cmd.Flags().Set("color", test.color))
+	}
+	if test.diffEngine != "" {
+		require.NoError(t, cmd.Flags().Set("diff-engine", test.diffEngine))
+	}
+	if test.diffStyle != "" {
+		require.NoError(t, cmd.Flags().Set("diff-style", test.diffStyle))
+	}
+	if test.diffContext != "" {
+		require.NoError(t, cmd.Flags().Set("diff-context", test.diffContext))
+	}
+	if test.noDiffHeader {
+		require.NoError(t, cmd.Flags().Set("no-diff-header", "true"))
+	}
+	if test.showMatchedOnly {
+		require.NoError(t, cmd.Flags().Set("show-matched-only", "true"))
+	}
+	if test.serverSideDryRun {
+		require.NoError(t, cmd.Flags().Set("server-side-dry-run", "true"))
+	}
+	if test.inputFormat != "" {
+		require.NoError(t, cmd.Flags().Set("input-format", test.inputFormat))
+	}
+	for _, p := range test.onlyPaths {
+		require.NoError(t, cmd.Flags().Set("only-path", p))
+	}
+	for _, p := range test.ignorePaths {
+		require.NoError(t, cmd.Flags().Set("ignore-path", p))
+	}
+	for _, s := range test.fieldSelectors {
+		require.NoError(t, cmd.Flags().Set("field-selector", s))
+	}
+	for _, p := range test.referencePatches {
+		require.NoError(t, cmd.Flags().Set("reference-patch", filepath.Join(test.getTestDir(), p)))
+	}
+	for _, namespace := range test.namespaces {
+		require.NoError(t, cmd.Flags().Set("namespaces", namespace))
+	}
+	if test.namespaceSelector != "" {
+		require.NoError(t, cmd.Flags().Set("namespace-selector", test.namespaceSelector))
+	}
+	if test.ignoreAPIDefaults {
+		require.NoError(t, cmd.Flags().Set("ignore-api-defaults", "true"))
+	}
+	if test.groupBy != "" {
+		require.NoError(t, cmd.Flags().Set("group-by", test.groupBy))
+	}
+	if test.validateSchemas {
+		tf.OpenAPIV3ClientFunc = func() (openapi.Client, error) {
+			return openapitest.NewEmbeddedFileClient(), nil
+		}
+		require.NoError(t, cmd.Flags().Set("validate-schemas", "true"))
+	}
+	if test.schemas != "" {
+		require.NoError(t, cmd.Flags().Set("schemas", filepath.Join(test.getTestDir(), test.schemas)))
+	}
+	if test.reviewInput != "" {
+		require.NoError(t, cmd.Flags().Set("review", "true"))
+		if mode.crSource != Stdin {
+			in, ok := streams.In.(*bytes.Buffer)
+			require.True(t, ok)
+			in.WriteString(test.reviewInput)
+		}
+	}
+	if test.noDiffProgram {
+		program := diffProgram
+		diffProgram = "diff-not-installed"
+		t.Cleanup(func() { diffProgram = program })
+	}
+	if test.onTemplateError != "" {
+		require.NoError(t, cmd.Flags().Set("on-template-error", test.onTemplateError))
+	}
+	if test.enableLookups {
+		require.NoError(t, cmd.Flags().Set("enable-lookups", "true"))
+	}
+	if test.driftAnnotations != "" {
+		require.NoError(t, cmd.Flags().Set(test.driftAnnotations, "true"))
+	}
+	if test.emitEvents {
+		require.NoError(t, cmd.Flags().Set("emit-events", "true"))
+	}
+	for name, value := range test.exitPolicyFlags {
+		require.NoError(t, cmd.Flags().Set(name, value))
+	}
+	if len(test.diffLimitFlags) > 0 {
+		require.NoError(t, cmd.Flags().Set("concurrency", "1"))
+	}
+	for name, value := range test.diffLimitFlags {
+		require.NoError(t, cmd.Flags().Set(name, value))
+	}
+	if test.stream {
+		require.NoError(t, cmd.Flags().Set("concurrency", "1"))
+		require.NoError(t, cmd.Flags().Set("stream", "true"))
+	}
+	if len(test.contexts) > 0 {
+		require.NoError(t, cmd.Flags().Set("contexts", strings.Join(test.contexts, ",")))
+		origNewContextFactory := newContextFactory
+		newContextFactory = func(f cmdutil.Factory, context string) (cmdutil.Factory, error) {
+			if context == "missing" {
+				return nil, fmt.Errorf("context %q doesn't exist in the kubeconfig", context)
+			}
+			return f, nil
+		}
+		t.Cleanup(func() {
+			newContextFactory = origNewContextFactory
+		})
+	}
+
+	if test.sopsBinary != "" {
+		origSopsBinary := sopsBinary
+		sopsBinary = test.sopsBinary
+		t.Cleanup(func() {
+			sopsBinary = origSopsBinary
+		})
+	}
+
+	if test.cancelled {
+		ctx, cancel := context.WithCancelCause(context.Background())
+		cancel(errors.New("cancelled by test"))
+		cmd.SetContext(ctx)
+	}
+
+	if test.compareToSnapshot != "" {
+		require.NoError(t, cmd.Flags().Set("compare-to-snapshot", path.Join(test.getTestDir(), test.compareToSnapshot)))
+	}
+
 	return cmd
 }
 
-func setClient(t *testing.T, resources []*unstructured.Unstructured, tf *cmdtesting.TestFactory) {
+func setClient(t *testing.T, resources []*unstructured.Unstructured, listErrors map[string]listError, tf *cmdtesting.TestFactory) {
 	resourcesByKind := make(map[string][]*unstructured.Unstructured)
 	for _, t := range resources {
 		key := fmt.Sprintf("/%ss", strings.ToLower(t.GetKind()))
 		resourcesByKind[key] = append(resourcesByKind[key], t)
 	}
-	tf.UnstructuredClient = &fake.RESTClient{
-		NegotiatedSerializer: resource.UnstructuredPlusDefaultContentConfig().NegotiatedSerializer,
-		Client: fake.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
-			switch p, m := req.URL.Path, req.Method; {
-			case m == "GET":
-				a := unstructured.Unstructured{}
-				exampleResource := resourcesByKind[p][0]
-				a.SetKind(exampleResource.GetKind() + "List")
-				a.SetAPIVersion(exampleResource.GetAPIVersion())
-				a.SetResourceVersion(exampleResource.GetResourceVersion())
-
-				requestedResources := lo.Map(resourcesByKind[p], func(value *unstructured.Unstructured, index int) any {
-					return value.Object
-				})
+	errorsByKind := make(map[string]listError)
+	for kind, err := range listErrors {
+		// Errors of kinds in the form of namespace/kind only fail listing the kind in the namespace
+		if namespace, nsKind, ok := strings.Cut(kind, "/"); ok {
+			errorsByKind[fmt.Sprintf("/namespaces/%s/%ss", namespace, strings.ToLower(nsKind))] = err
+			continue
+		}
+		errorsByKind[fmt.Sprintf("/%ss", strings.ToLower(kind))] = err
+	}
+	requestsByKind := make(map[string]int)
+	var requestsLock sync.Mutex
+	failsRequest := func(p string) bool {
+		listErr, ok := errorsByKind[p]
+		if !ok {
+			return false
+		}
+		requestsLock.Lock()
+		defer requestsLock.Unlock()
+		requestsByKind[p]++
+		return listErr.times == 0 || requestsByKind[p] <= listErr.times
+	}
+	client := fake.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
+		switch p, m := req.URL.Path, req.Method; {
+		case m == "GET" && strings.HasPrefix(p, "/namespaces/") && strings.Count(p, "/") > 3:
+			return getResource(t, resources, p), nil
+		case m == "GET" && failsRequest(p):
+			status := errorsByKind[p].err.ErrStatus
+			status.SetGroupVersionKind(v1.SchemeGroupVersion.WithKind("Status"))
+			b, err := json.Marshal(status)
+			require.NoError(t, err)
+			return &http.Response{StatusCode: int(status.Code), Header: cmdtesting.DefaultHeader(), Body: io.NopCloser(bytes.NewReader(b))}, nil
+		case m == "GET":
+			// Namespaced lists are in the form of /namespaces/{namespace}/{kind}s
+			namespace := ""
+			if rest, ok := strings.CutPrefix(p, "/namespaces/"); ok {
+				namespace, p, _ = strings.Cut(rest, "/")
+				p = "/" + p
+			}
+			a := unstructured.Unstructured{}
+			exampleResource := resourcesByKind[p][0]
+			a.SetKind(exampleResource.GetKind() + "List")
+			a.SetAPIVersion(exampleResource.GetAPIVersion())
+			a.SetResourceVersion(exampleResource.GetResourceVersion())
+
+			selector, err := fields.ParseSelector(req.URL.Query().Get("fieldSelector"))
+			require.NoError(t, err)
+			labelSelector, err := labels.Parse(req.URL.Query().Get("labelSelector"))
+			require.NoError(t, err)
+			selected := lo.Filter(resourcesByKind[p], func(value *unstructured.Unstructured, index int) bool {
+				return (namespace == "" || value.GetNamespace() == namespace) &&
+					selector.Matches(fields.Set{"metadata.name": value.GetName(), "metadata.namespace": value.GetNamespace()}) &&
+					labelSelector.Matches(labels.Set(value.GetLabels()))
+			})
+			requestedResources := lo.Map(selected, func(value *unstructured.Unstructured, index int) any {
+				return value.Object
+			})
+
+			require.NoError(t, unstructured.SetNestedSlice(a.Object, requestedResources, "items"))
+			b, _ := a.MarshalJSON()
+			bodyRC := io.NopCloser(bytes.NewReader(b))
+			return &http.Response{StatusCode: http.StatusOK, Header: cmdtesting.DefaultHeader(), Body: bodyRC}, nil
+		case m == "PATCH" && strings.HasPrefix(p, "/namespaces/"):
+			return getResource(t, resources, p), nil
+		case m == "POST" && req.URL.Query().Get("dryRun") == "All":
+			return dryRunCreate(t, req), nil
+		default:
+			t.Fatalf("unexpected request: %#v\n%#v", req.URL, req)
+			return nil, nil
+		}
+	})
+	// The fake client records the last request it made, which isn't safe for concurrent use: the kinds are listed in
+	// parallel and multiple references are compared in parallel, so every client gets its own fake
+	tf.UnstructuredClientForMappingFunc = func(schema.GroupVersion) (resource.RESTClient, error) {
+		return &fake.RESTClient{
+			NegotiatedSerializer: resource.UnstructuredPlusDefaultContentConfig().NegotiatedSerializer,
+			Client:               client,
+		}, nil
+	}
+}
+
+// setFactsClient serves the objects the cluster facts are discovered from with the dynamic client
+func setFactsClient(resources []*unstructured.Unstructured, tf *cmdtesting.TestFactory) {
+	var facts []runtime.Object
+	for _, r := range resources {
+		switch r.GetKind() {
+		case "ClusterVersion", "Infrastructure", "CustomResourceDefinition", "Node":
+			facts = append(facts, r)
+		}
+	}
+	listKinds := map[schema.GroupVersionResource]string{
+		nodeGVR: "NodeList",
+		crdGVR:  "CustomResourceDefinitionList",
+	}
+	tf.FakeDynamicClient = fakedynamic.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), listKinds, facts...)
+}
+
+// getResource fakes the get requests of a namespaced resource by name, e.g. /namespaces/<ns>/secrets/<name>
+func getResource(t *testing.T, resources []*unstructured.Unstructured, p string) *http.Response {
+	parts := strings.Split(strings.TrimPrefix(p, "/namespaces/"), "/")
+	require.Len(t, parts, 3, "unexpected get request %s", p)
+	for _, r := range resources {
+		if r.GetNamespace() == parts[0] && strings.ToLower(r.GetKind())+"s" == parts[1] && r.GetName() == parts[2] {
+			b, err := r.MarshalJSON()
+			require.NoError(t, err)
+			return &http.Response{StatusCode: http.StatusOK, Header: cmdtesting.DefaultHeader(), Body: io.NopCloser(bytes.NewReader(b))}
+		}
+	}
+	status := apierrors.NewNotFound(schema.GroupResource{Resource: parts[1]}, parts[2]).ErrStatus
+	status.SetGroupVersionKind(v1.SchemeGroupVersion.WithKind("Status"))
+	b, err := json.Marshal(status)
+	require.NoError(t, err)
+	return &http.Response{StatusCode: http.StatusNotFound, Header: cmdtesting.DefaultHeader(), Body: io.NopCloser(bytes.NewReader(b))}
+}
+
+// staticCorrelator is a custom correlator matching all the CRs to the same template
+type staticCorrelator struct {
+	temp ReferenceTemplate
+}
+
+func (c staticCorrelator) Match(*unstructured.Unstructured) ([]ReferenceTemplate, error) {
+	return []ReferenceTemplate{c.temp}, nil
+}
 
-				require.NoError(t, unstructured.SetNestedSlice(a.Object, requestedResources, "items"))
-				b, _ := a.MarshalJSON()
-				bodyRC := io.NopCloser(bytes.NewReader(b))
-				return &http.Response{StatusCode: http.StatusOK, Header: cmdtesting.DefaultHeader(), Body: bodyRC}, nil
-			default:
-				t.Fatalf("unexpected request: %#v\n%#v", req.URL, req)
-				return nil, nil
+// templatePathCorrelator creates a custom correlator matching all the CRs to the template with the path
+func templatePathCorrelator(path string) CorrelatorFactory {
+	return func(templates []ReferenceTemplate) (Correlator[ReferenceTemplate], error) {
+		for _, temp := range templates {
+			if temp.GetPath() == path {
+				return staticCorrelator{temp: temp}, nil
 			}
-		}),
+		}
+		return nil, fmt.Errorf("template %s not found", path)
+	}
+}
+
+// dryRunCreate fakes the defaulting and validation of the API server for the dry run create requests of Deployments
+func dryRunCreate(t *testing.T, req *http.Request) *http.Response {
+	body, err := io.ReadAll(req.Body)
+	require.NoError(t, err)
+	obj := unstructured.Unstructured{}
+	require.NoError(t, obj.UnmarshalJSON(body))
+	if _, ok, _ := unstructured.NestedMap(obj.Object, "spec", "selector"); !ok {
+		status := apierrors.NewInvalid(obj.GroupVersionKind().GroupKind(), obj.GetGenerateName(),
+			field.ErrorList{field.Required(field.NewPath("spec", "selector"), "")}).ErrStatus
+		status.SetGroupVersionKind(v1.SchemeGroupVersion.WithKind("Status"))
+		b, err := json.Marshal(status)
+		require.NoError(t, err)
+		return &http.Response{StatusCode: int(status.Code), Header: cmdtesting.DefaultHeader(), Body: io.NopCloser(bytes.NewReader(b))}
+	}
+	obj.SetName(obj.GetGenerateName() + "x7k2p")
+	obj.SetUID("9b0b3c4e-6f0e-4a59-9d5b-3f1c1e5b8a21")
+	obj.SetResourceVersion("1")
+	defaults := map[string]any{
+		"progressDeadlineSeconds": int64(600),
+		"revisionHistoryLimit":    int64(10),
+		"strategy": map[string]any{
+			"type":          "RollingUpdate",
+			"rollingUpdate": map[string]any{"maxSurge": "25%", "maxUnavailable": "25%"},
+		},
 	}
+	for k, v := range defaults {
+		if _, ok, _ := unstructured.NestedFieldNoCopy(obj.Object, "spec", k); !ok {
+			require.NoError(t, unstructured.SetNestedField(obj.Object, v, "spec", k))
+		}
+	}
+	obj.Object["status"] = map[string]any{}
+	b, err := obj.MarshalJSON()
+	require.NoError(t, err)
+	return &http.Response{StatusCode: http.StatusCreated, Header: cmdtesting.DefaultHeader(), Body: io.NopCloser(bytes.NewReader(b))}
 }
 
 func getResources(t *testing.T, test Test, resourcesDir string) ([]v1.APIResource, []*unstructured.Unstructured) {
@@ -785,8 +2211,18 @@ func getResources(t *testing.T, test Test, resourcesDir string) ([]v1.APIResourc
 	return rL, resources
 }
 
+// fakeServerVersion serves the version of the fake cluster
+type fakeServerVersion struct {
+	discovery.DiscoveryInterface
+}
+
+func (fakeServerVersion) ServerVersion() (*version.Info, error) {
+	return &version.Info{GitVersion: "v1.29.4"}, nil
+}
+
 func updateTestDiscoveryClient(tf *cmdtesting.TestFactory, discoveryResources []v1.APIResource) {
 	discoveryClient := cmdtesting.NewFakeCachedDiscoveryClient()
+	discoveryClient.DiscoveryInterface = fakeServerVersion{}
 	ResourceList := v1.APIResourceList{APIResources: discoveryResources}
 	discoveryClient.Resources = append(discoveryClient.Resources, &ResourceList)
 	discoveryClient.PreferredResources = append(discoveryClient.PreferredResources, &ResourceList)
@@ -0,0 +1,89 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+const (
+	// ComparisonModeFull compares all the fields of the cluster CR, the fields the template doesn't set are reported
+	ComparisonModeFull = "full"
+	// ComparisonModeSubset only compares the fields of the cluster CR the rendered template sets
+	ComparisonModeSubset = "subset"
+)
+
+var ComparisonModes = []string{ComparisonModeFull, ComparisonModeSubset}
+
+func validateComparisonMode(mode string) error {
+	if mode != "" && !slices.Contains(ComparisonModes, mode) {
+		return fmt.Errorf("invalid comparisonMode %q, must be one of: %s", mode, strings.Join(ComparisonModes, ", "))
+	}
+	return nil
+}
+
+// pruneToShape returns the fields of the value that are set in the shape, e.g. the fields of a cluster CR the template
+// sets. The items of lists are pruned to the union of the shapes of the items of the list in the shape, so the lists
+// are pruned the same way whatever the order of their items. Values that aren't maps or lists are kept as is, the value
+// isn't modified.
+func pruneToShape(value, shape any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		s, ok := shape.(map[string]any)
+		if !ok {
+			return value
+		}
+		pruned := make(map[string]any, len(s))
+		for key, child := range v {
+			if childShape, ok := s[key]; ok {
+				pruned[key] = pruneToShape(child, childShape)
+			}
+		}
+		return pruned
+	case []any:
+		s, ok := shape.([]any)
+		if !ok || len(s) == 0 {
+			return value
+		}
+		itemShape := unionShape(s)
+		pruned := make([]any, 0, len(v))
+		for _, item := range v {
+			pruned = append(pruned, pruneToShape(item, itemShape))
+		}
+		return pruned
+	}
+	return value
+}
+
+// unionShape returns the shape holding the fields of all the values, the values of the fields are the ones of the last
+// value setting them
+func unionShape(values []any) any {
+	var union any
+	for _, value := range values {
+		union = mergeShapes(union, value)
+	}
+	return union
+}
+
+func mergeShapes(a, b any) any {
+	am, aIsMap := a.(map[string]any)
+	bm, bIsMap := b.(map[string]any)
+	if aIsMap && bIsMap {
+		merged := make(map[string]any, len(am)+len(bm))
+		for key, value := range am {
+			merged[key] = value
+		}
+		for key, value := range bm {
+			merged[key] = mergeShapes(merged[key], value)
+		}
+		return merged
+	}
+	al, aIsList := a.([]any)
+	bl, bIsList := b.([]any)
+	if aIsList && bIsList {
+		return []any{unionShape(slices.Concat(al, bl))}
+	}
+	return b
+}
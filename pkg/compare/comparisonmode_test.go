@@ -0,0 +1,53 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPruneToShape(t *testing.T) {
+	live := map[string]any{
+		"metadata": map[string]any{"name": "web", "labels": map[string]any{"app": "web"}},
+		"spec": map[string]any{
+			"replicas": int64(3),
+			"paused":   false,
+			"containers": []any{
+				map[string]any{"name": "b", "image": "b:1", "imagePullPolicy": "Always"},
+				map[string]any{"name": "a", "image": "a:1", "ports": []any{map[string]any{"containerPort": int64(80), "protocol": "TCP"}}},
+			},
+			"args": []any{"--one", "--two"},
+		},
+	}
+	shape := map[string]any{
+		"metadata": map[string]any{"name": "web"},
+		"spec": map[string]any{
+			"replicas": int64(2),
+			"containers": []any{
+				map[string]any{"name": "a", "image": "a:1"},
+				map[string]any{"name": "b", "ports": []any{map[string]any{"containerPort": int64(80)}}},
+			},
+			"args": []any{"--one"},
+		},
+	}
+	require.Equal(t, map[string]any{
+		"metadata": map[string]any{"name": "web"},
+		"spec": map[string]any{
+			"replicas": int64(3),
+			"containers": []any{
+				map[string]any{"name": "b", "image": "b:1"},
+				map[string]any{"name": "a", "image": "a:1", "ports": []any{map[string]any{"containerPort": int64(80)}}},
+			},
+			"args": []any{"--one", "--two"},
+		},
+	}, pruneToShape(live, shape), "list items should be pruned to the union of the items of the template, whatever their order")
+	require.Contains(t, live["metadata"], "labels", "the pruned value shouldn't be modified")
+}
+
+func TestValidateComparisonMode(t *testing.T) {
+	require.NoError(t, validateComparisonMode(""))
+	require.NoError(t, validateComparisonMode(ComparisonModeSubset))
+	require.Error(t, validateComparisonMode("partial"))
+}
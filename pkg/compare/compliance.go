@@ -0,0 +1,122 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/Masterminds/sprig/v3"
+	"sigs.k8s.io/yaml"
+)
+
+// CompliantCR is a cluster CR that matched a reference template without any differences
+type CompliantCR struct {
+	CRName             string `json:"CRName"`
+	CorrelatedTemplate string `json:"CorrelatedTemplate"`
+}
+
+// ComplianceOutput lists the cluster CRs that comply with the reference, grouped by the part and component of the
+// template they matched
+type ComplianceOutput struct {
+	CompliantCRs    map[string]map[string][]CompliantCR `json:"CompliantCRs"`
+	NumCompliantCRs int                                 `json:"NumCompliantCRs"`
+	TotalCRs        int                                 `json:"TotalCRs"`
+	MetadataHash    string                              `json:"MetadataHash"`
+}
+
+// newComplianceOutput groups the diffs without differences by component. CRs compared to a template patched by user
+// overrides aren't listed, the overrides may be hiding differences from the reference.
+func newComplianceOutput(ref Reference, sum *Summary, diffs []DiffSum) ComplianceOutput {
+	componentsOf := make(map[string][][2]string)
+	for part, components := range ref.GetComponentTemplates() {
+		for component, paths := range components {
+			for _, p := range paths {
+				componentsOf[p] = append(componentsOf[p], [2]string{part, component})
+			}
+		}
+	}
+
+	output := ComplianceOutput{
+		CompliantCRs: make(map[string]map[string][]CompliantCR),
+		TotalCRs:     sum.TotalCRs,
+		MetadataHash: sum.MetadataHash,
+	}
+	for _, d := range diffs {
+		if d.HasDiff() || d.WasPatched() {
+			continue
+		}
+		output.NumCompliantCRs++
+		for _, pc := range componentsOf[d.CorrelatedTemplate] {
+			if output.CompliantCRs[pc[0]] == nil {
+				output.CompliantCRs[pc[0]] = make(map[string][]CompliantCR)
+			}
+			output.CompliantCRs[pc[0]][pc[1]] = append(output.CompliantCRs[pc[0]][pc[1]],
+				CompliantCR{CRName: d.CRName, CorrelatedTemplate: d.CorrelatedTemplate})
+		}
+	}
+	for _, components := range output.CompliantCRs {
+		for _, crs := range components {
+			sort.Slice(crs, func(i, j int) bool {
+				return crs[i].CorrelatedTemplate+crs[i].CRName < crs[j].CorrelatedTemplate+crs[j].CRName
+			})
+		}
+	}
+	return output
+}
+
+func (o ComplianceOutput) String() string {
+	t := `
+Compliant CRs
+{{- range $partname, $part := .CompliantCRs }}
+{{ $partname }}:
+  {{- range $componentname, $crs := $part }}
+  {{ $componentname }}:
+    {{- range $cr := $crs }}
+    - {{ $cr.CRName }} ({{ $cr.CorrelatedTemplate }})
+    {{- end }}
+  {{- end }}
+{{- else }}
+No cluster CRs match the reference without differences
+{{- end }}
+
+Summary
+CRs without diffs: {{ .NumCompliantCRs }}/{{ .TotalCRs }}
+Metadata Hash: {{ .MetadataHash }}
+`
+	var buf bytes.Buffer
+	tmpl, _ := template.New("Compliance").Funcs(sprig.TxtFuncMap()).Parse(t)
+	_ = tmpl.Execute(&buf, o)
+	return strings.TrimSpace(buf.String()) + "\n"
+}
+
+func (o ComplianceOutput) Print(format string, out io.Writer) error {
+	var (
+		content []byte
+		err     error
+	)
+	switch format {
+	case Json:
+		content, err = json.Marshal(o)
+		if err != nil {
+			return fmt.Errorf("failed to marshal output to json: %w", err)
+		}
+		content = append(content, []byte("\n")...)
+	case Yaml:
+		content, err = yaml.Marshal(o)
+		if err != nil {
+			return fmt.Errorf("failed to marshal output to yaml: %w", err)
+		}
+	default:
+		content = []byte(o.String())
+	}
+	if _, err := out.Write(content); err != nil {
+		return fmt.Errorf("error occurred when writing output: %w", err)
+	}
+	return nil
+}
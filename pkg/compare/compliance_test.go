@@ -0,0 +1,29 @@
+package compare
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewComplianceOutput(t *testing.T) {
+	ref := &ReferenceV1{Parts: []PartV1{{Name: "part", Components: []ComponentV1{
+		{Name: "comp", RequiredTemplates: []*ReferenceTemplateV1{{Path: "a.yaml"}, {Path: "b.yaml"}, {Path: "c.yaml"}}},
+	}}}}
+	diffs := []DiffSum{
+		{CRName: "cr-b", CorrelatedTemplate: "b.yaml"},
+		{CRName: "cr-a", CorrelatedTemplate: "a.yaml"},
+		{CRName: "cr-diff", CorrelatedTemplate: "a.yaml", DiffOutput: "diff"},
+		{CRName: "cr-patched", CorrelatedTemplate: "c.yaml", Patched: "patches.yaml"},
+	}
+	out := newComplianceOutput(ref, &Summary{TotalCRs: 4, MetadataHash: "hash"}, diffs)
+	assert.Equal(t, ComplianceOutput{
+		CompliantCRs: map[string]map[string][]CompliantCR{"part": {"comp": {
+			{CRName: "cr-a", CorrelatedTemplate: "a.yaml"},
+			{CRName: "cr-b", CorrelatedTemplate: "b.yaml"},
+		}}},
+		NumCompliantCRs: 2,
+		TotalCRs:        4,
+		MetadataHash:    "hash",
+	}, out)
+}
@@ -0,0 +1,69 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+const (
+	noTemplatesAfterComponentFilter = "no templates in the reference are in components included by --components and --skip-components"
+	unknownComponents               = "components not found in the reference: %s, the reference has the components: %s"
+)
+
+// componentFilter limits a run to a subset of the components of the reference, components are matched by name
+type componentFilter struct {
+	include []string
+	exclude []string
+}
+
+func (f componentFilter) isSet() bool {
+	return len(f.include) > 0 || len(f.exclude) > 0
+}
+
+func (f componentFilter) includes(component string) bool {
+	if len(f.include) > 0 && !slices.Contains(f.include, component) {
+		return false
+	}
+	return !slices.Contains(f.exclude, component)
+}
+
+// filterReference returns a copy of the reference without the components that aren't included, so templates of other
+// components are neither compared nor reported missing. The reference itself is left whole, the copy shares its
+// templates.
+func (f componentFilter) filterReference(ref Reference) (Reference, error) {
+	var names []string
+	switch r := ref.(type) {
+	case *ReferenceV1:
+		names = r.getComponentNames()
+	case *ReferenceV2:
+		names = r.getComponentNames()
+	default:
+		return nil, fmt.Errorf("unknown reference file apiVersion: '%s'", ref.GetAPIVersion())
+	}
+	var unknown []string
+	for _, name := range slices.Concat(f.include, f.exclude) {
+		if !slices.Contains(names, name) && !slices.Contains(unknown, name) {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		slices.Sort(names)
+		return nil, fmt.Errorf(unknownComponents, strings.Join(unknown, ", "), strings.Join(slices.Compact(names), ", "))
+	}
+
+	var filtered Reference
+	switch r := ref.(type) {
+	case *ReferenceV1:
+		filtered = r.filterComponents(f.includes)
+	case *ReferenceV2:
+		filtered = r.filterComponents(f.includes)
+	}
+	if len(filtered.GetTemplates()) == 0 {
+		return nil, errors.New(noTemplatesAfterComponentFilter)
+	}
+	return filtered, nil
+}
@@ -0,0 +1,31 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilterReferenceKeepsMetadataHash(t *testing.T) {
+	test := defaultTest("Component Filters")
+	fsys := os.DirFS(path.Join(test.getTestDir(), TestRefDirName))
+	for _, referenceFile := range []string{defaultReferenceFilename, "metadata_v1.yaml"} {
+		t.Run(referenceFile, func(t *testing.T) {
+			ref, err := GetReference(fsys, referenceFile)
+			require.NoError(t, err)
+			templates, err := ParseTemplates(ref, fsys)
+			require.NoError(t, err)
+			hash := getMetadataHash(ref, templates)
+
+			filtered, err := componentFilter{exclude: []string{"Volumes"}}.filterReference(ref)
+			require.NoError(t, err)
+			require.Len(t, filtered.GetTemplates(), len(templates)-1)
+			require.Len(t, ref.GetTemplates(), len(templates), "the reference shouldn't be filtered in place")
+			require.Equal(t, hash, getMetadataHash(ref, ref.GetTemplates()))
+		})
+	}
+}
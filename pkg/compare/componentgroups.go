@@ -0,0 +1,91 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"fmt"
+	"sort"
+)
+
+// referenceComponent is a component of the reference, identified by its part and component names
+type referenceComponent struct {
+	part      string
+	component string
+}
+
+// templateComponents maps the paths of the templates to the component they're reported under. Templates used by
+// several components are reported under the first one, by part and component names.
+func templateComponents(ref Reference) map[string]referenceComponent {
+	result := make(map[string]referenceComponent)
+	for part, components := range ref.GetComponentTemplates() {
+		for component, paths := range components {
+			c := referenceComponent{part: part, component: component}
+			for _, p := range paths {
+				if existing, ok := result[p]; !ok || c.less(existing) {
+					result[p] = c
+				}
+			}
+		}
+	}
+	return result
+}
+
+func (c referenceComponent) less(other referenceComponent) bool {
+	if c.part != other.part {
+		return c.part < other.part
+	}
+	return c.component < other.component
+}
+
+// ComponentStats are the statistics of the cluster CRs correlated to the templates of a reference component
+type ComponentStats struct {
+	Part          string `json:"Part"`
+	Component     string `json:"Component"`
+	CorrelatedCRs int    `json:"CorrelatedCRs"`
+	CRsWithDiffs  int    `json:"CRsWithDiffs"`
+}
+
+func (s ComponentStats) String() string {
+	return fmt.Sprintf("Component: %s/%s (CRs with diffs: %d/%d)", s.Part, s.Component, s.CRsWithDiffs, s.CorrelatedCRs)
+}
+
+// componentStats returns the statistics of every component the diffs are reported under, sorted by part and component
+// names
+func componentStats(diffs []DiffSum) []ComponentStats {
+	counter := componentCounter{}
+	for _, d := range diffs {
+		counter.add(d)
+	}
+	return counter.stats()
+}
+
+// componentCounter counts the CRs correlated to the templates of every component as they're compared
+type componentCounter map[referenceComponent]*ComponentStats
+
+func (c componentCounter) add(d DiffSum) {
+	if d.Part == "" {
+		return
+	}
+	component := referenceComponent{part: d.Part, component: d.Component}
+	stats, ok := c[component]
+	if !ok {
+		stats = &ComponentStats{Part: d.Part, Component: d.Component}
+		c[component] = stats
+	}
+	stats.CorrelatedCRs++
+	if d.HasDiff() {
+		stats.CRsWithDiffs++
+	}
+}
+
+// stats returns the statistics of the components sorted by part and component names
+func (c componentCounter) stats() []ComponentStats {
+	var result []ComponentStats
+	for _, stats := range c {
+		result = append(result, *stats)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return referenceComponent{result[i].Part, result[i].Component}.less(referenceComponent{result[j].Part, result[j].Component})
+	})
+	return result
+}
@@ -0,0 +1,122 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// TemplateCondition restricts a template to the clusters matching it, all the conditions that are set must hold.
+// Templates of other clusters are reported as not applicable instead of missing.
+type TemplateCondition struct {
+	// CRDs are the names of the CustomResourceDefinitions that must be installed, e.g.
+	// performanceprofiles.performance.openshift.io
+	CRDs []string `json:"crds,omitempty"`
+	// OpenShiftVersion is the range of versions of OpenShift the cluster must run, e.g. ">=4.14"
+	OpenShiftVersion string `json:"openshiftVersion,omitempty"`
+	// Platforms are the infrastructure platforms of which the cluster must run on one, e.g. BareMetal
+	Platforms []string `json:"platforms,omitempty"`
+}
+
+func (c *TemplateCondition) validate() error {
+	if len(c.CRDs) == 0 && c.OpenShiftVersion == "" && len(c.Platforms) == 0 {
+		return errors.New("condition must set at least one of crds, openshiftVersion or platforms")
+	}
+	if c.OpenShiftVersion != "" {
+		if _, err := semver.NewConstraint(c.OpenShiftVersion); err != nil {
+			return fmt.Errorf("invalid openshiftVersion in condition: %w", err)
+		}
+	}
+	return nil
+}
+
+// unmet returns the reason the condition doesn't hold for the cluster, an empty string if it holds
+func (f *ClusterFacts) unmet(c *TemplateCondition) string {
+	if c == nil {
+		return ""
+	}
+	for _, crd := range c.CRDs {
+		if !f.CRDs[crd] {
+			return fmt.Sprintf("CRD %s is not installed", crd)
+		}
+	}
+	if c.OpenShiftVersion != "" {
+		if f.OpenShiftVersion == "" {
+			return fmt.Sprintf("requires OpenShift %s, the OpenShift version of the cluster is unknown", c.OpenShiftVersion)
+		}
+		constraints, err := semver.NewConstraint(c.OpenShiftVersion)
+		if err != nil {
+			return fmt.Sprintf("invalid openshiftVersion %q: %s", c.OpenShiftVersion, err)
+		}
+		version, err := semver.NewVersion(f.OpenShiftVersion)
+		if err != nil {
+			return fmt.Sprintf("requires OpenShift %s, the OpenShift version of the cluster %q is invalid", c.OpenShiftVersion, f.OpenShiftVersion)
+		}
+		if !constraints.Check(version) {
+			return fmt.Sprintf("requires OpenShift %s, the cluster runs %s", c.OpenShiftVersion, f.OpenShiftVersion)
+		}
+	}
+	if len(c.Platforms) > 0 && !slices.ContainsFunc(c.Platforms, func(p string) bool { return strings.EqualFold(p, f.Platform) }) {
+		platform := f.Platform
+		if platform == "" {
+			platform = "unknown"
+		}
+		return fmt.Sprintf("requires one of the platforms %s, the platform of the cluster is %s", strings.Join(c.Platforms, ", "), platform)
+	}
+	return ""
+}
+
+// NotApplicableTemplate is a template that wasn't compared because its condition doesn't hold for the cluster
+type NotApplicableTemplate struct {
+	Template string `json:"Template"`
+	Reason   string `json:"Reason"`
+}
+
+// hasConditions checks if any of the templates has a condition
+func hasConditions(templates []ReferenceTemplate) bool {
+	return slices.ContainsFunc(templates, func(t ReferenceTemplate) bool { return t.GetConfig().GetCondition() != nil })
+}
+
+// applyConditions removes the templates whose condition doesn't hold for the compared cluster from the comparison,
+// they're recorded as not applicable so they aren't reported missing. The templates depending on a template that isn't
+// applicable aren't applicable either, the templates are ordered by their dependencies so their dependencies are
+// checked first.
+func (o *Options) applyConditions() error {
+	applicable := make([]ReferenceTemplate, 0, len(o.templates))
+	o.notApplicable = nil
+	notApplicable := make(map[string]bool)
+	for _, t := range o.templates {
+		reason := o.clusterFacts.unmet(t.GetConfig().GetCondition())
+		if reason == "" {
+			reason = unmetDependency(t, notApplicable)
+		}
+		if reason != "" {
+			o.notApplicable = append(o.notApplicable, NotApplicableTemplate{Template: t.GetPath(), Reason: reason})
+			notApplicable[t.GetPath()] = true
+			continue
+		}
+		applicable = append(applicable, t)
+	}
+	if len(o.notApplicable) == 0 {
+		return nil
+	}
+	sort.Slice(o.notApplicable, func(i, j int) bool { return o.notApplicable[i].Template < o.notApplicable[j].Template })
+	o.templates = applicable
+	// The correlators only correlate CRs to the applicable templates
+	return o.setupCorrelators()
+}
+
+// notApplicableTemplates returns the paths of the templates that weren't applicable to the cluster
+func notApplicableTemplates(templates []NotApplicableTemplate) map[string]bool {
+	paths := make(map[string]bool, len(templates))
+	for _, t := range templates {
+		paths[t.Template] = true
+	}
+	return paths
+}
@@ -0,0 +1,77 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestClusterFactsUnmet(t *testing.T) {
+	openshift := newClusterFacts()
+	openshift.add(&unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "config.openshift.io/v1",
+		"kind":       "ClusterVersion",
+		"metadata":   map[string]any{"name": "version"},
+		"status":     map[string]any{"desired": map[string]any{"version": "4.15.3"}},
+	}})
+	openshift.add(&unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "config.openshift.io/v1",
+		"kind":       "Infrastructure",
+		"metadata":   map[string]any{"name": "cluster"},
+		"status":     map[string]any{"platform": "AWS"},
+	}})
+	openshift.add(&unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "apiextensions.k8s.io/v1",
+		"kind":       "CustomResourceDefinition",
+		"metadata":   map[string]any{"name": "tuneds.tuned.openshift.io"},
+	}})
+
+	tests := []struct {
+		name      string
+		facts     *ClusterFacts
+		condition *TemplateCondition
+		expected  string
+	}{
+		{
+			name:  "no condition",
+			facts: newClusterFacts(),
+		},
+		{
+			name:      "all conditions hold",
+			facts:     openshift,
+			condition: &TemplateCondition{CRDs: []string{"tuneds.tuned.openshift.io"}, OpenShiftVersion: ">=4.14 <4.16", Platforms: []string{"aws"}},
+		},
+		{
+			name:      "missing CRD",
+			facts:     openshift,
+			condition: &TemplateCondition{CRDs: []string{"tuneds.tuned.openshift.io", "nodefeatures.nfd.openshift.io"}},
+			expected:  "CRD nodefeatures.nfd.openshift.io is not installed",
+		},
+		{
+			name:      "version out of range",
+			facts:     openshift,
+			condition: &TemplateCondition{OpenShiftVersion: "<4.15"},
+			expected:  "requires OpenShift <4.15, the cluster runs 4.15.3",
+		},
+		{
+			name:      "not an OpenShift cluster",
+			facts:     newClusterFacts(),
+			condition: &TemplateCondition{OpenShiftVersion: ">=4.14"},
+			expected:  "requires OpenShift >=4.14, the OpenShift version of the cluster is unknown",
+		},
+		{
+			name:      "unknown platform",
+			facts:     newClusterFacts(),
+			condition: &TemplateCondition{Platforms: []string{"BareMetal", "None"}},
+			expected:  "requires one of the platforms BareMetal, None, the platform of the cluster is unknown",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require.Equal(t, test.expected, test.facts.unmet(test.condition))
+		})
+	}
+}
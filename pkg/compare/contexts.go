@@ -0,0 +1,368 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
+	"k8s.io/client-go/tools/clientcmd"
+	kcmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"sigs.k8s.io/yaml"
+)
+
+const ClusterSeparator = "=================================="
+
+// clusterContext is a kubeconfig context of a cluster to compare
+type clusterContext struct {
+	name    string
+	factory kcmdutil.Factory
+}
+
+// newContextFactory creates a factory for the cluster of the kubeconfig context. The context is taken from the
+// kubeconfig loaded by the parent factory, so the kubeconfig it was pointed to (e.g. with KUBECONFIG) is used, and the
+// namespace is kept if it was overridden.
+var newContextFactory = func(f kcmdutil.Factory, context string) (kcmdutil.Factory, error) {
+	loader := f.ToRawKubeConfigLoader()
+	raw, err := loader.RawConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load kubeconfig: %w", err)
+	}
+	if _, ok := raw.Contexts[context]; !ok {
+		return nil, fmt.Errorf("context %q doesn't exist in the kubeconfig", context)
+	}
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: context}
+	if namespace, overridden, err := loader.Namespace(); err == nil && overridden {
+		overrides.Context.Namespace = namespace
+	}
+	return kcmdutil.NewFactory(newClientConfigGetter(clientcmd.NewNonInteractiveClientConfig(raw, context, overrides, nil))), nil
+}
+
+// clientConfigGetter creates the clients of a cluster from its client config, the discovery client and the REST mapper
+// are created once and cached in memory
+type clientConfigGetter struct {
+	config clientcmd.ClientConfig
+
+	discoveryOnce   sync.Once
+	discoveryClient discovery.CachedDiscoveryInterface
+	restMapper      meta.RESTMapper
+	err             error
+}
+
+func newClientConfigGetter(config clientcmd.ClientConfig) *clientConfigGetter {
+	return &clientConfigGetter{config: config}
+}
+
+func (g *clientConfigGetter) ToRESTConfig() (*rest.Config, error) {
+	return g.config.ClientConfig() // nolint:wrapcheck
+}
+
+func (g *clientConfigGetter) ToRawKubeConfigLoader() clientcmd.ClientConfig {
+	return g.config
+}
+
+func (g *clientConfigGetter) ToDiscoveryClient() (discovery.CachedDiscoveryInterface, error) {
+	g.discover()
+	return g.discoveryClient, g.err
+}
+
+func (g *clientConfigGetter) ToRESTMapper() (meta.RESTMapper, error) {
+	g.discover()
+	return g.restMapper, g.err
+}
+
+func (g *clientConfigGetter) discover() {
+	g.discoveryOnce.Do(func() {
+		config, err := g.ToRESTConfig()
+		if err != nil {
+			g.err = err
+			return
+		}
+		// Discovery sends a burst of requests, the same limits as kubectl's are used
+		config.Burst = 300
+		config.QPS = 50
+		client, err := discovery.NewDiscoveryClientForConfig(config)
+		if err != nil {
+			g.err = fmt.Errorf("failed to create discovery client: %w", err)
+			return
+		}
+		g.discoveryClient = memory.NewMemCacheClient(client)
+		mapper := restmapper.NewDeferredDiscoveryRESTMapper(g.discoveryClient)
+		g.restMapper = restmapper.NewShortcutExpander(mapper, g.discoveryClient, nil)
+	})
+}
+
+// kubeconfigContexts returns the sorted names of the contexts in the kubeconfig
+func kubeconfigContexts(f kcmdutil.Factory) ([]string, error) {
+	config, err := f.ToRawKubeConfigLoader().RawConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load kubeconfig: %w", err)
+	}
+	contexts := make([]string, 0, len(config.Contexts))
+	for name := range config.Contexts {
+		contexts = append(contexts, name)
+	}
+	sort.Strings(contexts)
+	return contexts, nil
+}
+
+// validateContextFlags checks the flags that can't be used when comparing multiple clusters
+func (o *Options) validateContextFlags() error {
+	if len(o.contextNames) == 0 && !o.allContexts {
+		return nil
+	}
+	if len(o.contextNames) > 0 && o.allContexts {
+		return usageErrorf("--contexts and --all-contexts can't be used together")
+	}
+	if o.parallelContexts < 1 {
+		return usageErrorf("--parallel-contexts must be at least 1")
+	}
+	if o.OutputFormat == PatchYaml || o.snapshotDir != "" || o.compareToSnapshot != "" || o.exportUnmatched != "" || o.generatePatches != "" ||
+		o.generateConfig != "" || o.extractValues != "" {
+		return usageErrorf("--contexts and --all-contexts can't be used with snapshots, --export-unmatched, --generate-patches, --generate-config, --extract-values or with -o %s", PatchYaml)
+	}
+	return nil
+}
+
+// setContexts resolves the contexts of the clusters to compare
+func (o *Options) setContexts(f kcmdutil.Factory) error {
+	if o.local {
+		return usageErrorf("--contexts and --all-contexts can't be used with local files")
+	}
+	names := o.contextNames
+	if o.allContexts {
+		var err error
+		if names, err = kubeconfigContexts(f); err != nil {
+			return err
+		}
+		if len(names) == 0 {
+			return errors.New("no contexts found in the kubeconfig")
+		}
+	}
+	for _, name := range names {
+		cf, err := newContextFactory(f, name)
+		if err != nil {
+			return err
+		}
+		o.contexts = append(o.contexts, clusterContext{name: name, factory: o.throttled(cf)})
+	}
+	return nil
+}
+
+// compareContext compares the cluster of the context to the reference. The comparison runs on a copy of the options
+// with its own per-cluster state, the loaded reference, templates and correlators are shared.
+func (o *Options) compareContext(ctx context.Context, c clusterContext) ClusterOutput {
+	co := *o
+	co.newBuilder = c.factory.NewBuilder
+	co.factory = c.factory
+	co.metricsTracker = NewMetricsTracker()
+	co.unavailableKinds = &unavailableKinds{}
+	co.renderFailures = &renderFailures{}
+	co.ambiguousMatches = &ambiguousMatches{}
+	co.newUserOverrides = slices.Clone(o.newUserOverrides)
+	if o.serverSideDryRun {
+		co.normalizer = newServerSideNormalizer(c.factory)
+	}
+	if o.enableLookups {
+		co.lookups = newClusterLookup(c.factory, o.lookupQPS)
+	}
+	if o.annotator != nil {
+		co.annotator = newDriftAnnotator(o.annotator.removeAll)
+	}
+	if o.operatorVersions != nil {
+		co.operatorVersions = newOperatorVersionTracker(o.ref.GetOperatorVersions())
+	}
+	if o.parallelContexts > 1 {
+		// Progress of clusters compared concurrently would be interleaved
+		co.Progress = ProgressNever
+	}
+
+	result := ClusterOutput{Context: c.name}
+	var err error
+	if o.validateSchemas && o.schemasPath == "" {
+		co.schemas, err = clusterSchemas(c.factory, o.templates)
+	}
+	if err == nil && o.emitEvents {
+		co.emitter, err = newDriftEventEmitter(c.factory)
+	}
+	if err == nil {
+		err = co.setLiveSearchTypes(c.factory)
+	}
+	if err == nil {
+		err = co.namespaceScope.resolve(c.factory)
+	}
+	if err == nil {
+		var diffs []DiffSum
+		result.Summary, diffs, err = co.compare(ctx)
+		result.Diffs = &diffs
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// runContexts compares the clusters of all the contexts, up to --parallel-contexts at a time, and prints the output of
+// every cluster followed by a roll-up of all of them
+func (o *Options) runContexts(ctx context.Context) error {
+	clusters := make([]ClusterOutput, len(o.contexts))
+	sem := make(chan struct{}, o.parallelContexts)
+	var wg sync.WaitGroup
+	for i, c := range o.contexts {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			clusters[i] = o.compareContext(ctx, c)
+		}()
+	}
+	wg.Wait()
+
+	output := FleetOutput{Clusters: clusters, Summary: newFleetSummary(clusters), color: useColor(o.color, o.Out), groupBy: o.groupBy}
+	if err := output.Print(o.OutputFormat, o.Out, o.verboseOutput); err != nil {
+		return err
+	}
+	if ctx.Err() != nil {
+		return interruptedError{cause: context.Cause(ctx)}
+	}
+	if o.metricsFile != "" {
+		var summaries []contextSummary
+		for _, c := range clusters {
+			if c.Summary != nil {
+				summaries = append(summaries, contextSummary{context: c.Context, summary: c.Summary})
+			}
+		}
+		if err := writeMetricsFile(o.metricsFile, summaries...); err != nil {
+			return err
+		}
+	}
+
+	if len(output.Summary.FailedClusters) > 0 {
+		return fmt.Errorf("failed to compare %d of %d clusters: %s", len(output.Summary.FailedClusters), len(clusters),
+			strings.Join(output.Summary.FailedClusters, ", "))
+	}
+	var summaries []*Summary
+	for _, c := range clusters {
+		summaries = append(summaries, c.Summary)
+	}
+	return o.exitPolicy.exitError(summaries...)
+}
+
+// ClusterOutput is the output of the comparison of the cluster of a context, the error is set if it couldn't be
+// compared
+type ClusterOutput struct {
+	Context string     `json:"Context"`
+	Summary *Summary   `json:"Summary,omitempty"`
+	Diffs   *[]DiffSum `json:"Diffs,omitempty"`
+	Error   string     `json:"Error,omitempty"`
+}
+
+// FleetSummary rolls up the summaries of all the compared clusters
+type FleetSummary struct {
+	Clusters          int      `json:"Clusters"`
+	ClustersWithDiffs []string `json:"ClustersWithDiffs"`
+	FailedClusters    []string `json:"FailedClusters"`
+	TotalCRs          int      `json:"TotalCRs"`
+	NumDiffCRs        int      `json:"NumDiffCRs"`
+	NumMissing        int      `json:"NumMissing"`
+	NumUnmatchedCRs   int      `json:"NumUnmatchedCRs"`
+}
+
+func newFleetSummary(clusters []ClusterOutput) *FleetSummary {
+	s := FleetSummary{Clusters: len(clusters), ClustersWithDiffs: []string{}, FailedClusters: []string{}}
+	for _, c := range clusters {
+		if c.Summary == nil {
+			s.FailedClusters = append(s.FailedClusters, c.Context)
+			continue
+		}
+		if c.Summary.hasDiffs() {
+			s.ClustersWithDiffs = append(s.ClustersWithDiffs, c.Context)
+		}
+		s.TotalCRs += c.Summary.TotalCRs
+		s.NumDiffCRs += c.Summary.NumDiffCRs
+		s.NumMissing += c.Summary.NumMissing
+		s.NumUnmatchedCRs += len(c.Summary.UnmatchedCRS)
+	}
+	return &s
+}
+
+func (s FleetSummary) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "\nFleet Summary\nClusters compared: %d\n", s.Clusters)
+	fmt.Fprintf(&sb, "Clusters with diffs: %d\n", len(s.ClustersWithDiffs))
+	for _, c := range s.ClustersWithDiffs {
+		fmt.Fprintf(&sb, "- %s\n", c)
+	}
+	if len(s.FailedClusters) > 0 {
+		fmt.Fprintf(&sb, "Clusters that couldn't be compared: %d\n", len(s.FailedClusters))
+		for _, c := range s.FailedClusters {
+			fmt.Fprintf(&sb, "- %s\n", c)
+		}
+	}
+	fmt.Fprintf(&sb, "CRs with diffs: %d/%d\n", s.NumDiffCRs, s.TotalCRs)
+	fmt.Fprintf(&sb, "CRs in reference missing from the clusters: %d\n", s.NumMissing)
+	fmt.Fprintf(&sb, "Cluster CRs unmatched to reference CRs: %d\n", s.NumUnmatchedCRs)
+	return sb.String()
+}
+
+// FleetOutput is the output of comparing multiple clusters
+type FleetOutput struct {
+	Clusters []ClusterOutput `json:"Clusters"`
+	Summary  *FleetSummary   `json:"Summary"`
+	color    bool
+	groupBy  string
+}
+
+func (o FleetOutput) String(showEmptyDiffs bool) string {
+	var sb strings.Builder
+	for _, c := range o.Clusters {
+		fmt.Fprintf(&sb, "%s\nCluster: %s\n%s\n", ClusterSeparator, c.Context, ClusterSeparator)
+		if c.Error != "" {
+			fmt.Fprintf(&sb, "Error: %s\n\n", c.Error)
+			continue
+		}
+		sb.WriteString(Output{Summary: c.Summary, Diffs: c.Diffs, color: o.color, groupBy: o.groupBy}.String(showEmptyDiffs))
+		sb.WriteString("\n")
+	}
+	sb.WriteString(o.Summary.String())
+	return sb.String()
+}
+
+func (o FleetOutput) Print(format string, out io.Writer, showEmptyDiffs bool) error {
+	var (
+		content []byte
+		err     error
+	)
+	switch format {
+	case Json:
+		content, err = json.Marshal(o)
+		if err != nil {
+			return fmt.Errorf("failed to marshal output to json: %w", err)
+		}
+		content = append(content, []byte("\n")...)
+	case Yaml:
+		content, err = yaml.Marshal(o)
+		if err != nil {
+			return fmt.Errorf("failed to marshal output to yaml: %w", err)
+		}
+	default:
+		content = []byte(o.String(showEmptyDiffs))
+	}
+	if _, err := out.Write(content); err != nil {
+		return fmt.Errorf("error occurred when writing output: %w", err)
+	}
+	return nil
+}
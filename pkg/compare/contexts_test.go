@@ -0,0 +1,99 @@
+package compare
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	kcmdutil "k8s.io/kubectl/pkg/cmd/util"
+)
+
+const testKubeconfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: east
+  cluster:
+    server: https://east.example.com:6443
+- name: west
+  cluster:
+    server: https://west.example.com:6443
+users:
+- name: admin
+  user:
+    token: token
+contexts:
+- name: prod-east
+  context:
+    cluster: east
+    user: admin
+    namespace: east-ns
+- name: prod-west
+  context:
+    cluster: west
+    user: admin
+current-context: prod-east
+`
+
+func TestNewContextFactory(t *testing.T) {
+	kubeconfig := filepath.Join(t.TempDir(), "kubeconfig")
+	require.NoError(t, os.WriteFile(kubeconfig, []byte(testKubeconfig), 0o600))
+	newParent := func(namespace string) kcmdutil.Factory {
+		configFlags := genericclioptions.NewConfigFlags(true)
+		configFlags.KubeConfig = &kubeconfig
+		configFlags.Namespace = &namespace
+		return kcmdutil.NewFactory(configFlags)
+	}
+
+	f, err := newContextFactory(newParent(""), "prod-west")
+	require.NoError(t, err)
+	config, err := f.ToRESTConfig()
+	require.NoError(t, err)
+	assert.Equal(t, "https://west.example.com:6443", config.Host, "the context should be loaded from the kubeconfig of the parent")
+	namespace, _, err := f.ToRawKubeConfigLoader().Namespace()
+	require.NoError(t, err)
+	assert.Equal(t, "default", namespace)
+
+	f, err = newContextFactory(newParent("override-ns"), "prod-east")
+	require.NoError(t, err)
+	namespace, _, err = f.ToRawKubeConfigLoader().Namespace()
+	require.NoError(t, err)
+	assert.Equal(t, "override-ns", namespace)
+
+	_, err = newContextFactory(newParent(""), "missing")
+	require.EqualError(t, err, `context "missing" doesn't exist in the kubeconfig`)
+}
+
+func TestFleetSummary(t *testing.T) {
+	clusters := []ClusterOutput{
+		{Context: "a", Summary: &Summary{TotalCRs: 3, NumDiffCRs: 1, NumMissing: 2, UnmatchedCRS: []string{"x"}}, Diffs: &[]DiffSum{}},
+		{Context: "b", Summary: &Summary{TotalCRs: 4}, Diffs: &[]DiffSum{}},
+		{Context: "c", Error: "failed to create discovery client: unreachable"},
+	}
+	sum := newFleetSummary(clusters)
+	assert.Equal(t, &FleetSummary{
+		Clusters:          3,
+		ClustersWithDiffs: []string{"a"},
+		FailedClusters:    []string{"c"},
+		TotalCRs:          7,
+		NumDiffCRs:        1,
+		NumMissing:        2,
+		NumUnmatchedCRs:   1,
+	}, sum)
+
+	out := FleetOutput{Clusters: clusters, Summary: sum}.String(false)
+	assert.Contains(t, out, "Cluster: c\n"+ClusterSeparator+"\nError: failed to create discovery client: unreachable\n")
+	assert.Contains(t, out, `
+Fleet Summary
+Clusters compared: 3
+Clusters with diffs: 1
+- a
+Clusters that couldn't be compared: 1
+- c
+CRs with diffs: 1/7
+CRs in reference missing from the clusters: 2
+Cluster CRs unmatched to reference CRs: 1
+`)
+}
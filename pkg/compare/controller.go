@@ -0,0 +1,515 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/klog/v2"
+	kcmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"k8s.io/kubectl/pkg/util/i18n"
+	"k8s.io/kubectl/pkg/util/templates"
+)
+
+var (
+	controllerLong = templates.LongDesc(`
+		Run in the cluster and compare it on a schedule to the references of the CompareJob CRs.
+
+		The controller compares the cluster to the reference of every CompareJob CR at the interval of the job, and
+		reports the results in the status of the job: the time of the last run, a summary of the results and the
+		Succeeded and Compliant conditions. A job is compliant when the comparison finds no failures according to its
+		exit policy. The summaries of the jobs are served as Prometheus gauges, labeled with the job, and the results
+		can also be published to a config map or report with the publish field of the job.
+
+		The CompareJob CRD must be installed in the cluster. The controller needs to list the resources of the
+		references, and to update the status of the CompareJob CRs.
+	`)
+
+	controllerExample = templates.Examples(`
+		# Run the controller for the CompareJob CRs of all the namespaces, serving the metrics on port 8080:
+		kubectl cluster-compare controller
+
+		# Run the controller for the CompareJob CRs of a namespace, checking which jobs are due every 5 minutes:
+		kubectl cluster-compare controller --watch-namespace compliance --resync 5m
+	`)
+)
+
+// CompareJobGVR is the resource of the CRs describing the comparisons run by the controller
+var CompareJobGVR = schema.GroupVersionResource{Group: "cluster-compare.openshift.io", Version: "v1alpha1", Resource: "comparejobs"}
+
+const (
+	// defaultCompareJobInterval is the interval of the jobs that don't set one
+	defaultCompareJobInterval = 24 * time.Hour
+	// minCompareJobInterval keeps jobs from loading the API server with back to back comparisons
+	minCompareJobInterval = time.Minute
+
+	// ConditionSucceeded tells whether the last comparison of a job ran to completion
+	ConditionSucceeded = "Succeeded"
+	// ConditionCompliant tells whether the cluster matched the reference of a job in the last comparison
+	ConditionCompliant = "Compliant"
+)
+
+// CompareJobSpec is the comparison run by the controller
+type CompareJobSpec struct {
+	// Reference is the path or URL of the reference config file or bundle
+	Reference string `json:"reference"`
+	// Interval is the time between the comparisons (e.g. 6h), 24h by default
+	Interval string `json:"interval,omitempty"`
+	// Args are passed to the comparison, e.g. --ignore-api-defaults, only the flags of compareJobFlags can be set
+	Args []string `json:"args,omitempty"`
+	// Publish publishes the results of the comparisons in the namespace of the job
+	Publish *CompareJobPublish `json:"publish,omitempty"`
+	// Suspend stops the comparisons until it's unset
+	Suspend bool `json:"suspend,omitempty"`
+}
+
+// CompareJobPublish is where the results of a job are published, see --publish-results
+type CompareJobPublish struct {
+	// Target is configmap or report
+	Target string `json:"target"`
+	// Name is the name of the config map or report, the name of the job by default
+	Name string `json:"name,omitempty"`
+}
+
+// CompareJobStatus is the result of the last comparison of a job
+type CompareJobStatus struct {
+	ObservedGeneration int64              `json:"observedGeneration,omitempty"`
+	LastRunTime        *metav1.Time       `json:"lastRunTime,omitempty"`
+	NextRunTime        *metav1.Time       `json:"nextRunTime,omitempty"`
+	Summary            *CompareJobSummary `json:"summary,omitempty"`
+	Conditions         []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// CompareJobSummary are the counts of the summary of the last comparison of a job
+type CompareJobSummary struct {
+	TotalCRs     int    `json:"totalCRs"`
+	CRsWithDiffs int    `json:"crsWithDiffs"`
+	MissingCRs   int    `json:"missingCRs"`
+	UnmatchedCRs int    `json:"unmatchedCRs"`
+	MetadataHash string `json:"metadataHash,omitempty"`
+}
+
+// compareJobFlags are the flags the args of a job can set. Anyone allowed to create a job runs the comparison with the
+// service account of the controller, so the args can only tune the comparison: the flags writing to the cluster or to
+// the filesystem of the controller, reading its files or weakening the checks of the reference aren't allowed.
+var compareJobFlags = []string{
+	"all-resources", "show-managed-fields", "explain",
+	"include-kind", "exclude-kind", "field-selector", "namespaces", "namespace-selector", "components", "skip-components",
+	"field-manager", "ignore-field-manager", "only-path", "ignore-path", "ignore-api-defaults", "validate-schemas",
+	"diff-engine", "diff-context",
+	"concurrency", "fetch-concurrency", "qps", "burst", "chunk-size", "retries", "retry-interval", "timeout",
+	"template-timeout", "max-diffs", "fail-fast", "on-template-error",
+	"fail-on-missing", "fail-on-unmatched", "fail-on-severity",
+	"verify-signature", "reference-timeout", "reference-retries", "reference-retry-interval",
+	"release-name", "release-namespace",
+}
+
+// validateArgs checks that the args only set the flags of compareJobFlags, in their long form. Every arg starting with
+// a dash is checked as a flag, even if it's meant as the value of the previous one.
+func validateArgs(args []string) error {
+	var denied []string
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "-") {
+			continue
+		}
+		name, _, _ := strings.Cut(strings.TrimPrefix(arg, "--"), "=")
+		if !strings.HasPrefix(arg, "--") || !slices.Contains(compareJobFlags, name) {
+			denied = append(denied, arg)
+		}
+	}
+	if len(denied) > 0 {
+		return fmt.Errorf("args %s aren't allowed, only the flags --%s can be set", strings.Join(denied, ", "),
+			strings.Join(compareJobFlags, ", --"))
+	}
+	return nil
+}
+
+func (spec CompareJobSpec) interval() (time.Duration, error) {
+	if spec.Interval == "" {
+		return defaultCompareJobInterval, nil
+	}
+	interval, err := time.ParseDuration(spec.Interval)
+	if err != nil {
+		return 0, fmt.Errorf("invalid interval: %w", err)
+	}
+	if interval < minCompareJobInterval {
+		return 0, fmt.Errorf("the interval must be at least %s", minCompareJobInterval)
+	}
+	return interval, nil
+}
+
+func (spec CompareJobSpec) validate() error {
+	var errs []error
+	if spec.Reference == "" {
+		errs = append(errs, errors.New("the reference is required"))
+	}
+	if _, err := spec.interval(); err != nil {
+		errs = append(errs, err)
+	}
+	if spec.Publish != nil && !slices.Contains(PublishTargets, spec.Publish.Target) {
+		errs = append(errs, fmt.Errorf("invalid publish target %q, must be one of: %s", spec.Publish.Target, strings.Join(PublishTargets, ", ")))
+	}
+	if err := validateArgs(spec.Args); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// compareArgs returns the args of the comparison of the job, the reference, the output format and the namespace the
+// results are published to are set last so the args of the job can't override them
+func (spec CompareJobSpec) compareArgs(namespace, name string) []string {
+	args := slices.Clone(spec.Args)
+	if spec.Publish != nil {
+		publishName := spec.Publish.Name
+		if publishName == "" {
+			publishName = name
+		}
+		args = append(args, "--publish-results", spec.Publish.Target, "--publish-name", publishName)
+	}
+	return append(args, "--publish-namespace", namespace, "--reference", spec.Reference, "--output", Json)
+}
+
+// compareJob is a CompareJob CR
+type compareJob struct {
+	obj    *unstructured.Unstructured
+	spec   CompareJobSpec
+	status CompareJobStatus
+}
+
+func newCompareJob(obj *unstructured.Unstructured) (*compareJob, error) {
+	job := &compareJob{obj: obj}
+	if spec, ok := obj.Object["spec"].(map[string]any); ok {
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(spec, &job.spec); err != nil {
+			return nil, fmt.Errorf("invalid spec: %w", err)
+		}
+	}
+	if status, ok := obj.Object["status"].(map[string]any); ok {
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(status, &job.status); err != nil {
+			return nil, fmt.Errorf("invalid status: %w", err)
+		}
+	}
+	return job, nil
+}
+
+func (job *compareJob) key() string {
+	return job.obj.GetNamespace() + "/" + job.obj.GetName()
+}
+
+// due checks if the job should be run: it wasn't run since its spec changed or its interval elapsed
+func (job *compareJob) due(now time.Time, interval time.Duration) bool {
+	if job.spec.Suspend {
+		return false
+	}
+	if job.status.LastRunTime == nil || job.status.ObservedGeneration != job.obj.GetGeneration() {
+		return true
+	}
+	return !now.Before(job.status.LastRunTime.Add(interval))
+}
+
+// jobResult is the result of the last comparison of a job, served as metrics
+type jobResult struct {
+	summary *Summary
+	failed  bool
+	lastRun time.Time
+}
+
+// compareFunc compares the cluster to the reference of the job, it returns the summary and whether the comparison
+// found failures according to the exit policy of the job
+type compareFunc func(ctx context.Context, namespace, name string, spec CompareJobSpec) (*Summary, bool, error)
+
+// compareJobController runs the comparisons of the CompareJob CRs when they're due and reports their results in the
+// status of the CRs
+type compareJobController struct {
+	client    dynamic.Interface
+	namespace string
+	compare   compareFunc
+	now       func() time.Time
+
+	mu      sync.Mutex
+	results map[string]jobResult
+}
+
+func newCompareJobController(client dynamic.Interface, namespace string, compare compareFunc) *compareJobController {
+	return &compareJobController{client: client, namespace: namespace, compare: compare, now: time.Now, results: make(map[string]jobResult)}
+}
+
+// reconcileAll runs the jobs that are due, one after the other so the API server isn't loaded by concurrent
+// comparisons
+func (c *compareJobController) reconcileAll(ctx context.Context) error {
+	list, err := c.client.Resource(CompareJobGVR).Namespace(c.namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to list the CompareJob CRs: %w", err)
+	}
+	existing := make(map[string]bool)
+	for i := range list.Items {
+		if ctx.Err() != nil {
+			return nil
+		}
+		obj := &list.Items[i]
+		existing[obj.GetNamespace()+"/"+obj.GetName()] = true
+		if err := c.reconcile(ctx, obj); err != nil {
+			klog.Errorf("CompareJob %s/%s: %s", obj.GetNamespace(), obj.GetName(), err)
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key := range c.results {
+		if !existing[key] {
+			delete(c.results, key)
+		}
+	}
+	return nil
+}
+
+// reconcile runs the job if it's due and updates its status
+func (c *compareJobController) reconcile(ctx context.Context, obj *unstructured.Unstructured) error {
+	job, err := newCompareJob(obj)
+	if err != nil {
+		return err
+	}
+	if err := job.spec.validate(); err != nil {
+		if cond := meta.FindStatusCondition(job.status.Conditions, ConditionSucceeded); cond != nil &&
+			cond.Reason == "InvalidSpec" && cond.ObservedGeneration == obj.GetGeneration() {
+			// Already reported
+			return nil
+		}
+		job.status.ObservedGeneration = obj.GetGeneration()
+		job.status.NextRunTime = nil
+		c.setConditions(job, metav1.ConditionFalse, "InvalidSpec", err.Error(), metav1.ConditionUnknown, "InvalidSpec", "The job wasn't run")
+		return c.updateStatus(ctx, job)
+	}
+	interval, _ := job.spec.interval()
+	now := c.now()
+	if !job.due(now, interval) {
+		return nil
+	}
+
+	klog.V(1).Infof("Running CompareJob %s", job.key())
+	sum, failing, err := c.compare(ctx, obj.GetNamespace(), obj.GetName(), job.spec)
+	if ctx.Err() != nil {
+		// The controller is stopping, the job is run again once it's restarted
+		return nil
+	}
+	job.status.ObservedGeneration = obj.GetGeneration()
+	job.status.LastRunTime = &metav1.Time{Time: now}
+	job.status.NextRunTime = &metav1.Time{Time: now.Add(interval)}
+	if err != nil {
+		c.setConditions(job, metav1.ConditionFalse, "ComparisonFailed", err.Error(), metav1.ConditionUnknown, "ComparisonFailed", "The comparison failed")
+	} else {
+		job.status.Summary = &CompareJobSummary{
+			TotalCRs:     sum.TotalCRs,
+			CRsWithDiffs: sum.NumDiffCRs,
+			MissingCRs:   sum.NumMissing,
+			UnmatchedCRs: len(sum.UnmatchedCRS),
+			MetadataHash: sum.MetadataHash,
+		}
+		compliant, reason := metav1.ConditionTrue, "NoFailures"
+		if failing {
+			compliant, reason = metav1.ConditionFalse, "FailuresFound"
+		}
+		message := fmt.Sprintf("%d/%d CRs with diffs, %d missing CRs, %d unmatched CRs", sum.NumDiffCRs, sum.TotalCRs, sum.NumMissing, len(sum.UnmatchedCRS))
+		c.setConditions(job, metav1.ConditionTrue, "Completed", "The comparison ran to completion", compliant, reason, message)
+	}
+	c.mu.Lock()
+	c.results[job.key()] = jobResult{summary: sum, failed: err != nil, lastRun: now}
+	c.mu.Unlock()
+	return c.updateStatus(ctx, job)
+}
+
+func (c *compareJobController) setConditions(job *compareJob, succeeded metav1.ConditionStatus, succeededReason, succeededMessage string,
+	compliant metav1.ConditionStatus, compliantReason, compliantMessage string) {
+	generation := job.obj.GetGeneration()
+	meta.SetStatusCondition(&job.status.Conditions, metav1.Condition{Type: ConditionSucceeded, Status: succeeded,
+		ObservedGeneration: generation, Reason: succeededReason, Message: succeededMessage})
+	meta.SetStatusCondition(&job.status.Conditions, metav1.Condition{Type: ConditionCompliant, Status: compliant,
+		ObservedGeneration: generation, Reason: compliantReason, Message: compliantMessage})
+}
+
+func (c *compareJobController) updateStatus(ctx context.Context, job *compareJob) error {
+	status, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&job.status)
+	if err != nil {
+		return fmt.Errorf("failed to convert the status: %w", err)
+	}
+	obj := job.obj.DeepCopy()
+	obj.Object["status"] = status
+	if _, err := c.client.Resource(CompareJobGVR).Namespace(obj.GetNamespace()).UpdateStatus(ctx, obj, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("failed to update the status: %w", err)
+	}
+	return nil
+}
+
+// metrics returns the summaries of the last comparisons of the jobs as gauges in the Prometheus text format, labeled
+// with the namespace and name of the jobs
+func (c *compareJobController) metrics() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	keys := make([]string, 0, len(c.results))
+	for key := range c.results {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	w := &metricsWriter{}
+	var failed, lastRun []metricSample
+	var summaries []contextSummary
+	for _, key := range keys {
+		result := c.results[key]
+		labels := [][2]string{{"comparejob", key}}
+		failedValue := 0
+		if result.failed {
+			failedValue = 1
+		}
+		failed = append(failed, metricSample{labels: labels, value: failedValue})
+		lastRun = append(lastRun, metricSample{labels: labels, value: int(result.lastRun.Unix())})
+		if result.summary != nil {
+			summaries = append(summaries, contextSummary{context: key, contextLabel: "comparejob", summary: result.summary})
+		}
+	}
+	w.gauge("comparejob_failed", "Whether the last comparison of the CompareJob failed to run.", failed...)
+	w.gauge("comparejob_last_run_timestamp_seconds", "Time of the last comparison of the CompareJob.", lastRun...)
+	return w.sb.String() + metricsOf(summaries)
+}
+
+// run reconciles the jobs every resync period until the context is done
+func (c *compareJobController) run(ctx context.Context, resync time.Duration) {
+	ticker := time.NewTicker(resync)
+	defer ticker.Stop()
+	for {
+		if err := c.reconcileAll(ctx); err != nil {
+			klog.Error(err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+type ControllerOptions struct {
+	namespace      string
+	resync         time.Duration
+	metricsAddress string
+
+	genericiooptions.IOStreams
+}
+
+func NewControllerCmd(f kcmdutil.Factory, streams genericiooptions.IOStreams) *cobra.Command {
+	options := &ControllerOptions{IOStreams: streams, resync: time.Minute, metricsAddress: ":8080"}
+
+	cmd := &cobra.Command{
+		Use:                   "controller",
+		DisableFlagsInUseLine: true,
+		Short:                 i18n.T("Compare the cluster on a schedule to the references of the CompareJob CRs."),
+		Long:                  controllerLong,
+		Example:               exampleForBinary(controllerExample),
+		Run: func(cmd *cobra.Command, args []string) {
+			kcmdutil.CheckDiffErr(options.Complete(cmd, args))
+			ctx, cancel := withCancellation(cmd.Context(), 0)
+			defer cancel()
+			kcmdutil.CheckDiffErr(options.Run(ctx, f))
+		},
+	}
+	cmd.SetFlagErrorFunc(func(command *cobra.Command, err error) error {
+		kcmdutil.CheckDiffErr(kcmdutil.UsageErrorf(cmd, err.Error()))
+		return nil
+	})
+	cmd.Flags().StringVar(&options.namespace, "watch-namespace", "", "Only run the CompareJob CRs of this namespace, all the namespaces by default.")
+	cmd.Flags().DurationVar(&options.resync, "resync", options.resync, "Interval at which the CompareJob CRs are checked for the jobs that are due.")
+	cmd.Flags().StringVar(&options.metricsAddress, "metrics-address", options.metricsAddress,
+		"Address the metrics are served on at /metrics, empty to not serve them.")
+	return cmd
+}
+
+func (o *ControllerOptions) Complete(cmd *cobra.Command, args []string) error {
+	if len(args) != 0 {
+		return kcmdutil.UsageErrorf(cmd, "Unexpected args: %v", args)
+	}
+	if o.resync < time.Second {
+		return kcmdutil.UsageErrorf(cmd, "--resync must be at least 1s")
+	}
+	return nil
+}
+
+// Run runs the controller until the context is done
+func (o *ControllerOptions) Run(ctx context.Context, f kcmdutil.Factory) error {
+	client, err := f.DynamicClient()
+	if err != nil {
+		return fmt.Errorf("failed to create dynamic client: %w", err)
+	}
+	c := newCompareJobController(client, o.namespace, func(ctx context.Context, namespace, name string, spec CompareJobSpec) (*Summary, bool, error) {
+		return runCompareJob(ctx, f, spec.compareArgs(namespace, name))
+	})
+
+	var server *http.Server
+	if o.metricsAddress != "" {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/metrics", func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+			_, _ = w.Write([]byte(c.metrics()))
+		})
+		mux.HandleFunc("/healthz", func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})
+		server = &http.Server{Addr: o.metricsAddress, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+		go func() {
+			if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				klog.Errorf("failed to serve the metrics: %s", err)
+			}
+		}()
+	}
+	c.run(ctx, o.resync)
+	if server != nil {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			return fmt.Errorf("failed to stop the metrics server: %w", err)
+		}
+	}
+	return nil
+}
+
+// runCompareJob runs a comparison with the args, and returns its summary and whether it found failures according to
+// its exit policy
+func runCompareJob(ctx context.Context, f kcmdutil.Factory, args []string) (*Summary, bool, error) {
+	var out, errOut bytes.Buffer
+	options := NewOptions(genericiooptions.IOStreams{In: &bytes.Buffer{}, Out: &out, ErrOut: &errOut})
+	cmd := NewCmdWithOptions(f, options)
+	if err := cmd.ParseFlags(args); err != nil {
+		return nil, false, fmt.Errorf("invalid args: %w", err)
+	}
+	if err := options.Complete(f, cmd, cmd.Flags().Args()); err != nil {
+		return nil, false, err
+	}
+	err := options.Run(ctx)
+	_, failing := failuresExitCode(err)
+	if err != nil && !failing {
+		return nil, false, err
+	}
+	var output struct{ Summary *Summary }
+	if err := json.Unmarshal(out.Bytes(), &output); err != nil {
+		return nil, false, fmt.Errorf("failed to read the summary of the comparison: %w", err)
+	}
+	if output.Summary == nil {
+		return nil, false, fmt.Errorf("the comparison didn't output a summary: %s", strings.TrimSpace(errOut.String()))
+	}
+	return output.Summary, failing, nil
+}
@@ -0,0 +1,122 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+	fakedynamic "k8s.io/client-go/dynamic/fake"
+	cmdtesting "k8s.io/kubectl/pkg/cmd/testing"
+)
+
+func newTestCompareJob(name string, spec map[string]any) *unstructured.Unstructured {
+	job := &unstructured.Unstructured{Object: map[string]any{"spec": spec}}
+	job.SetGroupVersionKind(schema.GroupVersionKind{Group: CompareJobGVR.Group, Version: CompareJobGVR.Version, Kind: "CompareJob"})
+	job.SetNamespace("compliance")
+	job.SetName(name)
+	job.SetGeneration(1)
+	return job
+}
+
+func TestCompareJobController(t *testing.T) {
+	client := fakedynamic.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{CompareJobGVR: "CompareJobList"},
+		newTestCompareJob("daily", map[string]any{"reference": "https://example.com/ref.tgz", "publish": map[string]any{"target": PublishConfigMap},
+			"args": []any{"--ignore-api-defaults", "--max-diffs=3", "--include-kind", "ConfigMap"}}),
+		newTestCompareJob("broken", map[string]any{"reference": "https://example.com/broken.tgz", "interval": "2h"}),
+		newTestCompareJob("invalid", map[string]any{"interval": "10s",
+			"args": []any{"--ignore-api-defaults", "--publish-namespace=kube-system", "--run-cache", "/tmp/cache", "-p", "overrides.yaml"}}),
+		newTestCompareJob("suspended", map[string]any{"reference": "https://example.com/ref.tgz", "suspend": true}),
+	)
+	var runs []string
+	c := newCompareJobController(client, "", func(_ context.Context, namespace, name string, spec CompareJobSpec) (*Summary, bool, error) {
+		runs = append(runs, name)
+		if name == "broken" {
+			return nil, false, errors.New("failed to fetch the reference")
+		}
+		require.Equal(t, []string{"--ignore-api-defaults", "--max-diffs=3", "--include-kind", "ConfigMap", "--publish-results", PublishConfigMap, "--publish-name", "daily", "--publish-namespace", "compliance",
+			"--reference", "https://example.com/ref.tgz", "--output", Json}, spec.compareArgs(namespace, name))
+		return &Summary{TotalCRs: 5, NumDiffCRs: 2, NumMissing: 1, MetadataHash: "abc"}, true, nil
+	})
+	now := time.Date(2024, 5, 6, 7, 0, 0, 0, time.UTC)
+	c.now = func() time.Time { return now }
+
+	status := func(name string) CompareJobStatus {
+		obj, err := client.Resource(CompareJobGVR).Namespace("compliance").Get(context.Background(), name, metav1.GetOptions{})
+		require.NoError(t, err)
+		job, err := newCompareJob(obj)
+		require.NoError(t, err)
+		return job.status
+	}
+
+	require.NoError(t, c.reconcileAll(context.Background()))
+	require.ElementsMatch(t, []string{"daily", "broken"}, runs, "only the valid jobs that aren't suspended should be run")
+
+	daily := status("daily")
+	require.Equal(t, &CompareJobSummary{TotalCRs: 5, CRsWithDiffs: 2, MissingCRs: 1, MetadataHash: "abc"}, daily.Summary)
+	require.Equal(t, now.Add(defaultCompareJobInterval), daily.NextRunTime.UTC())
+	require.True(t, meta.IsStatusConditionTrue(daily.Conditions, ConditionSucceeded))
+	compliant := meta.FindStatusCondition(daily.Conditions, ConditionCompliant)
+	require.Equal(t, metav1.ConditionFalse, compliant.Status)
+	require.Equal(t, "2/5 CRs with diffs, 1 missing CRs, 0 unmatched CRs", compliant.Message)
+
+	broken := meta.FindStatusCondition(status("broken").Conditions, ConditionSucceeded)
+	require.Equal(t, "ComparisonFailed", broken.Reason)
+	require.Equal(t, "failed to fetch the reference", broken.Message)
+	invalid := meta.FindStatusCondition(status("invalid").Conditions, ConditionSucceeded)
+	require.Equal(t, "InvalidSpec", invalid.Reason)
+	require.Contains(t, invalid.Message, "the reference is required")
+	require.Contains(t, invalid.Message, "the interval must be at least 1m0s")
+	require.Contains(t, invalid.Message, "args --publish-namespace=kube-system, --run-cache, -p aren't allowed")
+	require.Empty(t, status("suspended").Conditions)
+
+	runs = nil
+	now = now.Add(3 * time.Hour)
+	require.NoError(t, c.reconcileAll(context.Background()))
+	require.Equal(t, []string{"broken"}, runs, "jobs should be run again once their interval elapsed")
+
+	metrics := c.metrics()
+	require.Contains(t, metrics, `kube_compare_comparejob_failed{comparejob="compliance/broken"} 1`)
+	require.Contains(t, metrics, `kube_compare_comparejob_failed{comparejob="compliance/daily"} 0`)
+	require.Contains(t, metrics, `kube_compare_crs_with_diffs{comparejob="compliance/daily"} 2`)
+
+	require.NoError(t, client.Resource(CompareJobGVR).Namespace("compliance").Delete(context.Background(), "broken", metav1.DeleteOptions{}))
+	require.NoError(t, c.reconcileAll(context.Background()))
+	require.NotContains(t, c.metrics(), "compliance/broken", "the metrics of deleted jobs should be removed")
+}
+
+func TestCompareJobDue(t *testing.T) {
+	now := time.Date(2024, 5, 6, 7, 0, 0, 0, time.UTC)
+	job, err := newCompareJob(newTestCompareJob("daily", map[string]any{"reference": "ref.yaml"}))
+	require.NoError(t, err)
+	require.True(t, job.due(now, time.Hour), "jobs that never ran should be due")
+
+	job.status = CompareJobStatus{ObservedGeneration: 1, LastRunTime: &metav1.Time{Time: now.Add(-30 * time.Minute)}}
+	require.False(t, job.due(now, time.Hour))
+	require.True(t, job.due(now.Add(30*time.Minute), time.Hour))
+
+	job.obj.SetGeneration(2)
+	require.True(t, job.due(now, time.Hour), "jobs whose spec changed should be due")
+}
+
+func TestCompareJobFlags(t *testing.T) {
+	cmd := NewCmd(cmdtesting.NewTestFactory(), genericiooptions.NewTestIOStreamsDiscard())
+	for _, name := range compareJobFlags {
+		require.NotNil(t, cmd.Flags().Lookup(name), "the allowed flag --%s should be a flag of the comparison", name)
+	}
+	require.NoError(t, validateArgs([]string{"--namespaces", "a,b", "--fail-on-missing=false", "--timeout", "10m"}))
+	require.EqualError(t, validateArgs([]string{"--annotate-drift", "-A", "--", "--reference=https://example.com/ref.tgz"}),
+		"args --annotate-drift, -A, --, --reference=https://example.com/ref.tgz aren't allowed, only the flags --"+
+			strings.Join(compareJobFlags, ", --")+" can be set")
+}
@@ -5,13 +5,17 @@ package compare
 import (
 	"errors"
 	"fmt"
+	"maps"
+	"math"
 	"reflect"
+	"regexp"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
-	"k8s.io/klog/v2"
 )
 
 var FieldSeparator = "_"
@@ -22,6 +26,11 @@ type Correlator[T CorrelationEntry] interface {
 	Match(*unstructured.Unstructured) ([]T, error)
 }
 
+// CorrelatorFactory creates a custom Correlator for the templates of the reference, allowing programs embedding the
+// command to match Resources to templates with their own logic. The Correlator should return an UnknownMatch error for
+// the Resources it doesn't match so the next correlators are tried.
+type CorrelatorFactory func(templates []ReferenceTemplate) (Correlator[ReferenceTemplate], error)
+
 // UnknownMatch an error that can be returned by a Correlator in a case no template was matched for a Resource.
 type UnknownMatch struct {
 	Resource *unstructured.Unstructured
@@ -98,6 +107,122 @@ func (c ExactMatchCorrelator[T]) Match(object *unstructured.Unstructured) ([]T,
 	return []T{temp}, nil
 }
 
+// regexCorrelationPrefix marks the keys of correlation pairs that are regular expressions
+const regexCorrelationPrefix = "regex:"
+
+// isCorrelationPattern checks if the key of a correlation pair is a glob or a regular expression rather than the exact
+// apiVersion_kind_namespace_name of a resource. Glob characters can't be part of the name of a resource.
+func isCorrelationPattern(key string) bool {
+	return strings.HasPrefix(key, regexCorrelationPrefix) || strings.ContainsAny(key, "*?[")
+}
+
+// splitCorrelationPairs splits correlation pairs into the ones with exact keys and the ones with pattern keys
+func splitCorrelationPairs(pairs map[string]string) (exact, patterns map[string]string) {
+	exact, patterns = make(map[string]string), make(map[string]string)
+	for key, temp := range pairs {
+		if isCorrelationPattern(key) {
+			patterns[key] = temp
+		} else {
+			exact[key] = temp
+		}
+	}
+	return exact, patterns
+}
+
+// globToRegexp converts a glob to an anchored regular expression. * matches any sequence of characters (including
+// the / of apiVersions), ? matches a single character and [...] matches a character class, [!...] negating it.
+func globToRegexp(glob string) (*regexp.Regexp, error) {
+	var sb strings.Builder
+	sb.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '*':
+			sb.WriteString(".*")
+		case '?':
+			sb.WriteString(".")
+		case '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated character class in %s", glob)
+			}
+			class := glob[i+1 : i+1+end]
+			if negated, ok := strings.CutPrefix(class, "!"); ok {
+				class = "^" + negated
+			}
+			sb.WriteString("[" + class + "]")
+			i += end + 1
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	sb.WriteString("$")
+	return regexp.Compile(sb.String()) //nolint: wrapcheck
+}
+
+type correlationPattern[T CorrelationEntry] struct {
+	key    string
+	regexp *regexp.Regexp
+	temp   T
+}
+
+// PatternCorrelator Matches templates by matching the apiVersion_kind_namespace_name of resources against globs or
+// regular expressions (prefixed with regex:) predefined in the config, for resources with generated names.
+// Resources matching multiple patterns are matched with all their templates.
+type PatternCorrelator[T CorrelationEntry] struct {
+	patterns []correlationPattern[T]
+}
+
+func NewPatternCorrelator[T CorrelationEntry](matchPairs map[string]string, templates []T) (*PatternCorrelator[T], error) {
+	nameToObject := make(map[string]T)
+	for _, temp := range templates {
+		nameToObject[temp.GetIdentifier()] = temp
+	}
+	core := PatternCorrelator[T]{}
+	var errs []error
+	for key, temp := range matchPairs {
+		obj, ok := nameToObject[temp]
+		if !ok {
+			errs = append(errs, fmt.Errorf("error in template manual matching for resource: %s no template in the name of %s", key, temp))
+			continue
+		}
+		var re *regexp.Regexp
+		var err error
+		if expr, isRegex := strings.CutPrefix(key, regexCorrelationPrefix); isRegex {
+			re, err = regexp.Compile("^(?:" + expr + ")$")
+		} else {
+			re, err = globToRegexp(key)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("error in template manual matching for resource: %s isn't a valid pattern: %w", key, err))
+			continue
+		}
+		core.patterns = append(core.patterns, correlationPattern[T]{key: key, regexp: re, temp: obj})
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	sort.Slice(core.patterns, func(i, j int) bool {
+		return core.patterns[i].key < core.patterns[j].key
+	})
+	return &core, nil
+}
+
+func (c PatternCorrelator[T]) Match(object *unstructured.Unstructured) ([]T, error) {
+	name := apiKindNamespaceName(object)
+	var temps []T
+	seen := make(map[string]bool)
+	for _, p := range c.patterns {
+		if p.regexp.MatchString(name) && !seen[p.temp.GetIdentifier()] {
+			seen[p.temp.GetIdentifier()] = true
+			temps = append(temps, p.temp)
+		}
+	}
+	if len(temps) == 0 {
+		return []T{}, UnknownMatch{Resource: object}
+	}
+	return temps, nil
+}
+
 // GroupCorrelator Matches templates by hashing predefined fields.
 // All The templates are indexed by  hashing groups of `indexed` fields. The `indexed` fields can be nested.
 // Resources will be attempted to be matched with hashing by the group with the largest amount of `indexed` fields.
@@ -116,8 +241,8 @@ type GroupCorrelator[T CorrelationEntry] struct {
 // the fixedNamespaceKindTemplate will be added to a mapping where the keys are  in the format of `namespace_kind`. The fixedKindTemplate
 // will be added to a mapping where the keys are  in the format of `kind`.
 func NewGroupCorrelator[T CorrelationEntry](fieldGroups [][][]string, objects []T) (*GroupCorrelator[T], error) {
-	sort.Slice(fieldGroups, func(i, j int) bool {
-		return len(fieldGroups[i]) >= len(fieldGroups[j])
+	sort.SliceStable(fieldGroups, func(i, j int) bool {
+		return len(fieldGroups[i]) > len(fieldGroups[j])
 	})
 	core := GroupCorrelator[T]{}
 	for _, group := range fieldGroups {
@@ -132,11 +257,6 @@ func NewGroupCorrelator[T CorrelationEntry](fieldGroups [][][]string, objects []
 		objects = newObjects
 		core.fieldCorrelators = append(core.fieldCorrelators, &fc)
 
-		err := fc.ValidateTemplates()
-		if err != nil {
-			klog.Warning(err)
-		}
-
 		if len(objects) == 0 {
 			break
 		}
@@ -145,6 +265,16 @@ func NewGroupCorrelator[T CorrelationEntry](fieldGroups [][][]string, objects []
 	return &core, nil
 }
 
+// ValidateTemplates reports groups of templates that share the same hash in one of the field correlators,
+// such templates can't be told apart by the correlator and will be decided by the number of diffs.
+func (c *GroupCorrelator[T]) ValidateTemplates() error {
+	errs := make([]error, 0)
+	for _, fc := range c.fieldCorrelators {
+		errs = append(errs, fc.ValidateTemplates())
+	}
+	return errors.Join(errs...)
+}
+
 func getFields(fields [][]string) string {
 	var stringifiedFields []string
 	for _, field := range fields {
@@ -160,12 +290,16 @@ func createGroupHashFunc(fieldGroup [][]string) templateHashFunc {
 	groupHashFunc := func(cr *unstructured.Unstructured, replaceEmptyWith string) (group string, err error) {
 		var values []string
 		for _, fields := range fieldGroup {
-			value, isFound, NotStringErr := NestedString(cr.Object, fields...)
-			if !isFound || value == "" {
+			field, isFound, err := NestedField(cr.Object, fields...)
+			if err != nil {
+				return "", err
+			}
+			value, isEmpty, err := hashFieldValue(field)
+			if !isFound || isEmpty {
 				return "", fmt.Errorf("the field %s doesn't exist in resource", strings.Join(fields, FieldSeparator))
 			}
-			if NotStringErr != nil {
-				return "", fmt.Errorf("the field %s isn't string - grouping by non string values isn't supported", strings.Join(fields, FieldSeparator))
+			if err != nil {
+				return "", fmt.Errorf("the field %s can't be used for grouping: %w", strings.Join(fields, FieldSeparator), err)
 			}
 			values = append(values, value)
 		}
@@ -174,6 +308,75 @@ func createGroupHashFunc(fieldGroup [][]string) templateHashFunc {
 	return groupHashFunc
 }
 
+// hashFieldValue returns a canonical representation of a field value for grouping. Strings, numbers, booleans and
+// arrays and maps of them are supported. Numbers are canonicalized so the same value is represented the same whether it was
+// decoded as an int or a float (templates are decoded from YAML as floats while cluster CRs are decoded as ints).
+func hashFieldValue(value any) (hash string, isEmpty bool, err error) {
+	switch v := value.(type) {
+	case nil:
+		return "", true, nil
+	case string:
+		return v, v == "", nil
+	case bool:
+		return strconv.FormatBool(v), false, nil
+	case int:
+		return strconv.Itoa(v), false, nil
+	case int32:
+		return strconv.FormatInt(int64(v), 10), false, nil
+	case int64:
+		return strconv.FormatInt(v, 10), false, nil
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+			return strconv.FormatInt(int64(v), 10), false, nil
+		}
+		return strconv.FormatFloat(v, 'g', -1, 64), false, nil
+	case []any:
+		if len(v) == 0 {
+			return "", true, nil
+		}
+		elements := make([]string, 0, len(v))
+		for _, element := range v {
+			if _, isArray := element.([]any); isArray {
+				return "", false, errors.New("grouping by nested arrays isn't supported")
+			}
+			hash, isEmpty, err := hashFieldValue(element)
+			if err != nil {
+				return "", false, err
+			}
+			if isEmpty {
+				return "", true, nil
+			}
+			elements = append(elements, strconv.Quote(hash))
+		}
+		return "[" + strings.Join(elements, ",") + "]", false, nil
+	case map[string]any:
+		if len(v) == 0 {
+			return "", true, nil
+		}
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		entries := make([]string, 0, len(v))
+		for _, key := range keys {
+			if _, isMap := v[key].(map[string]any); isMap {
+				return "", false, errors.New("grouping by nested maps isn't supported")
+			}
+			hash, isEmpty, err := hashFieldValue(v[key])
+			if err != nil {
+				return "", false, err
+			}
+			if isEmpty {
+				return "", true, nil
+			}
+			entries = append(entries, strconv.Quote(key)+":"+strconv.Quote(hash))
+		}
+		return "{" + strings.Join(entries, ",") + "}", false, nil
+	}
+	return "", false, fmt.Errorf("grouping by values of type %T isn't supported", value)
+}
+
 func getTemplatesNames[T CorrelationEntry](templates []T) string {
 	var names []string
 	for _, temp := range templates {
@@ -201,13 +404,21 @@ type MetricsTracker struct {
 	UnMatchedCRs          []*unstructured.Unstructured
 	unMatchedLock         sync.Mutex
 	MatchedTemplatesNames map[string]int
+	templateDiffs         map[string]templateDiffs
 	matchedLock           sync.Mutex
 }
 
+// templateDiffs counts the diffs of the cluster CRs correlated to a template
+type templateDiffs struct {
+	crs          int
+	changedLines int
+}
+
 func NewMetricsTracker() *MetricsTracker {
 	cr := MetricsTracker{
 		UnMatchedCRs:          []*unstructured.Unstructured{},
 		MatchedTemplatesNames: map[string]int{},
+		templateDiffs:         map[string]templateDiffs{},
 	}
 	return &cr
 }
@@ -241,12 +452,61 @@ func (c *MetricsTracker) addMatch(temp ReferenceTemplate) {
 	c.matchedLock.Unlock()
 }
 
+// addDiff records that a cluster CR correlated to the template differs from it
+func (c *MetricsTracker) addDiff(temp ReferenceTemplate, diffOutput string) {
+	c.matchedLock.Lock()
+	d := c.templateDiffs[temp.GetIdentifier()]
+	d.crs++
+	d.changedLines += countChangedLines(diffOutput)
+	c.templateDiffs[temp.GetIdentifier()] = d
+	c.matchedLock.Unlock()
+}
+
 func (c *MetricsTracker) addUNMatch(cr *unstructured.Unstructured) {
 	c.unMatchedLock.Lock()
 	c.UnMatchedCRs = append(c.UnMatchedCRs, cr)
 	c.unMatchedLock.Unlock()
 }
 
+// counts returns the number of matched and unmatched CRs recorded so far, it's safe to call during a run
+func (c *MetricsTracker) counts() (matched, unmatched int) {
+	c.matchedLock.Lock()
+	for _, v := range c.MatchedTemplatesNames {
+		matched += v
+	}
+	c.matchedLock.Unlock()
+	c.unMatchedLock.Lock()
+	unmatched = len(c.UnMatchedCRs)
+	c.unMatchedLock.Unlock()
+	return matched, unmatched
+}
+
+// clone returns a copy of the CRs recorded so far, it's safe to call during a run
+func (c *MetricsTracker) clone() *MetricsTracker {
+	clone := NewMetricsTracker()
+	c.matchedLock.Lock()
+	maps.Copy(clone.MatchedTemplatesNames, c.MatchedTemplatesNames)
+	maps.Copy(clone.templateDiffs, c.templateDiffs)
+	c.matchedLock.Unlock()
+	c.unMatchedLock.Lock()
+	clone.UnMatchedCRs = slices.Clone(c.UnMatchedCRs)
+	c.unMatchedLock.Unlock()
+	return clone
+}
+
+// templateStats returns the statistics of every template at least one cluster CR was correlated to
+func (c *MetricsTracker) templateStats() map[string]TemplateStats {
+	stats := make(map[string]TemplateStats)
+	for name, count := range c.MatchedTemplatesNames {
+		if count == 0 {
+			continue
+		}
+		d := c.templateDiffs[name]
+		stats[name] = TemplateStats{CorrelatedCRs: count, CRsWithDiffs: d.crs, ChangedLines: d.changedLines}
+	}
+	return stats
+}
+
 func (c *MetricsTracker) getTotalCRs() int {
 	count := 0
 	for _, v := range c.MatchedTemplatesNames {
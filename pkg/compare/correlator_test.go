@@ -0,0 +1,142 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestHashFieldValue(t *testing.T) {
+	cases := []struct {
+		name      string
+		value     any
+		expected  string
+		isEmpty   bool
+		expectErr bool
+	}{
+		{name: "string", value: "value", expected: "value"},
+		{name: "empty string", value: "", isEmpty: true},
+		{name: "nil", value: nil, isEmpty: true},
+		{name: "bool", value: false, expected: "false"},
+		{name: "int64", value: int64(3), expected: "3"},
+		{name: "integral float", value: float64(3), expected: "3"},
+		{name: "float", value: 0.5, expected: "0.5"},
+		{name: "array", value: []any{int64(80), float64(443), "tcp", true}, expected: `["80","443","tcp","true"]`},
+		{name: "empty array", value: []any{}, isEmpty: true},
+		{name: "array with templated value", value: []any{int64(80), nil}, isEmpty: true},
+		{name: "nested array", value: []any{[]any{"a"}}, expectErr: true},
+		{name: "map", value: map[string]any{"b": int64(1), "a": "x"}, expected: `{"a":"x","b":"1"}`},
+		{name: "empty map", value: map[string]any{}, isEmpty: true},
+		{name: "map with templated value", value: map[string]any{"a": nil}, isEmpty: true},
+		{name: "nested map", value: map[string]any{"a": map[string]any{"b": "c"}}, expectErr: true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			hash, isEmpty, err := hashFieldValue(c.value)
+			if c.expectErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.isEmpty, isEmpty)
+			require.Equal(t, c.expected, hash)
+		})
+	}
+}
+
+func TestGroupCorrelatorNonStringFields(t *testing.T) {
+	newTemplate := func(path string, spec map[string]any) ReferenceTemplateV1 {
+		return ReferenceTemplateV1{Path: path, metadata: &unstructured.Unstructured{Object: map[string]any{"kind": "Service", "spec": spec}}}
+	}
+	// Numbers in templates are decoded from YAML as floats
+	templates := []ReferenceTemplateV1{
+		newTemplate("one-replica.yaml", map[string]any{"replicas": float64(1), "ports": []any{float64(80)}}),
+		newTemplate("three-replicas.yaml", map[string]any{"replicas": float64(3), "ports": []any{float64(80), float64(443)}}),
+		newTemplate("templated-replicas.yaml", map[string]any{"replicas": nil}),
+	}
+	fieldGroups := [][][]string{
+		{{"kind"}, {"spec", "replicas"}, {"spec", "ports"}},
+		{{"kind"}},
+	}
+	correlator, err := NewGroupCorrelator(fieldGroups, templates)
+	require.NoError(t, err)
+	require.NoError(t, correlator.ValidateTemplates())
+
+	cr := &unstructured.Unstructured{Object: map[string]any{
+		"kind": "Service",
+		"spec": map[string]any{"replicas": int64(3), "ports": []any{int64(80), int64(443)}},
+	}}
+	matches, err := correlator.Match(cr)
+	require.NoError(t, err)
+	require.Len(t, matches, 1)
+	require.Equal(t, "three-replicas.yaml", matches[0].GetIdentifier())
+
+	cr.Object["spec"] = map[string]any{"replicas": int64(2)}
+	matches, err = correlator.Match(cr)
+	require.NoError(t, err)
+	require.Len(t, matches, 1)
+	require.Equal(t, "templated-replicas.yaml", matches[0].GetIdentifier())
+}
+
+func TestPatternCorrelator(t *testing.T) {
+	newTemplate := func(path string) ReferenceTemplateV1 {
+		return ReferenceTemplateV1{Path: path}
+	}
+	templates := []ReferenceTemplateV1{newTemplate("prom-cm.yaml"), newTemplate("deploy.yaml"), newTemplate("any-cm.yaml")}
+	correlator, err := NewPatternCorrelator(map[string]string{
+		"v1_ConfigMap_openshift-monitoring_prometheus-*": "prom-cm.yaml",
+		"v1_ConfigMap_*": "any-cm.yaml",
+		"regex:apps/v1_Deployment_[a-z-]+_web-[0-9a-f]{5}": "deploy.yaml",
+		"*/v1_ReplicaSet_[!x]?_*":                          "deploy.yaml",
+	}, templates)
+	require.NoError(t, err)
+
+	newCR := func(apiVersion, kind, namespace, name string) *unstructured.Unstructured {
+		cr := &unstructured.Unstructured{Object: map[string]any{}}
+		cr.SetAPIVersion(apiVersion)
+		cr.SetKind(kind)
+		cr.SetNamespace(namespace)
+		cr.SetName(name)
+		return cr
+	}
+	cases := []struct {
+		name     string
+		cr       *unstructured.Unstructured
+		expected []string
+	}{
+		{name: "glob and catch all", cr: newCR("v1", "ConfigMap", "openshift-monitoring", "prometheus-k8s-x7k2p"), expected: []string{"any-cm.yaml", "prom-cm.yaml"}},
+		{name: "catch all", cr: newCR("v1", "ConfigMap", "default", "settings"), expected: []string{"any-cm.yaml"}},
+		{name: "regex", cr: newCR("apps/v1", "Deployment", "shop", "web-3f9a1"), expected: []string{"deploy.yaml"}},
+		{name: "regex is anchored", cr: newCR("apps/v1", "Deployment", "shop", "web-3f9a1-extra"), expected: nil},
+		{name: "glob spans apiVersion group", cr: newCR("apps/v1", "ReplicaSet", "ab", "web"), expected: []string{"deploy.yaml"}},
+		{name: "negated class", cr: newCR("apps/v1", "ReplicaSet", "xb", "web"), expected: nil},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			matches, err := correlator.Match(c.cr)
+			if c.expected == nil {
+				require.ErrorAs(t, err, &UnknownMatch{})
+				return
+			}
+			require.NoError(t, err)
+			var names []string
+			for _, m := range matches {
+				names = append(names, m.GetIdentifier())
+			}
+			require.Equal(t, c.expected, names)
+		})
+	}
+}
+
+func TestPatternCorrelatorErrors(t *testing.T) {
+	templates := []ReferenceTemplateV1{{Path: "cm.yaml"}}
+	_, err := NewPatternCorrelator(map[string]string{"v1_ConfigMap_[a-": "cm.yaml"}, templates)
+	require.ErrorContains(t, err, "isn't a valid pattern")
+	_, err = NewPatternCorrelator(map[string]string{"regex:v1_ConfigMap_(": "cm.yaml"}, templates)
+	require.ErrorContains(t, err, "isn't a valid pattern")
+	_, err = NewPatternCorrelator(map[string]string{"v1_ConfigMap_*": "missing.yaml"}, templates)
+	require.ErrorContains(t, err, "no template in the name of missing.yaml")
+}
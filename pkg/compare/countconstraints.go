@@ -0,0 +1,72 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// CountMismatch is a template the number of cluster CRs correlated to is out of the range declared by the minCount
+// and maxCount of its config
+type CountMismatch struct {
+	Template string `json:"Template"`
+	MinCount *int   `json:"MinCount,omitempty"`
+	MaxCount *int   `json:"MaxCount,omitempty"`
+	Found    int    `json:"Found"`
+}
+
+// Expected describes the expected number of CRs, e.g. "exactly 3" or "at least 1"
+func (m CountMismatch) Expected() string {
+	switch {
+	case m.MinCount != nil && m.MaxCount != nil && *m.MinCount == *m.MaxCount:
+		return fmt.Sprintf("exactly %d", *m.MinCount)
+	case m.MinCount != nil && m.MaxCount != nil:
+		return fmt.Sprintf("between %d and %d", *m.MinCount, *m.MaxCount)
+	case m.MinCount != nil:
+		return fmt.Sprintf("at least %d", *m.MinCount)
+	case m.MaxCount != nil:
+		return fmt.Sprintf("at most %d", *m.MaxCount)
+	}
+	return "any number"
+}
+
+// validateCounts checks the minCount and maxCount of a template config
+func validateCounts(minCount, maxCount *int) error {
+	if minCount != nil && *minCount < 0 {
+		return errors.New("minCount can't be negative")
+	}
+	if maxCount != nil && *maxCount < 1 {
+		return errors.New("maxCount must be at least 1")
+	}
+	if minCount != nil && maxCount != nil && *minCount > *maxCount {
+		return fmt.Errorf("minCount %d is greater than maxCount %d", *minCount, *maxCount)
+	}
+	return nil
+}
+
+// countMismatches returns the templates whose number of correlated CRs is out of their expected range, templates
+// that weren't compared (e.g. their kind couldn't be fetched or they failed to render) are left out
+func countMismatches(templates []ReferenceTemplate, matched map[string]int, notCompared ...map[string]bool) []CountMismatch {
+	var mismatches []CountMismatch
+	for _, temp := range templates {
+		minCount, maxCount := temp.GetConfig().GetExpectedCount()
+		if minCount == nil && maxCount == nil {
+			continue
+		}
+		skipped := false
+		for _, excluded := range notCompared {
+			skipped = skipped || excluded[temp.GetPath()]
+		}
+		if skipped {
+			continue
+		}
+		found := matched[temp.GetIdentifier()]
+		if (minCount != nil && found < *minCount) || (maxCount != nil && found > *maxCount) {
+			mismatches = append(mismatches, CountMismatch{Template: temp.GetIdentifier(), MinCount: minCount, MaxCount: maxCount, Found: found})
+		}
+	}
+	sort.Slice(mismatches, func(i, j int) bool { return mismatches[i].Template < mismatches[j].Template })
+	return mismatches
+}
@@ -0,0 +1,33 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/utils/ptr"
+)
+
+func TestCountMismatchExpected(t *testing.T) {
+	tests := []struct {
+		minCount, maxCount *int
+		expected           string
+	}{
+		{ptr.To(3), ptr.To(3), "exactly 3"},
+		{ptr.To(1), ptr.To(2), "between 1 and 2"},
+		{ptr.To(1), nil, "at least 1"},
+		{nil, ptr.To(2), "at most 2"},
+	}
+	for _, test := range tests {
+		require.Equal(t, test.expected, CountMismatch{MinCount: test.minCount, MaxCount: test.maxCount}.Expected())
+	}
+}
+
+func TestValidateCounts(t *testing.T) {
+	require.NoError(t, validateCounts(ptr.To(0), nil))
+	require.NoError(t, validateCounts(ptr.To(2), ptr.To(2)))
+	require.EqualError(t, validateCounts(ptr.To(-1), nil), "minCount can't be negative")
+	require.EqualError(t, validateCounts(nil, ptr.To(0)), "maxCount must be at least 1")
+	require.EqualError(t, validateCounts(ptr.To(3), ptr.To(2)), "minCount 3 is greater than maxCount 2")
+}
@@ -0,0 +1,154 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// FieldDefault is a field the API server sets to a default value when it isn't set. The fields of a cluster CR
+// holding their default value aren't reported when the template doesn't set them.
+type FieldDefault struct {
+	// JSONPath selects the fields, e.g. .spec.template.spec.containers[*].imagePullPolicy
+	JSONPath string `json:"jsonPath"`
+	// Value is the default value of the fields
+	Value any `json:"value"`
+}
+
+func validateDefaults(defaults []FieldDefault) error {
+	var errs []error
+	for i, d := range defaults {
+		if _, err := parseJSONPath(d.JSONPath); err != nil {
+			errs = append(errs, fmt.Errorf("defaults[%d]: %w", i, err))
+		}
+		if d.Value == nil {
+			errs = append(errs, fmt.Errorf("defaults[%d]: the default value of %s is required", i, d.JSONPath))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// fieldDefault is a parsed FieldDefault, its value is kept in its JSON form to compare it to the values of the fields
+type fieldDefault struct {
+	path  []jsonPathSegment
+	value string
+}
+
+func parseDefaults(defaults []FieldDefault) []fieldDefault {
+	var result []fieldDefault
+	for _, d := range defaults {
+		if segments, err := parseJSONPath(d.JSONPath); err == nil {
+			result = append(result, fieldDefault{path: segments, value: formatFieldValue(d.Value)})
+		}
+	}
+	return result
+}
+
+// podSpecDefaults are the defaults of the fields of a pod spec
+var podSpecDefaults = []FieldDefault{
+	{JSONPath: ".dnsPolicy", Value: "ClusterFirst"},
+	{JSONPath: ".restartPolicy", Value: "Always"},
+	{JSONPath: ".schedulerName", Value: "default-scheduler"},
+	{JSONPath: ".securityContext", Value: map[string]any{}},
+	{JSONPath: ".terminationGracePeriodSeconds", Value: 30},
+	{JSONPath: ".containers[*].imagePullPolicy", Value: "IfNotPresent"},
+	{JSONPath: ".containers[*].terminationMessagePath", Value: "/dev/termination-log"},
+	{JSONPath: ".containers[*].terminationMessagePolicy", Value: "File"},
+	{JSONPath: ".containers[*].ports[*].protocol", Value: "TCP"},
+	{JSONPath: ".initContainers[*].imagePullPolicy", Value: "IfNotPresent"},
+	{JSONPath: ".initContainers[*].terminationMessagePath", Value: "/dev/termination-log"},
+	{JSONPath: ".initContainers[*].terminationMessagePolicy", Value: "File"},
+}
+
+// withPrefix returns the defaults with their JSONPaths under the prefix, e.g. for the pod template of a workload
+func withPrefix(prefix string, defaults []FieldDefault) []FieldDefault {
+	result := make([]FieldDefault, 0, len(defaults))
+	for _, d := range defaults {
+		result = append(result, FieldDefault{JSONPath: prefix + d.JSONPath, Value: d.Value})
+	}
+	return result
+}
+
+// apiDefaults are the defaults the API server sets on the fields of the built-in kinds, ignored with
+// --ignore-api-defaults. Defaults depending on other fields aren't listed (e.g. the pull policy of images with the
+// latest tag defaults to Always).
+var apiDefaults = map[schema.GroupKind][]fieldDefault{
+	{Kind: "Pod"}: parseDefaults(withPrefix(".spec", podSpecDefaults)),
+	{Kind: "Service"}: parseDefaults([]FieldDefault{
+		{JSONPath: ".spec.type", Value: "ClusterIP"},
+		{JSONPath: ".spec.sessionAffinity", Value: "None"},
+		{JSONPath: ".spec.internalTrafficPolicy", Value: "Cluster"},
+		{JSONPath: ".spec.ports[*].protocol", Value: "TCP"},
+	}),
+	{Group: "apps", Kind: "Deployment"}: parseDefaults(slices.Concat([]FieldDefault{
+		{JSONPath: ".spec.replicas", Value: 1},
+		{JSONPath: ".spec.progressDeadlineSeconds", Value: 600},
+		{JSONPath: ".spec.revisionHistoryLimit", Value: 10},
+		{JSONPath: ".spec.strategy.type", Value: "RollingUpdate"},
+		{JSONPath: ".spec.strategy.rollingUpdate.maxSurge", Value: "25%"},
+		{JSONPath: ".spec.strategy.rollingUpdate.maxUnavailable", Value: "25%"},
+	}, withPrefix(".spec.template.spec", podSpecDefaults))),
+	{Group: "apps", Kind: "StatefulSet"}: parseDefaults(slices.Concat([]FieldDefault{
+		{JSONPath: ".spec.replicas", Value: 1},
+		{JSONPath: ".spec.podManagementPolicy", Value: "OrderedReady"},
+		{JSONPath: ".spec.revisionHistoryLimit", Value: 10},
+		{JSONPath: ".spec.updateStrategy.type", Value: "RollingUpdate"},
+		{JSONPath: ".spec.updateStrategy.rollingUpdate.partition", Value: 0},
+	}, withPrefix(".spec.template.spec", podSpecDefaults))),
+	{Group: "apps", Kind: "DaemonSet"}: parseDefaults(slices.Concat([]FieldDefault{
+		{JSONPath: ".spec.revisionHistoryLimit", Value: 10},
+		{JSONPath: ".spec.updateStrategy.type", Value: "RollingUpdate"},
+		{JSONPath: ".spec.updateStrategy.rollingUpdate.maxSurge", Value: 0},
+		{JSONPath: ".spec.updateStrategy.rollingUpdate.maxUnavailable", Value: 1},
+	}, withPrefix(".spec.template.spec", podSpecDefaults))),
+	{Group: "apps", Kind: "ReplicaSet"}: parseDefaults(slices.Concat([]FieldDefault{
+		{JSONPath: ".spec.replicas", Value: 1},
+	}, withPrefix(".spec.template.spec", podSpecDefaults))),
+	{Group: "batch", Kind: "Job"}: parseDefaults(slices.Concat([]FieldDefault{
+		{JSONPath: ".spec.backoffLimit", Value: 6},
+		{JSONPath: ".spec.completionMode", Value: "NonIndexed"},
+		{JSONPath: ".spec.completions", Value: 1},
+		{JSONPath: ".spec.parallelism", Value: 1},
+		{JSONPath: ".spec.suspend", Value: false},
+	}, withPrefix(".spec.template.spec", podSpecDefaults))),
+	{Group: "batch", Kind: "CronJob"}: parseDefaults(slices.Concat([]FieldDefault{
+		{JSONPath: ".spec.concurrencyPolicy", Value: "Allow"},
+		{JSONPath: ".spec.failedJobsHistoryLimit", Value: 1},
+		{JSONPath: ".spec.successfulJobsHistoryLimit", Value: 3},
+		{JSONPath: ".spec.suspend", Value: false},
+	}, withPrefix(".spec.jobTemplate.spec.template.spec", podSpecDefaults))),
+}
+
+// defaultsFor returns the defaults of the fields of the cluster CR compared to the template: the ones of the API for
+// its kind with --ignore-api-defaults, followed by the ones of the reference and the ones of the template. The
+// defaults of the reference and the template are validated when the reference is loaded.
+func defaultsFor(ref Reference, temp ReferenceTemplate, clusterCR *unstructured.Unstructured, ignoreAPIDefaults bool) []fieldDefault {
+	var result []fieldDefault
+	if ignoreAPIDefaults {
+		result = append(result, apiDefaults[clusterCR.GroupVersionKind().GroupKind()]...)
+	}
+	return append(result, parseDefaults(slices.Concat(ref.GetDefaults(), temp.GetConfig().GetDefaults()))...)
+}
+
+// omitDefaults removes the fields of the cluster object holding their default value that the template doesn't set
+func omitDefaults(object, template map[string]any, defaults []fieldDefault) {
+	var fieldPaths [][]string
+	for _, d := range defaults {
+		for _, field := range findJSONPathFields(object, d.path) {
+			value, _, _ := NestedField(object, field...)
+			if formatFieldValue(value) != d.value {
+				continue
+			}
+			if _, found, _ := NestedField(template, field...); found {
+				continue
+			}
+			fieldPaths = append(fieldPaths, field)
+		}
+	}
+	omitFieldPaths(object, fieldPaths)
+}
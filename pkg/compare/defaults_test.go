@@ -0,0 +1,55 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOmitDefaults(t *testing.T) {
+	defaults := parseDefaults([]FieldDefault{
+		{JSONPath: ".spec.containers[*].imagePullPolicy", Value: "IfNotPresent"},
+		{JSONPath: ".spec.terminationGracePeriodSeconds", Value: 30},
+		{JSONPath: ".spec.securityContext", Value: map[string]any{}},
+		{JSONPath: ".spec.containers[*].imagePullPolicy", Value: "IfNotPresent"},
+	})
+	object := map[string]any{
+		"spec": map[string]any{
+			"containers": []any{
+				map[string]any{"name": "a", "imagePullPolicy": "IfNotPresent"},
+				map[string]any{"name": "b", "imagePullPolicy": "Always"},
+				map[string]any{"name": "c", "imagePullPolicy": "IfNotPresent"},
+			},
+			"terminationGracePeriodSeconds": int64(30),
+			"securityContext":               map[string]any{},
+		},
+	}
+	template := map[string]any{
+		"spec": map[string]any{
+			"containers": []any{
+				map[string]any{"name": "a"},
+				map[string]any{"name": "b"},
+				map[string]any{"name": "c", "imagePullPolicy": "Always"},
+			},
+		},
+	}
+
+	omitDefaults(object, template, defaults)
+	require.Equal(t, map[string]any{
+		"spec": map[string]any{
+			"containers": []any{
+				map[string]any{"name": "a"},
+				map[string]any{"name": "b", "imagePullPolicy": "Always"},
+				map[string]any{"name": "c", "imagePullPolicy": "IfNotPresent"},
+			},
+		},
+	}, object, "only the fields holding their default and not set by the template should be removed")
+}
+
+func TestValidateDefaults(t *testing.T) {
+	require.NoError(t, validateDefaults([]FieldDefault{{JSONPath: ".spec.replicas", Value: 1}}))
+	require.ErrorContains(t, validateDefaults([]FieldDefault{{JSONPath: ".spec[?(@.x)]", Value: 1}}), "defaults[0]")
+	require.ErrorContains(t, validateDefaults([]FieldDefault{{JSONPath: ".spec.replicas"}}), "default value of .spec.replicas is required")
+}
@@ -0,0 +1,152 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// validateDependencies checks that the templates only depend on other templates of the reference and that their
+// dependencies have no cycle, so the templates can be evaluated in the order of their dependencies
+func validateDependencies(templates []*ReferenceTemplateV2) error {
+	dependsOn := make(map[string][]string, len(templates))
+	for _, temp := range templates {
+		dependsOn[temp.Path] = temp.Config.DependsOn
+	}
+	var errs []error
+	for _, temp := range templates {
+		for _, dep := range temp.Config.DependsOn {
+			switch _, ok := dependsOn[dep]; {
+			case dep == temp.Path:
+				errs = append(errs, fmt.Errorf("template %s: dependsOn: the template can't depend on itself", temp.Path))
+			case !ok:
+				errs = append(errs, fmt.Errorf("template %s: dependsOn: template %s isn't in the reference", temp.Path, dep))
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	// Depth-first search of the dependencies, a template found again while its dependencies are searched is in a cycle
+	const (
+		searching = 1
+		searched  = 2
+	)
+	state := make(map[string]int, len(templates))
+	var stack []string
+	var search func(p string) error
+	search = func(p string) error {
+		switch state[p] {
+		case searched:
+			return nil
+		case searching:
+			cycle := append(slices.Clone(stack[slices.Index(stack, p):]), p)
+			return fmt.Errorf("templates have a dependency cycle: %s", strings.Join(cycle, " -> "))
+		}
+		state[p] = searching
+		stack = append(stack, p)
+		for _, dep := range dependsOn[p] {
+			if err := search(dep); err != nil {
+				return err
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[p] = searched
+		return nil
+	}
+	for _, temp := range templates {
+		if err := search(temp.Path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dependencyStages returns the stage in which every template is evaluated: templates without dependencies are
+// evaluated first in stage 0, other templates in the stage following the last stage of their dependencies.
+// Dependencies on templates that aren't compared, e.g. filtered out with --kinds, are ignored.
+func dependencyStages(templates []ReferenceTemplate) map[string]int {
+	dependsOn := make(map[string][]string, len(templates))
+	for _, temp := range templates {
+		dependsOn[temp.GetPath()] = temp.GetConfig().GetDependsOn()
+	}
+	stages := make(map[string]int, len(templates))
+	var stage func(p string) int
+	stage = func(p string) int {
+		if s, ok := stages[p]; ok {
+			return s
+		}
+		s := 0
+		for _, dep := range dependsOn[p] {
+			if _, ok := dependsOn[dep]; ok {
+				s = max(s, stage(dep)+1)
+			}
+		}
+		stages[p] = s
+		return s
+	}
+	for _, temp := range templates {
+		stage(temp.GetPath())
+	}
+	return stages
+}
+
+// hasDependencies checks if any of the templates depends on another template
+func hasDependencies(templates []ReferenceTemplate) bool {
+	return slices.ContainsFunc(templates, func(t ReferenceTemplate) bool { return len(t.GetConfig().GetDependsOn()) > 0 })
+}
+
+// orderByDependencies sorts the templates by their stage, templates of the same stage are kept in the order of the
+// reference
+func orderByDependencies(templates []ReferenceTemplate) []ReferenceTemplate {
+	if !hasDependencies(templates) {
+		return templates
+	}
+	stages := dependencyStages(templates)
+	ordered := slices.Clone(templates)
+	slices.SortStableFunc(ordered, func(a, b ReferenceTemplate) int { return stages[a.GetPath()] - stages[b.GetPath()] })
+	return ordered
+}
+
+// orderTypesByDependencies sorts the types listed from the cluster by the stage of their templates, so the CRs of the
+// templates that others depend on are compared first. The CRs of a type are compared in the last stage of the
+// templates of the type.
+func orderTypesByDependencies(types []string, templates []ReferenceTemplate) []string {
+	if !hasDependencies(templates) {
+		return types
+	}
+	stages := typeStages(templates)
+	ordered := slices.Clone(types)
+	slices.SortStableFunc(ordered, func(a, b string) int { return stages(a) - stages(b) })
+	return ordered
+}
+
+// typeStages returns the stage in which the CRs of a type are compared, the last stage of the templates of its kind.
+// Types are in the form of {kind} or {kind}.{version}.{group}.
+func typeStages(templates []ReferenceTemplate) func(resourceType string) int {
+	stages := dependencyStages(templates)
+	kindStages := make(map[string]int)
+	for _, temp := range templates {
+		kind := temp.GetMetadata().GetKind()
+		kindStages[kind] = max(kindStages[kind], stages[temp.GetPath()])
+	}
+	return func(resourceType string) int {
+		kind, _, _ := strings.Cut(resourceType, ".")
+		return kindStages[kind]
+	}
+}
+
+// unmetDependency returns the reason a template isn't applicable because one of its dependencies isn't, an empty
+// string if all its dependencies are applicable
+func unmetDependency(temp ReferenceTemplate, notApplicable map[string]bool) string {
+	for _, dep := range temp.GetConfig().GetDependsOn() {
+		if notApplicable[dep] {
+			return fmt.Sprintf("depends on %s, which is not applicable", dep)
+		}
+	}
+	return ""
+}
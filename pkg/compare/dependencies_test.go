@@ -0,0 +1,41 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestOrderByDependencies(t *testing.T) {
+	newTemplate := func(path, kind string, dependsOn ...string) ReferenceTemplate {
+		metadata := &unstructured.Unstructured{Object: map[string]any{}}
+		metadata.SetAPIVersion("v1")
+		metadata.SetKind(kind)
+		return ReferenceTemplateV2{
+			Config:              ReferenceTemplateConfigV2{DependsOn: dependsOn},
+			ReferenceTemplateV1: ReferenceTemplateV1{Path: path, metadata: metadata},
+		}
+	}
+	templates := []ReferenceTemplate{
+		newTemplate("settings.yaml", "ConfigMap", "namespace.yaml"),
+		newTemplate("deployment.yaml", "Deployment", "settings.yaml", "filtered.yaml"),
+		newTemplate("namespace.yaml", "Namespace"),
+		newTemplate("other.yaml", "ConfigMap"),
+	}
+	require.Equal(t, map[string]int{"namespace.yaml": 0, "other.yaml": 0, "settings.yaml": 1, "deployment.yaml": 2},
+		dependencyStages(templates), "dependencies on templates that aren't compared should be ignored")
+
+	var paths []string
+	for _, temp := range orderByDependencies(templates) {
+		paths = append(paths, temp.GetPath())
+	}
+	require.Equal(t, []string{"namespace.yaml", "other.yaml", "settings.yaml", "deployment.yaml"}, paths)
+
+	types := []string{"ConfigMap", "Deployment.v1.apps", "Namespace", "Secret"}
+	require.Equal(t, []string{"Namespace", "Secret", "ConfigMap", "Deployment.v1.apps"}, orderTypesByDependencies(types, templates),
+		"types should be ordered by the last stage of their templates")
+	require.Equal(t, types, orderTypesByDependencies(types, templates[2:]))
+}
@@ -0,0 +1,265 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+	"k8s.io/cli-runtime/pkg/resource"
+	kcmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"k8s.io/kubectl/pkg/util/i18n"
+	"k8s.io/kubectl/pkg/util/templates"
+	"sigs.k8s.io/yaml"
+)
+
+var (
+	discoverFieldsLong = templates.LongDesc(`
+		Report which fields of the resources of a kind vary across their instances, to help writing reference templates.
+
+		The discover-fields command reads the resources of the given kind from the cluster, or from local files, removes
+		the fields populated by the API server (status, managed fields, uid, resource version...) and reports for every
+		field how many of the resources set it and how many distinct values it has. A suggestion is made for each field:
+		fixed fields have the same value in all the resources and can be kept as is in the template, templated fields
+		have different values and should be replaced with a template expression or a capture group, and optional fields
+		are only set by some of the resources and should be wrapped in a condition. The more sample resources, the more
+		accurate the suggestions.
+	`)
+
+	discoverFieldsExample = templates.Examples(`
+		# Report the fields of the deployments of a namespace that vary:
+		kubectl cluster-compare discover-fields --kind Deployment -n my-namespace
+
+		# Report the fields of the config maps of a must-gather as json:
+		kubectl cluster-compare discover-fields --kind ConfigMap -f ./must-gather -R -o json
+	`)
+)
+
+const (
+	FieldFixed     = "fixed"
+	FieldTemplated = "templated"
+	FieldOptional  = "optional"
+)
+
+// maxFieldExamples is the maximum number of distinct values reported as examples for a field
+const maxFieldExamples = 3
+
+// DiscoveredField is a field of the sample resources with the suggestion of how to write it in the template
+type DiscoveredField struct {
+	// Path is the path of the field, e.g. .spec.template.spec.containers[0].image
+	Path string `json:"path"`
+	// Present is the number of sample resources setting the field
+	Present int `json:"present"`
+	// Values is the number of distinct values of the field
+	Values int `json:"values"`
+	// Examples are some of the distinct values of the field, sorted
+	Examples   []string `json:"examples"`
+	Suggestion string   `json:"suggestion"`
+}
+
+// DiscoverFieldsResult is the output of the discover-fields command in json and yaml formats
+type DiscoverFieldsResult struct {
+	Kind    string            `json:"kind"`
+	Samples int               `json:"samples"`
+	Fields  []DiscoveredField `json:"fields"`
+}
+
+type DiscoverFieldsOptions struct {
+	kind         string
+	namespace    string
+	CRs          resource.FilenameOptions
+	OutputFormat string
+
+	local      bool
+	newBuilder func() *resource.Builder
+	genericiooptions.IOStreams
+}
+
+func NewDiscoverFieldsCmd(f kcmdutil.Factory, streams genericiooptions.IOStreams) *cobra.Command {
+	options := &DiscoverFieldsOptions{IOStreams: streams}
+
+	cmd := &cobra.Command{
+		Use:                   "discover-fields --kind <Kind>",
+		DisableFlagsInUseLine: true,
+		Short:                 i18n.T("Report the fields that vary across the resources of a kind."),
+		Long:                  discoverFieldsLong,
+		Example:               exampleForBinary(discoverFieldsExample),
+		Run: func(cmd *cobra.Command, args []string) {
+			kcmdutil.CheckDiffErr(options.Complete(f, cmd, args))
+			kcmdutil.CheckDiffErr(options.Run())
+		},
+	}
+	cmd.SetFlagErrorFunc(func(command *cobra.Command, err error) error {
+		kcmdutil.CheckDiffErr(kcmdutil.UsageErrorf(cmd, err.Error()))
+		return nil
+	})
+	cmd.Flags().StringVar(&options.kind, "kind", "", "Kind of the sample resources")
+	cmd.Flags().StringVarP(&options.namespace, "namespace", "n", "",
+		"Only fetch namespaced resources from this namespace, resources are fetched from all namespaces if not set")
+	kcmdutil.AddFilenameOptionFlags(cmd, &options.CRs, "contains the sample resources, instead of fetching them from the cluster")
+	cmd.Flags().StringVarP(&options.OutputFormat, "output", "o", "", fmt.Sprintf(`Output format. One of: (%s, %s)`, Json, Yaml))
+	return cmd
+}
+
+func (o *DiscoverFieldsOptions) Complete(f kcmdutil.Factory, cmd *cobra.Command, args []string) error {
+	if len(args) != 0 {
+		return kcmdutil.UsageErrorf(cmd, "Unexpected args: %v", args)
+	}
+	if o.kind == "" {
+		return kcmdutil.UsageErrorf(cmd, "--kind is required")
+	}
+	if o.OutputFormat != "" && o.OutputFormat != Json && o.OutputFormat != Yaml {
+		return kcmdutil.UsageErrorf(cmd, "Invalid output format %q, must be one of: %s, %s", o.OutputFormat, Json, Yaml)
+	}
+	o.local = len(o.CRs.Filenames) > 0
+	if o.local && o.namespace != "" {
+		return kcmdutil.UsageErrorf(cmd, "--namespace can't be used with local files")
+	}
+	o.newBuilder = f.NewBuilder
+	return nil
+}
+
+// Run reads the sample resources and prints the fields they set
+func (o *DiscoverFieldsOptions) Run() error {
+	b := o.newBuilder().Unstructured()
+	if o.local {
+		b = b.Local().FilenameParam(false, &o.CRs)
+	} else {
+		b = b.NamespaceParam(o.namespace).AllNamespaces(o.namespace == "").ResourceTypes(o.kind).SelectAllParam(true)
+	}
+	infos, err := b.ContinueOnError().Flatten().Do().Infos()
+	if err != nil {
+		return fmt.Errorf("failed to collect resources: %w", err)
+	}
+	var objs []*unstructured.Unstructured
+	for _, info := range infos {
+		obj, ok := info.Object.(*unstructured.Unstructured)
+		// Local files hold resources of any kind, in live mode the kind may be given as a resource name or short name
+		if ok && (!o.local || strings.EqualFold(obj.GetKind(), o.kind)) {
+			objs = append(objs, obj)
+		}
+	}
+	if len(objs) == 0 {
+		return fmt.Errorf("no resources of kind %s found", o.kind)
+	}
+	result := DiscoverFieldsResult{Kind: objs[0].GetKind(), Samples: len(objs), Fields: discoverFields(objs)}
+	if err := o.print(result); err != nil {
+		return fmt.Errorf("error occurred when writing output: %w", err)
+	}
+	return nil
+}
+
+// discoverFields returns the fields set by the resources, without the fields populated by the API server, sorted by
+// path
+func discoverFields(objs []*unstructured.Unstructured) []DiscoveredField {
+	values := make(map[string]map[string]bool)
+	present := make(map[string]int)
+	for _, obj := range objs {
+		leaves := make(map[string]string)
+		collectLeafFields("", withoutServerPopulatedFields(obj).Object, leaves)
+		for p, v := range leaves {
+			if values[p] == nil {
+				values[p] = make(map[string]bool)
+			}
+			values[p][v] = true
+			present[p]++
+		}
+	}
+	fields := make([]DiscoveredField, 0, len(values))
+	for p, distinct := range values {
+		examples := make([]string, 0, len(distinct))
+		for v := range distinct {
+			examples = append(examples, v)
+		}
+		sort.Strings(examples)
+		field := DiscoveredField{
+			Path:     p,
+			Present:  present[p],
+			Values:   len(distinct),
+			Examples: examples[:min(len(examples), maxFieldExamples)],
+		}
+		switch {
+		case field.Present < len(objs):
+			field.Suggestion = FieldOptional
+		case field.Values > 1:
+			field.Suggestion = FieldTemplated
+		default:
+			field.Suggestion = FieldFixed
+		}
+		fields = append(fields, field)
+	}
+	sort.Slice(fields, func(i, j int) bool { return fields[i].Path < fields[j].Path })
+	return fields
+}
+
+// collectLeafFields records the value of every scalar field, empty map and empty list under the path, the values are
+// recorded as json so values of different types don't collide
+func collectLeafFields(p string, value any, leaves map[string]string) {
+	switch v := value.(type) {
+	case map[string]any:
+		if len(v) > 0 {
+			for key, child := range v {
+				collectLeafFields(p+fieldPathKey(key), child, leaves)
+			}
+			return
+		}
+	case []any:
+		if len(v) > 0 {
+			for i, child := range v {
+				collectLeafFields(p+"["+strconv.Itoa(i)+"]", child, leaves)
+			}
+			return
+		}
+	}
+	content, err := json.Marshal(value)
+	if err != nil {
+		content = []byte(fmt.Sprint(value))
+	}
+	leaves[p] = string(content)
+}
+
+// fieldPathKey returns the segment of the key in a path, keys containing dots or brackets are quoted as in the
+// pathToKey syntax, e.g. ."example.com/key"
+func fieldPathKey(key string) string {
+	if strings.ContainsAny(key, `.[]"`) {
+		return `."` + key + `"`
+	}
+	return "." + key
+}
+
+func (o *DiscoverFieldsOptions) print(result DiscoverFieldsResult) error {
+	var content []byte
+	var err error
+	switch o.OutputFormat {
+	case Json:
+		content, err = json.Marshal(result)
+		content = append(content, '\n')
+	case Yaml:
+		content, err = yaml.Marshal(result)
+	default:
+		var sb strings.Builder
+		fmt.Fprintf(&sb, "Fields of %d %s resources:\n", result.Samples, result.Kind)
+		w := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "FIELD\tPRESENT\tVALUES\tSUGGESTION\tEXAMPLES")
+		for _, f := range result.Fields {
+			fmt.Fprintf(w, "%s\t%d/%d\t%d\t%s\t%s\n", f.Path, f.Present, result.Samples, f.Values, f.Suggestion, strings.Join(f.Examples, ", "))
+		}
+		err = w.Flush()
+		if result.Samples < 2 {
+			sb.WriteString("Only one resource was found, the fields of more resources are needed to tell which fields vary\n")
+		}
+		content = []byte(sb.String())
+	}
+	if err != nil {
+		return err // nolint:wrapcheck
+	}
+	_, err = o.Out.Write(content)
+	return err // nolint:wrapcheck
+}
@@ -0,0 +1,53 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+)
+
+func TestDiscoverFields(t *testing.T) {
+	deployment := func(name string, replicas int64, annotations map[string]any) *unstructured.Unstructured {
+		metadata := map[string]any{"name": name, "namespace": "example", "uid": name, "resourceVersion": "1"}
+		if annotations != nil {
+			metadata["annotations"] = annotations
+		}
+		return &unstructured.Unstructured{Object: map[string]any{
+			"apiVersion": "apps/v1",
+			"kind":       "Deployment",
+			"metadata":   metadata,
+			"spec": map[string]any{
+				"replicas": replicas,
+				"template": map[string]any{"spec": map[string]any{
+					"containers": []any{map[string]any{"name": "app", "image": "registry.example.com/app:v1"}},
+				}},
+			},
+			"status": map[string]any{"readyReplicas": replicas},
+		}}
+	}
+	objs := []*unstructured.Unstructured{
+		deployment("a", 1, map[string]any{"example.com/owner": "team-a"}),
+		deployment("b", 3, nil),
+		deployment("c", 3, nil),
+	}
+
+	out := &bytes.Buffer{}
+	o := &DiscoverFieldsOptions{IOStreams: genericiooptions.IOStreams{Out: out}}
+	require.NoError(t, o.print(DiscoverFieldsResult{Kind: "Deployment", Samples: len(objs), Fields: discoverFields(objs)}))
+	require.Equal(t, `Fields of 3 Deployment resources:
+FIELD                                      PRESENT  VALUES  SUGGESTION  EXAMPLES
+.apiVersion                                3/3      1       fixed       "apps/v1"
+.kind                                      3/3      1       fixed       "Deployment"
+.metadata.annotations."example.com/owner"  1/3      1       optional    "team-a"
+.metadata.name                             3/3      3       templated   "a", "b", "c"
+.metadata.namespace                        3/3      1       fixed       "example"
+.spec.replicas                             3/3      2       templated   1, 3
+.spec.template.spec.containers[0].image    3/3      1       fixed       "registry.example.com/app:v1"
+.spec.template.spec.containers[0].name     3/3      1       fixed       "app"
+`, out.String())
+}
@@ -0,0 +1,156 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/cli-runtime/pkg/resource"
+)
+
+const (
+	// DriftAnnotation is set to "true" on the cluster CRs with diffs by --annotate-drift
+	DriftAnnotation = "cluster-compare.openshift.io/drift"
+	// DriftHashAnnotation holds the hash of the changed lines of the diff of the cluster CRs annotated with
+	// DriftAnnotation, it changes when the drift of the CR changes
+	DriftHashAnnotation = "cluster-compare.openshift.io/drift-hash"
+)
+
+var driftAnnotations = []string{DriftAnnotation, DriftHashAnnotation}
+
+// DriftAnnotationsSummary counts the cluster CRs whose drift annotations were written or removed
+type DriftAnnotationsSummary struct {
+	Annotated int `json:"Annotated"`
+	Removed   int `json:"Removed"`
+	// Failed lists the CRs that couldn't be patched along with the error
+	Failed []string `json:"Failed,omitempty"`
+}
+
+// driftAnnotator patches the drift annotations of the cluster CRs. By default the CRs with diffs are annotated and the
+// annotations of the CRs compared without diffs are removed, in the removal mode the annotations are removed from all
+// the visited cluster CRs. CRs that already have the expected annotations aren't patched. A nil annotator doesn't
+// patch anything.
+type driftAnnotator struct {
+	removeAll bool
+
+	mu      sync.Mutex
+	summary DriftAnnotationsSummary
+}
+
+func newDriftAnnotator(removeAll bool) *driftAnnotator {
+	return &driftAnnotator{removeAll: removeAll}
+}
+
+// driftHash hashes the changed lines of the diff, it changes when the drift of the CR changes
+func driftHash(diff string) string {
+	hash := sha256.New()
+	for _, line := range changedLines(diff) {
+		hash.Write([]byte(line + "\n"))
+	}
+	return hex.EncodeToString(hash.Sum(nil))[:16]
+}
+
+// stripDriftAnnotations removes the drift annotations from the cluster CR so they aren't reported as diffs, and
+// returns them
+func stripDriftAnnotations(clusterCR *unstructured.Unstructured) map[string]string {
+	annotations := clusterCR.GetAnnotations()
+	stripped := make(map[string]string)
+	for _, k := range driftAnnotations {
+		if v, ok := annotations[k]; ok {
+			stripped[k] = v
+			delete(annotations, k)
+		}
+	}
+	if len(stripped) == 0 {
+		return stripped
+	}
+	if len(annotations) == 0 {
+		unstructured.RemoveNestedField(clusterCR.Object, "metadata", "annotations")
+	} else {
+		clusterCR.SetAnnotations(annotations)
+	}
+	return stripped
+}
+
+// visited removes the drift annotations of every cluster CR in the removal mode, current are the drift annotations
+// of the CR
+func (a *driftAnnotator) visited(info *resource.Info, clusterCR *unstructured.Unstructured, current map[string]string) {
+	if a == nil || !a.removeAll {
+		return
+	}
+	a.remove(info, clusterCR, current)
+}
+
+// compared sets the drift annotations of the cluster CR compared with the diff, or removes them if there's no diff
+func (a *driftAnnotator) compared(info *resource.Info, clusterCR *unstructured.Unstructured, current map[string]string, diff string) {
+	if a == nil || a.removeAll {
+		return
+	}
+	if diff == "" {
+		a.remove(info, clusterCR, current)
+		return
+	}
+	hash := driftHash(diff)
+	if current[DriftAnnotation] == "true" && current[DriftHashAnnotation] == hash {
+		return
+	}
+	if a.patch(info, clusterCR, map[string]any{DriftAnnotation: "true", DriftHashAnnotation: hash}) {
+		a.mu.Lock()
+		a.summary.Annotated++
+		a.mu.Unlock()
+	}
+}
+
+// remove removes the drift annotations of the cluster CR, if it has any
+func (a *driftAnnotator) remove(info *resource.Info, clusterCR *unstructured.Unstructured, current map[string]string) {
+	if len(current) == 0 {
+		return
+	}
+	annotations := make(map[string]any, len(current))
+	for k := range current {
+		annotations[k] = nil
+	}
+	if a.patch(info, clusterCR, annotations) {
+		a.mu.Lock()
+		a.summary.Removed++
+		a.mu.Unlock()
+	}
+}
+
+// patch merges the annotations into the ones of the cluster CR, nil values remove them. Failures are reported in the
+// summary, they don't stop the comparison.
+func (a *driftAnnotator) patch(info *resource.Info, clusterCR *unstructured.Unstructured, annotations map[string]any) bool {
+	data, err := json.Marshal(map[string]any{"metadata": map[string]any{"annotations": annotations}})
+	if err == nil {
+		_, err = resource.NewHelper(info.Client, info.Mapping).
+			WithFieldManager(serverSideDryRunFieldManager).
+			Patch(clusterCR.GetNamespace(), clusterCR.GetName(), types.MergePatchType, data, nil)
+	}
+	if err != nil {
+		a.mu.Lock()
+		a.summary.Failed = append(a.summary.Failed, fmt.Sprintf("%s: %s", apiKindNamespaceName(clusterCR), err))
+		a.mu.Unlock()
+		return false
+	}
+	return true
+}
+
+// summarize returns the counts of the patched CRs, nil if the annotations aren't written
+func (a *driftAnnotator) summarize() *DriftAnnotationsSummary {
+	if a == nil {
+		return nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	summary := a.summary
+	summary.Failed = append([]string(nil), a.summary.Failed...)
+	sort.Strings(summary.Failed)
+	return &summary
+}
@@ -0,0 +1,97 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/cli-runtime/pkg/resource"
+	"k8s.io/client-go/rest/fake"
+	cmdtesting "k8s.io/kubectl/pkg/cmd/testing"
+)
+
+func TestDriftHash(t *testing.T) {
+	diff := "--- /tmp/a/cm\t2024-01-01\n+++ /tmp/b/cm\t2024-01-01\n@@ -1,3 +1,3 @@\n data:\n-  mode: strict\n+  mode: relaxed\n"
+	sameDrift := "--- /tmp/c/cm\t2025-06-01\n+++ /tmp/d/cm\t2025-06-01\n@@ -2,3 +2,3 @@\n data:\n-  mode: strict\n+  mode: relaxed\n"
+	require.Len(t, driftHash(diff), 16)
+	require.Equal(t, driftHash(diff), driftHash(sameDrift), "the hash shouldn't depend on the files compared by the diff")
+	require.NotEqual(t, driftHash(diff), driftHash("-  mode: strict\n+  mode: lax\n"))
+}
+
+func TestStripDriftAnnotations(t *testing.T) {
+	cr := &unstructured.Unstructured{}
+	cr.SetAnnotations(map[string]string{DriftAnnotation: "true", DriftHashAnnotation: "0123456789abcdef", "example.com/key": "value"})
+	require.Equal(t, map[string]string{DriftAnnotation: "true", DriftHashAnnotation: "0123456789abcdef"}, stripDriftAnnotations(cr))
+	require.Equal(t, map[string]string{"example.com/key": "value"}, cr.GetAnnotations())
+
+	cr.SetAnnotations(map[string]string{DriftAnnotation: "true"})
+	stripDriftAnnotations(cr)
+	_, found, _ := unstructured.NestedFieldNoCopy(cr.Object, "metadata", "annotations")
+	require.False(t, found, "empty annotations should be removed so they don't show in the diff")
+}
+
+func TestDriftAnnotator(t *testing.T) {
+	var patches []string
+	client := &fake.RESTClient{
+		NegotiatedSerializer: resource.UnstructuredPlusDefaultContentConfig().NegotiatedSerializer,
+		Client: fake.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
+			require.Equal(t, http.MethodPatch, req.Method)
+			body, err := io.ReadAll(req.Body)
+			require.NoError(t, err)
+			patches = append(patches, string(body))
+			if req.URL.Path != "/namespaces/example/configmaps/settings" {
+				return &http.Response{StatusCode: http.StatusForbidden, Header: cmdtesting.DefaultHeader(),
+					Body: io.NopCloser(bytes.NewReader([]byte(`{"kind":"Status","apiVersion":"v1","status":"Failure","reason":"Forbidden","message":"forbidden","code":403}`)))}, nil
+			}
+			return &http.Response{StatusCode: http.StatusOK, Header: cmdtesting.DefaultHeader(),
+				Body: io.NopCloser(bytes.NewReader([]byte(`{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"settings","namespace":"example"}}`)))}, nil
+		}),
+	}
+	info := &resource.Info{Client: client, Mapping: &meta.RESTMapping{
+		Resource:         schema.GroupVersionResource{Version: "v1", Resource: "configmaps"},
+		GroupVersionKind: schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"},
+		Scope:            meta.RESTScopeNamespace,
+	}}
+	configMap := func(name string) *unstructured.Unstructured {
+		cr := &unstructured.Unstructured{}
+		cr.SetAPIVersion("v1")
+		cr.SetKind("ConfigMap")
+		cr.SetNamespace("example")
+		cr.SetName(name)
+		return cr
+	}
+	diff := "-  mode: strict\n+  mode: relaxed\n"
+
+	a := newDriftAnnotator(false)
+	a.compared(info, configMap("settings"), map[string]string{DriftAnnotation: "true", DriftHashAnnotation: driftHash(diff)}, diff)
+	require.Empty(t, patches, "CRs with up to date annotations shouldn't be patched")
+	a.compared(info, configMap("settings"), nil, "")
+	require.Empty(t, patches, "CRs without diffs or annotations shouldn't be patched")
+
+	a.compared(info, configMap("settings"), map[string]string{DriftHashAnnotation: "0123456789abcdef"}, diff)
+	require.JSONEq(t, `{"metadata":{"annotations":{"`+DriftAnnotation+`":"true","`+DriftHashAnnotation+`":"`+driftHash(diff)+`"}}}`, patches[0])
+	a.compared(info, configMap("settings"), map[string]string{DriftAnnotation: "true"}, "")
+	require.JSONEq(t, `{"metadata":{"annotations":{"`+DriftAnnotation+`":null}}}`, patches[1])
+	a.compared(info, configMap("limits"), nil, diff)
+	a.visited(info, configMap("limits"), map[string]string{DriftAnnotation: "true"})
+	require.Len(t, patches, 3, "CRs are only cleared when visited in the removal mode")
+	require.Equal(t, &DriftAnnotationsSummary{Annotated: 1, Removed: 1, Failed: []string{"v1_ConfigMap_example_limits: forbidden"}}, a.summarize())
+
+	a = newDriftAnnotator(true)
+	a.visited(info, configMap("settings"), map[string]string{DriftAnnotation: "true", DriftHashAnnotation: "0123456789abcdef"})
+	a.compared(info, configMap("settings"), nil, diff)
+	require.Len(t, patches, 4, "CRs shouldn't be annotated in the removal mode")
+	require.Equal(t, &DriftAnnotationsSummary{Removed: 1}, a.summarize())
+
+	var disabled *driftAnnotator
+	disabled.compared(info, configMap("settings"), nil, diff)
+	require.Len(t, patches, 4)
+	require.Nil(t, disabled.summarize())
+}
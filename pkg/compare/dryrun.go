@@ -0,0 +1,170 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/cli-runtime/pkg/resource"
+	kcmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"sigs.k8s.io/yaml"
+)
+
+// resourceCount is the number of resources of a type in the cluster, the count is a lower bound when the API server
+// didn't report the number of remaining resources
+type resourceCount struct {
+	count int
+	exact bool
+}
+
+// resourceCounter counts the resources of a type in the cluster
+type resourceCounter func(resourceType string) (resourceCount, error)
+
+// newResourceCounter creates a counter listing a single resource of each type from the cluster, the rest of the
+// resources are counted from the remaining item count reported by the API server so their bodies aren't fetched
+func newResourceCounter(f kcmdutil.Factory) resourceCounter {
+	return func(resourceType string) (resourceCount, error) {
+		mapper, err := f.ToRESTMapper()
+		if err != nil {
+			return resourceCount{}, fmt.Errorf("failed to create rest mapper: %w", err)
+		}
+		mapping, err := restMappingFor(mapper, resourceType)
+		if err != nil {
+			return resourceCount{}, err
+		}
+		client, err := f.UnstructuredClientForMapping(mapping)
+		if err != nil {
+			return resourceCount{}, fmt.Errorf("failed to create client for %s: %w", resourceType, err)
+		}
+		list, err := resource.NewHelper(client, mapping).List("", "", &metav1.ListOptions{Limit: 1})
+		if err != nil {
+			return resourceCount{}, fmt.Errorf("failed to list %s: %w", resourceType, err)
+		}
+		n := meta.LenList(list)
+		listMeta, err := meta.ListAccessor(list)
+		if err != nil {
+			return resourceCount{}, fmt.Errorf("failed to count %s: %w", resourceType, err)
+		}
+		if remaining := listMeta.GetRemainingItemCount(); remaining != nil {
+			return resourceCount{count: n + int(*remaining), exact: true}, nil
+		}
+		return resourceCount{count: n, exact: listMeta.GetContinue() == ""}, nil
+	}
+}
+
+// DryRunKind is the estimation of the comparison of the resources of a type
+type DryRunKind struct {
+	Type      string `json:"Type"`
+	Templates int    `json:"Templates"`
+	Resources int    `json:"Resources"`
+	// Exact is false when there are at least Resources resources of the type
+	Exact bool   `json:"Exact"`
+	Error string `json:"Error,omitempty"`
+}
+
+// DryRunOutput is the estimation of a comparison, without fetching the resources or diffing them
+type DryRunOutput struct {
+	Kinds              []DryRunKind `json:"Kinds"`
+	Templates          int          `json:"Templates"`
+	ReferenceTemplates int          `json:"ReferenceTemplates"`
+	Resources          int          `json:"Resources"`
+	UnsupportedKinds   []string     `json:"UnsupportedKinds,omitempty"`
+}
+
+// estimate counts the resources of every type that would be fetched from the cluster and the templates they would be
+// compared to
+func (o *Options) estimate() DryRunOutput {
+	templatesByKind := make(map[string]int)
+	for _, t := range o.templates {
+		templatesByKind[t.GetMetadata().GetKind()]++
+	}
+	output := DryRunOutput{ReferenceTemplates: len(o.ref.GetTemplates())}
+	supportedKinds := make(map[string]bool)
+	for _, t := range o.types {
+		kind, _, _ := strings.Cut(t, ".")
+		if !supportedKinds[kind] {
+			output.Templates += templatesByKind[kind]
+		}
+		supportedKinds[kind] = true
+		k := DryRunKind{Type: t, Templates: templatesByKind[kind]}
+		count, err := o.countResources(t)
+		if err != nil {
+			k.Error = err.Error()
+		} else {
+			k.Resources, k.Exact = count.count, count.exact
+			output.Resources += count.count
+		}
+		output.Kinds = append(output.Kinds, k)
+	}
+	for kind := range templatesByKind {
+		if !supportedKinds[kind] {
+			output.UnsupportedKinds = append(output.UnsupportedKinds, kind)
+		}
+	}
+	sort.Strings(output.UnsupportedKinds)
+	return output
+}
+
+func (o DryRunOutput) String() string {
+	var sb strings.Builder
+	w := tabwriter.NewWriter(&sb, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "TYPE\tTEMPLATES\tRESOURCES")
+	exact := true
+	for _, k := range o.Kinds {
+		resources := fmt.Sprint(k.Resources)
+		switch {
+		case k.Error != "":
+			resources = "error: " + k.Error
+			exact = false
+		case !k.Exact:
+			resources += "+"
+			exact = false
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\n", k.Type, k.Templates, resources)
+	}
+	w.Flush()
+
+	fmt.Fprintf(&sb, "\nTemplates that would be compared: %d/%d\n", o.Templates, o.ReferenceTemplates)
+	if exact {
+		fmt.Fprintf(&sb, "Resources that would be fetched: %d\n", o.Resources)
+	} else {
+		fmt.Fprintf(&sb, "Resources that would be fetched: at least %d\n", o.Resources)
+	}
+	if len(o.UnsupportedKinds) > 0 {
+		fmt.Fprintf(&sb, "Kinds of templates not supported by the cluster: %s\n", strings.Join(o.UnsupportedKinds, ", "))
+	}
+	return sb.String()
+}
+
+func (o DryRunOutput) Print(format string, out io.Writer) error {
+	var (
+		content []byte
+		err     error
+	)
+	switch format {
+	case Json:
+		content, err = json.Marshal(o)
+		if err != nil {
+			return fmt.Errorf("failed to marshal output to json: %w", err)
+		}
+		content = append(content, []byte("\n")...)
+	case Yaml:
+		content, err = yaml.Marshal(o)
+		if err != nil {
+			return fmt.Errorf("failed to marshal output to yaml: %w", err)
+		}
+	default:
+		content = []byte(o.String())
+	}
+	if _, err := out.Write(content); err != nil {
+		return fmt.Errorf("error occurred when writing output: %w", err)
+	}
+	return nil
+}
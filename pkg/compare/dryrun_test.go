@@ -0,0 +1,26 @@
+package compare
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDryRunOutputString(t *testing.T) {
+	out := DryRunOutput{
+		Kinds: []DryRunKind{
+			{Type: "ConfigMap", Templates: 2, Resources: 500},
+			{Type: "Secret", Templates: 1, Error: "failed to list Secret: forbidden"},
+		},
+		Templates:          3,
+		ReferenceTemplates: 3,
+		Resources:          500,
+	}
+	assert.Equal(t, `TYPE        TEMPLATES   RESOURCES
+ConfigMap   2           500+
+Secret      1           error: failed to list Secret: forbidden
+
+Templates that would be compared: 3/3
+Resources that would be fetched: at least 500
+`, out.String())
+}
@@ -0,0 +1,126 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	kcmdutil "k8s.io/kubectl/pkg/cmd/util"
+)
+
+const (
+	// DriftEventReason is the reason of the events created by --emit-events for the cluster CRs with diffs
+	DriftEventReason = "ConfigDrift"
+	// driftEventComponent is the source component of the drift events
+	driftEventComponent = "cluster-compare"
+	// clusterScopedEventNamespace is the namespace of the events of cluster scoped CRs, as for the events of nodes
+	clusterScopedEventNamespace = metav1.NamespaceDefault
+)
+
+var eventGVR = schema.GroupVersionResource{Version: "v1", Resource: "events"}
+
+// DriftEventsSummary counts the drift events created for the cluster CRs with diffs
+type DriftEventsSummary struct {
+	Emitted int `json:"Emitted"`
+	// Failed lists the CRs whose event couldn't be created along with the error
+	Failed []string `json:"Failed,omitempty"`
+}
+
+// driftEventEmitter creates a Warning event with the ConfigDrift reason for each cluster CR with diffs, so the
+// alerting based on events picks up the drift. Failures are reported in the summary, they don't stop the comparison.
+// A nil emitter doesn't create anything.
+type driftEventEmitter struct {
+	client dynamic.Interface
+	now    func() time.Time
+
+	mu      sync.Mutex
+	summary DriftEventsSummary
+}
+
+func newDriftEventEmitter(f kcmdutil.Factory) (*driftEventEmitter, error) {
+	client, err := f.DynamicClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dynamic client: %w", err)
+	}
+	return &driftEventEmitter{client: client, now: time.Now}, nil
+}
+
+// driftEventMessage summarizes the diff of the cluster CR with the template it was compared to
+func driftEventMessage(template, diff string) string {
+	return fmt.Sprintf("Drifted from the reference template %s: %d changed lines", template, len(changedLines(diff)))
+}
+
+// newDriftEvent returns the event reporting the drift of the cluster CR
+func newDriftEvent(clusterCR *unstructured.Unstructured, message string, now time.Time) *unstructured.Unstructured {
+	namespace := clusterCR.GetNamespace()
+	if namespace == "" {
+		namespace = clusterScopedEventNamespace
+	}
+	timestamp := now.UTC().Format(time.RFC3339)
+	involvedObject := map[string]any{
+		"apiVersion":      clusterCR.GetAPIVersion(),
+		"kind":            clusterCR.GetKind(),
+		"name":            clusterCR.GetName(),
+		"uid":             string(clusterCR.GetUID()),
+		"resourceVersion": clusterCR.GetResourceVersion(),
+	}
+	if clusterCR.GetNamespace() != "" {
+		involvedObject["namespace"] = clusterCR.GetNamespace()
+	}
+	return &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "v1",
+		"kind":       "Event",
+		"metadata": map[string]any{
+			// The name scheme of the events recorded by client-go
+			"name":      fmt.Sprintf("%s.%x", clusterCR.GetName(), now.UnixNano()),
+			"namespace": namespace,
+		},
+		"involvedObject":     involvedObject,
+		"reason":             DriftEventReason,
+		"message":            message,
+		"type":               "Warning",
+		"source":             map[string]any{"component": driftEventComponent},
+		"reportingComponent": driftEventComponent,
+		"firstTimestamp":     timestamp,
+		"lastTimestamp":      timestamp,
+		"count":              int64(1),
+	}}
+}
+
+// drifted creates the drift event of the cluster CR compared to the template with the diff, CRs without diff don't
+// get any
+func (e *driftEventEmitter) drifted(ctx context.Context, clusterCR *unstructured.Unstructured, template, diff string) {
+	if e == nil || diff == "" {
+		return
+	}
+	event := newDriftEvent(clusterCR, driftEventMessage(template, diff), e.now())
+	_, err := e.client.Resource(eventGVR).Namespace(event.GetNamespace()).Create(ctx, event, metav1.CreateOptions{})
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if err != nil {
+		e.summary.Failed = append(e.summary.Failed, fmt.Sprintf("%s: %s", apiKindNamespaceName(clusterCR), err))
+		return
+	}
+	e.summary.Emitted++
+}
+
+// summarize returns the count of the created events, nil if the events aren't emitted
+func (e *driftEventEmitter) summarize() *DriftEventsSummary {
+	if e == nil {
+		return nil
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	summary := e.summary
+	summary.Failed = append([]string(nil), e.summary.Failed...)
+	sort.Strings(summary.Failed)
+	return &summary
+}
@@ -0,0 +1,69 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakedynamic "k8s.io/client-go/dynamic/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+func TestDriftEventEmitter(t *testing.T) {
+	client := fakedynamic.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{eventGVR: "EventList"})
+	client.PrependReactor("create", "events", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		event := action.(clienttesting.CreateAction).GetObject().(*unstructured.Unstructured)
+		if event.GetNamespace() == "restricted" {
+			return true, nil, errors.New("forbidden")
+		}
+		return false, nil, nil
+	})
+	now := time.Date(2024, 5, 6, 7, 0, 0, 0, time.UTC)
+	e := &driftEventEmitter{client: client, now: func() time.Time { return now }}
+	cr := func(kind, namespace, name string) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion("v1")
+		obj.SetKind(kind)
+		obj.SetNamespace(namespace)
+		obj.SetName(name)
+		obj.SetUID("1234")
+		return obj
+	}
+	diff := "--- /tmp/a/cm\n+++ /tmp/b/cm\n@@ -1,3 +1,3 @@\n data:\n-  mode: strict\n+  mode: relaxed\n"
+
+	ctx := context.Background()
+	e.drifted(ctx, cr("ConfigMap", "example", "settings"), "settings.yaml", diff)
+	e.drifted(ctx, cr("ConfigMap", "example", "limits"), "limits.yaml", "")
+	e.drifted(ctx, cr("Namespace", "", "example"), "namespace.yaml", diff)
+	e.drifted(ctx, cr("ConfigMap", "restricted", "settings"), "settings.yaml", diff)
+	require.Equal(t, &DriftEventsSummary{Emitted: 2, Failed: []string{"v1_ConfigMap_restricted_settings: forbidden"}}, e.summarize())
+
+	name := fmt.Sprintf("settings.%x", now.UnixNano())
+	event, err := client.Resource(eventGVR).Namespace("example").Get(ctx, name, metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, DriftEventReason, event.Object["reason"])
+	require.Equal(t, "Warning", event.Object["type"])
+	require.Equal(t, "Drifted from the reference template settings.yaml: 2 changed lines", event.Object["message"])
+	require.Equal(t, map[string]any{"apiVersion": "v1", "kind": "ConfigMap", "namespace": "example", "name": "settings", "uid": "1234", "resourceVersion": ""},
+		event.Object["involvedObject"])
+	require.Equal(t, "2024-05-06T07:00:00Z", event.Object["firstTimestamp"])
+
+	events, err := client.Resource(eventGVR).Namespace(clusterScopedEventNamespace).List(ctx, metav1.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, events.Items, 1, "the events of cluster scoped CRs should be created in the default namespace")
+	_, found, _ := unstructured.NestedString(events.Items[0].Object, "involvedObject", "namespace")
+	require.False(t, found)
+
+	var disabled *driftEventEmitter
+	disabled.drifted(ctx, cr("ConfigMap", "example", "settings"), "settings.yaml", diff)
+	require.Nil(t, disabled.summarize())
+}
@@ -0,0 +1,102 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/openshift/kube-compare/pkg/api"
+	"k8s.io/utils/exec"
+)
+
+// errDiffsFound is the error of the exit errors returned when the comparison found failures, the command exits with
+// the code of the exit error without printing it since the summary already reports the failures
+var errDiffsFound = errors.New(DiffsFoundMsg)
+
+// exitPolicy selects the failures of a comparison that fail the command and the exit code of each class of failures:
+// diffs (CRs with diffs, operator version drift, kinds that couldn't be fetched and templates that failed to render),
+// required CRs missing from the cluster (and templates with an unexpected number of CRs) and cluster CRs unmatched by
+// the reference. When failures of several classes are found the code of the first one in that order is used. CRs with
+// diffs only fail the command when their diffs are at least as severe as failOnSeverity, all of them when it's empty.
+type exitPolicy struct {
+	failOnMissing   bool
+	failOnUnmatched bool
+	failOnSeverity  string
+
+	diffsCode     int
+	missingCode   int
+	unmatchedCode int
+}
+
+func (p *exitPolicy) validate() error {
+	if p.failOnSeverity != "" && !slices.Contains(Severities, api.Severity(p.failOnSeverity)) {
+		return fmt.Errorf("invalid --fail-on-severity %q, must be one of: %s", p.failOnSeverity, severityNames())
+	}
+	codes := []struct {
+		flag string
+		code int
+	}{
+		{"exit-code-diffs", p.diffsCode},
+		{"exit-code-missing", p.missingCode},
+		{"exit-code-unmatched", p.unmatchedCode},
+	}
+	for _, c := range codes {
+		// 0 means success and 2 is the exit code of the errors of the command
+		if c.code < 1 || c.code == 2 || c.code > 125 {
+			return fmt.Errorf("--%s must be 1 or between 3 and 125, got %d", c.flag, c.code)
+		}
+	}
+	return nil
+}
+
+// exitCode returns the exit code of the failures found in the summaries, 0 if none of them fail the command
+func (p *exitPolicy) exitCode(summaries ...*Summary) int {
+	var missing, unmatched bool
+	for _, s := range summaries {
+		if p.failsOnDiffs(s) {
+			return p.diffsCode
+		}
+		missing = missing || len(s.ValidationIssues) != 0 || len(s.CountMismatches) != 0
+		unmatched = unmatched || len(s.UnmatchedCRS) != 0
+	}
+	switch {
+	case missing && p.failOnMissing:
+		return p.missingCode
+	case unmatched && p.failOnUnmatched:
+		return p.unmatchedCode
+	}
+	return 0
+}
+
+// failsOnDiffs checks if the differences other than the validation issues fail the command: the CRs with diffs of at
+// least the severity of the policy, the diffs of CRs are warnings when the reference doesn't set field severities
+func (p *exitPolicy) failsOnDiffs(s *Summary) bool {
+	if s.OperatorVersions.hasIssues() || len(s.UnavailableKinds) != 0 || len(s.RenderFailures) != 0 {
+		return true
+	}
+	severities := s.DiffSeverities
+	if severities == nil {
+		severities = &DiffSeveritiesSummary{Warning: s.NumDiffCRs}
+	}
+	return severities.atLeast(cmp.Or(api.Severity(p.failOnSeverity), api.SeverityInfo)) != 0
+}
+
+// exitError returns the exit error of the failures found in the summaries, nil if none of them fail the command
+func (p *exitPolicy) exitError(summaries ...*Summary) error {
+	if code := p.exitCode(summaries...); code != 0 {
+		return exec.CodeExitError{Err: errDiffsFound, Code: code}
+	}
+	return nil
+}
+
+// failuresExitCode returns the exit code of the failures found by the comparison, if the error reports them
+func failuresExitCode(err error) (int, bool) {
+	var exitErr exec.CodeExitError
+	if errors.As(err, &exitErr) && errors.Is(exitErr.Err, errDiffsFound) {
+		return exitErr.Code, true
+	}
+	return 0, false
+}
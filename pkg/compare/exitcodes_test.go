@@ -0,0 +1,64 @@
+package compare
+
+import (
+	"testing"
+
+	"github.com/openshift/kube-compare/pkg/api"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExitPolicyExitCode(t *testing.T) {
+	policy := exitPolicy{failOnMissing: true, diffsCode: 3, missingCode: 4, unmatchedCode: 5}
+	clean := &Summary{}
+	diffs := &Summary{NumDiffCRs: 1}
+	missing := &Summary{ValidationIssues: map[string]map[string]ValidationIssue{"part": {"component": {CRs: []string{"cm.yaml"}}}}}
+	unmatched := &Summary{UnmatchedCRS: []string{"v1_ConfigMap_example_extra"}}
+	drifted := &Summary{OperatorVersions: &OperatorVersionsSummary{NumDrifted: 1}}
+
+	require.Equal(t, 0, policy.exitCode(clean))
+	require.Equal(t, 3, policy.exitCode(diffs))
+	require.Equal(t, 3, policy.exitCode(drifted))
+	require.Equal(t, 4, policy.exitCode(missing))
+	require.Equal(t, 0, policy.exitCode(unmatched), "unmatched CRs only fail with failOnUnmatched")
+	require.Equal(t, 3, policy.exitCode(missing, unmatched, diffs), "diffs take precedence over the other failures")
+
+	policy.failOnUnmatched = true
+	require.Equal(t, 5, policy.exitCode(unmatched))
+	require.Equal(t, 4, policy.exitCode(unmatched, missing), "missing CRs take precedence over unmatched CRs")
+
+	policy.failOnMissing = false
+	require.Equal(t, 5, policy.exitCode(missing, unmatched))
+	require.Equal(t, 0, policy.exitCode(missing))
+
+	code, ok := failuresExitCode(policy.exitError(diffs))
+	require.True(t, ok)
+	require.Equal(t, 3, code)
+	require.NoError(t, policy.exitError(clean))
+}
+
+func TestExitPolicyFailOnSeverity(t *testing.T) {
+	policy := exitPolicy{failOnSeverity: string(api.SeverityCritical), diffsCode: 3, missingCode: 4, unmatchedCode: 5}
+	critical := &Summary{NumDiffCRs: 2, DiffSeverities: &DiffSeveritiesSummary{Critical: 1, Info: 1}}
+	info := &Summary{NumDiffCRs: 1, DiffSeverities: &DiffSeveritiesSummary{Info: 1}}
+	unclassified := &Summary{NumDiffCRs: 1}
+	drifted := &Summary{OperatorVersions: &OperatorVersionsSummary{NumDrifted: 1}}
+
+	require.Equal(t, 3, policy.exitCode(critical))
+	require.Equal(t, 0, policy.exitCode(info))
+	require.Equal(t, 0, policy.exitCode(unclassified), "the diffs of references without severities should be warnings")
+	require.Equal(t, 3, policy.exitCode(drifted), "the other diffs should fail regardless of the severity")
+
+	policy.failOnSeverity = string(api.SeverityWarning)
+	require.Equal(t, 0, policy.exitCode(info))
+	require.Equal(t, 3, policy.exitCode(unclassified))
+
+	policy.failOnSeverity = string(api.SeverityInfo)
+	require.Equal(t, 3, policy.exitCode(info))
+}
+
+func TestExitPolicyValidate(t *testing.T) {
+	require.NoError(t, (&exitPolicy{diffsCode: 1, missingCode: 3, unmatchedCode: 125}).validate())
+	require.ErrorContains(t, (&exitPolicy{diffsCode: 1, missingCode: 0, unmatchedCode: 1}).validate(), "--exit-code-missing")
+	require.ErrorContains(t, (&exitPolicy{diffsCode: 1, missingCode: 1, unmatchedCode: 126}).validate(), "--exit-code-unmatched")
+	require.ErrorContains(t, (&exitPolicy{failOnSeverity: "high", diffsCode: 1, missingCode: 1, unmatchedCode: 1}).validate(), "--fail-on-severity")
+}
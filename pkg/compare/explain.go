@@ -0,0 +1,243 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// matchExplainer is implemented by the correlators that can tell how they matched a cluster CR, or why they didn't,
+// beyond the templates they returned
+type matchExplainer interface {
+	explainMatch(object *unstructured.Unstructured) []string
+}
+
+// correlatorName returns how a correlator of the chain is referred to by --explain
+func correlatorName[T CorrelationEntry](c Correlator[T]) string {
+	switch any(c).(type) {
+	case *ExactMatchCorrelator[T]:
+		return "manual correlation"
+	case *PatternCorrelator[T]:
+		return "manual correlation patterns"
+	case *AnnotationCorrelator:
+		return "annotation correlation"
+	case *GroupCorrelator[T]:
+		return "field groups correlation"
+	}
+	return fmt.Sprintf("custom correlator %T", c)
+}
+
+// explain returns the decisions of the correlators of the chain for the cluster CR, in the order they were tried
+func (c MultiCorrelator[T]) explain(object *unstructured.Unstructured) []string {
+	var lines []string
+	for _, core := range c.correlators {
+		temps, err := core.Match(object)
+		unknown := errors.As(err, &UnknownMatch{})
+		switch {
+		case err == nil:
+			lines = append(lines, fmt.Sprintf("%s: matched %s", correlatorName(core), getTemplatesNames(temps)))
+		case unknown:
+			lines = append(lines, fmt.Sprintf("%s: no match", correlatorName(core)))
+		default:
+			lines = append(lines, fmt.Sprintf("%s: %s", correlatorName(core), err))
+		}
+		if e, ok := core.(matchExplainer); ok {
+			for _, detail := range e.explainMatch(object) {
+				lines = append(lines, "  "+detail)
+			}
+		}
+		if !unknown {
+			break
+		}
+	}
+	return lines
+}
+
+func (c ExactMatchCorrelator[T]) explainMatch(object *unstructured.Unstructured) []string {
+	if _, ok := c.apiKindNamespaceName[apiKindNamespaceName(object)]; ok {
+		return nil
+	}
+	return []string{fmt.Sprintf("no correlation pair for %s", apiKindNamespaceName(object))}
+}
+
+func (c PatternCorrelator[T]) explainMatch(object *unstructured.Unstructured) []string {
+	name := apiKindNamespaceName(object)
+	var lines []string
+	for _, p := range c.patterns {
+		if p.regexp.MatchString(name) {
+			lines = append(lines, fmt.Sprintf("%s matches pattern %s", name, p.key))
+		}
+	}
+	if len(lines) == 0 {
+		return []string{fmt.Sprintf("no pattern matches %s", name)}
+	}
+	return lines
+}
+
+func (c AnnotationCorrelator) explainMatch(object *unstructured.Unstructured) []string {
+	var lines []string
+	for _, key := range c.keys {
+		id, ok := object.GetAnnotations()[key]
+		switch temp, declared := c.templates[key][id]; {
+		case !ok:
+			lines = append(lines, fmt.Sprintf("not annotated with %s", key))
+		case !declared:
+			lines = append(lines, fmt.Sprintf("no template declares %s=%s", key, id))
+		case temp.GetMetadata().GroupVersionKind().GroupKind() != object.GroupVersionKind().GroupKind():
+			lines = append(lines, fmt.Sprintf("template %s declaring %s=%s is of another kind", temp.GetIdentifier(), key, id))
+		default:
+			lines = append(lines, fmt.Sprintf("annotated with %s=%s", key, id))
+		}
+	}
+	return lines
+}
+
+// explainMatch returns the groups of fields tried until the one matching the cluster CR
+func (c *GroupCorrelator[T]) explainMatch(object *unstructured.Unstructured) []string {
+	var lines []string
+	for _, fc := range c.fieldCorrelators {
+		fields := getFields(fc.Fields)
+		hash, err := fc.hashFunc(object, "")
+		if err != nil {
+			lines = append(lines, fmt.Sprintf("group %s: %s", fields, err))
+			continue
+		}
+		temps, ok := fc.objects[hash]
+		if !ok {
+			lines = append(lines, fmt.Sprintf("group %s: no template with the values %s", fields, hash))
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("group %s: matched %s", fields, getTemplatesNames(temps)))
+		break
+	}
+	return lines
+}
+
+// candidateExplanation is a template a cluster CR was correlated to and how it ranked
+type candidateExplanation struct {
+	template string
+	// score is the score of the weighted fields of the tie-breakers of the reference
+	score int
+	// differences is the number of fields of the CR differing from the template
+	differences int
+	// differenceScore is the number of differences weighted by the difference weights
+	differenceScore int
+	err             error
+	chosen          bool
+}
+
+func (c candidateExplanation) String() string {
+	if c.err != nil {
+		return fmt.Sprintf("%s: %s", c.template, c.err)
+	}
+	s := fmt.Sprintf("%s: %d differing fields", c.template, c.differences)
+	if c.differenceScore != c.differences {
+		s += fmt.Sprintf(" (weighted %d)", c.differenceScore)
+	}
+	s += fmt.Sprintf(", weighted fields score %d", c.score)
+	if c.chosen {
+		s += " (chosen)"
+	}
+	return s
+}
+
+// matchExplanation tells how a cluster CR was correlated and which of the templates it was correlated to it was
+// compared to
+type matchExplanation struct {
+	correlation []string
+	candidates  []candidateExplanation
+}
+
+// explanations collects the explanations of the matches of a run for --explain, CRs are compared concurrently
+type explanations struct {
+	mu   sync.Mutex
+	byCR map[string]*matchExplanation
+}
+
+func newExplanations() *explanations {
+	return &explanations{byCR: make(map[string]*matchExplanation)}
+}
+
+func (e *explanations) get(cr *unstructured.Unstructured) *matchExplanation {
+	name := apiKindNamespaceName(cr)
+	x, ok := e.byCR[name]
+	if !ok {
+		x = &matchExplanation{}
+		e.byCR[name] = x
+	}
+	return x
+}
+
+// correlated records the decisions of the correlators for the cluster CR
+func (e *explanations) correlated(cr *unstructured.Unstructured, lines []string) {
+	if e == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	x := e.get(cr)
+	x.correlation = append(x.correlation, lines...)
+}
+
+// ranked records the templates the cluster CR was compared to, the templates that failed to render and the chosen one
+func (e *explanations) ranked(cr *unstructured.Unstructured, matches []*diffResult, failed map[string]error, best *diffResult, tieBreakers *MatchTieBreakers) {
+	if e == nil {
+		return
+	}
+	candidates := make([]candidateExplanation, 0, len(matches)+len(failed))
+	for _, m := range matches {
+		candidates = append(candidates, candidateExplanation{
+			template:        m.temp.GetIdentifier(),
+			score:           tieBreakers.score(m.rendered, cr),
+			differences:     m.leafCount,
+			differenceScore: m.differenceScore,
+			chosen:          m == best,
+		})
+	}
+	for temp, err := range failed {
+		candidates = append(candidates, candidateExplanation{template: temp, err: err})
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].template < candidates[j].template })
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.get(cr).candidates = candidates
+}
+
+// write writes the explanations sorted by cluster CR
+func (e *explanations) write(w io.Writer) error {
+	if e == nil {
+		return nil
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	names := make([]string, 0, len(e.byCR))
+	for name := range e.byCR {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var sb strings.Builder
+	for _, name := range names {
+		x := e.byCR[name]
+		fmt.Fprintf(&sb, "Explanation for %s:\n  Correlation:\n", name)
+		for _, line := range x.correlation {
+			fmt.Fprintf(&sb, "    %s\n", line)
+		}
+		if len(x.candidates) > 0 {
+			sb.WriteString("  Candidates:\n")
+			for _, c := range x.candidates {
+				fmt.Fprintf(&sb, "    %s\n", c)
+			}
+		}
+	}
+	if _, err := io.WriteString(w, sb.String()); err != nil {
+		return fmt.Errorf("error occurred when writing explanations: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestExplanations(t *testing.T) {
+	newObject := func(kind, namespace, name string) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{Object: map[string]any{}}
+		obj.SetAPIVersion("v1")
+		obj.SetKind(kind)
+		obj.SetNamespace(namespace)
+		obj.SetName(name)
+		return obj
+	}
+	newTemplate := func(path string, metadata *unstructured.Unstructured) ReferenceTemplate {
+		return ReferenceTemplateV1{Path: path, metadata: metadata}
+	}
+	templates := []ReferenceTemplate{
+		newTemplate("a.yaml", newObject("ConfigMap", "ns", "a")),
+		newTemplate("b.yaml", newObject("ConfigMap", "", "")),
+		newTemplate("c.yaml", newObject("ConfigMap", "", "")),
+	}
+	manual, err := NewExactMatchCorrelator(map[string]string{"v1_ConfigMap_ns_manual": "c.yaml"}, templates)
+	require.NoError(t, err)
+	group, err := NewGroupCorrelator(defaultFieldGroups, templates)
+	require.NoError(t, err)
+	correlator := NewMultiCorrelator([]Correlator[ReferenceTemplate]{manual, group})
+
+	t.Run("correlation", func(t *testing.T) {
+		require.Equal(t, []string{
+			"manual correlation: no match",
+			"  no correlation pair for v1_ConfigMap_ns_a",
+			"field groups correlation: matched a.yaml",
+			"  group apiVersion, metadata_name, metadata_namespace, kind: matched a.yaml",
+		}, correlator.explain(newObject("ConfigMap", "ns", "a")))
+		require.Equal(t, []string{
+			"manual correlation: matched c.yaml",
+		}, correlator.explain(newObject("ConfigMap", "ns", "manual")))
+		require.Equal(t, []string{
+			"manual correlation: no match",
+			"  no correlation pair for v1_ConfigMap_other",
+			"field groups correlation: matched b.yaml, c.yaml",
+			"  group apiVersion, metadata_name, metadata_namespace, kind: the field metadata_namespace doesn't exist in resource",
+			"  group apiVersion, kind: matched b.yaml, c.yaml",
+		}, correlator.explain(newObject("ConfigMap", "", "other")))
+	})
+
+	t.Run("candidates", func(t *testing.T) {
+		cr := newObject("ConfigMap", "", "other")
+		e := newExplanations()
+		e.correlated(cr, correlator.explain(cr))
+		b := &diffResult{temp: templates[1], leafCount: 3, differenceScore: 3}
+		c := &diffResult{temp: templates[2], leafCount: 1, differenceScore: 1}
+		e.ranked(cr, []*diffResult{b, c}, map[string]error{"d.yaml": errors.New("failed to render")}, c, nil)
+		var out bytes.Buffer
+		require.NoError(t, e.write(&out))
+		require.Equal(t, `Explanation for v1_ConfigMap_other:
+  Correlation:
+    manual correlation: no match
+      no correlation pair for v1_ConfigMap_other
+    field groups correlation: matched b.yaml, c.yaml
+      group apiVersion, metadata_name, metadata_namespace, kind: the field metadata_namespace doesn't exist in resource
+      group apiVersion, kind: matched b.yaml, c.yaml
+  Candidates:
+    b.yaml: 3 differing fields, weighted fields score 0
+    c.yaml: 1 differing fields, weighted fields score 0 (chosen)
+    d.yaml: failed to render
+`, out.String())
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		var e *explanations
+		e.correlated(newObject("ConfigMap", "", "other"), nil)
+		var out bytes.Buffer
+		require.NoError(t, e.write(&out))
+		require.Empty(t, out.String())
+	})
+}
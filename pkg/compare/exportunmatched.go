@@ -0,0 +1,46 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+// prepareExportDir creates the directory the unmatched CRs will be exported to, the directory is required to be empty
+// so it will contain only the CRs of a single run.
+func prepareExportDir(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err == nil && len(entries) > 0 {
+		return fmt.Errorf("export directory %s isn't empty", dir)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil { // nolint:gosec
+		return fmt.Errorf("failed to create export directory: %w", err)
+	}
+	return nil
+}
+
+// exportUnmatched writes the cluster CRs that weren't matched to any template to the directory, ready to be turned
+// into templates: the fields populated by the API server are removed and template delimiters are escaped the same way
+// as by the generate command.
+func exportUnmatched(dir string, crs []*unstructured.Unstructured) error {
+	files := make(map[string][]byte)
+	for _, cr := range crs {
+		content, err := yaml.Marshal(withoutServerPopulatedFields(cr).Object)
+		if err != nil {
+			return fmt.Errorf("failed to marshal %s for the export: %w", apiKindNamespaceName(cr), err)
+		}
+		files[templateFileName(cr, files)] = []byte(templateDelimiterEscaper.Replace(string(content)))
+	}
+	// Cluster CRs can hold secrets, the exported files are only readable by their owner
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), content, 0o600); err != nil {
+			return fmt.Errorf("failed to write exported CR: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestExportUnmatched(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "unmatched")
+	require.NoError(t, prepareExportDir(dir))
+
+	crs := []*unstructured.Unstructured{
+		{Object: map[string]any{"apiVersion": "v1", "kind": "ConfigMap",
+			"metadata": map[string]any{"name": "cm", "namespace": "ns", "uid": "1234", "resourceVersion": "5"},
+			"data":     map[string]any{"template": "{{ .Values.key }}"}}},
+		{Object: map[string]any{"apiVersion": "apps/v1", "kind": "Deployment",
+			"metadata": map[string]any{"name": "web", "namespace": "ns"},
+			"spec":     map[string]any{"replicas": int64(2)},
+			"status":   map[string]any{"readyReplicas": int64(2)}}},
+	}
+	require.NoError(t, exportUnmatched(dir, crs))
+	require.Error(t, prepareExportDir(dir), "unmatched CRs shouldn't be exported to a directory that isn't empty")
+
+	cm, err := os.ReadFile(filepath.Join(dir, "ConfigMap_ns_cm.yaml"))
+	require.NoError(t, err)
+	require.Equal(t, `apiVersion: v1
+data:
+  template: '{{ "{{" }} .Values.key }}'
+kind: ConfigMap
+metadata:
+  name: cm
+  namespace: ns
+`, string(cm))
+
+	deployment, err := os.ReadFile(filepath.Join(dir, "Deployment_ns_web.yaml"))
+	require.NoError(t, err)
+	require.Equal(t, `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: ns
+spec:
+  replicas: 2
+`, string(deployment))
+	require.Contains(t, crs[1].Object, "status", "the exported CRs shouldn't be modified")
+
+	info, err := os.Stat(filepath.Join(dir, "ConfigMap_ns_cm.yaml"))
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+}
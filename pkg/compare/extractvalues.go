@@ -0,0 +1,84 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+const extractedValuesHeader = `# Values of the templated fields of the cluster CRs, extracted by --extract-values. The values are listed by
+# template, cluster CR and field. Fields of list items and fields the cluster CRs don't set aren't extracted.
+`
+
+// ExtractedValues are the values the cluster CRs supplied for the templated fields of the templates they matched, by
+// template path, cluster CR and path of the field
+type ExtractedValues struct {
+	Templates map[string]map[string]map[string]any `json:"templates"`
+}
+
+// valueExtractor records the values of the templated fields of the matched cluster CRs with --extract-values
+type valueExtractor struct {
+	// fields are the paths of the templated fields of every template, by template path
+	fields map[string][][]string
+
+	mu     sync.Mutex
+	values ExtractedValues
+}
+
+// newValueExtractor finds the templated fields of the templates in their source. Fields are templated when their value
+// is set by a template action, whether the action makes the whole value or only a part of it.
+func newValueExtractor(fsys fs.FS, templates []ReferenceTemplate) (*valueExtractor, error) {
+	e := &valueExtractor{fields: make(map[string][][]string), values: ExtractedValues{Templates: make(map[string]map[string]map[string]any)}}
+	for _, temp := range templates {
+		content, err := fs.ReadFile(fsys, temp.GetPath())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read template %s to extract its values: %w", temp.GetPath(), err)
+		}
+		e.fields[temp.GetPath()] = templatedFields(string(content))
+	}
+	return e, nil
+}
+
+// record extracts the values of the templated fields of the template from the cluster CR matched to it. Fields in
+// lists can't be told apart from the template source and aren't extracted.
+func (e *valueExtractor) record(temp ReferenceTemplate, clusterCR *unstructured.Unstructured) {
+	if e == nil {
+		return
+	}
+	values := make(map[string]any)
+	for _, field := range e.fields[temp.GetPath()] {
+		value, found, err := unstructured.NestedFieldCopy(clusterCR.Object, field...)
+		if err != nil || !found {
+			continue
+		}
+		values[strings.Join(field, ".")] = value
+	}
+	if len(values) == 0 {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.values.Templates[temp.GetPath()] == nil {
+		e.values.Templates[temp.GetPath()] = make(map[string]map[string]any)
+	}
+	e.values.Templates[temp.GetPath()][apiKindNamespaceName(clusterCR)] = values
+}
+
+// write writes the extracted values to the file as YAML
+func (e *valueExtractor) write(path string) error {
+	content, err := yaml.Marshal(e.values)
+	if err != nil {
+		return fmt.Errorf("failed to marshal the extracted values: %w", err)
+	}
+	if err := os.WriteFile(path, append([]byte(extractedValuesHeader), content...), 0o644); err != nil { // nolint:gosec
+		return fmt.Errorf("failed to write the extracted values: %w", err)
+	}
+	return nil
+}
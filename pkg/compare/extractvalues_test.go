@@ -0,0 +1,61 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestValueExtractor(t *testing.T) {
+	fsys := fstest.MapFS{"deployment.yaml": {Data: []byte(`apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+  namespace: {{ .metadata.namespace }}
+  labels: {{ .metadata.labels | toYaml | nindent 4 }}
+spec:
+  replicas: {{ .spec.replicas }}
+  template:
+    spec:
+      containers:
+        - name: app
+          image: registry.example.com/app:{{ .spec.template.spec.containers | len }}
+`)}}
+	temp := ReferenceTemplateV1{Path: "deployment.yaml"}
+	e, err := newValueExtractor(fsys, []ReferenceTemplate{temp})
+	require.NoError(t, err)
+
+	cr := &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata":   map[string]any{"name": "app", "namespace": "site-a", "labels": map[string]any{"site": "a"}},
+		"spec": map[string]any{
+			"replicas": int64(3),
+			"template": map[string]any{"spec": map[string]any{"containers": []any{map[string]any{"name": "app", "image": "registry.example.com/app:1"}}}},
+		},
+	}}
+	e.record(temp, cr)
+	cr.Object["metadata"].(map[string]any)["labels"].(map[string]any)["site"] = "b"
+	e.record(temp, &unstructured.Unstructured{Object: map[string]any{"apiVersion": "apps/v1", "kind": "Deployment", "metadata": map[string]any{"name": "other"}}})
+	var nilExtractor *valueExtractor
+	nilExtractor.record(temp, cr)
+
+	path := filepath.Join(t.TempDir(), "values.yaml")
+	require.NoError(t, e.write(path))
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, extractedValuesHeader+`templates:
+  deployment.yaml:
+    apps/v1_Deployment_site-a_app:
+      metadata.labels:
+        site: a
+      metadata.namespace: site-a
+      spec.replicas: 3
+`, string(content), "fields of list items and CRs without templated fields shouldn't be extracted")
+}
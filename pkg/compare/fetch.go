@@ -0,0 +1,112 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"strings"
+	"sync"
+
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/cli-runtime/pkg/resource"
+)
+
+// defaultFetchConcurrency is the number of resource types listed from the cluster at the same time
+const defaultFetchConcurrency = 4
+
+// fetchStages splits the results into the stages they're visited in, one after the other. The types of the templates
+// other templates depend on are listed and compared in an earlier stage than the types of their dependents, the
+// results of a stage are visited concurrently. Results are already ordered by the stages of their types.
+func (o *Options) fetchStages(results []typeResult) [][]typeResult {
+	if !hasDependencies(o.templates) {
+		return [][]typeResult{results}
+	}
+	stageOf := typeStages(o.templates)
+	var stages [][]typeResult
+	for i, r := range results {
+		if i == 0 || stageOf(r.resourceType) != stageOf(results[i-1].resourceType) {
+			stages = append(stages, nil)
+		}
+		stages[len(stages)-1] = append(stages[len(stages)-1], r)
+	}
+	return stages
+}
+
+// visitResults visits all the results and aggregates their errors. In live mode up to --fetch-concurrency types are
+// listed at the same time, every type is listed in pages of --chunk-size objects by its own LIST calls sharing the
+// discovery and REST mapper caches of the factory. Resources listed with several field selectors of their type are
+// only visited once.
+func (o *Options) visitResults(ctx context.Context, results []typeResult, fn resource.VisitorFunc) error {
+	var lock sync.Mutex
+	selected := make(map[string]bool)
+	visitSelected := func(info *resource.Info, err error) error {
+		if err == nil {
+			key := strings.Join([]string{info.Mapping.Resource.String(), info.Namespace, info.Name}, "/")
+			lock.Lock()
+			visited := selected[key]
+			selected[key] = true
+			lock.Unlock()
+			if visited {
+				return nil
+			}
+		}
+		return fn(info, err)
+	}
+	var errs []error
+	stages := o.fetchStages(results)
+	for s, stage := range stages {
+		// The errors are aggregated in the order of the results whatever the order the types were listed in
+		stageErrs := make([]error, len(stage))
+		sem := make(chan struct{}, max(o.fetchConcurrency, 1))
+		var wg sync.WaitGroup
+		stopped := false
+		for i, r := range stage {
+			sem <- struct{}{}
+			if ctx.Err() != nil {
+				stopped = true
+				break
+			}
+			visit := fn
+			if r.fieldSelector != "" {
+				visit = visitSelected
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer func() { <-sem }()
+				stageErrs[i] = o.visitWithRetries(ctx, r, visit)
+			}()
+		}
+		wg.Wait()
+		for _, err := range stageErrs {
+			if err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if stopped || (ctx.Err() != nil && s < len(stages)-1) {
+			// The remaining types aren't listed once the comparison is stopped
+			errs = append(errs, context.Cause(ctx))
+			break
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// orderByTypes sorts the diffs of the CRs listed from the cluster in the order of their types, as if the types were
+// listed one after the other, so the output doesn't depend on the order the types were listed in. The CRs of a type
+// are sorted by name, the order the API server lists them in.
+func orderByTypes(diffs []DiffSum, types []string) []DiffSum {
+	index := make(map[string]int, len(types))
+	for i, t := range types {
+		kind, _, _ := strings.Cut(t, ".")
+		if _, ok := index[kind]; !ok {
+			index[kind] = i
+		}
+	}
+	slices.SortStableFunc(diffs, func(a, b DiffSum) int {
+		return cmp.Or(index[a.kind]-index[b.kind], strings.Compare(a.CRName, b.CRName))
+	})
+	return diffs
+}
@@ -0,0 +1,55 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestFetchStages(t *testing.T) {
+	newTemplate := func(path, kind string, dependsOn ...string) ReferenceTemplate {
+		metadata := &unstructured.Unstructured{Object: map[string]any{}}
+		metadata.SetAPIVersion("v1")
+		metadata.SetKind(kind)
+		return ReferenceTemplateV2{
+			Config:              ReferenceTemplateConfigV2{DependsOn: dependsOn},
+			ReferenceTemplateV1: ReferenceTemplateV1{Path: path, metadata: metadata},
+		}
+	}
+	results := []typeResult{
+		{resourceType: "Namespace"},
+		{resourceType: "Secret"},
+		{resourceType: "ConfigMap", namespace: "a"},
+		{resourceType: "ConfigMap", namespace: "b"},
+		{resourceType: "Deployment.v1.apps"},
+	}
+	o := &Options{templates: []ReferenceTemplate{
+		newTemplate("namespace.yaml", "Namespace"),
+		newTemplate("secret.yaml", "Secret"),
+		newTemplate("settings.yaml", "ConfigMap", "namespace.yaml"),
+		newTemplate("deployment.yaml", "Deployment", "settings.yaml"),
+	}}
+	require.Equal(t, [][]typeResult{results[:2], results[2:4], results[4:]}, o.fetchStages(results))
+
+	o.templates = o.templates[:2]
+	require.Equal(t, [][]typeResult{results}, o.fetchStages(results), "types should be listed in a single stage without dependencies")
+}
+
+func TestOrderByTypes(t *testing.T) {
+	diffs := []DiffSum{
+		{CRName: "v1_Secret_b_tls", kind: "Secret"},
+		{CRName: "apps/v1_Deployment_a_app", kind: "Deployment"},
+		{CRName: "v1_ConfigMap_b_settings", kind: "ConfigMap"},
+		{CRName: "v1_Secret_a_tls", kind: "Secret"},
+		{CRName: "v1_ConfigMap_a_settings", kind: "ConfigMap"},
+	}
+	var names []string
+	for _, d := range orderByTypes(diffs, []string{"ConfigMap", "Secret", "Deployment.v1.apps"}) {
+		names = append(names, d.CRName)
+	}
+	require.Equal(t, []string{"v1_ConfigMap_a_settings", "v1_ConfigMap_b_settings", "v1_Secret_a_tls", "v1_Secret_b_tls",
+		"apps/v1_Deployment_a_app"}, names)
+}
@@ -0,0 +1,221 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// identityFields are always compared when limiting the diffs to the fields owned by some field managers, they aren't
+// tracked in managed fields
+var identityFields = [][]string{
+	{"apiVersion"},
+	{"kind"},
+	{"metadata", "name"},
+	{"metadata", "namespace"},
+}
+
+// fieldOwnerFilter limits the diffs to the fields of the cluster CRs owned by some field managers, or excludes the
+// fields owned only by some field managers, based on the managed fields of the cluster CRs
+type fieldOwnerFilter struct {
+	only   []string
+	ignore []string
+}
+
+func (f fieldOwnerFilter) isSet() bool {
+	return len(f.only) > 0 || len(f.ignore) > 0
+}
+
+// ownership returns the ownership of the fields of the cluster CR by the managers of the filter, nil if the filter
+// isn't set
+func (f fieldOwnerFilter) ownership(cr *unstructured.Unstructured) (*fieldOwnership, error) {
+	if !f.isSet() {
+		return nil, nil
+	}
+	o := &fieldOwnership{keep: len(f.only) > 0, owned: fieldSet{}, others: fieldSet{}}
+	for _, entry := range cr.GetManagedFields() {
+		if entry.FieldsV1 == nil {
+			continue
+		}
+		var fields map[string]any
+		if err := json.Unmarshal(entry.FieldsV1.Raw, &fields); err != nil {
+			return nil, fmt.Errorf("failed to parse the fields managed by %s in %s: %w", entry.Manager, apiKindNamespaceName(cr), err)
+		}
+		if slices.Contains(f.only, entry.Manager) || slices.Contains(f.ignore, entry.Manager) {
+			o.owned.add(fields)
+		} else {
+			o.others.add(fields)
+		}
+	}
+	return o, nil
+}
+
+// fieldSet is a set of fields in the fieldsV1 format of managed fields: map fields are keyed by "f:<name>", list
+// items by "k:<keys>", "v:<value>" or "i:<index>" and "." marks the field itself
+type fieldSet map[string]fieldSet
+
+// add adds the fields in the fieldsV1 format to the set
+func (s fieldSet) add(fields map[string]any) {
+	for k, v := range fields {
+		if s[k] == nil {
+			s[k] = fieldSet{}
+		}
+		if children, ok := v.(map[string]any); ok {
+			s[k].add(children)
+		}
+	}
+}
+
+// isLeaf checks if the whole value of the field is in the set, and not only some of its children
+func (s fieldSet) isLeaf() bool {
+	for k := range s {
+		if k != "." {
+			return false
+		}
+	}
+	return true
+}
+
+// item returns the set of the list item at the index
+func (s fieldSet) item(index int, item any) (fieldSet, bool) {
+	for k, children := range s {
+		switch {
+		case strings.HasPrefix(k, "k:"):
+			var keys map[string]any
+			if err := json.Unmarshal([]byte(k[2:]), &keys); err != nil {
+				continue
+			}
+			fields, ok := item.(map[string]any)
+			if ok && matchesKeys(fields, keys) {
+				return children, true
+			}
+		case strings.HasPrefix(k, "v:"):
+			if k[2:] == formatFieldValue(item) {
+				return children, true
+			}
+		case strings.HasPrefix(k, "i:"):
+			if k[2:] == strconv.Itoa(index) {
+				return children, true
+			}
+		}
+	}
+	return nil, false
+}
+
+func matchesKeys(fields, keys map[string]any) bool {
+	for k, v := range keys {
+		if formatFieldValue(fields[k]) != formatFieldValue(v) {
+			return false
+		}
+	}
+	return true
+}
+
+// fieldOwnership is the ownership of the fields of a cluster CR
+type fieldOwnership struct {
+	// keep is true if only the owned fields are compared, otherwise the fields owned only by the owners are excluded
+	keep bool
+	// owned are the fields owned by the managers of the filter
+	owned fieldSet
+	// others are the fields owned by the rest of the managers
+	others fieldSet
+}
+
+// apply removes the fields that shouldn't be compared from the object, the managed fields the ownership was read
+// from are removed as well
+func (o *fieldOwnership) apply(obj map[string]any) {
+	if o == nil {
+		return
+	}
+	unstructured.RemoveNestedField(obj, "metadata", "managedFields")
+	if !o.keep {
+		removeOwnedFields(obj, o.owned, o.others)
+		return
+	}
+	identity := make(map[string]any)
+	for _, field := range identityFields {
+		if v, ok, _ := unstructured.NestedFieldNoCopy(obj, field...); ok {
+			identity[strings.Join(field, ".")] = v
+		}
+	}
+	keepOwnedFields(obj, o.owned)
+	for _, field := range identityFields {
+		if v, ok := identity[strings.Join(field, ".")]; ok {
+			_ = unstructured.SetNestedField(obj, v, field...)
+		}
+	}
+}
+
+// keepOwnedFields removes the fields of the value that aren't in the set
+func keepOwnedFields(value any, owned fieldSet) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for k, child := range v {
+			children, ok := owned["f:"+k]
+			switch {
+			case !ok:
+				delete(v, k)
+			case !children.isLeaf():
+				v[k] = keepOwnedFields(child, children)
+			}
+		}
+		return v
+	case []any:
+		kept := make([]any, 0, len(v))
+		for i, item := range v {
+			children, ok := owned.item(i, item)
+			if !ok {
+				continue
+			}
+			if !children.isLeaf() {
+				item = keepOwnedFields(item, children)
+			}
+			kept = append(kept, item)
+		}
+		return kept
+	}
+	return value
+}
+
+// removeOwnedFields removes the fields of the value that are in the owned set but not in the others set
+func removeOwnedFields(value any, owned, others fieldSet) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for k, child := range v {
+			children, ok := owned["f:"+k]
+			if !ok {
+				continue
+			}
+			otherChildren, ownedByOthers := others["f:"+k]
+			switch {
+			case !children.isLeaf():
+				v[k] = removeOwnedFields(child, children, otherChildren)
+			case !ownedByOthers:
+				delete(v, k)
+			}
+		}
+		return v
+	case []any:
+		kept := make([]any, 0, len(v))
+		for i, item := range v {
+			children, ok := owned.item(i, item)
+			otherChildren, ownedByOthers := others.item(i, item)
+			switch {
+			case !ok:
+			case !children.isLeaf():
+				item = removeOwnedFields(item, children, otherChildren)
+			case !ownedByOthers:
+				continue
+			}
+			kept = append(kept, item)
+		}
+		return kept
+	}
+	return value
+}
@@ -0,0 +1,78 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+const ownedDeployment = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: example
+spec:
+  replicas: 3
+  template:
+    spec:
+      containers:
+        - name: app
+          image: app:v1
+          args: ["--verbose", "--injected"]
+        - name: sidecar
+          image: sidecar:v1
+`
+
+func ownedDeploymentCR(t *testing.T) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	require.NoError(t, yaml.Unmarshal([]byte(ownedDeployment), &obj.Object))
+	obj.SetManagedFields([]metav1.ManagedFieldsEntry{
+		{Manager: "kubectl", FieldsV1: &metav1.FieldsV1{Raw: []byte(`{"f:spec":{"f:template":{"f:spec":{"f:containers":{` +
+			`"k:{\"name\":\"app\"}":{".":{},"f:name":{},"f:image":{},"f:args":{"v:\"--verbose\"":{}}}}}}}}`)}},
+		{Manager: "injector", FieldsV1: &metav1.FieldsV1{Raw: []byte(`{"f:spec":{"f:replicas":{},"f:template":{"f:spec":{"f:containers":{` +
+			`"k:{\"name\":\"app\"}":{"f:image":{},"f:args":{"v:\"--injected\"":{}}},"k:{\"name\":\"sidecar\"}":{}}}}}}`)}},
+	})
+	return obj
+}
+
+func TestFieldOwnershipKeepsOwnedFields(t *testing.T) {
+	cr := ownedDeploymentCR(t)
+	ownership, err := fieldOwnerFilter{only: []string{"kubectl"}}.ownership(cr)
+	require.NoError(t, err)
+	ownership.apply(cr.Object)
+	require.Equal(t, map[string]any{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata":   map[string]any{"name": "web", "namespace": "example"},
+		"spec": map[string]any{"template": map[string]any{"spec": map[string]any{"containers": []any{
+			map[string]any{"name": "app", "image": "app:v1", "args": []any{"--verbose"}},
+		}}}},
+	}, cr.Object)
+}
+
+func TestFieldOwnershipIgnoresFieldsOwnedOnlyByManager(t *testing.T) {
+	cr := ownedDeploymentCR(t)
+	ownership, err := fieldOwnerFilter{ignore: []string{"injector"}}.ownership(cr)
+	require.NoError(t, err)
+	ownership.apply(cr.Object)
+	// The image is owned by both managers so it's still compared
+	require.Equal(t, map[string]any{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata":   map[string]any{"name": "web", "namespace": "example"},
+		"spec": map[string]any{"template": map[string]any{"spec": map[string]any{"containers": []any{
+			map[string]any{"name": "app", "image": "app:v1", "args": []any{"--verbose"}},
+		}}}},
+	}, cr.Object)
+}
+
+func TestFieldOwnershipNotSet(t *testing.T) {
+	ownership, err := fieldOwnerFilter{}.ownership(ownedDeploymentCR(t))
+	require.NoError(t, err)
+	require.Nil(t, ownership)
+}
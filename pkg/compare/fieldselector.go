@@ -0,0 +1,71 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/fields"
+)
+
+// fieldSelectors are the field selectors the resources of each kind are listed with in live mode, instead of listing
+// all the objects of the kind cluster-wide
+type fieldSelectors struct {
+	// flags are the selectors of --field-selector in the form of <kind>:<selector>
+	flags  []string
+	byKind map[string][]string
+}
+
+// process parses the selectors of --field-selector, kinds are compared case-insensitively
+func (s *fieldSelectors) process() error {
+	s.byKind = make(map[string][]string)
+	for _, flag := range s.flags {
+		kind, selector, ok := strings.Cut(flag, ":")
+		if !ok || kind == "" || selector == "" {
+			return fmt.Errorf("invalid --field-selector %q, must be in the form of <kind>:<selector>", flag)
+		}
+		if _, err := fields.ParseSelector(selector); err != nil {
+			return fmt.Errorf("invalid --field-selector %q: %w", flag, err)
+		}
+		kind = strings.ToLower(kind)
+		if !slices.Contains(s.byKind[kind], selector) {
+			s.byKind[kind] = append(s.byKind[kind], selector)
+		}
+	}
+	return nil
+}
+
+// forType returns the field selectors to list the resources of a type with, one list is made for every selector.
+// The selectors of --field-selector are used if the kind has any, otherwise the selectors of the templates of the kind
+// are used when all of them declare one. A single empty selector is returned when all the objects of the type have
+// to be listed, e.g. when a template of the kind has no selector, or when all the resources are compared.
+func (s fieldSelectors) forType(resourceType string, templates []ReferenceTemplate, allResources bool) []string {
+	// Types are in the form of {kind} or {kind}.{version}.{group}
+	kind, _, _ := strings.Cut(resourceType, ".")
+	if selectors, ok := s.byKind[strings.ToLower(kind)]; ok {
+		return selectors
+	}
+	if allResources {
+		return []string{""}
+	}
+	var selectors []string
+	for _, t := range templates {
+		if t.GetMetadata().GetKind() != kind {
+			continue
+		}
+		selector := t.GetConfig().GetFieldSelector()
+		if selector == "" {
+			return []string{""}
+		}
+		if !slices.Contains(selectors, selector) {
+			selectors = append(selectors, selector)
+		}
+	}
+	if len(selectors) == 0 {
+		return []string{""}
+	}
+	slices.Sort(selectors)
+	return selectors
+}
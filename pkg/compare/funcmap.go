@@ -42,6 +42,17 @@ func FuncMap() template.FuncMap {
 		"toJson":        toJSON,
 		"fromJson":      fromJSON,
 		"fromJsonArray": fromJSONArray,
+		"include":       includePlaceholder,
+		"warn":          warn,
+		"lookupCR":      lookupPlaceholder,
+
+		"cidrContains":      cidrContains,
+		"cidrOverlaps":      cidrOverlaps,
+		"normalizeIP":       normalizeIP,
+		"normalizeCIDR":     normalizeCIDR,
+		"parseCertificate":  parseCertificate,
+		"parseCertificates": parseCertificates,
+		"parseDuration":     parseDuration,
 	}
 
 	for k, v := range extra {
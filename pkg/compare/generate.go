@@ -0,0 +1,246 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+	"k8s.io/cli-runtime/pkg/resource"
+	kcmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"k8s.io/kubectl/pkg/util/i18n"
+	"k8s.io/kubectl/pkg/util/templates"
+	"sigs.k8s.io/yaml"
+)
+
+var (
+	generateLong = templates.LongDesc(`
+		Generate a starter reference configuration from the resources of a live cluster.
+
+		The generate command fetches the resources of the given kinds from the cluster, removes the fields populated
+		by the API server (status, managed fields, uid, resource version...) and writes a template for every resource
+		and a v2 reference config (metadata.yaml) listing them. Every kind is a component requiring all of its
+		templates, the generated reference is meant as a starting point: templates should be reviewed and generalized
+		(e.g. by replacing values expected to differ between clusters with template expressions) before being used.
+	`)
+
+	generateExample = templates.Examples(`
+		# Generate a reference from the config maps and deployments of a namespace:
+		kubectl cluster-compare generate -n my-namespace --kinds ConfigMap,Deployment -o ./reference
+
+		# Compare the cluster to the generated reference:
+		kubectl cluster-compare -r ./reference/metadata.yaml
+	`)
+)
+
+const (
+	generatedReferenceFileName = "metadata.yaml"
+	generatedPartName          = "generated"
+)
+
+// serverPopulatedFields are removed from the resources before writing them as templates
+var serverPopulatedFields = [][]string{
+	{"status"},
+	{"metadata", "managedFields"},
+	{"metadata", "uid"},
+	{"metadata", "resourceVersion"},
+	{"metadata", "generation"},
+	{"metadata", "creationTimestamp"},
+	{"metadata", "selfLink"},
+	{"metadata", "annotations", "kubectl.kubernetes.io/last-applied-configuration"},
+}
+
+// templateDelimiterEscaper escapes the template delimiters in the content of resources, so the templates render the
+// content as is
+var templateDelimiterEscaper = strings.NewReplacer("{{", `{{ "{{" }}`)
+
+var unsafeFileNameChars = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+type GenerateOptions struct {
+	namespace string
+	kinds     []string
+	outputDir string
+
+	newBuilder func() *resource.Builder
+	genericiooptions.IOStreams
+}
+
+func NewGenerateCmd(f kcmdutil.Factory, streams genericiooptions.IOStreams) *cobra.Command {
+	options := &GenerateOptions{IOStreams: streams}
+
+	cmd := &cobra.Command{
+		Use:                   "generate --kinds <Kinds> -o <Output Directory>",
+		DisableFlagsInUseLine: true,
+		Short:                 i18n.T("Generate a starter reference configuration from a live cluster."),
+		Long:                  generateLong,
+		Example:               exampleForBinary(generateExample),
+		Run: func(cmd *cobra.Command, args []string) {
+			kcmdutil.CheckDiffErr(options.Complete(f, cmd, args))
+			kcmdutil.CheckDiffErr(options.Run())
+		},
+	}
+	cmd.SetFlagErrorFunc(func(command *cobra.Command, err error) error {
+		kcmdutil.CheckDiffErr(kcmdutil.UsageErrorf(cmd, err.Error()))
+		return nil
+	})
+	cmd.Flags().StringVarP(&options.namespace, "namespace", "n", "",
+		"Only fetch namespaced resources from this namespace, resources are fetched from all namespaces if not set")
+	cmd.Flags().StringSliceVar(&options.kinds, "kinds", []string{}, "Kinds of the resources to generate templates for, can be repeated")
+	cmd.Flags().StringVarP(&options.outputDir, "output", "o", "", "Path of the directory to write the reference to, must not exist or be empty")
+	return cmd
+}
+
+func (o *GenerateOptions) Complete(f kcmdutil.Factory, cmd *cobra.Command, args []string) error {
+	if len(args) != 0 {
+		return kcmdutil.UsageErrorf(cmd, "Unexpected args: %v", args)
+	}
+	if len(o.kinds) == 0 {
+		return kcmdutil.UsageErrorf(cmd, "--kinds is required")
+	}
+	if o.outputDir == "" {
+		return kcmdutil.UsageErrorf(cmd, "--output is required")
+	}
+	entries, err := os.ReadDir(o.outputDir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to read output directory: %w", err)
+	}
+	if len(entries) > 0 {
+		return fmt.Errorf("output directory %s isn't empty", o.outputDir)
+	}
+	o.newBuilder = f.NewBuilder
+	return nil
+}
+
+// Run fetches the resources from the cluster and writes the generated reference
+func (o *GenerateOptions) Run() error {
+	b := o.newBuilder().
+		Unstructured().
+		NamespaceParam(o.namespace)
+	if o.namespace == "" {
+		b = b.AllNamespaces(true)
+	}
+	infos, err := b.ResourceTypes(o.kinds...).
+		SelectAllParam(true).
+		Flatten().
+		Do().
+		Infos()
+	if err != nil {
+		return fmt.Errorf("failed to collect resources: %w", err)
+	}
+	objs := make([]*unstructured.Unstructured, 0, len(infos))
+	for _, info := range infos {
+		if obj, ok := info.Object.(*unstructured.Unstructured); ok {
+			objs = append(objs, obj)
+		}
+	}
+	if len(objs) == 0 {
+		return fmt.Errorf("no resources of kinds %s found in the cluster", strings.Join(o.kinds, ", "))
+	}
+
+	files, err := generateReference(objs, generatedPartName)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(o.outputDir, 0o755); err != nil { // nolint:gosec
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(o.outputDir, name), content, 0o644); err != nil { // nolint:gosec
+			return fmt.Errorf("failed to write %s: %w", name, err)
+		}
+	}
+	if _, err := fmt.Fprintf(o.Out, "Generated a reference with %d templates in %s\n", len(files)-1, o.outputDir); err != nil {
+		return fmt.Errorf("error occurred when writing output: %w", err)
+	}
+	return nil
+}
+
+type generatedTemplate struct {
+	Path string `json:"path"`
+}
+
+type generatedComponent struct {
+	Name  string              `json:"name"`
+	AllOf []generatedTemplate `json:"allOf"`
+}
+
+type generatedPart struct {
+	Name       string               `json:"name"`
+	Components []generatedComponent `json:"components"`
+}
+
+type generatedReference struct {
+	APIVersion string          `json:"apiVersion"`
+	Parts      []generatedPart `json:"parts"`
+}
+
+// generateReference returns the content of the files of a reference made of a template for every resource: the
+// templates and the reference config, in which every kind is a component of the part requiring all its templates
+func generateReference(objs []*unstructured.Unstructured, partName string) (map[string][]byte, error) {
+	objs = append([]*unstructured.Unstructured(nil), objs...)
+	sort.Slice(objs, func(i, j int) bool {
+		return apiKindNamespaceName(objs[i]) < apiKindNamespaceName(objs[j])
+	})
+
+	files := make(map[string][]byte)
+	part := generatedPart{Name: partName}
+	componentOf := make(map[string]int)
+	for _, obj := range objs {
+		content, err := yaml.Marshal(withoutServerPopulatedFields(obj).Object)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal %s: %w", apiKindNamespaceName(obj), err)
+		}
+		name := templateFileName(obj, files)
+		files[name] = []byte(templateDelimiterEscaper.Replace(string(content)))
+
+		i, ok := componentOf[obj.GetKind()]
+		if !ok {
+			i = len(part.Components)
+			componentOf[obj.GetKind()] = i
+			part.Components = append(part.Components, generatedComponent{Name: obj.GetKind()})
+		}
+		part.Components[i].AllOf = append(part.Components[i].AllOf, generatedTemplate{Path: name})
+	}
+
+	metadata, err := yaml.Marshal(generatedReference{APIVersion: ReferenceVersionV2, Parts: []generatedPart{part}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal the reference config: %w", err)
+	}
+	files[generatedReferenceFileName] = metadata
+	return files, nil
+}
+
+// withoutServerPopulatedFields returns a copy of the resource without the fields populated by the API server
+func withoutServerPopulatedFields(obj *unstructured.Unstructured) *unstructured.Unstructured {
+	obj = obj.DeepCopy()
+	for _, field := range serverPopulatedFields {
+		unstructured.RemoveNestedField(obj.Object, field...)
+	}
+	if len(obj.GetAnnotations()) == 0 {
+		unstructured.RemoveNestedField(obj.Object, "metadata", "annotations")
+	}
+	return obj
+}
+
+// templateFileName returns a unique file name for the template of the resource, made of its kind, namespace and name
+func templateFileName(obj *unstructured.Unstructured, files map[string][]byte) string {
+	parts := []string{obj.GetKind()}
+	if obj.GetNamespace() != "" {
+		parts = append(parts, obj.GetNamespace())
+	}
+	parts = append(parts, obj.GetName())
+	base := unsafeFileNameChars.ReplaceAllString(strings.Join(parts, "_"), "-")
+	name := base + ".yaml"
+	for i := 2; files[name] != nil; i++ {
+		name = fmt.Sprintf("%s-%d.yaml", base, i)
+	}
+	return name
+}
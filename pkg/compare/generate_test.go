@@ -0,0 +1,101 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestGenerateReference(t *testing.T) {
+	cm := &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata": map[string]any{
+			"name":              "example",
+			"namespace":         "default",
+			"uid":               "0b2f7e0a",
+			"resourceVersion":   "42",
+			"creationTimestamp": "2024-01-01T00:00:00Z",
+			"managedFields":     []any{map[string]any{"manager": "kubectl"}},
+			"annotations": map[string]any{
+				"kubectl.kubernetes.io/last-applied-configuration": "{}",
+			},
+		},
+		"data": map[string]any{"template": "{{ .Values.name }}"},
+	}}
+	ns := &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "v1",
+		"kind":       "Namespace",
+		"metadata":   map[string]any{"name": "default", "labels": map[string]any{"team": "a"}},
+		"status":     map[string]any{"phase": "Active"},
+	}}
+	role := &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "rbac.authorization.k8s.io/v1",
+		"kind":       "ClusterRole",
+		"metadata":   map[string]any{"name": "system:example"},
+	}}
+
+	files, err := generateReference([]*unstructured.Unstructured{ns, cm, role}, generatedPartName)
+	require.NoError(t, err)
+	require.Equal(t, `apiVersion: v2
+parts:
+- components:
+  - allOf:
+    - path: ClusterRole_system-example.yaml
+    name: ClusterRole
+  - allOf:
+    - path: ConfigMap_default_example.yaml
+    name: ConfigMap
+  - allOf:
+    - path: Namespace_default.yaml
+    name: Namespace
+  name: generated
+`, string(files[generatedReferenceFileName]))
+
+	fsys := fstest.MapFS{}
+	for name, content := range files {
+		fsys[name] = &fstest.MapFile{Data: content}
+	}
+	ref, err := GetReference(fsys, generatedReferenceFileName)
+	require.NoError(t, err)
+	templates, err := ParseTemplates(ref, fsys)
+	require.NoError(t, err)
+	require.Len(t, templates, 3)
+
+	expected := map[string]*unstructured.Unstructured{
+		"ConfigMap_default_example.yaml": {Object: map[string]any{
+			"apiVersion": "v1",
+			"kind":       "ConfigMap",
+			"metadata":   map[string]any{"name": "example", "namespace": "default"},
+			"data":       map[string]any{"template": "{{ .Values.name }}"},
+		}},
+		"Namespace_default.yaml": {Object: map[string]any{
+			"apiVersion": "v1",
+			"kind":       "Namespace",
+			"metadata":   map[string]any{"name": "default", "labels": map[string]any{"team": "a"}},
+		}},
+		"ClusterRole_system-example.yaml": role,
+	}
+	for _, temp := range templates {
+		rendered, err := temp.Exec(map[string]any{})
+		require.NoError(t, err)
+		require.Equal(t, expected[temp.GetPath()].Object, rendered.Object, temp.GetPath())
+	}
+}
+
+func TestTemplateFileNameIsUnique(t *testing.T) {
+	obj := &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   map[string]any{"name": "a:b", "namespace": "default"},
+	}}
+	files := map[string][]byte{}
+	name := templateFileName(obj, files)
+	require.Equal(t, "ConfigMap_default_a-b.yaml", name)
+	files[name] = []byte{}
+	require.Equal(t, "ConfigMap_default_a-b-2.yaml", templateFileName(obj, files))
+}
@@ -0,0 +1,124 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+const configSkeletonHeader = `# Diff config generated by --generate-config, review the correlation pairs and pass the file with --diff-config.
+# Unmatched CRs are paired with the first template of their kind, pairs of CRs without a template of their kind are
+# commented out.
+`
+
+// configSkeleton writes a diff config pinning the CRs of a run that weren't correlated with certainty: the CRs matched
+// by no template and the CRs several templates match equally well. The settings of the diff config of the run are kept.
+type configSkeleton struct {
+	userConfig UserConfig
+	templates  []ReferenceTemplate
+	unmatched  []*unstructured.Unstructured
+	ambiguous  []AmbiguousMatch
+}
+
+// templatesOfKind returns the identifiers of the templates of the kind of the CR
+func (s configSkeleton) templatesOfKind(cr *unstructured.Unstructured) []string {
+	var ids []string
+	for _, t := range s.templates {
+		if t.GetMetadata().GroupVersionKind().GroupKind() == cr.GroupVersionKind().GroupKind() {
+			ids = append(ids, t.GetIdentifier())
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func (s configSkeleton) render() ([]byte, error) {
+	var b strings.Builder
+	b.WriteString(configSkeletonHeader)
+	b.WriteString("correlationSettings:\n  manualCorrelation:\n    correlationPairs:\n")
+
+	pair := func(comment, cr, template string, disabled bool) error {
+		key, err := yaml.Marshal(cr)
+		if err != nil {
+			return fmt.Errorf("failed to marshal correlation pair of %s: %w", cr, err)
+		}
+		value, err := yaml.Marshal(template)
+		if err != nil {
+			return fmt.Errorf("failed to marshal correlation pair of %s: %w", cr, err)
+		}
+		prefix := "      "
+		fmt.Fprintf(&b, "%s# %s\n", prefix, comment)
+		if disabled {
+			prefix += "# "
+		}
+		fmt.Fprintf(&b, "%s%s: %s\n", prefix, strings.TrimSpace(string(key)), strings.TrimSpace(string(value)))
+		return nil
+	}
+
+	existing := s.userConfig.CorrelationSettings.ManualCorrelation.CorrelationPairs
+	keys := make([]string, 0, len(existing))
+	for k := range existing {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if err := pair("From the diff config of the run", k, existing[k], false); err != nil {
+			return nil, err
+		}
+	}
+	for _, m := range s.ambiguous {
+		if _, ok := existing[m.CR]; ok {
+			continue
+		}
+		if err := pair("Ambiguous match, matched equally well by: "+strings.Join(m.Templates, ", "), m.CR, m.Templates[0], false); err != nil {
+			return nil, err
+		}
+	}
+	unmatched := append([]*unstructured.Unstructured(nil), s.unmatched...)
+	sort.Slice(unmatched, func(i, j int) bool {
+		return apiKindNamespaceName(unmatched[i]) < apiKindNamespaceName(unmatched[j])
+	})
+	for _, cr := range unmatched {
+		name := apiKindNamespaceName(cr)
+		if _, ok := existing[name]; ok {
+			continue
+		}
+		candidates := s.templatesOfKind(cr)
+		if len(candidates) == 0 {
+			if err := pair(fmt.Sprintf("Unmatched, the reference has no %s template", cr.GetKind()), name, "", true); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err := pair(fmt.Sprintf("Unmatched, %s templates: %s", cr.GetKind(), strings.Join(candidates, ", ")), name, candidates[0], false); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(s.userConfig.FieldsToOmit) > 0 {
+		content, err := yaml.Marshal(map[string]any{"fieldsToOmit": s.userConfig.FieldsToOmit})
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal the fields to omit of the diff config: %w", err)
+		}
+		b.Write(content)
+	}
+	return []byte(b.String()), nil
+}
+
+// writeConfigSkeleton writes the diff config skeleton of the run to the file
+func writeConfigSkeleton(path string, s configSkeleton) error {
+	content, err := s.render()
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, content, 0o644); err != nil { // nolint:gosec
+		return fmt.Errorf("failed to write the generated diff config: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+func TestConfigSkeleton(t *testing.T) {
+	template := func(path, apiVersion, kind string) ReferenceTemplate {
+		return ReferenceTemplateV1{Path: path, metadata: &unstructured.Unstructured{Object: map[string]any{"apiVersion": apiVersion, "kind": kind}}}
+	}
+	cr := func(apiVersion, kind, namespace, name string) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]any{
+			"apiVersion": apiVersion,
+			"kind":       kind,
+			"metadata":   map[string]any{"name": name, "namespace": namespace},
+		}}
+	}
+	s := configSkeleton{
+		userConfig: UserConfig{CorrelationSettings: CorrelationSettings{ManualCorrelation: ManualCorrelation{
+			CorrelationPairs: map[string]string{"v1_Service_ns_pinned": "svc.yaml"},
+		}}},
+		templates: []ReferenceTemplate{
+			template("cm-b.yaml", "v1", "ConfigMap"),
+			template("cm-a.yaml", "v1", "ConfigMap"),
+			template("svc.yaml", "v1", "Service"),
+		},
+		unmatched: []*unstructured.Unstructured{
+			cr("apps/v1", "Deployment", "ns", "web"),
+			cr("v1", "ConfigMap", "ns", "extra"),
+		},
+		ambiguous: []AmbiguousMatch{{CR: "v1_Service_ns_web", Templates: []string{"svc.yaml", "svc-headless.yaml"}}},
+	}
+	content, err := s.render()
+	require.NoError(t, err)
+	require.Equal(t, configSkeletonHeader+`correlationSettings:
+  manualCorrelation:
+    correlationPairs:
+      # From the diff config of the run
+      v1_Service_ns_pinned: svc.yaml
+      # Ambiguous match, matched equally well by: svc.yaml, svc-headless.yaml
+      v1_Service_ns_web: svc.yaml
+      # Unmatched, the reference has no Deployment template
+      # apps/v1_Deployment_ns_web: ""
+      # Unmatched, ConfigMap templates: cm-a.yaml, cm-b.yaml
+      v1_ConfigMap_ns_extra: cm-a.yaml
+`, string(content))
+
+	var config UserConfig
+	require.NoError(t, yaml.Unmarshal(content, &config), "the skeleton should be a valid diff config")
+	require.Len(t, config.CorrelationSettings.ManualCorrelation.CorrelationPairs, 3)
+}
@@ -0,0 +1,137 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const (
+	GroupByComponent = "component"
+	GroupByNamespace = "namespace"
+	GroupByKind      = "kind"
+)
+
+// GroupByModes are the ways the diffs of the text output can be grouped
+var GroupByModes = []string{GroupByComponent, GroupByNamespace, GroupByKind}
+
+// clusterScoped is how the namespace of cluster-scoped CRs is reported
+const clusterScoped = "<cluster-scoped>"
+
+// NamespaceStats are the statistics of the cluster CRs of a namespace, cluster-scoped CRs have an empty namespace
+type NamespaceStats struct {
+	Namespace     string `json:"Namespace"`
+	CorrelatedCRs int    `json:"CorrelatedCRs"`
+	CRsWithDiffs  int    `json:"CRsWithDiffs"`
+	UnmatchedCRs  int    `json:"UnmatchedCRs"`
+}
+
+func (s NamespaceStats) name() string {
+	if s.Namespace == "" {
+		return clusterScoped
+	}
+	return s.Namespace
+}
+
+func (s NamespaceStats) String() string {
+	return fmt.Sprintf("Namespace: %s (CRs with diffs: %d/%d, unmatched CRs: %d)", s.name(), s.CRsWithDiffs, s.CorrelatedCRs, s.UnmatchedCRs)
+}
+
+// namespaceCounter counts the CRs of every namespace as they're compared
+type namespaceCounter map[string]*NamespaceStats
+
+func (c namespaceCounter) get(namespace string) *NamespaceStats {
+	stats, ok := c[namespace]
+	if !ok {
+		stats = &NamespaceStats{Namespace: namespace}
+		c[namespace] = stats
+	}
+	return stats
+}
+
+func (c namespaceCounter) add(d DiffSum) {
+	stats := c.get(d.namespace)
+	stats.CorrelatedCRs++
+	if d.HasDiff() {
+		stats.CRsWithDiffs++
+	}
+}
+
+// stats returns the statistics of the namespaces of the compared and the unmatched CRs, sorted by namespace with the
+// cluster-scoped CRs first
+func (c namespaceCounter) stats(unmatched []*unstructured.Unstructured) []NamespaceStats {
+	counted := make(namespaceCounter, len(c))
+	for namespace, stats := range c {
+		s := *stats
+		counted[namespace] = &s
+	}
+	for _, cr := range unmatched {
+		counted.get(cr.GetNamespace()).UnmatchedCRs++
+	}
+	result := make([]NamespaceStats, 0, len(counted))
+	for _, stats := range counted {
+		result = append(result, *stats)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Namespace < result[j].Namespace })
+	return result
+}
+
+// diffGroup returns the group the diff is listed under in the text output, diffs are only grouped by component if
+// they're reported under a component
+func diffGroup(d DiffSum, groupBy string) (string, bool) {
+	switch groupBy {
+	case GroupByNamespace:
+		return NamespaceStats{Namespace: d.namespace}.name(), true
+	case GroupByKind:
+		return d.kind, true
+	}
+	return referenceComponent{d.Part, d.Component}.String(), d.Part != ""
+}
+
+func (c referenceComponent) String() string {
+	return c.part + "/" + c.component
+}
+
+// groupHeaders returns the headers of the groups of the diffs in the text output, the statistics of every group
+func groupHeaders(diffs []DiffSum, sum *Summary, groupBy string) map[string]string {
+	headers := make(map[string]string)
+	switch groupBy {
+	case GroupByNamespace:
+		for _, s := range sum.Namespaces {
+			headers[s.name()] = s.String()
+		}
+	case GroupByKind:
+		kinds := make(map[string]*[2]int)
+		for _, d := range diffs {
+			if kinds[d.kind] == nil {
+				kinds[d.kind] = &[2]int{}
+			}
+			kinds[d.kind][1]++
+			if d.HasDiff() {
+				kinds[d.kind][0]++
+			}
+		}
+		for kind, counts := range kinds {
+			headers[kind] = fmt.Sprintf("Kind: %s (CRs with diffs: %d/%d)", kind, counts[0], counts[1])
+		}
+	default:
+		for _, s := range componentStats(diffs) {
+			headers[referenceComponent{s.Part, s.Component}.String()] = s.String()
+		}
+	}
+	return headers
+}
+
+// namespacesSummary lists the statistics of every namespace, in the text output grouped by namespace
+func namespacesSummary(namespaces []NamespaceStats) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "CRs by namespace: %d", len(namespaces))
+	for _, s := range namespaces {
+		fmt.Fprintf(&sb, "\n%s: %d compared, %d with diffs, %d unmatched", s.name(), s.CorrelatedCRs, s.CRsWithDiffs, s.UnmatchedCRs)
+	}
+	return sb.String()
+}
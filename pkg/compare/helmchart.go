@@ -0,0 +1,350 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+	"text/template"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	k8syaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// chartReferencePrefix marks a reference given as the path of a Helm chart, e.g. -r chart:./mychart
+	chartReferencePrefix = "chart:"
+	// helmHookAnnotation marks the resources helm creates around the release (tests, jobs...) rather than as part of it
+	helmHookAnnotation = "helm.sh/hook"
+	// maxIncludeDepth stops templates including each other endlessly, the same limit as helm
+	maxIncludeDepth = 1000
+)
+
+// chartNamespace is inserted in the templates of resources rendered without a namespace, helm sets their namespace
+// when installing the chart, so the template takes the namespace of the CR it is compared to
+const chartNamespace = `{{- if .metadata.namespace }}
+  namespace: {{ .metadata.namespace }}
+{{- end }}
+`
+
+func isChartReference(reference string) bool {
+	return strings.HasPrefix(reference, chartReferencePrefix)
+}
+
+// chartOptions configures the rendering of a chart used as reference
+type chartOptions struct {
+	valuesFiles      []string
+	releaseName      string
+	releaseNamespace string
+}
+
+// loadChartReference renders the chart like helm template does and returns the in memory file system of the reference
+// generated from its manifests: a template for every resource and a reference config, in which the resources of every
+// kind are a component of a part named after the chart
+func loadChartReference(chartPath string, opts chartOptions) (bundleFS, error) {
+	c, err := loader.Load(chartPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load chart %s: %w", chartPath, err)
+	}
+	values := map[string]any{}
+	for _, p := range opts.valuesFiles {
+		v, err := chartutil.ReadValuesFile(p)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read values file %s: %w", p, err)
+		}
+		values = mergeValues(values, v)
+	}
+	if err := chartutil.ProcessDependencies(c, values); err != nil {
+		return nil, fmt.Errorf("failed to process the dependencies of chart %s: %w", c.Name(), err)
+	}
+	releaseName := opts.releaseName
+	if releaseName == "" {
+		releaseName = c.Name()
+	}
+	releaseNamespace := opts.releaseNamespace
+	if releaseNamespace == "" {
+		releaseNamespace = "default"
+	}
+	top, err := chartutil.ToRenderValues(c, values, chartutil.ReleaseOptions{
+		Name:      releaseName,
+		Namespace: releaseNamespace,
+		Revision:  1,
+		IsInstall: true,
+	}, chartutil.DefaultCapabilities)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute the values of chart %s: %w", c.Name(), err)
+	}
+
+	manifests, err := renderChart(c, top)
+	if err != nil {
+		return nil, err
+	}
+	objs, err := chartObjects(manifests)
+	if err != nil {
+		return nil, err
+	}
+	if len(objs) == 0 {
+		return nil, fmt.Errorf("chart %s doesn't render any resource", c.Name())
+	}
+	files, err := generateReference(objs, c.Name())
+	if err != nil {
+		return nil, err
+	}
+	for name, content := range files {
+		if name == generatedReferenceFileName {
+			continue
+		}
+		if files[name], err = withChartNamespace(content); err != nil {
+			return nil, fmt.Errorf("failed to generate template %s: %w", name, err)
+		}
+	}
+	return files, nil
+}
+
+// mergeValues merges the values of a values file into the values of the previous ones, the same way helm merges
+// the values of several --values flags
+func mergeValues(dst, src map[string]any) map[string]any {
+	for k, v := range src {
+		if m, ok := v.(map[string]any); ok {
+			if d, ok := dst[k].(map[string]any); ok {
+				dst[k] = mergeValues(d, m)
+				continue
+			}
+		}
+		dst[k] = v
+	}
+	return dst
+}
+
+// renderable is a template of a chart or of one of its sub charts, along with the values it is rendered with
+type renderable struct {
+	content  string
+	values   chartutil.Values
+	basePath string
+}
+
+// chartFiles gives the templates access to the non template files of the chart
+type chartFiles map[string][]byte
+
+func (f chartFiles) Get(name string) string {
+	return string(f[name])
+}
+
+func (f chartFiles) GetBytes(name string) []byte {
+	return f[name]
+}
+
+// collectTemplates adds the templates of the chart and of its enabled sub charts, the values of sub charts are scoped
+// under their name in the values of their parent
+func collectTemplates(c *chart.Chart, templates map[string]renderable, parent chartutil.Values) {
+	next := chartutil.Values{
+		"Chart": struct {
+			chart.Metadata
+			IsRoot bool
+		}{*c.Metadata, c.IsRoot()},
+		"Files":        newChartFiles(c),
+		"Release":      parent["Release"],
+		"Capabilities": parent["Capabilities"],
+		"Values":       chartutil.Values{},
+	}
+	if c.IsRoot() {
+		next["Values"] = parent["Values"]
+	} else if values, err := parent.Table("Values." + c.Name()); err == nil {
+		next["Values"] = values
+	}
+	for _, dependency := range c.Dependencies() {
+		collectTemplates(dependency, templates, next)
+	}
+	for _, t := range c.Templates {
+		// Library charts only provide partials
+		if t == nil || (c.Metadata.Type == "library" && !isPartial(t.Name)) {
+			continue
+		}
+		templates[path.Join(c.ChartFullPath(), t.Name)] = renderable{
+			content:  string(t.Data),
+			values:   next,
+			basePath: path.Join(c.ChartFullPath(), "templates"),
+		}
+	}
+}
+
+func newChartFiles(c *chart.Chart) chartFiles {
+	files := make(chartFiles, len(c.Files))
+	for _, f := range c.Files {
+		files[f.Name] = f.Data
+	}
+	return files
+}
+
+// isPartial returns whether the template only defines named templates, its output isn't a manifest
+func isPartial(name string) bool {
+	return strings.HasPrefix(path.Base(name), "_")
+}
+
+// renderChart renders the templates of the chart and returns the manifests by template name. The functions of
+// helm are available, except lookup which returns empty objects as the reference doesn't depend on the cluster.
+func renderChart(c *chart.Chart, top chartutil.Values) (map[string]string, error) {
+	templates := make(map[string]renderable)
+	collectTemplates(c, templates, top)
+
+	t := template.New(c.Name()).Option("missingkey=zero").Funcs(FuncMap())
+	depth := 0
+	t.Funcs(template.FuncMap{
+		"include": func(name string, data any) (string, error) {
+			if depth++; depth > maxIncludeDepth {
+				return "", fmt.Errorf("rendering template %s exceeded the maximum include depth of %d", name, maxIncludeDepth)
+			}
+			defer func() { depth-- }()
+			var sb strings.Builder
+			if err := t.ExecuteTemplate(&sb, name, data); err != nil {
+				return "", err //nolint: wrapcheck
+			}
+			return sb.String(), nil
+		},
+		"tpl": func(content string, values chartutil.Values) (string, error) {
+			clone, err := t.Clone()
+			if err != nil {
+				return "", err //nolint: wrapcheck
+			}
+			tpl, err := clone.New("tpl").Parse(content)
+			if err != nil {
+				return "", fmt.Errorf("failed to parse tpl content: %w", err)
+			}
+			var sb strings.Builder
+			if err := tpl.Execute(&sb, values); err != nil {
+				return "", err //nolint: wrapcheck
+			}
+			return strings.ReplaceAll(sb.String(), "<no value>", ""), nil
+		},
+		"required": func(msg string, value any) (any, error) {
+			if s, ok := value.(string); value == nil || (ok && s == "") {
+				return value, errors.New(msg)
+			}
+			return value, nil
+		},
+		"fail": func(msg string) (string, error) {
+			return "", errors.New(msg)
+		},
+		"lookup": func(string, string, string, string) (map[string]any, error) {
+			return map[string]any{}, nil
+		},
+	})
+
+	// Templates of sub charts are parsed first so the parent chart can override the named templates they define
+	names := make([]string, 0, len(templates))
+	for name := range templates {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		di, dj := strings.Count(names[i], "/"), strings.Count(names[j], "/")
+		if di != dj {
+			return di > dj
+		}
+		return names[i] < names[j]
+	})
+	for _, name := range names {
+		if _, err := t.New(name).Parse(templates[name].content); err != nil {
+			return nil, fmt.Errorf("failed to parse chart template %s: %w", name, err)
+		}
+	}
+
+	manifests := make(map[string]string)
+	for _, name := range names {
+		if isPartial(name) || path.Base(name) == "NOTES.txt" {
+			continue
+		}
+		r := templates[name]
+		values := make(chartutil.Values, len(r.values)+1)
+		for k, v := range r.values {
+			values[k] = v
+		}
+		values["Template"] = chartutil.Values{"Name": name, "BasePath": r.basePath}
+		var sb strings.Builder
+		if err := t.ExecuteTemplate(&sb, name, values); err != nil {
+			return nil, fmt.Errorf("failed to render chart template %s: %w", name, err)
+		}
+		manifests[name] = strings.ReplaceAll(sb.String(), "<no value>", "")
+	}
+	return manifests, nil
+}
+
+// chartObjects parses the resources of the rendered manifests, hooks aren't part of the release once installed so
+// they are left out of the reference
+func chartObjects(manifests map[string]string) ([]*unstructured.Unstructured, error) {
+	var objs []*unstructured.Unstructured
+	for name, manifest := range manifests {
+		decoder := k8syaml.NewYAMLOrJSONDecoder(bytes.NewReader([]byte(manifest)), 4096)
+		for {
+			var doc map[string]any
+			if err := decoder.Decode(&doc); err != nil {
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				return nil, fmt.Errorf("failed to parse the output of chart template %s: %w", name, err)
+			}
+			if len(doc) == 0 {
+				continue
+			}
+			obj := &unstructured.Unstructured{Object: doc}
+			if obj.GetKind() == "" || obj.GetAPIVersion() == "" {
+				return nil, fmt.Errorf("chart template %s renders a resource without apiVersion or kind", name)
+			}
+			if _, ok := obj.GetAnnotations()[helmHookAnnotation]; ok {
+				continue
+			}
+			objs = append(objs, obj)
+		}
+	}
+	return objs, nil
+}
+
+// withChartNamespace inserts the namespace of the compared CR in the template of a resource rendered without a
+// namespace
+func withChartNamespace(content []byte) ([]byte, error) {
+	var obj map[string]any
+	if err := yaml.Unmarshal(content, &obj); err != nil {
+		return nil, err // nolint:wrapcheck
+	}
+	if _, ok, _ := unstructured.NestedString(obj, "metadata", "namespace"); ok {
+		return content, nil
+	}
+	before, after, found := bytes.Cut(content, []byte("\nmetadata:\n"))
+	if !found {
+		return content, nil
+	}
+	return bytes.Join([][]byte{before, []byte("\nmetadata:\n"), []byte(chartNamespace), after}, nil), nil
+}
+
+// chartReferenceFS returns the file system of the reference rendered from the chart of a chart: reference, the
+// reference config of the file system is generatedReferenceFileName
+func chartReferenceFS(reference string, opts chartOptions) (bundleFS, error) {
+	chartPath := strings.TrimPrefix(reference, chartReferencePrefix)
+	if _, err := os.Stat(chartPath); err != nil {
+		return nil, fmt.Errorf("failed to find chart %s: %w", chartPath, err)
+	}
+	return loadChartReference(chartPath, opts)
+}
+
+// validateChartFlags checks the flags rendering chart references are only used with them, and that the flags
+// verifying the reference files, which a rendered chart doesn't have, aren't used with them
+func (o *Options) validateChartFlags() error {
+	charts := slices.ContainsFunc(o.referenceConfigs, isChartReference)
+	if !charts && (len(o.chart.valuesFiles) > 0 || o.chart.releaseName != "" || o.chart.releaseNamespace != "") {
+		return usageErrorf("--values, --release-name and --release-namespace can only be used with a chart reference passed as -r %s<chart directory>", chartReferencePrefix)
+	}
+	if charts && (o.verifySignature != "" || o.referenceLock != "") {
+		return usageErrorf("--verify-signature and --reference-lock can't be used with a chart reference")
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestChartReference(t *testing.T) {
+	files, err := chartReferenceFS(chartReferencePrefix+"testdata/HelmChart/chart", chartOptions{
+		valuesFiles:      []string{"testdata/HelmChart/values-prod.yaml"},
+		releaseName:      "frontend",
+		releaseNamespace: "web",
+	})
+	require.NoError(t, err)
+	require.Equal(t, `apiVersion: v2
+parts:
+- components:
+  - allOf:
+    - path: Deployment_frontend.yaml
+    name: Deployment
+  - allOf:
+    - path: ConfigMap_web_frontend-config.yaml
+    name: ConfigMap
+  name: web
+`, string(files[generatedReferenceFileName]), "hooks and notes shouldn't be part of the reference")
+
+	ref, err := GetReference(files, generatedReferenceFileName)
+	require.NoError(t, err)
+	templates, err := ParseTemplates(ref, files)
+	require.NoError(t, err)
+	require.Len(t, templates, 2)
+	for _, temp := range templates {
+		switch temp.GetPath() {
+		case "ConfigMap_web_frontend-config.yaml":
+			rendered, err := temp.Exec(map[string]any{})
+			require.NoError(t, err)
+			require.Equal(t, map[string]any{"greeting": "welcome", "farewell": "bye"}, rendered.Object["data"],
+				"the values files should be merged with the values of the chart")
+		case "Deployment_frontend.yaml":
+			rendered, err := temp.Exec(map[string]any{"metadata": map[string]any{"namespace": "prod"}})
+			require.NoError(t, err)
+			require.Equal(t, "prod", rendered.GetNamespace(), "resources without namespace should take the namespace of the CR")
+			require.Equal(t, "frontend", rendered.GetLabels()["app.kubernetes.io/instance"])
+			require.EqualValues(t, 3, rendered.Object["spec"].(map[string]any)["replicas"])
+
+			rendered, err = temp.Exec(map[string]any{})
+			require.NoError(t, err)
+			require.Empty(t, rendered.GetNamespace())
+		}
+	}
+}
+
+func TestChartReferenceErrors(t *testing.T) {
+	_, err := chartReferenceFS(chartReferencePrefix+"testdata/HelmChart/missing", chartOptions{})
+	require.ErrorContains(t, err, "failed to find chart")
+
+	_, err = loadChartReference("testdata/HelmChart/chart", chartOptions{valuesFiles: []string{"testdata/HelmChart/missing.yaml"}})
+	require.ErrorContains(t, err, "failed to read values file")
+}
+
+func TestMergeValues(t *testing.T) {
+	require.Equal(t, map[string]any{"a": map[string]any{"b": 2, "c": 1}, "d": []any{2}},
+		mergeValues(map[string]any{"a": map[string]any{"b": 1, "c": 1}, "d": []any{1}}, map[string]any{"a": map[string]any{"b": 2}, "d": []any{2}}))
+}
+
+func TestValidateChartFlags(t *testing.T) {
+	o := &Options{referenceConfigs: []string{"metadata.yaml"}, chart: chartOptions{valuesFiles: []string{"values.yaml"}}}
+	require.ErrorContains(t, o.validateChartFlags(), "can only be used with a chart reference")
+
+	o = &Options{referenceConfigs: []string{"chart:./web"}, referenceLock: "lock.yaml"}
+	require.ErrorContains(t, o.validateChartFlags(), "can't be used with a chart reference")
+
+	o = &Options{referenceConfigs: []string{"chart:./web"}, chart: chartOptions{valuesFiles: []string{"values.yaml"}}}
+	require.NoError(t, o.validateChartFlags())
+}
@@ -3,27 +3,170 @@
 package compare
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
 	"net/http"
 	"net/url"
+	"os"
 	"strings"
 	"time"
 )
 
-const defaultHttpGetAttempts = 5
+const (
+	defaultHttpGetAttempts = 5
+	// ReferenceTokenEnv is a bearer token sent with the requests fetching a reference served over http, unlike
+	// --reference-header it doesn't show in the command line of the process
+	ReferenceTokenEnv = "CLUSTER_COMPARE_REFERENCE_TOKEN"
+)
 
 // isURL checks if the given path is a URL by verifying if it starts with "http://" or "https://".
 func isURL(path string) bool {
 	return strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://")
 }
 
+// referenceHTTPOptions configure the requests fetching a reference served over http. The proxy defaults to the one
+// set by the HTTPS_PROXY, HTTP_PROXY and NO_PROXY environment variables.
+type referenceHTTPOptions struct {
+	timeout       time.Duration
+	retries       int
+	retryInterval time.Duration
+	headers       []string
+	proxy         string
+	caFile        string
+	certFile      string
+	keyFile       string
+}
+
+func defaultReferenceHTTPOptions() referenceHTTPOptions {
+	return referenceHTTPOptions{
+		timeout:       30 * time.Second,
+		retries:       defaultHttpGetAttempts - 1,
+		retryInterval: 500 * time.Millisecond,
+	}
+}
+
+// newClient checks the options and returns the client they configure
+func (o referenceHTTPOptions) newClient() (*referenceHTTPClient, error) {
+	if o.timeout < 0 || o.retries < 0 || o.retryInterval < 0 {
+		return nil, usageErrorf("--reference-timeout, --reference-retries and --reference-retry-interval can't be negative")
+	}
+	if (o.certFile == "") != (o.keyFile == "") {
+		return nil, usageErrorf("--reference-client-cert and --reference-client-key must be used together")
+	}
+	headers := http.Header{}
+	for _, h := range o.headers {
+		name, value, ok := strings.Cut(h, ":")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, usageErrorf("invalid --reference-header %q, must be in the form of Name: value", h)
+		}
+		headers.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone() // nolint:forcetypeassert
+	if o.proxy != "" {
+		proxy, err := url.Parse(o.proxy)
+		if err != nil || proxy.Host == "" {
+			return nil, usageErrorf("invalid --reference-proxy %q, must be a URL", o.proxy)
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+	if o.caFile != "" || o.certFile != "" {
+		transport.TLSClientConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	if o.caFile != "" {
+		ca, err := os.ReadFile(o.caFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the CA bundle of the reference server: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no PEM certificate found in the CA bundle %s", o.caFile)
+		}
+		transport.TLSClientConfig.RootCAs = pool
+	}
+	if o.certFile != "" {
+		cert, err := tls.LoadX509KeyPair(o.certFile, o.keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load the client certificate for the reference server: %w", err)
+		}
+		transport.TLSClientConfig.Certificates = []tls.Certificate{cert}
+	}
+	return &referenceHTTPClient{
+		client:        &http.Client{Transport: transport, Timeout: o.timeout},
+		headers:       headers,
+		attempts:      o.retries + 1,
+		retryInterval: o.retryInterval,
+	}, nil
+}
+
+// referenceHTTPClient fetches the files of references served over http, retrying transient errors with an
+// exponential backoff
+type referenceHTTPClient struct {
+	client        *http.Client
+	headers       http.Header
+	attempts      int
+	retryInterval time.Duration
+}
+
+// defaultReferenceClient returns a client with the default settings of the --reference-* flags, for the commands that
+// don't set them. The runs set up their own client from their flags.
+func defaultReferenceClient() (*referenceHTTPClient, error) {
+	return defaultReferenceHTTPOptions().newClient()
+}
+
+// get implements httpget with the headers of the client
+func (c *referenceHTTPClient) get(u string) (int, string, io.ReadCloser, int64, error) {
+	req, err := http.NewRequest(http.MethodGet, u, http.NoBody)
+	if err != nil {
+		return 0, "", nil, 0, fmt.Errorf("failed to create request for %s: %w", u, err)
+	}
+	req.Header = c.headers.Clone()
+	if token := os.Getenv(ReferenceTokenEnv); token != "" && req.Header.Get("Authorization") == "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, "", nil, 0, fmt.Errorf("failed to fetch %s: %w", u, err)
+	}
+	return resp.StatusCode, resp.Status, resp.Body, resp.ContentLength, nil
+}
+
+// read returns the body of the url
+func (c *referenceHTTPClient) read(u string) (io.ReadCloser, int64, error) {
+	return readHttpWithRetries(c.get, c.retryInterval, u, c.attempts)
+}
+
 // HTTPFS represents a file system that retrieves files from a http server by returning the http response body,
 // ideal for http servers that return raw files
 type HTTPFS struct {
 	baseURL string
-	httpGet httpget
+	client  *referenceHTTPClient
+}
+
+// urlFS is implemented by the file systems that can hold files served over http, url returns the URL a file is
+// loaded from or an empty string if the file isn't served over http
+type urlFS interface {
+	url(name string) (string, error)
+}
+
+// fileURL returns the URL a file of the reference is loaded from, or an empty string if it isn't served over http
+func fileURL(fsys fs.FS, name string) (string, error) {
+	if u, ok := fsys.(urlFS); ok {
+		return u.url(name)
+	}
+	return "", nil
+}
+
+func (fs HTTPFS) url(name string) (string, error) {
+	fullURL, err := url.JoinPath(fs.baseURL, name)
+	if err != nil {
+		return "", fmt.Errorf("could not construct url: %w", err)
+	}
+	return fullURL, nil
 }
 
 // httpget is a function type that defines the signature of functions used to retrieve HTTP resources.
@@ -31,11 +174,11 @@ type httpget func(url string) (int, string, io.ReadCloser, int64, error)
 
 // Open creates a http request and returns a http body reader object representing a file for reading.
 func (fs HTTPFS) Open(name string) (fs.File, error) {
-	fullURL, err := url.JoinPath(fs.baseURL, name)
+	fullURL, err := fs.url(name)
 	if err != nil {
-		return HTTPFile{}, fmt.Errorf("could not construct url: %w", err)
+		return HTTPFile{}, err
 	}
-	body, contentLength, err := readHttpWithRetries(fs.httpGet, 5*time.Millisecond, fullURL, defaultHttpGetAttempts)
+	body, contentLength, err := fs.client.read(fullURL)
 	if err != nil {
 		return HTTPFile{}, err
 	}
@@ -43,21 +186,20 @@ func (fs HTTPFS) Open(name string) (fs.File, error) {
 	return file, err
 }
 
-// httpgetImpl Implements a function to retrieve a url and return the results.
-func httpgetImpl(url string) (int, string, io.ReadCloser, int64, error) {
-	resp, err := http.Get(url) // nolint:gosec // intended behaviour
-	if err != nil {
-		return 0, "", nil, 0, fmt.Errorf("failed to fetch %s: %w", url, err)
-	}
-	return resp.StatusCode, resp.Status, resp.Body, resp.ContentLength, nil
+// isTransientStatus returns whether a request failing with the status code may succeed when retried
+func isTransientStatus(statusCode int) bool {
+	return statusCode == http.StatusTooManyRequests || statusCode == http.StatusRequestTimeout ||
+		(statusCode >= 500 && statusCode < 600)
 }
 
-// readHttpWithRetries tries to http.Get the v.URL retries times before giving up.
+// readHttpWithRetries tries to get the url attempts times before giving up. Errors of the connection and transient
+// status codes are retried, waiting for duration before the first retry and doubling the wait on every following one.
 func readHttpWithRetries(get httpget, duration time.Duration, u string, attempts int) (io.ReadCloser, int64, error) {
 	var err error
 	if attempts <= 0 {
 		return nil, 0, fmt.Errorf("http attempts must be greater than 0, was %d", attempts)
 	}
+	delay := duration
 	for i := 0; i < attempts; i++ {
 		var (
 			statusCode    int
@@ -66,13 +208,18 @@ func readHttpWithRetries(get httpget, duration time.Duration, u string, attempts
 			contentLength int64
 		)
 		if i > 0 {
-			time.Sleep(duration)
+			time.Sleep(delay)
+			delay *= 2
 		}
 
 		// Try to get the URL
 		statusCode, status, body, contentLength, err = get(u)
 
-		// Retry Errors
+		// Retry Errors, except certificates that can't be verified as they won't change
+		var certErr *tls.CertificateVerificationError
+		if err != nil && errors.As(err, &certErr) {
+			break
+		}
 		if err != nil {
 			continue
 		}
@@ -87,10 +234,7 @@ func readHttpWithRetries(get httpget, duration time.Duration, u string, attempts
 		// Error - Set the error condition from the StatusCode
 		err = fmt.Errorf("unable to read URL %q, server reported %s, status code=%d", u, status, statusCode)
 
-		if statusCode >= 500 && statusCode < 600 {
-			// Retry 500's
-			continue
-		} else {
+		if !isTransientStatus(statusCode) {
 			// Don't retry other StatusCodes
 			break
 		}
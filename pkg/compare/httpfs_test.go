@@ -0,0 +1,125 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadHttpWithRetries(t *testing.T) {
+	statuses := []int{http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusOK}
+	var requests []time.Time
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, time.Now())
+		w.WriteHeader(statuses[len(requests)-1])
+		_, _ = io.WriteString(w, "content")
+	}))
+	defer svr.Close()
+	opts := defaultReferenceHTTPOptions()
+	opts.retryInterval = 10 * time.Millisecond
+	client, err := opts.newClient()
+	require.NoError(t, err)
+
+	body, _, err := client.read(svr.URL)
+	require.NoError(t, err)
+	content, err := io.ReadAll(body)
+	require.NoError(t, err)
+	require.Equal(t, "content", string(content))
+	require.Len(t, requests, 3)
+	// The wait doubles after every retry
+	require.GreaterOrEqual(t, requests[2].Sub(requests[1]), 20*time.Millisecond)
+
+	t.Run("not found isn't retried", func(t *testing.T) {
+		requests = nil
+		statuses = []int{http.StatusNotFound}
+		_, _, err := client.read(svr.URL)
+		require.ErrorContains(t, err, "status code=404")
+		require.Len(t, requests, 1)
+	})
+}
+
+func TestReferenceHTTPClientHeaders(t *testing.T) {
+	var received http.Header
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header
+	}))
+	defer svr.Close()
+
+	opts := defaultReferenceHTTPOptions()
+	opts.headers = []string{"X-Tenant: telco"}
+	client, err := opts.newClient()
+	require.NoError(t, err)
+	t.Setenv(ReferenceTokenEnv, "secret")
+	_, err = HTTPFS{baseURL: svr.URL, client: client}.Open("metadata.yaml")
+	require.NoError(t, err)
+	require.Equal(t, "telco", received.Get("X-Tenant"))
+	require.Equal(t, "Bearer secret", received.Get("Authorization"))
+
+	opts.headers = []string{"Authorization: Basic dXNlcjpwYXNz"}
+	client, err = opts.newClient()
+	require.NoError(t, err)
+	_, err = HTTPFS{baseURL: svr.URL, client: client}.Open("metadata.yaml")
+	require.NoError(t, err)
+	require.Equal(t, "Basic dXNlcjpwYXNz", received.Get("Authorization"))
+
+	// The headers of a run are only sent by its own client
+	_, err = GetRefFS(svr.URL + "/metadata.yaml")
+	require.NoError(t, err)
+	require.Empty(t, received.Get("X-Tenant"))
+	require.Equal(t, "Bearer secret", received.Get("Authorization"))
+}
+
+func TestReferenceHTTPClientCAFile(t *testing.T) {
+	svr := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "apiVersion: v2")
+	}))
+	defer svr.Close()
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: svr.Certificate().Raw}), 0o600))
+
+	defaultClient, err := defaultReferenceClient()
+	require.NoError(t, err)
+	_, err = HTTPFS{baseURL: svr.URL, client: defaultClient}.Open("metadata.yaml")
+	require.ErrorContains(t, err, "certificate")
+
+	opts := defaultReferenceHTTPOptions()
+	opts.caFile = caFile
+	client, err := opts.newClient()
+	require.NoError(t, err)
+	f, err := HTTPFS{baseURL: svr.URL, client: client}.Open("metadata.yaml")
+	require.NoError(t, err)
+	content, err := io.ReadAll(f)
+	require.NoError(t, err)
+	require.Equal(t, "apiVersion: v2", string(content))
+}
+
+func TestReferenceHTTPOptionsErrors(t *testing.T) {
+	cases := []struct {
+		name   string
+		modify func(o *referenceHTTPOptions)
+		err    string
+	}{
+		{name: "negative retries", modify: func(o *referenceHTTPOptions) { o.retries = -1 }, err: "can't be negative"},
+		{name: "header without value", modify: func(o *referenceHTTPOptions) { o.headers = []string{"Authorization"} }, err: "must be in the form of Name: value"},
+		{name: "invalid proxy", modify: func(o *referenceHTTPOptions) { o.proxy = "proxy" }, err: "invalid --reference-proxy"},
+		{name: "cert without key", modify: func(o *referenceHTTPOptions) { o.certFile = "tls.crt" }, err: "must be used together"},
+		{name: "missing CA file", modify: func(o *referenceHTTPOptions) { o.caFile = "missing.pem" }, err: "failed to read the CA bundle"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			opts := defaultReferenceHTTPOptions()
+			c.modify(&opts)
+			_, err := opts.newClient()
+			require.ErrorContains(t, err, c.err)
+		})
+	}
+}
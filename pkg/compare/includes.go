@@ -0,0 +1,244 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// ReferenceInclude is a reference whose parts are added to the including reference, e.g. a shared base reference
+// included by the references of every hardware. The templates of the included reference are read from the namespace
+// directory of the including reference and its components are named <namespace>/<component>.
+type ReferenceInclude struct {
+	// Path is the path of the reference config file of the included reference, relative to the including reference,
+	// or its URL
+	Path      string `json:"path"`
+	Namespace string `json:"namespace"`
+}
+
+func validateIncludes(includes []*ReferenceInclude) error {
+	var errs []error
+	namespaces := make([]string, 0, len(includes))
+	for i, include := range includes {
+		switch {
+		case include.Path == "":
+			errs = append(errs, fmt.Errorf("includes entry %d: path is required", i))
+		case include.Namespace == "":
+			errs = append(errs, fmt.Errorf("includes entry %d: namespace is required", i))
+		case strings.Contains(include.Namespace, "/") || !fs.ValidPath(include.Namespace) || include.Namespace == ".":
+			errs = append(errs, fmt.Errorf("includes entry %d: invalid namespace %q, it must be a single path element", i, include.Namespace))
+		case slices.Contains(namespaces, include.Namespace):
+			errs = append(errs, fmt.Errorf("includes entry %d: namespace %s is used by another include", i, include.Namespace))
+		}
+		namespaces = append(namespaces, include.Namespace)
+	}
+	return errors.Join(errs...)
+}
+
+// includeFS is the file system of a reference with includes, the files of every included reference are read under its
+// namespace directory
+type includeFS struct {
+	fs.FS
+	includes map[string]fs.FS
+}
+
+func (f includeFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	namespace, rest, _ := strings.Cut(name, "/")
+	if included, ok := f.includes[namespace]; ok {
+		if rest == "" {
+			rest = "."
+		}
+		return included.Open(rest) // nolint:wrapcheck
+	}
+	return f.FS.Open(name) // nolint:wrapcheck
+}
+
+func (f includeFS) url(name string) (string, error) {
+	namespace, rest, _ := strings.Cut(name, "/")
+	if included, ok := f.includes[namespace]; ok {
+		return fileURL(included, rest)
+	}
+	return fileURL(f.FS, name)
+}
+
+// withIncludes mounts the file systems of the references included by the reference under their namespaces. The
+// includes of the included references are mounted in turn, a reference including itself is reported. The reference
+// config is the absolute path or the URL of the reference.
+func withIncludes(fsys fs.FS, refConfig string, client *referenceHTTPClient, including []string) (fs.FS, error) {
+	content, err := fs.ReadFile(fsys, ReferenceFileName(refConfig))
+	if err != nil {
+		// Reported when the reference is loaded
+		return fsys, nil
+	}
+	var ref struct {
+		Includes []*ReferenceInclude `json:"includes"`
+	}
+	if err := yaml.Unmarshal(content, &ref); err != nil || len(ref.Includes) == 0 {
+		return fsys, nil
+	}
+	if err := validateIncludes(ref.Includes); err != nil {
+		return nil, fmt.Errorf("invalid includes of reference %s: %w", refConfig, err)
+	}
+	including = append(including, refConfig)
+	result := includeFS{FS: fsys, includes: make(map[string]fs.FS)}
+	for _, include := range ref.Includes {
+		if _, err := fs.Stat(fsys, include.Namespace); err == nil {
+			return nil, fmt.Errorf("the namespace %s of the include of %s conflicts with the %s file of the reference", include.Namespace, include.Path, include.Namespace)
+		}
+		location, err := resolveInclude(refConfig, include.Path)
+		if err != nil {
+			return nil, err
+		}
+		if slices.Contains(including, location) {
+			return nil, fmt.Errorf("reference %s includes itself through %s", location, strings.Join(including, " -> "))
+		}
+		included, err := getRefFS(location, client, including)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load the reference %s included by %s: %w", include.Path, refConfig, err)
+		}
+		result.includes[include.Namespace] = included
+	}
+	return result, nil
+}
+
+// resolveInclude returns the location of an included reference, relative paths are relative to the including
+// reference
+func resolveInclude(refConfig, includePath string) (string, error) {
+	if isURL(includePath) || filepath.IsAbs(includePath) {
+		return includePath, nil
+	}
+	if isURL(refConfig) {
+		base, err := url.Parse(refConfig)
+		if err != nil {
+			return "", fmt.Errorf("invalid reference url %s: %w", refConfig, err)
+		}
+		rel, err := url.Parse(includePath)
+		if err != nil {
+			return "", fmt.Errorf("invalid include path %s: %w", includePath, err)
+		}
+		return base.ResolveReference(rel).String(), nil
+	}
+	location, err := filepath.Abs(filepath.Join(filepath.Dir(refConfig), includePath))
+	if err != nil {
+		return "", fmt.Errorf("failed to get absolute path: %w", err)
+	}
+	return location, nil
+}
+
+// includeReferences adds the parts of the included references to the reference. The included references are read
+// from their namespace directory, where withIncludes mounted them or where a bundle holds them.
+func (r *ReferenceV2) includeReferences(fsys fs.FS) error {
+	if err := validateIncludes(r.Includes); err != nil {
+		return err
+	}
+	var errs []error
+	for _, include := range r.Includes {
+		sub, err := fs.Sub(fsys, include.Namespace)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to read the reference included under %s: %w", include.Namespace, err))
+			continue
+		}
+		included, err := readReferenceV2(sub, ReferenceFileName(include.Path))
+		if err == nil {
+			err = r.merge(included, include.Namespace)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("included reference %s: %w", include.Path, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// merge adds the parts, template function files and fieldsToOmit of an included reference to the reference, the
+// components, template paths and fieldsToOmit items of the included reference are prefixed with its namespace
+func (r *ReferenceV2) merge(included *ReferenceV2, namespace string) error {
+	var settings []string
+	for name, set := range map[string]bool{
+		"correlationFieldGroups": len(included.CorrelationFieldGroups) > 0,
+		"unorderedLists":         len(included.UnorderedLists) > 0,
+		"defaults":               len(included.Defaults) > 0,
+		"normalizeQuantities":    included.NormalizeQuantities,
+		"matchTieBreakers":       included.MatchTieBreakers != nil,
+		"fieldSeverities":        len(included.FieldSeverities) > 0,
+		"operatorVersions":       len(included.OperatorVersions) > 0,
+	} {
+		if set {
+			settings = append(settings, name)
+		}
+	}
+	if len(settings) > 0 {
+		slices.Sort(settings)
+		return fmt.Errorf("included references can't set %s, these settings apply to every template and are only read from the including reference",
+			strings.Join(settings, ", "))
+	}
+
+	prefix := func(name string) string {
+		if name == builtInPathsKey {
+			return name
+		}
+		return namespace + "/" + name
+	}
+	defaultOmitRef := builtInPathsKey
+	if included.FieldsToOmit != nil {
+		if included.FieldsToOmit.DefaultOmitRef != "" {
+			defaultOmitRef = included.FieldsToOmit.DefaultOmitRef
+		}
+		if len(included.FieldsToOmit.Items) > 0 {
+			if r.FieldsToOmit == nil {
+				r.FieldsToOmit = &FieldsToOmitV2{}
+			}
+			if r.FieldsToOmit.Items == nil {
+				r.FieldsToOmit.Items = make(map[string][]*FieldsToOmitV2Entry)
+			}
+		}
+		for key, entries := range included.FieldsToOmit.Items {
+			merged := make([]*FieldsToOmitV2Entry, 0, len(entries))
+			for _, entry := range entries {
+				e := &FieldsToOmitV2Entry{ManifestPathV1: entry.ManifestPathV1}
+				if entry.Include != "" {
+					e.Include = prefix(entry.Include)
+				}
+				merged = append(merged, e)
+			}
+			r.FieldsToOmit.Items[prefix(key)] = merged
+		}
+	}
+	for _, f := range included.TemplateFunctionFiles {
+		r.TemplateFunctionFiles = append(r.TemplateFunctionFiles, path.Join(namespace, f))
+	}
+
+	for _, part := range included.Parts {
+		for _, comp := range part.Components {
+			comp.Name = namespace + "/" + comp.Name
+			for _, g := range comp.groups() {
+				for _, temp := range g.templates {
+					temp.Path = path.Join(namespace, temp.Path)
+					for i, dependency := range temp.Config.DependsOn {
+						temp.Config.DependsOn[i] = path.Join(namespace, dependency)
+					}
+					// The default fieldsToOmit of the included reference keeps applying to its templates
+					if len(temp.Config.FieldsToOmitRefs) == 0 {
+						temp.Config.FieldsToOmitRefs = []string{defaultOmitRef}
+					}
+					for i, ref := range temp.Config.FieldsToOmitRefs {
+						temp.Config.FieldsToOmitRefs[i] = prefix(ref)
+					}
+				}
+			}
+		}
+		r.Parts = append(r.Parts, part)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveInclude(t *testing.T) {
+	location, err := resolveInclude("https://example.com/refs/du/metadata.yaml", "../base/metadata.yaml")
+	require.NoError(t, err)
+	require.Equal(t, "https://example.com/refs/base/metadata.yaml", location)
+
+	location, err = resolveInclude("https://example.com/refs/du/metadata.yaml", "https://other.com/hw/metadata.yaml")
+	require.NoError(t, err)
+	require.Equal(t, "https://other.com/hw/metadata.yaml", location)
+
+	location, err = resolveInclude("/refs/du/metadata.yaml", "../base/metadata.yaml")
+	require.NoError(t, err)
+	require.Equal(t, "/refs/base/metadata.yaml", location)
+}
+
+func TestValidateIncludes(t *testing.T) {
+	err := validateIncludes([]*ReferenceInclude{
+		{Path: "base/metadata.yaml", Namespace: "base"},
+		{Path: "other/metadata.yaml", Namespace: "base"},
+		{Path: "hw/metadata.yaml", Namespace: "hw/nic"},
+		{Namespace: "hw"},
+		{Path: "hw/metadata.yaml"},
+	})
+	require.EqualError(t, err, `includes entry 1: namespace base is used by another include
+includes entry 2: invalid namespace "hw/nic", it must be a single path element
+includes entry 3: path is required
+includes entry 4: namespace is required`)
+}
+
+func TestBundleWithIncludes(t *testing.T) {
+	refConfig := filepath.Join(TestDirs, "ReferenceIncludes", TestRefDirName, defaultReferenceFilename)
+	content, _, err := bundleReference(refConfig)
+	require.NoError(t, err)
+	bundle := filepath.Join(t.TempDir(), "reference.tgz")
+	require.NoError(t, os.WriteFile(bundle, content, 0o600))
+
+	fsys, err := GetRefFS(bundle)
+	require.NoError(t, err)
+	ref, err := GetReference(fsys, ReferenceFileName(bundle))
+	require.NoError(t, err)
+	var paths []string
+	for _, temp := range ref.GetTemplates() {
+		paths = append(paths, temp.GetPath())
+	}
+	require.Equal(t, []string{"nic.yaml", "base/namespace.yaml", "base/settings.yaml"}, paths)
+	_, err = ParseTemplates(ref, fsys)
+	require.NoError(t, err)
+}
@@ -0,0 +1,210 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/kubectl/pkg/cmd/diff"
+	"k8s.io/utils/exec"
+)
+
+const (
+	DiffEngineExternal = "external"
+	DiffEngineInternal = "internal"
+)
+
+var DiffEngines = []string{DiffEngineExternal, DiffEngineInternal}
+
+type fieldChangeType string
+
+const (
+	fieldAdded   fieldChangeType = "+"
+	fieldRemoved fieldChangeType = "-"
+	fieldChanged fieldChangeType = "~"
+)
+
+// fieldChange is a difference in a single field between two objects
+type fieldChange struct {
+	change fieldChangeType
+	path   []string
+	from   any
+	to     any
+}
+
+func (c fieldChange) String() string {
+	switch c.change {
+	case fieldAdded:
+		return fmt.Sprintf("+ %s: %s", formatFieldPath(c.path), formatFieldValue(c.to))
+	case fieldRemoved:
+		return fmt.Sprintf("- %s: %s", formatFieldPath(c.path), formatFieldValue(c.from))
+	default:
+		return fmt.Sprintf("~ %s: %s -> %s", formatFieldPath(c.path), formatFieldValue(c.from), formatFieldValue(c.to))
+	}
+}
+
+// formatFieldPath formats the path in the pathToKey syntax, keys containing dots are quoted and list indexes are
+// appended in brackets
+func formatFieldPath(path []string) string {
+	var sb strings.Builder
+	for _, key := range path {
+		if strings.HasPrefix(key, "[") {
+			sb.WriteString(key)
+			continue
+		}
+		if sb.Len() > 0 {
+			sb.WriteString(".")
+		}
+		if strings.ContainsAny(key, `."[`) {
+			key = strconv.Quote(key)
+		}
+		sb.WriteString(key)
+	}
+	return sb.String()
+}
+
+func formatFieldValue(v any) string {
+	content, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(content)
+}
+
+// structuralDiff returns the field level changes needed to get from one unstructured value to the other. Maps are
+// compared key by key and lists index by index, values of different types are reported as changed.
+func structuralDiff(path []string, from, to any) []fieldChange {
+	switch f := from.(type) {
+	case map[string]any:
+		t, ok := to.(map[string]any)
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(f)+len(t))
+		for k := range f {
+			keys = append(keys, k)
+		}
+		for k := range t {
+			if _, ok := f[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		var changes []fieldChange
+		for _, k := range keys {
+			fv, inFrom := f[k]
+			tv, inTo := t[k]
+			keyPath := append(path[:len(path):len(path)], k)
+			switch {
+			case !inFrom:
+				changes = append(changes, fieldChange{change: fieldAdded, path: keyPath, to: tv})
+			case !inTo:
+				changes = append(changes, fieldChange{change: fieldRemoved, path: keyPath, from: fv})
+			default:
+				changes = append(changes, structuralDiff(keyPath, fv, tv)...)
+			}
+		}
+		return changes
+	case []any:
+		t, ok := to.([]any)
+		if !ok {
+			break
+		}
+		var changes []fieldChange
+		for i := 0; i < max(len(f), len(t)); i++ {
+			indexPath := append(path[:len(path):len(path)], fmt.Sprintf("[%d]", i))
+			switch {
+			case i >= len(f):
+				changes = append(changes, fieldChange{change: fieldAdded, path: indexPath, to: t[i]})
+			case i >= len(t):
+				changes = append(changes, fieldChange{change: fieldRemoved, path: indexPath, from: f[i]})
+			default:
+				changes = append(changes, structuralDiff(indexPath, f[i], t[i])...)
+			}
+		}
+		return changes
+	}
+	if formatFieldValue(from) == formatFieldValue(to) {
+		return nil
+	}
+	return []fieldChange{{change: fieldChanged, path: path, from: from, to: to}}
+}
+
+// runInternalDiffer diffs the versions of the object without an external diff program, the output lists the
+// changed fields. Like with diff, the returned exit error has code 1 if differences were found.
+func runInternalDiffer(obj diff.Object, from, to string, o *Options) (*bytes.Buffer, exec.ExitError, error) {
+	diffOutput := new(bytes.Buffer)
+	fromContent, toContent, err := diffContents(obj, from, to, o)
+	if err != nil {
+		return diffOutput, nil, err
+	}
+
+	changes := structuralDiff(nil, fromContent, toContent)
+	if len(changes) == 0 {
+		return diffOutput, nil, nil
+	}
+	fmt.Fprintf(diffOutput, "--- %s/%s\n+++ %s/%s\n", from, obj.Name(), to, obj.Name())
+	for _, c := range changes {
+		fmt.Fprintln(diffOutput, c)
+	}
+	return diffOutput, exec.CodeExitError{Err: fmt.Errorf("%d fields differ", len(changes)), Code: 1}, nil
+}
+
+// diffContents returns the content of the versions of the object as they're diffed by kubectl diff: without managed
+// fields unless they're shown, and with the data of secrets masked
+func diffContents(obj diff.Object, from, to string, o *Options) (map[string]any, map[string]any, error) {
+	fromObj, err := diffVersionObject(obj, from)
+	if err != nil {
+		return nil, nil, err
+	}
+	toObj, err := diffVersionObject(obj, to)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !o.ShowManagedFields {
+		fromObj, toObj = withoutManagedFields(fromObj), withoutManagedFields(toObj)
+	}
+	if gvk := toObj.GetObjectKind().GroupVersionKind(); gvk.Version == "v1" && gvk.Kind == "Secret" {
+		m, err := diff.NewMasker(fromObj, toObj)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error occurered during diff: %w", err)
+		}
+		fromObj, toObj = m.From(), m.To()
+	}
+
+	fromContent, err := runtime.DefaultUnstructuredConverter.ToUnstructured(fromObj)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error occurered during diff: %w", err)
+	}
+	toContent, err := runtime.DefaultUnstructuredConverter.ToUnstructured(toObj)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error occurered during diff: %w", err)
+	}
+	return fromContent, toContent, nil
+}
+
+func diffVersionObject(obj diff.Object, version string) (runtime.Object, error) {
+	switch version {
+	case "LIVE":
+		return obj.Live(), nil
+	case "MERGED":
+		return obj.Merged() //nolint: wrapcheck
+	}
+	return nil, fmt.Errorf("unknown version: %v", version)
+}
+
+func withoutManagedFields(o runtime.Object) runtime.Object {
+	a, err := meta.Accessor(o)
+	if err != nil {
+		return o
+	}
+	a.SetManagedFields(nil)
+	return o
+}
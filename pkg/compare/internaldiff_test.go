@@ -0,0 +1,61 @@
+package compare
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/yaml"
+)
+
+func TestStructuralDiff(t *testing.T) {
+	tests := []struct {
+		name     string
+		from     string
+		to       string
+		expected []string
+	}{
+		{
+			name:     "equal",
+			from:     "a: {b: [1, {c: d}]}",
+			to:       "a: {b: [1, {c: d}]}",
+			expected: nil,
+		},
+		{
+			name:     "list elements",
+			from:     "a: [u, v, z]",
+			to:       "a: [u, w]",
+			expected: []string{`~ a[1]: "v" -> "w"`, `- a[2]: "z"`},
+		},
+		{
+			name:     "nested list fields",
+			from:     "spec: {containers: [{name: c, image: one}]}",
+			to:       "spec: {containers: [{name: c, image: two}, {name: d}]}",
+			expected: []string{`~ spec.containers[0].image: "one" -> "two"`, `+ spec.containers[1]: {"name":"d"}`},
+		},
+		{
+			name:     "quoted keys",
+			from:     "metadata: {labels: {app.kubernetes.io/name: one}}",
+			to:       "metadata: {labels: {app.kubernetes.io/name: two}}",
+			expected: []string{`~ metadata.labels."app.kubernetes.io/name": "one" -> "two"`},
+		},
+		{
+			name:     "type change and null",
+			from:     "a: {b: c}\nd: null",
+			to:       "a: [b]",
+			expected: []string{`~ a: {"b":"c"} -> ["b"]`, `- d: null`},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var from, to map[string]any
+			require.NoError(t, yaml.Unmarshal([]byte(test.from), &from))
+			require.NoError(t, yaml.Unmarshal([]byte(test.to), &to))
+			var changes []string
+			for _, c := range structuralDiff(nil, from, to) {
+				changes = append(changes, c.String())
+			}
+			assert.Equal(t, test.expected, changes)
+		})
+	}
+}
@@ -0,0 +1,166 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	k8syaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	InputFormatManifests = "manifests"
+	InputFormatInventory = "inventory"
+)
+
+var InputFormats = []string{InputFormatManifests, InputFormatInventory}
+
+// readInventoryCRs reads the CRs of inventory exports: API list dumps (e.g. kubectl get --raw), ArgoCD managed
+// resources (their liveState), ACM ManagedClusterViews (their result) and lists of entries wrapping the CRs in an
+// object field. The CRs of each file are returned as a stream of YAML documents to pass to the builder.
+func readInventoryCRs(filenames []string, recursive bool, stdin io.Reader) ([]streamedCR, error) {
+	var result []streamedCR
+	for _, f := range filenames {
+		if isURL(f) {
+			return nil, fmt.Errorf("inventory exports can't be read from URLs: %s", f)
+		}
+		var paths []string
+		if f == stdinFilename {
+			paths = []string{f}
+		} else {
+			var err error
+			if paths, err = expandCRPaths(f, recursive); err != nil {
+				return nil, fmt.Errorf("failed to find the inventory exports: %w", err)
+			}
+		}
+		for _, p := range paths {
+			content, err := readInventoryFile(p, stdin)
+			if err != nil {
+				return nil, err
+			}
+			crs, err := inventoryCRs(content)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read the inventory export %s: %w", p, err)
+			}
+			result = append(result, streamedCR{name: p, content: crs})
+		}
+	}
+	return result, nil
+}
+
+func readInventoryFile(p string, stdin io.Reader) ([]byte, error) {
+	var content []byte
+	var err error
+	if p == stdinFilename {
+		content, err = io.ReadAll(stdin)
+	} else {
+		content, err = os.ReadFile(p)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", p, err)
+	}
+	if isSopsEncrypted(content) {
+		return decryptSops(p, content)
+	}
+	return content, nil
+}
+
+// inventoryCRs converts the documents of an inventory export to a stream of YAML documents of the CRs it holds
+func inventoryCRs(content []byte) ([]byte, error) {
+	var out bytes.Buffer
+	decoder := k8syaml.NewYAMLOrJSONDecoder(bytes.NewReader(content), 4096)
+	for {
+		var doc any
+		if err := decoder.Decode(&doc); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("failed to parse: %w", err)
+		}
+		if doc == nil {
+			continue
+		}
+		if list, ok := doc.([]any); ok {
+			doc = map[string]any{"items": list}
+		}
+		entry, ok := doc.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("unexpected document of type %T", doc)
+		}
+		crs, err := inventoryObjects(entry)
+		if err != nil {
+			return nil, err
+		}
+		for _, cr := range crs {
+			data, err := yaml.Marshal(cr)
+			if err != nil {
+				return nil, fmt.Errorf("failed to convert %s: %w", apiKindNamespaceName(&unstructured.Unstructured{Object: cr}), err)
+			}
+			out.WriteString("---\n")
+			out.Write(data)
+		}
+	}
+	return out.Bytes(), nil
+}
+
+// inventoryObjects returns the CRs held by an entry of an inventory export
+func inventoryObjects(entry map[string]any) ([]map[string]any, error) {
+	kind, _ := entry["kind"].(string)
+	if liveState, ok := entry["liveState"]; ok {
+		// ArgoCD managed resources hold the live CR as a JSON string, "null" when the CR doesn't exist in the cluster
+		s, _ := liveState.(string)
+		if s == "" || s == "null" {
+			return nil, nil
+		}
+		var cr map[string]any
+		if err := json.Unmarshal([]byte(s), &cr); err != nil {
+			return nil, fmt.Errorf("failed to parse the liveState of %s %v: %w", kind, entry["name"], err)
+		}
+		return inventoryObjects(cr)
+	}
+	if kind == "ManagedClusterView" {
+		result, _, _ := NestedField(entry, "status", "result")
+		cr, ok := result.(map[string]any)
+		if !ok {
+			return nil, nil
+		}
+		return inventoryObjects(cr)
+	}
+	if items, ok := entry["items"].([]any); ok {
+		var result []map[string]any
+		for i, item := range items {
+			cr, ok := item.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("item %d of %s isn't an object", i, listName(kind))
+			}
+			setTypedListItemKind(entry, cr)
+			crs, err := inventoryObjects(cr)
+			if err != nil {
+				return nil, fmt.Errorf("item %d of %s: %w", i, listName(kind), err)
+			}
+			result = append(result, crs...)
+		}
+		return result, nil
+	}
+	if object, ok := entry["object"].(map[string]any); ok && kind == "" {
+		return inventoryObjects(object)
+	}
+	if _, ok := entry["apiVersion"]; ok && kind != "" {
+		return []map[string]any{entry}, nil
+	}
+	return nil, errors.New("entry is neither a CR nor a known inventory entry")
+}
+
+func listName(kind string) string {
+	if kind == "" {
+		return "the list"
+	}
+	return kind
+}
@@ -0,0 +1,74 @@
+package compare
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/yaml"
+)
+
+func TestInventoryCRs(t *testing.T) {
+	cases := []struct {
+		name        string
+		content     string
+		expected    []string
+		expectError string
+	}{
+		{
+			name:     "json array of crs and wrapped crs",
+			content:  `[{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"a"}},{"object":{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"b"}}}]`,
+			expected: []string{"a", "b"},
+		},
+		{
+			name: "yaml documents",
+			content: `apiVersion: v1
+kind: List
+items:
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: a
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: b
+`,
+			expected: []string{"a", "b"},
+		},
+		{
+			name:     "managed cluster view without result",
+			content:  `{"apiVersion":"view.open-cluster-management.io/v1beta1","kind":"ManagedClusterView","metadata":{"name":"a"}}`,
+			expected: nil,
+		},
+		{
+			name:        "invalid live state",
+			content:     `{"items":[{"kind":"ConfigMap","name":"a","liveState":"{"}]}`,
+			expectError: "item 0 of the list: failed to parse the liveState of ConfigMap a",
+		},
+		{
+			name:        "unknown entry",
+			content:     `{"items":[{"name":"a"}]}`,
+			expectError: "item 0 of the list: entry is neither a CR nor a known inventory entry",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			content, err := inventoryCRs([]byte(c.content))
+			if c.expectError != "" {
+				require.ErrorContains(t, err, c.expectError)
+				return
+			}
+			require.NoError(t, err)
+			var names []string
+			for _, doc := range strings.Split(string(content), "---\n")[1:] {
+				var cr map[string]any
+				require.NoError(t, yaml.Unmarshal([]byte(doc), &cr))
+				name, _, _ := NestedString(cr, "metadata", "name")
+				names = append(names, name)
+			}
+			require.Equal(t, c.expected, names)
+		})
+	}
+}
@@ -0,0 +1,251 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+	kcmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"k8s.io/kubectl/pkg/util/i18n"
+	"k8s.io/kubectl/pkg/util/templates"
+	"sigs.k8s.io/yaml"
+)
+
+var (
+	jobLong = templates.LongDesc(`
+		Generate the manifests to run the comparison inside the cluster as a Job, or a CronJob with --schedule.
+
+		When no kubeconfig is found the comparison uses the in-cluster configuration: the token of the service account
+		of the pod it runs in. The job command writes a service account with read only access to all the resources of
+		the cluster, the job running the comparison with it and, when the reference is a local file, a config map
+		holding the bundle of the reference (see the bundle command). A reference served over http is passed as is.
+
+		The args after -- are passed to the comparison, e.g. to select the output format. The results of the
+		comparison are in the logs of the pods of the job, and the job fails when the comparison exits with a non-zero
+		exit code.
+	`)
+
+	jobExample = templates.Examples(`
+		# Generate a job comparing the cluster to a local reference and run it:
+		kubectl cluster-compare job -r ./reference/metadata.yaml --image quay.io/example/kube-compare:latest | kubectl apply -f -
+
+		# Compare the cluster to a reference served over http every day at 2am, with a JSON output:
+		kubectl cluster-compare job -r https://example.com/ref.tgz --image quay.io/example/kube-compare:latest --schedule "0 2 * * *" -- -o json
+	`)
+)
+
+const (
+	jobReferenceDir      = "/reference"
+	jobReferenceFileName = "reference.tgz"
+	// maxConfigMapSize is the maximum size of the data of a config map accepted by the API server
+	maxConfigMapSize = 1024 * 1024
+)
+
+type JobOptions struct {
+	referenceConfig string
+	image           string
+	command         string
+	name            string
+	namespace       string
+	schedule        string
+	compareArgs     []string
+
+	genericiooptions.IOStreams
+}
+
+func NewJobCmd(streams genericiooptions.IOStreams) *cobra.Command {
+	options := &JobOptions{IOStreams: streams}
+
+	cmd := &cobra.Command{
+		Use:                   "job -r <Reference File> --image <Image> [--schedule <Cron Schedule>] [-- <Comparison Args>]",
+		DisableFlagsInUseLine: true,
+		Short:                 i18n.T("Generate the manifests to run the comparison inside the cluster."),
+		Long:                  jobLong,
+		Example:               exampleForBinary(jobExample),
+		Run: func(cmd *cobra.Command, args []string) {
+			kcmdutil.CheckDiffErr(options.Complete(cmd, args))
+			kcmdutil.CheckDiffErr(options.Run())
+		},
+	}
+	cmd.SetFlagErrorFunc(func(command *cobra.Command, err error) error {
+		kcmdutil.CheckDiffErr(kcmdutil.UsageErrorf(cmd, err.Error()))
+		return nil
+	})
+	cmd.Flags().StringVarP(&options.referenceConfig, "reference", "r", "", "Path to reference config file or bundle, or its URL.")
+	cmd.Flags().StringVar(&options.image, "image", "", "Image holding the comparison binary to run in the job.")
+	cmd.Flags().StringVar(&options.command, "command", "kube-compare", "Path of the comparison binary in the image.")
+	cmd.Flags().StringVar(&options.name, "name", "cluster-compare", "Name of the job and of the resources it uses.")
+	cmd.Flags().StringVar(&options.namespace, "job-namespace", "cluster-compare", "Namespace the job runs in, created by the manifests.")
+	cmd.Flags().StringVar(&options.schedule, "schedule", "",
+		"Cron schedule of the comparison (e.g. \"0 2 * * *\"), a CronJob is generated instead of a Job when it's set.")
+	return cmd
+}
+
+func (o *JobOptions) Complete(cmd *cobra.Command, args []string) error {
+	if dash := cmd.ArgsLenAtDash(); dash != 0 && len(args) > 0 {
+		return kcmdutil.UsageErrorf(cmd, "Unexpected args: %v, the args of the comparison must follow --", args)
+	}
+	o.compareArgs = args
+	if o.referenceConfig == "" {
+		return kcmdutil.UsageErrorf(cmd, noRefFileWasPassed)
+	}
+	if _, err := os.Stat(o.referenceConfig); os.IsNotExist(err) && !isURL(o.referenceConfig) {
+		return errors.New(refFileNotExistsError)
+	}
+	if o.image == "" {
+		return kcmdutil.UsageErrorf(cmd, "--image is required")
+	}
+	if errs := validation.IsDNS1123Label(o.name); len(errs) > 0 {
+		return kcmdutil.UsageErrorf(cmd, "Invalid --name %q: %s", o.name, errs[0])
+	}
+	if errs := validation.IsDNS1123Label(o.namespace); len(errs) > 0 {
+		return kcmdutil.UsageErrorf(cmd, "Invalid --job-namespace %q: %s", o.namespace, errs[0])
+	}
+	return nil
+}
+
+// Run writes the manifests of the job
+func (o *JobOptions) Run() error {
+	manifests, err := o.manifests()
+	if err != nil {
+		return err
+	}
+	for _, m := range manifests {
+		content, err := yaml.Marshal(m)
+		if err != nil {
+			return fmt.Errorf("failed to write the manifests: %w", err)
+		}
+		if _, err := fmt.Fprintf(o.Out, "---\n%s", content); err != nil {
+			return fmt.Errorf("error occurred when writing output: %w", err)
+		}
+	}
+	return nil
+}
+
+// manifests returns the resources running the comparison in the cluster
+func (o *JobOptions) manifests() ([]map[string]any, error) {
+	metadata := func(namespaced bool) map[string]any {
+		m := map[string]any{
+			"name":   o.name,
+			"labels": map[string]any{"app.kubernetes.io/name": o.name},
+		}
+		if namespaced {
+			m["namespace"] = o.namespace
+		}
+		return m
+	}
+
+	manifests := []map[string]any{
+		{
+			"apiVersion": "v1",
+			"kind":       "Namespace",
+			"metadata":   map[string]any{"name": o.namespace},
+		},
+		{
+			"apiVersion": "v1",
+			"kind":       "ServiceAccount",
+			"metadata":   metadata(true),
+		},
+		{
+			"apiVersion": "rbac.authorization.k8s.io/v1",
+			"kind":       "ClusterRole",
+			"metadata":   metadata(false),
+			"rules": []any{
+				map[string]any{"apiGroups": []any{"*"}, "resources": []any{"*"}, "verbs": []any{"get", "list"}},
+			},
+		},
+		{
+			"apiVersion": "rbac.authorization.k8s.io/v1",
+			"kind":       "ClusterRoleBinding",
+			"metadata":   metadata(false),
+			"roleRef":    map[string]any{"apiGroup": "rbac.authorization.k8s.io", "kind": "ClusterRole", "name": o.name},
+			"subjects": []any{
+				map[string]any{"kind": "ServiceAccount", "name": o.name, "namespace": o.namespace},
+			},
+		},
+	}
+
+	reference := o.referenceConfig
+	volumes := []any{map[string]any{"name": "tmp", "emptyDir": map[string]any{}}}
+	mounts := []any{map[string]any{"name": "tmp", "mountPath": "/tmp"}}
+	if !isURL(o.referenceConfig) {
+		bundle, _, err := bundleReference(o.referenceConfig)
+		if err != nil {
+			return nil, err
+		}
+		if len(bundle) > maxConfigMapSize {
+			return nil, fmt.Errorf("the bundle of the reference is %d bytes, more than a config map can hold: serve the bundle over http and pass its URL instead", len(bundle))
+		}
+		manifests = append(manifests, map[string]any{
+			"apiVersion": "v1",
+			"kind":       "ConfigMap",
+			"metadata":   metadata(true),
+			"binaryData": map[string]any{jobReferenceFileName: base64.StdEncoding.EncodeToString(bundle)},
+		})
+		reference = path.Join(jobReferenceDir, jobReferenceFileName)
+		volumes = append(volumes, map[string]any{"name": "reference", "configMap": map[string]any{"name": o.name}})
+		mounts = append(mounts, map[string]any{"name": "reference", "mountPath": jobReferenceDir, "readOnly": true})
+	}
+
+	args := []any{"-r", reference}
+	for _, a := range o.compareArgs {
+		args = append(args, a)
+	}
+	podSpec := map[string]any{
+		"serviceAccountName": o.name,
+		"restartPolicy":      "Never",
+		"securityContext": map[string]any{
+			"seccompProfile": map[string]any{"type": "RuntimeDefault"},
+		},
+		"containers": []any{
+			map[string]any{
+				"name":    "compare",
+				"image":   o.image,
+				"command": []any{o.command},
+				"args":    args,
+				// The discovery cache of the comparison is written in the home directory
+				"env": []any{map[string]any{"name": "HOME", "value": "/tmp"}},
+				"securityContext": map[string]any{
+					"allowPrivilegeEscalation": false,
+					"readOnlyRootFilesystem":   true,
+					"capabilities":             map[string]any{"drop": []any{"ALL"}},
+				},
+				"volumeMounts": mounts,
+			},
+		},
+		"volumes": volumes,
+	}
+	// Diffs make the comparison exit with a non-zero code, retrying wouldn't change the result
+	jobSpec := map[string]any{
+		"backoffLimit": int64(0),
+		"template": map[string]any{
+			"metadata": map[string]any{"labels": map[string]any{"app.kubernetes.io/name": o.name}},
+			"spec":     podSpec,
+		},
+	}
+	if o.schedule == "" {
+		return append(manifests, map[string]any{
+			"apiVersion": "batch/v1",
+			"kind":       "Job",
+			"metadata":   metadata(true),
+			"spec":       jobSpec,
+		}), nil
+	}
+	return append(manifests, map[string]any{
+		"apiVersion": "batch/v1",
+		"kind":       "CronJob",
+		"metadata":   metadata(true),
+		"spec": map[string]any{
+			"schedule":          o.schedule,
+			"concurrencyPolicy": "Forbid",
+			"jobTemplate":       map[string]any{"spec": jobSpec},
+		},
+	}), nil
+}
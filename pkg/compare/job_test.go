@@ -0,0 +1,67 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestJobManifests(t *testing.T) {
+	o := JobOptions{
+		referenceConfig: "testdata/ExitCodes/reference/metadata.yaml",
+		image:           "quay.io/example/kube-compare:latest",
+		command:         "kube-compare",
+		name:            "compare",
+		namespace:       "compliance",
+		compareArgs:     []string{"-o", "json"},
+	}
+	manifests, err := o.manifests()
+	require.NoError(t, err)
+	var kinds []string
+	for _, m := range manifests {
+		kinds = append(kinds, m["kind"].(string))
+	}
+	require.Equal(t, []string{"Namespace", "ServiceAccount", "ClusterRole", "ClusterRoleBinding", "ConfigMap", "Job"}, kinds)
+
+	encoded, _, _ := NestedString(manifests[4], "binaryData", jobReferenceFileName)
+	bundle, err := base64.StdEncoding.DecodeString(encoded)
+	require.NoError(t, err)
+	bfs, err := readBundle(bytes.NewReader(bundle))
+	require.NoError(t, err)
+	require.Contains(t, bfs, "settings.yaml")
+
+	containers, _, _ := unstructured.NestedSlice(manifests[5], "spec", "template", "spec", "containers")
+	require.Len(t, containers, 1)
+	container := containers[0].(map[string]any)
+	require.Equal(t, []any{"-r", "/reference/reference.tgz", "-o", "json"}, container["args"])
+	require.Equal(t, []any{"kube-compare"}, container["command"])
+	serviceAccount, _, _ := NestedString(manifests[5], "spec", "template", "spec", "serviceAccountName")
+	require.Equal(t, "compare", serviceAccount)
+}
+
+func TestCronJobManifestsWithURLReference(t *testing.T) {
+	o := JobOptions{
+		referenceConfig: "https://example.com/ref.tgz",
+		image:           "quay.io/example/kube-compare:latest",
+		command:         "kube-compare",
+		name:            "compare",
+		namespace:       "compliance",
+		schedule:        "0 2 * * *",
+	}
+	manifests, err := o.manifests()
+	require.NoError(t, err)
+	cronJob := manifests[len(manifests)-1]
+	require.Equal(t, "CronJob", cronJob["kind"])
+	for _, m := range manifests {
+		require.NotEqual(t, "ConfigMap", m["kind"], "a reference served over http isn't stored in a config map")
+	}
+	schedule, _, _ := NestedString(cronJob, "spec", "schedule")
+	require.Equal(t, "0 2 * * *", schedule)
+	containers, _, _ := unstructured.NestedSlice(cronJob, "spec", "jobTemplate", "spec", "template", "spec", "containers")
+	require.Equal(t, []any{"-r", "https://example.com/ref.tgz"}, containers[0].(map[string]any)["args"])
+}
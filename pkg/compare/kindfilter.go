@@ -0,0 +1,42 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"slices"
+	"strings"
+)
+
+const noTemplatesAfterKindFilter = "no templates in the reference are of kinds included by --include-kind and --exclude-kind"
+
+// kindFilter limits a run to a subset of the kinds in the reference, kinds are compared case-insensitively
+type kindFilter struct {
+	include []string
+	exclude []string
+}
+
+func (f kindFilter) isSet() bool {
+	return len(f.include) > 0 || len(f.exclude) > 0
+}
+
+func (f kindFilter) includes(kind string) bool {
+	matches := func(k string) bool { return strings.EqualFold(k, kind) }
+	if len(f.include) > 0 && !slices.ContainsFunc(f.include, matches) {
+		return false
+	}
+	return !slices.ContainsFunc(f.exclude, matches)
+}
+
+// filterTemplates returns the templates of included kinds and the paths of the templates that were filtered out
+func (f kindFilter) filterTemplates(templates []ReferenceTemplate) ([]ReferenceTemplate, map[string]bool) {
+	included := make([]ReferenceTemplate, 0, len(templates))
+	excluded := make(map[string]bool)
+	for _, t := range templates {
+		if f.includes(t.GetMetadata().GetKind()) {
+			included = append(included, t)
+		} else {
+			excluded[t.GetPath()] = true
+		}
+	}
+	return included, excluded
+}
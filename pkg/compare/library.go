@@ -0,0 +1,156 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"time"
+
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+	kcmdutil "k8s.io/kubectl/pkg/cmd/util"
+)
+
+// CompareRequest configures a comparison run by a program embedding the comparison engine, e.g. a controller or a CI
+// tool. Fields left empty take the defaults of the command line flags of the same name.
+type CompareRequest struct {
+	// Reference is the path or URL of the reference config file (or bundle), the -r flag
+	Reference string
+	// Factory creates the clients for the cluster compared in live mode, and the builder of the local CRs
+	Factory kcmdutil.Factory
+	// Filenames are the local CR files, directories, archives or URLs compared instead of the cluster, the -f flag.
+	// The CRs can't be read from stdin.
+	Filenames []string
+	// Recursive processes the directories of Filenames recursively
+	Recursive bool
+	// InputFormat is the format of the files of Filenames, one of InputFormats
+	InputFormat string
+	// DiffConfig is the path to the user config file, the -c flag
+	DiffConfig string
+	// Overrides is the path to the user overrides, the -p flag
+	Overrides string
+	// AllResources matches all the resources of the types of the reference, the -A flag
+	AllResources bool
+	// DiffEngine is the engine used to diff the CRs, one of DiffEngines
+	DiffEngine string
+	// DiffStyle is the style of the diffs of the external engine, one of DiffStyles
+	DiffStyle string
+	// Concurrency is the number of CRs diffed in parallel
+	Concurrency int
+	// QPS and Burst are the client-side rate limit of the requests sent to the API server, the --qps and --burst flags
+	QPS   float32
+	Burst int
+	// ChunkSize is the number of objects requested per page when listing the resources of the cluster, the
+	// --chunk-size flag. Negative values list them in a single request.
+	ChunkSize int64
+	// MaxDiffs stops the comparison once this number of CRs with diffs were found, the --max-diffs flag. The summary
+	// of the result is then partial.
+	MaxDiffs int
+	// TemplateTimeout is the maximum time to render a template for a CR, the --template-timeout flag
+	TemplateTimeout time.Duration
+	// OnTemplateError is what to do when a template fails to render, one of TemplateErrorPolicies
+	OnTemplateError string
+	// IncludeKinds and ExcludeKinds limit the comparison to some kinds, the --include-kind and --exclude-kind flags
+	IncludeKinds []string
+	ExcludeKinds []string
+	// Components and SkipComponents limit the comparison to some components, the --components and --skip-components
+	// flags
+	Components     []string
+	SkipComponents []string
+	// OnlyPaths and IgnorePaths limit the compared fields of the CRs, the --only-path and --ignore-path flags
+	OnlyPaths   []string
+	IgnorePaths []string
+	// FieldSelectors limit the listed resources of some kinds, in the form of <kind>:<selector>, the --field-selector
+	// flag
+	FieldSelectors []string
+	// RunCache is the path of the file the results of the compared CRs are recorded to, the --run-cache flag
+	RunCache string
+	// ChangedOnly reuses the results recorded in RunCache for the CRs that didn't change, the --changed-only flag
+	ChangedOnly bool
+	// Correlators are added to the correlation chain, see Options.WithCorrelators
+	Correlators []CorrelatorFactory
+	// ErrOut receives the errors of the external diff program, they're discarded if not set
+	ErrOut io.Writer
+}
+
+// Result is the result of a comparison run by a program embedding the comparison engine
+type Result struct {
+	Summary *Summary
+	Diffs   []DiffSum
+}
+
+// HasDiffs checks if differences were found between the cluster CRs and the reference: CRs with diffs, validation
+// issues (e.g. missing CRs), operator version drift or kinds that couldn't be fetched
+func (r *Result) HasDiffs() bool {
+	return r.Summary.hasDiffs()
+}
+
+// Compare compares the cluster (or the local CRs) to the reference and returns the summary and the diffs of the CRs
+// without printing them. The command line and Compare share the same validation and defaults. When the context is done
+// before all the CRs were compared, the result holds a partial summary of the CRs compared until then and the returned
+// error wraps the cause of the cancellation.
+func Compare(ctx context.Context, req CompareRequest) (*Result, error) {
+	if req.Factory == nil {
+		return nil, errors.New("a factory is required to compare")
+	}
+	if slices.Contains(req.Filenames, stdinFilename) {
+		return nil, errors.New("CRs can't be read from stdin when comparing programmatically")
+	}
+	errOut := req.ErrOut
+	if errOut == nil {
+		errOut = io.Discard
+	}
+	o := NewOptions(genericiooptions.IOStreams{In: &bytes.Buffer{}, Out: io.Discard, ErrOut: errOut}).WithCorrelators(req.Correlators...)
+	o.Progress = ProgressNever
+	o.referenceConfigs = []string{req.Reference}
+	o.CRs.Filenames = req.Filenames
+	o.CRs.Recursive = req.Recursive
+	o.diffConfigFileName = req.DiffConfig
+	o.userOverridesPath = req.Overrides
+	o.diffAll = req.AllResources
+	o.kinds = kindFilter{include: req.IncludeKinds, exclude: req.ExcludeKinds}
+	o.components = componentFilter{include: req.Components, exclude: req.SkipComponents}
+	o.paths = pathFilter{only: req.OnlyPaths, ignore: req.IgnorePaths}
+	o.fieldSelectors = fieldSelectors{flags: req.FieldSelectors}
+	o.runCachePath = req.RunCache
+	o.changedOnly = req.ChangedOnly
+	if req.InputFormat != "" {
+		o.inputFormat = req.InputFormat
+	}
+	if req.DiffEngine != "" {
+		o.diffEngine = req.DiffEngine
+	}
+	if req.DiffStyle != "" {
+		o.diffStyle = req.DiffStyle
+	}
+	if req.TemplateTimeout != 0 {
+		o.templateTimeout = req.TemplateTimeout
+	}
+	if req.OnTemplateError != "" {
+		o.onTemplateError = req.OnTemplateError
+	}
+	if req.Concurrency != 0 {
+		o.Concurrency = req.Concurrency
+	}
+	o.maxDiffs = req.MaxDiffs
+	o.qps = req.QPS
+	o.burst = req.Burst
+	switch {
+	case req.ChunkSize > 0:
+		o.chunkSize = req.ChunkSize
+	case req.ChunkSize < 0:
+		o.chunkSize = 0
+	}
+
+	if err := o.setup(req.Factory); err != nil {
+		return nil, err
+	}
+	sum, diffs, err := o.compare(ctx)
+	if sum == nil {
+		return nil, err
+	}
+	return &Result{Summary: sum, Diffs: diffs}, err
+}
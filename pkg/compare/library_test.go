@@ -0,0 +1,67 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"context"
+	"path"
+	"testing"
+
+	"github.com/openshift/kube-compare/pkg/api"
+	"github.com/stretchr/testify/require"
+	cmdtesting "k8s.io/kubectl/pkg/cmd/testing"
+)
+
+func TestCompare(t *testing.T) {
+	tf := cmdtesting.NewTestFactory()
+	defer tf.Cleanup()
+	req := CompareRequest{
+		Reference:  path.Join(TestDirs, "SomeDiffs", TestRefDirName, defaultReferenceFilename),
+		Factory:    tf,
+		Filenames:  []string{path.Join(TestDirs, "SomeDiffs", ResourceDirName)},
+		Recursive:  true,
+		DiffEngine: DiffEngineInternal,
+	}
+
+	result, err := Compare(context.Background(), req)
+	require.NoError(t, err)
+	require.True(t, result.HasDiffs())
+	require.Equal(t, result.Summary.TotalCRs, len(result.Diffs))
+	diffs := 0
+	for _, d := range result.Diffs {
+		if d.DiffOutput != "" {
+			diffs++
+		}
+	}
+	require.Equal(t, result.Summary.NumDiffCRs, diffs)
+
+	apiResult := result.ComparisonResult()
+	require.Equal(t, api.APIVersion, apiResult.APIVersion)
+	require.Equal(t, result.Summary.TotalCRs, apiResult.Summary.TotalResources)
+	require.Len(t, apiResult.Resources, len(result.Diffs))
+	withDiffs := 0
+	for _, r := range apiResult.Resources {
+		if r.HasDiff() {
+			withDiffs++
+			require.Equal(t, api.SeverityWarning, r.Severity)
+		}
+	}
+	require.Equal(t, result.Summary.NumDiffCRs, withDiffs)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	result, err = Compare(ctx, req)
+	require.ErrorIs(t, err, context.Canceled)
+	require.NotNil(t, result, "a partial result should be returned when the comparison is interrupted")
+	require.Equal(t, context.Canceled.Error(), result.Summary.Interrupted)
+
+	_, err = Compare(context.Background(), CompareRequest{Factory: tf})
+	require.Error(t, err, "the reference is required")
+	_, err = Compare(context.Background(), CompareRequest{Reference: req.Reference, Factory: tf, Filenames: []string{"-"}})
+	require.Error(t, err, "CRs can't be read from stdin")
+	_, err = Compare(context.Background(), CompareRequest{Reference: req.Reference})
+	require.Error(t, err, "a factory is required")
+	_, err = Compare(context.Background(), CompareRequest{Reference: req.Reference, Factory: tf, DiffEngine: "colordiff"})
+	require.EqualError(t, err, `Invalid diff engine "colordiff", must be one of: external, internal`,
+		"invalid options shouldn't be reported with the help of the command")
+}
@@ -0,0 +1,560 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+	kcmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"k8s.io/kubectl/pkg/util/i18n"
+	"k8s.io/kubectl/pkg/util/templates"
+	"k8s.io/utils/exec"
+	"sigs.k8s.io/yaml"
+)
+
+var (
+	lintLong = templates.LongDesc(`
+		Statically validate a reference configuration without comparing it to a cluster.
+
+		The lint command loads the reference and reports every problem it can find instead of stopping at the first one:
+		templates that don't render or aren't valid YAML when executed with empty input, templates that the group
+		correlator can't tell apart, template function files that are never used, fieldsToOmit references that don't
+		exist and components that don't reference any templates (or reference the same template twice).
+
+		In addition the templates are checked for missing apiVersion or metadata.name, index calls on fields that
+		aren't guarded by an if or with, use of discouraged functions, fields that are both templated and omitted by
+		fieldsToOmit and content that is unreachable because its condition is constant.
+
+		With --schemas the templates rendered with empty input are validated against the schemas of their kinds from a
+		schema bundle of CRDs and OpenAPI v3 documents, fields the schemas don't declare are reported.
+
+		Checks can be disabled or have their severity changed with a lint config file (--lint-config). Only issues
+		with the error severity fail the lint.
+
+		Exit status: 0 No errors were found. 1 Errors were found. >1 The reference couldn't be linted.
+	`)
+
+	lintExample = templates.Examples(`
+		# Lint a reference configuration:
+		kubectl cluster-compare lint -r ./reference/metadata.yaml
+
+		# Lint a reference configuration with a lint config and print the issues as json:
+		kubectl cluster-compare lint -r ./reference/metadata.yaml --lint-config ./lint.yaml -o json
+
+		# Lint a reference configuration and validate its templates against the CRDs of a schema bundle:
+		kubectl cluster-compare lint -r ./reference/metadata.yaml --schemas ./crds
+	`)
+)
+
+const (
+	LintIssuesFoundMsg = "there are issues in the reference"
+
+	lintCheckReference   = "reference"
+	lintCheckTemplate    = "template"
+	lintCheckCorrelation = "correlation"
+	lintCheckFunctions   = "template-function-files"
+	lintCheckComponents  = "components"
+
+	lintCheckMissingMetadata     = "missing-metadata"
+	lintCheckNilDereference      = "unguarded-nil-dereference"
+	lintCheckDiscouragedFuncs    = "discouraged-functions"
+	lintCheckTemplatedAndOmitted = "templated-and-omitted"
+	lintCheckUnreachableContent  = "unreachable-content"
+	lintCheckSchema              = "schema"
+)
+
+const (
+	LintSeverityError   = "error"
+	LintSeverityWarning = "warning"
+)
+
+// lintChecks maps every check to its default severity
+var lintChecks = map[string]string{
+	lintCheckReference:           LintSeverityError,
+	lintCheckTemplate:            LintSeverityError,
+	lintCheckCorrelation:         LintSeverityError,
+	lintCheckFunctions:           LintSeverityError,
+	lintCheckComponents:          LintSeverityError,
+	lintCheckMissingMetadata:     LintSeverityError,
+	lintCheckNilDereference:      LintSeverityWarning,
+	lintCheckDiscouragedFuncs:    LintSeverityWarning,
+	lintCheckTemplatedAndOmitted: LintSeverityWarning,
+	lintCheckUnreachableContent:  LintSeverityWarning,
+	lintCheckSchema:              LintSeverityError,
+}
+
+// defaultDiscouragedFuncs are functions whose output isn't deterministic, templates using them render differently on
+// every run
+var defaultDiscouragedFuncs = []string{
+	"now", "randAlpha", "randAlphaNum", "randAscii", "randNumeric", "randInt", "randBytes", "shuffle", "uuidv4",
+	"genPrivateKey", "genCA", "genSelfSignedCert", "genSignedCert", "getHostByName",
+}
+
+// LintIssue is a single problem found in the reference by the lint command.
+type LintIssue struct {
+	Check    string `json:"check"`
+	Severity string `json:"severity"`
+	Template string `json:"template,omitempty"`
+	Message  string `json:"message"`
+}
+
+func (i LintIssue) String() string {
+	prefix := i.Check
+	if i.Severity == LintSeverityWarning {
+		prefix += " (warning)"
+	}
+	if i.Template != "" {
+		prefix += ": " + i.Template
+	}
+	return fmt.Sprintf("%s: %s", prefix, i.Message)
+}
+
+// LintResult contains all the issues found in the reference, it's the output of the lint command in json and yaml
+// formats
+type LintResult struct {
+	Issues      []LintIssue `json:"issues"`
+	NumErrors   int         `json:"numErrors"`
+	NumWarnings int         `json:"numWarnings"`
+}
+
+// LintConfig configures the checks run by the lint command, checks that aren't configured run with their default
+// severity
+type LintConfig struct {
+	Checks map[string]LintCheckConfig `json:"checks,omitempty"`
+}
+
+type LintCheckConfig struct {
+	Disabled bool   `json:"disabled,omitempty"`
+	Severity string `json:"severity,omitempty"`
+	// Functions replaces the default list of functions reported by the discouraged-functions check
+	Functions []string `json:"functions,omitempty"`
+}
+
+func (c *LintConfig) validate() error {
+	var errs []error
+	names := make([]string, 0, len(c.Checks))
+	for name := range c.Checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		check := c.Checks[name]
+		if _, ok := lintChecks[name]; !ok {
+			errs = append(errs, fmt.Errorf("unknown check %q", name))
+		}
+		if check.Severity != "" && check.Severity != LintSeverityError && check.Severity != LintSeverityWarning {
+			errs = append(errs, fmt.Errorf("invalid severity %q for check %s, must be one of: %s, %s",
+				check.Severity, name, LintSeverityError, LintSeverityWarning))
+		}
+	}
+	return errors.Join(errs...) // nolint:wrapcheck
+}
+
+func (c *LintConfig) severity(check string) string {
+	if c != nil && c.Checks[check].Severity != "" {
+		return c.Checks[check].Severity
+	}
+	return lintChecks[check]
+}
+
+func (c *LintConfig) disabled(check string) bool {
+	return c != nil && c.Checks[check].Disabled
+}
+
+func (c *LintConfig) discouragedFuncs() []string {
+	if c != nil && c.Checks[lintCheckDiscouragedFuncs].Functions != nil {
+		return c.Checks[lintCheckDiscouragedFuncs].Functions
+	}
+	return defaultDiscouragedFuncs
+}
+
+type LintOptions struct {
+	referenceConfig string
+	configPath      string
+	schemasPath     string
+	OutputFormat    string
+	config          *LintConfig
+	schemas         *schemaSet
+
+	genericiooptions.IOStreams
+}
+
+func NewLintCmd(streams genericiooptions.IOStreams) *cobra.Command {
+	options := &LintOptions{IOStreams: streams}
+
+	cmd := &cobra.Command{
+		Use:                   "lint -r <Reference File>",
+		DisableFlagsInUseLine: true,
+		Short:                 i18n.T("Validate a reference configuration."),
+		Long:                  lintLong,
+		Example:               exampleForBinary(lintExample),
+		Run: func(cmd *cobra.Command, args []string) {
+			kcmdutil.CheckDiffErr(options.Complete(cmd, args))
+			if err := options.Run(); err != nil {
+				if exitErr := diffError(err); exitErr != nil {
+					kcmdutil.CheckErr(kcmdutil.ErrExit)
+				}
+				kcmdutil.CheckDiffErr(err)
+			}
+		},
+	}
+	cmd.SetFlagErrorFunc(func(command *cobra.Command, err error) error {
+		kcmdutil.CheckDiffErr(kcmdutil.UsageErrorf(cmd, err.Error()))
+		return nil
+	})
+	cmd.Flags().StringVarP(&options.referenceConfig, "reference", "r", "", "Path to reference config file.")
+	cmd.Flags().StringVar(&options.configPath, "lint-config", "", "Path to a lint config file disabling checks or changing their severity")
+	cmd.Flags().StringVar(&options.schemasPath, "schemas", "",
+		"Path to a schema bundle, a file or directory of CRDs and OpenAPI v3 documents, to validate the templates against")
+	cmd.Flags().StringVarP(&options.OutputFormat, "output", "o", "", fmt.Sprintf(`Output format. One of: (%s, %s)`, Json, Yaml))
+	return cmd
+}
+
+func (o *LintOptions) Complete(cmd *cobra.Command, args []string) error {
+	if len(args) != 0 {
+		return kcmdutil.UsageErrorf(cmd, "Unexpected args: %v", args)
+	}
+	if o.referenceConfig == "" {
+		return kcmdutil.UsageErrorf(cmd, noRefFileWasPassed)
+	}
+	if _, err := os.Stat(o.referenceConfig); os.IsNotExist(err) && !isURL(o.referenceConfig) {
+		return errors.New(refFileNotExistsError)
+	}
+	if o.OutputFormat != "" && o.OutputFormat != Json && o.OutputFormat != Yaml {
+		return kcmdutil.UsageErrorf(cmd, "Invalid output format %q, must be one of: %s, %s", o.OutputFormat, Json, Yaml)
+	}
+	if o.configPath != "" {
+		content, err := os.ReadFile(o.configPath)
+		if err != nil {
+			return fmt.Errorf("failed to read lint config: %w", err)
+		}
+		o.config = &LintConfig{}
+		if err := yaml.UnmarshalStrict(content, o.config); err != nil {
+			return fmt.Errorf("failed to parse lint config: %w", err)
+		}
+		if err := o.config.validate(); err != nil {
+			return fmt.Errorf("invalid lint config: %w", err)
+		}
+	}
+	if o.schemasPath != "" {
+		schemas, err := loadSchemaBundle(o.schemasPath)
+		if err != nil {
+			return err
+		}
+		o.schemas = schemas
+	}
+	return nil
+}
+
+// Run lints the reference and prints the found issues, in case errors were found an exit error with code 1 is returned.
+func (o *LintOptions) Run() error {
+	cfs, err := GetRefFS(o.referenceConfig)
+	if err != nil {
+		return err
+	}
+	result := LintResult{Issues: lintReference(newSopsFS(cfs), ReferenceFileName(o.referenceConfig), o.config, o.schemas)}
+	for _, issue := range result.Issues {
+		if issue.Severity == LintSeverityError {
+			result.NumErrors++
+		} else {
+			result.NumWarnings++
+		}
+	}
+
+	if err := o.print(result); err != nil {
+		return fmt.Errorf("error occurred when writing output: %w", err)
+	}
+	if result.NumErrors > 0 {
+		return exec.CodeExitError{Err: errors.New(LintIssuesFoundMsg), Code: 1}
+	}
+	return nil
+}
+
+func (o *LintOptions) print(result LintResult) error {
+	var content []byte
+	var err error
+	switch o.OutputFormat {
+	case Json:
+		content, err = json.Marshal(result)
+		content = append(content, '\n')
+	case Yaml:
+		content, err = yaml.Marshal(result)
+	default:
+		var sb strings.Builder
+		for _, issue := range result.Issues {
+			sb.WriteString(issue.String() + "\n")
+		}
+		if len(result.Issues) > 0 {
+			fmt.Fprintf(&sb, "Found %d error(s) and %d warning(s) in the reference\n", result.NumErrors, result.NumWarnings)
+		} else {
+			sb.WriteString("No issues found in the reference\n")
+		}
+		content = []byte(sb.String())
+	}
+	if err != nil {
+		return err // nolint:wrapcheck
+	}
+	_, err = o.Out.Write(content)
+	return err // nolint:wrapcheck
+}
+
+// LintReference loads the reference from the file system and returns all the issues found in it. The config may be
+// nil, in which case all the checks run with their default severity.
+func LintReference(fsys fs.FS, referenceFileName string, config *LintConfig) []LintIssue {
+	return lintReference(fsys, referenceFileName, config, nil)
+}
+
+// lintReference lints the reference, when schemas are given the rendered templates are validated against them
+func lintReference(fsys fs.FS, referenceFileName string, config *LintConfig, schemas *schemaSet) []LintIssue {
+	ref, err := GetReference(fsys, referenceFileName)
+	if err != nil {
+		return configureIssues(issuesFromError(lintCheckReference, err), config)
+	}
+
+	var issues []LintIssue
+	temps, err := ParseTemplates(ref, fsys)
+	if err != nil {
+		issues = append(issues, issuesFromError(lintCheckTemplate, err)...)
+	}
+
+	parsed := make([]ReferenceTemplate, 0, len(temps))
+	for _, temp := range temps {
+		if temp.GetMetadata() != nil {
+			parsed = append(parsed, temp)
+		}
+	}
+
+	groupCorrelator, err := NewGroupCorrelator(defaultFieldGroups, parsed)
+	if err != nil {
+		issues = append(issues, issuesFromError(lintCheckCorrelation, err)...)
+	} else if err := groupCorrelator.ValidateTemplates(); err != nil {
+		issues = append(issues, issuesFromError(lintCheckCorrelation, err)...)
+	}
+
+	issues = append(issues, lintFunctionFiles(fsys, ref.GetTemplateFunctionFiles(), parsed)...)
+	issues = append(issues, lintComponents(ref)...)
+	issues = append(issues, lintTemplates(fsys, ref, temps, config.discouragedFuncs())...)
+	issues = append(issues, lintSchemas(parsed, schemas)...)
+
+	issues = configureIssues(issues, config)
+	sort.SliceStable(issues, func(i, j int) bool {
+		return issues[i].Check < issues[j].Check
+	})
+	return issues
+}
+
+// lintSchemas reports the fields of the templates rendered with empty input that the schemas of their kinds don't
+// declare. Types aren't checked, the values of templated fields are only known once rendered with a cluster CR.
+func lintSchemas(temps []ReferenceTemplate, schemas *schemaSet) []LintIssue {
+	var issues []LintIssue
+	for _, temp := range temps {
+		for _, v := range schemas.violations(temp.GetMetadata(), false) {
+			issues = append(issues, LintIssue{Check: lintCheckSchema, Template: temp.GetPath(), Message: strings.TrimPrefix(v, schemaViolationPrefix)})
+		}
+	}
+	return issues
+}
+
+// configureIssues drops the issues of disabled checks and sets the severity of the rest
+func configureIssues(issues []LintIssue, config *LintConfig) []LintIssue {
+	result := make([]LintIssue, 0, len(issues))
+	for _, issue := range issues {
+		if config.disabled(issue.Check) {
+			continue
+		}
+		issue.Severity = config.severity(issue.Check)
+		result = append(result, issue)
+	}
+	return result
+}
+
+func issuesFromError(check string, err error) []LintIssue {
+	errs := []error{err}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok { // nolint:errorlint
+		errs = joined.Unwrap()
+	}
+	var issues []LintIssue
+	for _, e := range errs {
+		if e == nil {
+			continue
+		}
+		if _, ok := e.(interface{ Unwrap() []error }); ok { // nolint:errorlint
+			issues = append(issues, issuesFromError(check, e)...)
+			continue
+		}
+		issues = append(issues, LintIssue{Check: check, Message: e.Error()})
+	}
+	return issues
+}
+
+// templateLookup is implemented by reference templates that embed their parsed *template.Template
+type templateLookup interface {
+	Lookup(name string) *template.Template
+}
+
+// lintFunctionFiles reports template function files that don't define any named templates or that define named
+// templates that aren't invoked (directly or through other named templates) by any of the reference templates.
+func lintFunctionFiles(fsys fs.FS, functionFiles []string, temps []ReferenceTemplate) []LintIssue {
+	if len(functionFiles) == 0 {
+		return nil
+	}
+	used := make(map[string]bool)
+	for _, temp := range temps {
+		lookup, ok := temp.(templateLookup)
+		if !ok || temp.GetTemplateTree() == nil {
+			continue
+		}
+		collectInvokedTemplates(temp.GetTemplateTree().Root, lookup, used)
+	}
+
+	var issues []LintIssue
+	for _, file := range functionFiles {
+		t, err := template.New(path.Base(file)).Funcs(FuncMap()).ParseFS(fsys, file)
+		if err != nil {
+			// Already reported when the templates were parsed
+			continue
+		}
+		var defined []string
+		for _, named := range t.Templates() {
+			if named.Name() != path.Base(file) && named.Tree != nil {
+				defined = append(defined, named.Name())
+			}
+		}
+		if len(defined) == 0 {
+			issues = append(issues, LintIssue{Check: lintCheckFunctions,
+				Message: fmt.Sprintf("template function file %s doesn't define any templates", file)})
+			continue
+		}
+		if !slices.ContainsFunc(defined, func(name string) bool { return used[name] }) {
+			sort.Strings(defined)
+			issues = append(issues, LintIssue{Check: lintCheckFunctions,
+				Message: fmt.Sprintf("template function file %s is unused, none of its templates (%s) are invoked by the reference templates",
+					file, strings.Join(defined, ", "))})
+		}
+	}
+	return issues
+}
+
+// collectInvokedTemplates walks the template parse tree and marks every named template invoked from it, either with
+// the template action or the include function
+func collectInvokedTemplates(node parse.Node, lookup templateLookup, used map[string]bool) {
+	invoked, _ := invokedTemplates(node)
+	for _, inv := range invoked {
+		if used[inv.name] {
+			continue
+		}
+		used[inv.name] = true
+		if named := lookup.Lookup(inv.name); named != nil && named.Tree != nil {
+			collectInvokedTemplates(named.Tree.Root, lookup, used)
+		}
+	}
+}
+
+// walkTemplateNodes calls fn for the node and every node nested within it
+func walkTemplateNodes(node parse.Node, fn func(parse.Node)) {
+	fn(node)
+	switch n := node.(type) {
+	case *parse.ListNode:
+		for _, child := range n.Nodes {
+			walkTemplateNodes(child, fn)
+		}
+	case *parse.IfNode:
+		walkBranchNode(&n.BranchNode, fn)
+	case *parse.RangeNode:
+		walkBranchNode(&n.BranchNode, fn)
+	case *parse.WithNode:
+		walkBranchNode(&n.BranchNode, fn)
+	case *parse.ActionNode:
+		walkTemplateNodes(n.Pipe, fn)
+	case *parse.TemplateNode:
+		if n.Pipe != nil {
+			walkTemplateNodes(n.Pipe, fn)
+		}
+	case *parse.PipeNode:
+		for _, cmd := range n.Cmds {
+			walkTemplateNodes(cmd, fn)
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			walkTemplateNodes(arg, fn)
+		}
+	case *parse.ChainNode:
+		walkTemplateNodes(n.Node, fn)
+	}
+}
+
+func walkBranchNode(n *parse.BranchNode, fn func(parse.Node)) {
+	walkTemplateNodes(n.Pipe, fn)
+	walkTemplateNodes(n.List, fn)
+	if n.ElseList != nil {
+		walkTemplateNodes(n.ElseList, fn)
+	}
+}
+
+// lintComponents reports parts without components, components that don't reference any template and templates that
+// are referenced more than once
+func lintComponents(ref Reference) []LintIssue {
+	var issues []LintIssue
+	emptyPart := func(part string) {
+		issues = append(issues, LintIssue{Check: lintCheckComponents, Message: fmt.Sprintf("part %s has no components", part)})
+	}
+	emptyComponent := func(part, comp string) {
+		issues = append(issues, LintIssue{Check: lintCheckComponents,
+			Message: fmt.Sprintf("component %s in part %s has no templates", comp, part)})
+	}
+	switch r := ref.(type) {
+	case *ReferenceV1:
+		for _, part := range r.Parts {
+			if len(part.Components) == 0 {
+				emptyPart(part.Name)
+			}
+			for _, comp := range part.Components {
+				if len(comp.RequiredTemplates) == 0 && len(comp.OptionalTemplates) == 0 {
+					emptyComponent(part.Name, comp.Name)
+				}
+			}
+		}
+	case *ReferenceV2:
+		for _, part := range r.Parts {
+			if len(part.Components) == 0 {
+				emptyPart(part.Name)
+			}
+			// Loading the reference already fails on components without templates, they're checked in case the
+			// reference was built without loading it
+			for _, comp := range part.Components {
+				if len(comp.getTemplates(part)) == 0 {
+					emptyComponent(part.Name, comp.Name)
+				}
+			}
+		}
+	}
+
+	seen := make(map[string]int)
+	for _, temp := range ref.GetTemplates() {
+		seen[temp.GetPath()]++
+	}
+	var duplicated []string
+	for p, n := range seen {
+		if n > 1 {
+			duplicated = append(duplicated, p)
+		}
+	}
+	sort.Strings(duplicated)
+	for _, p := range duplicated {
+		issues = append(issues, LintIssue{Check: lintCheckComponents,
+			Message: fmt.Sprintf("template %s is referenced %d times in the reference", p, seen[p])})
+	}
+	return issues
+}
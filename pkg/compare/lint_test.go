@@ -0,0 +1,103 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"path"
+	"testing"
+
+	"github.com/openshift/kube-compare/pkg/testutils"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+)
+
+func TestLint(t *testing.T) {
+	cases := []struct {
+		name         string
+		reference    string
+		config       string
+		schemas      string
+		outputFormat string
+		goldenPrefix string
+		expectIssue  bool
+	}{
+		{name: "Lint No Issues"},
+		{name: "Lint Reports All Issues", expectIssue: true},
+		{name: "Lint Template Checks", expectIssue: true},
+		{name: "Lint Template Checks", config: "lint-config.yaml", outputFormat: Json, goldenPrefix: "config_json_"},
+		{name: "Lint Component Checks", expectIssue: true},
+		{name: "Lint Component Checks", reference: "metadata_v1.yaml", goldenPrefix: "v1_", expectIssue: true},
+		{name: "Schema Validation", schemas: "schemas", goldenPrefix: "lint_", expectIssue: true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name+c.goldenPrefix, func(t *testing.T) {
+			test := defaultTest(c.name)
+			IOStream, _, out, _ := genericiooptions.NewTestIOStreams()
+			reference := defaultReferenceFilename
+			if c.reference != "" {
+				reference = c.reference
+			}
+			options := &LintOptions{
+				referenceConfig: path.Join(test.getTestDir(), TestRefDirName, reference),
+				OutputFormat:    c.outputFormat,
+				IOStreams:       IOStream,
+			}
+			if c.config != "" || c.schemas != "" {
+				if c.config != "" {
+					options.configPath = path.Join(test.getTestDir(), c.config)
+				}
+				if c.schemas != "" {
+					options.schemasPath = path.Join(test.getTestDir(), c.schemas)
+				}
+				require.NoError(t, options.Complete(&cobra.Command{}, nil))
+			}
+			err := options.Run()
+			if c.expectIssue {
+				require.NotNil(t, diffError(err))
+			} else {
+				require.NoError(t, err)
+			}
+			checkFile(t, path.Join(test.getTestDir(), c.goldenPrefix+defaultOutSuffix), testutils.RemoveInconsistentInfo(t, out.String()))
+		})
+	}
+}
+
+func TestTemplatedFields(t *testing.T) {
+	content := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: {{ .metadata.name }}
+  labels:
+    "app.kubernetes.io/name": fixed
+    {{- if .metadata.labels }}
+    tier: {{ .metadata.labels.tier }}
+    {{- end }}
+data:
+  config: |
+    key: {{ .data.key }}
+  plain: value
+spec:
+  ports:
+  - name: {{ .spec.name }}
+    port: 80
+`
+	require.Equal(t, [][]string{
+		{"metadata", "name"},
+		{"metadata", "labels", "tier"},
+		{"data", "config", "key"},
+		{"spec", "ports", "name"},
+	}, templatedFields(content))
+}
+
+func TestLintComponentsV2(t *testing.T) {
+	ref := &ReferenceV2{Parts: []*PartV2{
+		{Name: "Config", Components: []*ComponentV2{{Name: "Empty"}}},
+		{Name: "Unused"},
+	}}
+	require.Equal(t, []LintIssue{
+		{Check: lintCheckComponents, Message: "component Empty in part Config has no templates"},
+		{Check: lintCheckComponents, Message: "part Unused has no components"},
+	}, lintComponents(ref))
+}
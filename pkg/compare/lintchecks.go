@@ -0,0 +1,325 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"fmt"
+	"io/fs"
+	"regexp"
+	"slices"
+	"strings"
+	"text/template/parse"
+)
+
+// lintTemplates runs the checks that inspect the content of each of the reference templates
+func lintTemplates(fsys fs.FS, ref Reference, temps []ReferenceTemplate, discouragedFuncs []string) []LintIssue {
+	var issues []LintIssue
+	seen := make(map[string]bool)
+	for _, temp := range temps {
+		// Templates referenced more than once are already reported by the components check
+		if seen[temp.GetPath()] {
+			continue
+		}
+		seen[temp.GetPath()] = true
+		issues = append(issues, lintMissingMetadata(temp)...)
+		if tree := temp.GetTemplateTree(); tree != nil {
+			issues = append(issues, lintNilDereferences(temp.GetPath(), tree)...)
+			issues = append(issues, lintDiscouragedFuncs(temp.GetPath(), tree, discouragedFuncs)...)
+			issues = append(issues, lintUnreachableContent(temp.GetPath(), tree)...)
+		}
+		issues = append(issues, lintTemplatedAndOmitted(fsys, ref, temp)...)
+	}
+	return issues
+}
+
+// lintMissingMetadata reports templates that don't set the apiVersion or metadata.name of the CR, templated values
+// are considered set. A missing kind is already reported when the templates are parsed.
+func lintMissingMetadata(temp ReferenceTemplate) []LintIssue {
+	if temp.GetMetadata() == nil {
+		return nil
+	}
+	var issues []LintIssue
+	for _, field := range [][]string{{"apiVersion"}, {"metadata", "name"}} {
+		if _, found, _ := NestedField(temp.GetMetadata().Object, field...); !found {
+			issues = append(issues, LintIssue{Check: lintCheckMissingMetadata, Template: temp.GetPath(),
+				Message: fmt.Sprintf("template doesn't set %s", strings.Join(field, "."))})
+		}
+	}
+	return issues
+}
+
+// nodeLine returns the line of the node in the template in the form of "line <n>"
+func nodeLine(tree *parse.Tree, node parse.Node) string {
+	location, _ := tree.ErrorContext(node)
+	parts := strings.Split(location, ":")
+	if len(parts) < 3 {
+		return location
+	}
+	return "line " + parts[len(parts)-2]
+}
+
+// lintNilDereferences reports index calls on fields of the input that aren't guarded by an enclosing if or with
+// checking the field (or a preceding argument of an and). Unlike accessing a missing field, which renders as
+// <no value>, indexing a missing field fails the rendering of the template.
+func lintNilDereferences(templatePath string, tree *parse.Tree) []LintIssue {
+	var issues []LintIssue
+	var walk func(node parse.Node, guards [][]string, rootDot bool)
+	var walkPipe func(pipe *parse.PipeNode, guards [][]string, rootDot bool)
+
+	checkArgs := func(args []parse.Node, guards [][]string, rootDot bool) {
+		for _, arg := range args {
+			if pipe, ok := arg.(*parse.PipeNode); ok {
+				walkPipe(pipe, guards, rootDot)
+			}
+		}
+	}
+	walkPipe = func(pipe *parse.PipeNode, guards [][]string, rootDot bool) {
+		for _, cmd := range pipe.Cmds {
+			ident, ok := cmd.Args[0].(*parse.IdentifierNode)
+			switch {
+			case ok && ident.Ident == "and":
+				// and only evaluates an argument when the preceding ones are true
+				local := slices.Clone(guards)
+				for _, arg := range cmd.Args[1:] {
+					checkArgs([]parse.Node{arg}, local, rootDot)
+					local = append(local, fieldChains(arg, rootDot)...)
+				}
+				continue
+			case ok && ident.Ident == "index" && len(cmd.Args) > 2:
+				if chain := fieldChain(cmd.Args[1], rootDot); chain != nil && !isGuarded(chain, guards) {
+					issues = append(issues, LintIssue{Check: lintCheckNilDereference, Template: templatePath,
+						Message: fmt.Sprintf("%s: index of .%s isn't guarded by an if or with checking it, rendering fails when it's missing",
+							nodeLine(tree, cmd), strings.Join(chain, "."))})
+				}
+			}
+			checkArgs(cmd.Args, guards, rootDot)
+		}
+	}
+	walkBranch := func(n *parse.BranchNode, guards [][]string, rootDot, dotChanges bool) {
+		walkPipe(n.Pipe, guards, rootDot)
+		walk(n.List, append(slices.Clone(guards), fieldChains(n.Pipe, rootDot)...), rootDot && !dotChanges)
+		if n.ElseList != nil {
+			walk(n.ElseList, guards, rootDot)
+		}
+	}
+	walk = func(node parse.Node, guards [][]string, rootDot bool) {
+		switch n := node.(type) {
+		case *parse.ListNode:
+			for _, child := range n.Nodes {
+				walk(child, guards, rootDot)
+			}
+		case *parse.IfNode:
+			walkBranch(&n.BranchNode, guards, rootDot, false)
+		case *parse.WithNode:
+			walkBranch(&n.BranchNode, guards, rootDot, true)
+		case *parse.RangeNode:
+			walkBranch(&n.BranchNode, guards, rootDot, true)
+		case *parse.ActionNode:
+			walkPipe(n.Pipe, guards, rootDot)
+		case *parse.TemplateNode:
+			if n.Pipe != nil {
+				walkPipe(n.Pipe, guards, rootDot)
+			}
+		}
+	}
+	walk(tree.Root, nil, true)
+	return issues
+}
+
+// fieldChain returns the path of a field of the template input, relative fields are only known while the dot is the
+// input itself
+func fieldChain(node parse.Node, rootDot bool) []string {
+	switch n := node.(type) {
+	case *parse.FieldNode:
+		if rootDot {
+			return n.Ident
+		}
+	case *parse.VariableNode:
+		if len(n.Ident) > 1 && n.Ident[0] == "$" {
+			return n.Ident[1:]
+		}
+	}
+	return nil
+}
+
+// fieldChains returns the paths of all the fields of the template input referenced in the node
+func fieldChains(node parse.Node, rootDot bool) [][]string {
+	var chains [][]string
+	walkTemplateNodes(node, func(n parse.Node) {
+		if chain := fieldChain(n, rootDot); chain != nil {
+			chains = append(chains, chain)
+		}
+	})
+	return chains
+}
+
+func isGuarded(chain []string, guards [][]string) bool {
+	return slices.ContainsFunc(guards, func(guard []string) bool {
+		return len(guard) >= len(chain) && slices.Equal(guard[:len(chain)], chain)
+	})
+}
+
+// lintDiscouragedFuncs reports calls to discouraged functions
+func lintDiscouragedFuncs(templatePath string, tree *parse.Tree, discouraged []string) []LintIssue {
+	var issues []LintIssue
+	walkTemplateNodes(tree.Root, func(node parse.Node) {
+		if ident, ok := node.(*parse.IdentifierNode); ok && slices.Contains(discouraged, ident.Ident) {
+			issues = append(issues, LintIssue{Check: lintCheckDiscouragedFuncs, Template: templatePath,
+				Message: fmt.Sprintf("%s: use of discouraged function %s", nodeLine(tree, node), ident.Ident)})
+		}
+	})
+	return issues
+}
+
+// lintUnreachableContent reports if and with actions whose condition is a constant, making one of their branches
+// unreachable
+func lintUnreachableContent(templatePath string, tree *parse.Tree) []LintIssue {
+	var issues []LintIssue
+	walkTemplateNodes(tree.Root, func(node parse.Node) {
+		var branch *parse.BranchNode
+		var action string
+		switch n := node.(type) {
+		case *parse.IfNode:
+			branch, action = &n.BranchNode, "if"
+		case *parse.WithNode:
+			branch, action = &n.BranchNode, "with"
+		default:
+			return
+		}
+		truth, ok := constantTruth(branch.Pipe)
+		if !ok {
+			return
+		}
+		var message string
+		switch {
+		case !truth:
+			message = "its content is unreachable"
+		case branch.ElseList != nil:
+			message = "its else branch is unreachable"
+		default:
+			message = "it has no effect"
+		}
+		issues = append(issues, LintIssue{Check: lintCheckUnreachableContent, Template: templatePath,
+			Message: fmt.Sprintf("%s: the condition of {{ %s %s }} is always %t, %s", nodeLine(tree, node), action, branch.Pipe, truth, message)})
+	})
+	return issues
+}
+
+// constantTruth returns the truth of a pipeline made of a single constant
+func constantTruth(pipe *parse.PipeNode) (truth, ok bool) {
+	if len(pipe.Decl) > 0 || len(pipe.Cmds) != 1 || len(pipe.Cmds[0].Args) != 1 {
+		return false, false
+	}
+	switch n := pipe.Cmds[0].Args[0].(type) {
+	case *parse.BoolNode:
+		return n.True, true
+	case *parse.StringNode:
+		return n.Text != "", true
+	case *parse.NumberNode:
+		return !(n.IsInt && n.Int64 == 0 || n.IsFloat && n.Float64 == 0), true
+	}
+	return false, false
+}
+
+// yamlKeyLine matches a line of a YAML mapping, capturing its indentation, key and value
+var yamlKeyLine = regexp.MustCompile(`^(\s*(?:- +)?)("[^"]*"|'[^']*'|[^\s:"'{#-][^:]*?):(?:\s+(.*))?$`)
+
+// lintTemplatedAndOmitted reports fields that are set by template actions but are omitted by the fieldsToOmit of the
+// template, so differences in them are never reported
+func lintTemplatedAndOmitted(fsys fs.FS, ref Reference, temp ReferenceTemplate) []LintIssue {
+	content, err := fs.ReadFile(fsys, temp.GetPath())
+	if err != nil {
+		return nil
+	}
+	toOmit := temp.GetFieldsToOmit(ref.GetFieldsToOmit())
+	var issues []LintIssue
+	for _, field := range templatedFields(string(content)) {
+		for _, omit := range toOmit {
+			if omit.Process() != nil || !omitsField(omit, field) {
+				continue
+			}
+			issues = append(issues, LintIssue{Check: lintCheckTemplatedAndOmitted, Template: temp.GetPath(),
+				Message: fmt.Sprintf("field %s is templated but omitted by the fieldsToOmit path %s, differences in it are never reported",
+					strings.Join(field, "."), omit.PathToKey)})
+			break
+		}
+	}
+	return issues
+}
+
+// templatedFields returns the paths of the fields whose value is set by a template action. The paths are derived from
+// the indentation of the template source, keys of list items are treated as keys of the list.
+func templatedFields(content string) [][]string {
+	type key struct {
+		indent int
+		name   string
+	}
+	var stack []key
+	var fields [][]string
+	seen := make(map[string]bool)
+	addField := func() {
+		path := make([]string, 0, len(stack))
+		for _, k := range stack {
+			path = append(path, k.name)
+		}
+		if id := strings.Join(path, "\x00"); len(path) > 0 && !seen[id] {
+			seen[id] = true
+			fields = append(fields, path)
+		}
+	}
+	for _, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, "{{") {
+			continue
+		}
+		m := yamlKeyLine.FindStringSubmatch(line)
+		if m == nil {
+			// Continuation of a multiline value belongs to the last key
+			if strings.Contains(line, "{{") {
+				addField()
+			}
+			continue
+		}
+		indent := len(m[1])
+		for len(stack) > 0 && stack[len(stack)-1].indent >= indent {
+			stack = stack[:len(stack)-1]
+		}
+		stack = append(stack, key{indent: indent, name: strings.Trim(m[2], `"'`)})
+		if strings.Contains(m[3], "{{") {
+			addField()
+		}
+	}
+	return fields
+}
+
+// omitsField returns true if the field is omitted by the path, either directly or by omitting one of its parents
+func omitsField(omit *ManifestPathV1, field []string) bool {
+	if omit.segments != nil {
+		return segmentsOmitField(omit.segments, field)
+	}
+	n := len(omit.parts)
+	if n == 0 || len(field) < n || !slices.Equal(omit.parts[:n-1], field[:n-1]) {
+		return false
+	}
+	if omit.IsPrefix {
+		return strings.HasPrefix(field[n-1], omit.parts[n-1])
+	}
+	return field[n-1] == omit.parts[n-1]
+}
+
+// segmentsOmitField returns true if the field is omitted by the JSONPath, either directly or by omitting one of its
+// parents. The fields of templates don't go through lists, so paths selecting list items never omit them.
+func segmentsOmitField(segments []jsonPathSegment, field []string) bool {
+	if len(field) < len(segments) {
+		return false
+	}
+	for i, segment := range segments {
+		if segment.index != nil || segment.filter != nil || (segment.key == "" && !segment.wildcard) {
+			return false
+		}
+		if !segment.matchesKey(field[i]) {
+			return false
+		}
+	}
+	return true
+}
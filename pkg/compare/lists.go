@@ -0,0 +1,173 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	utiljson "k8s.io/apimachinery/pkg/util/json"
+	k8syaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/yaml"
+)
+
+// isListDocument checks if the document is a list of CRs: an object with an items array and either no kind, as in
+// hand written dumps, or a list kind (List or a typed list like ConfigMapList)
+func isListDocument(doc map[string]any) bool {
+	if _, ok := doc["items"].([]any); !ok {
+		return false
+	}
+	kind, ok := doc["kind"].(string)
+	return !ok || strings.HasSuffix(kind, "List")
+}
+
+// setTypedListItemKind sets the kind of an item of a typed list (e.g. a DeploymentList returned by the API), whose
+// items don't set their kind
+func setTypedListItemKind(list, item map[string]any) {
+	kind, _ := list["kind"].(string)
+	if _, ok := item["kind"]; !ok && strings.HasSuffix(kind, "List") && kind != "List" {
+		item["kind"] = strings.TrimSuffix(kind, "List")
+		item["apiVersion"] = list["apiVersion"]
+	}
+}
+
+// listObjects returns the CRs of the list document, the items of the lists nested in it are flattened as well. It
+// returns false if an item isn't an object.
+func listObjects(doc map[string]any) ([]map[string]any, bool) {
+	var result []map[string]any
+	for _, item := range doc["items"].([]any) {
+		cr, ok := item.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		setTypedListItemKind(doc, cr)
+		if !isListDocument(cr) {
+			result = append(result, cr)
+			continue
+		}
+		crs, ok := listObjects(cr)
+		if !ok {
+			return nil, false
+		}
+		result = append(result, crs...)
+	}
+	return result, true
+}
+
+// flattenListDocuments replaces the list documents of the CR file (e.g. the output of kubectl get -o yaml) with a
+// document for each of their items, the other documents are kept as they are. It returns false when the file has no
+// list or can't be parsed, the builder then reads the file as is and reports its invalid documents as usual.
+func flattenListDocuments(content []byte) ([]byte, bool) {
+	var out bytes.Buffer
+	flattened := false
+	reader := k8syaml.NewYAMLReader(bufio.NewReader(bytes.NewReader(content)))
+	for {
+		raw, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, false
+		}
+		var doc map[string]any
+		if data, err := k8syaml.ToJSON(raw); err == nil && utiljson.Unmarshal(data, &doc) == nil && isListDocument(doc) {
+			if crs, ok := listObjects(doc); ok {
+				for _, cr := range crs {
+					data, err := yaml.Marshal(cr)
+					if err != nil {
+						return nil, false
+					}
+					out.WriteString("---\n")
+					out.Write(data)
+				}
+				flattened = true
+				continue
+			}
+		}
+		out.WriteString("---\n")
+		out.Write(raw)
+		if !bytes.HasSuffix(raw, []byte("\n")) {
+			out.WriteString("\n")
+		}
+	}
+	if !flattened {
+		return nil, false
+	}
+	return out.Bytes(), true
+}
+
+// flattened returns the stream of the CR file with its list documents flattened
+func (s streamedCR) flattened() streamedCR {
+	if s.open == nil {
+		if content, ok := flattenListDocuments(s.content); ok {
+			s.content = content
+		}
+		return s
+	}
+	open := s.open
+	s.open = func() (io.ReadCloser, error) {
+		r, err := open()
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		content, err := io.ReadAll(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", s.name, err)
+		}
+		if flattened, ok := flattenListDocuments(content); ok {
+			content = flattened
+		}
+		return io.NopCloser(bytes.NewReader(content)), nil
+	}
+	return s
+}
+
+// flattenLocalLists finds the local CR files holding lists of CRs and returns their flattened content together with
+// the rest of the files to pass to the builder. The builder only flattens the documents of kind List whose items are
+// all CRs, lists without a kind or nested in other lists would be skipped as invalid or fail the run. Like with
+// decryptLocalCRs the file names are returned unchanged when no file holds a list.
+func flattenLocalLists(filenames []string, recursive bool) ([]string, []streamedCR, error) {
+	var plain []string
+	var flattened []streamedCR
+	for _, f := range filenames {
+		if f == stdinFilename || isURL(f) {
+			plain = append(plain, f)
+			continue
+		}
+		paths, err := expandCRPaths(f, recursive)
+		if err != nil {
+			plain = append(plain, f)
+			continue
+		}
+		for _, p := range paths {
+			mentionsItems, err := fileContains(p, []byte("items"))
+			if err != nil {
+				return nil, nil, err
+			}
+			if !mentionsItems {
+				plain = append(plain, p)
+				continue
+			}
+			content, err := os.ReadFile(p)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to read %s: %w", p, err)
+			}
+			content, ok := flattenListDocuments(content)
+			if !ok {
+				plain = append(plain, p)
+				continue
+			}
+			flattened = append(flattened, streamedCR{name: p, content: content})
+		}
+	}
+	if len(flattened) == 0 {
+		return filenames, nil, nil
+	}
+	return plain, flattened, nil
+}
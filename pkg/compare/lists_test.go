@@ -0,0 +1,76 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFlattenListDocuments(t *testing.T) {
+	_, ok := flattenListDocuments([]byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: settings\n"))
+	require.False(t, ok, "files without lists should be read as is")
+	_, ok = flattenListDocuments([]byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: settings\ndata:\n  items: |\n    - a\n"))
+	require.False(t, ok)
+	_, ok = flattenListDocuments([]byte("apiVersion: example.com/v1\nkind: Inventory\nitems:\n- name: a\n"))
+	require.False(t, ok, "CRs with items that aren't lists shouldn't be flattened")
+	_, ok = flattenListDocuments([]byte("items:\n- a\n"))
+	require.False(t, ok, "lists of items that aren't objects should be reported by the builder")
+
+	content := `apiVersion: v1
+kind: Namespace
+metadata:
+  name: example # kept as is
+---
+apiVersion: v1
+kind: List
+items:
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: settings
+  data:
+    mode: relaxed
+- apiVersion: apps/v1
+  kind: DeploymentList
+  items:
+  - metadata:
+      name: app
+    spec:
+      replicas: 9007199254740993
+---
+items:
+- apiVersion: v1
+  kind: Secret
+  metadata:
+    name: token
+`
+	flattened, ok := flattenListDocuments([]byte(content))
+	require.True(t, ok)
+	require.Equal(t, `---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: example # kept as is
+---
+apiVersion: v1
+data:
+  mode: relaxed
+kind: ConfigMap
+metadata:
+  name: settings
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+spec:
+  replicas: 9007199254740993
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: token
+`, string(flattened))
+}
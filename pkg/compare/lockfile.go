@@ -0,0 +1,289 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+	kcmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"k8s.io/kubectl/pkg/util/i18n"
+	"k8s.io/kubectl/pkg/util/templates"
+	"sigs.k8s.io/yaml"
+)
+
+var (
+	updateLockLong = templates.LongDesc(`
+		Pin the content of a reference configuration in a lock file.
+
+		The update-lock command loads the reference and records the resolved URL (for references served over http)
+		and the sha256 digest of every file it's made of: the reference config, the templates and the template
+		function files. When a lock is used by the compare command, loading a file whose content doesn't match the
+		lock or that isn't pinned in it fails, so the same reference content is compared across runs and machines.
+
+		A lock named reference.lock next to a local reference config is used automatically, a lock for a reference
+		served over http is passed to the compare command with --reference-lock.
+	`)
+
+	updateLockExample = templates.Examples(`
+		# Pin a local reference, the lock is written next to it:
+		kubectl cluster-compare update-lock -r ./reference/metadata.yaml
+
+		# Pin a reference served over http and use the lock when comparing:
+		kubectl cluster-compare update-lock -r https://example.com/reference/metadata.yaml --lock-file ./reference.lock
+		kubectl cluster-compare -r https://example.com/reference/metadata.yaml --reference-lock ./reference.lock
+	`)
+)
+
+const (
+	DefaultLockFileName = "reference.lock"
+	lockFileVersion     = "v1"
+
+	lockDigestMismatch = "%s doesn't match the reference lock: expected digest %s, got %s. Run update-lock if the change is intended"
+	lockFileNotPinned  = "%s isn't pinned in the reference lock. Run update-lock to pin it"
+	lockURLMismatch    = "%s was pinned from %s but is loaded from %s. Run update-lock to pin the new location"
+)
+
+// ReferenceLock pins the content of every file loaded from a reference
+type ReferenceLock struct {
+	Version   string       `json:"version"`
+	Reference string       `json:"reference"`
+	Files     []LockedFile `json:"files"`
+}
+
+// LockedFile is the digest of a file in the reference, the URL it was loaded from is recorded for references served
+// over http
+type LockedFile struct {
+	Path    string `json:"path"`
+	URL     string `json:"url,omitempty"`
+	Digest  string `json:"digest"`
+	content []byte
+}
+
+// LoadReferenceLock reads a lock written by update-lock
+func LoadReferenceLock(lockPath string) (*ReferenceLock, error) {
+	content, err := os.ReadFile(lockPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read reference lock: %w", err)
+	}
+	lock := &ReferenceLock{}
+	if err := yaml.UnmarshalStrict(content, lock); err != nil {
+		return nil, fmt.Errorf("failed to parse reference lock %s: %w", lockPath, err)
+	}
+	if lock.Version != lockFileVersion {
+		return nil, fmt.Errorf("unsupported reference lock version %q in %s", lock.Version, lockPath)
+	}
+	return lock, nil
+}
+
+// Write writes the lock with the files sorted by path
+func (l *ReferenceLock) Write(lockPath string) error {
+	sort.Slice(l.Files, func(i, j int) bool { return l.Files[i].Path < l.Files[j].Path })
+	content, err := yaml.Marshal(l)
+	if err != nil {
+		return fmt.Errorf("failed to marshal reference lock: %w", err)
+	}
+	if err := os.WriteFile(lockPath, content, 0o644); err != nil { // nolint:gosec
+		return fmt.Errorf("failed to write reference lock: %w", err)
+	}
+	return nil
+}
+
+func (l *ReferenceLock) verify(file LockedFile) error {
+	for _, pinned := range l.Files {
+		if pinned.Path != file.Path {
+			continue
+		}
+		if pinned.URL != "" && file.URL != "" && pinned.URL != file.URL {
+			return fmt.Errorf(lockURLMismatch, file.Path, pinned.URL, file.URL)
+		}
+		if pinned.Digest != file.Digest {
+			return fmt.Errorf(lockDigestMismatch, file.Path, pinned.Digest, file.Digest)
+		}
+		return nil
+	}
+	return fmt.Errorf(lockFileNotPinned, file.Path)
+}
+
+// referenceLockPath returns the path of the lock to enforce when loading the reference, an explicitly passed lock
+// takes precedence over a lock next to a local reference. An empty string is returned if there is no lock.
+func referenceLockPath(referenceConfig, lockPath string) string {
+	if lockPath != "" || isURL(referenceConfig) {
+		return lockPath
+	}
+	defaultPath := filepath.Join(filepath.Dir(referenceConfig), DefaultLockFileName)
+	if _, err := os.Stat(defaultPath); err == nil {
+		return defaultPath
+	}
+	return ""
+}
+
+// lockedFS wraps the file system of a reference and verifies the digest of every file opened from it against the
+// lock, when there is no lock the digests are recorded instead
+type lockedFS struct {
+	fsys     fs.FS
+	lock     *ReferenceLock
+	mu       sync.Mutex
+	recorded map[string]LockedFile
+}
+
+func newLockedFS(fsys fs.FS, lock *ReferenceLock) *lockedFS {
+	return &lockedFS{fsys: fsys, lock: lock, recorded: make(map[string]LockedFile)}
+}
+
+func (l *lockedFS) Open(name string) (fs.File, error) {
+	f, err := l.fsys.Open(name)
+	if err != nil {
+		return nil, err // nolint:wrapcheck
+	}
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		return f, err // nolint:wrapcheck
+	}
+	content, err := io.ReadAll(f)
+	closeErr := f.Close()
+	if err = errors.Join(err, closeErr); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+
+	file := LockedFile{Path: path.Clean(name), Digest: checksumOf(content)}
+	if file.URL, err = fileURL(l.fsys, name); err != nil {
+		return nil, err
+	}
+	if l.lock != nil {
+		if err := l.lock.verify(file); err != nil {
+			return nil, err
+		}
+	} else {
+		file.content = content
+		l.mu.Lock()
+		l.recorded[file.Path] = file
+		l.mu.Unlock()
+	}
+	return lockedFile{Reader: bytes.NewReader(content), info: info}, nil
+}
+
+// Stat returns the file info without verifying the file, so patterns matching files that don't match the lock still
+// match them and the verification error is reported when they're read
+func (l *lockedFS) Stat(name string) (fs.FileInfo, error) {
+	return fs.Stat(l.fsys, name) // nolint:wrapcheck
+}
+
+// lockedFile is a verified file, its content was already read from the underlying file system
+type lockedFile struct {
+	*bytes.Reader
+	info fs.FileInfo
+}
+
+func (f lockedFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+func (f lockedFile) Close() error {
+	return nil
+}
+
+// recordReferenceFiles loads the reference and its templates and returns all the files that were loaded
+func recordReferenceFiles(fsys fs.FS, referenceFileName string) (map[string]LockedFile, error) {
+	recorder := newLockedFS(fsys, nil)
+	ref, err := GetReference(recorder, referenceFileName)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := ParseTemplates(ref, recorder); err != nil {
+		return nil, err
+	}
+	return recorder.recorded, nil
+}
+
+// LockReference loads the reference and its templates and returns a lock pinning all the files that were loaded
+func LockReference(fsys fs.FS, referenceConfig string) (*ReferenceLock, error) {
+	recorded, err := recordReferenceFiles(fsys, ReferenceFileName(referenceConfig))
+	if err != nil {
+		return nil, err
+	}
+	lock := &ReferenceLock{Version: lockFileVersion, Reference: referenceConfig}
+	for _, file := range recorded {
+		lock.Files = append(lock.Files, file)
+	}
+	return lock, nil
+}
+
+type UpdateLockOptions struct {
+	referenceConfig string
+	lockPath        string
+
+	genericiooptions.IOStreams
+}
+
+func NewUpdateLockCmd(streams genericiooptions.IOStreams) *cobra.Command {
+	options := &UpdateLockOptions{IOStreams: streams}
+
+	cmd := &cobra.Command{
+		Use:                   "update-lock -r <Reference File>",
+		DisableFlagsInUseLine: true,
+		Short:                 i18n.T("Pin the content of a reference configuration in a lock file."),
+		Long:                  updateLockLong,
+		Example:               exampleForBinary(updateLockExample),
+		Run: func(cmd *cobra.Command, args []string) {
+			kcmdutil.CheckDiffErr(options.Complete(cmd, args))
+			kcmdutil.CheckDiffErr(options.Run())
+		},
+	}
+	cmd.SetFlagErrorFunc(func(command *cobra.Command, err error) error {
+		kcmdutil.CheckDiffErr(kcmdutil.UsageErrorf(cmd, err.Error()))
+		return nil
+	})
+	cmd.Flags().StringVarP(&options.referenceConfig, "reference", "r", "", "Path to reference config file.")
+	cmd.Flags().StringVar(&options.lockPath, "lock-file", "",
+		fmt.Sprintf("Path of the lock file to write. Defaults to %s next to a local reference config or in the current directory for a reference served over http", DefaultLockFileName))
+	return cmd
+}
+
+func (o *UpdateLockOptions) Complete(cmd *cobra.Command, args []string) error {
+	if len(args) != 0 {
+		return kcmdutil.UsageErrorf(cmd, "Unexpected args: %v", args)
+	}
+	if o.referenceConfig == "" {
+		return kcmdutil.UsageErrorf(cmd, noRefFileWasPassed)
+	}
+	if _, err := os.Stat(o.referenceConfig); os.IsNotExist(err) && !isURL(o.referenceConfig) {
+		return errors.New(refFileNotExistsError)
+	}
+	if o.lockPath == "" {
+		o.lockPath = DefaultLockFileName
+		if !isURL(o.referenceConfig) {
+			o.lockPath = filepath.Join(filepath.Dir(o.referenceConfig), DefaultLockFileName)
+		}
+	}
+	return nil
+}
+
+// Run pins the reference and writes the lock
+func (o *UpdateLockOptions) Run() error {
+	cfs, err := GetRefFS(o.referenceConfig)
+	if err != nil {
+		return err
+	}
+	lock, err := LockReference(cfs, o.referenceConfig)
+	if err != nil {
+		return err
+	}
+	if err := lock.Write(o.lockPath); err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(o.Out, "Pinned %d files of the reference in %s\n", len(lock.Files), o.lockPath); err != nil {
+		return fmt.Errorf("error occurred when writing output: %w", err)
+	}
+	return nil
+}
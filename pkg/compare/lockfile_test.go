@@ -0,0 +1,102 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReferenceLock(t *testing.T) {
+	fsys := fstest.MapFS{
+		"metadata.yaml": {Data: []byte(`apiVersion: v2
+parts:
+  - name: Part
+    components:
+      - name: Component
+        allOf:
+          - path: templates/cm.yaml
+templateFunctionFiles:
+  - functions/*.tmpl
+`)},
+		"templates/cm.yaml": {Data: []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: {{ template "name" }}
+`)},
+		"functions/name.tmpl": {Data: []byte(`{{- define "name" }}example{{ end -}}`)},
+		"unused.yaml":         {Data: []byte(`not part of the reference`)},
+	}
+	lock, err := LockReference(fsys, "reference/metadata.yaml")
+	require.NoError(t, err)
+	require.Equal(t, "reference/metadata.yaml", lock.Reference)
+	paths := make([]string, 0, len(lock.Files))
+	for _, f := range lock.Files {
+		paths = append(paths, f.Path)
+	}
+	require.ElementsMatch(t, []string{"metadata.yaml", "templates/cm.yaml", "functions/name.tmpl"}, paths)
+
+	parse := func(fsys fs.FS) error {
+		locked := newLockedFS(fsys, lock)
+		ref, err := GetReference(locked, "metadata.yaml")
+		if err != nil {
+			return err
+		}
+		_, err = ParseTemplates(ref, locked)
+		return err
+	}
+	require.NoError(t, parse(fsys))
+
+	fsys["functions/name.tmpl"] = &fstest.MapFile{Data: []byte(`{{- define "name" }}changed{{ end -}}`)}
+	require.ErrorContains(t, parse(fsys), "functions/name.tmpl doesn't match the reference lock")
+	delete(fsys, "functions/name.tmpl")
+
+	fsys["functions/other.tmpl"] = &fstest.MapFile{Data: []byte(`{{- define "name" }}example{{ end -}}`)}
+	require.ErrorContains(t, parse(fsys), "functions/other.tmpl isn't pinned in the reference lock")
+}
+
+func TestReferenceLockURLs(t *testing.T) {
+	dir := filepath.Join(TestDirs, "ReferenceIncludes")
+	svr := httptest.NewServer(http.FileServer(http.Dir(dir)))
+	defer svr.Close()
+	client, err := defaultReferenceClient()
+	require.NoError(t, err)
+	content, err := os.ReadFile(filepath.Join(dir, TestRefDirName, defaultReferenceFilename))
+	require.NoError(t, err)
+
+	// The included reference is read through the includes and the checksum of the reference config
+	refConfig := svr.URL + "/reference/metadata.yaml"
+	fsys, err := getVerifiedRefFS(refConfig, checksumOf(content), client)
+	require.NoError(t, err)
+	lock, err := LockReference(fsys, refConfig)
+	require.NoError(t, err)
+	urls := make(map[string]string)
+	for _, f := range lock.Files {
+		urls[f.Path] = f.URL
+	}
+	require.Equal(t, map[string]string{
+		"metadata.yaml":       svr.URL + "/reference/metadata.yaml",
+		"nic.yaml":            svr.URL + "/reference/nic.yaml",
+		"base/metadata.yaml":  svr.URL + "/base/metadata.yaml",
+		"base/namespace.yaml": svr.URL + "/base/namespace.yaml",
+		"base/settings.yaml":  svr.URL + "/base/settings.yaml",
+	}, urls)
+
+	for i, f := range lock.Files {
+		if f.Path == "base/settings.yaml" {
+			lock.Files[i].URL = "https://example.com/base/settings.yaml"
+		}
+	}
+	locked := newLockedFS(fsys, lock)
+	ref, err := GetReference(locked, "metadata.yaml")
+	require.NoError(t, err)
+	_, err = ParseTemplates(ref, locked)
+	require.ErrorContains(t, err, "base/settings.yaml was pinned from https://example.com/base/settings.yaml but is loaded from "+svr.URL+"/base/settings.yaml")
+}
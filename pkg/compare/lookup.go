@@ -0,0 +1,196 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"text/template"
+	"text/template/parse"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/cli-runtime/pkg/resource"
+	"k8s.io/client-go/util/flowcontrol"
+	kcmdutil "k8s.io/kubectl/pkg/cmd/util"
+)
+
+// lookupFunc is the lookupCR template function: it returns the cluster object of the apiVersion, kind, namespace
+// (empty for cluster scoped objects) and name, or an empty object if it doesn't exist. The arguments are usually fields
+// of the cluster CR, missing fields are passed as nil and taken as empty strings.
+type lookupFunc func(apiVersion, kind, namespace, name any) (map[string]any, error)
+
+// lookupPlaceholder is the lookupCR function of the templates rendered without looking up the cluster, e.g. when the
+// reference is loaded or compared to local files. Like the lookup function of Helm without a cluster, it returns an
+// empty object.
+func lookupPlaceholder(any, any, any, any) (map[string]any, error) {
+	return map[string]any{}, nil
+}
+
+func lookupArg(v any) string {
+	if v == nil {
+		return ""
+	}
+	return fmt.Sprint(v)
+}
+
+// lookupExecutor is implemented by the templates that can look up cluster objects while they render
+type lookupExecutor interface {
+	ExecWithLookup(params map[string]any, lookupCR lookupFunc) (*unstructured.Unstructured, []string, error)
+}
+
+// execWithLookup renders the template with lookupCR bound to the cluster, if the template supports it, and returns
+// the warnings of the render
+func execWithLookup(temp ReferenceTemplate, params map[string]any, lookupCR lookupFunc) (*unstructured.Unstructured, []string, error) {
+	if t, ok := temp.(lookupExecutor); ok && lookupCR != nil {
+		return t.ExecWithLookup(params, lookupCR)
+	}
+	return execWithWarnings(temp, params)
+}
+
+// bindLookup returns a copy of the template set where lookupCR looks up the cluster. The parsed templates are shared
+// by concurrent renders, so the functions are bound to a copy, along with include so the partials it executes use the
+// same functions.
+func bindLookup(t *template.Template, lookupCR lookupFunc) (*template.Template, error) {
+	clone, err := t.Clone()
+	if err != nil {
+		return nil, fmt.Errorf("failed to bind lookupCR: %w", err)
+	}
+	return clone.Funcs(template.FuncMap{"include": includeFunc(clone), "lookupCR": lookupCR}), nil
+}
+
+// clusterLookup looks up the cluster objects requested by the templates. Objects are fetched once per run and the
+// requests are rate limited, so templates rendered for many CRs don't flood the API server.
+type clusterLookup struct {
+	f       kcmdutil.Factory
+	limiter flowcontrol.RateLimiter
+	mu      sync.Mutex
+	objects map[string]*lookupResult
+}
+
+type lookupResult struct {
+	once   sync.Once
+	object map[string]any
+	err    error
+}
+
+func newClusterLookup(f kcmdutil.Factory, qps float64) *clusterLookup {
+	return &clusterLookup{
+		f:       f,
+		limiter: flowcontrol.NewTokenBucketRateLimiter(float32(qps), int(math.Max(1, math.Ceil(qps)))),
+		objects: make(map[string]*lookupResult),
+	}
+}
+
+// lookupFunc returns the lookupCR function of the renders of a run, the requests are given up when the context is done
+func (l *clusterLookup) lookupFunc(ctx context.Context) lookupFunc {
+	if l == nil {
+		return nil
+	}
+	return func(apiVersionArg, kindArg, namespaceArg, nameArg any) (map[string]any, error) {
+		apiVersion, kind, namespace, name := lookupArg(apiVersionArg), lookupArg(kindArg), lookupArg(namespaceArg), lookupArg(nameArg)
+		key := strings.Join([]string{apiVersion, kind, namespace, name}, FieldSeparator)
+		l.mu.Lock()
+		result, ok := l.objects[key]
+		if !ok {
+			result = &lookupResult{}
+			l.objects[key] = result
+		}
+		l.mu.Unlock()
+		result.once.Do(func() {
+			result.object, result.err = l.get(ctx, apiVersion, kind, namespace, name)
+		})
+		if result.err != nil {
+			return nil, result.err
+		}
+		// Templates can modify the objects they're given (e.g. with set), each render gets its own copy
+		return runtime.DeepCopyJSON(result.object), nil
+	}
+}
+
+func (l *clusterLookup) get(ctx context.Context, apiVersion, kind, namespace, name string) (map[string]any, error) {
+	if l.f == nil {
+		// Replayed comparisons only look up the objects of the recording
+		return nil, fmt.Errorf("lookupCR %s %s/%s: the object wasn't looked up when the comparison was recorded", kind, namespace, name)
+	}
+	gv, err := schema.ParseGroupVersion(apiVersion)
+	if err != nil {
+		return nil, fmt.Errorf("lookupCR: invalid apiVersion %q: %w", apiVersion, err)
+	}
+	if err := l.limiter.Wait(ctx); err != nil {
+		return nil, fmt.Errorf("lookupCR %s %s/%s: %w", kind, namespace, name, err)
+	}
+	mapper, err := l.f.ToRESTMapper()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create rest mapper: %w", err)
+	}
+	mapping, err := mapper.RESTMapping(gv.WithKind(kind).GroupKind(), gv.Version)
+	if err != nil {
+		return nil, fmt.Errorf("lookupCR: failed to map %s to a resource: %w", gv.WithKind(kind), err)
+	}
+	if mapping.Scope.Name() != meta.RESTScopeNameNamespace {
+		namespace = ""
+	} else if namespace == "" {
+		return nil, fmt.Errorf("lookupCR: %s is namespaced, the namespace of %s is required", kind, name)
+	}
+	client, err := l.f.UnstructuredClientForMapping(mapping)
+	if err != nil {
+		return nil, fmt.Errorf("lookupCR: failed to create client for %s: %w", gv.WithKind(kind), err)
+	}
+	obj, err := resource.NewHelper(client, mapping).Get(namespace, name)
+	if apierrors.IsNotFound(err) {
+		return map[string]any{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("lookupCR %s %s/%s: %w", kind, namespace, name, err)
+	}
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, fmt.Errorf("lookupCR: failed to convert %s %s/%s: %w", kind, namespace, name, err)
+	}
+	return content, nil
+}
+
+// recorded returns the objects that were looked up by their apiVersion_kind_namespace_name once the comparison is
+// done, the lookups that failed are left out
+func (l *clusterLookup) recorded() map[string]map[string]any {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	objects := make(map[string]map[string]any)
+	for key, result := range l.objects {
+		if result.err == nil {
+			objects[key] = result.object
+		}
+	}
+	return objects
+}
+
+// templatesUsingLookups returns the paths of the templates calling lookupCR
+func templatesUsingLookups(temps []ReferenceTemplate) []string {
+	var paths []string
+	for _, temp := range temps {
+		tree := temp.GetTemplateTree()
+		if tree == nil {
+			continue
+		}
+		uses := false
+		walkTemplateNodes(tree.Root, func(n parse.Node) {
+			if ident, ok := n.(*parse.IdentifierNode); ok && ident.Ident == "lookupCR" {
+				uses = true
+			}
+		})
+		if uses {
+			paths = append(paths, temp.GetPath())
+		}
+	}
+	return paths
+}
@@ -0,0 +1,101 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"testing"
+	"text/template"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/cli-runtime/pkg/resource"
+	"k8s.io/client-go/rest/fake"
+	cmdtesting "k8s.io/kubectl/pkg/cmd/testing"
+)
+
+func TestClusterLookup(t *testing.T) {
+	secret := &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"metadata":   map[string]any{"name": "app-tls", "namespace": "example"},
+		"data":       map[string]any{"tls.crt": "Y2VydA=="},
+	}}
+	requests := 0
+	tf := cmdtesting.NewTestFactory()
+	defer tf.Cleanup()
+	tf.UnstructuredClient = &fake.RESTClient{
+		NegotiatedSerializer: resource.UnstructuredPlusDefaultContentConfig().NegotiatedSerializer,
+		Client: fake.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
+			requests++
+			if req.URL.Path != "/namespaces/example/secrets/app-tls" {
+				return &http.Response{StatusCode: http.StatusNotFound, Header: cmdtesting.DefaultHeader(),
+					Body: io.NopCloser(bytes.NewReader([]byte(`{"kind":"Status","apiVersion":"v1","status":"Failure","reason":"NotFound","code":404}`)))}, nil
+			}
+			b, err := secret.MarshalJSON()
+			require.NoError(t, err)
+			return &http.Response{StatusCode: http.StatusOK, Header: cmdtesting.DefaultHeader(), Body: io.NopCloser(bytes.NewReader(b))}, nil
+		}),
+	}
+
+	lookupCR := newClusterLookup(tf, 100).lookupFunc(context.Background())
+	obj, err := lookupCR("v1", "Secret", "example", "app-tls")
+	require.NoError(t, err)
+	require.Equal(t, "Y2VydA==", obj["data"].(map[string]any)["tls.crt"])
+	obj["data"] = nil
+
+	obj, err = lookupCR("v1", "Secret", "example", "app-tls")
+	require.NoError(t, err)
+	require.Equal(t, "Y2VydA==", obj["data"].(map[string]any)["tls.crt"], "objects returned to templates should be copies")
+	require.Equal(t, 1, requests, "objects should be fetched once")
+
+	obj, err = lookupCR("v1", "Secret", "example", "missing")
+	require.NoError(t, err)
+	require.Empty(t, obj)
+
+	_, err = lookupCR("v1", "Secret", nil, "app-tls")
+	require.ErrorContains(t, err, "Secret is namespaced, the namespace of app-tls is required")
+
+	_, err = lookupCR("v1/v2/v3", "Secret", "example", "app-tls")
+	require.ErrorContains(t, err, "invalid apiVersion")
+
+	var nilLookup *clusterLookup
+	require.Nil(t, nilLookup.lookupFunc(context.Background()))
+}
+
+func TestBindLookup(t *testing.T) {
+	tmpl := newTemplate("cm.yaml")
+	_, err := tmpl.New("functions.tmpl").Parse(`{{ define "cert" }}{{ (lookupCR "v1" "Secret" "example" .).data.cert }}{{ end }}`)
+	require.NoError(t, err)
+	_, err = tmpl.Parse(`cert: {{ include "cert" "app-tls" }}`)
+	require.NoError(t, err)
+
+	lookupCR := func(_, _, _, name any) (map[string]any, error) {
+		return map[string]any{"data": map[string]any{"cert": name}}, nil
+	}
+	bound, err := bindLookup(tmpl, lookupCR)
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, bound.Execute(&buf, nil))
+	require.Equal(t, "cert: app-tls", buf.String(), "partials executed by include should use the bound lookupCR")
+
+	buf.Reset()
+	require.NoError(t, tmpl.Execute(&buf, nil))
+	require.Equal(t, "cert: <no value>", buf.String(), "the parsed template should keep the placeholder")
+}
+
+func TestTemplatesUsingLookups(t *testing.T) {
+	parse := func(content string) ReferenceTemplate {
+		tmpl, err := template.New("t").Funcs(FuncMap()).Parse(content)
+		require.NoError(t, err)
+		return ReferenceTemplateV1{Template: tmpl, Path: content}
+	}
+	temps := []ReferenceTemplate{
+		parse(`data: {{ (lookupCR "v1" "Secret" "ns" "name").data }}`),
+		parse(`data: {{ .data }}`),
+	}
+	require.Equal(t, []string{`data: {{ (lookupCR "v1" "Secret" "ns" "name").data }}`}, templatesUsingLookups(temps))
+}
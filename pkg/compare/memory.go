@@ -0,0 +1,149 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"runtime/metrics"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// diffCollector records the diffs of a run and the statistics of the summary computed from them as the CRs are
+// compared. Streamed diffs are written right away and not kept, only the statistics are, so the memory used by a
+// streamed run doesn't grow with the diffs.
+type diffCollector struct {
+	streamer   *diffStreamer
+	diffs      []DiffSum
+	components componentCounter
+	namespaces namespaceCounter
+	// severities counts the CRs with diffs by severity when the reference declares fieldSeverities
+	severities *DiffSeveritiesSummary
+	warnings   []TemplateWarning
+	// numChanged is the number of CRs that changed since the snapshot compared to
+	numChanged int
+}
+
+func newDiffCollector(streamer *diffStreamer) *diffCollector {
+	return &diffCollector{streamer: streamer, diffs: make([]DiffSum, 0), components: componentCounter{}, namespaces: namespaceCounter{}}
+}
+
+// add records the diff of a CR, it isn't safe for concurrent use
+func (c *diffCollector) add(d DiffSum) {
+	c.components.add(d)
+	c.namespaces.add(d)
+	c.severities.add(d)
+	c.warnings = append(c.warnings, diffWarnings(d)...)
+	if d.ChangedSinceSnapshot() {
+		c.numChanged++
+	}
+	if c.streamer != nil {
+		c.streamer.write(d)
+		return
+	}
+	c.diffs = append(c.diffs, d)
+}
+
+// severitiesSummary returns a copy of the counts of the CRs with diffs by severity, nil without fieldSeverities
+func (c *diffCollector) severitiesSummary() *DiffSeveritiesSummary {
+	if c.severities == nil {
+		return nil
+	}
+	summary := *c.severities
+	return &summary
+}
+
+// unmatchedCR returns what is kept of a CR matched to no template until the end of the run: its identity, which is
+// all the summary and --generate-config need, unless the whole CR is exported with --export-unmatched
+func (o *Options) unmatchedCR(cr *unstructured.Unstructured) *unstructured.Unstructured {
+	if o.exportUnmatched != "" {
+		return cr
+	}
+	identity := &unstructured.Unstructured{Object: map[string]any{}}
+	identity.SetAPIVersion(cr.GetAPIVersion())
+	identity.SetKind(cr.GetKind())
+	identity.SetNamespace(cr.GetNamespace())
+	identity.SetName(cr.GetName())
+	return identity
+}
+
+// memoryCheckInterval is how often the heap is checked against --max-memory
+var memoryCheckInterval = time.Second
+
+// heapMetric is the memory occupied by the objects of the heap, live or not yet collected
+const heapMetric = "/memory/classes/heap/objects:bytes"
+
+// memoryLimitExceeded is the cause of stopping the comparison once the heap outgrew --max-memory even after a garbage
+// collection
+type memoryLimitExceeded struct {
+	limit int64
+}
+
+func (e memoryLimitExceeded) Error() string {
+	return fmt.Sprintf("memory usage exceeded --max-memory %s, --stream keeps less in memory",
+		resource.NewQuantity(e.limit, resource.BinarySI))
+}
+
+// parseMemoryLimit parses the value of --max-memory, a quantity such as 2Gi
+func parseMemoryLimit(value string) (int64, error) {
+	q, err := resource.ParseQuantity(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid --max-memory %q: %w", value, err)
+	}
+	if q.Sign() <= 0 {
+		return 0, fmt.Errorf("--max-memory must be positive, got %s", value)
+	}
+	return q.Value(), nil
+}
+
+// heapSize returns the memory occupied by the objects of the heap
+func heapSize() int64 {
+	sample := []metrics.Sample{{Name: heapMetric}}
+	metrics.Read(sample)
+	if sample[0].Value.Kind() != metrics.KindUint64 {
+		return 0
+	}
+	return int64(sample[0].Value.Uint64()) // nolint:gosec
+}
+
+// watchMemory makes the garbage collector keep the heap under the limit and stops the comparison if it can't, the
+// comparison is then reported as interrupted like when it times out. The returned function stops watching and
+// restores the previous soft memory limit of the process.
+func watchMemory(ctx context.Context, limit int64, stop context.CancelCauseFunc) func() {
+	if limit <= 0 {
+		return func() {}
+	}
+	previous := debug.SetMemoryLimit(limit)
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(memoryCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if heapSize() <= limit {
+					continue
+				}
+				// Garbage that wasn't collected yet doesn't count
+				runtime.GC()
+				if heapSize() > limit {
+					stop(memoryLimitExceeded{limit: limit})
+					return
+				}
+			case <-ctx.Done():
+				return
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		debug.SetMemoryLimit(previous)
+	}
+}
@@ -0,0 +1,83 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestDiffCollector(t *testing.T) {
+	diffs := []DiffSum{
+		{CRName: "v1_ConfigMap_ns_a", CorrelatedTemplate: "cm.yaml", Part: "p", Component: "c", DiffOutput: "diff", Warnings: []string{"deprecated"}},
+		{CRName: "v1_ConfigMap_ns_b", CorrelatedTemplate: "cm.yaml", Part: "p", Component: "c", SnapshotDiffOutput: "diff"},
+	}
+
+	buffered := newDiffCollector(nil)
+	out := &bytes.Buffer{}
+	streamed := newDiffCollector(&diffStreamer{out: out})
+	for _, d := range diffs {
+		buffered.add(d)
+		streamed.add(d)
+	}
+	require.Equal(t, diffs, buffered.diffs)
+	require.Empty(t, streamed.diffs, "streamed diffs shouldn't be kept in memory")
+	require.Contains(t, out.String(), "v1_ConfigMap_ns_a")
+	for _, c := range []*diffCollector{buffered, streamed} {
+		require.Equal(t, componentStats(diffs), c.components.stats())
+		require.Equal(t, templateWarnings(diffs), c.warnings)
+		require.Equal(t, 1, c.numChanged)
+	}
+}
+
+func TestUnmatchedCR(t *testing.T) {
+	cr := &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   map[string]any{"name": "a", "namespace": "ns", "labels": map[string]any{"app": "a"}},
+		"data":       map[string]any{"key": "value"},
+	}}
+	o := &Options{}
+	require.Equal(t, map[string]any{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   map[string]any{"name": "a", "namespace": "ns"},
+	}, o.unmatchedCR(cr).Object, "only the identity of unmatched CRs should be kept")
+
+	o.exportUnmatched = t.TempDir()
+	require.Same(t, cr, o.unmatchedCR(cr), "exported CRs should be kept whole")
+}
+
+func TestParseMemoryLimit(t *testing.T) {
+	limit, err := parseMemoryLimit("2Gi")
+	require.NoError(t, err)
+	require.Equal(t, int64(2<<30), limit)
+	_, err = parseMemoryLimit("0")
+	require.Error(t, err)
+	_, err = parseMemoryLimit("lots")
+	require.Error(t, err)
+}
+
+func TestWatchMemory(t *testing.T) {
+	interval := memoryCheckInterval
+	memoryCheckInterval = time.Millisecond
+	t.Cleanup(func() { memoryCheckInterval = interval })
+
+	ctx, stop := context.WithCancelCause(context.Background())
+	defer stop(nil)
+	unwatch := watchMemory(ctx, 1, stop)
+	select {
+	case <-ctx.Done():
+	case <-time.After(10 * time.Second):
+		require.Fail(t, "the comparison should be stopped once the heap outgrows the limit")
+	}
+	unwatch()
+	require.True(t, errors.As(context.Cause(ctx), &memoryLimitExceeded{}))
+	require.Contains(t, context.Cause(ctx).Error(), "--max-memory 1")
+}
@@ -0,0 +1,168 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/samber/lo"
+)
+
+const metricsPrefix = "kube_compare_"
+
+// metricsLabelEscaper escapes label values as required by the Prometheus text format
+var metricsLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+type metricSample struct {
+	labels [][2]string
+	value  int
+}
+
+type metricsWriter struct {
+	sb strings.Builder
+}
+
+func (w *metricsWriter) gauge(name, help string, samples ...metricSample) {
+	fmt.Fprintf(&w.sb, "# HELP %s%s %s\n# TYPE %s%s gauge\n", metricsPrefix, name, help, metricsPrefix, name)
+	for _, sample := range samples {
+		labels := lo.Map(sample.labels, func(label [2]string, _ int) string {
+			return fmt.Sprintf(`%s="%s"`, label[0], metricsLabelEscaper.Replace(label[1]))
+		})
+		if len(labels) > 0 {
+			fmt.Fprintf(&w.sb, "%s%s{%s} %d\n", metricsPrefix, name, strings.Join(labels, ","), sample.value)
+		} else {
+			fmt.Fprintf(&w.sb, "%s%s %d\n", metricsPrefix, name, sample.value)
+		}
+	}
+}
+
+// contextSummary is the summary of the comparison of a cluster, the context is empty unless multiple clusters are
+// compared
+type contextSummary struct {
+	context string
+	// contextLabel is the name of the label of the context, context unless set
+	contextLabel string
+	summary      *Summary
+}
+
+// summaryGauges are the gauges with a single sample per summary
+var summaryGauges = []struct {
+	name  string
+	help  string
+	value func(s *Summary) int
+}{
+	{"crs_total", "Number of cluster CRs that were compared to the reference.", func(s *Summary) int { return s.TotalCRs }},
+	{"crs_with_diffs", "Number of cluster CRs that differ from their reference template.", func(s *Summary) int { return s.NumDiffCRs }},
+	{"missing_crs", "Number of required reference CRs that are missing from the cluster.", func(s *Summary) int { return s.NumMissing }},
+	{"unmatched_crs", "Number of cluster CRs that weren't matched to a reference template.", func(s *Summary) int { return len(s.UnmatchedCRS) }},
+	{"patched_crs", "Number of cluster CRs compared to a template patched by user overrides.", func(s *Summary) int { return s.PatchedCRs }},
+	{"unavailable_kinds", "Number of kinds that couldn't be listed from the cluster.", func(s *Summary) int { return len(s.UnavailableKinds) }},
+}
+
+// templateGauges are the gauges with a sample per template cluster CRs were correlated to
+var templateGauges = []struct {
+	name  string
+	help  string
+	value func(s TemplateStats) int
+}{
+	{"template_correlated_crs", "Number of cluster CRs correlated to the template.", func(s TemplateStats) int { return s.CorrelatedCRs }},
+	{"template_crs_with_diffs", "Number of cluster CRs that differ from the template.", func(s TemplateStats) int { return s.CRsWithDiffs }},
+	{"template_changed_lines", "Number of changed lines in the diffs of the cluster CRs correlated to the template.", func(s TemplateStats) int { return s.ChangedLines }},
+}
+
+// Metrics returns the summary as gauges in the Prometheus text format
+func (s *Summary) Metrics() string {
+	return metricsOf([]contextSummary{{summary: s}})
+}
+
+// metricsOf returns the gauges of the summaries in the Prometheus text format, samples of summaries of a context are
+// labeled with it
+func metricsOf(summaries []contextSummary) string {
+	w := &metricsWriter{}
+	withContext := func(cs contextSummary, labels ...[2]string) [][2]string {
+		if cs.context == "" {
+			return labels
+		}
+		label := cs.contextLabel
+		if label == "" {
+			label = "context"
+		}
+		return append([][2]string{{label, cs.context}}, labels...)
+	}
+
+	var samples []metricSample
+	for _, cs := range summaries {
+		samples = append(samples, metricSample{labels: withContext(cs, [2]string{"metadata_hash", cs.summary.MetadataHash}), value: 1})
+	}
+	w.gauge("reference_info", "Information about the reference the cluster was compared to, always 1.", samples...)
+	for _, g := range summaryGauges {
+		samples = nil
+		for _, cs := range summaries {
+			samples = append(samples, metricSample{labels: withContext(cs), value: g.value(cs.summary)})
+		}
+		w.gauge(g.name, g.help, samples...)
+	}
+
+	samples = nil
+	for _, cs := range summaries {
+		var componentSamples []metricSample
+		for part, components := range cs.summary.ValidationIssues {
+			for component, issue := range components {
+				componentSamples = append(componentSamples, metricSample{labels: withContext(cs, [2]string{"part", part}, [2]string{"component", component}), value: len(issue.CRs)})
+			}
+		}
+		sort.Slice(componentSamples, func(i, j int) bool {
+			a, b := componentSamples[i].labels, componentSamples[j].labels
+			for k := range a {
+				if a[k][1] != b[k][1] {
+					return a[k][1] < b[k][1]
+				}
+			}
+			return false
+		})
+		samples = append(samples, componentSamples...)
+	}
+	w.gauge("component_missing_crs", "Number of reference CRs missing from the cluster per component with validation issues.", samples...)
+
+	for _, g := range templateGauges {
+		samples = nil
+		for _, cs := range summaries {
+			templates := lo.Keys(cs.summary.TemplateStats)
+			sort.Strings(templates)
+			for _, name := range templates {
+				samples = append(samples, metricSample{labels: withContext(cs, [2]string{"template", name}), value: g.value(cs.summary.TemplateStats[name])})
+			}
+		}
+		w.gauge(g.name, g.help, samples...)
+	}
+	return w.sb.String()
+}
+
+// writeMetricsFile writes the metrics of the summary to the path, the file is replaced atomically so a collector
+// reading it never sees a partially written file
+func writeMetricsFile(path string, summaries ...contextSummary) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write metrics file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.WriteString(metricsOf(summaries)); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write metrics file: %w", err)
+	}
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write metrics file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write metrics file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write metrics file: %w", err)
+	}
+	return nil
+}
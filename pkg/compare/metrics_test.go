@@ -0,0 +1,104 @@
+package compare
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSummaryMetrics(t *testing.T) {
+	sum := &Summary{
+		ValidationIssues: map[string]map[string]ValidationIssue{
+			"part-b": {"comp": {Msg: "Missing CRs", CRs: []string{"a.yaml"}}},
+			"part-a": {
+				"comp-2":     {Msg: "Missing CRs", CRs: []string{"b.yaml", "c.yaml"}},
+				`comp-"1"\n`: {Msg: "Missing CRs", CRs: []string{"d.yaml"}},
+			},
+		},
+		NumMissing:       4,
+		UnmatchedCRS:     []string{"v1_ConfigMap_ns_cm"},
+		NumDiffCRs:       2,
+		TotalCRs:         10,
+		MetadataHash:     "abc",
+		PatchedCRs:       1,
+		UnavailableKinds: []UnavailableKind{{Kind: "Foo"}},
+		TemplateStats: map[string]TemplateStats{
+			"cm.yaml":     {CorrelatedCRs: 3, CRsWithDiffs: 2, ChangedLines: 7},
+			"secret.yaml": {CorrelatedCRs: 1},
+		},
+	}
+	expected := `# HELP kube_compare_reference_info Information about the reference the cluster was compared to, always 1.
+# TYPE kube_compare_reference_info gauge
+kube_compare_reference_info{metadata_hash="abc"} 1
+# HELP kube_compare_crs_total Number of cluster CRs that were compared to the reference.
+# TYPE kube_compare_crs_total gauge
+kube_compare_crs_total 10
+# HELP kube_compare_crs_with_diffs Number of cluster CRs that differ from their reference template.
+# TYPE kube_compare_crs_with_diffs gauge
+kube_compare_crs_with_diffs 2
+# HELP kube_compare_missing_crs Number of required reference CRs that are missing from the cluster.
+# TYPE kube_compare_missing_crs gauge
+kube_compare_missing_crs 4
+# HELP kube_compare_unmatched_crs Number of cluster CRs that weren't matched to a reference template.
+# TYPE kube_compare_unmatched_crs gauge
+kube_compare_unmatched_crs 1
+# HELP kube_compare_patched_crs Number of cluster CRs compared to a template patched by user overrides.
+# TYPE kube_compare_patched_crs gauge
+kube_compare_patched_crs 1
+# HELP kube_compare_unavailable_kinds Number of kinds that couldn't be listed from the cluster.
+# TYPE kube_compare_unavailable_kinds gauge
+kube_compare_unavailable_kinds 1
+# HELP kube_compare_component_missing_crs Number of reference CRs missing from the cluster per component with validation issues.
+# TYPE kube_compare_component_missing_crs gauge
+kube_compare_component_missing_crs{part="part-a",component="comp-\"1\"\\n"} 1
+kube_compare_component_missing_crs{part="part-a",component="comp-2"} 2
+kube_compare_component_missing_crs{part="part-b",component="comp"} 1
+# HELP kube_compare_template_correlated_crs Number of cluster CRs correlated to the template.
+# TYPE kube_compare_template_correlated_crs gauge
+kube_compare_template_correlated_crs{template="cm.yaml"} 3
+kube_compare_template_correlated_crs{template="secret.yaml"} 1
+# HELP kube_compare_template_crs_with_diffs Number of cluster CRs that differ from the template.
+# TYPE kube_compare_template_crs_with_diffs gauge
+kube_compare_template_crs_with_diffs{template="cm.yaml"} 2
+kube_compare_template_crs_with_diffs{template="secret.yaml"} 0
+# HELP kube_compare_template_changed_lines Number of changed lines in the diffs of the cluster CRs correlated to the template.
+# TYPE kube_compare_template_changed_lines gauge
+kube_compare_template_changed_lines{template="cm.yaml"} 7
+kube_compare_template_changed_lines{template="secret.yaml"} 0
+`
+	assert.Equal(t, expected, sum.Metrics())
+
+	path := filepath.Join(t.TempDir(), "kube_compare.prom")
+	require.NoError(t, os.WriteFile(path, []byte("stale"), 0o644))
+	require.NoError(t, writeMetricsFile(path, contextSummary{summary: sum}))
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, expected, string(content))
+	entries, err := os.ReadDir(filepath.Dir(path))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "temporary files should be cleaned up")
+}
+
+func TestCountChangedLines(t *testing.T) {
+	unified := `diff -u -N /tmp/MERGED/cm /tmp/LIVE/cm
+--- /tmp/MERGED/cm	2024-01-01
++++ /tmp/LIVE/cm	2024-01-01
+@@ -1,4 +1,4 @@
+ apiVersion: v1
+ data:
+-  key: value
++  key: other
++  new: value
+`
+	assert.Equal(t, 3, countChangedLines(unified))
+	internal := `--- MERGED/cm
++++ LIVE/cm
+~ data.key: "value" -> "other"
++ data.new: "value"
+`
+	assert.Equal(t, 2, countChangedLines(internal))
+	assert.Equal(t, 0, countChangedLines(""))
+}
@@ -0,0 +1,93 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"fmt"
+	"slices"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/klog/v2"
+	kcmdutil "k8s.io/kubectl/pkg/cmd/util"
+)
+
+// namespaceScope restricts the live resources listed to some namespaces, for users whose roles only allow listing
+// resources in the namespaces they are bound to. Namespaced kinds are listed in every namespace instead of across the
+// cluster, cluster scoped kinds are still listed across the cluster.
+type namespaceScope struct {
+	// names are the namespaces passed with --namespaces
+	names []string
+	// selector is the label selector of --namespace-selector, the namespaces matching it are listed with the names
+	selector string
+	// namespaces are the namespaces the resources are listed in, sorted
+	namespaces []string
+}
+
+func (s namespaceScope) isSet() bool {
+	return len(s.names) > 0 || s.selector != ""
+}
+
+// resolve lists the namespaces matching the selector, listing namespaces requires a role allowing it while the
+// namespaces passed by name don't
+func (s *namespaceScope) resolve(f kcmdutil.Factory) error {
+	s.namespaces = slices.Clone(s.names)
+	if s.selector != "" {
+		r := f.NewBuilder().
+			Unstructured().
+			ResourceTypes("namespaces").
+			LabelSelectorParam(s.selector).
+			Flatten().
+			Do()
+		infos, err := r.Infos()
+		if err != nil {
+			return fmt.Errorf("failed to list the namespaces matching --namespace-selector: %w", err)
+		}
+		if len(infos) == 0 {
+			klog.Warningf("No namespace matches --namespace-selector %s", s.selector)
+		}
+		for _, info := range infos {
+			s.namespaces = append(s.namespaces, info.Name)
+		}
+	}
+	slices.Sort(s.namespaces)
+	s.namespaces = slices.Compact(s.namespaces)
+	return nil
+}
+
+// namespacesOf returns the namespaces the resources of the type are listed in, a single empty namespace stands for the
+// whole cluster
+func (s namespaceScope) namespacesOf(f kcmdutil.Factory, resourceType string) ([]string, error) {
+	if !s.isSet() {
+		return []string{""}, nil
+	}
+	mapper, err := f.ToRESTMapper()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create rest mapper: %w", err)
+	}
+	mapping, err := restMappingFor(mapper, resourceType)
+	if err != nil {
+		return nil, err
+	}
+	if mapping.Scope.Name() == meta.RESTScopeNameRoot {
+		return []string{""}, nil
+	}
+	return s.namespaces, nil
+}
+
+// restMappingFor maps a type in the form of {kind} or {kind}.{version}.{group} to its resource
+func restMappingFor(mapper meta.RESTMapper, resourceType string) (*meta.RESTMapping, error) {
+	var mapping *meta.RESTMapping
+	var err error
+	gvk, gk := schema.ParseKindArg(resourceType)
+	if gvk != nil {
+		mapping, err = mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	}
+	if gvk == nil || err != nil {
+		mapping, err = mapper.RESTMapping(gk)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to map %s to a resource: %w", resourceType, err)
+	}
+	return mapping, nil
+}
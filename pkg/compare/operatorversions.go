@@ -0,0 +1,206 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/Masterminds/semver/v3"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const (
+	csvKind             = "ClusterServiceVersion"
+	csvGroup            = "operators.coreos.com"
+	olmPackageLabel     = "operators.coreos.com/"
+	olmCopiedFromLabel  = "olm.copiedFrom"
+	invalidOperatorDecl = "operatorVersions entry %d: %w"
+)
+
+const (
+	OperatorCompliant    = "Compliant"
+	OperatorDrifted      = "Drifted"
+	OperatorMissing      = "Missing"
+	OperatorNotInstalled = "NotInstalled"
+)
+
+// csvNameVersion matches the conventional <package>.v<version> name of a CSV
+var csvNameVersion = regexp.MustCompile(`^(.+?)\.v?(\d+\.\d+\.\d+.*)$`)
+
+// OperatorVersion declares an operator package that is expected to be installed in the cluster by OLM and the range
+// of versions it's allowed to be installed at
+type OperatorVersion struct {
+	Package     string `json:"package"`
+	Namespace   string `json:"namespace,omitempty"`
+	Versions    string `json:"versions"`
+	Optional    bool   `json:"optional,omitempty"`
+	Description string `json:"description,omitempty"`
+	constraints *semver.Constraints
+}
+
+func (ov *OperatorVersion) validate() error {
+	if ov.Package == "" {
+		return errors.New("package must be specified")
+	}
+	if ov.Versions == "" {
+		return fmt.Errorf("versions must be specified for package %s", ov.Package)
+	}
+	var err error
+	ov.constraints, err = semver.NewConstraint(ov.Versions)
+	if err != nil {
+		return fmt.Errorf("invalid versions for package %s: %w", ov.Package, err)
+	}
+	return nil
+}
+
+// OperatorVersionResult contains the compliance of the installed versions of an operator package
+type OperatorVersionResult struct {
+	Package     string   `json:"Package"`
+	Namespace   string   `json:"Namespace,omitempty"`
+	Expected    string   `json:"Expected"`
+	Installed   []string `json:"Installed,omitempty"`
+	Status      string   `json:"Status"`
+	Description string   `json:"Description,omitempty"`
+}
+
+// OperatorVersionsSummary contains the version compliance of all the operator packages declared in the reference
+type OperatorVersionsSummary struct {
+	Results    []OperatorVersionResult `json:"Results"`
+	NumDrifted int                     `json:"NumDrifted"`
+	NumMissing int                     `json:"NumMissing"`
+}
+
+func (s *OperatorVersionsSummary) hasIssues() bool {
+	return s != nil && (s.NumDrifted > 0 || s.NumMissing > 0)
+}
+
+// Table returns the results formatted as a table
+func (s OperatorVersionsSummary) Table() string {
+	var sb strings.Builder
+	w := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PACKAGE\tNAMESPACE\tEXPECTED\tINSTALLED\tSTATUS")
+	for _, r := range s.Results {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+			r.Package, orDash(r.Namespace), r.Expected, orDash(strings.Join(r.Installed, ",")), r.Status)
+	}
+	_ = w.Flush()
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// installedOperator is an operator package version found in a CSV
+type installedOperator struct {
+	pkg       string
+	namespace string
+	version   string
+}
+
+// operatorVersionTracker collects the CSVs seen during a run and checks them against the operator versions declared
+// in the reference
+type operatorVersionTracker struct {
+	expected []*OperatorVersion
+
+	// mu guards installed, the CRs are visited concurrently
+	mu        sync.Mutex
+	installed []installedOperator
+}
+
+func newOperatorVersionTracker(expected []*OperatorVersion) *operatorVersionTracker {
+	if len(expected) == 0 {
+		return nil
+	}
+	return &operatorVersionTracker{expected: expected}
+}
+
+func isCSV(obj *unstructured.Unstructured) bool {
+	gvk := obj.GroupVersionKind()
+	return gvk.Kind == csvKind && gvk.Group == csvGroup
+}
+
+// add records the operator package installed by a CSV, CSVs copied by OLM to other namespaces are ignored
+func (t *operatorVersionTracker) add(obj *unstructured.Unstructured) {
+	if t == nil || !isCSV(obj) {
+		return
+	}
+	if _, ok := obj.GetLabels()[olmCopiedFromLabel]; ok {
+		return
+	}
+	pkg, nameVersion := "", ""
+	if m := csvNameVersion.FindStringSubmatch(obj.GetName()); m != nil {
+		pkg, nameVersion = m[1], m[2]
+	}
+	for label := range obj.GetLabels() {
+		if name, ok := strings.CutPrefix(label, olmPackageLabel); ok {
+			if p, ok := strings.CutSuffix(name, "."+obj.GetNamespace()); ok {
+				pkg = p
+				break
+			}
+		}
+	}
+	if pkg == "" {
+		pkg = obj.GetName()
+	}
+	version, _, _ := NestedString(obj.Object, "spec", "version")
+	if version == "" {
+		version = nameVersion
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.installed = append(t.installed, installedOperator{pkg: pkg, namespace: obj.GetNamespace(), version: version})
+}
+
+// Summarize checks the installed version of every declared operator package
+func (t *operatorVersionTracker) Summarize() *OperatorVersionsSummary {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	sum := &OperatorVersionsSummary{}
+	for _, ov := range t.expected {
+		result := OperatorVersionResult{
+			Package:     ov.Package,
+			Namespace:   ov.Namespace,
+			Expected:    ov.Versions,
+			Description: ov.Description,
+			Status:      OperatorCompliant,
+		}
+		for _, op := range t.installed {
+			if op.pkg != ov.Package || (ov.Namespace != "" && op.namespace != ov.Namespace) {
+				continue
+			}
+			result.Installed = append(result.Installed, op.version)
+			v, err := semver.NewVersion(op.version)
+			// Operators commonly use the prerelease part of the version for build timestamps
+			// (e.g. 4.16.0-202409051837), only the version core is checked so these won't be excluded by the constraints
+			if err != nil || !ov.constraints.Check(semver.New(v.Major(), v.Minor(), v.Patch(), "", "")) {
+				result.Status = OperatorDrifted
+			}
+		}
+		slices.Sort(result.Installed)
+		result.Installed = slices.Compact(result.Installed)
+		switch {
+		case len(result.Installed) == 0 && ov.Optional:
+			result.Status = OperatorNotInstalled
+		case len(result.Installed) == 0:
+			result.Status = OperatorMissing
+			sum.NumMissing++
+		case result.Status == OperatorDrifted:
+			sum.NumDrifted++
+		}
+		sum.Results = append(sum.Results, result)
+	}
+	return sum
+}
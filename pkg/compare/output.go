@@ -3,14 +3,15 @@ package compare
 import (
 	"bytes"
 	"crypto/sha256"
-	"encoding/json"
 	"fmt"
 	"io"
+	"slices"
 	"sort"
 	"strings"
 	"text/template"
 
 	"github.com/Masterminds/sprig/v3"
+	"github.com/openshift/kube-compare/pkg/api"
 	"github.com/samber/lo"
 
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
@@ -20,22 +21,47 @@ import (
 
 // DiffSum Contains the diff output and correlation info of a specific CR
 type DiffSum struct {
-	DiffOutput         string   `json:"DiffOutput"`
-	CorrelatedTemplate string   `json:"CorrelatedTemplate"`
-	CRName             string   `json:"CRName"`
+	DiffOutput         string `json:"DiffOutput"`
+	CorrelatedTemplate string `json:"CorrelatedTemplate"`
+	CRName             string `json:"CRName"`
+	// Part and Component are the reference component the diff is reported under, see templateComponents
+	Part               string   `json:"Part,omitempty"`
+	Component          string   `json:"Component,omitempty"`
 	Patched            string   `json:"Patched,omitempty"`
 	OverrideReasons    []string `json:"OverrideReason,omitempty"`
 	Description        string   `json:"description,omitempty"`
+	SnapshotDiffOutput string   `json:"SnapshotDiffOutput,omitempty"`
+	Warnings           []string `json:"Warnings,omitempty"`
+	// Severity is the highest severity of the differences of the CR, set when the reference declares fieldSeverities
+	Severity api.Severity `json:"Severity,omitempty"`
+	// TemplateAnnotations are the annotations of the template set in its config
+	TemplateAnnotations
+	// namespace and kind are the namespace and the kind of the cluster CR, the diffs can be grouped by them
+	namespace string
+	kind      string
 }
 
 func (s DiffSum) String() string {
 	t := `
 Cluster CR: {{ .CRName }}
 Reference File: {{ .CorrelatedTemplate }}
+{{- if and .Severity .DiffOutput }}
+Severity: {{ .Severity }}
+{{- end }}
 {{- if .Description }}
 Description:
 {{ .Description | indent 2 }}
 {{- end }}
+{{- if .DocURL }}
+Documentation: {{ .DocURL }}
+{{- end }}
+{{- if .Owner }}
+Owner: {{ .Owner }}
+{{- end }}
+{{- if and .Remediation .DiffOutput }}
+Remediation:
+{{ .Remediation | indent 2 }}
+{{- end }}
 Diff Output: {{or .DiffOutput "None" }}
 {{- if ne (len  .Patched) 0 }}
 Patched with {{ .Patched }}
@@ -48,6 +74,15 @@ Patch Reasons:
 {{- end }}
 {{- end }}
 {{- end }}
+{{- if .SnapshotDiffOutput }}
+Changes Since Snapshot: {{ .SnapshotDiffOutput }}
+{{- end }}
+{{- if ne (len .Warnings) 0 }}
+Warnings:
+{{- range .Warnings }}
+- {{ . }}
+{{- end }}
+{{- end }}
 `
 	var buf bytes.Buffer
 	tmpl, _ := template.New("DiffSummary").Funcs(sprig.TxtFuncMap()).Parse(t)
@@ -63,6 +98,10 @@ func (s DiffSum) WasPatched() bool {
 	return s.Patched != ""
 }
 
+func (s DiffSum) ChangedSinceSnapshot() bool {
+	return s.SnapshotDiffOutput != ""
+}
+
 // Summary Contains all info included in the Summary output of the compare command
 type Summary struct {
 	ValidationIssues map[string]map[string]ValidationIssue `json:"ValidationIssuses"`
@@ -72,16 +111,116 @@ type Summary struct {
 	TotalCRs         int                                   `json:"TotalCRs"`
 	MetadataHash     string                                `json:"MetadataHash"`
 	PatchedCRs       int                                   `json:"patchedCRs"`
+	Snapshot         *SnapshotSummary                      `json:"Snapshot,omitempty"`
+	OperatorVersions *OperatorVersionsSummary              `json:"OperatorVersions,omitempty"`
+	UnavailableKinds []UnavailableKind                     `json:"UnavailableKinds,omitempty"`
+	NotApplicable    []NotApplicableTemplate               `json:"NotApplicable,omitempty"`
+	TemplateStats    map[string]TemplateStats              `json:"TemplateStats,omitempty"`
+	Components       []ComponentStats                      `json:"Components,omitempty"`
+	Namespaces       []NamespaceStats                      `json:"Namespaces,omitempty"`
+	RenderFailures   []RenderFailure                       `json:"RenderFailures,omitempty"`
+	AmbiguousMatches []AmbiguousMatch                      `json:"AmbiguousMatches,omitempty"`
+	CountMismatches  []CountMismatch                       `json:"CountMismatches,omitempty"`
+	Warnings         []TemplateWarning                     `json:"Warnings,omitempty"`
+	DriftAnnotations *DriftAnnotationsSummary              `json:"DriftAnnotations,omitempty"`
+	DriftEvents      *DriftEventsSummary                   `json:"DriftEvents,omitempty"`
+	// DiffSeverities counts the CRs with diffs by severity, set when the reference declares fieldSeverities
+	DiffSeverities *DiffSeveritiesSummary `json:"DiffSeverities,omitempty"`
+	// UnchangedCRs is the number of cluster CRs that didn't change since the run recorded in the run cache, their
+	// recorded results were reused (with --changed-only)
+	UnchangedCRs int `json:"UnchangedCRs,omitempty"`
+	// Interrupted is the reason the comparison was interrupted before all the cluster CRs were compared, the summary
+	// only covers the CRs compared until then
+	Interrupted string `json:"Interrupted,omitempty"`
+}
+
+// TemplateStats are the statistics of the cluster CRs correlated to a reference template
+type TemplateStats struct {
+	CorrelatedCRs int `json:"CorrelatedCRs"`
+	CRsWithDiffs  int `json:"CRsWithDiffs"`
+	// ChangedLines is the total number of removed and added lines in the diffs of the CRs (changed fields with the
+	// internal diff engine)
+	ChangedLines int `json:"ChangedLines"`
+}
+
+// countChangedLines counts the removed and added lines of a unified diff, or the changed fields in the output of the
+// internal diff engine
+func countChangedLines(diff string) int {
+	return len(changedLines(diff))
+}
+
+// changedLines returns the removed and added lines of a unified diff, or the changed fields in the output of the
+// internal diff engine, leaving out the headers of the compared files
+func changedLines(diff string) []string {
+	var lines []string
+	for _, line := range strings.Split(diff, "\n") {
+		if strings.HasPrefix(line, "--- ") || strings.HasPrefix(line, "+++ ") {
+			continue
+		}
+		if strings.HasPrefix(line, "-") || strings.HasPrefix(line, "+") || strings.HasPrefix(line, "~") {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// hasDiffs returns true if differences were found between the reference and the cluster
+func (s *Summary) hasDiffs() bool {
+	return s.hasCRDiffs() || len(s.ValidationIssues) != 0 || len(s.CountMismatches) != 0
+}
+
+// hasCRDiffs returns true if differences other than the validation issues (e.g. missing CRs) were found
+func (s *Summary) hasCRDiffs() bool {
+	return s.NumDiffCRs != 0 || s.OperatorVersions.hasIssues() || len(s.UnavailableKinds) != 0 || len(s.RenderFailures) != 0
+}
+
+// filterValidationIssues removes validation issues caused by templates whose kind wasn't compared in the run
+// (filtered out by kind or couldn't be fetched from the cluster), such templates can't be reported missing. A group
+// requiring one of its templates is only dropped when none of its templates were compared.
+func (s *Summary) filterValidationIssues(excluded map[string]bool) {
+	if len(excluded) == 0 {
+		return
+	}
+	for partName, part := range s.ValidationIssues {
+		for compName, issue := range part {
+			filtered := slices.DeleteFunc(slices.Clone(issue.CRs), func(cr string) bool { return excluded[cr] })
+			if len(filtered) == len(issue.CRs) {
+				continue
+			}
+			switch issue.Msg {
+			case MissingCRsMsg:
+				s.NumMissing -= len(issue.CRs) - len(filtered)
+			case OneOfRequiredMsg:
+				if len(filtered) == 0 {
+					s.NumMissing--
+				}
+			}
+			if len(filtered) == 0 {
+				delete(part, compName)
+				continue
+			}
+			issue.CRs = filtered
+			part[compName] = issue
+		}
+		if len(part) == 0 {
+			delete(s.ValidationIssues, partName)
+		}
+	}
 }
 
-func newSummary(reference Reference, c *MetricsTracker, numDiffCRs int, templates []ReferenceTemplate, numPatchedCRs int) *Summary {
-	s := Summary{NumDiffCRs: numDiffCRs, PatchedCRs: numPatchedCRs}
+func newSummary(reference Reference, c *MetricsTracker, numDiffCRs int, metadataHash string, numPatchedCRs int) *Summary {
+	s := Summary{NumDiffCRs: numDiffCRs, PatchedCRs: numPatchedCRs, MetadataHash: metadataHash}
 	s.ValidationIssues, s.NumMissing = reference.GetValidationIssues(c.MatchedTemplatesNames)
 	s.TotalCRs = c.getTotalCRs()
+	s.TemplateStats = c.templateStats()
 	s.UnmatchedCRS = lo.Map(c.UnMatchedCRs, func(r *unstructured.Unstructured, i int) string {
 		return apiKindNamespaceName(r)
 	})
+	return &s
+}
 
+// getMetadataHash returns the hash identifying the reference config and the content of its templates
+func getMetadataHash(reference Reference, templates []ReferenceTemplate) string {
 	hash := sha256.New()
 
 	refBytes, err := yaml.Marshal(reference)
@@ -91,21 +230,30 @@ func newSummary(reference Reference, c *MetricsTracker, numDiffCRs int, template
 	hash.Write(refBytes)
 
 	for _, template := range templates {
-		for _, node := range template.GetTemplateTree().Root.Nodes {
-			hash.Write([]byte(node.String()))
-		}
+		hash.Write([]byte(getTemplateContent(template)))
 	}
 
-	s.MetadataHash = fmt.Sprintf("%x", hash.Sum(nil))
+	return fmt.Sprintf("%x", hash.Sum(nil))
+}
 
-	return &s
+// getTemplateContent returns the parsed content of a template, avoiding parsing templates loaded from the template cache
+func getTemplateContent(template ReferenceTemplate) string {
+	if t, ok := template.(interface{ templateContent() string }); ok {
+		return t.templateContent()
+	}
+	return treeContent(template.GetTemplateTree())
 }
 
 func (s Summary) String() string {
 	t := `
 Summary
 CRs with diffs: {{ .NumDiffCRs }}/{{ .TotalCRs }}
-{{- if ne (len  .ValidationIssues) 0 }}
+{{- with .DiffSeverities }}
+CRs with diffs by severity: {{ .Critical }} critical, {{ .Warning }} warning, {{ .Info }} info
+{{- end }}
+{{- if .Interrupted }}
+Comparison interrupted ({{ .Interrupted }}): only the CRs compared until then are reported, missing CRs aren't checked
+{{- else if ne (len  .ValidationIssues) 0 }}
 CRs in reference missing from the cluster: {{.NumMissing}}
 {{- range $groupname, $group := .ValidationIssues }}
 {{ $groupname }}:
@@ -125,6 +273,18 @@ CRs in reference missing from the cluster: {{.NumMissing}}
 {{- else}}
 No validation issues with the cluster
 {{- end }}
+{{- if ne (len .NotApplicable) 0 }}
+Templates not applicable to the cluster: {{ len .NotApplicable }}
+{{- range .NotApplicable }}
+{{ .Template }}: {{ .Reason }}
+{{- end }}
+{{- end }}
+{{- if ne (len .CountMismatches) 0 }}
+Templates with an unexpected number of CRs (count mismatch): {{ len .CountMismatches }}
+{{- range .CountMismatches }}
+{{ .Template }}: expected {{ .Expected }}, found {{ .Found }}
+{{- end }}
+{{- end }}
 {{- if ne (len  .UnmatchedCRS) 0 }}
 Cluster CRs unmatched to reference CRs: {{len  .UnmatchedCRS}}
 {{ toYaml .UnmatchedCRS}}
@@ -137,6 +297,73 @@ Cluster CRs with patches applied: {{ .PatchedCRs }}
 {{- else}}
 No patched CRs
 {{- end }}
+{{- if ne .UnchangedCRs 0 }}
+CRs unchanged since the cached run, their results were reused: {{ .UnchangedCRs }}
+{{- end }}
+{{- with .Snapshot }}
+CRs changed since snapshot {{ .Dir }}: {{ .NumChanged }}
+{{- if ne (len .NewCRs) 0 }}
+CRs not in snapshot: {{ len .NewCRs }}
+{{ toYaml .NewCRs }}
+{{- end }}
+{{- if ne (len .RemovedCRs) 0 }}
+CRs in snapshot missing from this run: {{ len .RemovedCRs }}
+{{ toYaml .RemovedCRs }}
+{{- end }}
+{{- end }}
+{{- if ne (len .UnavailableKinds) 0 }}
+Kinds that couldn't be fetched from the cluster: {{ len .UnavailableKinds }}
+{{- range .UnavailableKinds }}
+{{ .Kind }}: kind unavailable: {{ .Reason }}
+  Error: {{ .Error }}
+  {{- if ne (len .Templates) 0 }}
+  Affected templates:
+  {{- range .Templates }}
+  - {{ . }}
+  {{- end }}
+  {{- end }}
+{{- end }}
+{{- end }}
+{{- if ne (len .Warnings) 0 }}
+Warnings reported by templates: {{ len .Warnings }}
+{{- range .Warnings }}
+{{ .Template }} for {{ .CR }}: {{ .Message }}
+{{- end }}
+{{- end }}
+{{- if ne (len .RenderFailures) 0 }}
+Templates that failed to render: {{ len .RenderFailures }}
+{{- range .RenderFailures }}
+{{ .Template }} for {{ .CR }}: {{ .Error }}
+{{- end }}
+{{- end }}
+{{- if ne (len .AmbiguousMatches) 0 }}
+CRs matching several templates equally: {{ len .AmbiguousMatches }}
+{{- range .AmbiguousMatches }}
+{{ .CR }}: {{ join ", " .Templates }}
+{{- end }}
+{{- end }}
+{{- with .DriftAnnotations }}
+CRs annotated with drift: {{ .Annotated }}, CRs with drift annotations removed: {{ .Removed }}
+{{- if ne (len .Failed) 0 }}
+CRs that couldn't be patched: {{ len .Failed }}
+{{- range .Failed }}
+{{ . }}
+{{- end }}
+{{- end }}
+{{- end }}
+{{- with .DriftEvents }}
+Drift events emitted: {{ .Emitted }}
+{{- if ne (len .Failed) 0 }}
+CRs whose drift event couldn't be created: {{ len .Failed }}
+{{- range .Failed }}
+{{ . }}
+{{- end }}
+{{- end }}
+{{- end }}
+{{- with .OperatorVersions }}
+Operator versions (drifted: {{ .NumDrifted }}, missing: {{ .NumMissing }}):
+{{ .Table }}
+{{- end }}
 `
 	var buf bytes.Buffer
 	tmpl, _ := template.New("Summary").Funcs(sprig.TxtFuncMap()).Funcs(template.FuncMap{"toYaml": toYAML}).Parse(t)
@@ -149,17 +376,48 @@ type Output struct {
 	Summary *Summary   `json:"Summary"`
 	Diffs   *[]DiffSum `json:"Diffs"`
 	patches []*UserOverride
+	color   bool
+	// groupBy is how the diffs are grouped in the text output, one of GroupByModes. They're grouped by component by
+	// default.
+	groupBy string
 }
 
-func (o Output) String(showEmptyDiffs bool) string {
-	sort.Slice(*o.Diffs, func(i, j int) bool {
-		return (*o.Diffs)[i].CorrelatedTemplate+(*o.Diffs)[i].CRName < (*o.Diffs)[j].CorrelatedTemplate+(*o.Diffs)[j].CRName
+// sortDiffs sorts the diffs in the order they're printed: by group, then by template and CR
+func sortDiffs(diffs []DiffSum, groupBy string) {
+	sort.Slice(diffs, func(i, j int) bool {
+		a, b := diffs[i], diffs[j]
+		if groupBy == GroupByNamespace && a.namespace != b.namespace {
+			return a.namespace < b.namespace
+		}
+		if groupBy == GroupByKind && a.kind != b.kind {
+			return a.kind < b.kind
+		}
+		if a.Part != b.Part || a.Component != b.Component {
+			return referenceComponent{a.Part, a.Component}.less(referenceComponent{b.Part, b.Component})
+		}
+		return a.CorrelatedTemplate+a.CRName < b.CorrelatedTemplate+b.CRName
 	})
+}
 
-	diffParts := []string{}
+// String prints the diffs grouped by the reference component they're reported under, or by the namespace or the kind
+// of the CRs with --group-by, followed by the summary. Every group is headed by the number of CRs with diffs out of the
+// CRs of the group.
+func (o Output) String(showEmptyDiffs bool) string {
+	sortDiffs(*o.Diffs, o.groupBy)
+	headers := groupHeaders(*o.Diffs, o.Summary, o.groupBy)
 
+	diffParts := []string{}
+	var group *string
 	for _, diffSum := range *o.Diffs {
-		if showEmptyDiffs || diffSum.HasDiff() || diffSum.WasPatched() {
+		if showEmptyDiffs || diffSum.HasDiff() || diffSum.WasPatched() || diffSum.ChangedSinceSnapshot() {
+			if g, grouped := diffGroup(diffSum, o.groupBy); grouped && (group == nil || *group != g) {
+				group = &g
+				diffParts = append(diffParts, fmt.Sprintln(headers[g]))
+			}
+			if o.color {
+				diffSum.DiffOutput = colorizeDiff(diffSum.DiffOutput)
+				diffSum.SnapshotDiffOutput = colorizeDiff(diffSum.SnapshotDiffOutput)
+			}
 			diffParts = append(diffParts, fmt.Sprintln(diffSum.String()))
 		}
 	}
@@ -170,38 +428,19 @@ func (o Output) String(showEmptyDiffs bool) string {
 		str = fmt.Sprintf("%s\n%s\n%s\n", DiffSeparator, partsStr, DiffSeparator)
 	}
 
+	if o.groupBy == GroupByNamespace {
+		return fmt.Sprintf("%s%s\n%s\n", str, o.Summary.String(), namespacesSummary(o.Summary.Namespaces))
+	}
 	return fmt.Sprintf("%s%s\n", str, o.Summary.String())
 }
 
+// Print writes the output in the format with the renderer registered for it and returns the number of bytes written
 func (o Output) Print(format string, out io.Writer, showEmptyDiffs bool) (int, error) {
-	var (
-		content []byte
-		err     error
-	)
-	switch format {
-	case Json:
-		content, err = json.Marshal(o)
-		if err != nil {
-			return 0, fmt.Errorf("failed to marshal output to json: %w", err)
-		}
-		content = append(content, []byte("\n")...)
-
-	case Yaml:
-		content, err = yaml.Marshal(o)
-		if err != nil {
-			return 0, fmt.Errorf("failed to marshal output to yaml: %w", err)
-		}
-	case PatchYaml:
-		content, err = yaml.Marshal(o.patches)
-		if err != nil {
-			return 0, fmt.Errorf("failed to marshal patches to yaml: %w", err)
-		}
-	default:
-		content = []byte(o.String(showEmptyDiffs))
-	}
-	n, err := out.Write(content)
-	if err != nil {
-		return n, fmt.Errorf("error occurred when writing output: %w", err)
+	r, ok := getRenderer(format)
+	if !ok {
+		return 0, fmt.Errorf("no renderer registered for output format %q", format)
 	}
-	return n, nil
+	w := &countingWriter{out: out}
+	err := r.Render(w, o, showEmptyDiffs)
+	return w.n, err // nolint:wrapcheck
 }
@@ -0,0 +1,49 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilterValidationIssues(t *testing.T) {
+	s := &Summary{
+		ValidationIssues: map[string]map[string]ValidationIssue{
+			"part": {
+				"missing":  {Msg: MissingCRsMsg, CRs: []string{"a.yaml", "b.yaml"}},
+				"oneOf":    {Msg: OneOfRequiredMsg, CRs: []string{"c.yaml", "d.yaml"}},
+				"excluded": {Msg: OneOfRequiredMsg, CRs: []string{"e.yaml"}},
+			},
+			"other": {
+				"missing": {Msg: MissingCRsMsg, CRs: []string{"f.yaml"}},
+			},
+		},
+		NumMissing: 5,
+	}
+	s.filterValidationIssues(map[string]bool{"a.yaml": true, "c.yaml": true, "e.yaml": true, "f.yaml": true})
+	require.Equal(t, map[string]map[string]ValidationIssue{
+		"part": {
+			"missing": {Msg: MissingCRsMsg, CRs: []string{"b.yaml"}},
+			"oneOf":   {Msg: OneOfRequiredMsg, CRs: []string{"d.yaml"}},
+		},
+	}, s.ValidationIssues)
+	require.Equal(t, 2, s.NumMissing)
+}
+
+func TestOutputGroupedByComponent(t *testing.T) {
+	diffs := []DiffSum{
+		{CRName: "c", CorrelatedTemplate: "c.yaml", Part: "part", Component: "b", DiffOutput: "diff c"},
+		{CRName: "a", CorrelatedTemplate: "a.yaml", Part: "part", Component: "a"},
+		{CRName: "b", CorrelatedTemplate: "b.yaml", Part: "part", Component: "a", DiffOutput: "diff b"},
+	}
+	require.Equal(t, []ComponentStats{
+		{Part: "part", Component: "a", CorrelatedCRs: 2, CRsWithDiffs: 1},
+		{Part: "part", Component: "b", CorrelatedCRs: 1, CRsWithDiffs: 1},
+	}, componentStats(diffs))
+
+	out := Output{Summary: &Summary{}, Diffs: &diffs}.String(false)
+	require.Regexp(t, `(?s)Component: part/a \(CRs with diffs: 1/2\).*Cluster CR: b.*Component: part/b \(CRs with diffs: 1/1\).*Cluster CR: c`, out)
+	require.NotContains(t, out, "Cluster CR: a\n", "CRs without diffs are only shown in verbose mode")
+}
@@ -5,6 +5,7 @@ package compare
 import (
 	"fmt"
 	"io/fs"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
@@ -18,8 +19,16 @@ type Reference interface {
 	GetAPIVersion() string
 	GetTemplates() []ReferenceTemplate
 	GetValidationIssues(matchedTemplates map[string]int) (map[string]map[string]ValidationIssue, int)
+	GetComponentTemplates() map[string]map[string][]string
 	GetFieldsToOmit() FieldsToOmit
 	GetTemplateFunctionFiles() []string
+	GetOperatorVersions() []*OperatorVersion
+	GetCorrelationFieldGroups() [][][]string
+	GetUnorderedLists() []string
+	GetDefaults() []FieldDefault
+	GetNormalizeQuantities() bool
+	GetMatchTieBreakers() *MatchTieBreakers
+	GetFieldSeverities() []FieldSeverity
 }
 
 type ReferenceTemplate interface {
@@ -37,6 +46,37 @@ type TemplateConfig interface {
 	GetAllowMerge() bool
 	GetFieldsToOmitRefs() []string
 	GetInlineDiffFuncs() map[string]inlineDiffType
+	GetUnorderedLists() []string
+	GetDefaults() []FieldDefault
+	GetQuantityFields() []string
+	GetRenderTimeout() string
+	GetExpectedCount() (minCount, maxCount *int)
+	GetFieldSelector() string
+	GetCondition() *TemplateCondition
+	GetComparisonMode() string
+	GetAnnotations() TemplateAnnotations
+	GetCorrelateBy() *CorrelateBy
+	GetDependsOn() []string
+}
+
+// TemplateAnnotations are reported with the diffs of the CRs correlated to the template
+type TemplateAnnotations struct {
+	// DocURL links to the documentation of the settings of the template
+	DocURL string `json:"docURL,omitempty"`
+	// Owner is the team or person to contact about the template
+	Owner string `json:"owner,omitempty"`
+	// Remediation tells how to fix a CR differing from the template
+	Remediation string `json:"remediation,omitempty"`
+}
+
+func (a TemplateAnnotations) validate() error {
+	if a.DocURL == "" {
+		return nil
+	}
+	if u, err := url.Parse(a.DocURL); err != nil || !u.IsAbs() {
+		return fmt.Errorf("docURL %q isn't an absolute URL", a.DocURL)
+	}
+	return nil
 }
 
 type FieldsToOmit interface {
@@ -92,10 +132,14 @@ func parseYaml[T any](fsys fs.FS, filePath string, structType *T, fileNotFoundEr
 
 type UserConfig struct {
 	CorrelationSettings CorrelationSettings `json:"correlationSettings"`
+	FieldsToOmit        []*UserFieldsToOmit `json:"fieldsToOmit,omitempty"`
 }
 
 type CorrelationSettings struct {
 	ManualCorrelation ManualCorrelation `json:"manualCorrelation"`
+	// DifferenceWeights are added to the differenceWeights of the matchTieBreakers of the reference, replacing the
+	// weights the reference sets for the same fields
+	DifferenceWeights []FieldWeight `json:"differenceWeights,omitempty"`
 }
 
 type ManualCorrelation struct {
@@ -109,7 +153,13 @@ func parseDiffConfig(filePath string) (UserConfig, error) {
 		return result, fmt.Errorf("failed to get absolute path for %s: %w", filePath, err)
 	}
 	err = parseYaml(os.DirFS("/"), confPath[1:], &result, userConfNotExistsError, userConfigNotInFormat)
-	return result, err
+	if err != nil {
+		return result, err
+	}
+	if err := result.process(); err != nil {
+		return result, fmt.Errorf(userConfigNotInFormat, err)
+	}
+	return result, nil
 }
 
 func ParseTemplates(ref Reference, fsys fs.FS) ([]ReferenceTemplate, error) {
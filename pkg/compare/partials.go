@@ -0,0 +1,141 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// newTemplate creates an empty reference template with the template functions, including include bound to the
+// templates that will be parsed into it
+func newTemplate(name string) *template.Template {
+	t := template.New(name).Funcs(FuncMap())
+	return t.Funcs(template.FuncMap{"include": includeFunc(t)})
+}
+
+// includeFunc returns the include function of the template set: it executes the named template (usually a partial
+// defined in a template function file) and returns the output as a string, so unlike the template action it can be
+// piped into other functions such as indent or nindent.
+func includeFunc(t *template.Template) func(name string, data any) (string, error) {
+	return func(name string, data any) (string, error) {
+		var sb strings.Builder
+		if err := t.ExecuteTemplate(&sb, name, data); err != nil {
+			return "", err //nolint: wrapcheck
+		}
+		return sb.String(), nil
+	}
+}
+
+// includePlaceholder allows parsing templates calling include outside of a reference template set, calling it fails
+func includePlaceholder(string, any) (string, error) {
+	return "", errors.New("include can only be used in reference templates")
+}
+
+// invocation is a named template invoked by the template action or by the include function
+type invocation struct {
+	name    string
+	include bool
+}
+
+// invokedTemplates returns the named templates invoked within the node. Include calls must use a constant name so
+// they can be resolved and checked for recursion when the reference is parsed, an error is returned for others.
+func invokedTemplates(node parse.Node) ([]invocation, error) {
+	var invoked []invocation
+	var errs []error
+	walkTemplateNodes(node, func(n parse.Node) {
+		switch n := n.(type) {
+		case *parse.TemplateNode:
+			invoked = append(invoked, invocation{name: n.Name})
+		case *parse.CommandNode:
+			ident, ok := n.Args[0].(*parse.IdentifierNode)
+			if !ok || ident.Ident != "include" {
+				return
+			}
+			if len(n.Args) > 1 {
+				if name, ok := n.Args[1].(*parse.StringNode); ok {
+					invoked = append(invoked, invocation{name: name.Text, include: true})
+					return
+				}
+			}
+			errs = append(errs, fmt.Errorf("include must be called with a constant template name: %s", n))
+		}
+	})
+	return invoked, errors.Join(errs...)
+}
+
+// validatePartials checks that every named template invoked in the template set is defined and that no named template
+// includes itself. Recursion through include isn't bounded when the template is executed so it's rejected, recursion
+// using only the template action is left to text/template.
+func validatePartials(t *template.Template) error {
+	graph := make(map[string][]invocation)
+	var errs []error
+	var visit func(name string)
+	visit = func(name string) {
+		if _, ok := graph[name]; ok {
+			return
+		}
+		graph[name] = nil
+		named := t.Lookup(name)
+		if named == nil || named.Tree == nil {
+			return
+		}
+		invoked, err := invokedTemplates(named.Tree.Root)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		graph[name] = invoked
+		for _, inv := range invoked {
+			if t.Lookup(inv.name) == nil {
+				errs = append(errs, fmt.Errorf("template %q invokes undefined template %q", name, inv.name))
+				continue
+			}
+			visit(inv.name)
+		}
+	}
+	defined := make([]string, 0, len(t.Templates()))
+	for _, named := range t.Templates() {
+		defined = append(defined, named.Name())
+	}
+	sort.Strings(defined)
+	for _, name := range defined {
+		visit(name)
+	}
+
+	reaches := func(from, to string) bool {
+		seen := map[string]bool{}
+		stack := []string{from}
+		for len(stack) > 0 {
+			name := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if name == to {
+				return true
+			}
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+			for _, inv := range graph[name] {
+				stack = append(stack, inv.name)
+			}
+		}
+		return false
+	}
+	names := make([]string, 0, len(graph))
+	for name := range graph {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, inv := range graph[name] {
+			if inv.include && reaches(inv.name, name) {
+				errs = append(errs, fmt.Errorf("template %q recursively includes itself through %q", name, inv.name))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,119 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// pathFilter limits the fields of the CRs that are compared, the paths are JSONPaths
+type pathFilter struct {
+	only   []string
+	ignore []string
+
+	onlyPaths   [][]jsonPathSegment
+	ignorePaths [][]jsonPathSegment
+}
+
+func (f *pathFilter) process() error {
+	var errs []error
+	parse := func(flag string, exprs []string) [][]jsonPathSegment {
+		var result [][]jsonPathSegment
+		for _, expr := range exprs {
+			// Paths relative to the CR are accepted without the leading dot, e.g. spec.*
+			if !strings.ContainsAny(expr[:min(len(expr), 1)], ".[{$") {
+				expr = "." + expr
+			}
+			segments, err := parseJSONPath(expr)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("--%s: %w", flag, err))
+				continue
+			}
+			result = append(result, segments)
+		}
+		return result
+	}
+	f.onlyPaths = parse("only-path", f.only)
+	f.ignorePaths = parse("ignore-path", f.ignore)
+	if len(f.onlyPaths) > 0 {
+		// The identity fields are kept so the diffs still identify the CRs
+		for _, field := range identityFields {
+			segments := make([]jsonPathSegment, 0, len(field))
+			for _, key := range field {
+				segments = append(segments, jsonPathSegment{key: key})
+			}
+			f.onlyPaths = append(f.onlyPaths, segments)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// fieldTrie holds the paths of the selected fields, a selected node selects all the fields under it
+type fieldTrie struct {
+	selected bool
+	children map[string]*fieldTrie
+}
+
+func (t *fieldTrie) add(field []string) {
+	for _, key := range field {
+		if t.selected {
+			return
+		}
+		if t.children == nil {
+			t.children = make(map[string]*fieldTrie)
+		}
+		child, ok := t.children[key]
+		if !ok {
+			child = &fieldTrie{}
+			t.children[key] = child
+		}
+		t = child
+	}
+	t.selected = true
+	t.children = nil
+}
+
+// keepJSONPathFields returns a copy of the object holding only the fields selected by the JSONPaths, with the
+// mappings and lists leading to them. List items without selected fields are left out. The object isn't modified,
+// the selected fields are shared with it.
+func keepJSONPathFields(object map[string]any, jsonPaths [][]jsonPathSegment) map[string]any {
+	trie := &fieldTrie{}
+	for _, segments := range jsonPaths {
+		for _, field := range findJSONPathFields(object, segments) {
+			trie.add(field)
+		}
+	}
+	kept, _ := keepFields(object, trie).(map[string]any)
+	if kept == nil {
+		kept = map[string]any{}
+	}
+	return kept
+}
+
+func keepFields(value any, trie *fieldTrie) any {
+	if trie.selected {
+		return value
+	}
+	switch v := value.(type) {
+	case map[string]any:
+		kept := make(map[string]any)
+		for key, child := range trie.children {
+			if field, ok := v[key]; ok {
+				kept[key] = keepFields(field, child)
+			}
+		}
+		return kept
+	case []any:
+		var kept []any
+		for i, item := range v {
+			if child, ok := trie.children[strconv.Itoa(i)]; ok {
+				kept = append(kept, keepFields(item, child))
+			}
+		}
+		return kept
+	}
+	return value
+}
@@ -0,0 +1,69 @@
+package compare
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeepJSONPathFields(t *testing.T) {
+	object := map[string]any{
+		"kind":     "Pod",
+		"metadata": map[string]any{"name": "n", "labels": map[string]any{"a": "b"}},
+		"spec": map[string]any{
+			"containers": []any{
+				map[string]any{"name": "a", "image": "i1", "env": []any{"e1"}},
+				map[string]any{"name": "b", "env": []any{"e2"}},
+			},
+			"nodeName": "node",
+		},
+		"status": map[string]any{"phase": "Running"},
+	}
+	cases := []struct {
+		name      string
+		jsonPaths []string
+		expected  map[string]any
+	}{
+		{
+			name:      "nested field through a list, items without it are left out",
+			jsonPaths: []string{".spec.containers[*].image", ".metadata.name"},
+			expected: map[string]any{
+				"metadata": map[string]any{"name": "n"},
+				"spec":     map[string]any{"containers": []any{map[string]any{"image": "i1"}}},
+			},
+		},
+		{
+			name:      "parent and child paths",
+			jsonPaths: []string{".spec.containers[1].env", ".spec", ".spec.nodeName"},
+			expected:  map[string]any{"spec": object["spec"]},
+		},
+		{
+			name:      "nothing selected",
+			jsonPaths: []string{".missing"},
+			expected:  map[string]any{},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var jsonPaths [][]jsonPathSegment
+			for _, p := range c.jsonPaths {
+				segments, err := parseJSONPath(p)
+				require.NoError(t, err)
+				jsonPaths = append(jsonPaths, segments)
+			}
+			require.Equal(t, c.expected, keepJSONPathFields(object, jsonPaths))
+			require.Contains(t, object, "status", "the object should not be modified")
+		})
+	}
+}
+
+func TestPathFilterProcess(t *testing.T) {
+	f := pathFilter{only: []string{"spec.*"}, ignore: []string{".status"}}
+	require.NoError(t, f.process())
+	require.Len(t, f.onlyPaths, 1+len(identityFields), "the identity fields should be kept")
+	require.Equal(t, []jsonPathSegment{{key: "spec"}, {key: "*", wildcard: true}}, f.onlyPaths[0])
+	require.Len(t, f.ignorePaths, 1)
+
+	f = pathFilter{ignore: []string{".a[", ""}}
+	require.ErrorContains(t, f.process(), "--ignore-path")
+}
@@ -0,0 +1,160 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/term"
+	"k8s.io/cli-runtime/pkg/resource"
+)
+
+const (
+	ProgressAuto   = "auto"
+	ProgressAlways = "always"
+	ProgressNever  = "never"
+)
+
+var ProgressModes = []string{ProgressAuto, ProgressAlways, ProgressNever}
+
+const (
+	progressTTYInterval    = time.Second
+	progressNonTTYInterval = 10 * time.Second
+)
+
+// isTerminal checks if the writer is a terminal
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	return ok && term.IsTerminal(int(f.Fd()))
+}
+
+// progressReporter periodically writes a status line describing how far a run got, so that long runs
+// against large clusters don't look hung.
+type progressReporter struct {
+	out        io.Writer
+	tty        bool
+	interval   time.Duration
+	tracker    *MetricsTracker
+	totalKinds int
+
+	lock      sync.Mutex
+	start     time.Time
+	processed int
+	diffs     int
+	kinds     map[string]bool
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// newProgressReporter returns a reporter for the requested mode, or nil in case progress shouldn't be reported.
+// totalKinds is the number of resource types that will be fetched, it's used to estimate the remaining time
+// and should be 0 when unknown (local mode).
+func newProgressReporter(mode string, out io.Writer, tracker *MetricsTracker, totalKinds int) *progressReporter {
+	tty := isTerminal(out)
+	if mode == ProgressNever || (mode != ProgressAlways && !tty) {
+		return nil
+	}
+	interval := progressNonTTYInterval
+	if tty {
+		interval = progressTTYInterval
+	}
+	return &progressReporter{
+		out:        out,
+		tty:        tty,
+		interval:   interval,
+		tracker:    tracker,
+		totalKinds: totalKinds,
+		kinds:      make(map[string]bool),
+	}
+}
+
+// Start begins writing the status line periodically until Stop is called
+func (p *progressReporter) Start() {
+	if p == nil {
+		return
+	}
+	p.start = time.Now()
+	p.stop = make(chan struct{})
+	p.done = make(chan struct{})
+	go func() {
+		defer close(p.done)
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-p.stop:
+				return
+			case <-ticker.C:
+				p.print()
+			}
+		}
+	}()
+}
+
+// Stop writes a final status line and stops the reporter
+func (p *progressReporter) Stop() {
+	if p == nil {
+		return
+	}
+	close(p.stop)
+	<-p.done
+	p.print()
+	if p.tty {
+		fmt.Fprintln(p.out)
+	}
+}
+
+// addProcessed records that a resource was fetched and is being processed
+func (p *progressReporter) addProcessed(info *resource.Info) {
+	if p == nil {
+		return
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.processed++
+	if info.Mapping != nil {
+		p.kinds[info.Mapping.GroupVersionKind.String()] = true
+	}
+}
+
+// addDiff records that a processed resource has differences from its template
+func (p *progressReporter) addDiff() {
+	if p == nil {
+		return
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.diffs++
+}
+
+func (p *progressReporter) print() {
+	line := p.String()
+	if p.tty {
+		fmt.Fprintf(p.out, "\r%s\033[K", line)
+	} else {
+		fmt.Fprintln(p.out, line)
+	}
+}
+
+func (p *progressReporter) String() string {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	matched, unmatched := p.tracker.counts()
+	elapsed := time.Since(p.start).Round(time.Second)
+	line := fmt.Sprintf("Processed %d resources (matched: %d, unmatched: %d, with diffs: %d), elapsed %s",
+		p.processed, matched, unmatched, p.diffs, elapsed)
+	if p.totalKinds > 0 {
+		line += fmt.Sprintf(", kinds fetched %d/%d", len(p.kinds), p.totalKinds)
+		// The kind currently being processed isn't complete, so it can't be used to estimate the remaining time
+		if completed := len(p.kinds) - 1; completed > 0 && completed < p.totalKinds {
+			eta := time.Duration(float64(elapsed) / float64(completed) * float64(p.totalKinds-completed))
+			line += fmt.Sprintf(", ETA %s", eta.Round(time.Second))
+		}
+	}
+	return line
+}
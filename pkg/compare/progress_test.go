@@ -0,0 +1,40 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/cli-runtime/pkg/resource"
+)
+
+func TestProgressReporter(t *testing.T) {
+	out := new(bytes.Buffer)
+	require.Nil(t, newProgressReporter(ProgressAuto, out, NewMetricsTracker(), 0), "auto mode shouldn't report to a non terminal")
+	require.Nil(t, newProgressReporter(ProgressNever, out, NewMetricsTracker(), 0))
+
+	tracker := NewMetricsTracker()
+	p := newProgressReporter(ProgressAlways, out, tracker, 4)
+	require.NotNil(t, p)
+	p.start = time.Now().Add(-30 * time.Second)
+
+	for _, kind := range []string{"ConfigMap", "ConfigMap", "Secret", "Namespace"} {
+		p.addProcessed(&resource.Info{Mapping: &meta.RESTMapping{GroupVersionKind: schema.GroupVersionKind{Version: "v1", Kind: kind}}})
+	}
+	tracker.addMatch(ReferenceTemplateV1{Path: "cm.yaml"})
+	tracker.addUNMatch(&unstructured.Unstructured{})
+	p.addDiff()
+
+	require.Equal(t,
+		"Processed 4 resources (matched: 1, unmatched: 1, with diffs: 1), elapsed 30s, kinds fetched 3/4, ETA 30s",
+		p.String())
+
+	p.print()
+	require.Contains(t, out.String(), "Processed 4 resources")
+}
@@ -0,0 +1,142 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/cli-runtime/pkg/resource"
+	kcmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"k8s.io/utils/ptr"
+)
+
+const (
+	// PublishConfigMap publishes the results in a config map
+	PublishConfigMap = "configmap"
+	// PublishReport publishes the results in a ClusterCompareReport CR, its CRD must be installed in the cluster
+	PublishReport = "report"
+
+	// defaultPublishName is the name of the config map or report the results are published in
+	defaultPublishName = "cluster-compare-results"
+	// maxPublishedSize is the maximum size of the published results, objects are limited to 1MiB by the API server
+	maxPublishedSize = 1 << 20
+)
+
+var PublishTargets = []string{PublishConfigMap, PublishReport}
+
+// ReportGVK is the kind of the CRs the results are published in with --publish-results report
+var ReportGVK = schema.GroupVersionKind{Group: "cluster-compare.openshift.io", Version: "v1alpha1", Kind: "ClusterCompareReport"}
+
+// resultsPublisher writes the results of the comparison to a config map or a ClusterCompareReport CR of the cluster,
+// for dashboards and policies to consume them. The object is server side applied so it's created or updated in
+// place on every run.
+type resultsPublisher struct {
+	target    string
+	namespace string
+	name      string
+	// fullOutput also publishes the diffs, not only the summary
+	fullOutput bool
+	reference  string
+	now        func() time.Time
+}
+
+func (p resultsPublisher) isSet() bool {
+	return p.target != ""
+}
+
+// object returns the config map or report holding the results
+func (p *resultsPublisher) object(sum *Summary, diffs []DiffSum) (*unstructured.Unstructured, error) {
+	summary, err := json.Marshal(sum)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal the summary: %w", err)
+	}
+	var output []byte
+	if p.fullOutput {
+		output, err = json.Marshal(Output{Summary: sum, Diffs: &diffs})
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal the output: %w", err)
+		}
+	}
+	if size := len(summary) + len(output); size > maxPublishedSize {
+		return nil, fmt.Errorf("the results are too large to be published (%d bytes), the objects of the cluster are limited to %d bytes", size, maxPublishedSize)
+	}
+	timestamp := p.now().UTC().Format(time.RFC3339)
+
+	obj := &unstructured.Unstructured{Object: map[string]any{}}
+	switch p.target {
+	case PublishConfigMap:
+		obj.SetAPIVersion("v1")
+		obj.SetKind("ConfigMap")
+		data := map[string]any{"timestamp": timestamp, "reference": p.reference, "summary.json": string(summary)}
+		if output != nil {
+			data["output.json"] = string(output)
+		}
+		obj.Object["data"] = data
+	case PublishReport:
+		obj.SetGroupVersionKind(ReportGVK)
+		report := map[string]any{"timestamp": timestamp, "reference": p.reference}
+		var summaryValues map[string]any
+		if err := json.Unmarshal(summary, &summaryValues); err != nil {
+			return nil, fmt.Errorf("failed to convert the summary: %w", err)
+		}
+		report["summary"] = summaryValues
+		if output != nil {
+			var outputValues map[string]any
+			if err := json.Unmarshal(output, &outputValues); err != nil {
+				return nil, fmt.Errorf("failed to convert the output: %w", err)
+			}
+			report["output"] = outputValues
+		}
+		obj.Object["report"] = report
+	default:
+		return nil, fmt.Errorf("invalid publish target %q", p.target)
+	}
+	obj.SetNamespace(p.namespace)
+	obj.SetName(p.name)
+	obj.SetLabels(map[string]string{"app.kubernetes.io/managed-by": "cluster-compare"})
+	return obj, nil
+}
+
+// publish writes the results to the cluster
+func (p *resultsPublisher) publish(f kcmdutil.Factory, sum *Summary, diffs []DiffSum) error {
+	obj, err := p.object(sum, diffs)
+	if err != nil {
+		return err
+	}
+	mapper, err := f.ToRESTMapper()
+	if err != nil {
+		return fmt.Errorf("failed to create rest mapper: %w", err)
+	}
+	gvk := obj.GroupVersionKind()
+	mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return fmt.Errorf("failed to publish the results, is the %s CRD installed? %w", gvk.Kind, err)
+	}
+	client, err := f.UnstructuredClientForMapping(mapping)
+	if err != nil {
+		return fmt.Errorf("failed to create a client for %s: %w", gvk.Kind, err)
+	}
+	return p.apply(client, mapping, obj)
+}
+
+// apply server side applies the object, taking the ownership of its fields from other managers
+func (p *resultsPublisher) apply(client resource.RESTClient, mapping *meta.RESTMapping, obj *unstructured.Unstructured) error {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return fmt.Errorf("failed to marshal the results: %w", err)
+	}
+	_, err = resource.NewHelper(client, mapping).
+		WithFieldManager(serverSideDryRunFieldManager).
+		Patch(p.namespace, p.name, types.ApplyPatchType, data, &metav1.PatchOptions{Force: ptr.To(true)})
+	if err != nil {
+		return fmt.Errorf("failed to publish the results to %s %s/%s: %w", mapping.GroupVersionKind.Kind, p.namespace, p.name, err)
+	}
+	return nil
+}
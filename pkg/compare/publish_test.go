@@ -0,0 +1,94 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/cli-runtime/pkg/resource"
+	"k8s.io/client-go/rest/fake"
+	cmdtesting "k8s.io/kubectl/pkg/cmd/testing"
+)
+
+func newTestPublisher(target string, fullOutput bool) resultsPublisher {
+	return resultsPublisher{
+		target:     target,
+		namespace:  "compliance",
+		name:       defaultPublishName,
+		fullOutput: fullOutput,
+		reference:  "https://example.com/reference/metadata.yaml",
+		now:        func() time.Time { return time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC) },
+	}
+}
+
+func TestPublishedObject(t *testing.T) {
+	sum := &Summary{TotalCRs: 3, NumDiffCRs: 1, MetadataHash: "abc"}
+	diffs := []DiffSum{{CRName: "v1_ConfigMap_example_settings", CorrelatedTemplate: "cm.yaml", DiffOutput: "-a\n+b\n"}}
+
+	p := newTestPublisher(PublishConfigMap, false)
+	obj, err := p.object(sum, diffs)
+	require.NoError(t, err)
+	require.Equal(t, "ConfigMap", obj.GetKind())
+	require.Equal(t, "compliance", obj.GetNamespace())
+	require.Equal(t, defaultPublishName, obj.GetName())
+	data := obj.Object["data"].(map[string]any)
+	require.Equal(t, "2024-05-06T07:08:09Z", data["timestamp"])
+	require.Equal(t, p.reference, data["reference"])
+	require.Contains(t, data["summary.json"], `"TotalCRs":3`)
+	require.NotContains(t, data, "output.json", "the diffs should only be published with --publish-full-output")
+
+	p = newTestPublisher(PublishReport, true)
+	obj, err = p.object(sum, diffs)
+	require.NoError(t, err)
+	require.Equal(t, ReportGVK, obj.GroupVersionKind())
+	report := obj.Object["report"].(map[string]any)
+	require.Equal(t, float64(1), report["summary"].(map[string]any)["NumDiffCRs"])
+	require.Len(t, report["output"].(map[string]any)["Diffs"], 1)
+	_, found := report["summary"].(map[string]any)["Diffs"]
+	require.False(t, found, "the summary shouldn't hold the diffs")
+
+	diffs[0].DiffOutput = strings.Repeat("+a\n", maxPublishedSize/3)
+	_, err = p.object(sum, diffs)
+	require.ErrorContains(t, err, "too large to be published")
+}
+
+func TestPublishApply(t *testing.T) {
+	var requests []*http.Request
+	var bodies []string
+	client := &fake.RESTClient{
+		NegotiatedSerializer: resource.UnstructuredPlusDefaultContentConfig().NegotiatedSerializer,
+		Client: fake.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
+			body, err := io.ReadAll(req.Body)
+			require.NoError(t, err)
+			requests = append(requests, req)
+			bodies = append(bodies, string(body))
+			return &http.Response{StatusCode: http.StatusOK, Header: cmdtesting.DefaultHeader(), Body: io.NopCloser(bytes.NewReader(body))}, nil
+		}),
+	}
+	mapping := &meta.RESTMapping{
+		Resource:         schema.GroupVersionResource{Version: "v1", Resource: "configmaps"},
+		GroupVersionKind: schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"},
+		Scope:            meta.RESTScopeNamespace,
+	}
+
+	p := newTestPublisher(PublishConfigMap, false)
+	obj, err := p.object(&Summary{TotalCRs: 1}, nil)
+	require.NoError(t, err)
+	require.NoError(t, p.apply(client, mapping, obj))
+	require.Len(t, requests, 1)
+	require.Equal(t, http.MethodPatch, requests[0].Method)
+	require.Equal(t, "/namespaces/compliance/configmaps/"+defaultPublishName, requests[0].URL.Path)
+	require.Equal(t, string(types.ApplyPatchType), requests[0].Header.Get("Content-Type"))
+	require.Equal(t, "true", requests[0].URL.Query().Get("force"))
+	require.Equal(t, serverSideDryRunFieldManager, requests[0].URL.Query().Get("fieldManager"))
+	require.Contains(t, bodies[0], `"summary.json"`)
+}
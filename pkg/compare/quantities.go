@@ -0,0 +1,113 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"reflect"
+	"slices"
+	"strconv"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// quantityFields selects the fields of a template whose equal quantities or numbers are compared as equal
+type quantityFields struct {
+	all   bool
+	paths [][]string
+}
+
+// quantityFieldsFor returns the fields normalized for the template, nil if the template doesn't normalize any field.
+// The paths are validated when the reference is loaded.
+func quantityFieldsFor(ref Reference, temp ReferenceTemplate) *quantityFields {
+	fields := &quantityFields{all: ref.GetNormalizeQuantities()}
+	for _, p := range temp.GetConfig().GetQuantityFields() {
+		if listedPath, err := pathToList(p); err == nil {
+			fields.paths = append(fields.paths, listedPath)
+		}
+	}
+	if !fields.all && len(fields.paths) == 0 {
+		return nil
+	}
+	return fields
+}
+
+// selects checks if the field of the path or one of its parents is selected
+func (f *quantityFields) selects(path []string) bool {
+	if f.all {
+		return true
+	}
+	return slices.ContainsFunc(f.paths, func(p []string) bool {
+		return len(p) <= len(path) && slices.Equal(p, path[:len(p)])
+	})
+}
+
+// normalize returns a copy of the cluster object where the selected fields holding a quantity or a number equal to
+// the one of the template (e.g. 1Gi and 1024Mi, 0.5 and 500m, 1 and 1.0) take the representation of the template, so
+// only the quantities that differ are reported. The items of lists are paired by their index, they're selected by the
+// path of the list.
+func (f *quantityFields) normalize(template, clusterObj *unstructured.Unstructured) *unstructured.Unstructured {
+	if f == nil {
+		return clusterObj
+	}
+	normalized := clusterObj.DeepCopy()
+	f.normalizeValue(template.Object, normalized.Object, nil)
+	return normalized
+}
+
+func (f *quantityFields) normalizeValue(template, value any, path []string) any {
+	switch v := value.(type) {
+	case map[string]any:
+		t, ok := template.(map[string]any)
+		if !ok {
+			return value
+		}
+		for key, field := range v {
+			if templateField, ok := t[key]; ok {
+				v[key] = f.normalizeValue(templateField, field, append(slices.Clip(path), key))
+			}
+		}
+	case []any:
+		t, ok := template.([]any)
+		if !ok {
+			return value
+		}
+		for i := range min(len(t), len(v)) {
+			v[i] = f.normalizeValue(t[i], v[i], path)
+		}
+	default:
+		if f.selects(path) && !reflect.DeepEqual(template, value) && equalQuantities(template, value) {
+			return template
+		}
+	}
+	return value
+}
+
+// equalQuantities checks if both values are quantities or numbers of the same amount
+func equalQuantities(a, b any) bool {
+	qa, ok := toQuantity(a)
+	if !ok {
+		return false
+	}
+	qb, ok := toQuantity(b)
+	if !ok {
+		return false
+	}
+	return qa.Cmp(qb) == 0
+}
+
+func toQuantity(value any) (resource.Quantity, bool) {
+	var s string
+	switch v := value.(type) {
+	case string:
+		s = v
+	case int64:
+		s = strconv.FormatInt(v, 10)
+	case float64:
+		s = strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return resource.Quantity{}, false
+	}
+	q, err := resource.ParseQuantity(s)
+	return q, err == nil
+}
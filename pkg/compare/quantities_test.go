@@ -0,0 +1,63 @@
+package compare
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestEqualQuantities(t *testing.T) {
+	cases := []struct {
+		a, b  any
+		equal bool
+	}{
+		{a: "1Gi", b: "1024Mi", equal: true},
+		{a: "500m", b: "0.5", equal: true},
+		{a: "2", b: "2000m", equal: true},
+		{a: int64(1), b: float64(1), equal: true},
+		{a: int64(1), b: "1", equal: true},
+		{a: "1G", b: "1Gi", equal: false},
+		{a: "1Gi", b: "2Gi", equal: false},
+		{a: "abc", b: "abc", equal: false},
+		{a: true, b: "1", equal: false},
+	}
+	for _, c := range cases {
+		require.Equal(t, c.equal, equalQuantities(c.a, c.b), "%v and %v", c.a, c.b)
+	}
+}
+
+func TestNormalizeQuantities(t *testing.T) {
+	template := &unstructured.Unstructured{Object: map[string]any{
+		"spec": map[string]any{
+			"replicas": int64(1),
+			"containers": []any{
+				map[string]any{"resources": map[string]any{"cpu": "500m", "memory": "1Gi"}},
+			},
+		},
+	}}
+	newClusterObj := func() *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]any{
+			"spec": map[string]any{
+				"replicas": "1",
+				"containers": []any{
+					map[string]any{"resources": map[string]any{"cpu": "0.5", "memory": "2Gi"}, "name": "a"},
+					map[string]any{"resources": map[string]any{"cpu": "0.5"}},
+				},
+			},
+		}}
+	}
+
+	require.Equal(t, newClusterObj(), (*quantityFields)(nil).normalize(template, newClusterObj()))
+
+	clusterObj := newClusterObj()
+	normalized := (&quantityFields{paths: [][]string{{"spec", "containers", "resources"}}}).normalize(template, clusterObj)
+	require.Equal(t, newClusterObj(), clusterObj, "the cluster object should not be modified")
+	expected := newClusterObj()
+	expected.Object["spec"].(map[string]any)["containers"].([]any)[0].(map[string]any)["resources"].(map[string]any)["cpu"] = "500m"
+	require.Equal(t, expected, normalized)
+
+	normalized = (&quantityFields{all: true}).normalize(template, newClusterObj())
+	expected.Object["spec"].(map[string]any)["replicas"] = int64(1)
+	require.Equal(t, expected, normalized)
+}
@@ -0,0 +1,162 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	recordingFileName     = "recording.yaml"
+	recordedResourcesDir  = "resources"
+	recordedReferenceDir  = "reference"
+	recordingNotFoundText = "%s doesn't hold a recording written by --record: %w"
+)
+
+// Recording holds what a comparison read from the cluster besides the cluster CRs, so the comparison can be replayed
+// without the cluster. It's written to recording.yaml by --record, next to the cluster CRs and the reference files.
+type Recording struct {
+	// Reference is the path of the reference config file in the reference directory of the recording
+	Reference string `json:"reference"`
+	// ClusterFacts are the facts discovered about the cluster, if the templates needed them
+	ClusterFacts *ClusterFacts `json:"clusterFacts,omitempty"`
+	// EnableLookups tells if lookupCR looked up the cluster, Lookups are the objects it returned by their
+	// apiVersion_kind_namespace_name
+	EnableLookups bool                      `json:"enableLookups,omitempty"`
+	Lookups       map[string]map[string]any `json:"lookups,omitempty"`
+}
+
+// recorder records the inputs of a live comparison with --record
+type recorder struct {
+	dir       string
+	recording Recording
+	// mu guards the writes of the resources, resources are visited concurrently
+	mu sync.Mutex
+}
+
+// newRecorder creates the directory the comparison is recorded to, the directory is required to be empty so it only
+// holds the recording of a single run
+func newRecorder(dir string) (*recorder, error) {
+	entries, err := os.ReadDir(dir)
+	if err == nil && len(entries) > 0 {
+		return nil, fmt.Errorf("recording directory %s isn't empty", dir)
+	}
+	for _, d := range []string{recordedResourcesDir, recordedReferenceDir} {
+		if err := os.MkdirAll(filepath.Join(dir, d), 0o755); err != nil { // nolint:gosec
+			return nil, fmt.Errorf("failed to create recording directory: %w", err)
+		}
+	}
+	return &recorder{dir: dir}, nil
+}
+
+// recordReference copies the reference config file, the templates and the template function files to the recording
+func (r *recorder) recordReference(ref Reference, fsys fs.FS, referenceFileName string) error {
+	if d, ok := fsys.(interface{ containsDecryptedFiles() bool }); ok && d.containsDecryptedFiles() {
+		klog.Warning("The reference contains files encrypted with SOPS, they're recorded decrypted")
+	}
+	_, files, err := referenceDigest(ref, fsys, referenceFileName)
+	if err != nil {
+		return fmt.Errorf("failed to record the reference: %w", err)
+	}
+	content, err := fs.ReadFile(fsys, referenceFileName)
+	if err != nil {
+		return fmt.Errorf("failed to record the reference: %w", err)
+	}
+	recorded := []referenceFile{{name: referenceFileName, content: content}}
+	for _, templateFiles := range files {
+		recorded = append(recorded, templateFiles...)
+	}
+	for _, f := range recorded {
+		fileName := filepath.Join(r.dir, recordedReferenceDir, filepath.FromSlash(f.name))
+		if err := os.MkdirAll(filepath.Dir(fileName), 0o755); err != nil { // nolint:gosec
+			return fmt.Errorf("failed to record the reference: %w", err)
+		}
+		if err := os.WriteFile(fileName, f.content, 0o600); err != nil {
+			return fmt.Errorf("failed to record the reference: %w", err)
+		}
+	}
+	r.recording.Reference = referenceFileName
+	return nil
+}
+
+// recordResource records a cluster CR as it was fetched, before it's normalized
+func (r *recorder) recordResource(obj *unstructured.Unstructured) error {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return writeSnapshotObject(filepath.Join(r.dir, recordedResourcesDir), obj)
+}
+
+// finish writes the cluster facts and the looked up objects of the comparison to the recording
+func (r *recorder) finish(facts *ClusterFacts, lookups *clusterLookup) error {
+	r.recording.ClusterFacts = facts
+	r.recording.EnableLookups = lookups != nil
+	r.recording.Lookups = lookups.recorded()
+	content, err := yaml.Marshal(r.recording)
+	if err != nil {
+		return fmt.Errorf("failed to marshal the recording: %w", err)
+	}
+	// The looked up cluster objects can be secrets, the recording is only readable by its owner
+	if err := os.WriteFile(filepath.Join(r.dir, recordingFileName), content, 0o600); err != nil {
+		return fmt.Errorf("failed to write the recording: %w", err)
+	}
+	return nil
+}
+
+// loadRecording reads the recording of a directory written by --record
+func loadRecording(dir string) (*Recording, error) {
+	content, err := os.ReadFile(filepath.Join(dir, recordingFileName))
+	if err != nil {
+		return nil, fmt.Errorf(recordingNotFoundText, dir, err)
+	}
+	recording := &Recording{}
+	if err := yaml.Unmarshal(content, recording); err != nil {
+		return nil, fmt.Errorf("failed to parse the recording of %s: %w", dir, err)
+	}
+	if recording.Reference == "" {
+		return nil, fmt.Errorf("the recording of %s has no reference", dir)
+	}
+	return recording, nil
+}
+
+// setupReplay compares the CRs and the reference of the recording, the flags selecting them can't be used
+func (o *Options) setupReplay() error {
+	if len(o.referenceConfigs) > 0 || len(o.CRs.Filenames) > 0 || o.CRs.Kustomize != "" {
+		return usageErrorf("--replay can't be used with -r, -f or -k, the reference and the CRs of the recording are compared")
+	}
+	if o.recordDir != "" || len(o.contextNames) > 0 || o.allContexts || o.dryRun {
+		return usageErrorf("--replay can't be used with --record, --contexts, --all-contexts or --dry-run")
+	}
+	var err error
+	if o.replay, err = loadRecording(o.replayDir); err != nil {
+		return err
+	}
+	o.referenceConfigs = []string{filepath.Join(o.replayDir, recordedReferenceDir, filepath.FromSlash(o.replay.Reference))}
+	o.CRs.Filenames = []string{filepath.Join(o.replayDir, recordedResourcesDir)}
+	o.CRs.Recursive = true
+	return nil
+}
+
+// replayLookup returns the lookups of the replayed comparison, they return the recorded objects
+func replayLookup(recording *Recording) *clusterLookup {
+	if !recording.EnableLookups {
+		return nil
+	}
+	l := &clusterLookup{objects: make(map[string]*lookupResult)}
+	for key, object := range recording.Lookups {
+		result := &lookupResult{object: object}
+		result.once.Do(func() {})
+		l.objects[key] = result
+	}
+	return l
+}
@@ -0,0 +1,60 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestRecording(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "recording")
+	r, err := newRecorder(dir)
+	require.NoError(t, err)
+	secret := map[string]any{"apiVersion": "v1", "kind": "Secret", "metadata": map[string]any{"name": "app-tls", "namespace": "example"}}
+	cm := &unstructured.Unstructured{Object: map[string]any{"apiVersion": "v1", "kind": "ConfigMap", "metadata": map[string]any{"name": "app", "namespace": "example"}}}
+	require.NoError(t, r.recordResource(cm))
+	lookups := &clusterLookup{objects: map[string]*lookupResult{
+		"v1_Secret_example_app-tls": {object: secret},
+		"v1_Secret_example_missing": {err: context.Canceled},
+	}}
+	require.NoError(t, r.finish(&ClusterFacts{Platform: "BareMetal"}, lookups))
+
+	_, err = newRecorder(dir)
+	require.ErrorContains(t, err, "isn't empty")
+	entries, err := os.ReadDir(filepath.Join(dir, recordedResourcesDir))
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	for _, name := range []string{recordingFileName, filepath.Join(recordedResourcesDir, entries[0].Name())} {
+		info, err := os.Stat(filepath.Join(dir, name))
+		require.NoError(t, err)
+		require.Equal(t, os.FileMode(0o600), info.Mode().Perm(), "%s should only be readable by its owner", name)
+	}
+
+	recording, err := loadRecording(dir)
+	require.ErrorContains(t, err, "has no reference", "the recording should only be valid once the reference is recorded")
+	require.Nil(t, recording)
+
+	r.recording.Reference = "metadata.yaml"
+	require.NoError(t, r.finish(&ClusterFacts{Platform: "BareMetal"}, lookups))
+	recording, err = loadRecording(dir)
+	require.NoError(t, err)
+	require.Equal(t, "BareMetal", recording.ClusterFacts.Platform)
+	require.True(t, recording.EnableLookups)
+	require.Equal(t, map[string]map[string]any{"v1_Secret_example_app-tls": secret}, recording.Lookups, "failed lookups shouldn't be recorded")
+
+	lookup := replayLookup(recording).lookupFunc(context.Background())
+	object, err := lookup("v1", "Secret", "example", "app-tls")
+	require.NoError(t, err)
+	require.Equal(t, secret, object)
+	_, err = lookup("v1", "Secret", "example", "other")
+	require.ErrorContains(t, err, "wasn't looked up when the comparison was recorded")
+
+	_, err = loadRecording(t.TempDir())
+	require.ErrorContains(t, err, "doesn't hold a recording written by --record")
+}
@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"io/fs"
 	"path"
+	"slices"
 	"strings"
 	"text/template"
 	"text/template/parse"
@@ -76,6 +77,65 @@ func (r *ReferenceV1) GetFieldsToOmit() FieldsToOmit {
 	return r.FieldsToOmit
 }
 
+// GetOperatorVersions returns nil, operator versions can only be declared in v2 references
+func (r *ReferenceV1) GetOperatorVersions() []*OperatorVersion {
+	return nil
+}
+
+// GetCorrelationFieldGroups returns nil, correlation field groups can only be declared in v2 references
+func (r *ReferenceV1) GetCorrelationFieldGroups() [][][]string {
+	return nil
+}
+
+// GetUnorderedLists returns nil, unordered lists can only be declared in v2 references
+func (r *ReferenceV1) GetUnorderedLists() []string {
+	return nil
+}
+
+// GetDefaults returns nil, defaults can only be declared in v2 references
+func (r *ReferenceV1) GetDefaults() []FieldDefault {
+	return nil
+}
+
+// GetNormalizeQuantities returns false, quantities can only be normalized in v2 references
+func (r *ReferenceV1) GetNormalizeQuantities() bool {
+	return false
+}
+
+// GetMatchTieBreakers returns nil, tie-breakers can only be declared in v2 references
+func (r *ReferenceV1) GetMatchTieBreakers() *MatchTieBreakers {
+	return nil
+}
+
+// GetFieldSeverities returns nil, field severities can only be declared in v2 references
+func (r *ReferenceV1) GetFieldSeverities() []FieldSeverity {
+	return nil
+}
+
+func (r *ReferenceV1) getComponentNames() []string {
+	var names []string
+	for _, part := range r.Parts {
+		for _, comp := range part.Components {
+			names = append(names, comp.Name)
+		}
+	}
+	return names
+}
+
+// filterComponents returns a copy of the reference without the components for which keep returns false, parts left
+// without components are removed
+func (r *ReferenceV1) filterComponents(keep func(component string) bool) *ReferenceV1 {
+	filtered := *r
+	filtered.Parts = nil
+	for _, part := range r.Parts {
+		part.Components = slices.DeleteFunc(slices.Clone(part.Components), func(c ComponentV1) bool { return !keep(c.Name) })
+		if len(part.Components) > 0 {
+			filtered.Parts = append(filtered.Parts, part)
+		}
+	}
+	return &filtered
+}
+
 func (r *ReferenceV1) GetTemplateFunctionFiles() []string {
 	return r.TemplateFunctionFiles
 }
@@ -127,6 +187,20 @@ func (r *ReferenceV1) GetValidationIssues(matchedTemplates map[string]int) (map[
 	return crs, count
 }
 
+// GetComponentTemplates returns the paths of the templates of every component, keyed by part and component names
+func (r *ReferenceV1) GetComponentTemplates() map[string]map[string][]string {
+	result := make(map[string]map[string][]string)
+	for _, part := range r.Parts {
+		result[part.Name] = make(map[string][]string)
+		for _, comp := range part.Components {
+			for _, temp := range slices.Concat(comp.RequiredTemplates, comp.OptionalTemplates) {
+				result[part.Name][comp.Name] = append(result[part.Name][comp.Name], temp.GetPath())
+			}
+		}
+	}
+	return result
+}
+
 func getReferenceV1(fsys fs.FS, referenceFileName string) (*ReferenceV1, error) {
 	result := &ReferenceV1{}
 	err := parseYaml(fsys, referenceFileName, &result, refConfNotExistsError, refConfigNotInFormat)
@@ -207,6 +281,61 @@ func (config ReferenceTemplateConfigV1) GetInlineDiffFuncs() map[string]inlineDi
 	return map[string]inlineDiffType{}
 }
 
+// GetUnorderedLists returns nil, unordered lists can only be declared in v2 references
+func (config ReferenceTemplateConfigV1) GetUnorderedLists() []string {
+	return nil
+}
+
+// GetDefaults returns nil, defaults can only be declared in v2 references
+func (config ReferenceTemplateConfigV1) GetDefaults() []FieldDefault {
+	return nil
+}
+
+// GetQuantityFields returns nil, quantities can only be normalized in v2 references
+func (config ReferenceTemplateConfigV1) GetQuantityFields() []string {
+	return nil
+}
+
+// GetRenderTimeout returns an empty string, render timeouts can only be set per template in v2 references
+func (config ReferenceTemplateConfigV1) GetRenderTimeout() string {
+	return ""
+}
+
+// GetExpectedCount returns nil, the expected number of CRs can only be set per template in v2 references
+func (config ReferenceTemplateConfigV1) GetExpectedCount() (minCount, maxCount *int) {
+	return nil, nil
+}
+
+// GetFieldSelector returns an empty string, field selectors can only be set per template in v2 references
+func (config ReferenceTemplateConfigV1) GetFieldSelector() string {
+	return ""
+}
+
+// GetCondition returns nil, conditions can only be set per template in v2 references
+func (config ReferenceTemplateConfigV1) GetCondition() *TemplateCondition {
+	return nil
+}
+
+// GetCorrelateBy returns nil, correlation by annotation can only be set per template in v2 references
+func (config ReferenceTemplateConfigV1) GetCorrelateBy() *CorrelateBy {
+	return nil
+}
+
+// GetDependsOn returns nil, dependencies can only be set per template in v2 references
+func (config ReferenceTemplateConfigV1) GetDependsOn() []string {
+	return nil
+}
+
+// GetAnnotations returns no annotations, annotations can only be set per template in v2 references
+func (config ReferenceTemplateConfigV1) GetAnnotations() TemplateAnnotations {
+	return TemplateAnnotations{}
+}
+
+// GetComparisonMode returns an empty string, comparison modes can only be set per template in v2 references
+func (config ReferenceTemplateConfigV1) GetComparisonMode() string {
+	return ""
+}
+
 func (config ReferenceTemplateConfigV1) GetFieldsToOmitRefs() []string {
 	return config.FieldsToOmitRefs
 }
@@ -217,6 +346,7 @@ type ReferenceTemplateV1 struct {
 	Description        string                    `json:"description,omitempty"`
 	Config             ReferenceTemplateConfigV1 `json:"config,omitempty"`
 	metadata           *unstructured.Unstructured
+	lazy               *lazyTemplate
 }
 
 func (rf ReferenceTemplateV1) GetFieldsToOmit(fieldsToOmit FieldsToOmit) []*ManifestPathV1 {
@@ -253,21 +383,42 @@ func (rf ReferenceTemplateV1) ValidateFieldsToOmit(fieldsToOmit FieldsToOmit) er
 const noValue = "<no value>"
 
 func (rf ReferenceTemplateV1) Exec(params map[string]any) (*unstructured.Unstructured, error) {
+	obj, _, err := rf.ExecWithWarnings(params)
+	return obj, err
+}
+
+// ExecWithWarnings renders the template like Exec and returns the warnings reported with the warn function
+func (rf ReferenceTemplateV1) ExecWithWarnings(params map[string]any) (*unstructured.Unstructured, []string, error) {
+	return rf.ExecWithLookup(params, nil)
+}
+
+// ExecWithLookup renders the template like ExecWithWarnings, with lookupCR looking up the cluster objects through the
+// function (the placeholder returning empty objects is used if it's nil)
+func (rf ReferenceTemplateV1) ExecWithLookup(params map[string]any, lookupCR lookupFunc) (*unstructured.Unstructured, []string, error) {
+	tmpl, err := rf.getTemplate()
+	if err != nil {
+		return nil, nil, err
+	}
+	if lookupCR != nil {
+		if tmpl, err = bindLookup(tmpl, lookupCR); err != nil {
+			return nil, nil, err
+		}
+	}
 	var buf bytes.Buffer
-	err := rf.Template.Execute(&buf, params)
+	err = tmpl.Execute(&buf, params)
 	if err != nil {
-		return nil, fmt.Errorf("failed to constuct template: %w", err)
+		return nil, nil, fmt.Errorf("failed to constuct template: %w", err)
 	}
 	data := make(map[string]any)
-	content := buf.Bytes()
+	content, warnings := extractWarnings(buf.Bytes())
 	err = yaml.Unmarshal(bytes.ReplaceAll(content, []byte(noValue), []byte("")), &data)
 	if err != nil {
-		return nil, fmt.Errorf(
+		return nil, nil, fmt.Errorf(
 			"template: %s isn't a yaml file after injection. yaml unmarshal error: %w. The Template After Execution: %s",
 			rf.GetIdentifier(), err, string(content),
 		)
 	}
-	return &unstructured.Unstructured{Object: data}, nil
+	return &unstructured.Unstructured{Object: data}, warnings, nil
 }
 
 func (rf ReferenceTemplateV1) GetPath() string {
@@ -291,7 +442,27 @@ func (rf ReferenceTemplateV1) GetConfig() TemplateConfig {
 }
 
 func (rf ReferenceTemplateV1) GetTemplateTree() *parse.Tree {
-	return rf.Tree
+	tmpl, err := rf.getTemplate()
+	if err != nil || tmpl == nil {
+		return nil
+	}
+	return tmpl.Tree
+}
+
+// getTemplate returns the parsed template, templates loaded from the template cache are parsed on first use
+func (rf ReferenceTemplateV1) getTemplate() (*template.Template, error) {
+	if rf.lazy != nil {
+		return rf.lazy.get()
+	}
+	return rf.Template, nil
+}
+
+// templateContent returns the parsed content of the template without parsing templates loaded from the cache
+func (rf ReferenceTemplateV1) templateContent() string {
+	if rf.lazy != nil {
+		return rf.lazy.content
+	}
+	return treeContent(rf.Tree)
 }
 
 const builtInPathsKey = "cluster-compare-built-in"
@@ -312,17 +483,51 @@ type ManifestPathV1 struct {
 	PathToKey string `json:"pathToKey"`
 	IsPrefix  bool   `json:"isPrefix,omitempty"`
 	parts     []string
+	// segments are set instead of parts for paths using the JSONPath syntax: list items, filters or wildcards
+	segments []jsonPathSegment
 }
 
 func (p *ManifestPathV1) Process() error {
-	if len(p.parts) > 0 {
+	if len(p.parts) > 0 || len(p.segments) > 0 {
 		return nil
 	}
 	var err error
+	if usesJSONPathSyntax(p.PathToKey) {
+		p.segments, err = manifestPathSegments(p.PathToKey, p.IsPrefix)
+		return err
+	}
 	p.parts, err = pathToList(p.PathToKey)
 	return err
 }
 
+// usesJSONPathSyntax checks if a pathToKey selects list items or uses wildcards, e.g. spec.containers[*].image or
+// metadata.annotations["kubectl.kubernetes.io/*"]. Such paths are parsed as JSONPaths.
+func usesJSONPathSyntax(pathToKey string) bool {
+	return strings.ContainsAny(pathToKey, "[*")
+}
+
+// manifestPathSegments parses a pathToKey in the JSONPath syntax, with isPrefix the last key of the path is matched as
+// a prefix
+func manifestPathSegments(pathToKey string, isPrefix bool) ([]jsonPathSegment, error) {
+	expr := pathToKey
+	if !strings.HasPrefix(expr, ".") && !strings.HasPrefix(expr, "[") {
+		expr = "." + expr
+	}
+	segments, err := parseJSONPath(expr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse path: %w", err)
+	}
+	if isPrefix {
+		last := &segments[len(segments)-1]
+		if last.key == "" || last.wildcard {
+			return nil, fmt.Errorf("failed to parse path %s: isPrefix requires the path to end with a key", pathToKey)
+		}
+		last.key += "*"
+		last.pattern = true
+	}
+	return segments, nil
+}
+
 func pathToList(path string) ([]string, error) {
 	pathToKey, _ := strings.CutPrefix(path, ".")
 	r := csv.NewReader(strings.NewReader(pathToKey))
@@ -340,7 +545,7 @@ func ParseV1Templates(ref *ReferenceV1, fsys fs.FS) ([]ReferenceTemplate, error)
 	functionTemplates := ref.TemplateFunctionFiles
 	for _, temp := range ref.getTemplates() {
 		result = append(result, temp)
-		parsedTemp, err := template.New(path.Base(temp.Path)).Funcs(FuncMap()).ParseFS(fsys, temp.Path)
+		parsedTemp, err := newTemplate(path.Base(temp.Path)).ParseFS(fsys, temp.Path)
 		if err != nil {
 			errs = append(errs, fmt.Errorf(templatesCantBeParsed, temp.Path, err))
 			continue
@@ -352,6 +557,10 @@ func ParseV1Templates(ref *ReferenceV1, fsys fs.FS) ([]ReferenceTemplate, error)
 				continue
 			}
 		}
+		if err := validatePartials(parsedTemp); err != nil {
+			errs = append(errs, fmt.Errorf(templatesCantBeParsed, temp.Path, err))
+			continue
+		}
 		temp.Template = parsedTemp
 		temp.metadata, err = temp.Exec(map[string]any{}) // Extract Metadata
 		if err != nil {
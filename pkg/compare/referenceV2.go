@@ -11,20 +11,44 @@ import (
 	"reflect"
 	"slices"
 	"strings"
-	"text/template"
+	"time"
 
+	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/klog/v2"
 )
 
 const ReferenceVersionV2 string = "v2"
 
+const invalidFieldGroupDecl = "correlationFieldGroups entry %d: %w"
+
 type ReferenceV2 struct {
 	Version           string `json:"apiVersion,omitempty"`
 	normalisedVersion string
 
-	Parts                 []*PartV2       `json:"parts"`
-	TemplateFunctionFiles []string        `json:"templateFunctionFiles,omitempty"`
-	FieldsToOmit          *FieldsToOmitV2 `json:"fieldsToOmit,omitempty"`
+	// Includes are the references whose parts are added to the reference
+	Includes              []*ReferenceInclude `json:"includes,omitempty"`
+	Parts                 []*PartV2           `json:"parts"`
+	TemplateFunctionFiles []string            `json:"templateFunctionFiles,omitempty"`
+	FieldsToOmit          *FieldsToOmitV2     `json:"fieldsToOmit,omitempty"`
+	OperatorVersions      []*OperatorVersion  `json:"operatorVersions,omitempty"`
+
+	CorrelationFieldGroups [][]string `json:"correlationFieldGroups,omitempty"`
+	correlationFieldGroups [][][]string
+
+	// UnorderedLists are the JSONPaths of the lists compared regardless of the order of their items in all templates
+	UnorderedLists []string `json:"unorderedLists,omitempty"`
+
+	// Defaults are the default values of fields not reported when the templates don't set them
+	Defaults []FieldDefault `json:"defaults,omitempty"`
+
+	// NormalizeQuantities compares the fields of all templates holding equal quantities or numbers as equal
+	NormalizeQuantities bool `json:"normalizeQuantities,omitempty"`
+
+	// MatchTieBreakers choose the template a cluster CR is compared to when it's correlated to several templates
+	MatchTieBreakers *MatchTieBreakers `json:"matchTieBreakers,omitempty"`
+
+	// FieldSeverities set the severity of the differences of fields in all templates
+	FieldSeverities []FieldSeverity `json:"fieldSeverities,omitempty"`
 }
 
 func (r *ReferenceV2) GetAPIVersion() string {
@@ -58,6 +82,10 @@ func (r *ReferenceV2) GetTemplateFunctionFiles() []string {
 	return r.TemplateFunctionFiles
 }
 
+func (r *ReferenceV2) GetOperatorVersions() []*OperatorVersion {
+	return r.OperatorVersions
+}
+
 func (r *ReferenceV2) validate() error {
 	errs := make([]error, 0)
 	for _, part := range r.Parts {
@@ -68,9 +96,93 @@ func (r *ReferenceV2) validate() error {
 			}
 		}
 	}
+	for i, ov := range r.OperatorVersions {
+		if err := ov.validate(); err != nil {
+			errs = append(errs, fmt.Errorf(invalidOperatorDecl, i, err))
+		}
+	}
+	for i, group := range r.CorrelationFieldGroups {
+		fields, err := parseFieldGroup(group)
+		if err != nil {
+			errs = append(errs, fmt.Errorf(invalidFieldGroupDecl, i, err))
+			continue
+		}
+		r.correlationFieldGroups = append(r.correlationFieldGroups, fields)
+	}
+	if err := validateUnorderedLists(r.UnorderedLists); err != nil {
+		errs = append(errs, err)
+	}
+	if err := validateDefaults(r.Defaults); err != nil {
+		errs = append(errs, err)
+	}
+	if err := validateDependencies(r.getTemplates()); err != nil {
+		errs = append(errs, err)
+	}
+	if r.MatchTieBreakers != nil {
+		paths := make([]string, 0)
+		for _, temp := range r.getTemplates() {
+			paths = append(paths, temp.Path)
+		}
+		if err := r.MatchTieBreakers.validate(paths); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := validateFieldSeverities(r.FieldSeverities); err != nil {
+		errs = append(errs, err)
+	}
 	return errors.Join(errs...)
 }
 
+// parseFieldGroup parses the paths of the fields of a correlation field group
+func parseFieldGroup(group []string) ([][]string, error) {
+	if len(group) == 0 {
+		return nil, errors.New("a field group must contain at least one field")
+	}
+	fields := make([][]string, 0, len(group))
+	for _, p := range group {
+		field, err := pathToList(p)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", p, err)
+		}
+		if slices.Contains(field, "") {
+			return nil, fmt.Errorf("field %s: path contains an empty key", p)
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// GetCorrelationFieldGroups returns the field groups the reference declares to correlate cluster CRs to templates
+// by, nil if the default field groups should be used
+func (r *ReferenceV2) GetCorrelationFieldGroups() [][][]string {
+	return r.correlationFieldGroups
+}
+
+// GetUnorderedLists returns the JSONPaths of the lists compared regardless of the order of their items in all templates
+func (r *ReferenceV2) GetUnorderedLists() []string {
+	return r.UnorderedLists
+}
+
+// GetDefaults returns the default values of fields not reported when the templates don't set them
+func (r *ReferenceV2) GetDefaults() []FieldDefault {
+	return r.Defaults
+}
+
+// GetNormalizeQuantities checks if equal quantities or numbers are compared as equal in all the fields of all templates
+func (r *ReferenceV2) GetNormalizeQuantities() bool {
+	return r.NormalizeQuantities
+}
+
+// GetMatchTieBreakers returns the rules choosing the template a CR correlated to several templates is compared to
+func (r *ReferenceV2) GetMatchTieBreakers() *MatchTieBreakers {
+	return r.MatchTieBreakers
+}
+
+// GetFieldSeverities returns the severities of the differences of fields in all templates
+func (r *ReferenceV2) GetFieldSeverities() []FieldSeverity {
+	return r.FieldSeverities
+}
+
 func (r *ReferenceV2) GetValidationIssues(matchedTemplates map[string]int) (map[string]map[string]ValidationIssue, int) {
 	crs := make(map[string]map[string]ValidationIssue)
 	count := 0
@@ -84,6 +196,45 @@ func (r *ReferenceV2) GetValidationIssues(matchedTemplates map[string]int) (map[
 	return crs, count
 }
 
+func (r *ReferenceV2) getComponentNames() []string {
+	var names []string
+	for _, part := range r.Parts {
+		for _, comp := range part.Components {
+			names = append(names, comp.Name)
+		}
+	}
+	return names
+}
+
+// filterComponents returns a copy of the reference without the components for which keep returns false, parts left
+// without components are removed
+func (r *ReferenceV2) filterComponents(keep func(component string) bool) *ReferenceV2 {
+	filtered := *r
+	filtered.Parts = nil
+	for _, part := range r.Parts {
+		filteredPart := *part
+		filteredPart.Components = slices.DeleteFunc(slices.Clone(part.Components), func(c *ComponentV2) bool { return !keep(c.Name) })
+		if len(filteredPart.Components) > 0 {
+			filtered.Parts = append(filtered.Parts, &filteredPart)
+		}
+	}
+	return &filtered
+}
+
+// GetComponentTemplates returns the paths of the templates of every component, keyed by part and component names
+func (r *ReferenceV2) GetComponentTemplates() map[string]map[string][]string {
+	result := make(map[string]map[string][]string)
+	for _, part := range r.Parts {
+		result[part.Name] = make(map[string][]string)
+		for _, comp := range part.Components {
+			for _, temp := range comp.getTemplates(part) {
+				result[part.Name][comp.Name] = append(result[part.Name][comp.Name], temp.GetPath())
+			}
+		}
+	}
+	return result
+}
+
 func getbuiltInPathsV2() []*FieldsToOmitV2Entry {
 	res := make([]*FieldsToOmitV2Entry, 0)
 	for _, p := range builtInPathsV1 {
@@ -239,18 +390,103 @@ func (rf ReferenceTemplateV2) GetDescription() string {
 
 type ReferenceTemplateConfigV2 struct {
 	PerField []*PerFieldConfigV2 `json:"perField,omitempty"`
+	// UnorderedLists are the JSONPaths of the lists of the template compared regardless of the order of their items
+	UnorderedLists []string `json:"unorderedLists,omitempty"`
+	// Defaults are the default values of fields not reported when the template doesn't set them
+	Defaults []FieldDefault `json:"defaults,omitempty"`
+	// RenderTimeout is the maximum time to render the template for a cluster CR (e.g. 5s), it overrides --template-timeout
+	RenderTimeout string `json:"renderTimeout,omitempty"`
+	// MinCount and MaxCount are the range of the number of cluster CRs expected to be correlated to the template
+	MinCount *int `json:"minCount,omitempty"`
+	MaxCount *int `json:"maxCount,omitempty"`
+	// FieldSelector limits the objects of the kind of the template listed in live mode (e.g. metadata.name=cluster)
+	FieldSelector string `json:"fieldSelector,omitempty"`
+	// Condition limits the template to the clusters matching it, e.g. running on a platform
+	Condition *TemplateCondition `json:"condition,omitempty"`
+	// ComparisonMode is either full (the default) or subset to only compare the fields of the CR the template sets
+	ComparisonMode string `json:"comparisonMode,omitempty"`
+	// CorrelateBy correlates the template with the cluster CRs annotated with its id
+	CorrelateBy *CorrelateBy `json:"correlateBy,omitempty"`
+	// DependsOn are the paths of the templates evaluated before the template, e.g. the template of its namespace
+	DependsOn []string `json:"dependsOn,omitempty"`
+	// TemplateAnnotations tell the reader of the results what the template is for and how to fix the CRs differing
+	// from it
+	TemplateAnnotations
 	ReferenceTemplateConfigV1
 }
 
+func (config ReferenceTemplateConfigV2) GetUnorderedLists() []string {
+	return config.UnorderedLists
+}
+
+func (config ReferenceTemplateConfigV2) GetDefaults() []FieldDefault {
+	return config.Defaults
+}
+
 func (config ReferenceTemplateConfigV2) GetInlineDiffFuncs() map[string]inlineDiffType {
 	diffFuncs := make(map[string]inlineDiffType)
 	for _, fieldConf := range config.PerField {
-		diffFuncs[fieldConf.PathToKey] = fieldConf.InlineDiffFunc
+		if fieldConf.InlineDiffFunc != "" {
+			diffFuncs[fieldConf.PathToKey] = fieldConf.InlineDiffFunc
+		}
 	}
 	return diffFuncs
 }
 
+func (config ReferenceTemplateConfigV2) GetRenderTimeout() string {
+	return config.RenderTimeout
+}
+
+func (config ReferenceTemplateConfigV2) GetExpectedCount() (minCount, maxCount *int) {
+	return config.MinCount, config.MaxCount
+}
+
+func (config ReferenceTemplateConfigV2) GetFieldSelector() string {
+	return config.FieldSelector
+}
+
+func (config ReferenceTemplateConfigV2) GetCondition() *TemplateCondition {
+	return config.Condition
+}
+
+func (config ReferenceTemplateConfigV2) GetComparisonMode() string {
+	return config.ComparisonMode
+}
+
+func (config ReferenceTemplateConfigV2) GetCorrelateBy() *CorrelateBy {
+	return config.CorrelateBy
+}
+
+func (config ReferenceTemplateConfigV2) GetDependsOn() []string {
+	return config.DependsOn
+}
+
+func (config ReferenceTemplateConfigV2) GetAnnotations() TemplateAnnotations {
+	return config.TemplateAnnotations
+}
+
+// GetQuantityFields returns the paths of the fields holding equal quantities or numbers compared as equal
+func (config ReferenceTemplateConfigV2) GetQuantityFields() []string {
+	var fields []string
+	for _, fieldConf := range config.PerField {
+		if fieldConf.NormalizeQuantities {
+			fields = append(fields, fieldConf.PathToKey)
+		}
+	}
+	return fields
+}
+
 func (rf ReferenceTemplateV2) validateConfigPerField() error {
+	for _, fieldConf := range rf.Config.PerField {
+		if fieldConf.InlineDiffFunc == "" && !fieldConf.NormalizeQuantities {
+			return fmt.Errorf("reference contains template with config per field that sets neither inlineDiffFunc "+
+				"nor normalizeQuantities. path: %s", fieldConf.PathToKey)
+		}
+		if _, err := pathToList(fieldConf.PathToKey); err != nil {
+			return fmt.Errorf("reference contains template with config per field with pathToKey that is not in "+
+				"supoorted format. path: %s. error: %v", fieldConf.PathToKey, err)
+		}
+	}
 	for pathToKey, inlineDiffFunc := range rf.GetConfig().GetInlineDiffFuncs() {
 		listedPath, err := pathToList(pathToKey)
 		if err != nil {
@@ -279,6 +515,8 @@ func (rf ReferenceTemplateV2) validateConfigPerField() error {
 type PerFieldConfigV2 struct {
 	PathToKey      string         `json:"pathToKey,omitempty"`
 	InlineDiffFunc inlineDiffType `json:"inlineDiffFunc,omitempty"`
+	// NormalizeQuantities compares equal quantities or numbers in the field and the fields under it as equal
+	NormalizeQuantities bool `json:"normalizeQuantities,omitempty"`
 }
 
 type inlineDiffType string
@@ -365,6 +603,7 @@ func componentV2GroupUnmarshalJSON(s ComponentV2Group, b []byte) (err error) {
 const (
 	MissingCRsMsg      = "Missing CRs"
 	MatchedMoreThanOne = "Should only match one but matched"
+	OneOfRequiredMsg   = "One of the following is required"
 )
 
 type OneOf struct {
@@ -387,7 +626,7 @@ func (g *OneOf) getMissingCRs(matchedTemplates map[string]int) (ValidationIssue,
 	}
 	if len(matched) == 0 {
 		return ValidationIssue{
-			Msg: "One of the following is required",
+			Msg: OneOfRequiredMsg,
 			CRs: notMatched,
 		}, 1
 	}
@@ -568,8 +807,7 @@ func (comp ComponentV2) getValidationIssues(matchedTemplates map[string]int) (Va
 }
 
 func getReferenceV2(fsys fs.FS, referenceFileName string) (*ReferenceV2, error) {
-	result := &ReferenceV2{}
-	err := parseYaml(fsys, referenceFileName, &result, refConfNotExistsError, refConfigNotInFormat)
+	result, err := readReferenceV2(fsys, referenceFileName)
 	if err != nil {
 		return result, err
 	}
@@ -589,13 +827,35 @@ func getReferenceV2(fsys fs.FS, referenceFileName string) (*ReferenceV2, error)
 	return result, nil
 }
 
+// readReferenceV2 reads the reference config, expands its template patterns and adds the parts of the references it
+// includes
+func readReferenceV2(fsys fs.FS, referenceFileName string) (*ReferenceV2, error) {
+	result := &ReferenceV2{}
+	err := parseYaml(fsys, referenceFileName, &result, refConfNotExistsError, refConfigNotInFormat)
+	if err != nil {
+		return result, err
+	}
+	if !strings.EqualFold(strings.TrimSpace(result.Version), ReferenceVersionV2) {
+		return result, fmt.Errorf("reference %s has apiVersion %q, only %s references can include other references", referenceFileName, result.Version, ReferenceVersionV2)
+	}
+	err = result.expandTemplatePatterns(fsys, referenceFileName)
+	if err != nil {
+		return result, err
+	}
+	err = result.includeReferences(fsys)
+	if err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
 func ParseV2Templates(ref *ReferenceV2, fsys fs.FS) ([]ReferenceTemplate, error) {
 	var errs []error
 	var result []ReferenceTemplate
 	functionTemplates := ref.TemplateFunctionFiles
 	for _, temp := range ref.getTemplates() {
 		result = append(result, temp)
-		parsedTemp, err := template.New(path.Base(temp.Path)).Funcs(FuncMap()).ParseFS(fsys, temp.Path)
+		parsedTemp, err := newTemplate(path.Base(temp.Path)).ParseFS(fsys, temp.Path)
 		if err != nil {
 			errs = append(errs, fmt.Errorf(templatesCantBeParsed, temp.Path, err))
 			continue
@@ -607,6 +867,10 @@ func ParseV2Templates(ref *ReferenceV2, fsys fs.FS) ([]ReferenceTemplate, error)
 				continue
 			}
 		}
+		if err := validatePartials(parsedTemp); err != nil {
+			errs = append(errs, fmt.Errorf(templatesCantBeParsed, temp.Path, err))
+			continue
+		}
 		temp.Template = parsedTemp
 		temp.ReferenceTemplateV1.Config = temp.Config.ReferenceTemplateConfigV1
 		temp.metadata, err = temp.Exec(map[string]any{}) // Extract Metadata
@@ -617,6 +881,41 @@ func ParseV2Templates(ref *ReferenceV2, fsys fs.FS) ([]ReferenceTemplate, error)
 		if err != nil {
 			errs = append(errs, err)
 		}
+		if err := validateUnorderedLists(temp.Config.UnorderedLists); err != nil {
+			errs = append(errs, fmt.Errorf("template %s: %w", temp.Path, err))
+		}
+		if err := validateDefaults(temp.Config.Defaults); err != nil {
+			errs = append(errs, fmt.Errorf("template %s: %w", temp.Path, err))
+		}
+		if temp.Config.RenderTimeout != "" {
+			if _, err := time.ParseDuration(temp.Config.RenderTimeout); err != nil {
+				errs = append(errs, fmt.Errorf("template %s: invalid renderTimeout: %w", temp.Path, err))
+			}
+		}
+		if err := validateCounts(temp.Config.MinCount, temp.Config.MaxCount); err != nil {
+			errs = append(errs, fmt.Errorf("template %s: %w", temp.Path, err))
+		}
+		if temp.Config.FieldSelector != "" {
+			if _, err := fields.ParseSelector(temp.Config.FieldSelector); err != nil {
+				errs = append(errs, fmt.Errorf("template %s: invalid fieldSelector: %w", temp.Path, err))
+			}
+		}
+		if temp.Config.Condition != nil {
+			if err := temp.Config.Condition.validate(); err != nil {
+				errs = append(errs, fmt.Errorf("template %s: %w", temp.Path, err))
+			}
+		}
+		if err := validateComparisonMode(temp.Config.ComparisonMode); err != nil {
+			errs = append(errs, fmt.Errorf("template %s: %w", temp.Path, err))
+		}
+		if err := temp.Config.TemplateAnnotations.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("template %s: %w", temp.Path, err))
+		}
+		if temp.Config.CorrelateBy != nil {
+			if err := temp.Config.CorrelateBy.validate(); err != nil {
+				errs = append(errs, fmt.Errorf("template %s: %w", temp.Path, err))
+			}
+		}
 		err = temp.ValidateFieldsToOmit(ref.FieldsToOmit)
 		if err != nil {
 			errs = append(errs, err)
@@ -0,0 +1,142 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"k8s.io/klog/v2"
+)
+
+// DefaultCacheDir returns the directory under the user cache dir where references and their parsed templates are
+// cached
+func DefaultCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find the user cache directory: %w", err)
+	}
+	return filepath.Join(dir, "kube-compare"), nil
+}
+
+// referenceCache keeps the files of the references served over http whose content is known before they're fetched:
+// bundles pinned by --verify-signature and references pinned by a reference lock. Repeated runs against the same
+// reference content load it from the cache directory instead of fetching it, its parsed templates are cached
+// alongside by the template cache. A reference cache without a directory doesn't cache anything.
+type referenceCache struct {
+	dir string
+	// key and recorder are set when a reference that can be cached is fetched, the files it recorded are stored
+	// under the key once the templates of the reference are parsed
+	key      string
+	recorder *lockedFS
+}
+
+// referenceCacheKey returns the digest the reference is cached under, an empty string if the reference can't be
+// cached. Only the content of the files is part of the key, so a reference is reused whatever URL it's served from.
+func referenceCacheKey(refConfig, checksum string, lock *ReferenceLock) string {
+	if !isURL(refConfig) {
+		return ""
+	}
+	if checksum != "" && isBundle(refConfig) {
+		if normalized, err := parseChecksum(checksum); err == nil {
+			return "bundle-" + strings.TrimPrefix(normalized, checksumPrefix)
+		}
+	}
+	if lock == nil || len(lock.Files) == 0 {
+		return ""
+	}
+	files := make([]string, 0, len(lock.Files))
+	for _, f := range lock.Files {
+		files = append(files, f.Path+"\x00"+f.Digest)
+	}
+	sort.Strings(files)
+	hash := sha256.New()
+	for _, f := range files {
+		fmt.Fprintf(hash, "%s\n", f)
+	}
+	return fmt.Sprintf("lock-%x", hash.Sum(nil))
+}
+
+// setupCache sets the directory of the reference cache from the flags. The cache is enabled by default, if the user
+// cache directory can't be found the references are only cached when asked to with --template-cache or --cache-dir.
+func (o *Options) setupCache() error {
+	if o.noCache && (o.templateCache || o.cacheDir != "") {
+		return usageErrorf("--no-cache can't be used with --template-cache or --cache-dir")
+	}
+	o.referenceCache = referenceCache{}
+	if o.noCache {
+		return nil
+	}
+	dir := o.cacheDir
+	if dir == "" {
+		var err error
+		if dir, err = DefaultCacheDir(); err != nil {
+			if o.templateCache {
+				return err
+			}
+			klog.V(1).Infof("The references aren't cached: %s", err)
+			return nil
+		}
+	}
+	o.referenceCache = referenceCache{dir: dir}
+	return nil
+}
+
+func (c *referenceCache) enabled() bool {
+	return c.dir != ""
+}
+
+func (c *referenceCache) path(key string) string {
+	return filepath.Join(c.dir, "references", key+".tgz")
+}
+
+// load returns the file system of the reference cached under the key. When the reference isn't cached yet, the
+// returned function wraps the file system the reference is fetched into so its files are recorded for store.
+func (c *referenceCache) load(key string) (fs.FS, func(fs.FS) fs.FS) {
+	none := func(fsys fs.FS) fs.FS { return fsys }
+	if !c.enabled() || key == "" {
+		return nil, none
+	}
+	if content, err := os.ReadFile(c.path(key)); err == nil {
+		fsys, err := readBundle(bytes.NewReader(content))
+		if err == nil {
+			klog.V(1).Infof("Loaded the reference from the cache %s", c.path(key))
+			return fsys, none
+		}
+		klog.Warningf("ignoring invalid reference cache file %s: %s", c.path(key), err)
+	}
+	return nil, func(fsys fs.FS) fs.FS {
+		c.key = key
+		c.recorder = newLockedFS(fsys, nil)
+		return c.recorder
+	}
+}
+
+// store writes the files of the fetched reference to the cache, it's only called once the reference was loaded and
+// its templates parsed successfully. Failures are only logged since the comparison doesn't depend on the cache.
+func (c *referenceCache) store() {
+	if c.recorder == nil {
+		return
+	}
+	c.recorder.mu.Lock()
+	files := make([]bundleFile, 0, len(c.recorder.recorded))
+	for _, f := range c.recorder.recorded {
+		files = append(files, bundleFile{name: f.Path, content: f.content})
+	}
+	c.recorder.mu.Unlock()
+	var buf bytes.Buffer
+	err := writeBundle(&buf, files)
+	if err == nil {
+		err = writeCacheFile(c.path(c.key), buf.Bytes())
+	}
+	if err != nil {
+		klog.Warningf("failed to cache the reference: %s", err)
+	}
+	c.recorder = nil
+}
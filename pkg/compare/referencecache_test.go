@@ -0,0 +1,111 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReferenceCacheKey(t *testing.T) {
+	checksum := "sha256:AFE61F5D1B308863BAFB45FFD0E6C4FCF85368FD544A17BF599FBCC1465DEE8E"
+	require.Equal(t, "bundle-afe61f5d1b308863bafb45ffd0e6c4fcf85368fd544a17bf599fbcc1465dee8e",
+		referenceCacheKey("https://example.com/ref.tgz", checksum, nil))
+	require.Empty(t, referenceCacheKey("./ref.tgz", checksum, nil), "local references shouldn't be cached")
+	require.Empty(t, referenceCacheKey("https://example.com/metadata.yaml", checksum, nil),
+		"the checksum of a reference config doesn't cover its templates")
+
+	lock := &ReferenceLock{Files: []LockedFile{{Path: "metadata.yaml", Digest: "sha256:01"}, {Path: "cm.yaml", Digest: "sha256:02"}}}
+	reordered := &ReferenceLock{Files: []LockedFile{lock.Files[1], lock.Files[0]}}
+	changed := &ReferenceLock{Files: []LockedFile{lock.Files[0], {Path: "cm.yaml", Digest: "sha256:03"}}}
+	key := referenceCacheKey("https://example.com/metadata.yaml", "", lock)
+	require.Regexp(t, "^lock-[0-9a-f]{64}$", key)
+	require.Equal(t, key, referenceCacheKey("https://other.example.com/metadata.yaml", "", reordered))
+	require.NotEqual(t, key, referenceCacheKey("https://example.com/metadata.yaml", "", changed))
+}
+
+func TestReferenceCache(t *testing.T) {
+	serve := func(t *testing.T, dir string) (string, *atomic.Int32) {
+		requests := &atomic.Int32{}
+		svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests.Add(1)
+			http.ServeFile(w, r, path.Join(dir, r.URL.Path))
+		}))
+		t.Cleanup(svr.Close)
+		return svr.URL, requests
+	}
+	client, err := defaultReferenceClient()
+	require.NoError(t, err)
+	load := func(t *testing.T, o *Options) {
+		cfs, referenceFileName, err := o.referenceFS()
+		require.NoError(t, err)
+		ref, err := GetReference(cfs, referenceFileName)
+		require.NoError(t, err)
+		_, err = ParseTemplates(ref, cfs)
+		require.NoError(t, err)
+		o.referenceCache.store()
+	}
+
+	t.Run("Bundle", func(t *testing.T) {
+		test := defaultTest("Reference Bundle")
+		url, requests := serve(t, filepath.Join(test.getTestDir(), TestRefDirName))
+		cacheDir := t.TempDir()
+		o := &Options{referenceConfig: url + "/ref.tgz", cacheDir: cacheDir, referenceClient: client,
+			verifySignature: "sha256:AFE61F5D1B308863BAFB45FFD0E6C4FCF85368FD544A17BF599FBCC1465DEE8E"}
+		require.NoError(t, o.setupCache())
+		load(t, o)
+		require.Equal(t, int32(1), requests.Load())
+		require.FileExists(t, filepath.Join(cacheDir, "references", "bundle-afe61f5d1b308863bafb45ffd0e6c4fcf85368fd544a17bf599fbcc1465dee8e.tgz"))
+
+		load(t, o)
+		require.Equal(t, int32(1), requests.Load(), "a cached reference shouldn't be fetched again")
+
+		o.noCache, o.cacheDir = true, ""
+		require.NoError(t, o.setupCache())
+		load(t, o)
+		require.Equal(t, int32(2), requests.Load(), "the cache shouldn't be used with --no-cache")
+	})
+
+	t.Run("Lock", func(t *testing.T) {
+		test := defaultTest("Ref With Template Functions Renders As Expected")
+		url, requests := serve(t, filepath.Join(test.getTestDir(), TestRefDirName))
+		refConfig := url + "/" + defaultReferenceFilename
+		fsys, err := GetRefFS(refConfig)
+		require.NoError(t, err)
+		lock, err := LockReference(fsys, refConfig)
+		require.NoError(t, err)
+		lockPath := filepath.Join(t.TempDir(), DefaultLockFileName)
+		require.NoError(t, lock.Write(lockPath))
+
+		o := &Options{referenceConfig: refConfig, referenceLock: lockPath, cacheDir: t.TempDir(), referenceClient: client}
+		require.NoError(t, o.setupCache())
+		load(t, o)
+		fetched := requests.Load()
+		load(t, o)
+		require.Equal(t, fetched, requests.Load(), "a cached reference shouldn't be fetched again")
+
+		// The cached files are still verified against the lock
+		var tampered bytes.Buffer
+		require.NoError(t, writeBundle(&tampered, []bundleFile{{name: defaultReferenceFilename, content: []byte("apiVersion: v2\n")}}))
+		key := referenceCacheKey(refConfig, "", lock)
+		require.NoError(t, os.WriteFile(o.referenceCache.path(key), tampered.Bytes(), 0o600))
+		cfs, referenceFileName, err := o.referenceFS()
+		require.NoError(t, err)
+		_, err = GetReference(cfs, referenceFileName)
+		require.ErrorContains(t, err, "doesn't match the reference lock")
+		require.Equal(t, fetched, requests.Load())
+	})
+
+	t.Run("No Cache With Cache Dir", func(t *testing.T) {
+		o := &Options{noCache: true, cacheDir: t.TempDir()}
+		require.EqualError(t, o.setupCache(), "--no-cache can't be used with --template-cache or --cache-dir")
+	})
+}
@@ -0,0 +1,255 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	k8syaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/yaml"
+)
+
+const strategicMergePatch = "strategic"
+
+// patchTarget selects the rendered templates a reference patch applies to, the fields are those of the targets of
+// kustomize patches, plus the path of the template in the reference. Empty fields match any template.
+type patchTarget struct {
+	Group     string `json:"group,omitempty"`
+	Version   string `json:"version,omitempty"`
+	Kind      string `json:"kind,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+	Template  string `json:"template,omitempty"`
+}
+
+// matchesTemplate returns whether the template can render objects the target selects, the name and namespace are only
+// known once it's rendered
+func (t patchTarget) matchesTemplate(temp ReferenceTemplate) bool {
+	gvk := temp.GetMetadata().GroupVersionKind()
+	return (t.Group == "" || t.Group == gvk.Group) &&
+		(t.Version == "" || t.Version == gvk.Version) &&
+		(t.Kind == "" || t.Kind == gvk.Kind) &&
+		(t.Template == "" || t.Template == temp.GetPath())
+}
+
+func (t patchTarget) matches(temp ReferenceTemplate, rendered *unstructured.Unstructured) bool {
+	gvk := rendered.GroupVersionKind()
+	return (t.Group == "" || t.Group == gvk.Group) &&
+		(t.Version == "" || t.Version == gvk.Version) &&
+		(t.Kind == "" || t.Kind == gvk.Kind) &&
+		(t.Name == "" || t.Name == rendered.GetName()) &&
+		(t.Namespace == "" || t.Namespace == rendered.GetNamespace()) &&
+		(t.Template == "" || t.Template == temp.GetPath())
+}
+
+// referencePatch is a strategic merge or JSON6902 patch passed with --reference-patch, applied to the rendered
+// templates it targets before they are diffed so a reference can be adjusted to a site without forking it
+type referencePatch struct {
+	Source string          `json:"source"`
+	Target patchTarget     `json:"target"`
+	Type   patchType       `json:"type"`
+	Patch  json.RawMessage `json:"patch"`
+}
+
+// kustomizePatch is an entry of the patches of a kustomization, the patch is either inline or in the file at path
+type kustomizePatch struct {
+	Target *patchTarget `json:"target,omitempty"`
+	Patch  string       `json:"patch,omitempty"`
+	Path   string       `json:"path,omitempty"`
+}
+
+// loadReferencePatches reads the patch files. Every document of a file is either a strategic merge patch targeting
+// the objects of its kind, name and namespace, or a kustomize patches entry with a target and an inline patch or the
+// path of one, relative to the file.
+func loadReferencePatches(paths []string) ([]*referencePatch, error) {
+	var patches []*referencePatch
+	for _, path := range paths {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read reference patch: %w", err)
+		}
+		docs, err := decodeDocuments(content)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse reference patch %s: %w", path, err)
+		}
+		for _, doc := range docs {
+			p, err := parseReferencePatch(path, doc)
+			if err != nil {
+				return nil, fmt.Errorf("invalid reference patch %s: %w", path, err)
+			}
+			patches = append(patches, p)
+		}
+	}
+	return patches, nil
+}
+
+func decodeDocuments(content []byte) ([]any, error) {
+	var docs []any
+	decoder := k8syaml.NewYAMLOrJSONDecoder(bytes.NewReader(content), 4096)
+	for {
+		var doc any
+		if err := decoder.Decode(&doc); err != nil {
+			if errors.Is(err, io.EOF) {
+				return docs, nil
+			}
+			return nil, err // nolint:wrapcheck
+		}
+		if doc != nil {
+			docs = append(docs, doc)
+		}
+	}
+}
+
+func parseReferencePatch(source string, doc any) (*referencePatch, error) {
+	obj, ok := doc.(map[string]any)
+	if !ok {
+		return nil, errors.New("a JSON6902 patch must be set in the patch of an entry with a target")
+	}
+	if _, isResource := obj["kind"]; isResource {
+		return newStrategicPatch(source, nil, obj)
+	}
+	content, err := json.Marshal(obj)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal patch entry: %w", err)
+	}
+	var entry kustomizePatch
+	if err := json.Unmarshal(content, &entry); err != nil {
+		return nil, fmt.Errorf("failed to parse patch entry: %w", err)
+	}
+	if (entry.Patch == "") == (entry.Path == "") {
+		return nil, errors.New("a patch entry must set one of patch and path")
+	}
+	patch := []byte(entry.Patch)
+	if entry.Path != "" {
+		if patch, err = os.ReadFile(filepath.Join(filepath.Dir(source), entry.Path)); err != nil {
+			return nil, fmt.Errorf("failed to read patch: %w", err)
+		}
+	}
+	var parsed any
+	if err := yaml.Unmarshal(patch, &parsed); err != nil {
+		return nil, fmt.Errorf("failed to parse patch: %w", err)
+	}
+	switch p := parsed.(type) {
+	case map[string]any:
+		return newStrategicPatch(source, entry.Target, p)
+	case []any:
+		if entry.Target == nil {
+			return nil, errors.New("a JSON6902 patch requires a target")
+		}
+		content, err := json.Marshal(p)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal JSON6902 patch: %w", err)
+		}
+		if _, err := jsonpatch.DecodePatch(content); err != nil {
+			return nil, fmt.Errorf("failed to decode JSON6902 patch: %w", err)
+		}
+		return &referencePatch{Source: source, Target: *entry.Target, Type: rfc6902, Patch: content}, nil
+	}
+	return nil, errors.New("the patch is neither a strategic merge patch nor a JSON6902 patch")
+}
+
+// newStrategicPatch returns a strategic merge patch, targeting the objects the patch identifies when no target is set
+func newStrategicPatch(source string, target *patchTarget, patch map[string]any) (*referencePatch, error) {
+	content, err := json.Marshal(patch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal strategic merge patch: %w", err)
+	}
+	if target != nil {
+		return &referencePatch{Source: source, Target: *target, Type: strategicMergePatch, Patch: content}, nil
+	}
+	obj := unstructured.Unstructured{Object: patch}
+	if obj.GetName() == "" {
+		return nil, errors.New("a strategic merge patch without a target must set the apiVersion, kind and metadata.name of the objects it patches")
+	}
+	gv, err := schema.ParseGroupVersion(obj.GetAPIVersion())
+	if err != nil {
+		return nil, fmt.Errorf("invalid apiVersion of strategic merge patch: %w", err)
+	}
+	return &referencePatch{
+		Source: source,
+		Target: patchTarget{
+			Group:     gv.Group,
+			Version:   gv.Version,
+			Kind:      obj.GetKind(),
+			Name:      obj.GetName(),
+			Namespace: obj.GetNamespace(),
+		},
+		Type:  strategicMergePatch,
+		Patch: content,
+	}, nil
+}
+
+// apply patches the rendered template. Strategic merge patches of kinds without a patch strategy, such as custom
+// resources, are applied as JSON merge patches: their lists are replaced rather than merged.
+func (p *referencePatch) apply(rendered *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	data, err := json.Marshal(rendered)
+	if err != nil {
+		return rendered, fmt.Errorf("failed to marshal reference CR: %w", err)
+	}
+	var modified []byte
+	switch p.Type {
+	case rfc6902:
+		var ops jsonpatch.Patch
+		if ops, err = jsonpatch.DecodePatch(p.Patch); err == nil {
+			modified, err = ops.Apply(data)
+		}
+	default:
+		if typed, schemeErr := scheme.Scheme.New(rendered.GroupVersionKind()); schemeErr == nil {
+			modified, err = strategicpatch.StrategicMergePatch(data, p.Patch, typed)
+		} else {
+			modified, err = jsonpatch.MergePatch(data, p.Patch)
+		}
+	}
+	if err != nil {
+		return rendered, fmt.Errorf("failed to apply reference patch %s: %w", p.Source, err)
+	}
+	patched := make(map[string]any)
+	if err := json.Unmarshal(modified, &patched); err != nil {
+		return rendered, fmt.Errorf("failed to unmarshal patched reference CR: %w", err)
+	}
+	return &unstructured.Unstructured{Object: patched}, nil
+}
+
+// applyReferencePatches applies the patches targeting the rendered template in the order they were passed
+func applyReferencePatches(patches []*referencePatch, temp ReferenceTemplate, rendered *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	var err error
+	for _, p := range patches {
+		if !p.Target.matches(temp, rendered) {
+			continue
+		}
+		if rendered, err = p.apply(rendered); err != nil {
+			return rendered, err
+		}
+	}
+	return rendered, nil
+}
+
+// unusedReferencePatches returns the sources of the patches whose target matches none of the templates
+func unusedReferencePatches(patches []*referencePatch, templates []ReferenceTemplate) []string {
+	var unused []string
+	for _, p := range patches {
+		used := false
+		for _, temp := range templates {
+			if p.Target.matchesTemplate(temp) {
+				used = true
+				break
+			}
+		}
+		if !used {
+			unused = append(unused, p.Source)
+		}
+	}
+	return slices.Compact(unused)
+}
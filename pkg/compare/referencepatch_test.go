@@ -0,0 +1,61 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+func TestParseReferencePatchErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+		err  string
+	}{
+		{name: "json6902 without entry", doc: "- op: remove\n  path: /spec", err: "must be set in the patch of an entry"},
+		{name: "strategic without name", doc: "apiVersion: v1\nkind: ConfigMap", err: "must set the apiVersion, kind and metadata.name"},
+		{name: "entry without patch", doc: "target:\n  kind: ConfigMap", err: "must set one of patch and path"},
+		{name: "entry with patch and path", doc: "patch: 'data: {}'\npath: patch.yaml", err: "must set one of patch and path"},
+		{name: "invalid json6902", doc: "target:\n  kind: ConfigMap\npatch: '[\"replace\"]'", err: "failed to decode JSON6902 patch"},
+		{name: "scalar patch", doc: "target:\n  kind: ConfigMap\npatch: value", err: "neither a strategic merge patch nor a JSON6902 patch"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var doc any
+			require.NoError(t, yaml.Unmarshal([]byte(test.doc), &doc))
+			_, err := parseReferencePatch("patches.yaml", doc)
+			require.ErrorContains(t, err, test.err)
+		})
+	}
+}
+
+func TestReferencePatchOfCustomResourceReplacesLists(t *testing.T) {
+	var doc any
+	require.NoError(t, yaml.Unmarshal([]byte(`apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: widget
+spec:
+  ports:
+  - port: 8443
+`), &doc))
+	p, err := parseReferencePatch("patches.yaml", doc)
+	require.NoError(t, err)
+	require.Equal(t, patchTarget{Group: "example.com", Version: "v1", Kind: "Widget", Name: "widget"}, p.Target)
+
+	rendered := &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "example.com/v1",
+		"kind":       "Widget",
+		"metadata":   map[string]any{"name": "widget"},
+		"spec":       map[string]any{"ports": []any{map[string]any{"port": int64(80)}, map[string]any{"port": int64(443)}}},
+	}}
+	patched, err := p.apply(rendered)
+	require.NoError(t, err)
+	ports, _, err := unstructured.NestedSlice(patched.Object, "spec", "ports")
+	require.NoError(t, err)
+	require.Equal(t, []any{map[string]any{"port": float64(8443)}}, ports)
+}
@@ -0,0 +1,188 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	kcmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"sigs.k8s.io/yaml"
+)
+
+// validateReferenceFlags checks the flags that can't be used when comparing the cluster to multiple references
+func (o *Options) validateReferenceFlags() error {
+	if len(o.referenceConfigs) < 2 {
+		return nil
+	}
+	if len(o.contextNames) > 0 || o.allContexts || o.OutputFormat == PatchYaml || o.snapshotDir != "" ||
+		o.compareToSnapshot != "" || o.exportUnmatched != "" || o.generatePatches != "" || o.generateConfig != "" || o.extractValues != "" || o.metricsFile != "" || o.showMatchedOnly || o.dryRun ||
+		o.referenceLock != "" || o.verifySignature != "" || o.annotateDrift || o.removeAnnotations || o.emitEvents ||
+		o.review || o.runCachePath != "" || o.stream || slices.Contains(o.CRs.Filenames, stdinFilename) {
+		return usageErrorf("multiple references can't be used with --contexts, --all-contexts, snapshots, "+
+			"--export-unmatched, --generate-patches, --generate-config, --extract-values, --metrics-file, --show-matched-only, --dry-run, --reference-lock, --verify-signature, "+
+			"--annotate-drift, --remove-annotations, --emit-events, --review, --run-cache, --stream, -f - or -o %s", PatchYaml)
+	}
+	return nil
+}
+
+// setReferences loads every reference into its own copy of the options, the copies are completed as if the command
+// was run with the reference alone
+func (o *Options) setReferences(f kcmdutil.Factory) error {
+	for _, r := range o.referenceConfigs {
+		ro := *o
+		ro.referenceConfigs = []string{r}
+		// Progress of references compared concurrently would be interleaved
+		ro.Progress = ProgressNever
+		if err := ro.setup(f); err != nil {
+			return fmt.Errorf("failed to load reference %s: %w", r, err)
+		}
+		o.references = append(o.references, &ro)
+	}
+	return nil
+}
+
+// runReferences compares the cluster to all the references concurrently and prints the compliance matrix of the
+// cluster against them
+func (o *Options) runReferences(ctx context.Context) error {
+	results := make([]ReferenceOutput, len(o.references))
+	errOut := &syncWriter{w: o.ErrOut}
+	var wg sync.WaitGroup
+	for i, ro := range o.references {
+		ro.ErrOut = errOut
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = ReferenceOutput{Reference: ro.referenceConfig}
+			sum, _, err := ro.compare(ctx)
+			results[i].Summary = sum
+			if err != nil {
+				results[i].Error = err.Error()
+			}
+		}()
+	}
+	wg.Wait()
+
+	output := MatrixOutput{References: results, BestMatch: bestMatchingReference(results)}
+	if err := output.Print(o.OutputFormat, o.Out); err != nil {
+		return err
+	}
+	if ctx.Err() != nil {
+		return interruptedError{cause: context.Cause(ctx)}
+	}
+	if output.BestMatch == "" {
+		return errors.New("failed to compare the cluster to any of the references")
+	}
+	for _, r := range results {
+		if r.Reference == output.BestMatch {
+			return o.exitPolicy.exitError(r.Summary)
+		}
+	}
+	return nil
+}
+
+// syncWriter serializes the writes of the comparisons running concurrently (e.g. the errors of external diff programs)
+type syncWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (s *syncWriter) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.w.Write(p) // nolint:wrapcheck
+}
+
+// ReferenceOutput is the summary of the comparison of the cluster to a reference, the error is set if it couldn't be
+// compared
+type ReferenceOutput struct {
+	Reference string   `json:"Reference"`
+	Summary   *Summary `json:"Summary,omitempty"`
+	Error     string   `json:"Error,omitempty"`
+}
+
+// deviations is the number of CRs of the cluster that deviate from the reference: the CRs with diffs and the CRs of the
+// reference missing from the cluster
+func (r ReferenceOutput) deviations() int {
+	return r.Summary.NumDiffCRs + r.Summary.NumMissing
+}
+
+// bestMatchingReference returns the reference the cluster deviates the least from, the first one in case of a tie
+func bestMatchingReference(results []ReferenceOutput) string {
+	best := -1
+	for i, r := range results {
+		if r.Summary == nil || r.Summary.Interrupted != "" {
+			continue
+		}
+		if best < 0 || r.deviations() < results[best].deviations() {
+			best = i
+		}
+	}
+	if best < 0 {
+		return ""
+	}
+	return results[best].Reference
+}
+
+// MatrixOutput is the output of comparing the cluster to multiple references, the summaries of the references side by
+// side
+type MatrixOutput struct {
+	References []ReferenceOutput `json:"References"`
+	BestMatch  string            `json:"BestMatch,omitempty"`
+}
+
+func (o MatrixOutput) String() string {
+	var sb strings.Builder
+	w := tabwriter.NewWriter(&sb, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "REFERENCE\tCRS WITH DIFFS\tMISSING CRS\tUNMATCHED CRS\tPATCHED CRS")
+	for _, r := range o.References {
+		if s := r.Summary; s != nil {
+			fmt.Fprintf(w, "%s\t%d/%d\t%d\t%d\t%d\n", r.Reference, s.NumDiffCRs, s.TotalCRs, s.NumMissing, len(s.UnmatchedCRS), s.PatchedCRs)
+		} else {
+			fmt.Fprintf(w, "%s\terror: %s\n", r.Reference, r.Error)
+		}
+	}
+	w.Flush()
+	for _, r := range o.References {
+		if r.Summary != nil && r.Error != "" {
+			fmt.Fprintf(&sb, "\nReference %s: %s\n", r.Reference, r.Error)
+		}
+	}
+	if o.BestMatch != "" {
+		fmt.Fprintf(&sb, "\nBest matching reference: %s\n", o.BestMatch)
+	}
+	return sb.String()
+}
+
+func (o MatrixOutput) Print(format string, out io.Writer) error {
+	var (
+		content []byte
+		err     error
+	)
+	switch format {
+	case Json:
+		content, err = json.Marshal(o)
+		if err != nil {
+			return fmt.Errorf("failed to marshal output to json: %w", err)
+		}
+		content = append(content, []byte("\n")...)
+	case Yaml:
+		content, err = yaml.Marshal(o)
+		if err != nil {
+			return fmt.Errorf("failed to marshal output to yaml: %w", err)
+		}
+	default:
+		content = []byte(o.String())
+	}
+	if _, err := out.Write(content); err != nil {
+		return fmt.Errorf("error occurred when writing output: %w", err)
+	}
+	return nil
+}
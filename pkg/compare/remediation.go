@@ -0,0 +1,147 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	PatchFormatMerge    = "merge"
+	PatchFormatManifest = "manifest"
+)
+
+var PatchFormats = []string{PatchFormatMerge, PatchFormatManifest}
+
+// patchScriptName is the script applying the merge patches written by --generate-patches
+const patchScriptName = "patch.sh"
+
+// remediation is the merge patch bringing a cluster CR in line with the template it was compared to
+type remediation struct {
+	cr    *unstructured.Unstructured
+	patch []byte
+}
+
+// remediations collects the merge patches of the CRs with diffs of a run, CRs are compared concurrently
+type remediations struct {
+	mu    sync.Mutex
+	items []remediation
+}
+
+func (r *remediations) add(cr *unstructured.Unstructured, patch []byte) {
+	if r == nil || patch == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.items = append(r.items, remediation{cr: cr, patch: patch})
+}
+
+// remediationPatch returns the merge patch turning the compared fields of the cluster CR into the rendered template.
+// Fields omitted from the comparison aren't part of the patch, fields of the CR the template doesn't set are removed
+// by the patch as they're reported as diffs.
+func remediationPatch(obj *InfoObject) ([]byte, error) {
+	merged, err := obj.Merged()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create remediation patch: %w", err)
+	}
+	mergedData, err := json.Marshal(merged)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal reference CR: %w", err)
+	}
+	liveData, err := json.Marshal(obj.Live())
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal cluster CR: %w", err)
+	}
+	patch, err := jsonpatch.CreateMergePatch(liveData, mergedData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create remediation patch: %w", err)
+	}
+	return patch, nil
+}
+
+// writeRemediations writes a file per CR with diffs to the directory. With the merge format the files are the merge
+// patches of the CRs, together with a script patching the CRs with kubectl. With the manifest format the files are
+// the CRs with the patches applied, ready to be applied with kubectl.
+func writeRemediations(dir, format string, r *remediations) error {
+	r.mu.Lock()
+	items := append([]remediation(nil), r.items...)
+	r.mu.Unlock()
+	// The CRs are sorted so the file names of CRs with the same kind, namespace and name don't depend on the order
+	// they were compared in
+	sort.Slice(items, func(i, j int) bool { return apiKindNamespaceName(items[i].cr) < apiKindNamespaceName(items[j].cr) })
+
+	// The names are reserved under the yaml name of the template of the CR, like for the generate command
+	reserved := make(map[string][]byte)
+	files := make(map[string][]byte)
+	var script strings.Builder
+	script.WriteString("#!/bin/sh\nset -e\ncd \"$(dirname \"$0\")\"\n")
+	for _, item := range items {
+		name := templateFileName(item.cr, reserved)
+		reserved[name] = item.patch
+		if format == PatchFormatManifest {
+			manifest, err := remediatedManifest(item)
+			if err != nil {
+				return err
+			}
+			files[name] = manifest
+			continue
+		}
+		name = strings.TrimSuffix(name, ".yaml") + ".json"
+		files[name] = append(slices.Clone(item.patch), '\n')
+		script.WriteString(patchCommand(item.cr, name))
+	}
+	if format == PatchFormatMerge && len(items) > 0 {
+		files[patchScriptName] = []byte(script.String())
+	}
+	for name, content := range files {
+		mode := os.FileMode(0o644)
+		if name == patchScriptName {
+			mode = 0o755
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), content, mode); err != nil {
+			return fmt.Errorf("failed to write remediation patch: %w", err)
+		}
+	}
+	return nil
+}
+
+// remediatedManifest returns the cluster CR with the remediation patch applied, without the fields populated by the
+// API server
+func remediatedManifest(item remediation) ([]byte, error) {
+	crData, err := json.Marshal(withoutServerPopulatedFields(item.cr).Object)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal cluster CR %s: %w", apiKindNamespaceName(item.cr), err)
+	}
+	patched, err := jsonpatch.MergePatch(crData, item.patch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to apply the remediation patch to %s: %w", apiKindNamespaceName(item.cr), err)
+	}
+	manifest, err := yaml.JSONToYAML(patched)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal the remediated %s: %w", apiKindNamespaceName(item.cr), err)
+	}
+	return manifest, nil
+}
+
+// patchCommand returns the kubectl command patching the CR with the merge patch file. The resource is fully qualified
+// so it isn't ambiguous when several groups serve the kind.
+func patchCommand(cr *unstructured.Unstructured, patchFile string) string {
+	gvk := cr.GroupVersionKind()
+	cmd := fmt.Sprintf("kubectl patch %s.%s.%s %s", gvk.Kind, gvk.Version, gvk.Group, cr.GetName())
+	if cr.GetNamespace() != "" {
+		cmd += " -n " + cr.GetNamespace()
+	}
+	return cmd + " --type merge --patch-file " + patchFile + "\n"
+}
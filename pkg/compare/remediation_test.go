@@ -0,0 +1,87 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestRemediationPatch(t *testing.T) {
+	obj := InfoObject{
+		injectedObjFromTemplate: &unstructured.Unstructured{Object: map[string]any{"apiVersion": "v1", "kind": "ConfigMap",
+			"metadata": map[string]any{"name": "cm", "namespace": "ns"},
+			"data":     map[string]any{"a": "1", "b": "2"}}},
+		clusterObj: &unstructured.Unstructured{Object: map[string]any{"apiVersion": "v1", "kind": "ConfigMap",
+			"metadata": map[string]any{"name": "cm", "namespace": "ns", "uid": "1234"},
+			"data":     map[string]any{"a": "1", "b": "3", "c": "4"}}},
+		FieldsToOmit: []*ManifestPathV1{{PathToKey: "metadata.uid", parts: []string{"metadata", "uid"}}},
+	}
+	patch, err := remediationPatch(&obj)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"data":{"b":"2","c":null}}`, string(patch), "omitted fields shouldn't be patched")
+}
+
+func remediationsForTest() *remediations {
+	r := &remediations{}
+	r.add(&unstructured.Unstructured{Object: map[string]any{"apiVersion": "apps/v1", "kind": "Deployment",
+		"metadata": map[string]any{"name": "web", "namespace": "ns", "resourceVersion": "5"},
+		"spec":     map[string]any{"replicas": int64(2), "paused": true}}},
+		[]byte(`{"spec":{"paused":null,"replicas":3}}`))
+	r.add(&unstructured.Unstructured{Object: map[string]any{"apiVersion": "v1", "kind": "Namespace",
+		"metadata": map[string]any{"name": "ns"}}},
+		[]byte(`{"metadata":{"labels":{"team":"web"}}}`))
+	return r
+}
+
+func TestWriteRemediationsMerge(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "patches")
+	require.NoError(t, prepareExportDir(dir))
+	require.NoError(t, writeRemediations(dir, PatchFormatMerge, remediationsForTest()))
+
+	patch, err := os.ReadFile(filepath.Join(dir, "Deployment_ns_web.json"))
+	require.NoError(t, err)
+	require.Equal(t, "{\"spec\":{\"paused\":null,\"replicas\":3}}\n", string(patch))
+
+	script, err := os.ReadFile(filepath.Join(dir, patchScriptName))
+	require.NoError(t, err)
+	require.Equal(t, `#!/bin/sh
+set -e
+cd "$(dirname "$0")"
+kubectl patch Deployment.v1.apps web -n ns --type merge --patch-file Deployment_ns_web.json
+kubectl patch Namespace.v1. ns --type merge --patch-file Namespace_ns.json
+`, string(script))
+}
+
+func TestWriteRemediationsManifest(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "patches")
+	require.NoError(t, prepareExportDir(dir))
+	require.NoError(t, writeRemediations(dir, PatchFormatManifest, remediationsForTest()))
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 2, "no script should be written with the manifest format")
+	deployment, err := os.ReadFile(filepath.Join(dir, "Deployment_ns_web.yaml"))
+	require.NoError(t, err)
+	require.Equal(t, `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: ns
+spec:
+  replicas: 3
+`, string(deployment))
+}
+
+func TestWriteRemediationsNoDiffs(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "patches")
+	require.NoError(t, prepareExportDir(dir))
+	require.NoError(t, writeRemediations(dir, PatchFormatMerge, &remediations{}))
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
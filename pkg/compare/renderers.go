@@ -0,0 +1,116 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Renderer writes the output of the comparison of a cluster to a reference in an output format
+type Renderer interface {
+	Render(out io.Writer, o Output, showEmptyDiffs bool) error
+}
+
+// RendererFunc is a function rendering the output, e.g. to register a format without declaring a type
+type RendererFunc func(out io.Writer, o Output, showEmptyDiffs bool) error
+
+func (f RendererFunc) Render(out io.Writer, o Output, showEmptyDiffs bool) error {
+	return f(out, o, showEmptyDiffs)
+}
+
+// textFormat is the format of the human readable output, used when -o isn't set
+const textFormat = ""
+
+var (
+	renderersMu sync.RWMutex
+	renderers   = map[string]Renderer{
+		textFormat: RendererFunc(renderText),
+		Json:       RendererFunc(renderJSON),
+		Yaml:       RendererFunc(renderYAML),
+		Jsonl:      RendererFunc(renderJSONL),
+		PatchYaml:  RendererFunc(renderPatches),
+	}
+)
+
+// RegisterRenderer registers the renderer of an output format, which can then be selected with -o. Registering a
+// format already registered replaces its renderer. Renderers apply to the output of a single cluster compared to a
+// single reference, the other outputs are only available in the built-in formats.
+func RegisterRenderer(format string, r Renderer) {
+	renderersMu.Lock()
+	defer renderersMu.Unlock()
+	renderers[format] = r
+	if format != textFormat && !slices.Contains(OutputFormats, format) {
+		OutputFormats = append(OutputFormats, format)
+	}
+}
+
+func getRenderer(format string) (Renderer, bool) {
+	renderersMu.RLock()
+	defer renderersMu.RUnlock()
+	r, ok := renderers[format]
+	return r, ok
+}
+
+func writeOutput(out io.Writer, content []byte) error {
+	if _, err := out.Write(content); err != nil {
+		return fmt.Errorf("error occurred when writing output: %w", err)
+	}
+	return nil
+}
+
+func renderText(out io.Writer, o Output, showEmptyDiffs bool) error {
+	return writeOutput(out, []byte(o.String(showEmptyDiffs)))
+}
+
+func renderJSON(out io.Writer, o Output, _ bool) error {
+	content, err := json.Marshal(o)
+	if err != nil {
+		return fmt.Errorf("failed to marshal output to json: %w", err)
+	}
+	return writeOutput(out, append(content, '\n'))
+}
+
+func renderYAML(out io.Writer, o Output, _ bool) error {
+	content, err := yaml.Marshal(o)
+	if err != nil {
+		return fmt.Errorf("failed to marshal output to yaml: %w", err)
+	}
+	return writeOutput(out, content)
+}
+
+// renderJSONL writes the records the jsonl output streams, for outputs that weren't streamed
+func renderJSONL(out io.Writer, o Output, _ bool) error {
+	s := diffStreamer{out: out, format: Jsonl}
+	if o.Diffs != nil {
+		for _, d := range *o.Diffs {
+			s.write(d)
+		}
+	}
+	return s.finish(o.Summary)
+}
+
+func renderPatches(out io.Writer, o Output, _ bool) error {
+	content, err := yaml.Marshal(o.patches)
+	if err != nil {
+		return fmt.Errorf("failed to marshal patches to yaml: %w", err)
+	}
+	return writeOutput(out, content)
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	out io.Writer
+	n   int
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.out.Write(p)
+	w.n += n
+	return n, err // nolint:wrapcheck
+}
@@ -0,0 +1,48 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegisterRenderer(t *testing.T) {
+	const format = "markdown"
+	formats := slices.Clone(OutputFormats)
+	t.Cleanup(func() {
+		renderersMu.Lock()
+		defer renderersMu.Unlock()
+		delete(renderers, format)
+		OutputFormats = formats
+	})
+
+	output := Output{Summary: &Summary{TotalCRs: 2, NumDiffCRs: 1}, Diffs: &[]DiffSum{}}
+	_, err := output.Print(format, io.Discard, false)
+	require.ErrorContains(t, err, `no renderer registered for output format "markdown"`)
+
+	RegisterRenderer(format, RendererFunc(func(out io.Writer, o Output, _ bool) error {
+		_, err := fmt.Fprintf(out, "| CRs | With diffs |\n| %d | %d |\n", o.Summary.TotalCRs, o.Summary.NumDiffCRs)
+		return err // nolint:wrapcheck
+	}))
+	require.Contains(t, OutputFormats, format)
+
+	var out bytes.Buffer
+	n, err := output.Print(format, &out, false)
+	require.NoError(t, err)
+	require.Equal(t, "| CRs | With diffs |\n| 2 | 1 |\n", out.String())
+	require.Equal(t, out.Len(), n)
+
+	RegisterRenderer(format, RendererFunc(renderJSONL))
+	require.Len(t, OutputFormats, len(formats)+1, "a format should be listed once")
+
+	out.Reset()
+	_, err = output.Print(format, &out, false)
+	require.NoError(t, err)
+	require.Contains(t, out.String(), `{"Summary":{`)
+}
@@ -0,0 +1,308 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+	kcmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"k8s.io/kubectl/pkg/util/i18n"
+	"k8s.io/kubectl/pkg/util/templates"
+	"k8s.io/utils/exec"
+	"sigs.k8s.io/yaml"
+)
+
+var (
+	reportDiffLong = templates.LongDesc(`
+		Compare the results of two comparisons of a cluster to track the remediation progress between audits.
+
+		The report-diff command reads two results written by the compare command with -o json (or -o yaml), an older
+		and a newer one, and reports the CRs whose diffs were introduced, resolved or changed since the older result,
+		as well as the reference CRs newly missing from the cluster and the ones found again. Diffs are compared on
+		their changed lines, regardless of the dates and file names of their headers. CRs with diffs in the older
+		result that aren't in the newer one, e.g. deleted from the cluster, are reported as resolved.
+
+		Exit status: 0 No diffs were introduced or changed and no CRs are newly missing. 1 Diffs were introduced or
+		changed, or CRs are newly missing. >1 The results couldn't be compared.
+	`)
+
+	reportDiffExample = templates.Examples(`
+		# Report the progress between two audits of a cluster:
+		kubectl cluster-compare -r ./reference/metadata.yaml -o json > audit-1.json
+		kubectl cluster-compare -r ./reference/metadata.yaml -o json > audit-2.json
+		kubectl cluster-compare report-diff audit-1.json audit-2.json
+
+		# Print the progress as json:
+		kubectl cluster-compare report-diff audit-1.json audit-2.json -o json
+	`)
+)
+
+const RegressionsMsg = "diffs were introduced or changed, or CRs are newly missing"
+
+// ReportDiffEntry is a CR whose diff was introduced, resolved or changed between two results
+type ReportDiffEntry struct {
+	CR       string `json:"CR"`
+	Template string `json:"Template"`
+	// Diff is the diff of the CR in the newer result, or in the older result for resolved diffs
+	Diff string `json:"Diff,omitempty"`
+}
+
+// ReportDiffMissing is a reference CR newly missing from the cluster, or found again
+type ReportDiffMissing struct {
+	Part      string `json:"Part"`
+	Component string `json:"Component"`
+	Template  string `json:"Template"`
+}
+
+func (m ReportDiffMissing) String() string {
+	return fmt.Sprintf("%s/%s: %s", m.Part, m.Component, m.Template)
+}
+
+// ReportDiffResult is the output of the report-diff command in json and yaml formats
+type ReportDiffResult struct {
+	// ReferenceChanged is set when the results were produced with different references (or reference versions)
+	ReferenceChanged bool                `json:"ReferenceChanged"`
+	OldDiffCRs       int                 `json:"OldDiffCRs"`
+	NewDiffCRs       int                 `json:"NewDiffCRs"`
+	OldMissing       int                 `json:"OldMissing"`
+	NewMissing       int                 `json:"NewMissing"`
+	Introduced       []ReportDiffEntry   `json:"Introduced"`
+	Resolved         []ReportDiffEntry   `json:"Resolved"`
+	Changed          []ReportDiffEntry   `json:"Changed"`
+	NewlyMissing     []ReportDiffMissing `json:"NewlyMissing"`
+	NoLongerMissing  []ReportDiffMissing `json:"NoLongerMissing"`
+}
+
+// hasRegressions checks if the newer result is worse than the older one in any way
+func (r ReportDiffResult) hasRegressions() bool {
+	return len(r.Introduced) > 0 || len(r.Changed) > 0 || len(r.NewlyMissing) > 0
+}
+
+type ReportDiffOptions struct {
+	oldFile      string
+	newFile      string
+	OutputFormat string
+	genericiooptions.IOStreams
+}
+
+func NewReportDiffCmd(streams genericiooptions.IOStreams) *cobra.Command {
+	options := &ReportDiffOptions{IOStreams: streams}
+
+	cmd := &cobra.Command{
+		Use:                   "report-diff <old result> <new result>",
+		DisableFlagsInUseLine: true,
+		Short:                 i18n.T("Report the diffs introduced and resolved between two results."),
+		Long:                  reportDiffLong,
+		Example:               exampleForBinary(reportDiffExample),
+		Run: func(cmd *cobra.Command, args []string) {
+			kcmdutil.CheckDiffErr(options.Complete(cmd, args))
+			if err := options.Run(); err != nil {
+				if exitErr := diffError(err); exitErr != nil {
+					kcmdutil.CheckErr(kcmdutil.ErrExit)
+				}
+				kcmdutil.CheckDiffErr(err)
+			}
+		},
+	}
+	cmd.SetFlagErrorFunc(func(command *cobra.Command, err error) error {
+		kcmdutil.CheckDiffErr(kcmdutil.UsageErrorf(cmd, err.Error()))
+		return nil
+	})
+	cmd.Flags().StringVarP(&options.OutputFormat, "output", "o", "", fmt.Sprintf(`Output format. One of: (%s, %s)`, Json, Yaml))
+	return cmd
+}
+
+func (o *ReportDiffOptions) Complete(cmd *cobra.Command, args []string) error {
+	if len(args) != 2 {
+		return kcmdutil.UsageErrorf(cmd, "Expected the files of the old and the new results, got %d args", len(args))
+	}
+	o.oldFile, o.newFile = args[0], args[1]
+	if o.OutputFormat != "" && o.OutputFormat != Json && o.OutputFormat != Yaml {
+		return kcmdutil.UsageErrorf(cmd, "Invalid output format %q, must be one of: %s, %s", o.OutputFormat, Json, Yaml)
+	}
+	return nil
+}
+
+// Run compares the results and prints the differences, in case diffs were introduced or changed, or CRs are newly
+// missing, an exit error with code 1 is returned.
+func (o *ReportDiffOptions) Run() error {
+	oldResult, err := loadResultFile(o.oldFile)
+	if err != nil {
+		return err
+	}
+	newResult, err := loadResultFile(o.newFile)
+	if err != nil {
+		return err
+	}
+	result := reportDiff(oldResult, newResult)
+	if err := o.print(result); err != nil {
+		return fmt.Errorf("error occurred when writing output: %w", err)
+	}
+	if result.hasRegressions() {
+		return exec.CodeExitError{Err: errors.New(RegressionsMsg), Code: 1}
+	}
+	return nil
+}
+
+// loadResultFile reads the output of a comparison of a single cluster to a single reference, in json or yaml. Partial
+// results can't be compared as the CRs that weren't compared can't be told apart from resolved ones.
+func loadResultFile(path string) (Output, error) {
+	var result Output
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return result, fmt.Errorf("failed to read result %s: %w", path, err)
+	}
+	if err := yaml.Unmarshal(content, &result); err != nil {
+		return result, fmt.Errorf("result %s isn't in the json or yaml output format: %w", path, err)
+	}
+	if result.Summary == nil || result.Diffs == nil {
+		return result, fmt.Errorf("result %s doesn't hold the output of a comparison of a single cluster to a single reference", path)
+	}
+	if result.Summary.Interrupted != "" {
+		return result, fmt.Errorf("result %s is partial, the comparison was interrupted (%s)", path, result.Summary.Interrupted)
+	}
+	return result, nil
+}
+
+// reportDiff compares the diffs and the missing CRs of the results
+func reportDiff(oldResult, newResult Output) ReportDiffResult {
+	result := ReportDiffResult{
+		ReferenceChanged: oldResult.Summary.MetadataHash != newResult.Summary.MetadataHash,
+		OldDiffCRs:       oldResult.Summary.NumDiffCRs,
+		NewDiffCRs:       newResult.Summary.NumDiffCRs,
+		OldMissing:       oldResult.Summary.NumMissing,
+		NewMissing:       newResult.Summary.NumMissing,
+		Introduced:       []ReportDiffEntry{},
+		Resolved:         []ReportDiffEntry{},
+		Changed:          []ReportDiffEntry{},
+	}
+	oldDiffs := diffsByCR(*oldResult.Diffs)
+	newDiffs := diffsByCR(*newResult.Diffs)
+	for cr, d := range newDiffs {
+		entry := ReportDiffEntry{CR: cr, Template: d.CorrelatedTemplate, Diff: d.DiffOutput}
+		previous, ok := oldDiffs[cr]
+		switch {
+		case !ok:
+			result.Introduced = append(result.Introduced, entry)
+		case !slices.Equal(changedLines(previous.DiffOutput), changedLines(d.DiffOutput)):
+			result.Changed = append(result.Changed, entry)
+		}
+	}
+	for cr, d := range oldDiffs {
+		if _, ok := newDiffs[cr]; !ok {
+			result.Resolved = append(result.Resolved, ReportDiffEntry{CR: cr, Template: d.CorrelatedTemplate, Diff: d.DiffOutput})
+		}
+	}
+	for _, entries := range [][]ReportDiffEntry{result.Introduced, result.Resolved, result.Changed} {
+		sort.Slice(entries, func(i, j int) bool { return entries[i].CR < entries[j].CR })
+	}
+	oldMissing := missingCRs(oldResult.Summary)
+	newMissing := missingCRs(newResult.Summary)
+	result.NewlyMissing = missingDifference(newMissing, oldMissing)
+	result.NoLongerMissing = missingDifference(oldMissing, newMissing)
+	return result
+}
+
+// diffsByCR returns the CRs with diffs of a result
+func diffsByCR(diffs []DiffSum) map[string]DiffSum {
+	result := make(map[string]DiffSum)
+	for _, d := range diffs {
+		if d.HasDiff() {
+			result[d.CRName] = d
+		}
+	}
+	return result
+}
+
+// missingCRs returns the reference CRs reported missing in the summary
+func missingCRs(sum *Summary) map[ReportDiffMissing]bool {
+	result := make(map[ReportDiffMissing]bool)
+	for part, components := range sum.ValidationIssues {
+		for component, issue := range components {
+			for _, temp := range issue.CRs {
+				result[ReportDiffMissing{Part: part, Component: component, Template: temp}] = true
+			}
+		}
+	}
+	return result
+}
+
+// missingDifference returns the missing CRs of a that aren't in b, sorted
+func missingDifference(a, b map[ReportDiffMissing]bool) []ReportDiffMissing {
+	result := []ReportDiffMissing{}
+	for m := range a {
+		if !b[m] {
+			result = append(result, m)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].String() < result[j].String() })
+	return result
+}
+
+func (o *ReportDiffOptions) print(result ReportDiffResult) error {
+	var content []byte
+	var err error
+	switch o.OutputFormat {
+	case Json:
+		content, err = json.Marshal(result)
+		content = append(content, '\n')
+	case Yaml:
+		content, err = yaml.Marshal(result)
+	default:
+		content = []byte(result.String())
+	}
+	if err != nil {
+		return err // nolint:wrapcheck
+	}
+	_, err = o.Out.Write(content)
+	return err // nolint:wrapcheck
+}
+
+func (r ReportDiffResult) String() string {
+	var sb strings.Builder
+	if r.ReferenceChanged {
+		sb.WriteString("Warning: the results were produced with different references, diffs may have changed because of the reference\n")
+	}
+	fmt.Fprintf(&sb, "CRs with diffs: %d -> %d\n", r.OldDiffCRs, r.NewDiffCRs)
+	fmt.Fprintf(&sb, "CRs in reference missing from the cluster: %d -> %d\n", r.OldMissing, r.NewMissing)
+	writeEntries := func(title string, entries []ReportDiffEntry, withDiff bool) {
+		if len(entries) == 0 {
+			return
+		}
+		fmt.Fprintf(&sb, "%s: %d\n", title, len(entries))
+		for _, e := range entries {
+			fmt.Fprintf(&sb, "- %s (%s)\n", e.CR, e.Template)
+			if withDiff {
+				for _, line := range changedLines(e.Diff) {
+					fmt.Fprintf(&sb, "    %s\n", line)
+				}
+			}
+		}
+	}
+	writeMissing := func(title string, missing []ReportDiffMissing) {
+		if len(missing) == 0 {
+			return
+		}
+		fmt.Fprintf(&sb, "%s: %d\n", title, len(missing))
+		for _, m := range missing {
+			fmt.Fprintf(&sb, "- %s\n", m)
+		}
+	}
+	writeEntries("Introduced diffs", r.Introduced, true)
+	writeEntries("Changed diffs", r.Changed, true)
+	writeEntries("Resolved diffs", r.Resolved, false)
+	writeMissing("Newly missing CRs", r.NewlyMissing)
+	writeMissing("CRs no longer missing", r.NoLongerMissing)
+	if len(r.Introduced)+len(r.Changed)+len(r.Resolved)+len(r.NewlyMissing)+len(r.NoLongerMissing) == 0 {
+		sb.WriteString("No changes between the results\n")
+	}
+	return sb.String()
+}
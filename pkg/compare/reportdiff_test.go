@@ -0,0 +1,93 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+	"k8s.io/utils/exec"
+)
+
+func diffOutput(removed, added string) string {
+	return "diff -u -N TEMP/a TEMP/a\n--- TEMP/a\tDATE\n+++ TEMP/a\tDATE\n@@ -1,1 +1,1 @@\n-" + removed + "\n+" + added + "\n"
+}
+
+func writeResult(t *testing.T, name string, sum Summary, diffs []DiffSum) string {
+	content, err := json.Marshal(Output{Summary: &sum, Diffs: &diffs})
+	require.NoError(t, err)
+	p := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(p, content, 0o644))
+	return p
+}
+
+func TestReportDiff(t *testing.T) {
+	oldFile := writeResult(t, "old.json", Summary{
+		NumDiffCRs: 3, NumMissing: 1, MetadataHash: "1",
+		ValidationIssues: map[string]map[string]ValidationIssue{
+			"ExamplePart": {"Dashboard": {Msg: MissingCRsMsg, CRs: []string{"service.yaml"}}},
+		},
+	}, []DiffSum{
+		{CRName: "v1_ConfigMap_ns_fixed", CorrelatedTemplate: "cm.yaml", DiffOutput: diffOutput("a: 1", "a: 2")},
+		{CRName: "v1_ConfigMap_ns_same", CorrelatedTemplate: "cm.yaml", DiffOutput: diffOutput("b: 1", "b: 2")},
+		{CRName: "v1_ConfigMap_ns_changed", CorrelatedTemplate: "cm.yaml", DiffOutput: diffOutput("c: 1", "c: 2")},
+		{CRName: "v1_ConfigMap_ns_new", CorrelatedTemplate: "cm.yaml"},
+	})
+	newFile := writeResult(t, "new.json", Summary{
+		NumDiffCRs: 3, NumMissing: 1, MetadataHash: "1",
+		ValidationIssues: map[string]map[string]ValidationIssue{
+			"ExamplePart": {"Dashboard": {Msg: MissingCRsMsg, CRs: []string{"deployment.yaml"}}},
+		},
+	}, []DiffSum{
+		{CRName: "v1_ConfigMap_ns_fixed", CorrelatedTemplate: "cm.yaml"},
+		// Only the headers of the diff differ
+		{CRName: "v1_ConfigMap_ns_same", CorrelatedTemplate: "cm.yaml", DiffOutput: "diff -u -N TEMP/b TEMP/b\n--- TEMP/b\tDATE\n+++ TEMP/b\tDATE\n@@ -2,1 +2,1 @@\n-b: 1\n+b: 2\n"},
+		{CRName: "v1_ConfigMap_ns_changed", CorrelatedTemplate: "cm.yaml", DiffOutput: diffOutput("c: 1", "c: 3")},
+		{CRName: "v1_ConfigMap_ns_new", CorrelatedTemplate: "cm.yaml", DiffOutput: diffOutput("d: 1", "d: 2")},
+	})
+
+	out := &bytes.Buffer{}
+	o := &ReportDiffOptions{oldFile: oldFile, newFile: newFile, IOStreams: genericiooptions.IOStreams{Out: out}}
+	err := o.Run()
+	var exitErr exec.CodeExitError
+	require.ErrorAs(t, err, &exitErr, "introduced diffs should be reported with an exit error")
+	require.Equal(t, 1, exitErr.Code)
+	require.Equal(t, `CRs with diffs: 3 -> 3
+CRs in reference missing from the cluster: 1 -> 1
+Introduced diffs: 1
+- v1_ConfigMap_ns_new (cm.yaml)
+    -d: 1
+    +d: 2
+Changed diffs: 1
+- v1_ConfigMap_ns_changed (cm.yaml)
+    -c: 1
+    +c: 3
+Resolved diffs: 1
+- v1_ConfigMap_ns_fixed (cm.yaml)
+Newly missing CRs: 1
+- ExamplePart/Dashboard: deployment.yaml
+CRs no longer missing: 1
+- ExamplePart/Dashboard: service.yaml
+`, out.String())
+
+	out.Reset()
+	o.newFile = oldFile
+	require.NoError(t, o.Run())
+	require.Equal(t, "CRs with diffs: 3 -> 3\nCRs in reference missing from the cluster: 1 -> 1\nNo changes between the results\n", out.String())
+}
+
+func TestReportDiffInvalidResults(t *testing.T) {
+	partial := writeResult(t, "partial.json", Summary{Interrupted: "timed out after 5m0s"}, []DiffSum{})
+	_, err := loadResultFile(partial)
+	require.ErrorContains(t, err, "is partial")
+
+	multiple := filepath.Join(t.TempDir(), "contexts.json")
+	require.NoError(t, os.WriteFile(multiple, []byte(`{"Clusters":[]}`), 0o644))
+	_, err = loadResultFile(multiple)
+	require.ErrorContains(t, err, "single cluster")
+}
@@ -0,0 +1,289 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// defaultReviewOverridesFile is the overrides file the diffs acknowledged with --review are written to
+	// without -p
+	defaultReviewOverridesFile = "overrides.yaml"
+	// defaultReviewDiffConfigFile is the diff config the correlations set with --review are written to without -c
+	defaultReviewDiffConfigFile = "diff-config.yaml"
+)
+
+const reviewHelp = `Commands:
+  d  show the diff of the CR
+  a  acknowledge the diff, writing its patch to the overrides file with a reason
+  c  correlate the CR to another template in the diff config
+  n  next CR (or Enter)
+  p  previous CR
+  l  list the CRs with diffs
+  q  quit the review
+`
+
+// reviewer lets the user review the CRs with diffs one at a time once the comparison is over, with --review.
+// The commands are read line by line from the input stream, so a review can be scripted as well. Acknowledging the
+// diff of a CR adds the patch turning its template into the CR to the overrides file, the next runs loading the file
+// with -p report the CR as patched with the reason given. A CR compared to the wrong template can be correlated to
+// another one in the diff config, for the next runs loading it with -c. A nil reviewer doesn't record anything.
+type reviewer struct {
+	in             *bufio.Reader
+	out            io.Writer
+	overridesPath  string
+	diffConfigPath string
+	templates      []ReferenceTemplate
+
+	mu sync.Mutex
+	// patches are the patches turning the templates into the CRs with diffs, by CR
+	patches map[string]*UserOverride
+}
+
+func newReviewer(o *Options) *reviewer {
+	r := &reviewer{
+		in:             bufio.NewReader(o.In),
+		out:            o.Out,
+		overridesPath:  o.userOverridesPath,
+		diffConfigPath: o.diffConfigFileName,
+		templates:      o.templates,
+		patches:        map[string]*UserOverride{},
+	}
+	if r.overridesPath == "" {
+		r.overridesPath = defaultReviewOverridesFile
+	}
+	if r.diffConfigPath == "" {
+		r.diffConfigPath = defaultReviewDiffConfigFile
+	}
+	return r
+}
+
+// compared records the patch of a CR with diffs, so its diff can be acknowledged
+func (r *reviewer) compared(clusterCR *unstructured.Unstructured, patch *UserOverride) {
+	if r == nil || patch == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.patches[apiKindNamespaceName(clusterCR)] = patch
+}
+
+// reviewItem is a CR with diffs under review
+type reviewItem struct {
+	diff         DiffSum
+	acknowledged bool
+	correlated   string
+}
+
+func (i reviewItem) String() string {
+	s := fmt.Sprintf("%s (%s, %d changed lines)", i.diff.CRName, i.diff.CorrelatedTemplate, countChangedLines(i.diff.DiffOutput))
+	if i.diff.Severity != "" {
+		s += ", " + string(i.diff.Severity)
+	}
+	if i.acknowledged {
+		s += ", acknowledged"
+	}
+	if i.correlated != "" {
+		s += ", correlated to " + i.correlated
+	}
+	return s
+}
+
+// review walks through the CRs with diffs until the user quits or the input ends
+func (r *reviewer) review(diffs []DiffSum) error {
+	var items []*reviewItem
+	for _, d := range diffs {
+		if d.HasDiff() {
+			items = append(items, &reviewItem{diff: d})
+		}
+	}
+	if len(items) == 0 {
+		fmt.Fprintln(r.out, "No CRs with diffs to review")
+		return nil
+	}
+	fmt.Fprintf(r.out, "Reviewing %d CRs with diffs\n%s", len(items), reviewHelp)
+	current := 0
+	for {
+		fmt.Fprintf(r.out, "\n[%d/%d] %s\n", current+1, len(items), items[current])
+		command, ok := r.prompt("> ")
+		if !ok {
+			return nil
+		}
+		var err error
+		switch command {
+		case "d":
+			fmt.Fprintln(r.out, strings.TrimSuffix(items[current].diff.DiffOutput, "\n"))
+		case "a":
+			err = r.acknowledge(items[current])
+		case "c":
+			err = r.correlate(items[current])
+		case "n", "":
+			if current == len(items)-1 {
+				fmt.Fprintln(r.out, "Reviewed all the CRs with diffs")
+				return nil
+			}
+			current++
+		case "p":
+			current = max(current-1, 0)
+		case "l":
+			for i, item := range items {
+				fmt.Fprintf(r.out, "%d. %s\n", i+1, item)
+			}
+		case "q":
+			fmt.Fprintln(r.out)
+			return nil
+		default:
+			fmt.Fprintf(r.out, "Unknown command %q\n%s", command, reviewHelp)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// prompt reads the next line of input, it returns false once the input ends
+func (r *reviewer) prompt(message string) (string, bool) {
+	fmt.Fprint(r.out, message)
+	line, err := r.in.ReadString('\n')
+	if err != nil && (line == "" || !errors.Is(err, io.EOF)) {
+		fmt.Fprintln(r.out)
+		return "", false
+	}
+	return strings.TrimSpace(line), true
+}
+
+// acknowledge adds the patch of the CR to the overrides file with the reason given by the user
+func (r *reviewer) acknowledge(item *reviewItem) error {
+	if item.acknowledged {
+		fmt.Fprintf(r.out, "The diff is already acknowledged in %s\n", r.overridesPath)
+		return nil
+	}
+	r.mu.Lock()
+	patch, ok := r.patches[item.diff.CRName]
+	r.mu.Unlock()
+	if !ok {
+		fmt.Fprintln(r.out, "The diff can't be acknowledged, its patch wasn't recorded")
+		return nil
+	}
+	reason, ok := r.prompt("Reason: ")
+	if !ok || reason == "" {
+		fmt.Fprintln(r.out, "Not acknowledged, a reason is required")
+		return nil
+	}
+	acknowledged := *patch
+	acknowledged.Reason = reason
+	if err := appendUserOverride(r.overridesPath, &acknowledged); err != nil {
+		return err
+	}
+	item.acknowledged = true
+	fmt.Fprintf(r.out, "Acknowledged in %s, pass it with -p to report the CR as patched\n", r.overridesPath)
+	return nil
+}
+
+// appendUserOverride appends the override to the list of the overrides file, the file is created if it doesn't exist.
+// The file is only appended to so the comments, the order and the mode of the overrides already in it are kept.
+func appendUserOverride(path string, override *UserOverride) error {
+	content, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to read the overrides: %w", err)
+	}
+	overrides, err := LoadUserOverrides(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	entry, err := yaml.Marshal([]*UserOverride{override})
+	if err != nil {
+		return fmt.Errorf("failed to marshal the override: %w", err)
+	}
+	if len(content) > 0 && !bytes.HasSuffix(content, []byte("\n")) {
+		entry = append([]byte("\n"), entry...)
+	}
+	// An overrides file written as a flow list can't be appended to as a block list
+	var appended []*UserOverride
+	if err := yaml.Unmarshal(append(content, entry...), &appended); err != nil || len(appended) != len(overrides)+1 {
+		return fmt.Errorf("failed to add the override to %s, its list of overrides can't be appended to", path)
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to write the overrides: %w", err)
+	}
+	_, err = f.Write(entry)
+	if err = errors.Join(err, f.Close()); err != nil {
+		return fmt.Errorf("failed to write the overrides: %w", err)
+	}
+	return nil
+}
+
+// correlate sets the template of the CR in the manual correlation of the diff config, choosing among the templates of
+// the kind of the CR
+func (r *reviewer) correlate(item *reviewItem) error {
+	var candidates []string
+	for _, temp := range r.templates {
+		if temp.GetMetadata().GetKind() == item.diff.kind {
+			candidates = append(candidates, temp.GetPath())
+		}
+	}
+	slices.Sort(candidates)
+	for i, c := range candidates {
+		fmt.Fprintf(r.out, "%d. %s\n", i+1, c)
+	}
+	answer, ok := r.prompt("Template (number or path, empty to cancel): ")
+	if !ok || answer == "" {
+		return nil
+	}
+	template := answer
+	if n, err := strconv.Atoi(answer); err == nil && n >= 1 && n <= len(candidates) {
+		template = candidates[n-1]
+	}
+	if !slices.ContainsFunc(r.templates, func(t ReferenceTemplate) bool { return t.GetPath() == template }) {
+		fmt.Fprintf(r.out, "Template %s isn't in the reference\n", template)
+		return nil
+	}
+	if err := setManualCorrelation(r.diffConfigPath, item.diff.CRName, template); err != nil {
+		return err
+	}
+	item.correlated = template
+	fmt.Fprintf(r.out, "Correlated to %s in %s, pass it with -c to compare the CR to the template\n", template, r.diffConfigPath)
+	return nil
+}
+
+// setManualCorrelation sets the template of the CR in the correlation pairs of the diff config, the other settings
+// of the diff config are kept
+func setManualCorrelation(path, cr, template string) error {
+	config := map[string]any{}
+	content, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to read the diff config: %w", err)
+	}
+	if err := yaml.Unmarshal(content, &config); err != nil {
+		return fmt.Errorf("failed to parse the diff config: %w", err)
+	}
+	if config == nil {
+		config = map[string]any{}
+	}
+	if err := unstructured.SetNestedField(config, template, "correlationSettings", "manualCorrelation", "correlationPairs", cr); err != nil {
+		return fmt.Errorf("failed to set the correlation of %s: %w", cr, err)
+	}
+	content, err = yaml.Marshal(config)
+	if err != nil {
+		return fmt.Errorf("failed to marshal the diff config: %w", err)
+	}
+	if err := os.WriteFile(path, content, 0o600); err != nil {
+		return fmt.Errorf("failed to write the diff config: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/openshift/kube-compare/pkg/api"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/yaml"
+)
+
+func TestReview(t *testing.T) {
+	refDir := filepath.Join("testdata", "FieldSeverities", "reference")
+	ref, err := GetReference(os.DirFS(refDir), "metadata.yaml")
+	require.NoError(t, err)
+	templates, err := ParseTemplates(ref, os.DirFS(refDir))
+	require.NoError(t, err)
+
+	diffs := []DiffSum{
+		{CRName: "v1_ConfigMap_example_limits", CorrelatedTemplate: "limits.yaml", DiffOutput: "-  maxWorkers: \"8\"\n+  maxWorkers: \"16\"", kind: "ConfigMap"},
+		{CRName: "v1_Namespace_example", CorrelatedTemplate: "namespace.yaml"},
+		{CRName: "v1_ConfigMap_example_settings", CorrelatedTemplate: "settings.yaml", DiffOutput: "-  mode: strict\n+  mode: relaxed", kind: "ConfigMap", Severity: api.SeverityCritical},
+	}
+	patch := &UserOverride{ApiVersion: "v1", Kind: "ConfigMap", Namespace: "example", Name: "limits", Type: mergePatch,
+		Patch: `{"data":{"maxWorkers":"16"}}`, TemplatePath: "limits.yaml"}
+
+	newTestReviewer := func(t *testing.T, input string) (*reviewer, *bytes.Buffer) {
+		dir := t.TempDir()
+		out := &bytes.Buffer{}
+		return &reviewer{
+			in:             bufio.NewReader(strings.NewReader(input)),
+			out:            out,
+			overridesPath:  filepath.Join(dir, defaultReviewOverridesFile),
+			diffConfigPath: filepath.Join(dir, defaultReviewDiffConfigFile),
+			templates:      templates,
+			patches:        map[string]*UserOverride{"v1_ConfigMap_example_limits": patch},
+		}, out
+	}
+
+	t.Run("Acknowledge", func(t *testing.T) {
+		r, out := newTestReviewer(t, "d\na\nmore workers for the batch jobs\na\nn\na\nn\n")
+		require.NoError(t, r.review(diffs))
+		require.Contains(t, out.String(), "Reviewing 2 CRs with diffs")
+		require.Contains(t, out.String(), "[1/2] v1_ConfigMap_example_limits (limits.yaml, 2 changed lines)")
+		require.Contains(t, out.String(), `+  maxWorkers: "16"`)
+		require.Contains(t, out.String(), "The diff is already acknowledged")
+		require.Contains(t, out.String(), "[2/2] v1_ConfigMap_example_settings (settings.yaml, 2 changed lines), critical")
+		require.Contains(t, out.String(), "The diff can't be acknowledged, its patch wasn't recorded")
+		require.Contains(t, out.String(), "Reviewed all the CRs with diffs")
+
+		overrides, err := LoadUserOverrides(r.overridesPath)
+		require.NoError(t, err)
+		require.Len(t, overrides, 1)
+		require.Equal(t, "more workers for the batch jobs", overrides[0].Reason)
+		require.Equal(t, patch.Patch, overrides[0].Patch)
+		require.Empty(t, patch.Reason, "the recorded patch shouldn't be changed")
+	})
+
+	t.Run("Acknowledge Appends To Overrides", func(t *testing.T) {
+		r, _ := newTestReviewer(t, "a\nmore workers\nq\n")
+		existing := "# Overrides of the lab clusters\n- apiVersion: v1\n  kind: Namespace\n  name: example\n  type: mergepatch\n  patch: '{}'\n  reason: kept as is"
+		require.NoError(t, os.WriteFile(r.overridesPath, []byte(existing), 0o640))
+		require.NoError(t, os.Chmod(r.overridesPath, 0o640))
+		require.NoError(t, r.review(diffs))
+
+		content, err := os.ReadFile(r.overridesPath)
+		require.NoError(t, err)
+		require.True(t, strings.HasPrefix(string(content), existing+"\n"), "the overrides file should only be appended to")
+		info, err := os.Stat(r.overridesPath)
+		require.NoError(t, err)
+		require.Equal(t, os.FileMode(0o640), info.Mode().Perm())
+		overrides, err := LoadUserOverrides(r.overridesPath)
+		require.NoError(t, err)
+		require.Len(t, overrides, 2)
+		require.Equal(t, "kept as is", overrides[0].Reason)
+		require.Equal(t, "more workers", overrides[1].Reason)
+
+		r, _ = newTestReviewer(t, "a\nmore workers\nq\n")
+		flow := "[{apiVersion: v1, kind: Namespace, name: example, type: mergepatch, patch: '{}', reason: kept as is}]\n"
+		require.NoError(t, os.WriteFile(r.overridesPath, []byte(flow), 0o600))
+		require.ErrorContains(t, r.review(diffs), "its list of overrides can't be appended to")
+		content, err = os.ReadFile(r.overridesPath)
+		require.NoError(t, err)
+		require.Equal(t, flow, string(content))
+	})
+
+	t.Run("Correlate", func(t *testing.T) {
+		r, out := newTestReviewer(t, "c\nmissing.yaml\nc\n\nc\n1\nl\nq\n")
+		require.NoError(t, os.WriteFile(r.diffConfigPath, []byte("fieldsToOmit:\n  defaultOmitRef: all\n"), 0o600))
+		require.NoError(t, r.review(diffs))
+		require.Contains(t, out.String(), "1. features.yaml\n2. limits.yaml\n3. settings.yaml\n")
+		require.Contains(t, out.String(), "Template missing.yaml isn't in the reference")
+		require.Contains(t, out.String(), "1. v1_ConfigMap_example_limits (limits.yaml, 2 changed lines), correlated to features.yaml")
+
+		content, err := os.ReadFile(r.diffConfigPath)
+		require.NoError(t, err)
+		var config map[string]any
+		require.NoError(t, yaml.Unmarshal(content, &config))
+		require.Equal(t, map[string]any{
+			"fieldsToOmit": map[string]any{"defaultOmitRef": "all"},
+			"correlationSettings": map[string]any{"manualCorrelation": map[string]any{"correlationPairs": map[string]any{
+				"v1_ConfigMap_example_limits": "features.yaml",
+			}}},
+		}, config)
+	})
+
+	t.Run("Unknown Command And End Of Input", func(t *testing.T) {
+		r, out := newTestReviewer(t, "x\np")
+		require.NoError(t, r.review(diffs))
+		require.Contains(t, out.String(), "Unknown command \"x\"\n"+reviewHelp)
+		require.NoFileExists(t, r.overridesPath)
+		require.NoFileExists(t, r.diffConfigPath)
+	})
+
+	t.Run("No Diffs", func(t *testing.T) {
+		r, out := newTestReviewer(t, "")
+		require.NoError(t, r.review(diffs[1:2]))
+		require.Equal(t, "No CRs with diffs to review\n", out.String())
+	})
+}
@@ -0,0 +1,348 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/openapi3"
+	"k8s.io/kube-openapi/pkg/spec3"
+	"k8s.io/kube-openapi/pkg/validation/spec"
+	kcmdutil "k8s.io/kubectl/pkg/cmd/util"
+)
+
+const (
+	extensionGVK                   = "x-kubernetes-group-version-kind"
+	extensionPreserveUnknownFields = "x-kubernetes-preserve-unknown-fields"
+	extensionIntOrString           = "x-kubernetes-int-or-string"
+	extensionEmbeddedResource      = "x-kubernetes-embedded-resource"
+	schemaComponentsPrefix         = "#/components/schemas/"
+	schemaViolationPrefix          = "schema violation: "
+)
+
+// schemaSet holds the OpenAPI v3 schemas of kinds the rendered templates are validated against, either served by the
+// cluster or read from a schema bundle of CRDs and OpenAPI v3 documents
+type schemaSet struct {
+	kinds map[schema.GroupVersionKind]*spec.Schema
+	// components are the schemas the schemas of the OpenAPI documents refer to, by name
+	components map[string]*spec.Schema
+}
+
+func newSchemaSet() *schemaSet {
+	return &schemaSet{kinds: make(map[schema.GroupVersionKind]*spec.Schema), components: make(map[string]*spec.Schema)}
+}
+
+// addOpenAPI adds the schemas of an OpenAPI v3 document, the schemas of kinds are the ones declaring their group
+// version kind
+func (s *schemaSet) addOpenAPI(doc *spec3.OpenAPI) {
+	if doc.Components == nil {
+		return
+	}
+	for name, sch := range doc.Components.Schemas {
+		s.components[name] = sch
+		gvks, _ := sch.Extensions[extensionGVK].([]any)
+		for _, gvk := range gvks {
+			m, ok := gvk.(map[string]any)
+			if !ok {
+				continue
+			}
+			group, _ := m["group"].(string)
+			version, _ := m["version"].(string)
+			kind, _ := m["kind"].(string)
+			s.kinds[schema.GroupVersionKind{Group: group, Version: version, Kind: kind}] = sch
+		}
+	}
+}
+
+// addCRD adds the schemas of the served versions of a CRD
+func (s *schemaSet) addCRD(crd *apiextensionsv1.CustomResourceDefinition) error {
+	for _, v := range crd.Spec.Versions {
+		if v.Schema == nil || v.Schema.OpenAPIV3Schema == nil {
+			continue
+		}
+		content, err := json.Marshal(v.Schema.OpenAPIV3Schema)
+		if err != nil {
+			return fmt.Errorf("failed to read the schema of CRD %s version %s: %w", crd.Name, v.Name, err)
+		}
+		sch := &spec.Schema{}
+		if err := json.Unmarshal(content, sch); err != nil {
+			return fmt.Errorf("failed to read the schema of CRD %s version %s: %w", crd.Name, v.Name, err)
+		}
+		s.kinds[schema.GroupVersionKind{Group: crd.Spec.Group, Version: v.Name, Kind: crd.Spec.Names.Kind}] = sch
+	}
+	return nil
+}
+
+// loadSchemaBundle reads the schemas of a schema bundle: a file or a directory of YAML or JSON files holding CRDs or
+// OpenAPI v3 documents, like the ones served by the API server under /openapi/v3
+func loadSchemaBundle(path string) (*schemaSet, error) {
+	s := newSchemaSet()
+	err := filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !slices.Contains([]string{".yaml", ".yml", ".json"}, filepath.Ext(p)) {
+			return nil
+		}
+		content, err := os.ReadFile(p)
+		if err != nil {
+			return err // nolint:wrapcheck
+		}
+		if err := s.addBundleFile(content); err != nil {
+			return fmt.Errorf("%s: %w", p, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the schema bundle: %w", err)
+	}
+	if len(s.kinds) == 0 {
+		return nil, fmt.Errorf("failed to load the schema bundle: %s holds no CRD nor OpenAPI v3 document declaring kinds", path)
+	}
+	return s, nil
+}
+
+func (s *schemaSet) addBundleFile(content []byte) error {
+	docs, err := decodeDocuments(content)
+	if err != nil {
+		return err
+	}
+	for _, doc := range docs {
+		m, ok := doc.(map[string]any)
+		if !ok {
+			return errors.New("the file holds a document that isn't an object")
+		}
+		if _, ok := m["openapi"]; ok {
+			openAPI := &spec3.OpenAPI{}
+			content, err := json.Marshal(m)
+			if err == nil {
+				err = json.Unmarshal(content, openAPI)
+			}
+			if err != nil {
+				return fmt.Errorf("invalid OpenAPI document: %w", err)
+			}
+			s.addOpenAPI(openAPI)
+			continue
+		}
+		if kind, _ := m["kind"].(string); kind != "CustomResourceDefinition" {
+			return fmt.Errorf("the file holds a %s, schema bundles hold CRDs and OpenAPI v3 documents", kind)
+		}
+		crd := &apiextensionsv1.CustomResourceDefinition{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(m, crd); err != nil {
+			return fmt.Errorf("invalid CRD: %w", err)
+		}
+		if err := s.addCRD(crd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// clusterSchemas fetches the OpenAPI v3 documents of the group versions of the templates from the cluster, group
+// versions the cluster doesn't serve are left out
+func clusterSchemas(f kcmdutil.Factory, templates []ReferenceTemplate) (*schemaSet, error) {
+	client, err := f.OpenAPIV3Client()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OpenAPI client: %w", err)
+	}
+	root := openapi3.NewRoot(client)
+	served, err := root.GroupVersions()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the OpenAPI schemas of the cluster: %w", err)
+	}
+	s := newSchemaSet()
+	fetched := make(map[schema.GroupVersion]bool)
+	for _, temp := range templates {
+		gv := temp.GetMetadata().GroupVersionKind().GroupVersion()
+		if fetched[gv] || !slices.Contains(served, gv) {
+			continue
+		}
+		fetched[gv] = true
+		doc, err := root.GVSpec(gv)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch the OpenAPI schema of %s: %w", gv, err)
+		}
+		s.addOpenAPI(doc)
+	}
+	return s, nil
+}
+
+// violations validates the object against the schema of its kind and returns the violations: fields the schema
+// doesn't declare and, when types are checked, values of the wrong type. Objects of kinds without a schema aren't
+// validated. Null values aren't validated, templates rendered without input leave their templated values null.
+func (s *schemaSet) violations(obj *unstructured.Unstructured, checkTypes bool) []string {
+	if s == nil {
+		return nil
+	}
+	sch, ok := s.kinds[obj.GroupVersionKind()]
+	if !ok {
+		return nil
+	}
+	v := schemaValidator{set: s, checkTypes: checkTypes}
+	v.validateObject(obj.Object, sch, "", true)
+	sort.Strings(v.violations)
+	return v.violations
+}
+
+type schemaValidator struct {
+	set        *schemaSet
+	checkTypes bool
+	violations []string
+}
+
+// resolve follows the reference of the schema, and the allOf the OpenAPI documents of the API server wrap references
+// of fields in
+func (v *schemaValidator) resolve(sch *spec.Schema) *spec.Schema {
+	for range 10 {
+		if ref := sch.Ref.String(); ref != "" {
+			resolved, ok := v.set.components[strings.TrimPrefix(ref, schemaComponentsPrefix)]
+			if !ok {
+				return &spec.Schema{}
+			}
+			sch = resolved
+			continue
+		}
+		if len(sch.Properties) == 0 && len(sch.Type) == 0 && len(sch.AllOf) == 1 {
+			sch = &sch.AllOf[0]
+			continue
+		}
+		break
+	}
+	return sch
+}
+
+func (v *schemaValidator) add(path, format string, args ...any) {
+	v.violations = append(v.violations, schemaViolationPrefix+strings.TrimPrefix(path, ".")+": "+fmt.Sprintf(format, args...))
+}
+
+// validateObject validates the fields of an object, the fields of the metadata of resources are known to the API
+// server whether the schema declares them or not
+func (v *schemaValidator) validateObject(obj map[string]any, sch *spec.Schema, path string, resource bool) {
+	keys := make([]string, 0, len(obj))
+	for key := range obj {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	preserveUnknown, _ := sch.Extensions[extensionPreserveUnknownFields].(bool)
+	for _, key := range keys {
+		fieldPath := path + "." + key
+		if prop, ok := sch.Properties[key]; ok {
+			if resource && key == "metadata" && len(v.resolve(&prop).Properties) == 0 {
+				continue
+			}
+			v.validate(obj[key], &prop, fieldPath)
+			continue
+		}
+		switch {
+		case resource && slices.Contains([]string{"apiVersion", "kind", "metadata"}, key):
+		case sch.AdditionalProperties != nil && sch.AdditionalProperties.Schema != nil:
+			v.validate(obj[key], sch.AdditionalProperties.Schema, fieldPath)
+		case sch.AdditionalProperties != nil && sch.AdditionalProperties.Allows, preserveUnknown:
+		default:
+			v.add(fieldPath, "field not declared in the schema")
+		}
+	}
+}
+
+func (v *schemaValidator) validate(value any, sch *spec.Schema, path string) {
+	if value == nil {
+		return
+	}
+	sch = v.resolve(sch)
+	if intOrString, _ := sch.Extensions[extensionIntOrString].(bool); intOrString {
+		if v.checkTypes && !isInteger(value) {
+			if _, ok := value.(string); !ok {
+				v.add(path, "expected an integer or a string, got %s", jsonType(value))
+			}
+		}
+		return
+	}
+	switch {
+	case sch.Type.Contains("object") || len(sch.Properties) > 0:
+		obj, ok := value.(map[string]any)
+		if !ok {
+			v.typeViolation(path, "an object", value)
+			return
+		}
+		if preserveUnknown, _ := sch.Extensions[extensionPreserveUnknownFields].(bool); preserveUnknown && len(sch.Properties) == 0 {
+			return
+		}
+		embedded, _ := sch.Extensions[extensionEmbeddedResource].(bool)
+		v.validateObject(obj, sch, path, embedded)
+	case sch.Type.Contains("array"):
+		items, ok := value.([]any)
+		if !ok {
+			v.typeViolation(path, "an array", value)
+			return
+		}
+		if sch.Items == nil || sch.Items.Schema == nil {
+			return
+		}
+		for i, item := range items {
+			v.validate(item, sch.Items.Schema, fmt.Sprintf("%s[%d]", path, i))
+		}
+	case sch.Type.Contains("string"):
+		if _, ok := value.(string); !ok {
+			v.typeViolation(path, "a string", value)
+		}
+	case sch.Type.Contains("integer"):
+		if !isInteger(value) {
+			v.typeViolation(path, "an integer", value)
+		}
+	case sch.Type.Contains("number"):
+		if !isInteger(value) {
+			if _, ok := value.(float64); !ok {
+				v.typeViolation(path, "a number", value)
+			}
+		}
+	case sch.Type.Contains("boolean"):
+		if _, ok := value.(bool); !ok {
+			v.typeViolation(path, "a boolean", value)
+		}
+	}
+}
+
+func (v *schemaValidator) typeViolation(path, expected string, value any) {
+	if v.checkTypes {
+		v.add(path, "expected %s, got %s", expected, jsonType(value))
+	}
+}
+
+func isInteger(value any) bool {
+	switch n := value.(type) {
+	case int, int32, int64:
+		return true
+	case float64:
+		return n == float64(int64(n))
+	}
+	return false
+}
+
+// jsonType returns the JSON type of a value of an unstructured object
+func jsonType(value any) string {
+	switch value.(type) {
+	case map[string]any:
+		return "an object"
+	case []any:
+		return "an array"
+	case string:
+		return "a string"
+	case bool:
+		return "a boolean"
+	case int, int32, int64, float64:
+		return "a number"
+	}
+	return fmt.Sprintf("%T", value)
+}
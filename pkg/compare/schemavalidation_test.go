@@ -0,0 +1,116 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/openapi/openapitest"
+	"k8s.io/client-go/openapi3"
+)
+
+const widgetCRD = `apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: widgets.example.com
+spec:
+  group: example.com
+  names:
+    kind: Widget
+    plural: widgets
+  scope: Namespaced
+  versions:
+    - name: v1
+      served: true
+      storage: true
+      schema:
+        openAPIV3Schema:
+          type: object
+          properties:
+            spec:
+              type: object
+              properties:
+                size:
+                  x-kubernetes-int-or-string: true
+                options:
+                  type: object
+                  x-kubernetes-preserve-unknown-fields: true
+                template:
+                  type: object
+                  x-kubernetes-embedded-resource: true
+                  properties:
+                    data:
+                      type: object
+                      additionalProperties:
+                        type: string
+`
+
+func TestSchemaViolations(t *testing.T) {
+	root := openapi3.NewRoot(openapitest.NewEmbeddedFileClient())
+	doc, err := root.GVSpec(schema.GroupVersion{Group: "apps", Version: "v1"})
+	require.NoError(t, err)
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "widgets.yaml"), []byte(widgetCRD), 0o600))
+	s, err := loadSchemaBundle(dir)
+	require.NoError(t, err)
+	s.addOpenAPI(doc)
+
+	deployment := &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata":   map[string]any{"name": "app", "labels": map[string]any{"app": "app"}},
+		"spec": map[string]any{
+			"replicas": "2",
+			"paused":   nil,
+			"strategy": map[string]any{"type": "Recreate", "maxSurge": 1},
+			"template": map[string]any{"spec": map[string]any{"containers": []any{
+				map[string]any{"name": "app", "ports": []any{map[string]any{"containerPort": int64(8080), "protocol": "TCP"}}},
+			}}},
+		},
+	}}
+	require.Equal(t, []string{
+		"schema violation: spec.replicas: expected an integer, got a string",
+		"schema violation: spec.strategy.maxSurge: field not declared in the schema",
+	}, s.violations(deployment, true))
+	require.Equal(t, []string{
+		"schema violation: spec.strategy.maxSurge: field not declared in the schema",
+	}, s.violations(deployment, false), "types shouldn't be checked")
+
+	widget := &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "example.com/v1",
+		"kind":       "Widget",
+		"metadata":   map[string]any{"name": "widget"},
+		"spec": map[string]any{
+			"size":     "large",
+			"options":  map[string]any{"anything": true},
+			"template": map[string]any{"apiVersion": "v1", "kind": "ConfigMap", "data": map[string]any{"key": 1}, "immutable": true},
+		},
+	}}
+	require.Equal(t, []string{
+		"schema violation: spec.template.data.key: expected a string, got a number",
+		"schema violation: spec.template.immutable: field not declared in the schema",
+	}, s.violations(widget, true))
+
+	unknown := &unstructured.Unstructured{Object: map[string]any{"apiVersion": "example.com/v2", "kind": "Widget", "unknown": true}}
+	require.Empty(t, s.violations(unknown, true), "kinds without a schema shouldn't be validated")
+	require.Empty(t, (*schemaSet)(nil).violations(widget, true))
+}
+
+func TestLoadSchemaBundle(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("# Schemas"), 0o600))
+	_, err := loadSchemaBundle(dir)
+	require.ErrorContains(t, err, "holds no CRD nor OpenAPI v3 document declaring kinds")
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "cm.yaml"), []byte("apiVersion: v1\nkind: ConfigMap\n"), 0o600))
+	_, err = loadSchemaBundle(dir)
+	require.ErrorContains(t, err, "the file holds a ConfigMap, schema bundles hold CRDs and OpenAPI v3 documents")
+
+	_, err = loadSchemaBundle(filepath.Join(dir, "missing"))
+	require.ErrorContains(t, err, "failed to load the schema bundle")
+}
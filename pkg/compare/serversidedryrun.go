@@ -0,0 +1,109 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"fmt"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/cli-runtime/pkg/resource"
+	"k8s.io/klog/v2"
+	kcmdutil "k8s.io/kubectl/pkg/cmd/util"
+)
+
+// serverSideDryRunFieldManager is the field manager of the dry run requests normalizing the injected templates
+const serverSideDryRunFieldManager = "kube-compare"
+
+// serverGeneratedMetadata are the metadata fields set by the API server when creating an object, they're removed from
+// the normalized templates unless the template sets them
+var serverGeneratedMetadata = []string{"uid", "resourceVersion", "generation", "creationTimestamp", "generateName", "managedFields"}
+
+// serverSideNormalizer normalizes the injected templates with a server-side dry run create, so the defaulting and the
+// mutating admission of the API server are applied to them the same way they were applied to the cluster CRs.
+// The template is created under a generated name as the cluster CR it's compared to already exists, nothing is
+// persisted.
+type serverSideNormalizer struct {
+	f kcmdutil.Factory
+	// warned holds the templates that couldn't be normalized, the failure is only reported once per template
+	warned sync.Map
+}
+
+func newServerSideNormalizer(f kcmdutil.Factory) *serverSideNormalizer {
+	return &serverSideNormalizer{f: f}
+}
+
+// normalize returns the injected template as the API server would store it, or the template unchanged if the API
+// server rejected it (e.g. a template that only holds some of the required fields of the CR)
+func (n *serverSideNormalizer) normalize(temp ReferenceTemplate, obj *unstructured.Unstructured) *unstructured.Unstructured {
+	normalized, err := n.dryRunCreate(obj)
+	if err != nil {
+		if _, warned := n.warned.LoadOrStore(temp.GetPath(), true); !warned {
+			klog.Warningf("Template %s is compared without server-side dry run normalization: %s", temp.GetPath(), err)
+		}
+		return obj
+	}
+	return normalized
+}
+
+func (n *serverSideNormalizer) dryRunCreate(obj *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	mapper, err := n.f.ToRESTMapper()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create rest mapper: %w", err)
+	}
+	gvk := obj.GroupVersionKind()
+	mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to map %s to a resource: %w", gvk, err)
+	}
+	client, err := n.f.UnstructuredClientForMapping(mapping)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create client for %s: %w", gvk, err)
+	}
+
+	req := obj.DeepCopy()
+	req.SetGenerateName(generateNamePrefix(obj.GetName()))
+	req.SetName("")
+	created, err := resource.NewHelper(client, mapping).
+		DryRun(true).
+		WithFieldManager(serverSideDryRunFieldManager).
+		Create(obj.GetNamespace(), false, req)
+	if err != nil {
+		return nil, fmt.Errorf("server-side dry run failed: %w", err)
+	}
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(created)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert the result of the server-side dry run: %w", err)
+	}
+	normalized := &unstructured.Unstructured{Object: content}
+	restoreIdentity(normalized, obj)
+	return normalized, nil
+}
+
+// generateNamePrefix is the prefix of the name generated for the dry run, names longer than the prefix the API server
+// accepts are truncated by it
+func generateNamePrefix(name string) string {
+	if name == "" {
+		return "kube-compare-"
+	}
+	return name + "-"
+}
+
+// restoreIdentity restores the name, status and the metadata generated by the API server of the normalized template to
+// their value in the template
+func restoreIdentity(normalized, obj *unstructured.Unstructured) {
+	normalized.SetName(obj.GetName())
+	for _, field := range serverGeneratedMetadata {
+		if v, ok, _ := unstructured.NestedFieldNoCopy(obj.Object, "metadata", field); ok {
+			_ = unstructured.SetNestedField(normalized.Object, v, "metadata", field)
+		} else {
+			unstructured.RemoveNestedField(normalized.Object, "metadata", field)
+		}
+	}
+	if status, ok := obj.Object["status"]; ok {
+		normalized.Object["status"] = status
+	} else {
+		delete(normalized.Object, "status")
+	}
+}
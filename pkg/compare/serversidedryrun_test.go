@@ -0,0 +1,53 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestRestoreIdentity(t *testing.T) {
+	template := &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata": map[string]any{
+			"name":       "settings",
+			"namespace":  "example",
+			"generation": int64(3),
+		},
+		"data": map[string]any{"mode": "strict"},
+	}}
+	normalized := &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata": map[string]any{
+			"name":              "settings-x7k2p",
+			"generateName":      "settings-",
+			"namespace":         "example",
+			"uid":               "1234",
+			"resourceVersion":   "1",
+			"generation":        int64(1),
+			"creationTimestamp": "2024-01-01T00:00:00Z",
+			"managedFields":     []any{map[string]any{"manager": serverSideDryRunFieldManager}},
+			"labels":            map[string]any{"injected": "by-webhook"},
+		},
+		"data":   map[string]any{"mode": "strict"},
+		"status": map[string]any{},
+	}}
+
+	restoreIdentity(normalized, template)
+	require.Equal(t, map[string]any{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata": map[string]any{
+			"name":       "settings",
+			"namespace":  "example",
+			"generation": int64(3),
+			"labels":     map[string]any{"injected": "by-webhook"},
+		},
+		"data": map[string]any{"mode": "strict"},
+	}, normalized.Object, "the mutations of the API server should be kept but not the metadata it generates")
+}
@@ -0,0 +1,152 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/openshift/kube-compare/pkg/api"
+)
+
+// Severities are the severities of the diffs, from the most to the least severe
+var Severities = []api.Severity{api.SeverityCritical, api.SeverityWarning, api.SeverityInfo}
+
+// defaultSeverity is the severity of the differences of the fields without a severity
+const defaultSeverity = api.SeverityWarning
+
+// severityNames lists the severities for the messages of the flags and of the errors
+func severityNames() string {
+	names := make([]string, len(Severities))
+	for i, s := range Severities {
+		names[i] = string(s)
+	}
+	return strings.Join(names, ", ")
+}
+
+// FieldSeverity sets the severity of the differences of the field and of its subfields, e.g. critical for any change
+// under spec.securityContext or info for the labels
+type FieldSeverity struct {
+	PathToKey string       `json:"pathToKey"`
+	Severity  api.Severity `json:"severity"`
+	parts     []string
+}
+
+// validateFieldSeverities parses the paths of the fields with a severity
+func validateFieldSeverities(severities []FieldSeverity) error {
+	var errs []error
+	for i := range severities {
+		s := &severities[i]
+		parts, err := pathToList(s.PathToKey)
+		switch {
+		case err != nil:
+			errs = append(errs, fmt.Errorf("fieldSeverities[%d]: %w", i, err))
+		case s.PathToKey == "" || slices.Contains(parts, ""):
+			errs = append(errs, fmt.Errorf("fieldSeverities[%d]: path %q contains an empty key", i, s.PathToKey))
+		case !slices.Contains(Severities, s.Severity):
+			errs = append(errs, fmt.Errorf("fieldSeverities[%d]: invalid severity %q, must be one of: %s", i, s.Severity,
+				severityNames()))
+		default:
+			s.parts = parts
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// severityRank orders the severities, the most severe has the lowest rank
+func severityRank(severity api.Severity) int {
+	return slices.Index(Severities, severity)
+}
+
+// fieldSeverities classify the differences of a cluster CR with its template: the differences of a field and of its
+// subfields have the severity of the most specific field with a severity, the differences of other fields are
+// warnings. The severity of the diff of a CR is the highest severity of its differences.
+type fieldSeverities []FieldSeverity
+
+// severity returns the severity of the differences of the field
+func (s fieldSeverities) severity(field []string) api.Severity {
+	severity, depth := defaultSeverity, 0
+	for _, fs := range s {
+		if len(fs.parts) >= depth && len(fs.parts) <= len(field) && slices.Equal(fs.parts, field[:len(fs.parts)]) {
+			severity, depth = fs.Severity, len(fs.parts)
+		}
+	}
+	return severity
+}
+
+// highest returns the highest severity of the differing fields of the merge patch between the template and the CR,
+// an empty string if the patch has no differing field. The items of lists have the severity of the list.
+func (s fieldSeverities) highest(patch any, field []string) api.Severity {
+	switch t := patch.(type) {
+	case map[string]any:
+		var highest api.Severity
+		for key, v := range t {
+			highest = mostSevere(highest, s.highest(v, append(slices.Clip(field), key)))
+		}
+		return highest
+	case []any:
+		var highest api.Severity
+		for _, v := range t {
+			highest = mostSevere(highest, s.highest(v, field))
+		}
+		return highest
+	}
+	return s.severity(field)
+}
+
+func mostSevere(a, b api.Severity) api.Severity {
+	if a == "" || (b != "" && severityRank(b) < severityRank(a)) {
+		return b
+	}
+	return a
+}
+
+// diffSeverity returns the severity of the differences of the merge patch, an empty string when the reference doesn't
+// set the severity of any field
+func diffSeverity(uo *UserOverride, severities fieldSeverities) (api.Severity, error) {
+	if len(severities) == 0 {
+		return "", nil
+	}
+	var data map[string]any
+	if err := json.Unmarshal([]byte(uo.Patch), &data); err != nil {
+		return "", fmt.Errorf("failed to unmarshal internal diff: %w", err)
+	}
+	return severities.highest(data, nil), nil
+}
+
+// DiffSeveritiesSummary counts the CRs with diffs by the severity of their diffs
+type DiffSeveritiesSummary struct {
+	Critical int `json:"Critical"`
+	Warning  int `json:"Warning"`
+	Info     int `json:"Info"`
+}
+
+// add counts the diff of a CR, it isn't safe for concurrent use
+func (s *DiffSeveritiesSummary) add(d DiffSum) {
+	if s == nil || !d.HasDiff() {
+		return
+	}
+	switch d.Severity {
+	case api.SeverityCritical:
+		s.Critical++
+	case api.SeverityInfo:
+		s.Info++
+	default:
+		s.Warning++
+	}
+}
+
+// atLeast returns the number of CRs with diffs of the severity or of a higher one
+func (s *DiffSeveritiesSummary) atLeast(severity api.Severity) int {
+	count := s.Critical
+	if severityRank(severity) >= severityRank(api.SeverityWarning) {
+		count += s.Warning
+	}
+	if severityRank(severity) >= severityRank(api.SeverityInfo) {
+		count += s.Info
+	}
+	return count
+}
@@ -0,0 +1,49 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"testing"
+
+	"github.com/openshift/kube-compare/pkg/api"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFieldSeverities(t *testing.T) {
+	severities := fieldSeverities{
+		{PathToKey: "spec", Severity: api.SeverityInfo},
+		{PathToKey: "spec.securityContext", Severity: api.SeverityCritical},
+		{PathToKey: "metadata.labels", Severity: api.SeverityInfo},
+	}
+	require.NoError(t, validateFieldSeverities(severities))
+	require.ErrorContains(t, validateFieldSeverities([]FieldSeverity{{PathToKey: "spec", Severity: "high"}}), `invalid severity "high"`)
+	require.ErrorContains(t, validateFieldSeverities([]FieldSeverity{{PathToKey: "spec..mode", Severity: api.SeverityInfo}}), "empty key")
+
+	require.Equal(t, api.SeverityWarning, severities.severity([]string{"data", "mode"}))
+	require.Equal(t, api.SeverityInfo, severities.severity([]string{"spec", "replicas"}))
+	require.Equal(t, api.SeverityCritical, severities.severity([]string{"spec", "securityContext", "runAsUser"}),
+		"the most specific field should set the severity")
+
+	labels := map[string]any{"metadata": map[string]any{"labels": map[string]any{"app": nil}}}
+	require.Equal(t, api.SeverityInfo, severities.highest(labels, nil))
+	patch := map[string]any{
+		"metadata": map[string]any{"labels": map[string]any{"app": nil}},
+		"spec": map[string]any{
+			"replicas":        int64(3),
+			"securityContext": map[string]any{"capabilities": []any{"NET_ADMIN"}},
+		},
+	}
+	require.Equal(t, api.SeverityCritical, severities.highest(patch, nil))
+	require.Equal(t, api.SeverityWarning, severities.highest(map[string]any{"data": map[string]any{"mode": "ha"}}, nil))
+	require.Empty(t, severities.highest(map[string]any{}, nil))
+
+	summary := &DiffSeveritiesSummary{}
+	for _, severity := range []api.Severity{api.SeverityCritical, api.SeverityInfo, api.SeverityInfo, ""} {
+		summary.add(DiffSum{DiffOutput: "diff", Severity: severity})
+	}
+	summary.add(DiffSum{Severity: api.SeverityCritical})
+	require.Equal(t, &DiffSeveritiesSummary{Critical: 1, Warning: 1, Info: 2}, summary)
+	require.Equal(t, 1, summary.atLeast(api.SeverityCritical))
+	require.Equal(t, 2, summary.atLeast(api.SeverityWarning))
+	require.Equal(t, 4, summary.atLeast(api.SeverityInfo))
+}
@@ -0,0 +1,156 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"k8s.io/kubectl/pkg/cmd/diff"
+	"k8s.io/utils/exec"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	DiffStyleUnified    = "unified"
+	DiffStyleSideBySide = "side-by-side"
+)
+
+var DiffStyles = []string{DiffStyleUnified, DiffStyleSideBySide}
+
+// sideBySideMaxColumn is the maximum width of the left column, longer lines overflow it and push their right side
+const sideBySideMaxColumn = 80
+
+// sideBySideRow is a line of the side-by-side diff. The change is the first character of the line so the rows are
+// colored and counted like the lines of a unified diff: - for lines only in the merged version, + for lines only in
+// the live version and ~ for changed lines.
+type sideBySideRow struct {
+	change string
+	left   string
+	right  string
+	// skipped is the number of unchanged lines a collapsed row stands for, see collapseUnchangedRows
+	skipped int
+}
+
+// runSideBySideDiffer renders the versions of the object as YAML in two aligned columns, like diff -y, without an
+// external diff program. Like with diff, the returned exit error has code 1 if differences were found.
+func runSideBySideDiffer(obj diff.Object, from, to string, o *Options) (*bytes.Buffer, exec.ExitError, error) {
+	diffOutput := new(bytes.Buffer)
+	fromContent, toContent, err := diffContents(obj, from, to, o)
+	if err != nil {
+		return diffOutput, nil, err
+	}
+	fromYAML, err := yaml.Marshal(fromContent)
+	if err != nil {
+		return diffOutput, nil, fmt.Errorf("error occurered during diff: %w", err)
+	}
+	toYAML, err := yaml.Marshal(toContent)
+	if err != nil {
+		return diffOutput, nil, fmt.Errorf("error occurered during diff: %w", err)
+	}
+
+	rows := alignLines(string(fromYAML), string(toYAML))
+	changed := 0
+	for _, row := range rows {
+		if row.change != "" {
+			changed++
+		}
+	}
+	if changed == 0 {
+		return diffOutput, nil, nil
+	}
+	if o.diffContext != defaultDiffContext {
+		rows = collapseUnchangedRows(rows, o.diffContext)
+	}
+	fmt.Fprintf(diffOutput, "--- %s/%s\n+++ %s/%s\n", from, obj.Name(), to, obj.Name())
+	diffOutput.WriteString(renderSideBySide(rows))
+	return diffOutput, exec.CodeExitError{Err: fmt.Errorf("%d lines differ", changed), Code: 1}, nil
+}
+
+// alignLines aligns the lines of the two texts: equal lines side by side, and the lines of a block removed from the
+// first text paired with the lines of the block added in its place in the second text
+func alignLines(from, to string) []sideBySideRow {
+	var rows []sideBySideRow
+	var removed, added []string
+	flush := func() {
+		for i := 0; i < max(len(removed), len(added)); i++ {
+			switch {
+			case i >= len(removed):
+				rows = append(rows, sideBySideRow{change: "+", right: added[i]})
+			case i >= len(added):
+				rows = append(rows, sideBySideRow{change: "-", left: removed[i]})
+			default:
+				rows = append(rows, sideBySideRow{change: "~", left: removed[i], right: added[i]})
+			}
+		}
+		removed, added = nil, nil
+	}
+	for _, e := range lineEdits(from, to) {
+		line := strings.TrimSuffix(e.text, "\n")
+		switch e.op {
+		case '-':
+			removed = append(removed, line)
+		case '+':
+			added = append(added, line)
+		default:
+			flush()
+			rows = append(rows, sideBySideRow{left: line, right: line})
+		}
+	}
+	flush()
+	return rows
+}
+
+// collapseUnchangedRows keeps the unchanged rows that are at most context rows away from a changed row, the other
+// unchanged rows are replaced by a row counting them
+func collapseUnchangedRows(rows []sideBySideRow, context int) []sideBySideRow {
+	keep := make([]bool, len(rows))
+	for i, row := range rows {
+		if row.change == "" {
+			continue
+		}
+		for j := max(0, i-context); j <= min(len(rows)-1, i+context); j++ {
+			keep[j] = true
+		}
+	}
+	var collapsed []sideBySideRow
+	skipped := 0
+	flush := func() {
+		if skipped > 0 {
+			collapsed = append(collapsed, sideBySideRow{skipped: skipped})
+			skipped = 0
+		}
+	}
+	for i, row := range rows {
+		if !keep[i] {
+			skipped++
+			continue
+		}
+		flush()
+		collapsed = append(collapsed, row)
+	}
+	flush()
+	return collapsed
+}
+
+func renderSideBySide(rows []sideBySideRow) string {
+	width := 0
+	for _, row := range rows {
+		width = max(width, min(len(row.left), sideBySideMaxColumn))
+	}
+	var sb strings.Builder
+	for _, row := range rows {
+		if row.skipped > 0 {
+			fmt.Fprintf(&sb, "@@ %d unchanged lines @@\n", row.skipped)
+			continue
+		}
+		change := row.change
+		if change == "" {
+			change = " "
+		}
+		line := fmt.Sprintf("%s %-*s | %s", change, width, row.left, row.right)
+		sb.WriteString(strings.TrimRight(line, " ") + "\n")
+	}
+	return sb.String()
+}
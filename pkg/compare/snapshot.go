@@ -0,0 +1,147 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/gosimple/slug"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/yaml"
+)
+
+const snapshotFileExtension = ".yaml"
+
+// Snapshot contains the normalized cluster CRs that were recorded by a previous run (with --snapshot-dir),
+// indexed by their apiVersion_kind_namespace_name.
+type Snapshot struct {
+	Dir     string
+	objects map[string]*unstructured.Unstructured
+
+	// seenLock guards seen, Diff is called concurrently by the resource visitor
+	seenLock sync.Mutex
+	seen     map[string]bool
+}
+
+// SnapshotSummary contains the changes in the cluster CRs since the snapshot was recorded
+type SnapshotSummary struct {
+	Dir        string   `json:"Dir"`
+	NumChanged int      `json:"NumChanged"`
+	NewCRs     []string `json:"NewCRs,omitempty"`
+	RemovedCRs []string `json:"RemovedCRs,omitempty"`
+}
+
+// LoadSnapshot reads all the CRs recorded in a snapshot directory
+func LoadSnapshot(dir string) (*Snapshot, error) {
+	s := &Snapshot{Dir: dir, objects: make(map[string]*unstructured.Unstructured), seen: make(map[string]bool)}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot directory: %w", err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != snapshotFileExtension {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read snapshot file: %w", err)
+		}
+		data := make(map[string]any)
+		if err := yaml.Unmarshal(content, &data); err != nil {
+			return nil, fmt.Errorf("snapshot file %s isn't in correct format: %w", entry.Name(), err)
+		}
+		obj := &unstructured.Unstructured{Object: data}
+		s.objects[apiKindNamespaceName(obj)] = obj
+	}
+	return s, nil
+}
+
+// prepareSnapshotDir creates the directory a snapshot will be written to, the directory is required to be empty
+// so the snapshot will contain only the CRs of a single run.
+func prepareSnapshotDir(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err == nil && len(entries) > 0 {
+		return fmt.Errorf("snapshot directory %s isn't empty", dir)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil { // nolint:gosec
+		return fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+	return nil
+}
+
+// writeSnapshotObject records a normalized cluster CR in the snapshot directory
+func writeSnapshotObject(dir string, obj *unstructured.Unstructured) error {
+	content, err := yaml.Marshal(obj.Object)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s for the snapshot: %w", apiKindNamespaceName(obj), err)
+	}
+	fileName := filepath.Join(dir, slug.Make(apiKindNamespaceName(obj))+snapshotFileExtension)
+	// Cluster CRs can hold secrets, the snapshot is only readable by its owner
+	if err := os.WriteFile(fileName, content, 0o600); err != nil {
+		return fmt.Errorf("failed to write snapshot file: %w", err)
+	}
+	return nil
+}
+
+// Diff returns the diff between the snapshot version of the cluster CR and its current version. In case the CR
+// doesn't appear in the snapshot an empty diff is returned and the CR will be reported as new.
+func (s *Snapshot) Diff(ctx context.Context, clusterCR *unstructured.Unstructured, o *Options) (string, error) {
+	name := apiKindNamespaceName(clusterCR)
+	s.seenLock.Lock()
+	s.seen[name] = true
+	s.seenLock.Unlock()
+	recorded, ok := s.objects[name]
+	if !ok {
+		return "", nil
+	}
+	output, _, err := runDiffer(ctx, snapshotObject{snapshot: recorded, live: clusterCR}, "MERGED", "LIVE", o)
+	if err != nil {
+		return "", err
+	}
+	return output.String(), nil
+}
+
+// Summarize returns the changes since the snapshot given the number of CRs of the current run that changed since it
+func (s *Snapshot) Summarize(numChanged int) *SnapshotSummary {
+	sum := &SnapshotSummary{Dir: s.Dir, NumChanged: numChanged}
+	s.seenLock.Lock()
+	defer s.seenLock.Unlock()
+	for name := range s.seen {
+		if _, ok := s.objects[name]; !ok {
+			sum.NewCRs = append(sum.NewCRs, name)
+		}
+	}
+	for name := range s.objects {
+		if !s.seen[name] {
+			sum.RemovedCRs = append(sum.RemovedCRs, name)
+		}
+	}
+	sort.Strings(sum.NewCRs)
+	sort.Strings(sum.RemovedCRs)
+	return sum
+}
+
+// snapshotObject matches the diff.Object interface, it contains the snapshot and current version of a cluster CR.
+// The snapshot version is reported as the merged object so it will be the "from" side of the diff.
+type snapshotObject struct {
+	snapshot *unstructured.Unstructured
+	live     *unstructured.Unstructured
+}
+
+func (obj snapshotObject) Live() runtime.Object {
+	return obj.live
+}
+
+func (obj snapshotObject) Merged() (runtime.Object, error) {
+	return obj.snapshot, nil
+}
+
+func (obj snapshotObject) Name() string {
+	return slug.Make(apiKindNamespaceName(obj.live))
+}
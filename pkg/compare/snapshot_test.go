@@ -0,0 +1,72 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "snapshot")
+	require.NoError(t, prepareSnapshotDir(dir))
+
+	objs := []*unstructured.Unstructured{
+		{Object: map[string]any{"apiVersion": "v1", "kind": "Namespace", "metadata": map[string]any{"name": "ns"}}},
+		{Object: map[string]any{"apiVersion": "v1", "kind": "ConfigMap", "metadata": map[string]any{"name": "cm", "namespace": "ns"},
+			"data": map[string]any{"key": "value"}}},
+	}
+	for _, obj := range objs {
+		require.NoError(t, writeSnapshotObject(dir, obj))
+	}
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	for _, entry := range entries {
+		info, err := entry.Info()
+		require.NoError(t, err)
+		require.Equal(t, os.FileMode(0o600), info.Mode().Perm(), "the snapshot of cluster CRs should only be readable by its owner")
+	}
+	require.Error(t, prepareSnapshotDir(dir), "a snapshot shouldn't be written to a directory that isn't empty")
+
+	s, err := LoadSnapshot(dir)
+	require.NoError(t, err)
+	require.Len(t, s.objects, len(objs))
+	for _, obj := range objs {
+		require.Equal(t, obj, s.objects[apiKindNamespaceName(obj)])
+	}
+}
+
+func TestSnapshotDiffConcurrent(t *testing.T) {
+	configMap := func(name, value string) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]any{"apiVersion": "v1", "kind": "ConfigMap",
+			"metadata": map[string]any{"name": name, "namespace": "ns"}, "data": map[string]any{"key": value}}}
+	}
+	s := &Snapshot{objects: make(map[string]*unstructured.Unstructured), seen: make(map[string]bool)}
+	for i := 0; i < 10; i++ {
+		obj := configMap(fmt.Sprintf("cm-%d", i), "value")
+		s.objects[apiKindNamespaceName(obj)] = obj
+	}
+	o := &Options{diffEngine: DiffEngineInternal}
+
+	var wg sync.WaitGroup
+	for i := 5; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := s.Diff(context.Background(), configMap(fmt.Sprintf("cm-%d", i), "changed"), o)
+			require.NoError(t, err)
+		}(i)
+	}
+	wg.Wait()
+
+	sum := s.Summarize(0)
+	require.Len(t, sum.NewCRs, 10)
+	require.Len(t, sum.RemovedCRs, 5)
+}
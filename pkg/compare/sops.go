@@ -0,0 +1,243 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+
+	"k8s.io/cli-runtime/pkg/resource"
+	"k8s.io/utils/exec"
+	"sigs.k8s.io/yaml"
+)
+
+// sopsBinary is run to decrypt files encrypted with SOPS. It finds the keys (age, PGP, cloud KMS...) the same way as
+// when it's run directly, e.g. from SOPS_AGE_KEY_FILE or the cloud provider credentials.
+var sopsBinary = "sops"
+
+// isSopsEncrypted checks if the content is a YAML or JSON file encrypted with SOPS, such files hold the metadata
+// needed for decrypting them under a top-level sops key. Only the first document of multi-document files is checked.
+func isSopsEncrypted(content []byte) bool {
+	if !bytes.Contains(content, []byte("sops")) {
+		return false
+	}
+	var doc struct {
+		Sops *struct {
+			Mac string `json:"mac"`
+		} `json:"sops"`
+	}
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return false
+	}
+	return doc.Sops != nil && doc.Sops.Mac != ""
+}
+
+// decryptSops decrypts the content of a file encrypted with SOPS, the format of the file is deduced from its name. The
+// content is passed to sops in a temporary file readable only by the user, as /dev/stdin isn't available on all
+// platforms.
+func decryptSops(name string, content []byte) ([]byte, error) {
+	format := "yaml"
+	if strings.EqualFold(filepath.Ext(name), ".json") {
+		format = "json"
+	}
+	encrypted, err := os.CreateTemp("", "kube-compare-sops-*."+format)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create a temporary file to decrypt %s: %w", name, err)
+	}
+	defer os.Remove(encrypted.Name())
+	_, err = encrypted.Write(content)
+	if err = errors.Join(err, encrypted.Close()); err != nil {
+		return nil, fmt.Errorf("failed to write a temporary file to decrypt %s: %w", name, err)
+	}
+
+	cmd := exec.New().Command(sopsBinary, "--decrypt", "--input-type", format, "--output-type", format, encrypted.Name())
+	stderr := new(bytes.Buffer)
+	cmd.SetStderr(stderr)
+	decrypted, err := cmd.Output()
+	if errors.Is(err, exec.ErrExecutableNotFound) {
+		return nil, fmt.Errorf("%s is encrypted with SOPS, %s must be installed to decrypt it: %w", name, sopsBinary, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt %s with %s: %w: %s", name, sopsBinary, err, strings.TrimSpace(stderr.String()))
+	}
+	return decrypted, nil
+}
+
+// sopsFS wraps the file system of a reference and decrypts the files encrypted with SOPS when they're opened
+type sopsFS struct {
+	fs.FS
+	decrypted *atomic.Bool
+}
+
+func newSopsFS(fsys fs.FS) sopsFS {
+	return sopsFS{FS: fsys, decrypted: &atomic.Bool{}}
+}
+
+func (s sopsFS) Open(name string) (fs.File, error) {
+	f, err := s.FS.Open(name)
+	if err != nil {
+		return nil, err // nolint:wrapcheck
+	}
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		return f, err // nolint:wrapcheck
+	}
+	content, err := io.ReadAll(f)
+	closeErr := f.Close()
+	if err = errors.Join(err, closeErr); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	if !isSopsEncrypted(content) {
+		return lockedFile{Reader: bytes.NewReader(content), info: info}, nil
+	}
+	decrypted, err := decryptSops(name, content)
+	if err != nil {
+		return nil, err
+	}
+	s.decrypted.Store(true)
+	return lockedFile{Reader: bytes.NewReader(decrypted), info: decryptedFileInfo{FileInfo: info, size: int64(len(decrypted))}}, nil
+}
+
+func (s sopsFS) Stat(name string) (fs.FileInfo, error) {
+	return fs.Stat(s.FS, name) // nolint:wrapcheck
+}
+
+// containsDecryptedFiles checks if any of the files opened so far was decrypted, their content shouldn't be written
+// to disk in clear text (e.g. to the template cache)
+func (s sopsFS) containsDecryptedFiles() bool {
+	return s.decrypted.Load()
+}
+
+type decryptedFileInfo struct {
+	fs.FileInfo
+	size int64
+}
+
+func (i decryptedFileInfo) Size() int64 {
+	return i.size
+}
+
+// streamedCR is a local CR file passed to the builder as a stream of its content once preprocessed, e.g. decrypted
+// with SOPS or converted from an inventory export, or as a stream opened on demand, e.g. a CR file of an archive
+type streamedCR struct {
+	name    string
+	content []byte
+	open    func() (io.ReadCloser, error)
+}
+
+// reader returns a new stream of the CR file
+func (s streamedCR) reader() io.Reader {
+	if s.open != nil {
+		return &lazyReader{open: s.open}
+	}
+	return bytes.NewReader(s.content)
+}
+
+// decryptLocalCRs finds the local CR files encrypted with SOPS and returns their decrypted content together with the
+// rest of the files to pass to the builder. The file names are returned unchanged when no file is encrypted, so the
+// builder keeps reporting issues with them (e.g. missing files) as usual.
+func decryptLocalCRs(filenames []string, recursive bool) ([]string, []streamedCR, error) {
+	var plain []string
+	var decrypted []streamedCR
+	for _, f := range filenames {
+		if f == stdinFilename || isURL(f) {
+			plain = append(plain, f)
+			continue
+		}
+		paths, err := expandCRPaths(f, recursive)
+		if err != nil {
+			plain = append(plain, f)
+			continue
+		}
+		for _, p := range paths {
+			mentionsSops, err := fileContains(p, []byte("sops"))
+			if err != nil {
+				return nil, nil, err
+			}
+			var content []byte
+			if mentionsSops {
+				// Only files that may be encrypted are read fully, the builder reads the plain ones
+				if content, err = os.ReadFile(p); err != nil {
+					return nil, nil, fmt.Errorf("failed to read %s: %w", p, err)
+				}
+			}
+			if !mentionsSops || !isSopsEncrypted(content) {
+				plain = append(plain, p)
+				continue
+			}
+			content, err = decryptSops(p, content)
+			if err != nil {
+				return nil, nil, err
+			}
+			decrypted = append(decrypted, streamedCR{name: p, content: content})
+		}
+	}
+	if len(decrypted) == 0 {
+		return filenames, nil, nil
+	}
+	return plain, decrypted, nil
+}
+
+// fileContains checks if the file contains the token, the file is streamed so it's never held in memory as a whole
+func fileContains(name string, token []byte) (bool, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return false, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	defer f.Close()
+	buf := make([]byte, 32*1024)
+	// The end of the previous chunk is kept so a token spanning two chunks is found
+	keep := 0
+	for {
+		n, err := f.Read(buf[keep:])
+		read := keep + n
+		if bytes.Contains(buf[:read], token) {
+			return true, nil
+		}
+		if errors.Is(err, io.EOF) {
+			return false, nil
+		}
+		if err != nil {
+			return false, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		keep = min(read, len(token)-1)
+		copy(buf, buf[read-keep:read])
+	}
+}
+
+// expandCRPaths returns the files the builder would read for the path: the path itself if it's a file or the files
+// with a supported extension in the directory (and its sub directories if recursive)
+func expandCRPaths(root string, recursive bool) ([]string, error) {
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, err // nolint:wrapcheck
+	}
+	if !info.IsDir() {
+		return []string{root}, nil
+	}
+	var paths []string
+	err = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if p != root && !recursive {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if slices.Contains(resource.FileExtensions, filepath.Ext(p)) {
+			paths = append(paths, p)
+		}
+		return nil
+	})
+	return paths, err // nolint:wrapcheck
+}
@@ -0,0 +1,69 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"bytes"
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsSopsEncrypted(t *testing.T) {
+	tests := []struct {
+		name      string
+		content   string
+		encrypted bool
+	}{
+		{name: "yaml", content: "data: ENC[x]\nsops:\n  mac: ENC[y]\n", encrypted: true},
+		{name: "json", content: `{"data": "ENC[x]", "sops": {"mac": "ENC[y]"}}`, encrypted: true},
+		{name: "plain", content: "kind: ConfigMap\ndata:\n  key: sops\n"},
+		{name: "sops field without metadata", content: "sops: enabled\n"},
+		{name: "template", content: "name: {{ .sops }}\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require.Equal(t, test.encrypted, isSopsEncrypted([]byte(test.content)))
+		})
+	}
+}
+
+func TestFileContains(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "cm.yaml")
+	// The token spans two of the chunks the file is read in
+	content := append(bytes.Repeat([]byte("a"), 32*1024-2), []byte("sops: {}\n")...)
+	require.NoError(t, os.WriteFile(name, content, 0o600))
+	found, err := fileContains(name, []byte("sops"))
+	require.NoError(t, err)
+	require.True(t, found)
+	found, err = fileContains(name, []byte("mac"))
+	require.NoError(t, err)
+	require.False(t, found)
+}
+
+func TestDecryptLocalCRs(t *testing.T) {
+	origSopsBinary := sopsBinary
+	sopsBinary = path.Join(TestDirs, "SopsEncrypted", "sops")
+	t.Cleanup(func() {
+		sopsBinary = origSopsBinary
+	})
+
+	resources := path.Join(TestDirs, "SopsEncrypted", "resources")
+	plain, decrypted, err := decryptLocalCRs([]string{resources, "-"}, false)
+	require.NoError(t, err)
+	require.Equal(t, []string{filepath.Join(resources, "cmSettings.yaml"), "-"}, plain)
+	require.Len(t, decrypted, 1)
+	require.Equal(t, filepath.Join(resources, "secretToken.yaml"), decrypted[0].name)
+	require.NotContains(t, string(decrypted[0].content), "sops")
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "cm.yaml"), []byte("kind: ConfigMap\n"), 0o600))
+	filenames := []string{dir, "missing.yaml"}
+	plain, decrypted, err = decryptLocalCRs(filenames, false)
+	require.NoError(t, err)
+	require.Equal(t, filenames, plain, "file names should be unchanged when no file is encrypted")
+	require.Empty(t, decrypted)
+}
@@ -0,0 +1,90 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Jsonl writes a JSON object per line: a record for every compared CR as soon as it is compared, then the summary
+const Jsonl string = "jsonl"
+
+// StreamRecord is a line of the jsonl output, either the diff of a CR or the summary that ends the run
+type StreamRecord struct {
+	Diff    *DiffSum `json:"Diff,omitempty"`
+	Summary *Summary `json:"Summary,omitempty"`
+}
+
+// diffStreamer writes the diffs of the CRs while the run progresses instead of once all the CRs were compared, the
+// text output is the same as the buffered one except the diffs are in the order the CRs were compared and aren't
+// grouped by component
+type diffStreamer struct {
+	mu             sync.Mutex
+	out            io.Writer
+	format         string
+	showEmptyDiffs bool
+	color          bool
+	written        int
+	err            error
+}
+
+// write writes the diff of a CR, the first error is kept and returned by finish
+func (s *diffStreamer) write(d DiffSum) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return
+	}
+	if s.format == Jsonl {
+		s.err = s.writeRecord(StreamRecord{Diff: &d})
+		return
+	}
+	if !s.showEmptyDiffs && !d.HasDiff() && !d.WasPatched() && !d.ChangedSinceSnapshot() {
+		return
+	}
+	if s.color {
+		d.DiffOutput = colorizeDiff(d.DiffOutput)
+		d.SnapshotDiffOutput = colorizeDiff(d.SnapshotDiffOutput)
+	}
+	s.written++
+	if _, err := fmt.Fprintf(s.out, "%s\n%s\n\n", DiffSeparator, d.String()); err != nil {
+		s.err = fmt.Errorf("error occurred when writing output: %w", err)
+	}
+}
+
+// finish writes the summary of the run after the diffs
+func (s *diffStreamer) finish(sum *Summary) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	if s.format == Jsonl {
+		return s.writeRecord(StreamRecord{Summary: sum})
+	}
+	separator := ""
+	if s.written > 0 {
+		separator = DiffSeparator + "\n"
+	}
+	if _, err := fmt.Fprintf(s.out, "%s%s\n", separator, sum.String()); err != nil {
+		return fmt.Errorf("error occurred when writing output: %w", err)
+	}
+	return nil
+}
+
+func (s *diffStreamer) writeRecord(r StreamRecord) error {
+	content, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("failed to marshal output to json: %w", err)
+	}
+	if _, err := s.out.Write(append(content, '\n')); err != nil {
+		return fmt.Errorf("error occurred when writing output: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,264 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/template"
+	"text/template/parse"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/klog/v2"
+)
+
+// templateCacheVersion is part of the cache key, it has to be changed whenever the cached format or the way templates
+// are parsed and validated changes so that entries written by older versions of the tool won't be used.
+const templateCacheVersion = "v1"
+
+// templateCacheEntry contains the results of parsing and validating a single template
+type templateCacheEntry struct {
+	Path     string         `json:"path"`
+	Metadata map[string]any `json:"metadata"`
+	Content  string         `json:"content"`
+}
+
+type templateCacheFile struct {
+	Templates []templateCacheEntry `json:"templates"`
+}
+
+// referenceFile is the content of a file that is part of the reference
+type referenceFile struct {
+	name    string
+	content []byte
+}
+
+// lazyTemplate parses a template that was loaded from the cache the first time it's needed. It keeps the content
+// of the template and the template function files that was read while computing the reference digest so the
+// files won't be read again.
+type lazyTemplate struct {
+	once    sync.Once
+	files   []referenceFile
+	content string
+	tmpl    *template.Template
+	err     error
+}
+
+func (l *lazyTemplate) get() (*template.Template, error) {
+	l.once.Do(func() {
+		// Same as template.ParseFS, every file is added as a template named after the base of its path
+		l.tmpl = newTemplate(path.Base(l.files[0].name))
+		for _, f := range l.files {
+			t := l.tmpl
+			if name := path.Base(f.name); name != l.tmpl.Name() {
+				t = l.tmpl.New(name)
+			}
+			if _, err := t.Parse(string(f.content)); err != nil {
+				l.err = fmt.Errorf(templatesCantBeParsed, f.name, err)
+				return
+			}
+		}
+	})
+	return l.tmpl, l.err
+}
+
+// treeContent returns the parsed content of a template, it's used for hashing the reference
+func treeContent(tree *parse.Tree) string {
+	var sb strings.Builder
+	for _, node := range tree.Root.Nodes {
+		sb.WriteString(node.String())
+	}
+	return sb.String()
+}
+
+// DefaultTemplateCacheDir returns the directory under the user cache dir where parsed templates are cached
+func DefaultTemplateCacheDir() (string, error) {
+	dir, err := DefaultCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return templateCacheDir(dir), nil
+}
+
+// templateCacheDir returns the directory of the parsed templates in the cache directory
+func templateCacheDir(cacheDir string) string {
+	return filepath.Join(cacheDir, "templates")
+}
+
+// ParseTemplatesWithCache is the same as ParseTemplates, but in case the reference was already parsed and
+// validated successfully the results are loaded from cacheDir instead. Templates loaded from the cache are
+// only parsed once they are executed. A cache entry is keyed by the digest of all the files of the reference,
+// so any change to the reference results in a new entry.
+func ParseTemplatesWithCache(ref Reference, fsys fs.FS, referenceFileName, cacheDir string) ([]ReferenceTemplate, error) {
+	digest, files, err := referenceDigest(ref, fsys, referenceFileName)
+	if err != nil {
+		// Let the regular parsing report the issue
+		return ParseTemplates(ref, fsys)
+	}
+	cachePath := filepath.Join(cacheDir, digest+".json")
+	if templates, ok := loadCachedTemplates(ref, cachePath, files); ok {
+		return templates, nil
+	}
+
+	templates, err := ParseTemplates(ref, fsys)
+	if err != nil {
+		return templates, err
+	}
+	if d, ok := fsys.(interface{ containsDecryptedFiles() bool }); ok && d.containsDecryptedFiles() {
+		klog.Warning("The reference contains files encrypted with SOPS, its templates aren't cached")
+		return templates, nil
+	}
+	if err := writeTemplateCache(cachePath, templates); err != nil {
+		klog.Warningf("failed to cache the reference templates: %s", err)
+	}
+	return templates, nil
+}
+
+// referenceDigest reads the reference config, its templates and template function files and returns their digest
+// together with the files content needed for parsing each template
+func referenceDigest(ref Reference, fsys fs.FS, referenceFileName string) (string, map[string][]referenceFile, error) {
+	hash := sha256.New()
+	write := func(name string, content []byte) {
+		fmt.Fprintf(hash, "%s\x00%d\x00", name, len(content))
+		hash.Write(content)
+	}
+	read := func(name string) (referenceFile, error) {
+		content, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return referenceFile{}, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		write(name, content)
+		return referenceFile{name: name, content: content}, nil
+	}
+
+	write("version", []byte(templateCacheVersion))
+	if _, err := read(referenceFileName); err != nil {
+		return "", nil, err
+	}
+
+	var functionFiles []referenceFile
+	for _, pattern := range ref.GetTemplateFunctionFiles() {
+		names := []string{pattern}
+		if strings.ContainsAny(pattern, `*?[\`) {
+			var err error
+			names, err = fs.Glob(fsys, pattern)
+			if err != nil || len(names) == 0 {
+				return "", nil, fmt.Errorf("pattern %s matches no files", pattern)
+			}
+		}
+		for _, name := range names {
+			f, err := read(name)
+			if err != nil {
+				return "", nil, err
+			}
+			functionFiles = append(functionFiles, f)
+		}
+	}
+
+	files := make(map[string][]referenceFile)
+	for _, temp := range ref.GetTemplates() {
+		if _, ok := files[temp.GetPath()]; ok {
+			continue
+		}
+		f, err := read(temp.GetPath())
+		if err != nil {
+			return "", nil, err
+		}
+		files[temp.GetPath()] = append([]referenceFile{f}, functionFiles...)
+	}
+	return fmt.Sprintf("%x", hash.Sum(nil)), files, nil
+}
+
+// baseTemplates returns the templates of the reference in the order returned by ParseTemplates together with
+// the ReferenceTemplateV1 each of them is based on
+func baseTemplates(ref Reference) ([]ReferenceTemplate, []*ReferenceTemplateV1) {
+	var templates []ReferenceTemplate
+	var bases []*ReferenceTemplateV1
+	switch r := ref.(type) {
+	case *ReferenceV1:
+		for _, temp := range r.getTemplates() {
+			templates = append(templates, temp)
+			bases = append(bases, temp)
+		}
+	case *ReferenceV2:
+		for _, temp := range r.getTemplates() {
+			temp.ReferenceTemplateV1.Config = temp.Config.ReferenceTemplateConfigV1
+			templates = append(templates, temp)
+			bases = append(bases, &temp.ReferenceTemplateV1)
+		}
+	}
+	return templates, bases
+}
+
+func loadCachedTemplates(ref Reference, cachePath string, files map[string][]referenceFile) ([]ReferenceTemplate, bool) {
+	content, err := os.ReadFile(cachePath)
+	if err != nil {
+		return nil, false
+	}
+	var cached templateCacheFile
+	if err := json.Unmarshal(content, &cached); err != nil {
+		klog.Warningf("ignoring invalid template cache file %s: %s", cachePath, err)
+		return nil, false
+	}
+	templates, bases := baseTemplates(ref)
+	if len(bases) == 0 || len(bases) != len(cached.Templates) {
+		return nil, false
+	}
+	for i, base := range bases {
+		if cached.Templates[i].Path != base.Path {
+			return nil, false
+		}
+	}
+	for i, base := range bases {
+		base.metadata = &unstructured.Unstructured{Object: cached.Templates[i].Metadata}
+		base.lazy = &lazyTemplate{files: files[base.Path], content: cached.Templates[i].Content}
+	}
+	return templates, true
+}
+
+func writeTemplateCache(cachePath string, templates []ReferenceTemplate) error {
+	cached := templateCacheFile{}
+	for _, temp := range templates {
+		cached.Templates = append(cached.Templates, templateCacheEntry{
+			Path:     temp.GetPath(),
+			Metadata: temp.GetMetadata().Object,
+			Content:  treeContent(temp.GetTemplateTree()),
+		})
+	}
+	content, err := json.Marshal(cached)
+	if err != nil {
+		return fmt.Errorf("failed to marshal the template cache: %w", err)
+	}
+	return writeCacheFile(cachePath, content)
+}
+
+// writeCacheFile writes a file of the cache directory, creating the directory if needed
+func writeCacheFile(cachePath string, content []byte) error {
+	if err := os.MkdirAll(filepath.Dir(cachePath), 0o755); err != nil { // nolint:gosec
+		return fmt.Errorf("failed to create the cache directory: %w", err)
+	}
+	// Write to a temporary file first so concurrent runs won't read a partially written cache file
+	tmp, err := os.CreateTemp(filepath.Dir(cachePath), filepath.Base(cachePath)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create the cache file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write the cache file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write the cache file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), cachePath); err != nil {
+		return fmt.Errorf("failed to write the cache file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTemplatesWithCache(t *testing.T) {
+	cases := []struct {
+		name         string
+		expectCached bool
+	}{
+		{name: "Ref With Template Functions Renders As Expected", expectCached: true},
+		{name: "Reference V2 Inline Capturegroups", expectCached: true},
+		{name: "Reference Contains Templates That Dont Parse"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			test := defaultTest(c.name)
+			fsys := os.DirFS(path.Join(test.getTestDir(), TestRefDirName))
+			cacheDir := t.TempDir()
+			parse := func(withCache bool) (Reference, []ReferenceTemplate, error) {
+				ref, err := GetReference(fsys, defaultReferenceFilename)
+				require.NoError(t, err)
+				if !withCache {
+					templates, err := ParseTemplates(ref, fsys)
+					return ref, templates, err
+				}
+				templates, err := ParseTemplatesWithCache(ref, fsys, defaultReferenceFilename, cacheDir)
+				return ref, templates, err
+			}
+
+			ref, expected, expectedErr := parse(false)
+			_, _, err := parse(true)
+			require.Equal(t, expectedErr, err)
+			entries, err := os.ReadDir(cacheDir)
+			require.NoError(t, err)
+			if !c.expectCached {
+				require.Empty(t, entries, "references with errors shouldn't be cached")
+				return
+			}
+			require.Len(t, entries, 1)
+
+			cachedRef, cached, err := parse(true)
+			require.NoError(t, err)
+			require.Len(t, cached, len(expected))
+			_, bases := baseTemplates(cachedRef)
+			for i, base := range bases {
+				require.NotNil(t, base.lazy, "template should be loaded from the cache")
+				require.Nil(t, base.Template, "template loaded from the cache shouldn't be parsed before it's used")
+				require.Equal(t, expected[i].GetMetadata(), cached[i].GetMetadata())
+			}
+			require.Equal(t,
+				getMetadataHash(ref, expected),
+				getMetadataHash(cachedRef, cached),
+			)
+			for i := range cached {
+				expectedObj, err := expected[i].Exec(map[string]any{})
+				require.NoError(t, err)
+				cachedObj, err := cached[i].Exec(map[string]any{})
+				require.NoError(t, err)
+				require.Equal(t, expectedObj, cachedObj)
+			}
+		})
+	}
+}
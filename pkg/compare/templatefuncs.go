@@ -0,0 +1,173 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+	"time"
+)
+
+// The template functions below help references express the expected values of network and certificate fields instead
+// of omitting them. Templates are also rendered without values when the reference is loaded, so empty arguments are
+// tolerated: they yield zero values rather than errors, while malformed values fail the render.
+
+// parseAddrOrPrefix parses an IP address as the prefix of its own length, or a CIDR
+func parseAddrOrPrefix(s string) (netip.Prefix, error) {
+	if strings.Contains(s, "/") {
+		prefix, err := netip.ParsePrefix(strings.TrimSpace(s))
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("invalid CIDR: %w", err)
+		}
+		return prefix.Masked(), nil
+	}
+	addr, err := netip.ParseAddr(strings.TrimSpace(s))
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid IP address: %w", err)
+	}
+	addr = addr.Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// cidrContains returns whether the IP address or CIDR is within the CIDR
+func cidrContains(cidr, s string) (bool, error) {
+	if cidr == "" || s == "" {
+		return false, nil
+	}
+	outer, err := parseAddrOrPrefix(cidr)
+	if err != nil {
+		return false, err
+	}
+	inner, err := parseAddrOrPrefix(s)
+	if err != nil {
+		return false, err
+	}
+	return outer.Bits() <= inner.Bits() && outer.Contains(inner.Addr()), nil
+}
+
+// cidrOverlaps returns whether the two CIDRs share any address
+func cidrOverlaps(a, b string) (bool, error) {
+	if a == "" || b == "" {
+		return false, nil
+	}
+	first, err := parseAddrOrPrefix(a)
+	if err != nil {
+		return false, err
+	}
+	second, err := parseAddrOrPrefix(b)
+	if err != nil {
+		return false, err
+	}
+	return first.Overlaps(second), nil
+}
+
+// normalizeIP returns the canonical form of an IP address (e.g. 2001:db8::1 for 2001:0db8:0:0:0:0:0:1, 10.0.0.1 for
+// ::ffff:10.0.0.1), so addresses written differently compare equal
+func normalizeIP(s string) (string, error) {
+	if s == "" {
+		return "", nil
+	}
+	addr, err := netip.ParseAddr(strings.TrimSpace(s))
+	if err != nil {
+		return "", fmt.Errorf("invalid IP address: %w", err)
+	}
+	return addr.Unmap().String(), nil
+}
+
+// normalizeCIDR returns the canonical form of a CIDR, with the bits of the host part cleared (e.g. 10.0.0.0/8 for
+// 10.1.2.3/8)
+func normalizeCIDR(s string) (string, error) {
+	if s == "" {
+		return "", nil
+	}
+	prefix, err := netip.ParsePrefix(strings.TrimSpace(s))
+	if err != nil {
+		return "", fmt.Errorf("invalid CIDR: %w", err)
+	}
+	return prefix.Masked().String(), nil
+}
+
+// Certificate is what templates can check of a certificate parsed with parseCertificate
+type Certificate struct {
+	Subject      string
+	Issuer       string
+	SerialNumber string
+	NotBefore    time.Time
+	NotAfter     time.Time
+	IsCA         bool
+	// DNSNames, IPAddresses, EmailAddresses and URIs are the subject alternative names of the certificate
+	DNSNames       []string
+	IPAddresses    []string
+	EmailAddresses []string
+	URIs           []string
+}
+
+func newCertificate(cert *x509.Certificate) Certificate {
+	c := Certificate{
+		Subject:        cert.Subject.String(),
+		Issuer:         cert.Issuer.String(),
+		SerialNumber:   cert.SerialNumber.String(),
+		NotBefore:      cert.NotBefore.UTC(),
+		NotAfter:       cert.NotAfter.UTC(),
+		IsCA:           cert.IsCA,
+		DNSNames:       cert.DNSNames,
+		EmailAddresses: cert.EmailAddresses,
+	}
+	for _, ip := range cert.IPAddresses {
+		c.IPAddresses = append(c.IPAddresses, ip.String())
+	}
+	for _, uri := range cert.URIs {
+		c.URIs = append(c.URIs, uri.String())
+	}
+	return c
+}
+
+// parseCertificates parses the certificates of a PEM bundle, other PEM blocks such as keys are skipped
+func parseCertificates(s string) ([]Certificate, error) {
+	var certs []Certificate
+	rest := []byte(s)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid certificate: %w", err)
+		}
+		certs = append(certs, newCertificate(cert))
+	}
+	if len(certs) == 0 && strings.TrimSpace(s) != "" {
+		return nil, errors.New("no PEM certificate found")
+	}
+	return certs, nil
+}
+
+// parseCertificate parses the first certificate of a PEM bundle, e.g. the certificate of the server in a chain
+func parseCertificate(s string) (Certificate, error) {
+	certs, err := parseCertificates(s)
+	if err != nil || len(certs) == 0 {
+		return Certificate{}, err
+	}
+	return certs[0], nil
+}
+
+// parseDuration parses a duration such as 1h30m, its Seconds, Minutes and Hours can be compared in templates
+func parseDuration(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration: %w", err)
+	}
+	return d, nil
+}
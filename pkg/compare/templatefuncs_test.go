@@ -0,0 +1,102 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"strings"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNetworkFuncs(t *testing.T) {
+	cases := []struct {
+		name     string
+		template string
+		expected string
+		err      string
+	}{
+		{name: "address in CIDR", template: `{{ cidrContains "10.0.0.0/16" "10.0.3.4" }}`, expected: "true"},
+		{name: "address outside CIDR", template: `{{ cidrContains "10.0.0.0/16" "10.1.0.1" }}`, expected: "false"},
+		{name: "subnet in CIDR", template: `{{ cidrContains "10.0.0.0/16" "10.0.128.0/17" }}`, expected: "true"},
+		{name: "larger subnet", template: `{{ cidrContains "10.0.0.0/16" "10.0.0.0/8" }}`, expected: "false"},
+		{name: "mapped IPv4 address", template: `{{ cidrContains "10.0.0.0/16" "::ffff:10.0.0.1" }}`, expected: "true"},
+		{name: "IPv6 address in CIDR", template: `{{ cidrContains "fd00::/48" "fd00:0:0:1::5" }}`, expected: "true"},
+		{name: "empty argument", template: `{{ cidrContains "" "10.0.0.1" }}`, expected: "false"},
+		{name: "invalid CIDR", template: `{{ cidrContains "10.0.0.0/33" "10.0.0.1" }}`, err: "invalid CIDR"},
+		{name: "overlapping CIDRs", template: `{{ cidrOverlaps "10.0.0.0/16" "10.0.128.0/24" }}`, expected: "true"},
+		{name: "disjoint CIDRs", template: `{{ cidrOverlaps "10.0.0.0/16" "10.1.0.0/16" }}`, expected: "false"},
+		{name: "IPv6 address", template: `{{ normalizeIP "2001:0DB8:0:0:0:0:0:1" }}`, expected: "2001:db8::1"},
+		{name: "mapped address", template: `{{ normalizeIP "::ffff:10.0.0.1" }}`, expected: "10.0.0.1"},
+		{name: "invalid address", template: `{{ normalizeIP "10.0.0.256" }}`, err: "invalid IP address"},
+		{name: "CIDR with host bits", template: `{{ normalizeCIDR "10.1.2.3/8" }}`, expected: "10.0.0.0/8"},
+		{name: "duration", template: `{{ (parseDuration "1h30m").Minutes }}`, expected: "90"},
+		{name: "empty duration", template: `{{ parseDuration "" }}`, expected: "0s"},
+		{name: "invalid duration", template: `{{ parseDuration "90" }}`, err: "invalid duration"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			out, err := renderFuncs(c.template, nil)
+			if c.err != "" {
+				require.ErrorContains(t, err, c.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expected, out)
+		})
+	}
+}
+
+func TestCertificateFuncs(t *testing.T) {
+	notAfter := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	bundle := newTestCertificate(t, "app.example.com", notAfter) + newTestCertificate(t, "intermediate", notAfter)
+
+	out, err := renderFuncs(`{{ $c := parseCertificate . }}{{ $c.Subject }} {{ $c.Issuer }} {{ $c.NotAfter.Format "2006-01-02" }} `+
+		`{{ join "," $c.DNSNames }} {{ join "," $c.IPAddresses }} {{ len (parseCertificates .) }}`, bundle)
+	require.NoError(t, err)
+	require.Equal(t, "CN=app.example.com CN=app.example.com 2030-01-02 app.example.com,www.example.com 10.0.0.1 2", out)
+
+	out, err = renderFuncs(`{{ (parseCertificate .).NotAfter.IsZero }}`, "")
+	require.NoError(t, err)
+	require.Equal(t, "true", out)
+
+	_, err = renderFuncs(`{{ parseCertificate . }}`, "not a certificate")
+	require.ErrorContains(t, err, "no PEM certificate found")
+}
+
+func renderFuncs(text string, data any) (string, error) {
+	tmpl, err := template.New("").Funcs(FuncMap()).Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	err = tmpl.Execute(&sb, data)
+	return sb.String(), err
+}
+
+// newTestCertificate returns a self-signed PEM certificate for the common name
+func newTestCertificate(t *testing.T, commonName string, notAfter time.Time) string {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    notAfter.AddDate(-1, 0, 0),
+		NotAfter:     notAfter,
+		DNSNames:     []string{commonName, "www.example.com"},
+		IPAddresses:  []net.IP{net.ParseIP("10.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	require.NoError(t, err)
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
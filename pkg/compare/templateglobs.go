@@ -0,0 +1,134 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+)
+
+// isTemplatePattern reports whether the path of a template entry is a glob or a directory, which are expanded to the
+// templates they match when the reference is loaded
+func isTemplatePattern(p string) bool {
+	return strings.ContainsAny(p, "*?[") || strings.HasSuffix(p, "/")
+}
+
+// expandTemplatePatterns replaces the template entries of the components whose path is a glob (e.g.
+// templates/network/*.yaml) or a directory (a path ending with /) by an entry per matched file, copying the config of
+// the entry. Directories match their YAML files and the ones of their subdirectories. The matched files are added in
+// lexical order. Files listed explicitly in the reference aren't matched by patterns, so their config can be set
+// apart, and files matched by more than one pattern are reported.
+func (r *ReferenceV2) expandTemplatePatterns(fsys fs.FS, referenceFileName string) error {
+	excluded := map[string]bool{path.Clean(referenceFileName): true}
+	for _, f := range r.TemplateFunctionFiles {
+		excluded[path.Clean(f)] = true
+	}
+	for _, part := range r.Parts {
+		for _, comp := range part.Components {
+			for _, g := range comp.groups() {
+				for _, temp := range g.templates {
+					if !isTemplatePattern(temp.Path) {
+						excluded[path.Clean(temp.Path)] = true
+					}
+				}
+			}
+		}
+	}
+
+	var errs []error
+	matchedBy := make(map[string]string)
+	for _, part := range r.Parts {
+		for _, comp := range part.Components {
+			for _, g := range comp.groups() {
+				templates, err := expandGroupPatterns(fsys, g.templates, excluded, matchedBy)
+				if err != nil {
+					errs = append(errs, fmt.Errorf("component %s: %w", comp.Name, err))
+				}
+				g.templates = templates
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// groups returns the template groups of the component, whether they have templates or not
+func (comp *ComponentV2) groups() []*componentGroup {
+	return []*componentGroup{
+		&comp.OneOf.componentGroup, &comp.NoneOf.componentGroup, &comp.AllOf.componentGroup,
+		&comp.AnyOf.componentGroup, &comp.AnyOneOf.componentGroup, &comp.AllOrNoneOf.componentGroup,
+	}
+}
+
+func expandGroupPatterns(fsys fs.FS, templates []*ReferenceTemplateV2, excluded map[string]bool, matchedBy map[string]string) ([]*ReferenceTemplateV2, error) {
+	var errs []error
+	result := make([]*ReferenceTemplateV2, 0, len(templates))
+	for _, temp := range templates {
+		if !isTemplatePattern(temp.Path) {
+			result = append(result, temp)
+			continue
+		}
+		matches, err := matchTemplatePattern(fsys, temp.Path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if len(matches) == 0 {
+			errs = append(errs, fmt.Errorf("template path %s matches no files", temp.Path))
+			continue
+		}
+		for _, match := range matches {
+			if excluded[match] {
+				continue
+			}
+			if other, ok := matchedBy[match]; ok {
+				errs = append(errs, fmt.Errorf("template %s is matched by both %s and %s", match, other, temp.Path))
+				continue
+			}
+			matchedBy[match] = temp.Path
+			expanded := *temp
+			expanded.Path = match
+			result = append(result, &expanded)
+		}
+	}
+	return result, errors.Join(errs...)
+}
+
+// matchTemplatePattern returns the files matched by a glob, or the YAML files of a directory and its subdirectories,
+// in lexical order
+func matchTemplatePattern(fsys fs.FS, pattern string) ([]string, error) {
+	var matches []string
+	if dir, ok := strings.CutSuffix(pattern, "/"); ok && !strings.ContainsAny(dir, "*?[") {
+		err := fs.WalkDir(fsys, path.Clean(dir), func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && slices.Contains([]string{".yaml", ".yml"}, path.Ext(p)) {
+				matches = append(matches, p)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list the templates of directory %s: %w", pattern, err)
+		}
+	} else {
+		globbed, err := fs.Glob(fsys, strings.TrimSuffix(pattern, "/"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid template path %s: %w", pattern, err)
+		}
+		for _, p := range globbed {
+			info, err := fs.Stat(fsys, p)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read template %s matched by %s: %w", p, pattern, err)
+			}
+			if !info.IsDir() {
+				matches = append(matches, p)
+			}
+		}
+	}
+	slices.Sort(matches)
+	return matches, nil
+}
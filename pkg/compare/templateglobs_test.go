@@ -0,0 +1,46 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchTemplatePattern(t *testing.T) {
+	fsys := fstest.MapFS{
+		"metadata.yaml":         {},
+		"net/b.yaml":            {},
+		"net/a.yaml":            {},
+		"net/a.json":            {},
+		"net/sub/c.yml":         {},
+		"net/sub-dir.yaml/x.md": {},
+		"net-extra/d.yaml":      {},
+	}
+	cases := []struct {
+		pattern string
+		matches []string
+		err     string
+	}{
+		{pattern: "net/*.yaml", matches: []string{"net/a.yaml", "net/b.yaml"}},
+		{pattern: "net/*", matches: []string{"net/a.json", "net/a.yaml", "net/b.yaml"}},
+		{pattern: "net*/*.yaml", matches: []string{"net-extra/d.yaml", "net/a.yaml", "net/b.yaml"}},
+		{pattern: "net/", matches: []string{"net/a.yaml", "net/b.yaml", "net/sub/c.yml"}},
+		{pattern: "missing/*.yaml"},
+		{pattern: "missing/", err: "failed to list the templates of directory missing/"},
+		{pattern: "net/[", err: "invalid template path net/["},
+	}
+	for _, c := range cases {
+		t.Run(c.pattern, func(t *testing.T) {
+			matches, err := matchTemplatePattern(fsys, c.pattern)
+			if c.err != "" {
+				require.ErrorContains(t, err, c.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.matches, matches)
+		})
+	}
+}
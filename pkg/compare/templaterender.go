@@ -0,0 +1,137 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const (
+	TemplateErrorFail = "fail"
+	TemplateErrorSkip = "skip"
+)
+
+var TemplateErrorPolicies = []string{TemplateErrorFail, TemplateErrorSkip}
+
+// TemplateRenderError is a failure to render a template for a cluster CR: an error returned by the template, a panic
+// or a render that took longer than the timeout
+type TemplateRenderError struct {
+	Template string
+	Err      error
+}
+
+func (e TemplateRenderError) Error() string {
+	return fmt.Sprintf("failed to render template %s: %s", e.Template, e.Err)
+}
+
+func (e TemplateRenderError) Unwrap() error {
+	return e.Err
+}
+
+// RenderFailure is a template that failed to render for a cluster CR, reported in the summary when render failures
+// are skipped
+type RenderFailure struct {
+	Template string `json:"Template"`
+	CR       string `json:"CR"`
+	Error    string `json:"Error"`
+}
+
+// renderFailures collects the render failures of a run, templates are rendered concurrently
+type renderFailures struct {
+	mu       sync.Mutex
+	failures []RenderFailure
+}
+
+func (r *renderFailures) add(temp ReferenceTemplate, cr *unstructured.Unstructured, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failures = append(r.failures, RenderFailure{Template: temp.GetPath(), CR: apiKindNamespaceName(cr), Error: err.Error()})
+}
+
+// summarize returns the render failures sorted by template and CR
+func (r *renderFailures) summarize() []RenderFailure {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	failures := append([]RenderFailure(nil), r.failures...)
+	sort.Slice(failures, func(i, j int) bool {
+		if failures[i].Template != failures[j].Template {
+			return failures[i].Template < failures[j].Template
+		}
+		return failures[i].CR < failures[j].CR
+	})
+	return failures
+}
+
+// renderFailedTemplates returns the paths of the templates that failed to render, they aren't reported as missing
+// since the CRs they failed to render for may be the ones they should match
+func renderFailedTemplates(failures []RenderFailure) map[string]bool {
+	templates := make(map[string]bool, len(failures))
+	for _, f := range failures {
+		templates[f.Template] = true
+	}
+	return templates
+}
+
+// renderTimeout returns the render timeout of the template: the one of its config if set, the default one otherwise.
+// The timeouts of the template configs are validated when the reference is loaded.
+func renderTimeout(temp ReferenceTemplate, defaultTimeout time.Duration) time.Duration {
+	if t := temp.GetConfig().GetRenderTimeout(); t != "" {
+		if timeout, err := time.ParseDuration(t); err == nil {
+			return timeout
+		}
+	}
+	return defaultTimeout
+}
+
+// renderTemplate renders the template with the params and returns the warnings of the render, turning panics into
+// errors and giving up after the timeout (zero means no timeout). lookupCR looks up the cluster objects requested by the
+// template, if set. A render that times out can't be interrupted, it keeps running in the background on a copy of the
+// params until it ends.
+func renderTemplate(ctx context.Context, temp ReferenceTemplate, params map[string]any, timeout time.Duration, lookupCR lookupFunc) (*unstructured.Unstructured, []string, error) {
+	type rendered struct {
+		obj      *unstructured.Unstructured
+		warnings []string
+		err      error
+	}
+	result := make(chan rendered, 1)
+	if timeout > 0 {
+		params = runtime.DeepCopyJSON(params)
+	}
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				result <- rendered{err: fmt.Errorf("panic: %v", r)}
+			}
+		}()
+		obj, warnings, err := execWithLookup(temp, params, lookupCR)
+		result <- rendered{obj: obj, warnings: warnings, err: err}
+	}()
+
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	select {
+	case r := <-result:
+		if r.err != nil {
+			return nil, nil, TemplateRenderError{Template: temp.GetPath(), Err: r.err}
+		}
+		return r.obj, r.warnings, nil
+	case <-expired:
+		return nil, nil, TemplateRenderError{Template: temp.GetPath(), Err: fmt.Errorf("rendering timed out after %s", timeout)}
+	case <-ctx.Done():
+		return nil, nil, context.Cause(ctx)
+	}
+}
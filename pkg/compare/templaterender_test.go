@@ -0,0 +1,68 @@
+package compare
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// execTemplate is a template rendered by a function
+type execTemplate struct {
+	ReferenceTemplate
+	exec   func(params map[string]any) (*unstructured.Unstructured, error)
+	config ReferenceTemplateConfigV2
+}
+
+func (t execTemplate) Exec(params map[string]any) (*unstructured.Unstructured, error) {
+	return t.exec(params)
+}
+
+func (t execTemplate) GetPath() string {
+	return "temp.yaml"
+}
+
+func (t execTemplate) GetConfig() TemplateConfig {
+	return t.config
+}
+
+func TestRenderTemplate(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	blocking := execTemplate{exec: func(map[string]any) (*unstructured.Unstructured, error) {
+		<-release
+		return &unstructured.Unstructured{}, nil
+	}}
+	params := map[string]any{"kind": "ConfigMap"}
+
+	obj, _, err := renderTemplate(context.Background(), execTemplate{exec: func(p map[string]any) (*unstructured.Unstructured, error) {
+		return &unstructured.Unstructured{Object: p}, nil
+	}}, params, time.Second, nil)
+	require.NoError(t, err)
+	require.Equal(t, params, obj.Object)
+
+	_, _, err = renderTemplate(context.Background(), execTemplate{exec: func(map[string]any) (*unstructured.Unstructured, error) {
+		panic("bad sprig usage")
+	}}, params, 0, nil)
+	require.ErrorAs(t, err, &TemplateRenderError{})
+	require.ErrorContains(t, err, "failed to render template temp.yaml: panic: bad sprig usage")
+
+	_, _, err = renderTemplate(context.Background(), blocking, params, 10*time.Millisecond, nil)
+	require.ErrorAs(t, err, &TemplateRenderError{})
+	require.ErrorContains(t, err, "rendering timed out after 10ms")
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	cause := errors.New("stopped")
+	cancel(cause)
+	_, _, err = renderTemplate(ctx, blocking, params, 0, nil)
+	require.ErrorIs(t, err, cause)
+	require.NotErrorAs(t, err, &TemplateRenderError{})
+}
+
+func TestRenderTimeout(t *testing.T) {
+	require.Equal(t, time.Minute, renderTimeout(execTemplate{}, time.Minute))
+	require.Equal(t, 5*time.Second, renderTimeout(execTemplate{config: ReferenceTemplateConfigV2{RenderTimeout: "5s"}}, time.Minute))
+}
@@ -0,0 +1,312 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+	kcmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"k8s.io/kubectl/pkg/util/i18n"
+	"k8s.io/kubectl/pkg/util/templates"
+	"k8s.io/utils/exec"
+	"sigs.k8s.io/yaml"
+)
+
+var (
+	testLong = templates.LongDesc(`
+		Run the unit tests of the templates of a reference configuration.
+
+		The tests are YAML files in the tests directory next to the reference config file (or in --tests-dir), every
+		file is a test case holding the path of the template as in the reference config, the input cluster CR and the
+		expected outcome: the template rendered with the input (expected), the diff of the input against the template
+		as printed by the internal diff engine (expectedDiff), or both. An empty expectedDiff expects no differences.
+
+		Exit status: 0 All the tests passed. 1 Some tests failed. >1 The tests couldn't be run.
+	`)
+
+	testExample = templates.Examples(`
+		# Run the template tests of a reference configuration:
+		kubectl cluster-compare test -r ./reference/metadata.yaml
+
+		# Run the template tests of a directory and print the results as json:
+		kubectl cluster-compare test -r ./reference/metadata.yaml --tests-dir ./reference-tests -o json
+	`)
+)
+
+const (
+	TemplateTestsFailedMsg = "some template tests failed"
+	DefaultTestsDir        = "tests"
+)
+
+// TemplateTestCase is a unit test of a template, read from a file of the tests directory
+type TemplateTestCase struct {
+	// Template is the path of the tested template as in the reference config
+	Template string `json:"template"`
+	// Input is the cluster CR the template is rendered with and compared to
+	Input map[string]any `json:"input"`
+	// Expected is the expected result of rendering the template with the input
+	Expected map[string]any `json:"expected,omitempty"`
+	// ExpectedDiff is the expected diff of the input against the template, as printed by the internal diff engine
+	ExpectedDiff *string `json:"expectedDiff,omitempty"`
+}
+
+// TemplateTestResult is the result of a test case
+type TemplateTestResult struct {
+	Name     string   `json:"name"`
+	Template string   `json:"template,omitempty"`
+	Passed   bool     `json:"passed"`
+	Failures []string `json:"failures,omitempty"`
+}
+
+func (r TemplateTestResult) String() string {
+	if r.Passed {
+		return fmt.Sprintf("PASS %s", r.Name)
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "FAIL %s", r.Name)
+	for _, f := range r.Failures {
+		sb.WriteString("\n    " + strings.ReplaceAll(strings.TrimRight(f, "\n"), "\n", "\n    "))
+	}
+	return sb.String()
+}
+
+// TemplateTestsOutput is the output of the test command in json and yaml formats
+type TemplateTestsOutput struct {
+	Results   []TemplateTestResult `json:"results"`
+	NumPassed int                  `json:"numPassed"`
+	NumFailed int                  `json:"numFailed"`
+}
+
+type TemplateTestOptions struct {
+	referenceConfig string
+	testsDir        string
+	OutputFormat    string
+
+	genericiooptions.IOStreams
+}
+
+func NewTestCmd(streams genericiooptions.IOStreams) *cobra.Command {
+	options := &TemplateTestOptions{IOStreams: streams}
+
+	cmd := &cobra.Command{
+		Use:                   "test -r <Reference File>",
+		DisableFlagsInUseLine: true,
+		Short:                 i18n.T("Run the unit tests of the templates of a reference configuration."),
+		Long:                  testLong,
+		Example:               exampleForBinary(testExample),
+		Run: func(cmd *cobra.Command, args []string) {
+			kcmdutil.CheckDiffErr(options.Complete(cmd, args))
+			if err := options.Run(); err != nil {
+				if exitErr := diffError(err); exitErr != nil {
+					kcmdutil.CheckErr(kcmdutil.ErrExit)
+				}
+				kcmdutil.CheckDiffErr(err)
+			}
+		},
+	}
+	cmd.SetFlagErrorFunc(func(command *cobra.Command, err error) error {
+		kcmdutil.CheckDiffErr(kcmdutil.UsageErrorf(cmd, err.Error()))
+		return nil
+	})
+	cmd.Flags().StringVarP(&options.referenceConfig, "reference", "r", "", "Path to reference config file.")
+	cmd.Flags().StringVar(&options.testsDir, "tests-dir", "",
+		fmt.Sprintf("Path to the directory of the test cases. Defaults to the %s directory next to the reference config file", DefaultTestsDir))
+	cmd.Flags().StringVarP(&options.OutputFormat, "output", "o", "", fmt.Sprintf(`Output format. One of: (%s, %s)`, Json, Yaml))
+	return cmd
+}
+
+func (o *TemplateTestOptions) Complete(cmd *cobra.Command, args []string) error {
+	if len(args) != 0 {
+		return kcmdutil.UsageErrorf(cmd, "Unexpected args: %v", args)
+	}
+	if o.referenceConfig == "" {
+		return kcmdutil.UsageErrorf(cmd, noRefFileWasPassed)
+	}
+	if _, err := os.Stat(o.referenceConfig); os.IsNotExist(err) && !isURL(o.referenceConfig) {
+		return errors.New(refFileNotExistsError)
+	}
+	if o.OutputFormat != "" && o.OutputFormat != Json && o.OutputFormat != Yaml {
+		return kcmdutil.UsageErrorf(cmd, "Invalid output format %q, must be one of: %s, %s", o.OutputFormat, Json, Yaml)
+	}
+	return nil
+}
+
+// Run runs the test cases and prints their results, in case some tests failed an exit error with code 1 is returned.
+func (o *TemplateTestOptions) Run() error {
+	cfs, err := GetRefFS(o.referenceConfig)
+	if err != nil {
+		return err
+	}
+	cfs = newSopsFS(cfs)
+	ref, err := GetReference(cfs, ReferenceFileName(o.referenceConfig))
+	if err != nil {
+		return err
+	}
+	temps, err := ParseTemplates(ref, cfs)
+	if err != nil {
+		return err
+	}
+
+	testsFS, testsDir := cfs, DefaultTestsDir
+	if o.testsDir != "" {
+		testsFS, testsDir = os.DirFS(o.testsDir), "."
+	}
+	output, err := RunTemplateTests(testsFS, testsDir, ref, temps)
+	if err != nil {
+		return err
+	}
+
+	if err := o.print(output); err != nil {
+		return fmt.Errorf("error occurred when writing output: %w", err)
+	}
+	if output.NumFailed > 0 {
+		return exec.CodeExitError{Err: errors.New(TemplateTestsFailedMsg), Code: 1}
+	}
+	return nil
+}
+
+func (o *TemplateTestOptions) print(output TemplateTestsOutput) error {
+	var content []byte
+	var err error
+	switch o.OutputFormat {
+	case Json:
+		content, err = json.Marshal(output)
+		content = append(content, '\n')
+	case Yaml:
+		content, err = yaml.Marshal(output)
+	default:
+		var sb strings.Builder
+		for _, r := range output.Results {
+			sb.WriteString(r.String() + "\n")
+		}
+		fmt.Fprintf(&sb, "%d passed, %d failed\n", output.NumPassed, output.NumFailed)
+		content = []byte(sb.String())
+	}
+	if err != nil {
+		return err // nolint:wrapcheck
+	}
+	_, err = o.Out.Write(content)
+	return err // nolint:wrapcheck
+}
+
+// RunTemplateTests runs the test cases of the directory of the file system against the templates of the reference.
+// Test cases that can't be run (e.g. invalid test files) are reported as failures.
+func RunTemplateTests(fsys fs.FS, dir string, ref Reference, temps []ReferenceTemplate) (TemplateTestsOutput, error) {
+	var files []string
+	err := fs.WalkDir(fsys, dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && (path.Ext(p) == ".yaml" || path.Ext(p) == ".yml") {
+			files = append(files, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return TemplateTestsOutput{}, fmt.Errorf("failed to find the template tests: %w", err)
+	}
+	if len(files) == 0 {
+		return TemplateTestsOutput{}, fmt.Errorf("no template tests found in %s", dir)
+	}
+	sort.Strings(files)
+
+	templatesByPath := make(map[string]ReferenceTemplate, len(temps))
+	for _, temp := range temps {
+		templatesByPath[temp.GetPath()] = temp
+	}
+	// The templates are rendered and diffed with the defaults of the compare command, so the tests can't hang on a
+	// template that loops
+	o := NewOptions(genericiooptions.IOStreams{In: &bytes.Buffer{}, Out: io.Discard, ErrOut: io.Discard})
+	o.ref = ref
+	o.diffEngine = DiffEngineInternal
+
+	var output TemplateTestsOutput
+	for _, file := range files {
+		name := file
+		if dir != "." {
+			name = strings.TrimPrefix(file, dir+"/")
+		}
+		result := TemplateTestResult{Name: name}
+		result.Template, result.Failures = runTemplateTest(fsys, file, templatesByPath, o)
+		result.Passed = len(result.Failures) == 0
+		if result.Passed {
+			output.NumPassed++
+		} else {
+			output.NumFailed++
+		}
+		output.Results = append(output.Results, result)
+	}
+	return output, nil
+}
+
+// runTemplateTest runs the test case of the file and returns the tested template and the failures of the test
+func runTemplateTest(fsys fs.FS, file string, templatesByPath map[string]ReferenceTemplate, o *Options) (string, []string) {
+	content, err := fs.ReadFile(fsys, file)
+	if err != nil {
+		return "", []string{fmt.Sprintf("failed to read the test case: %s", err)}
+	}
+	var tc TemplateTestCase
+	if err := yaml.UnmarshalStrict(content, &tc); err != nil {
+		return "", []string{fmt.Sprintf("failed to parse the test case: %s", err)}
+	}
+	temp, ok := templatesByPath[tc.Template]
+	switch {
+	case tc.Template == "":
+		return "", []string{"the test case doesn't set the template"}
+	case !ok:
+		return tc.Template, []string{fmt.Sprintf("template %s isn't in the reference", tc.Template)}
+	case tc.Input == nil:
+		return tc.Template, []string{"the test case doesn't set the input"}
+	case tc.Expected == nil && tc.ExpectedDiff == nil:
+		return tc.Template, []string{"the test case doesn't set expected or expectedDiff"}
+	}
+	return tc.Template, runTemplateTestCase(tc, temp, o)
+}
+
+// runTemplateTestCase checks the outcomes expected by the test case
+func runTemplateTestCase(tc TemplateTestCase, temp ReferenceTemplate, o *Options) []string {
+	var failures []string
+	if tc.Expected != nil {
+		rendered, _, err := renderTemplate(context.Background(), temp, tc.Input, renderTimeout(temp, o.templateTimeout), nil)
+		if err != nil {
+			return []string{err.Error()}
+		}
+		if changes := structuralDiff(nil, tc.Expected, rendered.Object); len(changes) > 0 {
+			lines := make([]string, 0, len(changes))
+			for _, c := range changes {
+				lines = append(lines, c.String())
+			}
+			failures = append(failures, "the rendered template differs from expected (expected -> rendered):\n"+strings.Join(lines, "\n"))
+		}
+	}
+	if tc.ExpectedDiff != nil {
+		res, err := diffAgainstTemplate(context.Background(), temp, &unstructured.Unstructured{Object: tc.Input}, nil, o)
+		if err != nil {
+			return append(failures, fmt.Sprintf("failed to diff the input against the template: %s", err))
+		}
+		actual := ""
+		if res.IsDiff() {
+			actual = res.DiffOutput().String()
+		}
+		if strings.TrimSpace(actual) != strings.TrimSpace(*tc.ExpectedDiff) {
+			if actual == "" {
+				actual = "no differences\n"
+			}
+			failures = append(failures, "the diff differs from expectedDiff, actual diff:\n"+actual)
+		}
+	}
+	return failures
+}
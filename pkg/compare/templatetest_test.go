@@ -0,0 +1,79 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"path"
+	"testing"
+	"time"
+
+	"github.com/openshift/kube-compare/pkg/testutils"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+)
+
+func TestTemplateTests(t *testing.T) {
+	cases := []struct {
+		name         string
+		testsDir     string
+		outputFormat string
+		goldenPrefix string
+		expectFail   bool
+	}{
+		{name: "Template Tests", expectFail: true},
+		{name: "Template Tests", testsDir: "settings", outputFormat: Json, goldenPrefix: "settings_json_"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name+c.goldenPrefix, func(t *testing.T) {
+			test := defaultTest(c.name)
+			IOStream, _, out, _ := genericiooptions.NewTestIOStreams()
+			options := &TemplateTestOptions{
+				referenceConfig: path.Join(test.getTestDir(), TestRefDirName, defaultReferenceFilename),
+				OutputFormat:    c.outputFormat,
+				IOStreams:       IOStream,
+			}
+			if c.testsDir != "" {
+				options.testsDir = path.Join(test.getTestDir(), TestRefDirName, DefaultTestsDir, c.testsDir)
+			}
+			err := options.Run()
+			if c.expectFail {
+				require.NotNil(t, diffError(err))
+			} else {
+				require.NoError(t, err)
+			}
+			checkFile(t, path.Join(test.getTestDir(), c.goldenPrefix+defaultOutSuffix), testutils.RemoveInconsistentInfo(t, out.String()))
+		})
+	}
+}
+
+func TestTemplateTestsNotFound(t *testing.T) {
+	IOStream, _, _, _ := genericiooptions.NewTestIOStreams()
+	options := &TemplateTestOptions{
+		referenceConfig: path.Join(TestDirs, "FieldManagers", TestRefDirName, defaultReferenceFilename),
+		IOStreams:       IOStream,
+	}
+	err := options.Run()
+	require.Error(t, err)
+	require.Nil(t, diffError(err), "a reference without tests should fail with an error, not test failures")
+}
+
+func TestTemplateTestCaseRenderFailures(t *testing.T) {
+	o := NewOptions(genericiooptions.IOStreams{})
+	o.templateTimeout = 10 * time.Millisecond
+	tc := TemplateTestCase{Input: map[string]any{"kind": "ConfigMap"}, Expected: map[string]any{"kind": "ConfigMap"}}
+
+	panicking := execTemplate{exec: func(map[string]any) (*unstructured.Unstructured, error) {
+		panic("bad sprig usage")
+	}}
+	require.Equal(t, []string{"failed to render template temp.yaml: panic: bad sprig usage"}, runTemplateTestCase(tc, panicking, o))
+
+	release := make(chan struct{})
+	defer close(release)
+	blocking := execTemplate{exec: func(map[string]any) (*unstructured.Unstructured, error) {
+		<-release
+		return &unstructured.Unstructured{}, nil
+	}}
+	require.Equal(t, []string{"failed to render template temp.yaml: rendering timed out after 10ms"}, runTemplateTestCase(tc, blocking, o))
+}
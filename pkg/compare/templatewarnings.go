@@ -0,0 +1,90 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const (
+	warningMarkerStart = "<kube-compare-warning:"
+	warningMarkerEnd   = ">"
+)
+
+var warningMarker = regexp.MustCompile(regexp.QuoteMeta(warningMarkerStart) + `([A-Za-z0-9+/=]*)` + regexp.QuoteMeta(warningMarkerEnd))
+
+// warn is the template function reporting an advisory message (e.g. a deprecated field is used) for the cluster CR
+// the template is rendered with. The message is written to the output of the template as a marker that's removed
+// before the output is parsed, so templates stay free of state shared by their renders.
+func warn(msg any) string {
+	return warningMarkerStart + base64.StdEncoding.EncodeToString([]byte(fmt.Sprint(msg))) + warningMarkerEnd
+}
+
+// extractWarnings removes the markers of the warnings from the output of a template and returns the warnings in the
+// order they were reported, without duplicates
+func extractWarnings(content []byte) ([]byte, []string) {
+	var warnings []string
+	content = warningMarker.ReplaceAllFunc(content, func(marker []byte) []byte {
+		encoded := warningMarker.FindSubmatch(marker)[1]
+		if msg, err := base64.StdEncoding.DecodeString(string(encoded)); err == nil && !slices.Contains(warnings, string(msg)) {
+			warnings = append(warnings, string(msg))
+		}
+		return nil
+	})
+	return content, warnings
+}
+
+// warningsExecutor is implemented by the templates that report the warnings of their renders
+type warningsExecutor interface {
+	ExecWithWarnings(params map[string]any) (*unstructured.Unstructured, []string, error)
+}
+
+// execWithWarnings renders the template and returns the warnings of the render, if the template reports them
+func execWithWarnings(temp ReferenceTemplate, params map[string]any) (*unstructured.Unstructured, []string, error) {
+	if t, ok := temp.(warningsExecutor); ok {
+		return t.ExecWithWarnings(params)
+	}
+	obj, err := temp.Exec(params)
+	return obj, nil, err
+}
+
+// TemplateWarning is a warning reported by a template when it was rendered for the cluster CR it's correlated to
+type TemplateWarning struct {
+	Template string `json:"Template"`
+	CR       string `json:"CR"`
+	Message  string `json:"Message"`
+}
+
+// templateWarnings returns the warnings of the diffs sorted by template and CR
+func templateWarnings(diffs []DiffSum) []TemplateWarning {
+	var warnings []TemplateWarning
+	for _, d := range diffs {
+		warnings = append(warnings, diffWarnings(d)...)
+	}
+	return sortWarnings(warnings)
+}
+
+// diffWarnings returns the warnings reported by the template when it was rendered for the CR of the diff
+func diffWarnings(d DiffSum) []TemplateWarning {
+	var warnings []TemplateWarning
+	for _, msg := range d.Warnings {
+		warnings = append(warnings, TemplateWarning{Template: d.CorrelatedTemplate, CR: d.CRName, Message: msg})
+	}
+	return warnings
+}
+
+func sortWarnings(warnings []TemplateWarning) []TemplateWarning {
+	sort.SliceStable(warnings, func(i, j int) bool {
+		if warnings[i].Template != warnings[j].Template {
+			return warnings[i].Template < warnings[j].Template
+		}
+		return warnings[i].CR < warnings[j].CR
+	})
+	return warnings
+}
@@ -0,0 +1,18 @@
+package compare
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtractWarnings(t *testing.T) {
+	content := []byte("data:\n  " + warn("first: a > b\nsecond line") + "\n  key: value" + warn("first: a > b\nsecond line") + warn(3) + "\n")
+	stripped, warnings := extractWarnings(content)
+	require.Equal(t, "data:\n  \n  key: value\n", string(stripped))
+	require.Equal(t, []string{"first: a > b\nsecond line", "3"}, warnings)
+
+	stripped, warnings = extractWarnings([]byte("key: <kube-compare-warning>"))
+	require.Equal(t, "key: <kube-compare-warning>", string(stripped))
+	require.Empty(t, warnings)
+}
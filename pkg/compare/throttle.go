@@ -0,0 +1,44 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/rest"
+	kcmdutil "k8s.io/kubectl/pkg/cmd/util"
+)
+
+// defaultChunkSize is the number of objects requested per page when listing the resources of the cluster, the same as
+// kubectl get
+const defaultChunkSize = 500
+
+// throttledGetter sets the client-side rate limit of the clients created from the REST config of the wrapped getter
+type throttledGetter struct {
+	genericclioptions.RESTClientGetter
+	qps   float32
+	burst int
+}
+
+func (g throttledGetter) ToRESTConfig() (*rest.Config, error) {
+	config, err := g.RESTClientGetter.ToRESTConfig()
+	if err != nil {
+		return nil, err // nolint:wrapcheck
+	}
+	config = rest.CopyConfig(config)
+	if g.qps > 0 {
+		config.QPS = g.qps
+	}
+	if g.burst > 0 {
+		config.Burst = g.burst
+	}
+	return config, nil
+}
+
+// throttled returns a factory creating the clients of the cluster of the factory with the rate limit set by --qps and
+// --burst, the factory is returned as is when neither is set so the client-go defaults apply
+func (o *Options) throttled(f kcmdutil.Factory) kcmdutil.Factory {
+	if o.qps == 0 && o.burst == 0 {
+		return f
+	}
+	return kcmdutil.NewFactory(throttledGetter{RESTClientGetter: f, qps: o.qps, burst: o.burst})
+}
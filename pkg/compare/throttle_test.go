@@ -0,0 +1,36 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/rest"
+)
+
+type restConfigGetter struct {
+	genericclioptions.RESTClientGetter
+	config *rest.Config
+}
+
+func (g restConfigGetter) ToRESTConfig() (*rest.Config, error) {
+	return g.config, nil
+}
+
+func TestThrottledGetter(t *testing.T) {
+	original := &rest.Config{Host: "https://cluster", QPS: 5, Burst: 10}
+	getter := restConfigGetter{config: original}
+
+	config, err := throttledGetter{RESTClientGetter: getter, qps: 20}.ToRESTConfig()
+	require.NoError(t, err)
+	require.Equal(t, float32(20), config.QPS)
+	require.Equal(t, 10, config.Burst, "the default burst should be kept when --burst isn't set")
+	require.Equal(t, "https://cluster", config.Host)
+
+	config, err = throttledGetter{RESTClientGetter: getter, qps: 20, burst: 40}.ToRESTConfig()
+	require.NoError(t, err)
+	require.Equal(t, 40, config.Burst)
+	require.Equal(t, float32(5), original.QPS, "the config of the wrapped getter shouldn't be modified")
+}
@@ -0,0 +1,241 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"reflect"
+	"slices"
+	"sort"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// MatchTieBreakers choose the template a cluster CR is compared to when it's correlated to several templates. By
+// default the template with the fewest differences is chosen: the CR is first compared to the templates setting the
+// most weighted fields to the values of the CR, and among the templates with the fewest weighted differences the
+// templates preferred for the namespace of the CR are chosen.
+type MatchTieBreakers struct {
+	// FieldWeights are the fields telling which template a CR is meant for, e.g. a type or a profile name
+	FieldWeights []FieldWeight `json:"fieldWeights,omitempty"`
+	// DifferenceWeights are the fields whose differences count more when counting the differences of the CR with the
+	// templates, e.g. a mode whose value tells the template apart better than the tunables of the template
+	DifferenceWeights []FieldWeight `json:"differenceWeights,omitempty"`
+	// PreferredTemplates are the templates preferred for the CRs of some namespaces, the first entry matching the
+	// namespace of the CR applies
+	PreferredTemplates []PreferredTemplates `json:"preferredTemplates,omitempty"`
+}
+
+// FieldWeight adds its weight to the score of the templates rendering the field with the value of the cluster CR
+type FieldWeight struct {
+	PathToKey string `json:"pathToKey"`
+	Weight    int    `json:"weight"`
+	parts     []string
+}
+
+// PreferredTemplates are the templates preferred for the CRs of the namespaces matching the pattern, e.g. openshift-*
+type PreferredTemplates struct {
+	Namespace string   `json:"namespace"`
+	Templates []string `json:"templates"`
+}
+
+func (t *MatchTieBreakers) validate(templatePaths []string) error {
+	errs := []error{validateFieldWeights("fieldWeights", t.FieldWeights), validateFieldWeights("differenceWeights", t.DifferenceWeights)}
+	for i, p := range t.PreferredTemplates {
+		if _, err := path.Match(p.Namespace, ""); err != nil || p.Namespace == "" {
+			errs = append(errs, fmt.Errorf("preferredTemplates entry %d: invalid namespace pattern %q", i, p.Namespace))
+		}
+		if len(p.Templates) == 0 {
+			errs = append(errs, fmt.Errorf("preferredTemplates entry %d: templates must not be empty", i))
+		}
+		for _, temp := range p.Templates {
+			if !slices.Contains(templatePaths, temp) {
+				errs = append(errs, fmt.Errorf("preferredTemplates entry %d: template %s isn't in the reference", i, temp))
+			}
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("invalid matchTieBreakers: %w", err)
+	}
+	return nil
+}
+
+// validateFieldWeights parses the paths of the weighted fields
+func validateFieldWeights(name string, weights []FieldWeight) error {
+	var errs []error
+	for i := range weights {
+		w := &weights[i]
+		parts, err := pathToList(w.PathToKey)
+		switch {
+		case err != nil:
+			errs = append(errs, fmt.Errorf("%s entry %d: %w", name, i, err))
+		case w.PathToKey == "" || slices.Contains(parts, ""):
+			errs = append(errs, fmt.Errorf("%s entry %d: path %q contains an empty key", name, i, w.PathToKey))
+		case w.Weight <= 0:
+			errs = append(errs, fmt.Errorf("%s entry %d: weight must be positive", name, i))
+		default:
+			w.parts = parts
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// score returns the sum of the weights of the fields the rendered template sets to the value of the cluster CR
+func (t *MatchTieBreakers) score(rendered, cr *unstructured.Unstructured) int {
+	if t == nil || rendered == nil {
+		return 0
+	}
+	score := 0
+	for _, w := range t.FieldWeights {
+		expected, found := nestedField(rendered.Object, w.parts)
+		if !found {
+			continue
+		}
+		if actual, found := nestedField(cr.Object, w.parts); found && reflect.DeepEqual(expected, actual) {
+			score += w.Weight
+		}
+	}
+	return score
+}
+
+func nestedField(obj map[string]any, parts []string) (any, bool) {
+	value, found, err := unstructured.NestedFieldNoCopy(obj, parts...)
+	return value, found && err == nil
+}
+
+// differenceWeights weigh the differences of a cluster CR with a template: the differences of a weighted field and of
+// its subfields count with the weight of the most specific weighted field, the differences of other fields count 1.
+// Later weights of the same field replace the earlier ones, so the user config can change the weights of the reference.
+type differenceWeights []FieldWeight
+
+// newDifferenceWeights returns the difference weights of the reference followed by the ones of the user config
+func newDifferenceWeights(tieBreakers *MatchTieBreakers, userConfig UserConfig) differenceWeights {
+	var weights differenceWeights
+	if tieBreakers != nil {
+		weights = append(weights, tieBreakers.DifferenceWeights...)
+	}
+	return append(weights, userConfig.CorrelationSettings.DifferenceWeights...)
+}
+
+// weight returns the weight of the differences of the field
+func (w differenceWeights) weight(field []string) int {
+	weight, depth := 1, 0
+	for _, fw := range w {
+		if len(fw.parts) >= depth && len(fw.parts) <= len(field) && slices.Equal(fw.parts, field[:len(fw.parts)]) {
+			weight, depth = fw.Weight, len(fw.parts)
+		}
+	}
+	return weight
+}
+
+// score returns the sum of the weights of the differing fields of the merge patch between the template and the CR,
+// the items of lists count with the weight of the list
+func (w differenceWeights) score(patch any, field []string) int {
+	switch t := patch.(type) {
+	case map[string]any:
+		score := 0
+		for key, v := range t {
+			score += w.score(v, append(slices.Clip(field), key))
+		}
+		return score
+	case []any:
+		score := 0
+		for _, v := range t {
+			score += w.score(v, field)
+		}
+		return score
+	}
+	return w.weight(field)
+}
+
+// preferred returns the templates preferred for the namespace
+func (t *MatchTieBreakers) preferred(namespace string) []string {
+	if t == nil {
+		return nil
+	}
+	for _, p := range t.PreferredTemplates {
+		if ok, _ := path.Match(p.Namespace, namespace); ok {
+			return p.Templates
+		}
+	}
+	return nil
+}
+
+// findBestMatch returns the match the cluster CR is reported against: the matches with the highest score of weighted
+// fields are kept, then the matches with the lowest score of weighted differences, then the matches of the templates
+// preferred for the namespace of the CR. When several matches remain the first one is returned, together with the templates of all the
+// remaining matches.
+func findBestMatch(matches []*diffResult, cr *unstructured.Unstructured, tieBreakers *MatchTieBreakers) (*diffResult, []string) {
+	if len(matches) == 0 {
+		return nil, nil
+	}
+	scores := make(map[*diffResult]int, len(matches))
+	for _, m := range matches {
+		scores[m] = tieBreakers.score(m.rendered, cr)
+	}
+	best := keepBest(matches, func(a, b *diffResult) int { return scores[b] - scores[a] })
+	best = keepBest(best, func(a, b *diffResult) int { return a.differenceScore - b.differenceScore })
+	if preferred := tieBreakers.preferred(cr.GetNamespace()); len(best) > 1 && len(preferred) > 0 {
+		if kept := slices.DeleteFunc(slices.Clone(best), func(m *diffResult) bool {
+			return !slices.Contains(preferred, m.temp.GetPath())
+		}); len(kept) > 0 {
+			best = kept
+		}
+	}
+	if len(best) == 1 {
+		return best[0], nil
+	}
+	tied := make([]string, 0, len(best))
+	for _, m := range best {
+		tied = append(tied, m.temp.GetPath())
+	}
+	return best[0], tied
+}
+
+// keepBest returns the matches that compare the lowest, in their original order
+func keepBest(matches []*diffResult, cmp func(a, b *diffResult) int) []*diffResult {
+	best := []*diffResult{matches[0]}
+	for _, m := range matches[1:] {
+		switch c := cmp(m, best[0]); {
+		case c < 0:
+			best = []*diffResult{m}
+		case c == 0:
+			best = append(best, m)
+		}
+	}
+	return best
+}
+
+// AmbiguousMatch is a cluster CR that several templates match equally well, even after applying the tie-breakers of
+// the reference. The CR is compared to the first template.
+type AmbiguousMatch struct {
+	CR        string   `json:"CR"`
+	Templates []string `json:"Templates"`
+}
+
+// ambiguousMatches collects the ambiguous matches of a run, CRs are compared concurrently
+type ambiguousMatches struct {
+	mu      sync.Mutex
+	matches []AmbiguousMatch
+}
+
+func (a *ambiguousMatches) add(cr *unstructured.Unstructured, templates []string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.matches = append(a.matches, AmbiguousMatch{CR: apiKindNamespaceName(cr), Templates: templates})
+}
+
+// summarize returns the ambiguous matches sorted by CR
+func (a *ambiguousMatches) summarize() []AmbiguousMatch {
+	if a == nil {
+		return nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	matches := append([]AmbiguousMatch(nil), a.matches...)
+	sort.Slice(matches, func(i, j int) bool { return matches[i].CR < matches[j].CR })
+	return matches
+}
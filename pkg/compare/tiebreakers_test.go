@@ -0,0 +1,37 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDifferenceWeights(t *testing.T) {
+	tieBreakers := &MatchTieBreakers{DifferenceWeights: []FieldWeight{
+		{PathToKey: "spec", Weight: 2},
+		{PathToKey: "spec.mode", Weight: 10},
+	}}
+	require.NoError(t, tieBreakers.validate(nil))
+	userConfig := UserConfig{CorrelationSettings: CorrelationSettings{DifferenceWeights: []FieldWeight{
+		{PathToKey: "spec.mode", Weight: 5},
+	}}}
+	require.NoError(t, userConfig.process())
+	weights := newDifferenceWeights(tieBreakers, userConfig)
+
+	require.Equal(t, 1, weights.weight([]string{"metadata", "labels", "app"}))
+	require.Equal(t, 2, weights.weight([]string{"spec", "replicas"}))
+	require.Equal(t, 5, weights.weight([]string{"spec", "mode"}), "the user config should replace the weight of the reference")
+	require.Equal(t, 5, weights.weight([]string{"spec", "mode", "name"}))
+
+	patch := map[string]any{
+		"metadata": map[string]any{"labels": map[string]any{"app": nil}},
+		"spec": map[string]any{
+			"mode":  "ha",
+			"ports": []any{map[string]any{"port": int64(80)}, map[string]any{"port": int64(443)}},
+		},
+	}
+	require.Equal(t, 1+5+2+2, weights.score(patch, nil))
+	require.Equal(t, 4, differenceWeights(nil).score(patch, nil), "without weights the score should be the number of differences")
+}
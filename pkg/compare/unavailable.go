@@ -0,0 +1,103 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+const (
+	ConversionWebhookError = "conversion webhook error"
+	APIServiceUnavailable  = "aggregated API unavailable"
+	TooManyRequests        = "too many requests"
+	ServerTimeout          = "server timeout"
+	Forbidden              = "forbidden"
+)
+
+// UnavailableKind is a kind that couldn't be listed from the cluster because the API serving it is unavailable or
+// overloaded, or because listing it is forbidden in some of the namespaces of --namespaces
+type UnavailableKind struct {
+	Kind      string   `json:"Kind"`
+	Reason    string   `json:"Reason"`
+	Error     string   `json:"Error"`
+	Templates []string `json:"Templates,omitempty"`
+}
+
+// unavailableReason classifies errors caused by a conversion webhook or an aggregated API server being down, or by
+// an overloaded API server. Such errors may be transient, an empty string is returned for any other error
+func unavailableReason(err error) string {
+	switch {
+	case strings.Contains(err.Error(), "conversion webhook"):
+		return ConversionWebhookError
+	case apierrors.IsServiceUnavailable(err):
+		return APIServiceUnavailable
+	case apierrors.IsTooManyRequests(err):
+		return TooManyRequests
+	case apierrors.IsServerTimeout(err), apierrors.IsTimeout(err):
+		return ServerTimeout
+	}
+	return ""
+}
+
+// unavailableKinds collects the kinds that couldn't be listed during a live run, the kinds are listed concurrently
+// and an interrupted run is summarized while the kinds are still being listed
+type unavailableKinds struct {
+	mu    sync.Mutex
+	kinds []UnavailableKind
+}
+
+// add records the type in case the error is caused by the API serving it being unavailable, it returns true if
+// the error was recorded so it can be ignored
+func (u *unavailableKinds) add(resourceType string, err error) bool {
+	return u.record(resourceType, unavailableReason(err), err)
+}
+
+// addForbidden records the type in case listing it was forbidden, with roles bound to some namespaces the resources
+// the role doesn't allow listing are left out rather than failing the run. It returns true if the error was recorded.
+func (u *unavailableKinds) addForbidden(resourceType string, err error) bool {
+	if !apierrors.IsForbidden(err) {
+		return false
+	}
+	return u.record(resourceType, Forbidden, err)
+}
+
+func (u *unavailableKinds) record(resourceType, reason string, err error) bool {
+	if reason == "" {
+		return false
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	// Types listed with several field selectors are only recorded once
+	if slices.ContainsFunc(u.kinds, func(k UnavailableKind) bool { return k.Kind == resourceType }) {
+		return true
+	}
+	u.kinds = append(u.kinds, UnavailableKind{Kind: resourceType, Reason: reason, Error: err.Error()})
+	return true
+}
+
+// summarize returns the unavailable kinds with the templates that couldn't be compared because of them, together
+// with the paths of those templates
+func (u *unavailableKinds) summarize(templates []ReferenceTemplate) ([]UnavailableKind, map[string]bool) {
+	u.mu.Lock()
+	kinds := slices.Clone(u.kinds)
+	u.mu.Unlock()
+	affected := make(map[string]bool)
+	for i, k := range kinds {
+		// Types are in the form of {kind} or {kind}.{version}.{group}
+		kind, _, _ := strings.Cut(k.Kind, ".")
+		for _, t := range templates {
+			if t.GetMetadata().GetKind() == kind {
+				kinds[i].Templates = append(kinds[i].Templates, t.GetPath())
+				affected[t.GetPath()] = true
+			}
+		}
+		sort.Strings(kinds[i].Templates)
+	}
+	sort.Slice(kinds, func(i, j int) bool { return kinds[i].Kind < kinds[j].Kind })
+	return kinds, affected
+}
@@ -0,0 +1,48 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestUnavailableKinds(t *testing.T) {
+	refDir := filepath.Join("testdata", "FieldSeverities", "reference")
+	ref, err := GetReference(os.DirFS(refDir), "metadata.yaml")
+	require.NoError(t, err)
+	templates, err := ParseTemplates(ref, os.DirFS(refDir))
+	require.NoError(t, err)
+
+	u := &unavailableKinds{}
+	unavailable := apierrors.NewServiceUnavailable("down")
+	var wg sync.WaitGroup
+	for i := range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			require.True(t, u.add(fmt.Sprintf("Kind%d", i), unavailable))
+		}()
+	}
+	// An interrupted run is summarized while the kinds are still being listed
+	_, _ = u.summarize(templates)
+	wg.Wait()
+	require.False(t, u.add("Kind0", apierrors.NewNotFound(schema.GroupResource{}, "")))
+	require.True(t, u.add("ConfigMap", unavailable))
+
+	kinds, affected := u.summarize(templates)
+	require.Len(t, kinds, 11)
+	require.Equal(t, "ConfigMap", kinds[0].Kind)
+	require.Equal(t, []string{"features.yaml", "limits.yaml", "settings.yaml"}, kinds[0].Templates)
+	require.Equal(t, map[string]bool{"features.yaml": true, "limits.yaml": true, "settings.yaml": true}, affected)
+
+	again, _ := u.summarize(templates)
+	require.Equal(t, kinds, again, "summarizing shouldn't change the recorded kinds")
+}
@@ -0,0 +1,223 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+	"k8s.io/kubectl/pkg/cmd/diff"
+	kexec "k8s.io/utils/exec"
+	"sigs.k8s.io/yaml"
+)
+
+// unifiedDiffContext is the number of unchanged lines around the changes in the hunks, as with diff -u
+const unifiedDiffContext = 3
+
+// defaultDiffContext is the value of --diff-context keeping the context of the diff style: the context of diff -u for
+// unified diffs and the whole CR for side-by-side diffs
+const defaultDiffContext = -1
+
+// diffProgram is the program kubectl runs to diff the versions of the objects when KUBECTL_EXTERNAL_DIFF isn't set
+var diffProgram = "diff"
+
+// externalDiffAvailable checks if kubectl can run the external diff program: the program of KUBECTL_EXTERNAL_DIFF or
+// diff, which isn't installed on Windows or in minimal images
+func externalDiffAvailable() bool {
+	if os.Getenv("KUBECTL_EXTERNAL_DIFF") != "" {
+		return true
+	}
+	_, err := exec.LookPath(diffProgram)
+	return err == nil
+}
+
+// runUnifiedDiffer diffs the YAML of the versions of the object like diff -u -N, without an external diff program.
+// It's used when diff isn't installed. Like with diff, the returned exit error has code 1 if differences were found.
+func runUnifiedDiffer(obj diff.Object, from, to string, o *Options) (*bytes.Buffer, kexec.ExitError, error) {
+	diffOutput := new(bytes.Buffer)
+	fromContent, toContent, err := diffContents(obj, from, to, o)
+	if err != nil {
+		return diffOutput, nil, err
+	}
+	fromYAML, err := yaml.Marshal(fromContent)
+	if err != nil {
+		return diffOutput, nil, fmt.Errorf("error occurered during diff: %w", err)
+	}
+	toYAML, err := yaml.Marshal(toContent)
+	if err != nil {
+		return diffOutput, nil, fmt.Errorf("error occurered during diff: %w", err)
+	}
+
+	context, contextFlag := unifiedDiffContext, "-u"
+	if o.diffContext != defaultDiffContext {
+		context, contextFlag = o.diffContext, fmt.Sprintf("-U%d", o.diffContext)
+	}
+	hunks := unifiedDiff(string(fromYAML), string(toYAML), context)
+	if hunks == "" {
+		return diffOutput, nil, nil
+	}
+	fromPath, toPath := from+"/"+obj.Name(), to+"/"+obj.Name()
+	date := time.Now().Format("2006-01-02 15:04:05.000000000 -0700")
+	fmt.Fprintf(diffOutput, "diff %s -N %s %s\n--- %s\t%s\n+++ %s\t%s\n%s", contextFlag, fromPath, toPath, fromPath, date, toPath, date, hunks)
+	return diffOutput, kexec.CodeExitError{Err: fmt.Errorf("%s differs", obj.Name()), Code: 1}, nil
+}
+
+// diffLine is a line of an edit script: a space for unchanged lines, - for removed lines and + for added lines
+type diffLine struct {
+	op   byte
+	text string
+}
+
+// lineEdits returns the edit script turning the lines of a text into the ones of the other. The removed lines of a
+// change are listed before the added ones, as diff does. Every distinct line is encoded as a rune, from the private use
+// area up, to diff the lines as characters.
+func lineEdits(from, to string) []diffLine {
+	var lines []string
+	runes := make(map[string]rune)
+	encode := func(text string) []rune {
+		var encoded []rune
+		for _, line := range strings.SplitAfter(text, "\n") {
+			if line == "" {
+				continue
+			}
+			r, ok := runes[line]
+			if !ok {
+				r = rune(0xE000 + len(lines))
+				runes[line] = r
+				lines = append(lines, line)
+			}
+			encoded = append(encoded, r)
+		}
+		return encoded
+	}
+	fromRunes, toRunes := encode(from), encode(to)
+	// Without a timeout the edit script is the shortest, as with diff
+	dmp := diffmatchpatch.New()
+	dmp.DiffTimeout = 0
+	diffs := dmp.DiffMainRunes(fromRunes, toRunes, false)
+
+	var edits, added []diffLine
+	for _, d := range diffs {
+		for _, r := range d.Text {
+			line := lines[r-0xE000]
+			switch d.Type {
+			case diffmatchpatch.DiffDelete:
+				edits = append(edits, diffLine{op: '-', text: line})
+			case diffmatchpatch.DiffInsert:
+				added = append(added, diffLine{op: '+', text: line})
+			default:
+				edits = append(edits, added...)
+				added = nil
+				edits = append(edits, diffLine{op: ' ', text: line})
+			}
+		}
+	}
+	return append(edits, added...)
+}
+
+// unifiedDiff returns the hunks of the unified diff of the texts with the number of context lines, empty if the texts
+// are equal
+func unifiedDiff(from, to string, context int) string {
+	edits := lineEdits(from, to)
+	var sb strings.Builder
+	for start := 0; start < len(edits); {
+		// Find the first change, the hunk starts with the context lines before it
+		first := start
+		for first < len(edits) && edits[first].op == ' ' {
+			first++
+		}
+		if first == len(edits) {
+			break
+		}
+		hunkStart := max(start, first-context)
+		// The hunk ends with the context lines after its last change, changes separated by at most twice the context
+		// lines are in the same hunk
+		end := first
+		for i := first; i < len(edits); i++ {
+			if edits[i].op != ' ' {
+				end = i + 1
+			} else if i-end >= 2*context {
+				break
+			}
+		}
+		hunkEnd := min(len(edits), end+context)
+		writeHunk(&sb, edits, hunkStart, hunkEnd)
+		start = hunkEnd
+	}
+	return sb.String()
+}
+
+// writeHunk writes the edits of the hunk along with its header, holding the ranges of the lines of both texts
+func writeHunk(sb *strings.Builder, edits []diffLine, start, end int) {
+	fromLine, toLine := 1, 1
+	for _, e := range edits[:start] {
+		if e.op != '+' {
+			fromLine++
+		}
+		if e.op != '-' {
+			toLine++
+		}
+	}
+	fromCount, toCount := 0, 0
+	for _, e := range edits[start:end] {
+		if e.op != '+' {
+			fromCount++
+		}
+		if e.op != '-' {
+			toCount++
+		}
+	}
+	fmt.Fprintf(sb, "@@ -%s +%s @@\n", hunkRange(fromLine, fromCount), hunkRange(toLine, toCount))
+	for _, e := range edits[start:end] {
+		sb.WriteByte(e.op)
+		sb.WriteString(e.text)
+		if !strings.HasSuffix(e.text, "\n") {
+			sb.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
+
+// hunkRange formats the range of lines of a hunk as diff does: the count is left out when it's 1, and an empty range
+// starts at the line before it
+func hunkRange(line, count int) string {
+	switch count {
+	case 0:
+		return fmt.Sprintf("%d,0", line-1)
+	case 1:
+		return fmt.Sprintf("%d", line)
+	}
+	return fmt.Sprintf("%d,%d", line, count)
+}
+
+// contextDiffExec runs the diff program kubectl defaults to with the context lines of --diff-context instead of the
+// ones of -u. The arguments of the program set in KUBECTL_EXTERNAL_DIFF are left as is.
+type contextDiffExec struct {
+	kexec.Interface
+	context int
+}
+
+func (e contextDiffExec) Command(cmd string, args ...string) kexec.Cmd {
+	if os.Getenv("KUBECTL_EXTERNAL_DIFF") == "" && len(args) > 0 && args[0] == "-u" {
+		args = append([]string{fmt.Sprintf("-U%d", e.context)}, args[1:]...)
+	}
+	return e.Interface.Command(cmd, args...)
+}
+
+// stripDiffHeader removes the lines naming the compared files at the start of a diff: the diff command and the ---
+// and +++ lines
+func stripDiffHeader(diff *bytes.Buffer) *bytes.Buffer {
+	rest := diff.String()
+	for {
+		line, next, _ := strings.Cut(rest, "\n")
+		if !strings.HasPrefix(line, "diff ") && !strings.HasPrefix(line, "--- ") && !strings.HasPrefix(line, "+++ ") {
+			break
+		}
+		rest = next
+	}
+	return bytes.NewBufferString(rest)
+}
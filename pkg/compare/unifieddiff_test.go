@@ -0,0 +1,105 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	require.Empty(t, unifiedDiff("a\nb\n", "a\nb\n", 3))
+	require.Equal(t, "@@ -1,2 +1,2 @@\n a\n-b\n+c\n", unifiedDiff("a\nb\n", "a\nc\n", 3))
+	require.Equal(t, "@@ -0,0 +1 @@\n+a\n", unifiedDiff("", "a\n", 3))
+	require.Equal(t, "@@ -1 +1 @@\n-a\n\\ No newline at end of file\n+a\n", unifiedDiff("a", "a\n", 3))
+}
+
+func TestStripDiffHeader(t *testing.T) {
+	diff := "diff -U1 -N /tmp/MERGED/cm /tmp/LIVE/cm\n--- /tmp/MERGED/cm\n+++ /tmp/LIVE/cm\n@@ -2 +2 @@\n---- a\n++++ b\n"
+	require.Equal(t, "@@ -2 +2 @@\n---- a\n++++ b\n", stripDiffHeader(bytes.NewBufferString(diff)).String())
+	require.Equal(t, "- data.locale: \"en\"\n", stripDiffHeader(bytes.NewBufferString("- data.locale: \"en\"\n")).String())
+	require.Empty(t, stripDiffHeader(&bytes.Buffer{}).String())
+}
+
+// TestUnifiedDiffMatchesDiff checks that the hunks are the ones of diff -u, for random edits of a text
+func TestUnifiedDiffMatchesDiff(t *testing.T) {
+	if _, err := exec.LookPath("diff"); err != nil {
+		t.Skip("diff isn't installed")
+	}
+	dir := t.TempDir()
+	rnd := rand.New(rand.NewSource(1)) // nolint:gosec
+	for i := range 200 {
+		var from, to []string
+		for j := range 30 {
+			line := fmt.Sprintf("line %d", j%12)
+			switch rnd.Intn(6) {
+			case 0:
+				from = append(from, line)
+			case 1:
+				to = append(to, line)
+			case 2:
+				from = append(from, line)
+				to = append(to, "changed "+line)
+			default:
+				from = append(from, line)
+				to = append(to, line)
+			}
+		}
+		fromText, toText := strings.Join(from, "\n")+"\n", strings.Join(to, "\n")+"\n"
+		fromPath, toPath := filepath.Join(dir, "from"), filepath.Join(dir, "to")
+		require.NoError(t, os.WriteFile(fromPath, []byte(fromText), 0o600))
+		require.NoError(t, os.WriteFile(toPath, []byte(toText), 0o600))
+		out, err := exec.Command("diff", "-u", fromPath, toPath).Output()
+		var exitErr *exec.ExitError
+		if err != nil && !errors.As(err, &exitErr) {
+			require.NoError(t, err)
+		}
+		expected := ""
+		if lines := strings.SplitAfterN(string(out), "\n", 3); len(lines) == 3 {
+			expected = lines[2]
+		}
+		// Different edit scripts can be as short as the one of diff, the hunks must be as long and apply to the text
+		hunks := unifiedDiff(fromText, toText, 3)
+		require.Len(t, changedLines(hunks), len(changedLines(expected)), "case %d:\n%s\n---\n%s", i, fromText, toText)
+		require.Equal(t, toText, applyHunks(t, fromText, hunks), "case %d", i)
+	}
+}
+
+// applyHunks applies the hunks of a unified diff to the text, checking the ranges of their headers
+func applyHunks(t *testing.T, text, hunks string) string {
+	lines := strings.SplitAfter(text, "\n")
+	var result []string
+	next := 0
+	for _, hunk := range strings.Split(hunks, "@@ -")[1:] {
+		header, body, _ := strings.Cut(hunk, "\n")
+		var fromStart, fromCount, toStart, toCount int
+		_, err := fmt.Sscanf(strings.ReplaceAll(header, ",", " "), "%d %d +%d %d @@", &fromStart, &fromCount, &toStart, &toCount)
+		require.NoError(t, err, "the header should hold the counts of the lines: %s", header)
+		result = append(result, lines[next:fromStart-1]...)
+		require.Len(t, result, toStart-1, "the hunk should start at its line in the new text: %s", header)
+		next = fromStart - 1
+		for _, line := range strings.SplitAfter(body, "\n") {
+			switch {
+			case strings.HasPrefix(line, " "):
+				require.Equal(t, lines[next], line[1:])
+				result = append(result, line[1:])
+				next++
+			case strings.HasPrefix(line, "-"):
+				require.Equal(t, lines[next], line[1:])
+				next++
+			case strings.HasPrefix(line, "+"):
+				result = append(result, line[1:])
+			}
+		}
+	}
+	return strings.Join(append(result, lines[next:]...), "")
+}
@@ -0,0 +1,54 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// validateUnorderedLists checks that the JSONPaths of the unordered lists can be parsed
+func validateUnorderedLists(paths []string) error {
+	var errs []error
+	for _, p := range paths {
+		if _, err := parseJSONPath(p); err != nil {
+			errs = append(errs, fmt.Errorf("unorderedLists: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// unorderedListsFor returns the parsed JSONPaths of the lists compared regardless of the order of their items for the
+// template: the ones of the reference followed by the ones of the template. The paths are validated when the reference
+// is loaded.
+func unorderedListsFor(ref Reference, temp ReferenceTemplate) [][]jsonPathSegment {
+	var result [][]jsonPathSegment
+	for _, p := range slices.Concat(ref.GetUnorderedLists(), temp.GetConfig().GetUnorderedLists()) {
+		if segments, err := parseJSONPath(p); err == nil {
+			result = append(result, segments)
+		}
+	}
+	return result
+}
+
+// sortUnorderedLists sorts the items of the lists selected by the JSONPaths in a canonical order, by their JSON
+// representation, so lists holding the same items in a different order are equal. Lists nested in unordered lists
+// (e.g. .spec.containers and .spec.containers[*].env) are sorted after their parent, the paths are applied in order.
+func sortUnorderedLists(object map[string]any, jsonPaths [][]jsonPathSegment) {
+	for _, segments := range jsonPaths {
+		for _, field := range findJSONPathFields(object, segments) {
+			value, _, _ := NestedField(object, field...)
+			list, ok := value.([]any)
+			if !ok {
+				continue
+			}
+			sorted := slices.Clone(list)
+			slices.SortStableFunc(sorted, func(a, b any) int {
+				return strings.Compare(formatFieldValue(a), formatFieldValue(b))
+			})
+			setNestedField(object, sorted, field)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package compare
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSortUnorderedLists(t *testing.T) {
+	newObject := func(first, second map[string]any) map[string]any {
+		return map[string]any{
+			"spec": map[string]any{
+				"containers":  []any{first, second},
+				"tolerations": "not a list",
+			},
+		}
+	}
+	a := func(env ...any) map[string]any { return map[string]any{"name": "a", "env": env} }
+	b := func(env ...any) map[string]any { return map[string]any{"name": "b", "env": env} }
+
+	var paths [][]jsonPathSegment
+	for _, p := range []string{".spec.containers", ".spec.containers[*].env", ".spec.tolerations", ".spec.missing"} {
+		segments, err := parseJSONPath(p)
+		require.NoError(t, err)
+		paths = append(paths, segments)
+	}
+
+	sorted := newObject(a("x", "y"), b("z"))
+	sortUnorderedLists(sorted, paths)
+	for _, object := range []map[string]any{newObject(b("z"), a("y", "x")), newObject(a("y", "x"), b("z"))} {
+		sortUnorderedLists(object, paths)
+		require.Equal(t, sorted, object)
+	}
+
+	unsorted := newObject(b("z"), a("y", "x"))
+	sortUnorderedLists(unsorted, paths[1:2])
+	require.Equal(t, newObject(b("z"), a("x", "y")), unsorted, "only the selected lists should be sorted")
+}
+
+func TestValidateUnorderedLists(t *testing.T) {
+	require.NoError(t, validateUnorderedLists([]string{".spec.containers", "{.spec.containers[*].env}"}))
+	require.ErrorContains(t, validateUnorderedLists([]string{".spec.containers", ".spec.containers[*"}), "unorderedLists")
+}
@@ -0,0 +1,385 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// UserFieldsToOmit omits fields of the cluster CRs matching the selector at comparison time, on top of the
+// fieldsToOmit of the reference. Empty selector fields match any value, the kind is required.
+type UserFieldsToOmit struct {
+	APIVersion string           `json:"apiVersion,omitempty"`
+	Kind       string           `json:"kind"`
+	Namespace  string           `json:"namespace,omitempty"`
+	Name       string           `json:"name,omitempty"`
+	Paths      []*UserFieldPath `json:"paths"`
+}
+
+// UserFieldPath is a path of a field to omit, either in the ManifestPath syntax used by the reference (pathToKey and
+// isPrefix) or as a JSONPath expression
+type UserFieldPath struct {
+	*ManifestPathV1
+	JSONPath string `json:"jsonPath,omitempty"`
+	jsonPath []jsonPathSegment
+}
+
+func (p *UserFieldPath) process() error {
+	hasManifestPath := p.ManifestPathV1 != nil && p.PathToKey != ""
+	switch {
+	case hasManifestPath && p.JSONPath != "":
+		return errors.New("must have either pathToKey or jsonPath, not both")
+	case hasManifestPath:
+		return p.ManifestPathV1.Process()
+	case p.JSONPath != "":
+		var err error
+		p.jsonPath, err = parseJSONPath(p.JSONPath)
+		return err
+	}
+	return errors.New("must have either pathToKey or jsonPath")
+}
+
+func (u *UserFieldsToOmit) process() error {
+	if u.Kind == "" {
+		return errors.New("kind is required")
+	}
+	if len(u.Paths) == 0 {
+		return errors.New("at least one path is required")
+	}
+	var errs []error
+	for i, p := range u.Paths {
+		if err := p.process(); err != nil {
+			errs = append(errs, fmt.Errorf("paths[%d]: %w", i, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (u *UserFieldsToOmit) matches(cr *unstructured.Unstructured) bool {
+	return u.Kind == cr.GetKind() &&
+		(u.APIVersion == "" || u.APIVersion == cr.GetAPIVersion()) &&
+		(u.Namespace == "" || u.Namespace == cr.GetNamespace()) &&
+		(u.Name == "" || u.Name == cr.GetName())
+}
+
+func (c *UserConfig) process() error {
+	var errs []error
+	for i, u := range c.FieldsToOmit {
+		if err := u.process(); err != nil {
+			errs = append(errs, fmt.Errorf("fieldsToOmit[%d]: %w", i, err))
+		}
+	}
+	if err := validateFieldWeights("correlationSettings.differenceWeights", c.CorrelationSettings.DifferenceWeights); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// fieldsToOmitFor returns the paths the user config omits for the cluster CR, split by their syntax
+func (c *UserConfig) fieldsToOmitFor(cr *unstructured.Unstructured) ([]*ManifestPathV1, [][]jsonPathSegment) {
+	var manifestPaths []*ManifestPathV1
+	var jsonPaths [][]jsonPathSegment
+	for _, u := range c.FieldsToOmit {
+		if !u.matches(cr) {
+			continue
+		}
+		for _, p := range u.Paths {
+			if p.jsonPath != nil {
+				jsonPaths = append(jsonPaths, p.jsonPath)
+			} else {
+				manifestPaths = append(manifestPaths, p.ManifestPathV1)
+			}
+		}
+	}
+	return manifestPaths, jsonPaths
+}
+
+// jsonPathSegment is a step of a JSONPath: a key of a mapping (a glob pattern when it contains *), an index of a list
+// (negative indexes count from the end), a filter of the items of a list or a wildcard matching all the keys or items
+type jsonPathSegment struct {
+	key      string
+	pattern  bool
+	index    *int
+	filter   *jsonPathFilter
+	wildcard bool
+}
+
+// jsonPathFilter selects the items of a list whose field is equal (or not equal) to a value, e.g.
+// [?(@.name=="manager")]
+type jsonPathFilter struct {
+	field    []string
+	value    string
+	notEqual bool
+}
+
+func (f *jsonPathFilter) matches(item any) bool {
+	mapping, ok := item.(map[string]any)
+	if !ok {
+		return false
+	}
+	value, found, _ := NestedField(mapping, f.field...)
+	equal := found && fmt.Sprint(value) == f.value
+	return equal != f.notEqual
+}
+
+// newKeySegment creates the segment of a key, keys containing * are glob patterns (e.g. kubectl.kubernetes.io/*)
+func newKeySegment(key string) jsonPathSegment {
+	if key == "*" {
+		return jsonPathSegment{key: key, wildcard: true}
+	}
+	return jsonPathSegment{key: key, pattern: strings.Contains(key, "*")}
+}
+
+func (s jsonPathSegment) matchesKey(key string) bool {
+	if s.wildcard {
+		return true
+	}
+	if s.pattern {
+		matched, _ := path.Match(s.key, key)
+		return matched
+	}
+	return s.key == key
+}
+
+// parseJSONPath parses the subset of JSONPath that selects fields: dot and bracket notation for keys (quoted with
+// either notation when they contain dots), glob patterns of keys, list indexes, filters of list items by the value of
+// a field and wildcards, e.g. {.metadata.annotations['example.com/*']}, .spec.containers[*].env or
+// .spec.containers[?(@.name=="manager")].image. Slices and recursive descent aren't supported.
+func parseJSONPath(expr string) ([]jsonPathSegment, error) {
+	s := strings.TrimSpace(expr)
+	if strings.HasPrefix(s, "{") && strings.HasSuffix(s, "}") {
+		s = s[1 : len(s)-1]
+	}
+	s = strings.TrimPrefix(s, "$")
+	var segments []jsonPathSegment
+	for s != "" {
+		switch {
+		case strings.HasPrefix(s, ".."):
+			return nil, fmt.Errorf("invalid jsonPath %q: recursive descent isn't supported", expr)
+		case strings.HasPrefix(s, `."`):
+			// Keys quoted as in the pathToKey syntax, e.g. .metadata.annotations."example.com/key"
+			end := strings.Index(s[2:], `"`)
+			if end == -1 {
+				return nil, fmt.Errorf("invalid jsonPath %q: unterminated quoted key", expr)
+			}
+			segments = append(segments, newKeySegment(s[2:end+2]))
+			s = s[end+3:]
+		case s[0] == '.':
+			end := strings.IndexAny(s[1:], ".[")
+			if end == -1 {
+				end = len(s) - 1
+			}
+			key := s[1 : end+1]
+			if key == "" {
+				return nil, fmt.Errorf("invalid jsonPath %q: empty key", expr)
+			}
+			segments = append(segments, newKeySegment(key))
+			s = s[end+1:]
+		case strings.HasPrefix(s, "['") || strings.HasPrefix(s, `["`):
+			// Quoted keys may contain dots and brackets, they end at the closing quote followed by ]
+			end := strings.Index(s[2:], s[1:2]+"]")
+			if end == -1 {
+				return nil, fmt.Errorf("invalid jsonPath %q: unterminated quoted key", expr)
+			}
+			segments = append(segments, newKeySegment(s[2:end+2]))
+			s = s[end+4:]
+		case strings.HasPrefix(s, "[?("):
+			end := strings.Index(s, ")]")
+			if end == -1 {
+				return nil, fmt.Errorf("invalid jsonPath %q: unterminated filter", expr)
+			}
+			filter, err := parseJSONPathFilter(s[3:end])
+			if err != nil {
+				return nil, fmt.Errorf("invalid jsonPath %q: %w", expr, err)
+			}
+			segments = append(segments, jsonPathSegment{filter: filter})
+			s = s[end+2:]
+		case s[0] == '[':
+			end := strings.Index(s, "]")
+			if end == -1 {
+				return nil, fmt.Errorf("invalid jsonPath %q: unterminated [", expr)
+			}
+			inner := s[1:end]
+			if inner == "*" {
+				segments = append(segments, jsonPathSegment{wildcard: true})
+			} else {
+				index, err := strconv.Atoi(inner)
+				if err != nil {
+					return nil, fmt.Errorf("invalid jsonPath %q: unsupported selector [%s], only keys, indexes, filters and [*] are supported", expr, inner)
+				}
+				segments = append(segments, jsonPathSegment{index: &index})
+			}
+			s = s[end+1:]
+		default:
+			return nil, fmt.Errorf("invalid jsonPath %q: expected . or [ at %q", expr, s)
+		}
+	}
+	if len(segments) == 0 {
+		return nil, fmt.Errorf("invalid jsonPath %q: it doesn't select a field", expr)
+	}
+	return segments, nil
+}
+
+// parseJSONPathFilter parses the expression of a filter, a comparison of a field of the item with a value:
+// @.<field> == <value> or @.<field> != <value>, the value may be quoted
+func parseJSONPathFilter(expr string) (*jsonPathFilter, error) {
+	filter := &jsonPathFilter{}
+	left, right, found := strings.Cut(expr, "==")
+	if !found {
+		left, right, found = strings.Cut(expr, "!=")
+		filter.notEqual = true
+	}
+	left = strings.TrimSpace(left)
+	if !found || !strings.HasPrefix(left, "@.") {
+		return nil, fmt.Errorf("unsupported filter %q, only @.<field> == <value> and @.<field> != <value> are supported", expr)
+	}
+	filter.field = strings.Split(strings.TrimPrefix(left, "@."), ".")
+	if slices.Contains(filter.field, "") {
+		return nil, fmt.Errorf("unsupported filter %q: empty key", expr)
+	}
+	filter.value = strings.TrimSpace(right)
+	if len(filter.value) >= 2 && strings.ContainsAny(filter.value[:1], `"'`) && filter.value[len(filter.value)-1] == filter.value[0] {
+		filter.value = filter.value[1 : len(filter.value)-1]
+	}
+	return filter, nil
+}
+
+// findJSONPathFields returns the paths of all the fields in the object selected by the JSONPath, list indexes are
+// included in the paths in their decimal form
+func findJSONPathFields(object any, segments []jsonPathSegment) [][]string {
+	if len(segments) == 0 {
+		return [][]string{{}}
+	}
+	var result [][]string
+	add := func(key string, child any) {
+		for _, rest := range findJSONPathFields(child, segments[1:]) {
+			result = append(result, append([]string{key}, rest...))
+		}
+	}
+	segment := segments[0]
+	switch v := object.(type) {
+	case map[string]any:
+		if segment.wildcard || segment.pattern {
+			for key, child := range v {
+				if segment.matchesKey(key) {
+					add(key, child)
+				}
+			}
+		} else if child, ok := v[segment.key]; ok && segment.index == nil && segment.filter == nil {
+			add(segment.key, child)
+		}
+	case []any:
+		switch {
+		case segment.wildcard:
+			for i, child := range v {
+				add(strconv.Itoa(i), child)
+			}
+		case segment.filter != nil:
+			for i, child := range v {
+				if segment.filter.matches(child) {
+					add(strconv.Itoa(i), child)
+				}
+			}
+		case segment.index != nil:
+			i := *segment.index
+			if i < 0 {
+				i += len(v)
+			}
+			if i >= 0 && i < len(v) {
+				add(strconv.Itoa(i), v[i])
+			}
+		}
+	}
+	return result
+}
+
+// omitJSONPathFields removes the fields selected by the JSONPaths from the object. List items are removed from the
+// highest index down so the indexes of the remaining items stay valid, and mappings left empty are removed.
+func omitJSONPathFields(object map[string]any, jsonPaths [][]jsonPathSegment) {
+	var fieldPaths [][]string
+	for _, segments := range jsonPaths {
+		fieldPaths = append(fieldPaths, findJSONPathFields(object, segments)...)
+	}
+	omitFieldPaths(object, fieldPaths)
+}
+
+// omitFieldPaths removes the fields from the object, from the highest list index down, and the mappings left empty
+func omitFieldPaths(object map[string]any, fieldPaths [][]string) {
+	slices.SortFunc(fieldPaths, func(a, b []string) int { return -compareFieldPaths(a, b) })
+	fieldPaths = slices.CompactFunc(fieldPaths, slices.Equal[[]string])
+	for _, field := range fieldPaths {
+		removeNestedField(object, field)
+		for i := 1; i < len(field); i++ {
+			val, _, _ := NestedField(object, field[:len(field)-i]...)
+			if mapping, ok := val.(map[string]any); ok && len(mapping) == 0 {
+				removeNestedField(object, field[:len(field)-i])
+			}
+		}
+	}
+}
+
+// compareFieldPaths orders paths by their fields, comparing list indexes numerically
+func compareFieldPaths(a, b []string) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] == b[i] {
+			continue
+		}
+		ai, aErr := strconv.Atoi(a[i])
+		bi, bErr := strconv.Atoi(b[i])
+		if aErr == nil && bErr == nil {
+			return ai - bi
+		}
+		return strings.Compare(a[i], b[i])
+	}
+	return len(a) - len(b)
+}
+
+// removeNestedField removes the field from the object, unlike unstructured.RemoveNestedField the path may go through
+// lists and the field may be an item of a list
+func removeNestedField(object map[string]any, field []string) {
+	if len(field) == 0 {
+		return
+	}
+	parent, found, _ := NestedField(object, field[:len(field)-1]...)
+	if !found {
+		return
+	}
+	last := field[len(field)-1]
+	switch v := parent.(type) {
+	case map[string]any:
+		delete(v, last)
+	case []any:
+		index, err := strconv.Atoi(last)
+		if err != nil || index < 0 || index >= len(v) {
+			return
+		}
+		setNestedField(object, slices.Delete(slices.Clone(v), index, index+1), field[:len(field)-1])
+	}
+}
+
+// setNestedField replaces the value of an existing field, the path may go through lists
+func setNestedField(object map[string]any, value any, field []string) {
+	if len(field) == 0 {
+		return
+	}
+	parent, found, _ := NestedField(object, field[:len(field)-1]...)
+	if !found {
+		return
+	}
+	last := field[len(field)-1]
+	switch v := parent.(type) {
+	case map[string]any:
+		v[last] = value
+	case []any:
+		if index, err := strconv.Atoi(last); err == nil && index >= 0 && index < len(v) {
+			v[index] = value
+		}
+	}
+}
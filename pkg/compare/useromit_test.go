@@ -0,0 +1,165 @@
+package compare
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOmitJSONPathFields(t *testing.T) {
+	newObject := func() map[string]any {
+		return map[string]any{
+			"metadata": map[string]any{
+				"annotations": map[string]any{
+					"example.com/a.b[0]": "x",
+				},
+				"name": "n",
+			},
+			"spec": map[string]any{
+				"containers": []any{
+					map[string]any{"name": "a", "env": []any{"e1"}},
+					map[string]any{"name": "b", "env": []any{"e2"}},
+				},
+				"tolerations": []any{"t0", "t1", "t2"},
+			},
+		}
+	}
+	cases := []struct {
+		name        string
+		jsonPaths   []string
+		expectError string
+		expected    func(map[string]any)
+	}{
+		{
+			name:      "quoted key with dots and brackets, empty parent removed",
+			jsonPaths: []string{`{.metadata.annotations['example.com/a.b[0]']}`},
+			expected: func(o map[string]any) {
+				delete(o["metadata"].(map[string]any), "annotations")
+			},
+		},
+		{
+			name:      "wildcard over list",
+			jsonPaths: []string{"$.spec.containers[*].env"},
+			expected: func(o map[string]any) {
+				for _, c := range o["spec"].(map[string]any)["containers"].([]any) {
+					delete(c.(map[string]any), "env")
+				}
+			},
+		},
+		{
+			name:      "list items are removed from the highest index down",
+			jsonPaths: []string{".spec.tolerations[0]", ".spec.tolerations[-1]"},
+			expected: func(o map[string]any) {
+				o["spec"].(map[string]any)["tolerations"] = []any{"t1"}
+			},
+		},
+		{
+			name:      "missing fields are ignored",
+			jsonPaths: []string{".spec.missing.field", ".spec.tolerations[5]", ".metadata.name[0]"},
+			expected:  func(map[string]any) {},
+		},
+		{
+			name:      "filter of list items",
+			jsonPaths: []string{`{.spec.containers[?(@.name=='a')].env}`, `.spec.containers[?(@.name != "a")].name`},
+			expected: func(o map[string]any) {
+				containers := o["spec"].(map[string]any)["containers"].([]any)
+				delete(containers[0].(map[string]any), "env")
+				delete(containers[1].(map[string]any), "name")
+			},
+		},
+		{
+			name:      "glob pattern of keys",
+			jsonPaths: []string{`.metadata.annotations["example.com/*"]`, `.metadata.n*e`},
+			expected: func(o map[string]any) {
+				delete(o, "metadata")
+			},
+		},
+		{
+			name:        "slices aren't supported",
+			jsonPaths:   []string{"{.spec.containers[0:1]}"},
+			expectError: "unsupported selector",
+		},
+		{
+			name:        "filters only compare fields",
+			jsonPaths:   []string{"{.spec.containers[?(@.name)]}"},
+			expectError: "unsupported filter",
+		},
+		{
+			name:        "recursive descent isn't supported",
+			jsonPaths:   []string{"{..env}"},
+			expectError: "recursive descent isn't supported",
+		},
+		{
+			name:        "unterminated quoted key",
+			jsonPaths:   []string{".metadata['name"},
+			expectError: "unterminated quoted key",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var segments [][]jsonPathSegment
+			for _, p := range tc.jsonPaths {
+				parsed, err := parseJSONPath(p)
+				if tc.expectError != "" {
+					require.ErrorContains(t, err, tc.expectError)
+					return
+				}
+				require.NoError(t, err)
+				segments = append(segments, parsed)
+			}
+			object := newObject()
+			omitJSONPathFields(object, segments)
+			expected := newObject()
+			tc.expected(expected)
+			assert.Equal(t, expected, object)
+		})
+	}
+}
+
+func TestManifestPathJSONPathSyntax(t *testing.T) {
+	paths := []*ManifestPathV1{
+		{PathToKey: "spec.containers[*].image"},
+		{PathToKey: `metadata.annotations["kubectl.kubernetes.io/*"]`},
+		{PathToKey: `metadata.*."example.com/"`, IsPrefix: true},
+		{PathToKey: `spec.containers[?(@.name=="proxy")].args`},
+		{PathToKey: "spec.replicas"},
+	}
+	for _, p := range paths {
+		require.NoError(t, p.Process())
+	}
+	require.Nil(t, paths[4].segments, "paths without lists or wildcards keep the pathToKey syntax")
+
+	object := map[string]any{
+		"metadata": map[string]any{
+			"annotations": map[string]any{"kubectl.kubernetes.io/restartedAt": "now", "other": "kept"},
+			"labels":      map[string]any{"example.com/a": "x"},
+		},
+		"spec": map[string]any{
+			"replicas": int64(3),
+			"containers": []any{
+				map[string]any{"name": "manager", "image": "m", "args": []any{"a"}},
+				map[string]any{"name": "proxy", "image": "p", "args": []any{"b"}},
+			},
+		},
+	}
+	omitFields(object, paths)
+	assert.Equal(t, map[string]any{
+		"metadata": map[string]any{
+			"annotations": map[string]any{"other": "kept"},
+		},
+		"spec": map[string]any{
+			"containers": []any{
+				map[string]any{"name": "manager", "args": []any{"a"}},
+				map[string]any{"name": "proxy"},
+			},
+		},
+	}, object)
+
+	assert.True(t, omitsField(paths[1], []string{"metadata", "annotations", "kubectl.kubernetes.io/restartedAt"}))
+	assert.True(t, omitsField(paths[2], []string{"metadata", "labels", "example.com/b", "nested"}))
+	assert.False(t, omitsField(paths[0], []string{"spec", "containers"}), "fields of templates don't go through lists")
+
+	invalid := &ManifestPathV1{PathToKey: "spec.containers[*]", IsPrefix: true}
+	require.ErrorContains(t, invalid.Process(), "isPrefix requires the path to end with a key")
+}
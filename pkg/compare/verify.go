@@ -0,0 +1,129 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+const (
+	checksumPrefix            = "sha256:"
+	unsupportedSignature      = "unsupported signature %q: only sha256 checksums in the form of sha256:<hex digest> are supported, cosign and sigstore signatures aren't"
+	referenceChecksumMismatch = "reference %s doesn't match the expected checksum: expected %s, got %s"
+	unverifiedRemoteReference = "The reference %s is loaded over http without being verified. Use --verify-signature to verify it, or --insecure-skip-verify to load it without verification"
+	unverifiedRemoteTemplates = "--verify-signature only covers the reference config of %s, not its templates. Bundle the reference or pin its templates with --reference-lock"
+)
+
+// parseChecksum validates the checksum passed to --verify-signature and returns it normalized
+func parseChecksum(signature string) (string, error) {
+	digest, ok := strings.CutPrefix(signature, checksumPrefix)
+	if !ok {
+		return "", fmt.Errorf(unsupportedSignature, signature)
+	}
+	if _, err := hex.DecodeString(digest); err != nil || len(digest) != sha256.Size*2 {
+		return "", fmt.Errorf(unsupportedSignature, signature)
+	}
+	return checksumPrefix + strings.ToLower(digest), nil
+}
+
+func checksumOf(content []byte) string {
+	sum := sha256.Sum256(content)
+	return checksumPrefix + hex.EncodeToString(sum[:])
+}
+
+// readReference reads a file passed to -r, either from the local file system or from a URL with the client
+func readReference(refConfig string, client *referenceHTTPClient) ([]byte, error) {
+	if !isURL(refConfig) {
+		content, err := os.ReadFile(refConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", refConfig, err)
+		}
+		return content, nil
+	}
+	body, _, err := client.read(refConfig)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	content, err := io.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", refConfig, err)
+	}
+	return content, nil
+}
+
+// GetVerifiedRefFS is the same as GetRefFS, but the file passed to -r (the bundle or the reference config) is
+// verified against the checksum before it's used. Templates of a reference that isn't bundled aren't covered by the
+// checksum, they can be pinned with a reference lock.
+func GetVerifiedRefFS(refConfig, checksum string) (fs.FS, error) {
+	client, err := defaultReferenceClient()
+	if err != nil {
+		return nil, err
+	}
+	return getVerifiedRefFS(refConfig, checksum, client)
+}
+
+func getVerifiedRefFS(refConfig, checksum string, client *referenceHTTPClient) (fs.FS, error) {
+	if checksum == "" {
+		return getRefFS(refConfig, client, nil)
+	}
+	expected, err := parseChecksum(checksum)
+	if err != nil {
+		return nil, err
+	}
+	if isBundle(refConfig) {
+		content, err := readReference(refConfig, client)
+		if err != nil {
+			return nil, err
+		}
+		if actual := checksumOf(content); actual != expected {
+			return nil, fmt.Errorf(referenceChecksumMismatch, refConfig, expected, actual)
+		}
+		return readBundle(bytes.NewReader(content))
+	}
+	fsys, err := getRefFS(refConfig, client, nil)
+	if err != nil {
+		return nil, err
+	}
+	return checksumFS{FS: fsys, refConfig: refConfig, name: ReferenceFileName(refConfig), checksum: expected}, nil
+}
+
+// checksumFS verifies the reference config against the checksum every time it's opened
+type checksumFS struct {
+	fs.FS
+	refConfig string
+	name      string
+	checksum  string
+}
+
+func (c checksumFS) Open(name string) (fs.File, error) {
+	f, err := c.FS.Open(name)
+	if err != nil || name != c.name {
+		return f, err // nolint:wrapcheck
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to stat %s: %w", name, err)
+	}
+	content, err := io.ReadAll(f)
+	f.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	if actual := checksumOf(content); actual != c.checksum {
+		return nil, fmt.Errorf(referenceChecksumMismatch, c.refConfig, c.checksum, actual)
+	}
+	return lockedFile{Reader: bytes.NewReader(content), info: info}, nil
+}
+
+func (c checksumFS) url(name string) (string, error) {
+	return fileURL(c.FS, name)
+}
@@ -0,0 +1,51 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package compare
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetVerifiedRefFS(t *testing.T) {
+	dir := t.TempDir()
+	content := []byte("apiVersion: v2\n")
+	refConfig := filepath.Join(dir, "metadata.yaml")
+	require.NoError(t, os.WriteFile(refConfig, content, 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "cm.yaml"), []byte("kind: ConfigMap\n"), 0o600))
+
+	fsys, err := GetVerifiedRefFS(refConfig, checksumOf(content))
+	require.NoError(t, err)
+	read, err := fs.ReadFile(fsys, "metadata.yaml")
+	require.NoError(t, err)
+	require.Equal(t, content, read)
+	// Only the reference config is covered by the checksum
+	_, err = fs.ReadFile(fsys, "cm.yaml")
+	require.NoError(t, err)
+
+	fsys, err = GetVerifiedRefFS(refConfig, checksumOf([]byte("other")))
+	require.NoError(t, err)
+	_, err = fs.ReadFile(fsys, "metadata.yaml")
+	require.ErrorContains(t, err, "doesn't match the expected checksum")
+
+	_, err = GetVerifiedRefFS(refConfig, "sha256:1234")
+	require.ErrorContains(t, err, "unsupported signature")
+}
+
+func TestVerifySignatureOfRemoteReference(t *testing.T) {
+	checksum := "sha256:AFE61F5D1B308863BAFB45FFD0E6C4FCF85368FD544A17BF599FBCC1465DEE8E"
+	o := &Options{referenceConfig: "https://example.com/metadata.yaml", verifySignature: checksum}
+	_, _, err := o.referenceFS()
+	require.EqualError(t, err, "--verify-signature only covers the reference config of https://example.com/metadata.yaml, not its templates. "+
+		"Bundle the reference or pin its templates with --reference-lock")
+	require.ErrorAs(t, err, &usageError{})
+
+	// The lock is read before anything is fetched
+	o.referenceLock = filepath.Join(t.TempDir(), DefaultLockFileName)
+	_, _, err = o.referenceFS()
+	require.ErrorContains(t, err, "failed to read reference lock")
+}